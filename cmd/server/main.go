@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -12,6 +13,7 @@ import (
 	"time"
 
 	"github.com/denwilliams/go-mqtt-automation/pkg/config"
+	"github.com/denwilliams/go-mqtt-automation/pkg/metrics"
 	"github.com/denwilliams/go-mqtt-automation/pkg/mqtt"
 	"github.com/denwilliams/go-mqtt-automation/pkg/state"
 	"github.com/denwilliams/go-mqtt-automation/pkg/strategy"
@@ -22,7 +24,10 @@ import (
 var (
 	configPath  = flag.String("config", "config/config.yaml", "Path to configuration file")
 	migrate     = flag.Bool("migrate", false, "Run database migrations and exit")
+	migrateDown = flag.Bool("migrate-down", false, "Roll back the most recent database migration and exit")
+	migrateTo   = flag.Int("migrate-to-version", -1, "Migrate the database up or down to a specific schema version and exit")
 	showVersion = flag.Bool("version", false, "Show version and exit")
+	force       = flag.Bool("force", false, "Start even if the database schema is newer than this build expects")
 
 	// Build-time variables
 	version   = "dev"
@@ -38,6 +43,7 @@ type Application struct {
 	logger         *log.Logger
 	stateManager   *state.Manager
 	strategyEngine *strategy.Engine
+	strategyFiles  *strategy.FileLoader
 	topicManager   *topics.Manager
 	mqttClient     *mqtt.Client
 	webServer      *web.Server
@@ -47,6 +53,11 @@ type Application struct {
 }
 
 func main() {
+	// Isolated strategy executions re-run this binary as a worker
+	if strategy.IsIsolatedWorker() {
+		os.Exit(strategy.RunIsolatedWorker(os.Stdin, os.Stdout))
+	}
+
 	flag.Parse()
 
 	if *showVersion {
@@ -54,21 +65,19 @@ func main() {
 		return
 	}
 
+	if *migrate || *migrateDown || *migrateTo >= 0 {
+		if err := runMigrations(*configPath); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
+	}
+
 	app, err := NewApplication(*configPath)
 	if err != nil {
 		log.Fatalf("Failed to initialize application: %v", err)
 	}
 	defer app.Cleanup()
 
-	if *migrate {
-		log.Println("Running database migrations...")
-		if err := app.stateManager.Close(); err != nil {
-			log.Printf("Error closing state manager: %v", err)
-		}
-		log.Println("Migrations completed successfully")
-		return
-	}
-
 	// Handle graceful shutdown
 	app.setupSignalHandling()
 
@@ -82,6 +91,44 @@ func main() {
 	log.Println("Application shutdown complete")
 }
 
+// runMigrations applies the requested migration command without starting the application
+func runMigrations(configPath string) error {
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return err
+	}
+
+	logger := log.New(os.Stdout, "", log.LstdFlags)
+
+	stateManager, err := state.OpenManager(cfg.Database, logger)
+	if err != nil {
+		return err
+	}
+	defer stateManager.Close()
+
+	switch {
+	case *migrateDown:
+		logger.Println("Rolling back the most recent database migration...")
+		err = stateManager.MigrateDown()
+	case *migrateTo >= 0:
+		logger.Printf("Migrating database to version %d...", *migrateTo)
+		err = stateManager.MigrateTo(uint(*migrateTo))
+	default:
+		logger.Println("Running database migrations...")
+		err = stateManager.MigrateUp()
+	}
+	if err != nil {
+		return err
+	}
+
+	version, dirty, err := stateManager.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	logger.Printf("Database schema version: %d (dirty: %v)", version, dirty)
+	return nil
+}
+
 func NewApplication(configPath string) (*Application, error) {
 	// Load configuration
 	cfg, err := config.Load(configPath)
@@ -116,8 +163,12 @@ func NewApplication(configPath string) (*Application, error) {
 func (a *Application) initializeComponents() error {
 	var err error
 
+	// Bound the cardinality of topic-labeled metrics
+	metrics.SetTopicAllowlist(a.config.Metrics.TopicAllowlist)
+
 	// Initialize state manager
 	a.logger.Println("Initializing state manager...")
+	a.config.Database.ForceSchemaVersion = *force
 	a.stateManager, err = state.NewManager(a.config.Database, a.logger)
 	if err != nil {
 		return err
@@ -126,34 +177,133 @@ func (a *Application) initializeComponents() error {
 	// Initialize strategy engine
 	a.logger.Println("Initializing strategy engine...")
 	a.strategyEngine = strategy.NewEngine(a.logger)
+	if err := a.configureCircuitBreaker(); err != nil {
+		return err
+	}
+	if poolSize := a.config.Strategies.ExecutionPoolSize; poolSize > 0 {
+		a.strategyEngine.SetExecutionPoolSize(poolSize)
+		a.logger.Printf("Running strategies on an execution pool of %d workers", poolSize)
+	}
+	if err := a.strategyEngine.SetPostProcessors(a.config.Strategies.PostProcessors); err != nil {
+		return fmt.Errorf("invalid strategy post-processors: %w", err)
+	}
+	asyncTimeout, err := time.ParseDuration(a.config.Strategies.AsyncTimeout)
+	if err != nil {
+		return fmt.Errorf("invalid strategies async_timeout: %w", err)
+	}
+	a.strategyEngine.SetAsyncTimeout(asyncTimeout)
+	if size := a.config.Strategies.ProgramCacheSize; size != nil {
+		a.strategyEngine.SetProgramCacheSize(*size)
+	}
+	a.strategyEngine.SetStateStore(a.stateManager)
+	a.strategyEngine.SetInputLimits(strategy.InputLimits{
+		MaxInputs: a.config.Strategies.InputLimits.MaxInputs,
+		MaxBytes:  a.config.Strategies.InputLimits.MaxBytes,
+		Policy:    a.config.Strategies.InputLimits.Policy,
+	})
+	if err := a.registerIsolatedExecutor(); err != nil {
+		return err
+	}
 
 	// Load strategies from database
 	if loadErr := a.loadStrategies(); loadErr != nil {
 		a.logger.Printf("Warning: Failed to load strategies: %v", loadErr)
 	}
 
+	// Load and watch file-backed strategies
+	if dir := a.config.Strategies.Directory; dir != "" {
+		a.strategyFiles = strategy.NewFileLoader(a.strategyEngine, dir, a.logger)
+		a.strategyFiles.SetReloadHandler(func(strat *strategy.Strategy) {
+			if err := a.stateManager.SaveStrategy(strat); err != nil {
+				a.logger.Printf("Failed to save file strategy %s: %v", strat.ID, err)
+			}
+		})
+		if err := a.strategyFiles.Start(); err != nil {
+			return err
+		}
+	}
+
 	// Initialize topic manager
 	a.logger.Println("Initializing topic manager...")
 	a.topicManager = topics.NewManager(a.logger)
 	a.topicManager.SetStrategyExecutor(a.strategyEngine)
+	a.strategyEngine.SetTopicReader(a.topicManager)
 	a.topicManager.SetStateManager(a.stateManager)
+	a.topicManager.SetExecutionRecorder(a.stateManager)
+	a.topicManager.SetSystemEventRecorder(a.stateManager)
+	a.topicManager.SetSnapshotStore(a.stateManager)
+	a.topicManager.SetBinaryTopicPatterns(a.config.MQTT.BinaryTopics)
+	a.topicManager.SetIngressStrategies(ingressStrategies(a.config.MQTT.IngressStrategies))
+	a.topicManager.SetPayloadTimestamps(payloadTimestamps(a.config.MQTT.PayloadTimestamps))
+	a.topicManager.SetCanonicalJSON(a.config.MQTT.CanonicalJSON)
+	a.topicManager.SetTopicNormalization(topicNormalization(a.config.MQTT.TopicNormalization))
+	if maxNameLength := a.config.Topics.MaxNameLength; maxNameLength != nil {
+		a.topicManager.SetMaxTopicNameLength(*maxNameLength)
+	}
+	if err := a.topicManager.SetMissingInputPolicy(topics.MissingInputPolicy(a.config.Topics.MissingInputPolicy)); err != nil {
+		return err
+	}
+	a.topicManager.SetWritableTargets(a.config.Topics.WritableTargets)
+	if err := a.registerEventSinks(); err != nil {
+		return err
+	}
+	if err := a.topicManager.SetDeadLetterTopic(a.config.Strategies.DeadLetterTopic); err != nil {
+		return err
+	}
+	if err := a.topicManager.SetMaxInFlight(a.config.Strategies.MaxInFlight, topics.BackpressurePolicy(a.config.Strategies.BackpressurePolicy)); err != nil {
+		return err
+	}
 
 	// Initialize MQTT client
 	a.logger.Println("Initializing MQTT client...")
 	a.mqttClient = mqtt.NewClient(a.config.MQTT, a.logger)
 	a.topicManager.SetMQTTClient(a.mqttClient)
 	a.mqttClient.SetTopicManager(a.topicManager)
+	a.mqttClient.SetConnectionHandler(func(connected bool, err error) {
+		if connected {
+			a.emitSystemEvent("mqtt_connected", map[string]interface{}{
+				"broker": a.config.MQTT.Broker,
+			})
+			return
+		}
+		a.emitSystemEvent("mqtt_disconnected", map[string]interface{}{
+			"broker": a.config.MQTT.Broker,
+			"error":  err.Error(),
+		})
+	})
 
 	// Load topics from database
 	if loadErr := a.loadTopics(); loadErr != nil {
 		a.logger.Printf("Warning: Failed to load topics: %v", loadErr)
 	}
 
-	// Initialize system topics
+	// Create system topics; their tickers start in Start, once everything
+	// they may trigger has loaded
 	if initErr := a.topicManager.InitializeSystemTopics(a.config.SystemTopics); initErr != nil {
 		return initErr
 	}
 
+	// Seed last values (including external topics) before the MQTT client
+	// connects in Start, so retained replays are compared against them
+	a.topicManager.SetDedupeAcrossRestart(a.config.Topics.DedupeAcrossRestart)
+	if a.config.Topics.ChainDeadline != "" {
+		chainDeadline, _ := time.ParseDuration(a.config.Topics.ChainDeadline)
+		a.topicManager.SetChainDeadline(chainDeadline)
+	}
+	if err := a.topicManager.RestoreTopicStatesFromDatabase(); err != nil {
+		a.logger.Printf("Warning: Failed to restore topic states: %v", err)
+		// Don't fail startup if state restoration fails
+	}
+
+	// Subscribe to topic inputs the configured subscriptions don't cover, or
+	// with minimal subscriptions only to the inputs themselves
+	if a.config.MQTT.MinimalSubscriptions {
+		a.topicManager.SetMinimalSubscriptions(true)
+	} else {
+		a.restoreSubscriptions()
+	}
+	a.topicManager.SetSubscriber(a.mqttClient)
+
 	// Initialize web server
 	a.logger.Println("Initializing web server...")
 	a.webServer, err = web.NewServer(a.config, a.topicManager, a.strategyEngine, a.stateManager, a.mqttClient, a.logger)
@@ -183,6 +333,29 @@ func (a *Application) loadStrategies() error {
 	return nil
 }
 
+// subscriptionsStateKey stores the MQTT subscriptions added for topic inputs
+const subscriptionsStateKey = "mqtt:subscriptions"
+
+// restoreSubscriptions re-adds persisted input subscriptions and persists any
+// added from now on
+func (a *Application) restoreSubscriptions() {
+	if saved, err := a.stateManager.LoadState(subscriptionsStateKey); err == nil {
+		if patterns, ok := saved.([]interface{}); ok {
+			for _, pattern := range patterns {
+				if topic, ok := pattern.(string); ok {
+					a.mqttClient.AddSubscription(topic)
+				}
+			}
+		}
+	}
+
+	a.mqttClient.SetAddedSubscriptionsHandler(func(topics []string) {
+		if err := a.stateManager.SaveState(subscriptionsStateKey, topics); err != nil {
+			a.logger.Printf("Failed to save MQTT subscriptions: %v", err)
+		}
+	})
+}
+
 func (a *Application) loadTopics() error {
 	topicConfigs, err := a.stateManager.LoadAllTopicConfigs()
 	if err != nil {
@@ -212,18 +385,21 @@ func (a *Application) loadTopics() error {
 
 	a.logger.Printf("Loaded %d topic configurations", len(topicConfigs))
 
-	// Restore topic states from database (including external topics)
-	if err := a.topicManager.RestoreTopicStatesFromDatabase(); err != nil {
-		a.logger.Printf("Warning: Failed to restore topic states: %v", err)
-		// Don't fail startup if state restoration fails
-	}
-
 	return nil
 }
 
 func (a *Application) Start() error {
 	a.logger.Println("Starting application components...")
 
+	// Buffer retained messages arriving before startup has finished
+	var warmup time.Duration
+	if a.config.MQTT.StartupWarmup != "" {
+		warmup, _ = time.ParseDuration(a.config.MQTT.StartupWarmup)
+	}
+	if warmup > 0 {
+		a.topicManager.BeginWarmup()
+	}
+
 	// Start MQTT client
 	if err := a.mqttClient.Connect(); err != nil {
 		a.logger.Printf("Failed to connect to MQTT broker: %v", err)
@@ -234,7 +410,8 @@ func (a *Application) Start() error {
 		go a.handleMQTTMessages()
 	}
 
-	// Start system topics
+	// Start system topic tickers now that strategies, topics and their
+	// states are loaded
 	if err := a.topicManager.StartSystemTopics(); err != nil {
 		a.logger.Printf("Failed to start system topics: %v", err)
 	}
@@ -245,6 +422,21 @@ func (a *Application) Start() error {
 		"config":  a.config,
 	})
 
+	if warmup > 0 {
+		a.wg.Add(1)
+		go a.endWarmup(warmup)
+	}
+
+	if days := a.config.Database.ExecutionLogs.RetentionDays; days > 0 {
+		if a.stateManager.AuditHashChainEnabled() {
+			a.logger.Printf("Warning: execution log cleanup is disabled while the audit hash chain is enabled")
+		} else {
+			interval, _ := time.ParseDuration(a.config.Database.ExecutionLogs.CleanupInterval)
+			a.wg.Add(1)
+			go a.cleanupExecutionLogs(days, interval)
+		}
+	}
+
 	// Start web server
 	a.wg.Add(1)
 	go func() {
@@ -258,6 +450,38 @@ func (a *Application) Start() error {
 	return nil
 }
 
+// endWarmup processes the MQTT messages buffered during startup once the
+// warmup window has passed
+func (a *Application) endWarmup(warmup time.Duration) {
+	defer a.wg.Done()
+
+	select {
+	case <-time.After(warmup):
+		a.topicManager.EndWarmup()
+	case <-a.ctx.Done():
+	}
+}
+
+// cleanupExecutionLogs removes execution logs older than days at startup
+// and then every interval
+func (a *Application) cleanupExecutionLogs(days int, interval time.Duration) {
+	defer a.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := a.stateManager.CleanupOldLogs(days); err != nil {
+			a.logger.Printf("Failed to clean up execution logs: %v", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-a.ctx.Done():
+			return
+		}
+	}
+}
+
 func (a *Application) handleMQTTMessages() {
 	defer a.wg.Done()
 
@@ -270,6 +494,112 @@ func (a *Application) handleMQTTMessages() {
 	a.logger.Println("MQTT message handler stopped")
 }
 
+// registerIsolatedExecutor registers the executor of "subprocess"
+// strategies, which run in a worker process with hard limits
+func (a *Application) registerIsolatedExecutor() error {
+	isolation := a.config.Strategies.Isolation
+	cpuLimit, err := time.ParseDuration(isolation.CPULimit)
+	if err != nil {
+		return fmt.Errorf("invalid isolation cpu_limit: %w", err)
+	}
+	timeout, err := time.ParseDuration(isolation.Timeout)
+	if err != nil {
+		return fmt.Errorf("invalid isolation timeout: %w", err)
+	}
+
+	executor, err := strategy.NewSubprocessExecutor(strategy.IsolationLimits{
+		MemoryMB: isolation.MemoryLimitMB,
+		CPUTime:  cpuLimit,
+		Timeout:  timeout,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create isolated executor: %w", err)
+	}
+	a.strategyEngine.RegisterExecutor(strategy.LanguageSubprocess, executor)
+	return nil
+}
+
+func (a *Application) configureCircuitBreaker() error {
+	breakerConfig := a.config.Strategies.CircuitBreaker
+	cooldown, err := time.ParseDuration(breakerConfig.Cooldown)
+	if err != nil {
+		return fmt.Errorf("invalid circuit breaker cooldown: %w", err)
+	}
+	a.strategyEngine.SetCircuitBreaker(breakerConfig.Threshold, cooldown)
+	a.strategyEngine.SetCircuitOpenHandler(func(strategyID, topicName string, err error) {
+		a.emitSystemEvent("error", map[string]interface{}{
+			"reason":      "circuit_open",
+			"strategy_id": strategyID,
+			"topic":       topicName,
+			"error":       err.Error(),
+			"cooldown":    cooldown.String(),
+		})
+	})
+	return nil
+}
+
+// topicNormalization converts the configured normalization rules
+func topicNormalization(rules []config.TopicNormalizationConfig) []topics.TopicNormalization {
+	normalization := make([]topics.TopicNormalization, len(rules))
+	for i, rule := range rules {
+		normalization[i] = topics.TopicNormalization{Prefix: rule.Prefix, Trim: rule.Trim, Lowercase: rule.Lowercase}
+	}
+	return normalization
+}
+
+func ingressStrategies(rules []config.IngressStrategyConfig) []topics.IngressStrategy {
+	strategies := make([]topics.IngressStrategy, len(rules))
+	for i, rule := range rules {
+		strategies[i] = topics.IngressStrategy{Pattern: rule.Topic, StrategyID: rule.Strategy}
+	}
+	return strategies
+}
+
+func payloadTimestamps(rules []config.PayloadTimestampConfig) []topics.PayloadTimestamp {
+	timestamps := make([]topics.PayloadTimestamp, len(rules))
+	for i, rule := range rules {
+		// Validated when the config was loaded
+		maxSkew, _ := time.ParseDuration(rule.MaxSkew)
+		timestamps[i] = topics.PayloadTimestamp{Pattern: rule.Topic, Pointer: rule.Pointer, MaxSkew: maxSkew}
+	}
+	return timestamps
+}
+
+// registerEventSinks forwards topic updates to the configured sinks
+func (a *Application) registerEventSinks() error {
+	for _, webhook := range a.config.Sinks.Webhooks {
+		options := topics.WebhookOptions{Headers: webhook.Headers, Retries: webhook.Retries}
+		if webhook.Backoff != "" {
+			backoff, err := time.ParseDuration(webhook.Backoff)
+			if err != nil {
+				return fmt.Errorf("invalid webhook backoff: %w", err)
+			}
+			options.Backoff = backoff
+		}
+		if webhook.Timeout != "" {
+			timeout, err := time.ParseDuration(webhook.Timeout)
+			if err != nil {
+				return fmt.Errorf("invalid webhook timeout: %w", err)
+			}
+			options.Timeout = timeout
+		}
+
+		sink, err := topics.NewWebhookSink(webhook.URL, options)
+		if err != nil {
+			return fmt.Errorf("failed to create webhook sink: %w", err)
+		}
+		name := webhook.Name
+		if name == "" {
+			name = webhook.URL
+		}
+		if err := a.topicManager.RegisterSink(name, sink, webhook.BufferSize); err != nil {
+			return err
+		}
+		a.logger.Printf("Forwarding topic updates to webhook %s", name)
+	}
+	return nil
+}
+
 func (a *Application) emitSystemEvent(eventType string, data interface{}) {
 	eventTopic := a.topicManager.GetSystemTopic("system/events/" + eventType)
 	if eventTopic != nil {
@@ -302,36 +632,53 @@ func (a *Application) Wait() {
 	a.logger.Println("Shutting down...")
 
 	// Create shutdown timeout
-	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	shutdownTimeout, err := time.ParseDuration(a.config.ShutdownTimeout)
+	if err != nil {
+		shutdownTimeout = 30 * time.Second
+	}
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer shutdownCancel()
 
-	// Stop system topics
+	// Stop system topics and scheduled executions
 	a.topicManager.StopSystemTopics()
+	a.topicManager.StopSchedules()
 
-	// Shutdown web server
-	if a.webServer != nil {
-		if err := a.webServer.Shutdown(shutdownCtx); err != nil {
-			a.logger.Printf("Error shutting down web server: %v", err)
-		}
+	if a.strategyFiles != nil {
+		a.strategyFiles.Stop()
 	}
 
-	// Disconnect MQTT client
-	if a.mqttClient != nil {
-		a.mqttClient.Disconnect()
-	}
+	// Drain in-flight work, interrupting running strategies if the timeout expires
+	drained := a.strategyEngine.Shutdown(shutdownCtx, func() {
+		// Shutdown web server
+		if a.webServer != nil {
+			if err := a.webServer.Shutdown(shutdownCtx); err != nil {
+				a.logger.Printf("Error shutting down web server: %v", err)
+			}
+		}
 
-	// Wait for goroutines to finish with timeout
-	done := make(chan struct{})
-	go func() {
-		a.wg.Wait()
-		close(done)
-	}()
+		// Disconnect MQTT client
+		if a.mqttClient != nil {
+			a.mqttClient.Disconnect()
+		}
 
-	select {
-	case <-done:
+		// Wait for goroutines to finish
+		a.wg.Wait()
+	})
+	if drained {
 		a.logger.Println("All goroutines stopped")
-	case <-shutdownCtx.Done():
-		a.logger.Println("Shutdown timeout reached")
+	}
+
+	// Stop strategy workers once nothing can trigger executions
+	a.strategyEngine.Close()
+
+	// Deliver the topic updates still queued for event sinks
+	if !a.topicManager.CloseSinks(shutdownCtx) {
+		a.logger.Println("Shutdown timeout reached; undelivered event sink updates were dropped")
+	}
+
+	// Write any batched topic state before the database is closed
+	if err := a.stateManager.Drain(); err != nil {
+		a.logger.Printf("Error writing batched state: %v", err)
 	}
 }
 
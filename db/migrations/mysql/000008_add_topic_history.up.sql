@@ -0,0 +1,11 @@
+-- Add topic value history table for trend queries
+CREATE TABLE IF NOT EXISTS topic_history (
+    id INT PRIMARY KEY AUTO_INCREMENT,
+    topic_name TEXT NOT NULL,
+    value TEXT, -- JSON
+    recorded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Index for time range queries per topic and retention cleanup
+CREATE INDEX IF NOT EXISTS idx_topic_history_topic_recorded ON topic_history(topic_name, recorded_at);
+CREATE INDEX IF NOT EXISTS idx_topic_history_recorded ON topic_history(recorded_at);
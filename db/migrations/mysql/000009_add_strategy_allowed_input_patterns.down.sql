@@ -0,0 +1,2 @@
+-- Remove allowed input topic patterns from strategies
+ALTER TABLE strategies DROP COLUMN allowed_input_patterns;
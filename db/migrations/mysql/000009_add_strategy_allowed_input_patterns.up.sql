@@ -0,0 +1,2 @@
+-- Add allowed input topic patterns to strategies (JSON array)
+ALTER TABLE strategies ADD COLUMN allowed_input_patterns TEXT;
@@ -0,0 +1,3 @@
+-- Add log level to strategies and leveled strategy log messages to execution logs
+ALTER TABLE strategies ADD COLUMN log_level TEXT;
+ALTER TABLE execution_log ADD COLUMN log_messages TEXT;
@@ -0,0 +1,2 @@
+-- Remove strategy file paths
+ALTER TABLE strategies DROP COLUMN file_path;
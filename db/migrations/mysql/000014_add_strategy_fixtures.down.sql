@@ -0,0 +1,2 @@
+-- Remove strategy test fixtures
+DROP TABLE IF EXISTS strategy_fixtures;
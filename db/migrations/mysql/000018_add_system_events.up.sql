@@ -0,0 +1,11 @@
+-- Add system event log for the operational timeline
+CREATE TABLE IF NOT EXISTS system_events (
+    id INT PRIMARY KEY AUTO_INCREMENT,
+    event_type TEXT NOT NULL,
+    data TEXT, -- JSON
+    occurred_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Index for type and time range queries and retention cleanup
+CREATE INDEX IF NOT EXISTS idx_system_events_type_occurred ON system_events(event_type, occurred_at);
+CREATE INDEX IF NOT EXISTS idx_system_events_occurred ON system_events(occurred_at);
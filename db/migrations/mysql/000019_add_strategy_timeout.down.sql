@@ -0,0 +1,2 @@
+-- Remove the per-strategy execution timeout
+ALTER TABLE strategies DROP COLUMN timeout_ms;
@@ -0,0 +1,2 @@
+-- Add the per-strategy execution timeout; NULL uses the executor's default
+ALTER TABLE strategies ADD COLUMN timeout_ms INT;
@@ -0,0 +1,2 @@
+-- Add library flag to strategies (libraries are imported with require() and not executed directly)
+ALTER TABLE strategies ADD COLUMN library BOOLEAN DEFAULT FALSE;
@@ -0,0 +1,3 @@
+-- Remove log level from strategies and log messages from execution logs
+ALTER TABLE execution_log DROP COLUMN log_messages;
+ALTER TABLE strategies DROP COLUMN log_level;
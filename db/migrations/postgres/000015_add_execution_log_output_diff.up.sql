@@ -0,0 +1,2 @@
+-- Add the diff against the previous execution's outputs to execution logs
+ALTER TABLE execution_log ADD COLUMN output_diff TEXT;
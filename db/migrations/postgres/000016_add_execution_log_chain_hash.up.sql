@@ -0,0 +1,2 @@
+-- Add the audit hash chaining each execution log to the topic's previous one
+ALTER TABLE execution_log ADD COLUMN chain_hash TEXT;
@@ -0,0 +1,4 @@
+-- Remove system event log
+DROP INDEX IF EXISTS idx_system_events_occurred;
+DROP INDEX IF EXISTS idx_system_events_type_occurred;
+DROP TABLE IF EXISTS system_events;
@@ -0,0 +1,4 @@
+-- Remove topic value history table
+DROP INDEX IF EXISTS idx_topic_history_recorded;
+DROP INDEX IF EXISTS idx_topic_history_topic_recorded;
+DROP TABLE IF EXISTS topic_history;
@@ -0,0 +1,2 @@
+-- Remove library flag from strategies
+ALTER TABLE strategies DROP COLUMN library;
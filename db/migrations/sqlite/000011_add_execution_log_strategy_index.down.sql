@@ -0,0 +1,2 @@
+-- Remove execution log strategy index
+DROP INDEX IF EXISTS idx_execution_log_strategy;
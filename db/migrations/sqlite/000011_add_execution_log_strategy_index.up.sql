@@ -0,0 +1,2 @@
+-- Index execution logs by strategy for usage queries
+CREATE INDEX IF NOT EXISTS idx_execution_log_strategy ON execution_log(strategy_id, executed_at);
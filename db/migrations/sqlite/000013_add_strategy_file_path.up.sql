@@ -0,0 +1,2 @@
+-- Track strategies whose code is loaded from a file on disk
+ALTER TABLE strategies ADD COLUMN file_path TEXT;
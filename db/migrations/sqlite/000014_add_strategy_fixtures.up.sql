@@ -0,0 +1,13 @@
+-- Named regression test fixtures (inputs and expected output) per strategy
+CREATE TABLE IF NOT EXISTS strategy_fixtures (
+    strategy_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    inputs TEXT, -- JSON
+    parameters TEXT, -- JSON
+    trigger_topic TEXT,
+    expected TEXT, -- JSON
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (strategy_id, name),
+    FOREIGN KEY (strategy_id) REFERENCES strategies(id)
+);
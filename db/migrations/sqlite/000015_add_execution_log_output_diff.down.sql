@@ -0,0 +1,2 @@
+-- Remove output diffs from execution logs
+ALTER TABLE execution_log DROP COLUMN output_diff;
@@ -0,0 +1,2 @@
+-- Remove the audit hash chain from execution logs
+ALTER TABLE execution_log DROP COLUMN chain_hash;
@@ -0,0 +1,2 @@
+-- Remove the fixed time from strategy fixtures
+ALTER TABLE strategy_fixtures DROP COLUMN fixed_time;
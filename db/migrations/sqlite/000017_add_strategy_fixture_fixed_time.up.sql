@@ -0,0 +1,2 @@
+-- Add the fixed time a strategy fixture runs at, for deterministic getTime/getISO
+ALTER TABLE strategy_fixtures ADD COLUMN fixed_time TIMESTAMP;
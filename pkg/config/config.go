@@ -1,6 +1,7 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
@@ -15,6 +16,18 @@ type Config struct {
 	Web          WebConfig          `yaml:"web"`
 	Logging      LoggingConfig      `yaml:"logging"`
 	SystemTopics SystemTopicsConfig `yaml:"system_topics"`
+	Strategies   StrategiesConfig   `yaml:"strategies"`
+	Topics       TopicsConfig       `yaml:"topics"`
+	Metrics      MetricsConfig      `yaml:"metrics"`
+	Sinks        SinksConfig        `yaml:"sinks"`
+
+	// DeviceTemplates are instantiated per device with
+	// POST /api/v1/templates/{name}/instantiate?device=...
+	DeviceTemplates map[string]DeviceTemplate `yaml:"device_templates"`
+
+	// ShutdownTimeout is how long shutdown waits for in-flight work to drain
+	// before running strategy executions are interrupted
+	ShutdownTimeout string `yaml:"shutdown_timeout"`
 }
 
 type MQTTConfig struct {
@@ -23,16 +36,214 @@ type MQTTConfig struct {
 	Username string   `yaml:"username"`
 	Password string   `yaml:"password"`
 	Topics   []string `yaml:"topics"`
+
+	// QoS is the QoS of subscriptions and publishes without a TopicQoS entry
+	QoS byte `yaml:"qos"`
+
+	// TopicQoS sets the QoS of topic patterns. Subscriptions without an entry
+	// use the highest QoS of an entry covering them, and publishes the
+	// highest QoS of an entry matching their topic, or QoS.
+	TopicQoS map[string]byte `yaml:"topic_qos"`
+
+	// DeviceLists are named lists of values referenced as {name} placeholders
+	// in TopicTemplates. Each template expands into one subscription per
+	// combination of the lists it references, added to Topics.
+	DeviceLists    map[string][]string `yaml:"device_lists"`
+	TopicTemplates []string            `yaml:"topic_templates"`
+
+	// MaxConcurrentMessages limits how many inbound messages are processed at
+	// once. Messages for the same topic are always processed in order.
+	MaxConcurrentMessages int `yaml:"max_concurrent_messages"`
+
+	// OrderingPrefixes group topics by device for ordered processing. A topic
+	// under a prefix (+ matches one level) is ordered with every topic sharing
+	// the levels the prefix matched, e.g. "zigbee2mqtt/+" processes all topics
+	// of one zigbee2mqtt device in arrival order.
+	OrderingPrefixes []string `yaml:"ordering_prefixes"`
+
+	// BinaryTopics are topic patterns whose payloads are kept as raw bytes
+	// (stored base64-encoded) instead of being parsed as JSON or text
+	BinaryTopics []string `yaml:"binary_topics"`
+
+	// IngressStrategies transform the payloads of matching topics with a
+	// strategy before they are stored as external topic values. The first
+	// matching rule applies.
+	IngressStrategies []IngressStrategyConfig `yaml:"ingress_strategies"`
+
+	// PayloadTimestamps take the update time of matching external topics from
+	// a field of their JSON payloads. The first matching rule applies.
+	PayloadTimestamps []PayloadTimestampConfig `yaml:"payload_timestamps"`
+
+	// PublishTimeout is how long a publish waits for the broker to confirm
+	// delivery before it is reported as timed out
+	PublishTimeout string `yaml:"publish_timeout"`
+
+	// StartupWarmup buffers inbound messages on startup until initialization
+	// has completed and this window has passed (e.g. "2s"); empty disables it
+	StartupWarmup string `yaml:"startup_warmup"`
+
+	// MinimalSubscriptions subscribes only to the patterns referenced by
+	// internal topic inputs instead of the configured topics
+	MinimalSubscriptions bool `yaml:"minimal_subscriptions"`
+
+	// CanonicalJSON publishes values with sorted object keys and no HTML
+	// escaping, so equal values are always published as identical bytes
+	CanonicalJSON bool `yaml:"canonical_json"`
+
+	// TopicNormalization trims and/or lowercases topic names under a prefix,
+	// so inconsistent device topics map to one external topic. The first
+	// matching rule applies.
+	TopicNormalization []TopicNormalizationConfig `yaml:"topic_normalization"`
+
+	// OutboundQueue buffers publishes made while the broker is unreachable
+	// and sends them once the connection is restored
+	OutboundQueue OutboundQueueConfig `yaml:"outbound_queue"`
+
+	// WillTopic is the status topic the broker publishes WillPayload to when
+	// the connection is lost without a clean disconnect (MQTT Last Will and
+	// Testament). A clean disconnect publishes the same payload first, so the
+	// topic always ends offline. Empty disables the will.
+	WillTopic   string `yaml:"will_topic"`
+	WillPayload string `yaml:"will_payload"`
+	WillQoS     byte   `yaml:"will_qos"`
+	WillRetain  bool   `yaml:"will_retain"`
+
+	// BirthTopic is the status topic BirthPayload is published to every time
+	// the connection is established. Pointed at WillTopic with both retained,
+	// the topic's retained value flips between online and offline. Empty
+	// disables the birth message.
+	BirthTopic   string `yaml:"birth_topic"`
+	BirthPayload string `yaml:"birth_payload"`
+	BirthQoS     byte   `yaml:"birth_qos"`
+	BirthRetain  bool   `yaml:"birth_retain"`
+}
+
+// OutboundQueueConfig bounds the outbound publish queue
+type OutboundQueueConfig struct {
+	// Size is how many messages are buffered; 0 disables the queue so
+	// publishes fail while disconnected
+	Size int `yaml:"size"`
+	// OverflowPolicy is drop-oldest (default) or drop-newest
+	OverflowPolicy string `yaml:"overflow_policy"`
+	// Collapse keeps only the latest queued message of each topic
+	Collapse bool `yaml:"collapse"`
+}
+
+// IngressStrategyConfig runs Strategy on the payloads of topics matching
+// Topic (+ and # wildcards allowed)
+type IngressStrategyConfig struct {
+	Topic    string `yaml:"topic"`
+	Strategy string `yaml:"strategy"`
+}
+
+// PayloadTimestampConfig reads the timestamp at Pointer, a JSON pointer such
+// as "/meta/ts", from the payloads of topics matching Topic. Timestamps
+// further than MaxSkew (e.g. "5m") from the receive time are ignored.
+type PayloadTimestampConfig struct {
+	Topic   string `yaml:"topic"`
+	Pointer string `yaml:"pointer"`
+	MaxSkew string `yaml:"max_skew"`
+}
+
+// TopicNormalizationConfig normalizes topic names starting with Prefix
+// (empty matches all topics)
+type TopicNormalizationConfig struct {
+	Prefix    string `yaml:"prefix"`
+	Trim      bool   `yaml:"trim"`
+	Lowercase bool   `yaml:"lowercase"`
 }
 
 type DatabaseConfig struct {
-	Type       string `yaml:"type"`
-	Connection string `yaml:"connection"`
+	Type       string        `yaml:"type"`
+	Connection string        `yaml:"connection"`
+	History    HistoryConfig `yaml:"history"`
+	SQLite     SQLiteConfig  `yaml:"sqlite"`
+
+	// SystemEvents controls the persisted system event log
+	SystemEvents SystemEventsConfig `yaml:"system_events"`
+
+	// ExecutionLogs controls the periodic removal of old execution logs
+	ExecutionLogs ExecutionLogsConfig `yaml:"execution_logs"`
+
+	// EncryptionKey enables encryption of topic and strategy parameters at
+	// rest. Falls back to the AUTOMATION_ENCRYPTION_KEY environment variable.
+	// Kept out of JSON so it is not published with the startup event.
+	EncryptionKey string `yaml:"encryption_key" json:"-"`
+
+	// WriteBatchInterval coalesces topic state writes and flushes the latest
+	// value per topic at this interval (e.g. "500ms"). Empty writes immediately.
+	WriteBatchInterval string `yaml:"write_batch_interval"`
+
+	// OutputDiff stores, with each execution log, which output values changed
+	// since the topic's previous successful execution
+	OutputDiff bool `yaml:"output_diff"`
+
+	// AuditHashChain records a tamper-evident hash with each execution log,
+	// chaining its inputs and outputs to the topic's previous execution
+	AuditHashChain bool `yaml:"audit_hash_chain"`
+
+	// ReadReplica is an optional Postgres connection string used for
+	// dashboard reads; writes, startup loads and failed replica reads use
+	// the primary. Kept out of JSON as it may hold credentials.
+	ReadReplica string `yaml:"read_replica" json:"-"`
+
+	// ForceSchemaVersion starts the application even when the database
+	// schema is newer than this build expects. It is set by the -force flag.
+	ForceSchemaVersion bool `yaml:"-"`
+}
+
+// EncryptionKeyEnv is the environment variable used when database.encryption_key is not set
+const EncryptionKeyEnv = "AUTOMATION_ENCRYPTION_KEY"
+
+// SQLiteConfig tunes the SQLite connection
+type SQLiteConfig struct {
+	// BusyTimeout is how long (in milliseconds) a write waits on a locked database
+	BusyTimeout int `yaml:"busy_timeout"`
+	// Pragmas are additional go-sqlite3 connection pragmas, e.g. synchronous: NORMAL
+	Pragmas map[string]string `yaml:"pragmas"`
+}
+
+// HistoryConfig controls persistent storage of topic value history
+type HistoryConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	Retention string `yaml:"retention"`
+}
+
+// SystemEventsConfig controls how long system events (startup, error,
+// mqtt_connected, ...) are kept
+type SystemEventsConfig struct {
+	Retention string `yaml:"retention"`
+}
+
+// ExecutionLogsConfig controls how long execution logs are kept. Logs are
+// kept forever when RetentionDays is 0.
+type ExecutionLogsConfig struct {
+	RetentionDays   int    `yaml:"retention_days"`
+	CleanupInterval string `yaml:"cleanup_interval"`
 }
 
 type WebConfig struct {
 	Port int    `yaml:"port"`
 	Bind string `yaml:"bind"`
+
+	// DefaultEmitToMQTT is used for topics created via the API without an explicit emit_to_mqtt
+	DefaultEmitToMQTT bool `yaml:"default_emit_to_mqtt"`
+
+	// AdminToken lets API clients sending "Authorization: Bearer <token>"
+	// reveal encrypted parameters. Revealing is disabled when empty.
+	AdminToken string `yaml:"admin_token"`
+
+	// StreamCoalesceInterval batches the WebSocket updates of each connection
+	// sent within this interval (e.g. "250ms") into one message holding the
+	// latest value per topic; empty sends every update as it happens
+	StreamCoalesceInterval string `yaml:"stream_coalesce_interval"`
+}
+
+type MetricsConfig struct {
+	// TopicAllowlist lists the topics (MQTT patterns allowed) that get their
+	// own series in topic-labeled metrics. Other topics are aggregated under
+	// the "other" label. Empty keeps a series per topic.
+	TopicAllowlist []string `yaml:"topic_allowlist"`
 }
 
 type LoggingConfig struct {
@@ -42,6 +253,118 @@ type LoggingConfig struct {
 
 type SystemTopicsConfig struct {
 	TickerIntervals []string `yaml:"ticker_intervals"`
+
+	// StatsInterval is how often the system/stats/ topics report aggregates
+	// (topic count, messages per minute, average execution time). "off"
+	// disables them.
+	StatsInterval string `yaml:"stats_interval"`
+}
+
+type StrategiesConfig struct {
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker"`
+
+	// DeadLetterTopic is the MQTT topic failed strategy executions are
+	// published to, with their trigger, inputs and error. Empty disables it.
+	DeadLetterTopic string `yaml:"dead_letter_topic"`
+
+	// Directory holds file-backed strategies (one .js file per strategy) that
+	// are reloaded when they change. Empty disables file-backed strategies.
+	Directory string `yaml:"directory"`
+
+	// ExecutionPoolSize runs strategy executions on this many dedicated
+	// workers, each locked to an OS thread, so CPU-heavy strategies cannot
+	// starve MQTT and web handling. 0 runs executions on the caller.
+	ExecutionPoolSize int `yaml:"execution_pool_size"`
+
+	// MaxInFlight limits how many strategy executions run at once. While the
+	// limit is reached inbound MQTT messages are handled according to
+	// BackpressurePolicy: defer (wait) or drop. 0 disables the limit.
+	MaxInFlight        int    `yaml:"max_in_flight"`
+	BackpressurePolicy string `yaml:"backpressure_policy"`
+
+	// PostProcessors transform every value a strategy emits, in order, e.g.
+	// "round:2", "clamp:0:100" or "timestamp". Topics may select their own.
+	PostProcessors []string `yaml:"post_processors"`
+
+	// AsyncTimeout is how long the promise returned by an async process()
+	// may take to settle
+	AsyncTimeout string `yaml:"async_timeout"`
+
+	// ProgramCacheSize is how many compiled JavaScript strategies are kept,
+	// keyed by a hash of their code, so unchanged code is not compiled or
+	// validated again. A nil size uses the default; 0 disables the cache.
+	ProgramCacheSize *int `yaml:"program_cache_size"`
+
+	// Isolation limits each execution of "subprocess" strategies, which run
+	// in a separate worker process
+	Isolation IsolationConfig `yaml:"isolation"`
+
+	// InputLimits bounds the inputs passed to a single execution
+	InputLimits InputLimitsConfig `yaml:"input_limits"`
+}
+
+// InputLimitsConfig bounds the number and JSON-encoded size of the inputs of
+// an execution. Over a limit, executions are run with inputs dropped
+// (truncate) or not run (reject). 0 disables a limit.
+type InputLimitsConfig struct {
+	MaxInputs int    `yaml:"max_inputs"`
+	MaxBytes  int    `yaml:"max_bytes"`
+	Policy    string `yaml:"policy"`
+}
+
+// CircuitBreakerConfig controls skipping of strategies that keep failing for
+// a topic. A Threshold of 0 (the default) disables the breaker.
+type CircuitBreakerConfig struct {
+	Threshold int    `yaml:"threshold"`
+	Cooldown  string `yaml:"cooldown"`
+}
+
+// SinksConfig lists the external sinks every topic update is forwarded to
+type SinksConfig struct {
+	Webhooks []WebhookSinkConfig `yaml:"webhooks"`
+}
+
+// WebhookSinkConfig POSTs topic updates as JSON to a URL
+type WebhookSinkConfig struct {
+	Name       string            `yaml:"name"` // defaults to the URL
+	URL        string            `yaml:"url"`
+	Headers    map[string]string `yaml:"headers"`
+	Retries    int               `yaml:"retries"` // 0 uses the default of 3; -1 disables retries
+	Backoff    string            `yaml:"backoff"` // wait before the first retry, doubling after each
+	Timeout    string            `yaml:"timeout"`
+	BufferSize int               `yaml:"buffer_size"` // events queued before updates are dropped
+}
+
+// IsolationConfig holds the hard limits of isolated strategy executions
+type IsolationConfig struct {
+	MemoryLimitMB int    `yaml:"memory_limit_mb"`
+	CPULimit      string `yaml:"cpu_limit"` // CPU time, rounded up to whole seconds
+	Timeout       string `yaml:"timeout"`   // wall-clock time
+}
+
+// TopicsConfig holds defaults for internal topics
+type TopicsConfig struct {
+	// MissingInputPolicy is what topics do when an input topic does not exist
+	// yet: nil (pass nil), skip-execution or error. Topics can override it.
+	MissingInputPolicy string `yaml:"missing_input_policy"`
+
+	// MaxNameLength is the longest accepted topic name in bytes. A nil value
+	// uses the default; 0 disables the limit.
+	MaxNameLength *int `yaml:"max_name_length"`
+
+	// DedupeAcrossRestart ignores the first MQTT message for an external topic
+	// after startup when it repeats the value restored from the database, so
+	// retained replays don't trigger dependent topics
+	DedupeAcrossRestart bool `yaml:"dedupe_across_restart"`
+
+	// ChainDeadline limits how long a propagation chain may run, from the
+	// update that starts it through every topic it triggers (e.g. "2s").
+	// Empty has no limit.
+	ChainDeadline string `yaml:"chain_deadline"`
+
+	// WritableTargets are MQTT topic patterns of existing external and
+	// internal topics that strategies may emit to, replacing their values
+	WritableTargets []string `yaml:"writable_targets"`
 }
 
 func Load(configPath string) (*Config, error) {
@@ -67,6 +390,11 @@ func Load(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
+	// Expand templated subscriptions
+	if err := config.expandTopicTemplates(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
 	// Apply defaults
 	config.setDefaults()
 
@@ -86,6 +414,26 @@ func (c *Config) setDefaults() {
 	if c.MQTT.Broker == "" {
 		c.MQTT.Broker = "mqtt://localhost:1883"
 	}
+	if c.MQTT.MaxConcurrentMessages == 0 {
+		c.MQTT.MaxConcurrentMessages = 4
+	}
+	if c.MQTT.PublishTimeout == "" {
+		c.MQTT.PublishTimeout = "10s"
+	}
+	if c.MQTT.OutboundQueue.OverflowPolicy == "" {
+		c.MQTT.OutboundQueue.OverflowPolicy = "drop-oldest"
+	}
+	if c.MQTT.WillTopic != "" && c.MQTT.WillPayload == "" {
+		c.MQTT.WillPayload = `{"online":false}`
+	}
+	if c.MQTT.BirthTopic != "" && c.MQTT.BirthPayload == "" {
+		payload, _ := json.Marshal(map[string]interface{}{"online": true, "client_id": c.MQTT.ClientID})
+		c.MQTT.BirthPayload = string(payload)
+	}
+
+	if c.ShutdownTimeout == "" {
+		c.ShutdownTimeout = "30s"
+	}
 
 	// Database defaults
 	if c.Database.Type == "" {
@@ -100,6 +448,22 @@ func (c *Config) setDefaults() {
 		}
 	}
 
+	if c.Database.History.Retention == "" {
+		c.Database.History.Retention = "168h"
+	}
+	if c.Database.SystemEvents.Retention == "" {
+		c.Database.SystemEvents.Retention = "720h"
+	}
+	if c.Database.ExecutionLogs.CleanupInterval == "" {
+		c.Database.ExecutionLogs.CleanupInterval = "1h"
+	}
+	if c.Database.EncryptionKey == "" {
+		c.Database.EncryptionKey = os.Getenv(EncryptionKeyEnv)
+	}
+	if c.Database.SQLite.BusyTimeout == 0 {
+		c.Database.SQLite.BusyTimeout = 5000
+	}
+
 	// Web defaults
 	if c.Web.Port == 0 {
 		c.Web.Port = 8080
@@ -120,6 +484,45 @@ func (c *Config) setDefaults() {
 	if len(c.SystemTopics.TickerIntervals) == 0 {
 		c.SystemTopics.TickerIntervals = []string{"1s", "5s", "30s", "1m", "5m"}
 	}
+	if c.SystemTopics.StatsInterval == "" {
+		c.SystemTopics.StatsInterval = "30s"
+	}
+
+	// Strategy defaults
+	if c.Strategies.CircuitBreaker.Cooldown == "" {
+		c.Strategies.CircuitBreaker.Cooldown = "1m"
+	}
+	if c.Strategies.BackpressurePolicy == "" {
+		c.Strategies.BackpressurePolicy = "defer"
+	}
+	if c.Strategies.InputLimits.Policy == "" {
+		c.Strategies.InputLimits.Policy = "truncate"
+	}
+	if c.Strategies.AsyncTimeout == "" {
+		c.Strategies.AsyncTimeout = "5s"
+	}
+	if c.Strategies.ProgramCacheSize == nil {
+		size := 1000
+		c.Strategies.ProgramCacheSize = &size
+	}
+	if c.Strategies.Isolation.MemoryLimitMB == 0 {
+		c.Strategies.Isolation.MemoryLimitMB = 128
+	}
+	if c.Strategies.Isolation.CPULimit == "" {
+		c.Strategies.Isolation.CPULimit = "10s"
+	}
+	if c.Strategies.Isolation.Timeout == "" {
+		c.Strategies.Isolation.Timeout = "30s"
+	}
+
+	// Topic defaults
+	if c.Topics.MissingInputPolicy == "" {
+		c.Topics.MissingInputPolicy = "nil"
+	}
+	if c.Topics.MaxNameLength == nil {
+		maxNameLength := 256
+		c.Topics.MaxNameLength = &maxNameLength
+	}
 }
 
 func (c *Config) validate() error {
@@ -128,16 +531,144 @@ func (c *Config) validate() error {
 		return fmt.Errorf("MQTT broker URL is required")
 	}
 
+	if c.MQTT.MaxConcurrentMessages < 1 {
+		return fmt.Errorf("invalid MQTT max_concurrent_messages: %d", c.MQTT.MaxConcurrentMessages)
+	}
+	for _, prefix := range c.MQTT.OrderingPrefixes {
+		if prefix == "" || strings.Contains(prefix, "#") {
+			return fmt.Errorf("invalid MQTT ordering prefix %q: must be non-empty without #", prefix)
+		}
+	}
+
+	if timeout, err := time.ParseDuration(c.MQTT.PublishTimeout); err != nil || timeout <= 0 {
+		return fmt.Errorf("invalid MQTT publish_timeout: %s", c.MQTT.PublishTimeout)
+	}
+
+	if c.MQTT.StartupWarmup != "" {
+		if warmup, err := time.ParseDuration(c.MQTT.StartupWarmup); err != nil || warmup < 0 {
+			return fmt.Errorf("invalid MQTT startup_warmup: %s", c.MQTT.StartupWarmup)
+		}
+	}
+
+	for _, rule := range c.MQTT.TopicNormalization {
+		if !rule.Trim && !rule.Lowercase {
+			return fmt.Errorf("invalid MQTT topic_normalization for prefix %q: enable trim or lowercase", rule.Prefix)
+		}
+	}
+
+	if c.MQTT.OutboundQueue.Size < 0 {
+		return fmt.Errorf("invalid MQTT outbound_queue size: %d", c.MQTT.OutboundQueue.Size)
+	}
+	if policy := c.MQTT.OutboundQueue.OverflowPolicy; policy != "drop-oldest" && policy != "drop-newest" {
+		return fmt.Errorf("invalid MQTT outbound_queue overflow_policy: %s (must be drop-oldest or drop-newest)", policy)
+	}
+
+	for _, rule := range c.MQTT.IngressStrategies {
+		if rule.Topic == "" || rule.Strategy == "" {
+			return fmt.Errorf("invalid MQTT ingress_strategies entry: topic and strategy are required")
+		}
+	}
+
+	for _, rule := range c.MQTT.PayloadTimestamps {
+		if rule.Topic == "" || rule.Pointer == "" {
+			return fmt.Errorf("invalid MQTT payload_timestamps entry: topic and pointer are required")
+		}
+		if rule.Pointer[0] != '/' {
+			return fmt.Errorf("invalid MQTT payload_timestamps pointer: %s (must start with /)", rule.Pointer)
+		}
+		if rule.MaxSkew != "" {
+			if skew, err := time.ParseDuration(rule.MaxSkew); err != nil || skew <= 0 {
+				return fmt.Errorf("invalid MQTT payload_timestamps max_skew: %s", rule.MaxSkew)
+			}
+		}
+	}
+
+	for _, topic := range c.MQTT.Topics {
+		if !strings.HasPrefix(topic, "$share/") {
+			continue
+		}
+		group, filter, _ := strings.Cut(strings.TrimPrefix(topic, "$share/"), "/")
+		if group == "" || strings.ContainsAny(group, "+#") || filter == "" {
+			return fmt.Errorf("invalid MQTT shared subscription %q (must be $share/{group}/{topic})", topic)
+		}
+	}
+
+	if c.MQTT.BirthQoS > 2 {
+		return fmt.Errorf("invalid MQTT birth_qos %d (must be 0, 1 or 2)", c.MQTT.BirthQoS)
+	}
+	if strings.ContainsAny(c.MQTT.BirthTopic, "+#") {
+		return fmt.Errorf("invalid MQTT birth_topic %q (wildcards are not allowed)", c.MQTT.BirthTopic)
+	}
+	if c.MQTT.WillQoS > 2 {
+		return fmt.Errorf("invalid MQTT will_qos %d (must be 0, 1 or 2)", c.MQTT.WillQoS)
+	}
+	if strings.ContainsAny(c.MQTT.WillTopic, "+#") {
+		return fmt.Errorf("invalid MQTT will_topic %q (wildcards are not allowed)", c.MQTT.WillTopic)
+	}
+	if c.MQTT.QoS > 2 {
+		return fmt.Errorf("invalid MQTT qos %d (must be 0, 1 or 2)", c.MQTT.QoS)
+	}
+	for pattern, qos := range c.MQTT.TopicQoS {
+		if pattern == "" || qos > 2 {
+			return fmt.Errorf("invalid MQTT topic_qos %d for pattern %q (must be 0, 1 or 2)", qos, pattern)
+		}
+	}
+
+	if timeout, err := time.ParseDuration(c.ShutdownTimeout); err != nil || timeout <= 0 {
+		return fmt.Errorf("invalid shutdown_timeout: %s", c.ShutdownTimeout)
+	}
+
 	// Validate database type
 	if c.Database.Type != "sqlite" && c.Database.Type != "postgres" {
 		return fmt.Errorf("unsupported database type: %s", c.Database.Type)
 	}
 
+	if c.Database.ReadReplica != "" && c.Database.Type != "postgres" {
+		return fmt.Errorf("database read_replica requires the postgres database type")
+	}
+
+	// Validate history retention
+	if retention, err := time.ParseDuration(c.Database.History.Retention); err != nil || retention <= 0 {
+		return fmt.Errorf("invalid history retention: %s", c.Database.History.Retention)
+	}
+	if retention, err := time.ParseDuration(c.Database.SystemEvents.Retention); err != nil || retention <= 0 {
+		return fmt.Errorf("invalid system event retention: %s", c.Database.SystemEvents.Retention)
+	}
+	if c.Database.ExecutionLogs.RetentionDays < 0 {
+		return fmt.Errorf("invalid execution log retention: %d days", c.Database.ExecutionLogs.RetentionDays)
+	}
+	if interval, err := time.ParseDuration(c.Database.ExecutionLogs.CleanupInterval); err != nil || interval <= 0 {
+		return fmt.Errorf("invalid execution log cleanup interval: %s", c.Database.ExecutionLogs.CleanupInterval)
+	}
+
+	// Validate write batching
+	if interval := c.Database.WriteBatchInterval; interval != "" {
+		if d, err := time.ParseDuration(interval); err != nil || d < 0 {
+			return fmt.Errorf("invalid database write_batch_interval: %s", interval)
+		}
+	}
+
+	// Validate SQLite settings
+	if c.Database.SQLite.BusyTimeout < 0 {
+		return fmt.Errorf("invalid sqlite busy_timeout: %d", c.Database.SQLite.BusyTimeout)
+	}
+	for name := range c.Database.SQLite.Pragmas {
+		if !isPragmaName(name) {
+			return fmt.Errorf("invalid sqlite pragma: %s", name)
+		}
+	}
+
 	// Validate web port
 	if c.Web.Port < 1 || c.Web.Port > 65535 {
 		return fmt.Errorf("invalid web port: %d", c.Web.Port)
 	}
 
+	if c.Web.StreamCoalesceInterval != "" {
+		if interval, err := time.ParseDuration(c.Web.StreamCoalesceInterval); err != nil || interval < 0 {
+			return fmt.Errorf("invalid web stream_coalesce_interval: %s", c.Web.StreamCoalesceInterval)
+		}
+	}
+
 	// Validate logging level
 	validLevels := map[string]bool{
 		"debug": true,
@@ -155,14 +686,119 @@ func (c *Config) validate() error {
 			return fmt.Errorf("invalid ticker interval: %s", interval)
 		}
 	}
+	if interval := c.SystemTopics.StatsInterval; interval != "off" {
+		if d, err := time.ParseDuration(interval); err != nil || d <= 0 {
+			return fmt.Errorf("invalid system_topics.stats_interval: %s", interval)
+		}
+	}
+
+	// Validate circuit breaker
+	if threshold := c.Strategies.CircuitBreaker.Threshold; threshold < 0 {
+		return fmt.Errorf("invalid circuit breaker threshold: %d", threshold)
+	}
+	if cooldown, err := time.ParseDuration(c.Strategies.CircuitBreaker.Cooldown); err != nil || cooldown <= 0 {
+		return fmt.Errorf("invalid circuit breaker cooldown: %s", c.Strategies.CircuitBreaker.Cooldown)
+	}
+	if c.Strategies.ExecutionPoolSize < 0 {
+		return fmt.Errorf("invalid strategy execution pool size: %d", c.Strategies.ExecutionPoolSize)
+	}
+	if c.Strategies.MaxInFlight < 0 {
+		return fmt.Errorf("invalid strategies max_in_flight: %d", c.Strategies.MaxInFlight)
+	}
+	switch c.Strategies.BackpressurePolicy {
+	case "defer", "drop":
+	default:
+		return fmt.Errorf("invalid strategies backpressure_policy: %s", c.Strategies.BackpressurePolicy)
+	}
+	if c.Strategies.InputLimits.MaxInputs < 0 || c.Strategies.InputLimits.MaxBytes < 0 {
+		return fmt.Errorf("invalid strategies input_limits: max_inputs and max_bytes must not be negative")
+	}
+	switch c.Strategies.InputLimits.Policy {
+	case "truncate", "reject":
+	default:
+		return fmt.Errorf("invalid strategies input_limits policy: %s (must be truncate or reject)", c.Strategies.InputLimits.Policy)
+	}
+	if timeout, err := time.ParseDuration(c.Strategies.AsyncTimeout); err != nil || timeout <= 0 {
+		return fmt.Errorf("invalid strategies async_timeout: %s", c.Strategies.AsyncTimeout)
+	}
+	if size := c.Strategies.ProgramCacheSize; size != nil && *size < 0 {
+		return fmt.Errorf("invalid strategies program_cache_size: %d", *size)
+	}
+	if c.Strategies.Isolation.MemoryLimitMB < 0 {
+		return fmt.Errorf("invalid strategies isolation memory_limit_mb: %d", c.Strategies.Isolation.MemoryLimitMB)
+	}
+	if limit, err := time.ParseDuration(c.Strategies.Isolation.CPULimit); err != nil || limit <= 0 {
+		return fmt.Errorf("invalid strategies isolation cpu_limit: %s", c.Strategies.Isolation.CPULimit)
+	}
+	if timeout, err := time.ParseDuration(c.Strategies.Isolation.Timeout); err != nil || timeout <= 0 {
+		return fmt.Errorf("invalid strategies isolation timeout: %s", c.Strategies.Isolation.Timeout)
+	}
+
+	// Validate topic defaults
+	switch c.Topics.MissingInputPolicy {
+	case "nil", "skip-execution", "error":
+	default:
+		return fmt.Errorf("invalid topics missing_input_policy: %s", c.Topics.MissingInputPolicy)
+	}
+	if maxNameLength := c.Topics.MaxNameLength; maxNameLength != nil && *maxNameLength < 0 {
+		return fmt.Errorf("invalid topics max_name_length: %d", *maxNameLength)
+	}
+	if c.Topics.ChainDeadline != "" {
+		if deadline, err := time.ParseDuration(c.Topics.ChainDeadline); err != nil || deadline < 0 {
+			return fmt.Errorf("invalid topics chain_deadline: %s", c.Topics.ChainDeadline)
+		}
+	}
+	for _, pattern := range c.Topics.WritableTargets {
+		if pattern == "" {
+			return fmt.Errorf("invalid topics writable_targets: empty pattern")
+		}
+	}
+
+	// Validate metrics topic allowlist
+	for _, pattern := range c.Metrics.TopicAllowlist {
+		if pattern == "" {
+			return fmt.Errorf("invalid metrics topic_allowlist: empty pattern")
+		}
+	}
+
+	// Validate event sinks
+	for _, webhook := range c.Sinks.Webhooks {
+		if webhook.URL == "" {
+			return fmt.Errorf("webhook sink URL is required")
+		}
+		for _, duration := range []string{webhook.Backoff, webhook.Timeout} {
+			if duration == "" {
+				continue
+			}
+			if d, err := time.ParseDuration(duration); err != nil || d <= 0 {
+				return fmt.Errorf("invalid duration %q for webhook sink %s", duration, webhook.URL)
+			}
+		}
+		if webhook.BufferSize < 0 {
+			return fmt.Errorf("invalid buffer_size %d for webhook sink %s", webhook.BufferSize, webhook.URL)
+		}
+	}
 
-	return nil
+	return c.validateDeviceTemplates()
 }
 
 func (c *Config) GetAddress() string {
 	return fmt.Sprintf("%s:%d", c.Web.Bind, c.Web.Port)
 }
 
+// isPragmaName reports whether name is a plausible SQLite pragma name
+func isPragmaName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
 // isTestMode detects if we're running in test mode
 func isTestMode() bool {
 	// Check if the executable name contains ".test" (indicates test binary)
@@ -0,0 +1,38 @@
+package config
+
+import "fmt"
+
+// DevicePlaceholder is replaced by the device name when a device template is
+// instantiated
+const DevicePlaceholder = "{device}"
+
+// DeviceTemplate is a bundle of strategies and topics created together for a
+// device of a known type. Entries have the same fields as the strategy and
+// topic create requests of the API, and {device} anywhere in their strings is
+// replaced by the device name.
+type DeviceTemplate struct {
+	Description string                   `yaml:"description"`
+	Strategies  []map[string]interface{} `yaml:"strategies"`
+	Topics      []map[string]interface{} `yaml:"topics"`
+}
+
+// validateDeviceTemplates checks that every template creates something and
+// that its entries are identified
+func (c *Config) validateDeviceTemplates() error {
+	for name, template := range c.DeviceTemplates {
+		if len(template.Strategies) == 0 && len(template.Topics) == 0 {
+			return fmt.Errorf("device template %s has no strategies or topics", name)
+		}
+		for i, strategy := range template.Strategies {
+			if id, _ := strategy["id"].(string); id == "" {
+				return fmt.Errorf("device template %s strategy %d has no id", name, i+1)
+			}
+		}
+		for i, topic := range template.Topics {
+			if topicName, _ := topic["name"].(string); topicName == "" {
+				return fmt.Errorf("device template %s topic %d has no name", name, i+1)
+			}
+		}
+	}
+	return nil
+}
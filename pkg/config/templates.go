@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExpandTopicTemplate expands a topic template into one topic per combination
+// of the device lists it references. Placeholders are list names in braces,
+// e.g. "home/{room}/{device}/state"; the first placeholder varies slowest.
+func ExpandTopicTemplate(template string, lists map[string][]string) ([]string, error) {
+	// Split into literal text and placeholder names, alternating
+	var literals, names []string
+	rest := template
+	for {
+		start := strings.Index(rest, "{")
+		if start < 0 {
+			if strings.Contains(rest, "}") {
+				return nil, fmt.Errorf("topic template %q has an unmatched }", template)
+			}
+			literals = append(literals, rest)
+			break
+		}
+		end := strings.Index(rest[start:], "}")
+		if end < 0 {
+			return nil, fmt.Errorf("topic template %q has an unclosed {", template)
+		}
+		literal, name := rest[:start], rest[start+1:start+end]
+		if strings.Contains(literal, "}") {
+			return nil, fmt.Errorf("topic template %q has an unmatched }", template)
+		}
+		if name == "" {
+			return nil, fmt.Errorf("topic template %q has an empty placeholder", template)
+		}
+		values, ok := lists[name]
+		if !ok {
+			return nil, fmt.Errorf("topic template %q references unknown device list %q", template, name)
+		}
+		if len(values) == 0 {
+			return nil, fmt.Errorf("device list %q is empty", name)
+		}
+		literals = append(literals, literal)
+		names = append(names, name)
+		rest = rest[start+end+1:]
+	}
+
+	topics := []string{literals[0]}
+	for i, name := range names {
+		expanded := make([]string, 0, len(topics)*len(lists[name]))
+		for _, prefix := range topics {
+			for _, value := range lists[name] {
+				expanded = append(expanded, prefix+value+literals[i+1])
+			}
+		}
+		topics = expanded
+	}
+	return topics, nil
+}
+
+// expandTopicTemplates adds the topics expanded from mqtt.topic_templates to
+// mqtt.topics, skipping duplicates
+func (c *Config) expandTopicTemplates() error {
+	for name, values := range c.MQTT.DeviceLists {
+		for _, value := range values {
+			if value == "" || strings.ContainsAny(value, "+#{}") {
+				return fmt.Errorf("invalid value %q in device list %q", value, name)
+			}
+		}
+	}
+
+	seen := make(map[string]bool, len(c.MQTT.Topics))
+	for _, topic := range c.MQTT.Topics {
+		seen[topic] = true
+	}
+	for _, template := range c.MQTT.TopicTemplates {
+		topics, err := ExpandTopicTemplate(template, c.MQTT.DeviceLists)
+		if err != nil {
+			return err
+		}
+		for _, topic := range topics {
+			if !seen[topic] {
+				seen[topic] = true
+				c.MQTT.Topics = append(c.MQTT.Topics, topic)
+			}
+		}
+	}
+	return nil
+}
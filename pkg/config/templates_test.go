@@ -0,0 +1,92 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExpandTopicTemplate(t *testing.T) {
+	lists := map[string][]string{
+		"device": {"a", "b", "c"},
+		"room":   {"kitchen", "lounge"},
+		"empty":  {},
+	}
+
+	tests := []struct {
+		template string
+		want     []string
+		wantErr  bool
+	}{
+		{template: "home/{device}/state", want: []string{"home/a/state", "home/b/state", "home/c/state"}},
+		{template: "home/{room}/{device}", want: []string{
+			"home/kitchen/a", "home/kitchen/b", "home/kitchen/c",
+			"home/lounge/a", "home/lounge/b", "home/lounge/c",
+		}},
+		{template: "zigbee/{device}_{device}", want: []string{
+			"zigbee/a_a", "zigbee/a_b", "zigbee/a_c",
+			"zigbee/b_a", "zigbee/b_b", "zigbee/b_c",
+			"zigbee/c_a", "zigbee/c_b", "zigbee/c_c",
+		}},
+		{template: "sensors/+", want: []string{"sensors/+"}},
+		{template: "home/{unknown}/state", wantErr: true},
+		{template: "home/{empty}/state", wantErr: true},
+		{template: "home/{device/state", wantErr: true},
+		{template: "home/device}/state", wantErr: true},
+		{template: "home/{}/state", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			got, err := ExpandTopicTemplate(tt.template, lists)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ExpandTopicTemplate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExpandTopicTemplate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadExpandsTopicTemplates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := `
+mqtt:
+  broker: "tcp://localhost:1883"
+  topics:
+    - "home/a/state"
+    - "sensors/+"
+  device_lists:
+    device: [a, b, c]
+    room: [kitchen, lounge]
+  topic_templates:
+    - "home/{device}/state"
+    - "{room}/{device}/battery"
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	want := []string{
+		"home/a/state", "sensors/+", "home/b/state", "home/c/state",
+		"kitchen/a/battery", "kitchen/b/battery", "kitchen/c/battery",
+		"lounge/a/battery", "lounge/b/battery", "lounge/c/battery",
+	}
+	if !reflect.DeepEqual(cfg.MQTT.Topics, want) {
+		t.Errorf("topics = %v, want %v", cfg.MQTT.Topics, want)
+	}
+
+	if err := os.WriteFile(path, []byte(data+"    - \"home/{floor}/state\"\n"), 0o644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if _, err := Load(path); err == nil {
+		t.Error("Load accepted a template referencing an unknown device list")
+	}
+}
@@ -32,6 +32,14 @@ var (
 		[]string{"strategy", "error_type"},
 	)
 
+	TopicValidationFailures = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "automation_topic_validation_failures_total",
+			Help: "Total number of topic values that failed validation rules",
+		},
+		[]string{"topic", "action"}, // action: drop, clamp, keep-last
+	)
+
 	// Database metrics
 	DatabaseQueries = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -92,6 +100,22 @@ var (
 		[]string{"topic"},
 	)
 
+	MQTTPublishResults = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "automation_mqtt_publish_results_total",
+			Help: "Total number of MQTT publishes by delivery outcome (delivered, failed, timeout, queued, dropped)",
+		},
+		[]string{"topic", "outcome"},
+	)
+
+	MQTTBackpressure = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "automation_mqtt_backpressure_total",
+			Help: "Total number of MQTT messages deferred or dropped because too many strategy executions were in flight",
+		},
+		[]string{"action"}, // action: defer, drop
+	)
+
 	MQTTConnectionState = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
 			Name: "automation_mqtt_connection_state",
@@ -126,6 +150,14 @@ var (
 		[]string{"strategy_id", "error_type"},
 	)
 
+	EventSinkEvents = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "automation_event_sink_events_total",
+			Help: "Total number of topic events handed to event sinks by outcome",
+		},
+		[]string{"sink", "outcome"}, // outcome: delivered, failed, dropped
+	)
+
 	// System metrics
 	ActiveTopics = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -175,6 +207,11 @@ func RecordTopicProcessingError(strategy, errorType string) {
 	TopicProcessingErrors.WithLabelValues(strategy, errorType).Inc()
 }
 
+// RecordTopicValidationFailure records a value that failed a topic's validation rules
+func RecordTopicValidationFailure(topic, action string) {
+	TopicValidationFailures.WithLabelValues(topicLabel(topic), action).Inc()
+}
+
 // RecordDatabaseQuery records a database query
 func RecordDatabaseQuery(operation, mode string, duration float64) {
 	DatabaseQueries.WithLabelValues(operation, mode).Inc()
@@ -188,18 +225,29 @@ func RecordDatabaseError(operation string) {
 
 // RecordMQTTPublish records an MQTT publish event
 func RecordMQTTPublish(topic string, duration float64) {
-	MQTTMessagesPublished.WithLabelValues(topic).Inc()
-	MQTTPublishDuration.WithLabelValues(topic).Observe(duration)
+	MQTTMessagesPublished.WithLabelValues(topicLabel(topic)).Inc()
+	MQTTPublishDuration.WithLabelValues(topicLabel(topic)).Observe(duration)
 }
 
 // RecordMQTTReceive records an MQTT receive event
 func RecordMQTTReceive(topic string) {
-	MQTTMessagesReceived.WithLabelValues(topic).Inc()
+	MQTTMessagesReceived.WithLabelValues(topicLabel(topic)).Inc()
 }
 
 // RecordMQTTPublishError records an MQTT publish error
 func RecordMQTTPublishError(topic string) {
-	MQTTPublishErrors.WithLabelValues(topic).Inc()
+	MQTTPublishErrors.WithLabelValues(topicLabel(topic)).Inc()
+}
+
+// RecordMQTTPublishResult records the delivery outcome of an MQTT publish
+func RecordMQTTPublishResult(topic, outcome string) {
+	MQTTPublishResults.WithLabelValues(topicLabel(topic), outcome).Inc()
+}
+
+// RecordMQTTBackpressure records an MQTT message deferred or dropped by
+// backpressure
+func RecordMQTTBackpressure(action string) {
+	MQTTBackpressure.WithLabelValues(action).Inc()
 }
 
 // SetMQTTConnectionState sets the MQTT connection state
@@ -222,6 +270,11 @@ func RecordStrategyError(strategyID, errorType string) {
 	StrategyExecutionErrors.WithLabelValues(strategyID, errorType).Inc()
 }
 
+// RecordEventSink records the outcome of handing a topic event to a sink
+func RecordEventSink(sink, outcome string) {
+	EventSinkEvents.WithLabelValues(sink, outcome).Inc()
+}
+
 // SetActiveTopics sets the current number of active topics
 func SetActiveTopics(topicType string, count int) {
 	ActiveTopics.WithLabelValues(topicType).Set(float64(count))
@@ -234,6 +287,6 @@ func SetActiveStrategies(count int) {
 
 // RecordTopicChain records metrics for a topic chain
 func RecordTopicChain(rootTopic string, depth int, latency float64) {
-	TopicChainDepth.WithLabelValues(rootTopic).Observe(float64(depth))
-	TopicChainLatency.WithLabelValues(rootTopic, string(rune(depth+'0'))).Observe(latency)
+	TopicChainDepth.WithLabelValues(topicLabel(rootTopic)).Observe(float64(depth))
+	TopicChainLatency.WithLabelValues(topicLabel(rootTopic), string(rune(depth+'0'))).Observe(latency)
 }
@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"strings"
+	"sync"
+)
+
+// OtherTopicLabel is the topic label of topics outside the allowlist
+const OtherTopicLabel = "other"
+
+var (
+	topicAllowlist      []string
+	topicAllowlistMutex sync.RWMutex
+)
+
+// SetTopicAllowlist limits the topics that get their own series in
+// topic-labeled metrics. Patterns may use the MQTT + and # wildcards; topics
+// matching none of them are aggregated under OtherTopicLabel. An empty
+// allowlist keeps a series per topic.
+func SetTopicAllowlist(patterns []string) {
+	topicAllowlistMutex.Lock()
+	defer topicAllowlistMutex.Unlock()
+
+	topicAllowlist = append([]string(nil), patterns...)
+}
+
+// topicLabel returns the label value to record topic under
+func topicLabel(topic string) string {
+	topicAllowlistMutex.RLock()
+	defer topicAllowlistMutex.RUnlock()
+
+	if len(topicAllowlist) == 0 {
+		return topic
+	}
+	for _, pattern := range topicAllowlist {
+		if topicMatches(pattern, topic) {
+			return topic
+		}
+	}
+	return OtherTopicLabel
+}
+
+// topicMatches reports whether topic matches an MQTT subscription pattern
+func topicMatches(pattern, topic string) bool {
+	patternParts := strings.Split(pattern, "/")
+	topicParts := strings.Split(topic, "/")
+
+	for i, part := range patternParts {
+		if part == "#" {
+			return true
+		}
+		if i >= len(topicParts) {
+			return false
+		}
+		if part != "+" && part != topicParts[i] {
+			return false
+		}
+	}
+	return len(patternParts) == len(topicParts)
+}
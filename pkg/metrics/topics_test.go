@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+func receivedCount(t *testing.T, topic string) float64 {
+	t.Helper()
+	var metric dto.Metric
+	if err := MQTTMessagesReceived.WithLabelValues(topic).Write(&metric); err != nil {
+		t.Fatalf("failed to read metric: %v", err)
+	}
+	return metric.GetCounter().GetValue()
+}
+
+func TestTopicLabel(t *testing.T) {
+	SetTopicAllowlist([]string{"sensors/+/temp", "house/#", "garage/door"})
+	defer SetTopicAllowlist(nil)
+
+	tests := []struct {
+		topic string
+		want  string
+	}{
+		{topic: "sensors/kitchen/temp", want: "sensors/kitchen/temp"},
+		{topic: "house/lights/hall", want: "house/lights/hall"},
+		{topic: "garage/door", want: "garage/door"},
+		{topic: "sensors/kitchen/humidity", want: OtherTopicLabel},
+		{topic: "sensors/kitchen/temp/raw", want: OtherTopicLabel},
+		{topic: "garage/door/state", want: OtherTopicLabel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.topic, func(t *testing.T) {
+			if got := topicLabel(tt.topic); got != tt.want {
+				t.Errorf("topicLabel(%q) = %q, want %q", tt.topic, got, tt.want)
+			}
+		})
+	}
+
+	SetTopicAllowlist(nil)
+	if got := topicLabel("sensors/kitchen/humidity"); got != "sensors/kitchen/humidity" {
+		t.Errorf("topicLabel without an allowlist = %q", got)
+	}
+}
+
+func TestRecordMQTTReceiveAllowlist(t *testing.T) {
+	SetTopicAllowlist([]string{"allowlist/listed"})
+	defer SetTopicAllowlist(nil)
+
+	listed := receivedCount(t, "allowlist/listed")
+	other := receivedCount(t, OtherTopicLabel)
+
+	RecordMQTTReceive("allowlist/listed")
+	RecordMQTTReceive("allowlist/dynamic/1")
+	RecordMQTTReceive("allowlist/dynamic/2")
+
+	if got := receivedCount(t, "allowlist/listed") - listed; got != 1 {
+		t.Errorf("listed topic series increased by %v, want 1", got)
+	}
+	if got := receivedCount(t, OtherTopicLabel) - other; got != 2 {
+		t.Errorf("other series increased by %v, want 2", got)
+	}
+	if got := receivedCount(t, "allowlist/dynamic/1"); got != 0 {
+		t.Errorf("unlisted topic got its own series: %v", got)
+	}
+}
@@ -2,10 +2,12 @@
 package mqtt
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/denwilliams/go-mqtt-automation/pkg/config"
@@ -16,15 +18,53 @@ import (
 type Client struct {
 	config         config.MQTTConfig
 	client         mqtt.Client
-	handlers       map[string]EventHandler
+	handlers       map[string]subscription
+	handlersMutex  sync.RWMutex
 	state          ConnectionState
 	stateMutex     sync.RWMutex
 	logger         *log.Logger
 	stopChan       chan bool
 	reconnectDelay time.Duration
 	topicManager   TopicManager
+	dispatcher     *dispatcher
+
+	// publishTimeout bounds the wait for a delivery confirmation;
+	// onPublishResult receives the delivery report of every publish
+	publishTimeout  time.Duration
+	onPublishResult func(result PublishResult)
+
+	// queue buffers publishes while disconnected (nil when disabled)
+	queue *outboundQueue
+
+	// onConnectionChange is told when the broker connection is made or lost
+	onConnectionChange func(connected bool, err error)
+
+	// Connection history and traffic counters reported by Status;
+	// reconnectAttempts and lastConnectedAt are guarded by stateMutex
+	reconnectAttempts int
+	lastConnectedAt   time.Time
+	messagesIn        atomic.Uint64
+	messagesOut       atomic.Uint64
+
+	// addedTopics are subscriptions added at runtime with AddSubscription
+	addedTopics      []string
+	onTopicsChanged  func(topics []string)
+	addedTopicsMutex sync.Mutex
+
+	// requiredTopics is the exact subscription set used with minimal
+	// subscriptions (guarded by addedTopicsMutex); subscribedTopics is what
+	// has been subscribed so far (guarded by syncMutex)
+	requiredTopics   []string
+	subscribedTopics map[string]bool
+	syncMutex        sync.Mutex
 }
 
+// DefaultPublishTimeout is used when mqtt.publish_timeout is not set
+const DefaultPublishTimeout = 10 * time.Second
+
+// errNotConnected is returned by publishes made while disconnected
+var errNotConnected = errors.New("not connected to MQTT broker")
+
 type TopicManager interface {
 	HandleMQTTMessage(event Event) error
 }
@@ -34,14 +74,28 @@ func NewClient(cfg config.MQTTConfig, logger *log.Logger) *Client {
 		logger = log.Default()
 	}
 
-	return &Client{
+	publishTimeout, err := time.ParseDuration(cfg.PublishTimeout)
+	if err != nil || publishTimeout <= 0 {
+		publishTimeout = DefaultPublishTimeout
+	}
+
+	client := &Client{
 		config:         cfg,
-		handlers:       make(map[string]EventHandler),
+		handlers:       make(map[string]subscription),
 		state:          ConnectionStateClosed,
 		logger:         logger,
 		stopChan:       make(chan bool),
 		reconnectDelay: 5 * time.Second,
+		publishTimeout: publishTimeout,
+		queue:          newOutboundQueue(cfg.OutboundQueue),
 	}
+
+	// Process inbound messages off the paho callback goroutine
+	client.dispatcher = newDispatcher(cfg.MaxConcurrentMessages, cfg.OrderingPrefixes, func(event Event, err error) {
+		client.logger.Printf("Error handling message for topic %s: %v", event.Topic, err)
+	})
+
+	return client
 }
 
 func (c *Client) SetTopicManager(manager TopicManager) {
@@ -56,6 +110,7 @@ func (c *Client) Connect() error {
 		return nil
 	}
 
+	previousState := c.state
 	c.state = ConnectionStateConnecting
 	c.logger.Printf("Connecting to MQTT broker: %s", c.config.Broker)
 
@@ -75,27 +130,57 @@ func (c *Client) Connect() error {
 	opts.SetKeepAlive(30 * time.Second)
 	opts.SetPingTimeout(10 * time.Second)
 
+	c.setWill(opts)
+
 	opts.SetConnectionLostHandler(c.onConnectionLost)
 	opts.SetOnConnectHandler(c.onConnect)
 
 	opts.SetDefaultPublishHandler(c.onMessage)
 
+	// A previous Disconnect stopped the workers and reconnection
+	if previousState == ConnectionStateClosed {
+		c.dispatcher.start()
+		select {
+		case <-c.stopChan:
+			c.stopChan = make(chan bool)
+		default:
+		}
+	}
+
 	c.client = mqtt.NewClient(opts)
 
 	if token := c.client.Connect(); token.Wait() && token.Error() != nil {
 		c.state = ConnectionStateClosed
+		if previousState == ConnectionStateReconnecting {
+			// Still retrying in the background
+			c.state = ConnectionStateReconnecting
+		}
 		return fmt.Errorf("failed to connect to MQTT broker: %w", token.Error())
 	}
 
 	c.state = ConnectionStateConnected
+	c.reconnectAttempts = 0
+	c.lastConnectedAt = time.Now()
 	c.logger.Println("Successfully connected to MQTT broker")
 
 	// Update connection metrics
 	metrics.SetMQTTConnectionState(c.config.Broker, true)
 
-	// Subscribe to configured topics (async to prevent blocking)
+	// Send what was published while disconnected
+	if c.queue.pending() {
+		go c.flushQueue()
+	}
+
+	if c.config.MinimalSubscriptions {
+		// A clean session starts without subscriptions
+		go c.resyncSubscriptions()
+		return nil
+	}
+
+	// Subscribe to configured and added topics (async to prevent blocking)
+	subscriptions := append(append([]string{}, c.config.Topics...), c.AddedSubscriptions()...)
 	go func() {
-		for _, topic := range c.config.Topics {
+		for _, topic := range subscriptions {
 			if err := c.Subscribe(topic, c.handleTopicMessage); err != nil {
 				c.logger.Printf("Failed to subscribe to topic %s: %v", topic, err)
 			}
@@ -107,9 +192,9 @@ func (c *Client) Connect() error {
 
 func (c *Client) Disconnect() {
 	c.stateMutex.Lock()
-	defer c.stateMutex.Unlock()
 
 	if c.state == ConnectionStateClosed {
+		c.stateMutex.Unlock()
 		return
 	}
 
@@ -118,10 +203,18 @@ func (c *Client) Disconnect() {
 	close(c.stopChan)
 
 	if c.client != nil {
+		if c.state == ConnectionStateConnected {
+			c.publishWill()
+		}
 		c.client.Disconnect(250)
 	}
 
 	c.state = ConnectionStateClosed
+	c.stateMutex.Unlock()
+
+	// Finish processing queued messages. This happens outside the state lock
+	// because handlers may publish.
+	c.dispatcher.stop()
 
 	// Update connection metrics
 	metrics.SetMQTTConnectionState(c.config.Broker, false)
@@ -137,20 +230,101 @@ func (c *Client) Subscribe(topic string, handler EventHandler) error {
 		return fmt.Errorf("not connected to MQTT broker")
 	}
 
-	c.handlers[topic] = handler
+	qos := c.SubscriptionQoS(topic)
+	c.setHandler(topic, subscription{handler: handler, qos: qos})
 
-	token := c.client.Subscribe(topic, 0, nil)
+	token := c.client.Subscribe(topic, qos, nil)
 	token.Wait()
 
 	if token.Error() != nil {
-		delete(c.handlers, topic)
+		c.removeHandler(topic)
 		return fmt.Errorf("failed to subscribe to topic %s: %w", topic, token.Error())
 	}
 
-	c.logger.Printf("Subscribed to topic: %s", topic)
+	granted, err := grantedQoS(token, topic, qos)
+	if err != nil {
+		c.removeHandler(topic)
+		return err
+	}
+	c.setHandler(topic, subscription{handler: handler, qos: granted})
+
+	c.logger.Printf("Subscribed to topic: %s (QoS %d)", topic, granted)
 	return nil
 }
 
+// AddSubscription subscribes to pattern unless an existing subscription already
+// covers it, replacing added subscriptions the new pattern covers. Subscriptions
+// added while disconnected are made on the next (re)connect. It reports whether
+// a subscription was added.
+func (c *Client) AddSubscription(pattern string) bool {
+	c.addedTopicsMutex.Lock()
+	for _, existing := range c.config.Topics {
+		if SubscriptionCovers(SubscriptionFilter(existing), pattern) {
+			c.addedTopicsMutex.Unlock()
+			return false
+		}
+	}
+	for _, existing := range c.addedTopics {
+		if SubscriptionCovers(existing, pattern) {
+			c.addedTopicsMutex.Unlock()
+			return false
+		}
+	}
+
+	var replaced []string
+	kept := make([]string, 0, len(c.addedTopics)+1)
+	for _, existing := range c.addedTopics {
+		if SubscriptionCovers(pattern, existing) {
+			replaced = append(replaced, existing)
+		} else {
+			kept = append(kept, existing)
+		}
+	}
+	c.addedTopics = append(kept, pattern)
+	added := append([]string{}, c.addedTopics...)
+	onTopicsChanged := c.onTopicsChanged
+	c.addedTopicsMutex.Unlock()
+
+	c.logger.Printf("Adding subscription for topic: %s", pattern)
+	if onTopicsChanged != nil {
+		onTopicsChanged(added)
+	}
+
+	if c.IsConnected() {
+		// Async like the initial subscriptions so callers holding locks don't block
+		go func() {
+			if err := c.Subscribe(pattern, c.handleTopicMessage); err != nil {
+				c.logger.Printf("Failed to subscribe to topic %s: %v", pattern, err)
+				return
+			}
+			for _, topic := range replaced {
+				if err := c.Unsubscribe(topic); err != nil {
+					c.logger.Printf("Failed to unsubscribe from topic %s: %v", topic, err)
+				}
+			}
+		}()
+	}
+
+	return true
+}
+
+// AddedSubscriptions returns the subscriptions added at runtime
+func (c *Client) AddedSubscriptions() []string {
+	c.addedTopicsMutex.Lock()
+	defer c.addedTopicsMutex.Unlock()
+
+	return append([]string{}, c.addedTopics...)
+}
+
+// SetAddedSubscriptionsHandler sets a callback invoked with all added
+// subscriptions whenever one is added, so they can be persisted
+func (c *Client) SetAddedSubscriptionsHandler(handler func(topics []string)) {
+	c.addedTopicsMutex.Lock()
+	defer c.addedTopicsMutex.Unlock()
+
+	c.onTopicsChanged = handler
+}
+
 func (c *Client) Unsubscribe(topic string) error {
 	c.stateMutex.RLock()
 	defer c.stateMutex.RUnlock()
@@ -159,7 +333,7 @@ func (c *Client) Unsubscribe(topic string) error {
 		return fmt.Errorf("not connected to MQTT broker")
 	}
 
-	delete(c.handlers, topic)
+	c.removeHandler(topic)
 
 	token := c.client.Unsubscribe(topic)
 	token.Wait()
@@ -172,25 +346,126 @@ func (c *Client) Unsubscribe(topic string) error {
 	return nil
 }
 
+// SetPublishResultHandler sets a callback receiving the delivery report of
+// every publish
+func (c *Client) SetPublishResultHandler(handler func(result PublishResult)) {
+	c.onPublishResult = handler
+}
+
+// Publish sends a message to the broker at the topic's configured QoS (see
+// PublishQoS). With an outbound queue, messages published while disconnected
+// are queued and sent once reconnected.
 func (c *Client) Publish(topic string, payload []byte, retain bool) error {
+	return c.publish(topic, payload, c.PublishQoS(topic), retain, false)
+}
+
+// PublishWithQoS sends a message to the broker at qos, overriding the
+// configured QoS
+func (c *Client) PublishWithQoS(topic string, payload []byte, qos byte, retain bool) error {
+	return c.publish(topic, payload, qos, retain, false)
+}
+
+// publish sends a message; flushing is set for messages sent from the
+// outbound queue, which fail with errNotConnected rather than being queued
+func (c *Client) publish(topic string, payload []byte, qos byte, retain bool, flushing bool) error {
 	c.stateMutex.RLock()
 	defer c.stateMutex.RUnlock()
 
+	result := PublishResult{
+		Topic:     topic,
+		QoS:       qos,
+		Retained:  retain,
+		Bytes:     len(payload),
+		Outcome:   PublishDelivered,
+		Timestamp: time.Now(),
+	}
+
+	if flushing && c.state != ConnectionStateConnected {
+		return errNotConnected
+	}
+	// Queue behind earlier messages until they have been sent
+	if c.queue != nil && !flushing {
+		if queued, err := c.enqueue(result, payload); queued {
+			return err
+		}
+	}
 	if c.state != ConnectionStateConnected {
-		return fmt.Errorf("not connected to MQTT broker")
+		result.Outcome = PublishFailed
+		result.Err = errNotConnected
+		c.reportPublish(result)
+		return result.Err
 	}
 
-	token := c.client.Publish(topic, 0, retain, payload)
-	token.Wait()
+	token := c.client.Publish(topic, result.QoS, retain, payload)
+	if !token.WaitTimeout(c.publishTimeout) {
+		result.Outcome = PublishTimedOut
+		result.Err = fmt.Errorf("no delivery confirmation after %s", c.publishTimeout)
+	} else if err := token.Error(); err != nil {
+		result.Outcome = PublishFailed
+		result.Err = err
+	}
+	result.Duration = time.Since(result.Timestamp)
+	c.reportPublish(result)
 
-	if token.Error() != nil {
-		return fmt.Errorf("failed to publish to topic %s: %w", topic, token.Error())
+	if result.Err != nil {
+		return fmt.Errorf("failed to publish to topic %s: %w", topic, result.Err)
 	}
+	c.messagesOut.Add(1)
 
 	c.logger.Printf("Published to topic: %s (%d bytes)", topic, len(payload))
 	return nil
 }
 
+// enqueue adds a publish to the outbound queue while disconnected or while
+// earlier messages are pending, reporting it as queued (or failed when the
+// queue is full and drops new messages). It reports false when the message
+// can be published directly. The caller must hold the state lock.
+func (c *Client) enqueue(result PublishResult, payload []byte) (bool, error) {
+	queued, flush, dropped, rejected := c.queue.pushIfPending(queuedMessage{
+		topic:    result.Topic,
+		payload:  payload,
+		qos:      result.QoS,
+		retain:   result.Retained,
+		queuedAt: result.Timestamp,
+	}, c.state == ConnectionStateConnected)
+	if !queued {
+		return false, nil
+	}
+	if flush {
+		go c.flushQueue()
+	}
+	if rejected {
+		result.Outcome = PublishFailed
+		result.Err = fmt.Errorf("outbound queue is full (%d messages)", c.queue.size)
+		c.reportPublish(result)
+		return true, fmt.Errorf("failed to publish to topic %s: %w", result.Topic, result.Err)
+	}
+	if dropped != nil {
+		c.logger.Printf("Outbound queue is full; dropped the oldest queued message for %s", dropped.topic)
+		c.reportPublish(PublishResult{
+			Topic:     dropped.topic,
+			QoS:       dropped.qos,
+			Retained:  dropped.retain,
+			Bytes:     len(dropped.payload),
+			Outcome:   PublishDropped,
+			Err:       fmt.Errorf("dropped from the full outbound queue"),
+			Timestamp: time.Now(),
+		})
+	}
+
+	result.Outcome = PublishQueued
+	c.reportPublish(result)
+	return true, nil
+}
+
+// reportPublish records a publish delivery report
+func (c *Client) reportPublish(result PublishResult) {
+	metrics.RecordMQTTPublishResult(result.Topic, string(result.Outcome))
+	if c.onPublishResult != nil {
+		c.onPublishResult(result)
+	}
+}
+
 func (c *Client) IsConnected() bool {
 	c.stateMutex.RLock()
 	defer c.stateMutex.RUnlock()
@@ -203,27 +478,44 @@ func (c *Client) GetState() ConnectionState {
 	return c.state
 }
 
+// SetConnectionHandler sets a callback invoked when the broker connection is
+// made (connected is true) or lost (with the error)
+func (c *Client) SetConnectionHandler(handler func(connected bool, err error)) {
+	c.onConnectionChange = handler
+}
+
 func (c *Client) onConnect(client mqtt.Client) {
 	c.logger.Println("MQTT client connected")
+	c.publishBirth(client)
+	if c.onConnectionChange != nil {
+		c.onConnectionChange(true, nil)
+	}
 }
 
 func (c *Client) onConnectionLost(client mqtt.Client, err error) {
 	c.stateMutex.Lock()
 	c.state = ConnectionStateReconnecting
+	stopChan := c.stopChan
 	c.stateMutex.Unlock()
 
 	c.logger.Printf("Connection lost: %v", err)
 	c.logger.Println("Starting reconnection attempts...")
+	if c.onConnectionChange != nil {
+		c.onConnectionChange(false, err)
+	}
 
-	go c.reconnect()
+	go c.reconnect(stopChan)
 }
 
-func (c *Client) reconnect() {
+func (c *Client) reconnect(stopChan chan bool) {
 	for {
 		select {
-		case <-c.stopChan:
+		case <-stopChan:
 			return
 		case <-time.After(c.reconnectDelay):
+			c.stateMutex.Lock()
+			c.reconnectAttempts++
+			c.stateMutex.Unlock()
 			c.logger.Println("Attempting to reconnect...")
 
 			if err := c.Connect(); err != nil {
@@ -243,21 +535,53 @@ func (c *Client) reconnect() {
 }
 
 func (c *Client) onMessage(client mqtt.Client, msg mqtt.Message) {
+	c.messagesIn.Add(1)
+
 	event := Event{
 		Topic:     msg.Topic(),
 		Payload:   msg.Payload(),
 		Timestamp: time.Now(),
+		QoS:       msg.Qos(),
+		Retained:  msg.Retained(),
 	}
 
-	// Find matching handler
-	for pattern, handler := range c.handlers {
-		if c.topicMatches(pattern, msg.Topic()) {
-			if err := handler(event); err != nil {
-				c.logger.Printf("Error handling message for topic %s: %v", msg.Topic(), err)
-			}
-			break
+	// Find matching handler and queue it so a slow handler doesn't block the MQTT read loop
+	handler := c.matchHandler(msg.Topic())
+	if handler == nil {
+		return
+	}
+	if !c.dispatcher.dispatch(event, handler) {
+		c.logger.Printf("Dropping message for topic %s: client is shutting down", msg.Topic())
+	}
+}
+
+// matchHandler returns the handler of a subscription matching topic, or nil
+func (c *Client) matchHandler(topic string) EventHandler {
+	c.handlersMutex.RLock()
+	defer c.handlersMutex.RUnlock()
+
+	for pattern, sub := range c.handlers {
+		if c.topicMatches(SubscriptionFilter(pattern), topic) {
+			return sub.handler
 		}
 	}
+	return nil
+}
+
+// setHandler registers the handler of a subscription. Subscriptions are made
+// from several goroutines while messages are being matched.
+func (c *Client) setHandler(topic string, sub subscription) {
+	c.handlersMutex.Lock()
+	defer c.handlersMutex.Unlock()
+
+	c.handlers[topic] = sub
+}
+
+func (c *Client) removeHandler(topic string) {
+	c.handlersMutex.Lock()
+	defer c.handlersMutex.Unlock()
+
+	delete(c.handlers, topic)
 }
 
 func (c *Client) handleTopicMessage(event Event) error {
@@ -300,6 +624,58 @@ func TopicMatches(pattern, topic string) bool {
 	return matchSegments(patternSegments, topicSegments)
 }
 
+// SubscriptionCovers reports whether every topic matched by pattern is also
+// matched by subscription
+func SubscriptionCovers(subscription, pattern string) bool {
+	subscriptionSegments := strings.Split(subscription, "/")
+	patternSegments := strings.Split(pattern, "/")
+
+	for i, segment := range subscriptionSegments {
+		if segment == "#" {
+			return true
+		}
+		if i >= len(patternSegments) {
+			return false
+		}
+		switch {
+		case segment == "+":
+			if patternSegments[i] == "#" {
+				return false
+			}
+		case segment != patternSegments[i]:
+			return false
+		}
+	}
+
+	return len(subscriptionSegments) == len(patternSegments)
+}
+
+// PatternsOverlap reports whether some topic is matched by both patterns
+func PatternsOverlap(a, b string) bool {
+	aSegments := strings.Split(a, "/")
+	bSegments := strings.Split(b, "/")
+
+	for i := 0; ; i++ {
+		aDone, bDone := i >= len(aSegments), i >= len(bSegments)
+		switch {
+		case aDone && bDone:
+			return true
+		case aDone:
+			// # also matches the parent level
+			return bSegments[i] == "#"
+		case bDone:
+			return aSegments[i] == "#"
+		}
+
+		if aSegments[i] == "#" || bSegments[i] == "#" {
+			return true
+		}
+		if aSegments[i] != "+" && bSegments[i] != "+" && aSegments[i] != bSegments[i] {
+			return false
+		}
+	}
+}
+
 func matchSegments(patternSegments, topicSegments []string) bool {
 	patternLen := len(patternSegments)
 	topicLen := len(topicSegments)
@@ -0,0 +1,153 @@
+package mqtt
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/config"
+)
+
+func TestSubscriptionCovers(t *testing.T) {
+	tests := []struct {
+		subscription string
+		pattern      string
+		want         bool
+	}{
+		{"sensors/temp", "sensors/temp", true},
+		{"sensors/+", "sensors/temp", true},
+		{"sensors/+", "sensors/+", true},
+		{"sensors/#", "sensors/+/battery", true},
+		{"sensors/#", "sensors", true},
+		{"#", "anything/at/all", true},
+		{"sensors/+", "sensors/#", false},
+		{"sensors/+", "sensors/kitchen/temp", false},
+		{"sensors/temp", "sensors/+", false},
+		{"sensors/+/battery", "sensors/+", false},
+		{"devices/+", "sensors/temp", false},
+	}
+
+	for _, tt := range tests {
+		if got := SubscriptionCovers(tt.subscription, tt.pattern); got != tt.want {
+			t.Errorf("SubscriptionCovers(%q, %q) = %v, want %v", tt.subscription, tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestPatternsOverlap(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"sensors/+/temp", "sensors/kitchen/#", true},
+		{"sensors/+/temp", "sensors/kitchen/+", true},
+		{"sensors/#", "sensors", true},
+		{"+/kitchen/temp", "sensors/+/temp", true},
+		{"#", "anything", true},
+		{"sensors/+/temp", "sensors/+/humidity", false},
+		{"sensors/+", "sensors/+/temp", false},
+		{"sensors/kitchen/#", "devices/#", false},
+	}
+
+	for _, tt := range tests {
+		if got := PatternsOverlap(tt.a, tt.b); got != tt.want {
+			t.Errorf("PatternsOverlap(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+		if got := PatternsOverlap(tt.b, tt.a); got != tt.want {
+			t.Errorf("PatternsOverlap(%q, %q) = %v, want %v", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestClientAddSubscription(t *testing.T) {
+	client := NewClient(config.MQTTConfig{Topics: []string{"sensors/+"}}, nil)
+
+	var persisted []string
+	client.SetAddedSubscriptionsHandler(func(topics []string) {
+		persisted = topics
+	})
+
+	if client.AddSubscription("sensors/temp") {
+		t.Error("a pattern covered by a configured subscription should not be added")
+	}
+	if !client.AddSubscription("zigbee/+/battery") {
+		t.Error("a novel pattern should be added")
+	}
+	if client.AddSubscription("zigbee/+/battery") {
+		t.Error("the same pattern should not be added twice")
+	}
+	if !client.AddSubscription("cameras/front/motion") {
+		t.Error("a second novel pattern should be added")
+	}
+
+	// A broader pattern replaces the added subscriptions it covers
+	if !client.AddSubscription("zigbee/#") {
+		t.Error("a broader pattern should be added")
+	}
+
+	want := []string{"cameras/front/motion", "zigbee/#"}
+	if got := client.AddedSubscriptions(); !reflect.DeepEqual(got, want) {
+		t.Errorf("AddedSubscriptions() = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(persisted, want) {
+		t.Errorf("persisted subscriptions = %v, want %v", persisted, want)
+	}
+}
+
+func TestClientAddSubscriptionConcurrently(t *testing.T) {
+	const patterns = 20
+
+	client := NewClient(config.MQTTConfig{}, nil)
+	defer client.dispatcher.stop()
+	broker := &qosPahoClient{subscribed: make(map[string]byte)}
+	client.state = ConnectionStateConnected
+	client.client = broker
+
+	// Subscriptions are added while messages arrive
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				client.onMessage(nil, &fakeMessage{topic: "devices/0/state", payload: []byte("1")})
+			}
+		}
+	}()
+
+	for i := 0; i < patterns; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			client.AddSubscription(fmt.Sprintf("devices/%d/#", i))
+		}(i)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		broker.mutex.Lock()
+		subscribed := len(broker.subscribed)
+		broker.mutex.Unlock()
+		if subscribed == patterns {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d of %d subscriptions made", subscribed, patterns)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(stop)
+	wg.Wait()
+
+	client.handlersMutex.RLock()
+	defer client.handlersMutex.RUnlock()
+	if len(client.handlers) != patterns {
+		t.Errorf("%d handlers registered, want %d", len(client.handlers), patterns)
+	}
+}
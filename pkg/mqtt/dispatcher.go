@@ -0,0 +1,143 @@
+package mqtt
+
+import (
+	"hash/fnv"
+	"strings"
+	"sync"
+)
+
+const (
+	// defaultMessageWorkers is used when no concurrency limit is configured
+	defaultMessageWorkers = 4
+	// messageQueueSize is the number of messages each worker can buffer before
+	// the MQTT callback blocks
+	messageQueueSize = 256
+)
+
+type dispatchItem struct {
+	event   Event
+	handler EventHandler
+}
+
+// dispatcher processes inbound messages on a bounded pool of workers. Messages
+// with the same ordering key always go to the same worker, so they are
+// processed in arrival order while other keys are processed concurrently.
+// The key is the topic, or the device prefix for topics under an ordering
+// prefix.
+type dispatcher struct {
+	queues   []chan dispatchItem
+	prefixes [][]string // ordering prefixes split into levels
+	onError  func(event Event, err error)
+	wg       *sync.WaitGroup // workers of the current queues
+	mutex    sync.RWMutex
+	closed   bool
+}
+
+func newDispatcher(workers int, orderingPrefixes []string, onError func(event Event, err error)) *dispatcher {
+	if workers <= 0 {
+		workers = defaultMessageWorkers
+	}
+
+	d := &dispatcher{
+		queues:  make([]chan dispatchItem, workers),
+		onError: onError,
+		closed:  true,
+	}
+	for _, prefix := range orderingPrefixes {
+		d.prefixes = append(d.prefixes, strings.Split(prefix, "/"))
+	}
+	d.start()
+
+	return d
+}
+
+// start starts the workers if the dispatcher is stopped, so it can be
+// restarted when the client reconnects after a disconnect
+func (d *dispatcher) start() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if !d.closed {
+		return
+	}
+	d.closed = false
+
+	// Workers of a previous run may still be draining their old queues
+	d.wg = &sync.WaitGroup{}
+	for i := range d.queues {
+		d.queues[i] = make(chan dispatchItem, messageQueueSize)
+		d.wg.Add(1)
+		go d.work(d.queues[i], d.wg)
+	}
+}
+
+// dispatch queues an event for processing. It returns false if the dispatcher
+// has been stopped.
+func (d *dispatcher) dispatch(event Event, handler EventHandler) bool {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+
+	if d.closed {
+		return false
+	}
+
+	d.queues[d.queueIndex(d.orderingKey(event.Topic))] <- dispatchItem{event: event, handler: handler}
+	return true
+}
+
+// stop stops accepting messages and waits for queued messages to be processed
+func (d *dispatcher) stop() {
+	d.mutex.Lock()
+	if d.closed {
+		d.mutex.Unlock()
+		return
+	}
+	d.closed = true
+	for _, queue := range d.queues {
+		close(queue)
+	}
+	wg := d.wg
+	d.mutex.Unlock()
+
+	wg.Wait()
+}
+
+// orderingKey returns the key whose messages are processed in order: the
+// levels of topic matched by the first ordering prefix it falls under (so
+// "zigbee2mqtt/+" keys "zigbee2mqtt/kitchen/temperature" by
+// "zigbee2mqtt/kitchen"), or the topic itself
+func (d *dispatcher) orderingKey(topic string) string {
+	levels := strings.Split(topic, "/")
+	for _, prefix := range d.prefixes {
+		if len(levels) < len(prefix) {
+			continue
+		}
+		matched := true
+		for i, level := range prefix {
+			if level != "+" && level != levels[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return strings.Join(levels[:len(prefix)], "/")
+		}
+	}
+	return topic
+}
+
+func (d *dispatcher) queueIndex(key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(len(d.queues)))
+}
+
+func (d *dispatcher) work(queue chan dispatchItem, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	for item := range queue {
+		if err := item.handler(item.event); err != nil && d.onError != nil {
+			d.onError(item.event, err)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package mqtt
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/config"
+)
+
+// fakeMessage implements the paho Message interface for tests
+type fakeMessage struct {
+	topic   string
+	payload []byte
+}
+
+func (m *fakeMessage) Duplicate() bool   { return false }
+func (m *fakeMessage) Qos() byte         { return 0 }
+func (m *fakeMessage) Retained() bool    { return false }
+func (m *fakeMessage) Topic() string     { return m.topic }
+func (m *fakeMessage) MessageID() uint16 { return 0 }
+func (m *fakeMessage) Payload() []byte   { return m.payload }
+func (m *fakeMessage) Ack()              {}
+
+func TestOnMessageProcessesWithBoundedConcurrency(t *testing.T) {
+	const (
+		workers     = 3
+		topicCount  = 10
+		perTopic    = 20
+		handlerTime = 2 * time.Millisecond
+	)
+
+	client := NewClient(config.MQTTConfig{MaxConcurrentMessages: workers}, nil)
+
+	var (
+		active, maxActive int32
+		mutex             sync.Mutex
+		received          = make(map[string][]int)
+		wg                sync.WaitGroup
+	)
+	wg.Add(topicCount * perTopic)
+
+	client.handlers["sensors/#"] = subscription{handler: func(event Event) error {
+		defer wg.Done()
+
+		current := atomic.AddInt32(&active, 1)
+		for {
+			observed := atomic.LoadInt32(&maxActive)
+			if current <= observed || atomic.CompareAndSwapInt32(&maxActive, observed, current) {
+				break
+			}
+		}
+		time.Sleep(handlerTime)
+		atomic.AddInt32(&active, -1)
+
+		var seq int
+		fmt.Sscanf(string(event.Payload), "%d", &seq)
+		mutex.Lock()
+		received[event.Topic] = append(received[event.Topic], seq)
+		mutex.Unlock()
+		return nil
+	}}
+
+	start := time.Now()
+	for seq := 0; seq < perTopic; seq++ {
+		for topic := 0; topic < topicCount; topic++ {
+			client.onMessage(nil, &fakeMessage{
+				topic:   fmt.Sprintf("sensors/%d", topic),
+				payload: []byte(fmt.Sprintf("%d", seq)),
+			})
+		}
+	}
+
+	// Processing serially would take topicCount*perTopic*handlerTime (400ms)
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("onMessage blocked for %v, expected it to return quickly", elapsed)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for messages to be processed")
+	}
+
+	if maxActive > workers {
+		t.Errorf("max concurrent handlers = %d, want at most %d", maxActive, workers)
+	}
+
+	for topic, seqs := range received {
+		if len(seqs) != perTopic {
+			t.Errorf("topic %s received %d messages, want %d", topic, len(seqs), perTopic)
+		}
+		for i, seq := range seqs {
+			if seq != i {
+				t.Errorf("topic %s processed out of order: %v", topic, seqs)
+				break
+			}
+		}
+	}
+}
+
+func TestDispatcherStopDrainsQueue(t *testing.T) {
+	var processed int32
+	d := newDispatcher(2, nil, nil)
+
+	for i := 0; i < 50; i++ {
+		d.dispatch(Event{Topic: fmt.Sprintf("topic/%d", i%5)}, func(event Event) error {
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&processed, 1)
+			return nil
+		})
+	}
+
+	d.stop()
+
+	if processed != 50 {
+		t.Errorf("processed %d messages before stop returned, want 50", processed)
+	}
+	if d.dispatch(Event{Topic: "topic/0"}, func(Event) error { return nil }) {
+		t.Error("dispatch should fail after stop")
+	}
+}
+
+func TestDispatcherRestartsAfterStop(t *testing.T) {
+	d := newDispatcher(2, nil, nil)
+	d.stop()
+	d.start()
+	defer d.stop()
+
+	handled := make(chan Event, 1)
+	if !d.dispatch(Event{Topic: "sensors/temp"}, func(event Event) error {
+		handled <- event
+		return nil
+	}) {
+		t.Fatal("dispatch failed after restart")
+	}
+
+	select {
+	case event := <-handled:
+		if event.Topic != "sensors/temp" {
+			t.Errorf("handled topic %s, want sensors/temp", event.Topic)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("message dispatched after restart was not handled")
+	}
+}
+
+func TestDispatcherReportsHandlerErrors(t *testing.T) {
+	errs := make(chan error, 1)
+	d := newDispatcher(1, nil, func(event Event, err error) {
+		errs <- err
+	})
+	defer d.stop()
+
+	d.dispatch(Event{Topic: "sensors/temp"}, func(Event) error {
+		return fmt.Errorf("boom")
+	})
+
+	select {
+	case err := <-errs:
+		if err.Error() != "boom" {
+			t.Errorf("error = %v, want boom", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler error was not reported")
+	}
+}
+
+func TestDispatcherOrderingKey(t *testing.T) {
+	d := newDispatcher(1, []string{"zigbee2mqtt/+", "home/+/+/state"}, nil)
+	defer d.stop()
+
+	tests := []struct {
+		topic string
+		want  string
+	}{
+		{topic: "zigbee2mqtt/kitchen/temperature", want: "zigbee2mqtt/kitchen"},
+		{topic: "zigbee2mqtt/kitchen", want: "zigbee2mqtt/kitchen"},
+		{topic: "zigbee2mqtt", want: "zigbee2mqtt"},
+		{topic: "home/hall/light/state/brightness", want: "home/hall/light/state"},
+		{topic: "home/hall/light/set", want: "home/hall/light/set"},
+		{topic: "sensors/temp", want: "sensors/temp"},
+	}
+
+	for _, tt := range tests {
+		if got := d.orderingKey(tt.topic); got != tt.want {
+			t.Errorf("orderingKey(%q) = %q, want %q", tt.topic, got, tt.want)
+		}
+	}
+}
+
+func TestDispatcherPreservesPerDeviceOrder(t *testing.T) {
+	const perDevice = 100
+	d := newDispatcher(8, []string{"devices/+"}, nil)
+
+	var mutex sync.Mutex
+	received := make(map[string][]int)
+	handler := func(event Event) error {
+		var seq int
+		fmt.Sscanf(string(event.Payload), "%d", &seq)
+		// Vary handling time so unordered workers would reorder messages
+		time.Sleep(time.Duration(seq%3) * 100 * time.Microsecond)
+
+		device := d.orderingKey(event.Topic)
+		mutex.Lock()
+		received[device] = append(received[device], seq)
+		mutex.Unlock()
+		return nil
+	}
+
+	// Interleave the topics of two devices
+	topics := []string{"devices/a/temperature", "devices/b/temperature", "devices/a/humidity", "devices/b/battery", "devices/a/battery"}
+	seqs := make(map[string]int)
+	for i := 0; i < perDevice*len(topics); i++ {
+		topic := topics[i%len(topics)]
+		device := d.orderingKey(topic)
+		d.dispatch(Event{Topic: topic, Payload: []byte(fmt.Sprintf("%d", seqs[device]))}, handler)
+		seqs[device]++
+	}
+	d.stop()
+
+	for device, want := range seqs {
+		got := received[device]
+		if len(got) != want {
+			t.Errorf("device %s handled %d messages, want %d", device, len(got), want)
+		}
+		for i, seq := range got {
+			if seq != i {
+				t.Errorf("device %s processed out of order at %d: got %d", device, i, seq)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package mqtt
+
+// SetSubscriptions replaces the subscription set with exactly patterns when
+// minimal subscriptions are enabled. New patterns are subscribed and patterns
+// no longer needed are unsubscribed; changes made while disconnected are
+// applied on the next (re)connect.
+func (c *Client) SetSubscriptions(patterns []string) {
+	if !c.config.MinimalSubscriptions {
+		return
+	}
+
+	c.addedTopicsMutex.Lock()
+	c.requiredTopics = append([]string{}, patterns...)
+	c.addedTopicsMutex.Unlock()
+
+	if c.IsConnected() {
+		// Async so callers holding locks don't block on the broker
+		go c.syncSubscriptions()
+	}
+}
+
+// RequiredSubscriptions returns the subscription set used with minimal
+// subscriptions
+func (c *Client) RequiredSubscriptions() []string {
+	c.addedTopicsMutex.Lock()
+	defer c.addedTopicsMutex.Unlock()
+
+	return append([]string{}, c.requiredTopics...)
+}
+
+// resyncSubscriptions forgets the current subscriptions, which a clean
+// session drops, and subscribes the required set again
+func (c *Client) resyncSubscriptions() {
+	c.syncMutex.Lock()
+	c.subscribedTopics = nil
+	c.syncMutex.Unlock()
+
+	c.syncSubscriptions()
+}
+
+// syncSubscriptions brings the broker subscriptions in line with the latest
+// required set. Syncs are serialized and always work from the latest set, so
+// overlapping calls converge regardless of the order they run in.
+func (c *Client) syncSubscriptions() {
+	c.syncMutex.Lock()
+	defer c.syncMutex.Unlock()
+
+	if c.subscribedTopics == nil {
+		c.subscribedTopics = make(map[string]bool)
+	}
+
+	required := make(map[string]bool)
+	for _, pattern := range c.RequiredSubscriptions() {
+		required[pattern] = true
+	}
+
+	for pattern := range required {
+		if c.subscribedTopics[pattern] {
+			continue
+		}
+		if err := c.Subscribe(pattern, c.handleTopicMessage); err != nil {
+			c.logger.Printf("Failed to subscribe to topic %s: %v", pattern, err)
+			continue
+		}
+		c.subscribedTopics[pattern] = true
+	}
+
+	for pattern := range c.subscribedTopics {
+		if required[pattern] {
+			continue
+		}
+		if err := c.Unsubscribe(pattern); err != nil {
+			c.logger.Printf("Failed to unsubscribe from topic %s: %v", pattern, err)
+			continue
+		}
+		delete(c.subscribedTopics, pattern)
+	}
+}
@@ -0,0 +1,82 @@
+package mqtt
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/config"
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// subscribingPahoClient tracks the topics subscribed on the broker
+type subscribingPahoClient struct {
+	paho.Client
+	mutex        sync.Mutex
+	subscribed   map[string]bool
+	unsubscribed []string
+}
+
+func (m *subscribingPahoClient) Subscribe(topic string, qos byte, callback paho.MessageHandler) paho.Token {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.subscribed[topic] = true
+	return newMockToken(0, nil)
+}
+
+func (m *subscribingPahoClient) Unsubscribe(topics ...string) paho.Token {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, topic := range topics {
+		delete(m.subscribed, topic)
+		m.unsubscribed = append(m.unsubscribed, topic)
+	}
+	return newMockToken(0, nil)
+}
+
+func (m *subscribingPahoClient) topics() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	topics := make([]string, 0, len(m.subscribed))
+	for topic := range m.subscribed {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+func TestClientSetSubscriptions(t *testing.T) {
+	client := NewClient(config.MQTTConfig{Topics: []string{"sensors/#"}, MinimalSubscriptions: true}, nil)
+	broker := &subscribingPahoClient{subscribed: make(map[string]bool)}
+	client.state = ConnectionStateConnected
+	client.client = broker
+
+	client.SetSubscriptions([]string{"sensors/kitchen/temp", "zigbee/+/battery"})
+	// Syncs are serialized and idempotent, so this waits for the async one
+	client.syncSubscriptions()
+	if got, want := broker.topics(), []string{"sensors/kitchen/temp", "zigbee/+/battery"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("subscribed = %v, want %v", got, want)
+	}
+
+	client.SetSubscriptions([]string{"sensors/kitchen/temp"})
+	client.syncSubscriptions()
+	if got, want := broker.topics(), []string{"sensors/kitchen/temp"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("subscribed after removal = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(broker.unsubscribed, []string{"zigbee/+/battery"}) {
+		t.Errorf("unsubscribed = %v, want [zigbee/+/battery]", broker.unsubscribed)
+	}
+	if _, ok := client.handlers["zigbee/+/battery"]; ok {
+		t.Error("handler for an unsubscribed pattern was kept")
+	}
+}
+
+func TestClientSetSubscriptionsDisabled(t *testing.T) {
+	client := NewClient(config.MQTTConfig{Topics: []string{"sensors/#"}}, nil)
+
+	client.SetSubscriptions([]string{"sensors/kitchen/temp"})
+	if got := client.RequiredSubscriptions(); len(got) != 0 {
+		t.Errorf("RequiredSubscriptions() = %v without minimal subscriptions, want none", got)
+	}
+}
@@ -0,0 +1,151 @@
+package mqtt
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/config"
+	"github.com/denwilliams/go-mqtt-automation/pkg/metrics"
+	paho "github.com/eclipse/paho.mqtt.golang"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// mockToken completes after delay with err
+type mockToken struct {
+	done chan struct{}
+	err  error
+}
+
+func newMockToken(delay time.Duration, err error) *mockToken {
+	token := &mockToken{done: make(chan struct{}), err: err}
+	time.AfterFunc(delay, func() { close(token.done) })
+	return token
+}
+
+func (t *mockToken) Wait() bool {
+	<-t.done
+	return true
+}
+
+func (t *mockToken) WaitTimeout(timeout time.Duration) bool {
+	select {
+	case <-t.done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+func (t *mockToken) Done() <-chan struct{} {
+	return t.done
+}
+
+func (t *mockToken) Error() error {
+	select {
+	case <-t.done:
+		return t.err
+	default:
+		return nil
+	}
+}
+
+// mockPahoClient returns tokens from tokenFunc for publishes
+type mockPahoClient struct {
+	paho.Client
+	tokenFunc func(topic string) paho.Token
+}
+
+func (m *mockPahoClient) Publish(topic string, qos byte, retained bool, payload interface{}) paho.Token {
+	return m.tokenFunc(topic)
+}
+
+func publishResultCount(t *testing.T, topic string, outcome PublishOutcome) float64 {
+	t.Helper()
+
+	var metric dto.Metric
+	if err := metrics.MQTTPublishResults.WithLabelValues(topic, string(outcome)).Write(&metric); err != nil {
+		t.Fatalf("failed to read metric: %v", err)
+	}
+	return metric.GetCounter().GetValue()
+}
+
+func TestClientPublishResults(t *testing.T) {
+	client := NewClient(config.MQTTConfig{PublishTimeout: "50ms"}, nil)
+	client.state = ConnectionStateConnected
+	client.client = &mockPahoClient{
+		tokenFunc: func(topic string) paho.Token {
+			switch topic {
+			case "test/publish/delayed":
+				return newMockToken(20*time.Millisecond, nil)
+			case "test/publish/failed":
+				return newMockToken(0, errors.New("broker rejected"))
+			case "test/publish/stuck":
+				return newMockToken(time.Hour, nil)
+			}
+			return newMockToken(0, nil)
+		},
+	}
+
+	var results []PublishResult
+	client.SetPublishResultHandler(func(result PublishResult) {
+		results = append(results, result)
+	})
+
+	tests := []struct {
+		topic   string
+		outcome PublishOutcome
+		wantErr bool
+	}{
+		{topic: "test/publish/ok", outcome: PublishDelivered},
+		{topic: "test/publish/delayed", outcome: PublishDelivered},
+		{topic: "test/publish/failed", outcome: PublishFailed, wantErr: true},
+		{topic: "test/publish/stuck", outcome: PublishTimedOut, wantErr: true},
+	}
+
+	for i, tt := range tests {
+		before := publishResultCount(t, tt.topic, tt.outcome)
+
+		err := client.Publish(tt.topic, []byte("42"), true)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Publish(%s) error = %v, wantErr %v", tt.topic, err, tt.wantErr)
+		}
+
+		if len(results) != i+1 {
+			t.Fatalf("got %d publish results after %s, want %d", len(results), tt.topic, i+1)
+		}
+		result := results[i]
+		if result.Topic != tt.topic || result.Outcome != tt.outcome || !result.Retained || result.Bytes != 2 {
+			t.Errorf("publish result for %s = %+v, want outcome %s", tt.topic, result, tt.outcome)
+		}
+		if (result.Err != nil) != tt.wantErr {
+			t.Errorf("publish result error for %s = %v", tt.topic, result.Err)
+		}
+
+		if got := publishResultCount(t, tt.topic, tt.outcome) - before; got != 1 {
+			t.Errorf("%s %s count increased by %v, want 1", tt.topic, tt.outcome, got)
+		}
+	}
+
+	if results[1].Duration < 20*time.Millisecond {
+		t.Errorf("delayed publish duration = %s, want at least 20ms", results[1].Duration)
+	}
+}
+
+func TestClientPublishWhileDisconnected(t *testing.T) {
+	client := NewClient(config.MQTTConfig{}, nil)
+
+	var result PublishResult
+	client.SetPublishResultHandler(func(r PublishResult) { result = r })
+
+	before := publishResultCount(t, "test/publish/offline", PublishFailed)
+	if err := client.Publish("test/publish/offline", []byte("1"), false); err == nil {
+		t.Fatal("expected error publishing while disconnected")
+	}
+	if result.Outcome != PublishFailed || result.Err == nil {
+		t.Errorf("publish result = %+v, want failed", result)
+	}
+	if got := publishResultCount(t, "test/publish/offline", PublishFailed) - before; got != 1 {
+		t.Errorf("failed count increased by %v, want 1", got)
+	}
+}
@@ -0,0 +1,74 @@
+package mqtt
+
+import (
+	"fmt"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// subscription is the handler of a subscribed pattern and the QoS the broker
+// granted for it
+type subscription struct {
+	handler EventHandler
+	qos     byte
+}
+
+// subscribeFailure is the granted QoS a broker returns for a rejected
+// subscription
+const subscribeFailure = 0x80
+
+// SubscriptionQoS returns the QoS pattern is subscribed at: its own
+// mqtt.topic_qos entry, else the highest QoS of an entry covering it, else
+// mqtt.qos. Shared subscriptions are covered by entries for their topic
+// filter.
+func (c *Client) SubscriptionQoS(pattern string) byte {
+	if qos, ok := c.config.TopicQoS[pattern]; ok {
+		return qos
+	}
+
+	var qos byte
+	found := false
+	filter := SubscriptionFilter(pattern)
+	for configured, configuredQoS := range c.config.TopicQoS {
+		if (!found || configuredQoS > qos) && SubscriptionCovers(SubscriptionFilter(configured), filter) {
+			qos, found = configuredQoS, true
+		}
+	}
+	if !found {
+		return c.config.QoS
+	}
+	return qos
+}
+
+// PublishQoS returns the QoS messages to topic are published at: the highest
+// QoS of the mqtt.topic_qos entries matching it, else mqtt.qos
+func (c *Client) PublishQoS(topic string) byte {
+	var qos byte
+	found := false
+	for pattern, configuredQoS := range c.config.TopicQoS {
+		if (!found || configuredQoS > qos) && TopicMatches(SubscriptionFilter(pattern), topic) {
+			qos, found = configuredQoS, true
+		}
+	}
+	if !found {
+		return c.config.QoS
+	}
+	return qos
+}
+
+// grantedQoS returns the QoS the broker granted for topic, or requested when
+// the token does not report one
+func grantedQoS(token mqtt.Token, topic string, requested byte) (byte, error) {
+	subscribeToken, ok := token.(*mqtt.SubscribeToken)
+	if !ok {
+		return requested, nil
+	}
+	granted, ok := subscribeToken.Result()[topic]
+	if !ok {
+		return requested, nil
+	}
+	if granted == subscribeFailure {
+		return 0, fmt.Errorf("broker rejected subscription to topic %s", topic)
+	}
+	return granted, nil
+}
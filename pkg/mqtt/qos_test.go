@@ -0,0 +1,117 @@
+package mqtt
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/config"
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// qosPahoClient records the QoS each topic is subscribed and published at
+type qosPahoClient struct {
+	paho.Client
+	mutex      sync.Mutex
+	subscribed map[string]byte
+	published  map[string]byte
+}
+
+func (m *qosPahoClient) Publish(topic string, qos byte, retained bool, payload interface{}) paho.Token {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.published[topic] = qos
+	return newMockToken(0, nil)
+}
+
+func (m *qosPahoClient) Subscribe(topic string, qos byte, callback paho.MessageHandler) paho.Token {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.subscribed[topic] = qos
+	return newMockToken(0, nil)
+}
+
+func TestClientSubscribeQoS(t *testing.T) {
+	client := NewClient(config.MQTTConfig{
+		TopicQoS: map[string]byte{
+			"alarms/#":        2,
+			"sensors/+/power": 1,
+		},
+	}, nil)
+	broker := &qosPahoClient{subscribed: make(map[string]byte)}
+	client.state = ConnectionStateConnected
+	client.client = broker
+
+	tests := []struct {
+		pattern string
+		want    byte
+	}{
+		{pattern: "alarms/#", want: 2},
+		{pattern: "sensors/+/power", want: 1},
+		{pattern: "alarms/door/state", want: 2}, // covered by alarms/#
+		{pattern: "sensors/kitchen/power", want: 1},
+		{pattern: "sensors/#", want: 0},
+	}
+
+	handler := func(event Event) error { return nil }
+	for _, tt := range tests {
+		if err := client.Subscribe(tt.pattern, handler); err != nil {
+			t.Fatalf("Subscribe(%s) failed: %v", tt.pattern, err)
+		}
+		if got := broker.subscribed[tt.pattern]; got != tt.want {
+			t.Errorf("%s subscribed at QoS %d, want %d", tt.pattern, got, tt.want)
+		}
+		sub, ok := client.handlers[tt.pattern]
+		if !ok {
+			t.Errorf("no handler for %s", tt.pattern)
+			continue
+		}
+		if sub.qos != tt.want {
+			t.Errorf("%s handler QoS = %d, want %d", tt.pattern, sub.qos, tt.want)
+		}
+	}
+}
+
+func TestClientPublishQoS(t *testing.T) {
+	client := NewClient(config.MQTTConfig{
+		QoS: 1,
+		TopicQoS: map[string]byte{
+			"alarms/#":        2,
+			"lights/+/status": 0,
+		},
+	}, nil)
+	broker := &qosPahoClient{subscribed: make(map[string]byte), published: make(map[string]byte)}
+	client.state = ConnectionStateConnected
+	client.client = broker
+
+	tests := []struct {
+		topic string
+		want  byte
+	}{
+		{topic: "alarms/door/state", want: 2},
+		{topic: "lights/hall/status", want: 0},
+		{topic: "lights/hall/set", want: 1}, // mqtt.qos
+	}
+	for _, tt := range tests {
+		if err := client.Publish(tt.topic, []byte("1"), false); err != nil {
+			t.Fatalf("Publish(%s) failed: %v", tt.topic, err)
+		}
+		if got := broker.published[tt.topic]; got != tt.want {
+			t.Errorf("%s published at QoS %d, want %d", tt.topic, got, tt.want)
+		}
+	}
+
+	if err := client.PublishWithQoS("alarms/door/state", []byte("1"), 0, false); err != nil {
+		t.Fatalf("PublishWithQoS failed: %v", err)
+	}
+	if got := broker.published["alarms/door/state"]; got != 0 {
+		t.Errorf("explicit QoS publish at QoS %d, want 0", got)
+	}
+
+	// Subscriptions no entry covers use mqtt.qos too
+	if err := client.Subscribe("sensors/#", func(event Event) error { return nil }); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if got := broker.subscribed["sensors/#"]; got != 1 {
+		t.Errorf("sensors/# subscribed at QoS %d, want 1", got)
+	}
+}
@@ -0,0 +1,201 @@
+package mqtt
+
+import (
+	"sync"
+	"time"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/config"
+)
+
+// Outbound queue overflow policies
+const (
+	OverflowDropOldest = "drop-oldest"
+	OverflowDropNewest = "drop-newest"
+)
+
+// queuedMessage is a publish waiting for the connection to be restored
+type queuedMessage struct {
+	topic    string
+	payload  []byte
+	qos      byte
+	retain   bool
+	queuedAt time.Time
+}
+
+// outboundQueue buffers publishes made while disconnected, in order. While
+// it is being flushed, new publishes are queued behind it so a topic's
+// values are never reordered.
+type outboundQueue struct {
+	size     int
+	policy   string
+	collapse bool
+
+	mutex    sync.Mutex
+	messages []queuedMessage
+	flushing bool
+
+	// flushMutex runs flushes one at a time, in the order they start
+	flushMutex sync.Mutex
+}
+
+// newOutboundQueue returns nil when the queue is disabled
+func newOutboundQueue(cfg config.OutboundQueueConfig) *outboundQueue {
+	if cfg.Size <= 0 {
+		return nil
+	}
+	policy := cfg.OverflowPolicy
+	if policy != OverflowDropNewest {
+		policy = OverflowDropOldest
+	}
+	return &outboundQueue{size: cfg.Size, policy: policy, collapse: cfg.Collapse}
+}
+
+// push adds a message, returning the message dropped to make room (if any)
+// and whether the message itself was rejected because the queue is full
+func (q *outboundQueue) push(message queuedMessage) (dropped *queuedMessage, rejected bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return q.pushLocked(message)
+}
+
+// pushLocked adds a message; the caller must hold the queue's lock
+func (q *outboundQueue) pushLocked(message queuedMessage) (dropped *queuedMessage, rejected bool) {
+	if q.collapse && q.removeTopic(message.topic) {
+		q.messages = append(q.messages, message)
+		return nil, false
+	}
+	if len(q.messages) >= q.size {
+		if q.policy == OverflowDropNewest {
+			return nil, true
+		}
+		oldest := q.messages[0]
+		q.messages = q.messages[1:]
+		dropped = &oldest
+	}
+	q.messages = append(q.messages, message)
+	return dropped, false
+}
+
+// pushIfPending queues a message unless the client is connected with nothing
+// queued or being flushed, in which case it can be published directly.
+// Deciding and pushing under one lock means a flush cannot finish in between
+// and leave the message queued while connected. flush reports that the
+// message was queued while connected with no flush running, so the caller
+// must start one.
+func (q *outboundQueue) pushIfPending(message queuedMessage, connected bool) (queued, flush bool, dropped *queuedMessage, rejected bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if connected && len(q.messages) == 0 && !q.flushing {
+		return false, false, nil, false
+	}
+	dropped, rejected = q.pushLocked(message)
+	if connected && !q.flushing && len(q.messages) > 0 {
+		q.flushing = true
+		flush = true
+	}
+	return true, flush, dropped, rejected
+}
+
+// removeTopic removes the queued message of topic, reporting whether there
+// was one. Only used when collapsing, so a topic has at most one message.
+func (q *outboundQueue) removeTopic(topic string) bool {
+	for i, queued := range q.messages {
+		if queued.topic == topic {
+			q.messages = append(q.messages[:i], q.messages[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// next removes the oldest message for flushing. The queue stays pending
+// until next reports it is empty, so publishes made meanwhile are queued.
+func (q *outboundQueue) next() (queuedMessage, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if len(q.messages) == 0 {
+		q.flushing = false
+		return queuedMessage{}, false
+	}
+	message := q.messages[0]
+	q.messages = q.messages[1:]
+	q.flushing = true
+	return message, true
+}
+
+// requeue returns a message whose flush failed to the front of the queue and
+// ends the flush. A newer message of the same topic wins when collapsing.
+func (q *outboundQueue) requeue(message queuedMessage) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.flushing = false
+	if q.collapse {
+		for _, queued := range q.messages {
+			if queued.topic == message.topic {
+				return
+			}
+		}
+	}
+	if len(q.messages) >= q.size && q.policy == OverflowDropOldest {
+		return
+	}
+	q.messages = append([]queuedMessage{message}, q.messages...)
+	if len(q.messages) > q.size {
+		q.messages = q.messages[:q.size]
+	}
+}
+
+// pending reports whether messages are queued or being flushed
+func (q *outboundQueue) pending() bool {
+	if q == nil {
+		return false
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return len(q.messages) > 0 || q.flushing
+}
+
+func (q *outboundQueue) len() int {
+	if q == nil {
+		return 0
+	}
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return len(q.messages)
+}
+
+// flushQueue sends the queued messages in order. It stops, keeping the
+// remaining messages, if the connection is lost again.
+func (c *Client) flushQueue() {
+	q := c.queue
+	if q == nil {
+		return
+	}
+	q.flushMutex.Lock()
+	defer q.flushMutex.Unlock()
+
+	sent := 0
+	for {
+		message, ok := q.next()
+		if !ok {
+			break
+		}
+		err := c.publish(message.topic, message.payload, message.qos, message.retain, true)
+		if err == errNotConnected {
+			q.requeue(message)
+			c.logger.Printf("Connection lost while flushing the outbound queue; %d messages remain queued", q.len())
+			return
+		}
+		if err != nil {
+			c.logger.Printf("Failed to send queued message for %s (queued %s ago): %v", message.topic, time.Since(message.queuedAt).Round(time.Millisecond), err)
+			continue
+		}
+		sent++
+	}
+	if sent > 0 {
+		c.logger.Printf("Flushed %d queued messages", sent)
+	}
+}
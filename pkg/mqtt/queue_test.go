@@ -0,0 +1,192 @@
+package mqtt
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/config"
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// recordingPahoClient records published topic=payload pairs
+type recordingPahoClient struct {
+	paho.Client
+	mutex     sync.Mutex
+	published []string
+}
+
+func (m *recordingPahoClient) Publish(topic string, qos byte, retained bool, payload interface{}) paho.Token {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.published = append(m.published, topic+"="+string(payload.([]byte)))
+	return newMockToken(0, nil)
+}
+
+func (m *recordingPahoClient) messages() []string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]string{}, m.published...)
+}
+
+func TestClientOutboundQueue(t *testing.T) {
+	tests := []struct {
+		name     string
+		queue    config.OutboundQueueConfig
+		publish  []string // topic=payload
+		wantErrs int
+		want     []string
+	}{
+		{
+			name:    "flushed in order",
+			queue:   config.OutboundQueueConfig{Size: 10},
+			publish: []string{"lights/a=1", "lights/b=1", "lights/a=2"},
+			want:    []string{"lights/a=1", "lights/b=1", "lights/a=2"},
+		},
+		{
+			name:    "collapsed to latest per topic",
+			queue:   config.OutboundQueueConfig{Size: 10, Collapse: true},
+			publish: []string{"lights/a=1", "lights/b=1", "lights/a=2"},
+			want:    []string{"lights/b=1", "lights/a=2"},
+		},
+		{
+			name:    "drop oldest on overflow",
+			queue:   config.OutboundQueueConfig{Size: 2, OverflowPolicy: OverflowDropOldest},
+			publish: []string{"lights/a=1", "lights/b=1", "lights/c=1"},
+			want:    []string{"lights/b=1", "lights/c=1"},
+		},
+		{
+			name:     "drop newest on overflow",
+			queue:    config.OutboundQueueConfig{Size: 2, OverflowPolicy: OverflowDropNewest},
+			publish:  []string{"lights/a=1", "lights/b=1", "lights/c=1"},
+			wantErrs: 1,
+			want:     []string{"lights/a=1", "lights/b=1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(config.MQTTConfig{OutboundQueue: tt.queue}, nil)
+			broker := &recordingPahoClient{}
+			client.client = broker
+
+			var outcomes []PublishOutcome
+			client.SetPublishResultHandler(func(result PublishResult) {
+				outcomes = append(outcomes, result.Outcome)
+			})
+
+			errs := 0
+			for _, message := range tt.publish {
+				topic, payload, _ := strings.Cut(message, "=")
+				if err := client.Publish(topic, []byte(payload), false); err != nil {
+					errs++
+				}
+			}
+			if errs != tt.wantErrs {
+				t.Errorf("got %d publish errors while disconnected, want %d", errs, tt.wantErrs)
+			}
+			if len(broker.messages()) != 0 {
+				t.Fatalf("published while disconnected: %v", broker.messages())
+			}
+			if got := client.Status().QueuedMessages; got != len(tt.want) {
+				t.Errorf("queued messages = %d, want %d", got, len(tt.want))
+			}
+			if outcomes[0] != PublishQueued {
+				t.Errorf("first publish outcome = %s, want queued", outcomes[0])
+			}
+
+			// Reconnecting flushes the queue; later publishes follow it
+			client.state = ConnectionStateConnected
+			client.flushQueue()
+			if err := client.Publish("lights/z", []byte("9"), false); err != nil {
+				t.Fatalf("Publish after reconnect failed: %v", err)
+			}
+
+			want := append(append([]string{}, tt.want...), "lights/z=9")
+			if got := broker.messages(); !reflect.DeepEqual(got, want) {
+				t.Errorf("published %v, want %v", got, want)
+			}
+			if client.queue.pending() {
+				t.Error("queue still pending after flush")
+			}
+		})
+	}
+}
+
+func TestClientPublishQueuesBehindPendingMessages(t *testing.T) {
+	client := NewClient(config.MQTTConfig{OutboundQueue: config.OutboundQueueConfig{Size: 10}}, nil)
+	broker := &recordingPahoClient{}
+	client.client = broker
+
+	if err := client.Publish("lights/a", []byte("1"), false); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	// Connected but not yet flushed: the newer value must not overtake it,
+	// and queueing it starts the flush
+	client.state = ConnectionStateConnected
+	if err := client.Publish("lights/a", []byte("2"), false); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	// Waits for the flush the publish started
+	client.flushQueue()
+	if got, want := broker.messages(), []string{"lights/a=1", "lights/a=2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("published %v, want %v", got, want)
+	}
+}
+
+func TestOutboundQueuePushIfPending(t *testing.T) {
+	queue := newOutboundQueue(config.OutboundQueueConfig{Size: 10})
+
+	if queued, _, _, _ := queue.pushIfPending(queuedMessage{topic: "lights/a"}, true); queued {
+		t.Fatal("queued a message while connected with nothing pending")
+	}
+	if queued, flush, _, _ := queue.pushIfPending(queuedMessage{topic: "lights/a"}, false); !queued || flush {
+		t.Fatalf("while disconnected: queued = %v, flush = %v; want queued without a flush", queued, flush)
+	}
+	// Connected with messages queued but no flush running: one must start
+	if queued, flush, _, _ := queue.pushIfPending(queuedMessage{topic: "lights/b"}, true); !queued || !flush {
+		t.Fatalf("queued = %v, flush = %v; want queued with a flush", queued, flush)
+	}
+	if queued, flush, _, _ := queue.pushIfPending(queuedMessage{topic: "lights/c"}, true); !queued || flush {
+		t.Fatalf("during a flush: queued = %v, flush = %v; want queued without a flush", queued, flush)
+	}
+
+	// Once the flush drains the queue, publishes go out directly again
+	for {
+		if _, ok := queue.next(); !ok {
+			break
+		}
+	}
+	if queued, _, _, _ := queue.pushIfPending(queuedMessage{topic: "lights/d"}, true); queued {
+		t.Error("queued a message after the flush finished")
+	}
+}
+
+func TestOutboundQueueRequeue(t *testing.T) {
+	queue := newOutboundQueue(config.OutboundQueueConfig{Size: 2, Collapse: true})
+	queue.push(queuedMessage{topic: "lights/a", payload: []byte("1")})
+	queue.push(queuedMessage{topic: "lights/b", payload: []byte("1")})
+
+	message, _ := queue.next()
+	queue.push(queuedMessage{topic: "lights/a", payload: []byte("2")})
+
+	// A newer value of the topic was queued while the old one was in flight
+	queue.requeue(message)
+	if queue.len() != 2 {
+		t.Fatalf("queue length = %d, want 2", queue.len())
+	}
+	var got []string
+	for {
+		message, ok := queue.next()
+		if !ok {
+			break
+		}
+		got = append(got, message.topic+"="+string(message.payload))
+	}
+	if want := []string{"lights/b=1", "lights/a=2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("queued %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,32 @@
+package mqtt
+
+import "strings"
+
+// SharedSubscriptionPrefix starts a shared subscription,
+// $share/{group}/{filter}. The broker delivers each message matching filter
+// to only one subscriber in the group, so several instances can split the
+// load without processing a message twice.
+const SharedSubscriptionPrefix = "$share/"
+
+// ParseSharedSubscription returns the group and topic filter of a shared
+// subscription; ok is false for other subscriptions
+func ParseSharedSubscription(subscription string) (group, filter string, ok bool) {
+	if !strings.HasPrefix(subscription, SharedSubscriptionPrefix) {
+		return "", "", false
+	}
+	group, filter, found := strings.Cut(strings.TrimPrefix(subscription, SharedSubscriptionPrefix), "/")
+	if !found {
+		return "", "", false
+	}
+	return group, filter, true
+}
+
+// SubscriptionFilter returns the topic filter of a subscription, without the
+// $share/{group}/ prefix of a shared subscription. Messages are delivered on
+// topics matching the filter.
+func SubscriptionFilter(subscription string) string {
+	if _, filter, ok := ParseSharedSubscription(subscription); ok {
+		return filter
+	}
+	return subscription
+}
@@ -0,0 +1,84 @@
+package mqtt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/config"
+)
+
+func TestParseSharedSubscription(t *testing.T) {
+	tests := []struct {
+		subscription string
+		group        string
+		filter       string
+		ok           bool
+	}{
+		{subscription: "$share/automation/sensors/#", group: "automation", filter: "sensors/#", ok: true},
+		{subscription: "$share/g/+/temp", group: "g", filter: "+/temp", ok: true},
+		{subscription: "$share/automation", ok: false},
+		{subscription: "sensors/#", ok: false},
+		{subscription: "$SYS/broker/uptime", ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.subscription, func(t *testing.T) {
+			group, filter, ok := ParseSharedSubscription(tt.subscription)
+			if group != tt.group || filter != tt.filter || ok != tt.ok {
+				t.Errorf("ParseSharedSubscription() = %q, %q, %v, want %q, %q, %v", group, filter, ok, tt.group, tt.filter, tt.ok)
+			}
+
+			want := tt.subscription
+			if tt.ok {
+				want = tt.filter
+			}
+			if got := SubscriptionFilter(tt.subscription); got != want {
+				t.Errorf("SubscriptionFilter() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestClientSharedSubscription(t *testing.T) {
+	client := NewClient(config.MQTTConfig{
+		Topics:   []string{"$share/automation/sensors/#"},
+		TopicQoS: map[string]byte{"sensors/#": 1},
+	}, nil)
+	broker := &qosPahoClient{subscribed: make(map[string]byte)}
+	client.state = ConnectionStateConnected
+	client.client = broker
+
+	received := make(chan Event, 1)
+	handler := func(event Event) error {
+		received <- event
+		return nil
+	}
+	if err := client.Subscribe("$share/automation/sensors/#", handler); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	// The broker is asked for the shared subscription, at the QoS of its filter
+	qos, ok := broker.subscribed["$share/automation/sensors/#"]
+	if !ok || len(broker.subscribed) != 1 {
+		t.Fatalf("broker subscriptions = %v", broker.subscribed)
+	}
+	if qos != 1 {
+		t.Errorf("subscribed at QoS %d, want 1", qos)
+	}
+
+	// Messages arrive on the bare topic and reach the shared subscription's handler
+	client.onMessage(nil, &fakeMessage{topic: "sensors/kitchen/temp", payload: []byte("21.5")})
+	select {
+	case event := <-received:
+		if event.Topic != "sensors/kitchen/temp" {
+			t.Errorf("event topic = %q, want sensors/kitchen/temp", event.Topic)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("handler was not called")
+	}
+
+	// Patterns covered by the shared subscription's filter need no subscription of their own
+	if client.AddSubscription("sensors/kitchen/#") {
+		t.Error("AddSubscription subscribed to a pattern covered by the shared subscription")
+	}
+}
@@ -0,0 +1,55 @@
+package mqtt
+
+import (
+	"time"
+)
+
+// Status is a snapshot of the client's connection and traffic counters
+type Status struct {
+	Broker            string     `json:"broker"`
+	State             string     `json:"state"`
+	Connected         bool       `json:"connected"`
+	ReconnectAttempts int        `json:"reconnect_attempts"`
+	LastConnectedAt   *time.Time `json:"last_connected_at,omitempty"`
+	MessagesIn        uint64     `json:"messages_in"`
+	MessagesOut       uint64     `json:"messages_out"`
+	QueuedMessages    int        `json:"queued_messages"`
+}
+
+func (s ConnectionState) String() string {
+	switch s {
+	case ConnectionStateClosed:
+		return "closed"
+	case ConnectionStateConnecting:
+		return "connecting"
+	case ConnectionStateConnected:
+		return "connected"
+	case ConnectionStateReconnecting:
+		return "reconnecting"
+	default:
+		return "unknown"
+	}
+}
+
+// Status returns the current connection state, the reconnect attempts made
+// since the connection was lost and the number of messages received and
+// successfully published
+func (c *Client) Status() Status {
+	c.stateMutex.RLock()
+	defer c.stateMutex.RUnlock()
+
+	status := Status{
+		Broker:            c.config.Broker,
+		State:             c.state.String(),
+		Connected:         c.state == ConnectionStateConnected,
+		ReconnectAttempts: c.reconnectAttempts,
+		MessagesIn:        c.messagesIn.Load(),
+		MessagesOut:       c.messagesOut.Load(),
+		QueuedMessages:    c.queue.len(),
+	}
+	if !c.lastConnectedAt.IsZero() {
+		lastConnected := c.lastConnectedAt
+		status.LastConnectedAt = &lastConnected
+	}
+	return status
+}
@@ -0,0 +1,98 @@
+package mqtt
+
+import (
+	"testing"
+	"time"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/config"
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// mockMessage is an inbound message on topic
+type mockMessage struct {
+	paho.Message
+	topic string
+}
+
+func (m *mockMessage) Topic() string   { return m.topic }
+func (m *mockMessage) Payload() []byte { return []byte("1") }
+func (m *mockMessage) Qos() byte       { return 0 }
+func (m *mockMessage) Retained() bool  { return false }
+
+func TestClientStatus(t *testing.T) {
+	connectedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		state         ConnectionState
+		attempts      int
+		lastConnected time.Time
+		wantState     string
+		wantConnected bool
+	}{
+		{name: "never connected", state: ConnectionStateClosed, wantState: "closed"},
+		{name: "connecting", state: ConnectionStateConnecting, wantState: "connecting"},
+		{name: "connected", state: ConnectionStateConnected, lastConnected: connectedAt, wantState: "connected", wantConnected: true},
+		{name: "reconnecting", state: ConnectionStateReconnecting, attempts: 3, lastConnected: connectedAt, wantState: "reconnecting"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient(config.MQTTConfig{Broker: "tcp://broker:1883"}, nil)
+			client.state = tt.state
+			client.reconnectAttempts = tt.attempts
+			client.lastConnectedAt = tt.lastConnected
+
+			status := client.Status()
+			if status.Broker != "tcp://broker:1883" {
+				t.Errorf("Broker = %q", status.Broker)
+			}
+			if status.State != tt.wantState || status.Connected != tt.wantConnected {
+				t.Errorf("State = %q connected %v, want %q connected %v", status.State, status.Connected, tt.wantState, tt.wantConnected)
+			}
+			if status.ReconnectAttempts != tt.attempts {
+				t.Errorf("ReconnectAttempts = %d, want %d", status.ReconnectAttempts, tt.attempts)
+			}
+			if tt.lastConnected.IsZero() {
+				if status.LastConnectedAt != nil {
+					t.Errorf("LastConnectedAt = %v, want nil", status.LastConnectedAt)
+				}
+			} else if status.LastConnectedAt == nil || !status.LastConnectedAt.Equal(tt.lastConnected) {
+				t.Errorf("LastConnectedAt = %v, want %v", status.LastConnectedAt, tt.lastConnected)
+			}
+		})
+	}
+}
+
+func TestClientStatusMessageCounts(t *testing.T) {
+	client := NewClient(config.MQTTConfig{PublishTimeout: "50ms"}, nil)
+	defer client.dispatcher.stop()
+
+	// Publishes while disconnected are not counted
+	client.Publish("test/status/out", []byte("1"), false)
+
+	client.state = ConnectionStateConnected
+	client.client = &mockPahoClient{
+		tokenFunc: func(topic string) paho.Token {
+			if topic == "test/status/stuck" {
+				return newMockToken(time.Hour, nil)
+			}
+			return newMockToken(0, nil)
+		},
+	}
+	client.Publish("test/status/out", []byte("1"), false)
+	client.Publish("test/status/out", []byte("2"), false)
+	client.Publish("test/status/stuck", []byte("3"), false)
+
+	for i := 0; i < 3; i++ {
+		client.onMessage(nil, &mockMessage{topic: "test/status/in"})
+	}
+
+	status := client.Status()
+	if status.MessagesIn != 3 {
+		t.Errorf("MessagesIn = %d, want 3", status.MessagesIn)
+	}
+	if status.MessagesOut != 2 {
+		t.Errorf("MessagesOut = %d, want 2", status.MessagesOut)
+	}
+}
@@ -8,10 +8,39 @@ type Event struct {
 	Topic     string
 	Payload   []byte
 	Timestamp time.Time
+	// QoS is the QoS the message was delivered at
+	QoS byte
+	// Retained is set for retained messages the broker replays on subscribe
+	Retained bool
 }
 
 type EventHandler func(event Event) error
 
+// PublishOutcome is the delivery result of a publish
+type PublishOutcome string
+
+const (
+	PublishDelivered PublishOutcome = "delivered"
+	PublishFailed    PublishOutcome = "failed"
+	PublishTimedOut  PublishOutcome = "timeout"
+	// PublishQueued means the message was queued until the connection is
+	// restored; PublishDropped means it was later dropped from a full queue
+	PublishQueued  PublishOutcome = "queued"
+	PublishDropped PublishOutcome = "dropped"
+)
+
+// PublishResult is the delivery report for a single publish
+type PublishResult struct {
+	Topic     string
+	QoS       byte
+	Retained  bool
+	Bytes     int
+	Outcome   PublishOutcome
+	Err       error
+	Duration  time.Duration
+	Timestamp time.Time
+}
+
 type ConnectionState int
 
 const (
@@ -0,0 +1,50 @@
+package mqtt
+
+import (
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// setWill registers the configured Last Will and Testament, which the broker
+// publishes if the connection is lost without a clean disconnect
+func (c *Client) setWill(opts *mqtt.ClientOptions) {
+	if c.config.WillTopic == "" {
+		return
+	}
+	opts.SetWill(c.config.WillTopic, c.config.WillPayload, c.config.WillQoS, c.config.WillRetain)
+}
+
+// publishWill publishes the will payload before a clean disconnect, which the
+// broker does not send the will for. Must be called with the state lock held
+// while connected.
+func (c *Client) publishWill() {
+	if c.config.WillTopic == "" {
+		return
+	}
+
+	token := c.client.Publish(c.config.WillTopic, c.config.WillQoS, c.config.WillRetain, []byte(c.config.WillPayload))
+	if !token.WaitTimeout(c.publishTimeout) {
+		c.logger.Printf("Timed out publishing the will to %s before disconnecting", c.config.WillTopic)
+		return
+	}
+	if err := token.Error(); err != nil {
+		c.logger.Printf("Failed to publish the will to %s before disconnecting: %v", c.config.WillTopic, err)
+	}
+}
+
+// publishBirth announces the server is online after every connect. Paho may
+// run the connect handler before Connect has updated the connection state, so
+// it publishes on the paho client directly.
+func (c *Client) publishBirth(client mqtt.Client) {
+	if c.config.BirthTopic == "" {
+		return
+	}
+
+	token := client.Publish(c.config.BirthTopic, c.config.BirthQoS, c.config.BirthRetain, []byte(c.config.BirthPayload))
+	if !token.WaitTimeout(c.publishTimeout) {
+		c.logger.Printf("Timed out publishing the birth message to %s", c.config.BirthTopic)
+		return
+	}
+	if err := token.Error(); err != nil {
+		c.logger.Printf("Failed to publish the birth message to %s: %v", c.config.BirthTopic, err)
+	}
+}
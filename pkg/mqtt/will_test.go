@@ -0,0 +1,93 @@
+package mqtt
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/config"
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// disconnectingPahoClient records publishes and disconnects in call order
+type disconnectingPahoClient struct {
+	paho.Client
+	calls []string
+}
+
+func (m *disconnectingPahoClient) Publish(topic string, qos byte, retained bool, payload interface{}) paho.Token {
+	m.calls = append(m.calls, "publish "+topic+" "+string(payload.([]byte)))
+	if qos != 1 || !retained {
+		m.calls = append(m.calls, "unexpected qos or retain")
+	}
+	return newMockToken(0, nil)
+}
+
+func (m *disconnectingPahoClient) Disconnect(quiesce uint) {
+	m.calls = append(m.calls, "disconnect")
+}
+
+func TestClientWill(t *testing.T) {
+	cfg := config.MQTTConfig{
+		WillTopic:   "automation/status",
+		WillPayload: `{"online":false}`,
+		WillQoS:     1,
+		WillRetain:  true,
+	}
+
+	client := NewClient(cfg, nil)
+	opts := paho.NewClientOptions()
+	client.setWill(opts)
+	if !opts.WillEnabled || opts.WillTopic != cfg.WillTopic || string(opts.WillPayload) != cfg.WillPayload || opts.WillQos != 1 || !opts.WillRetained {
+		t.Errorf("will options = enabled %v, topic %q, payload %q, qos %d, retained %v", opts.WillEnabled, opts.WillTopic, opts.WillPayload, opts.WillQos, opts.WillRetained)
+	}
+
+	// A clean disconnect publishes the will itself, before disconnecting
+	broker := &disconnectingPahoClient{}
+	client.client = broker
+	client.state = ConnectionStateConnected
+	client.Disconnect()
+	want := []string{`publish automation/status {"online":false}`, "disconnect"}
+	if !reflect.DeepEqual(broker.calls, want) {
+		t.Errorf("calls = %v, want %v", broker.calls, want)
+	}
+}
+
+func TestClientWithoutWill(t *testing.T) {
+	client := NewClient(config.MQTTConfig{}, nil)
+	opts := paho.NewClientOptions()
+	client.setWill(opts)
+	if opts.WillEnabled {
+		t.Error("will enabled without a will topic")
+	}
+
+	broker := &disconnectingPahoClient{}
+	client.client = broker
+	client.state = ConnectionStateConnected
+	client.Disconnect()
+	if want := []string{"disconnect"}; !reflect.DeepEqual(broker.calls, want) {
+		t.Errorf("calls = %v, want %v", broker.calls, want)
+	}
+}
+
+func TestClientBirth(t *testing.T) {
+	client := NewClient(config.MQTTConfig{
+		BirthTopic:   "automation/status",
+		BirthPayload: `{"online":true,"client_id":"automation"}`,
+		BirthQoS:     1,
+		BirthRetain:  true,
+	}, nil)
+	broker := &disconnectingPahoClient{}
+	client.onConnect(broker)
+	want := []string{`publish automation/status {"online":true,"client_id":"automation"}`}
+	if !reflect.DeepEqual(broker.calls, want) {
+		t.Errorf("calls = %v, want %v", broker.calls, want)
+	}
+
+	// Unset, connecting publishes nothing
+	client = NewClient(config.MQTTConfig{}, nil)
+	broker = &disconnectingPahoClient{}
+	client.onConnect(broker)
+	if len(broker.calls) != 0 {
+		t.Errorf("calls = %v, want none", broker.calls)
+	}
+}
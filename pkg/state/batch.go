@@ -0,0 +1,109 @@
+package state
+
+import (
+	"time"
+)
+
+// startWriteBatching coalesces topic state writes and flushes the latest value
+// per topic every interval
+func (m *Manager) startWriteBatching(interval time.Duration) {
+	m.batchMutex.Lock()
+	defer m.batchMutex.Unlock()
+
+	m.pendingStates = make(map[string]interface{})
+	m.batchStop = make(chan struct{})
+	m.batchDone = make(chan struct{})
+
+	go func(stop, done chan struct{}) {
+		defer close(done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := m.FlushStateWrites(); err != nil {
+					m.logger.Printf("Failed to flush batched state writes: %v", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}(m.batchStop, m.batchDone)
+
+	m.logger.Printf("Batching topic state writes every %s", interval)
+}
+
+// queueTopicState holds a state write until the next flush, replacing any
+// pending value for the same key. It reports false when batching is off.
+func (m *Manager) queueTopicState(key string, value interface{}) bool {
+	m.batchMutex.Lock()
+	defer m.batchMutex.Unlock()
+
+	if m.pendingStates == nil {
+		return false
+	}
+	m.pendingStates[key] = value
+	return true
+}
+
+// pendingTopicState returns a queued value that has not been written yet
+func (m *Manager) pendingTopicState(key string) (interface{}, bool) {
+	m.batchMutex.Lock()
+	defer m.batchMutex.Unlock()
+
+	value, found := m.pendingStates[key]
+	return value, found
+}
+
+// takePendingStates removes and returns the queued writes. When disable is
+// set, later writes bypass the batch.
+func (m *Manager) takePendingStates(disable bool) map[string]interface{} {
+	m.batchMutex.Lock()
+	defer m.batchMutex.Unlock()
+
+	pending := m.pendingStates
+	switch {
+	case disable:
+		m.pendingStates = nil
+	case len(pending) > 0:
+		m.pendingStates = make(map[string]interface{})
+	}
+	return pending
+}
+
+// FlushStateWrites writes all queued topic states now
+func (m *Manager) FlushStateWrites() error {
+	return m.writePendingStates(m.takePendingStates(false))
+}
+
+func (m *Manager) writePendingStates(pending map[string]interface{}) error {
+	// Serialize flushes so an older value can't overwrite a newer one
+	m.flushMutex.Lock()
+	defer m.flushMutex.Unlock()
+
+	var firstErr error
+	for key, value := range pending {
+		if err := m.writeTopicState(key, value); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// Drain stops write batching and writes any queued topic states. Later
+// writes go straight to the database.
+func (m *Manager) Drain() error {
+	m.batchMutex.Lock()
+	stop, done := m.batchStop, m.batchDone
+	m.batchStop, m.batchDone = nil, nil
+	m.batchMutex.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+
+	return m.writePendingStates(m.takePendingStates(true))
+}
@@ -0,0 +1,166 @@
+package state
+
+import (
+	"log"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingDatabase counts state writes made to the wrapped database
+type countingDatabase struct {
+	Database
+
+	mutex      sync.Mutex
+	saves      map[string]int
+	lastValues map[string]int
+}
+
+func (c *countingDatabase) SaveState(key string, value interface{}) error {
+	c.mutex.Lock()
+	c.saves[key]++
+	c.mutex.Unlock()
+	return c.Database.SaveState(key, value)
+}
+
+func (c *countingDatabase) UpdateTopicLastValue(topicName string, value interface{}) error {
+	c.mutex.Lock()
+	c.lastValues[topicName]++
+	c.mutex.Unlock()
+	return c.Database.UpdateTopicLastValue(topicName, value)
+}
+
+func newCountingManager(t *testing.T) (*Manager, *countingDatabase) {
+	t.Helper()
+
+	db := &countingDatabase{
+		Database:   setupTestSQLite(t),
+		saves:      make(map[string]int),
+		lastValues: make(map[string]int),
+	}
+	return &Manager{db: db, logger: log.New(os.Stderr, "", 0)}, db
+}
+
+func TestManager_BatchedStateWrites(t *testing.T) {
+	manager, db := newCountingManager(t)
+	manager.startWriteBatching(time.Hour)
+
+	for i := 1; i <= 10; i++ {
+		if err := manager.SaveTopicState("topic:sensors/temp", float64(i)); err != nil {
+			t.Fatalf("SaveTopicState failed: %v", err)
+		}
+	}
+	if err := manager.SaveTopicState("topic:sensors/humidity", 40.0); err != nil {
+		t.Fatalf("SaveTopicState failed: %v", err)
+	}
+
+	if db.saves["topic:sensors/temp"] != 0 {
+		t.Fatalf("state written before flush: %d writes", db.saves["topic:sensors/temp"])
+	}
+
+	// Queued values are visible before they are written
+	if value, err := manager.LoadTopicState("sensors/temp"); err != nil || value != 10.0 {
+		t.Errorf("LoadTopicState() = %v, %v; want 10", value, err)
+	}
+
+	if err := manager.Drain(); err != nil {
+		t.Fatalf("Drain failed: %v", err)
+	}
+
+	if got := db.saves["topic:sensors/temp"]; got != 1 {
+		t.Errorf("got %d state writes for sensors/temp, want 1", got)
+	}
+	if got := db.lastValues["sensors/temp"]; got != 1 {
+		t.Errorf("got %d last value writes for sensors/temp, want 1", got)
+	}
+	if got := db.saves["topic:sensors/humidity"]; got != 1 {
+		t.Errorf("got %d state writes for sensors/humidity, want 1", got)
+	}
+
+	value, err := db.Database.LoadState("topic:sensors/temp")
+	if err != nil || value != 10.0 {
+		t.Errorf("stored value = %v, %v; want 10", value, err)
+	}
+
+	// After draining, writes go straight to the database
+	if err := manager.SaveTopicState("topic:sensors/temp", 11.0); err != nil {
+		t.Fatalf("SaveTopicState failed: %v", err)
+	}
+	if got := db.saves["topic:sensors/temp"]; got != 2 {
+		t.Errorf("got %d state writes after drain, want 2", got)
+	}
+}
+
+func TestManager_BatchedStateWritesFlushPeriodically(t *testing.T) {
+	manager, db := newCountingManager(t)
+	manager.startWriteBatching(20 * time.Millisecond)
+	defer manager.Drain()
+
+	for i := 1; i <= 5; i++ {
+		if err := manager.SaveTopicState("topic:sensors/temp", float64(i)); err != nil {
+			t.Fatalf("SaveTopicState failed: %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		db.mutex.Lock()
+		saves := db.saves["topic:sensors/temp"]
+		db.mutex.Unlock()
+		if saves > 0 {
+			if saves != 1 {
+				t.Errorf("got %d state writes, want 1", saves)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("batched state was not flushed")
+}
+
+func TestManager_UnbatchedStateWrites(t *testing.T) {
+	manager, db := newCountingManager(t)
+
+	for i := 1; i <= 3; i++ {
+		if err := manager.SaveTopicState("topic:sensors/temp", float64(i)); err != nil {
+			t.Fatalf("SaveTopicState failed: %v", err)
+		}
+	}
+	if got := db.saves["topic:sensors/temp"]; got != 3 {
+		t.Errorf("got %d state writes, want 3", got)
+	}
+}
+
+func TestManager_BatchedStateWritesRecordHistory(t *testing.T) {
+	manager, _ := newCountingManager(t)
+	manager.historyEnabled = true
+	manager.startWriteBatching(time.Hour)
+
+	from := time.Now().Add(-time.Minute)
+	for _, value := range []float64{1, 2, 2, 3, 1} {
+		if err := manager.SaveTopicState("external:sensors/temp", value); err != nil {
+			t.Fatalf("SaveTopicState failed: %v", err)
+		}
+	}
+	if err := manager.Drain(); err != nil {
+		t.Fatalf("Drain failed: %v", err)
+	}
+
+	entries, err := manager.LoadTopicHistory("sensors/temp", from, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("LoadTopicHistory failed: %v", err)
+	}
+	var got []interface{}
+	for _, entry := range entries {
+		got = append(got, entry.Value)
+	}
+
+	// Every change is kept though only the last value is written; the
+	// repeated value is not
+	want := []interface{}{1.0, 2.0, 3.0, 1.0}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("history = %v, want %v", got, want)
+	}
+}
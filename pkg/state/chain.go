@@ -0,0 +1,182 @@
+package state
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/topics"
+)
+
+// chainRecord is the part of an execution log covered by its chain hash
+type chainRecord struct {
+	Previous     string      `json:"previous"`
+	TopicName    string      `json:"topic"`
+	StrategyID   string      `json:"strategy_id"`
+	TriggerTopic string      `json:"trigger_topic"`
+	Inputs       interface{} `json:"inputs"`
+	Outputs      interface{} `json:"outputs"`
+	Error        string      `json:"error"`
+	ExecutedAt   int64       `json:"executed_at"` // Unix microseconds
+}
+
+// ChainHash returns the audit hash of an execution log chained to the
+// previous log's hash: the hex SHA-256 of the execution's canonical JSON.
+// Inputs and outputs are hashed as stored, after a JSON round trip, so the
+// hash can be recomputed from the execution log.
+func ChainHash(previous string, log ExecutionLog) (string, error) {
+	inputs, err := roundTripJSON(log.InputValues)
+	if err != nil {
+		return "", fmt.Errorf("failed to normalize input values: %w", err)
+	}
+	outputs, err := roundTripJSON(log.OutputValues)
+	if err != nil {
+		return "", fmt.Errorf("failed to normalize output values: %w", err)
+	}
+
+	data, err := topics.CanonicalJSON(chainRecord{
+		Previous:     previous,
+		TopicName:    log.TopicName,
+		StrategyID:   log.StrategyID,
+		TriggerTopic: log.TriggerTopic,
+		Inputs:       inputs,
+		Outputs:      outputs,
+		Error:        log.ErrorMessage,
+		ExecutedAt:   log.ExecutedAt.UnixMicro(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode execution for hashing: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func roundTripJSON(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+// nullString stores empty strings as NULL
+func nullString(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+// saveChainedExecutionLog hashes log together with the topic's previous
+// chain hash and saves it. Chained saves are serialized so the chain follows
+// insertion order.
+func (m *Manager) saveChainedExecutionLog(log ExecutionLog) error {
+	m.chainMutex.Lock()
+	defer m.chainMutex.Unlock()
+
+	if m.lastChainHashes == nil {
+		m.lastChainHashes = make(map[string]string)
+	}
+	previous, ok := m.lastChainHashes[log.TopicName]
+	if !ok {
+		err := m.db.EachExecutionLogByID(log.TopicName, func(latest ExecutionLog) error {
+			previous = latest.ChainHash
+			return errFoundExecution
+		})
+		if err != nil && !errors.Is(err, errFoundExecution) {
+			return fmt.Errorf("failed to load previous chain hash: %w", err)
+		}
+	}
+
+	// Timestamps are stored with microsecond precision
+	log.ExecutedAt = log.ExecutedAt.UTC().Truncate(time.Microsecond)
+
+	hash, err := ChainHash(previous, log)
+	if err != nil {
+		return err
+	}
+	log.ChainHash = hash
+
+	if err := m.SaveExecutionLog(log); err != nil {
+		return err
+	}
+	m.lastChainHashes[log.TopicName] = hash
+	return nil
+}
+
+// ChainVerification is the result of checking a topic's audit hash chain
+type ChainVerification struct {
+	Valid bool `json:"valid"`
+	// Verified is how many chained execution logs were checked
+	Verified int `json:"verified"`
+	// BrokenAt is the ID of the newest execution log whose hash does not
+	// match its contents and the previous log's hash
+	BrokenAt int `json:"broken_at,omitempty"`
+}
+
+// errChainChecked stops streaming execution logs once verification is done
+var errChainChecked = errors.New("chain checked")
+
+// VerifyExecutionChain recomputes the chain hashes of a topic's execution
+// logs, newest first, back to the first log recorded with the chain enabled.
+// It always reads the primary database.
+func (m *Manager) VerifyExecutionChain(topicName string) (ChainVerification, error) {
+	result := ChainVerification{Valid: true}
+
+	// verify checks the newer log against the hash of the log before it
+	var newer *ExecutionLog
+	verify := func(previous string) error {
+		hash, err := ChainHash(previous, *newer)
+		if err != nil {
+			return err
+		}
+		result.Verified++
+		if hash != newer.ChainHash {
+			result.Valid = false
+			result.BrokenAt = newer.ID
+			return errChainChecked
+		}
+		return nil
+	}
+
+	err := m.db.EachExecutionLogByID(topicName, func(log ExecutionLog) error {
+		if newer != nil {
+			if err := verify(log.ChainHash); err != nil {
+				return err
+			}
+		}
+		if log.ChainHash == "" {
+			// Recorded before the chain was enabled
+			return errChainChecked
+		}
+		newer = &log
+		return nil
+	})
+	if errors.Is(err, errChainChecked) {
+		return result, nil
+	}
+	if err != nil {
+		return ChainVerification{}, fmt.Errorf("failed to verify execution chain: %w", err)
+	}
+
+	// The oldest chained log starts the chain
+	if newer != nil {
+		if err := verify(""); err != nil && !errors.Is(err, errChainChecked) {
+			return ChainVerification{}, fmt.Errorf("failed to verify execution chain: %w", err)
+		}
+	}
+	return result, nil
+}
+
+// AuditHashChainEnabled reports whether execution logs are hash chained
+func (m *Manager) AuditHashChainEnabled() bool {
+	return m.hashChainEnabled
+}
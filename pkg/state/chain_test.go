@@ -0,0 +1,112 @@
+package state
+
+import (
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/strategy"
+	"github.com/denwilliams/go-mqtt-automation/pkg/topics"
+)
+
+func TestManager_ExecutionHashChain(t *testing.T) {
+	db := setupTestSQLite(t)
+	manager := &Manager{db: db, logger: log.New(os.Stderr, "", 0), hashChainEnabled: true}
+
+	if err := db.SaveStrategy(&strategy.Strategy{ID: "thermostat", Name: "Thermostat", Language: "javascript", CreatedAt: time.Now(), UpdatedAt: time.Now()}); err != nil {
+		t.Fatalf("SaveStrategy failed: %v", err)
+	}
+	if err := db.SaveTopic(topics.InternalTopicConfig{
+		BaseTopicConfig: topics.BaseTopicConfig{Name: "house/heating", Type: topics.TopicTypeInternal, CreatedAt: time.Now()},
+		StrategyID:      "thermostat",
+	}); err != nil {
+		t.Fatalf("SaveTopic failed: %v", err)
+	}
+
+	// A log recorded before the chain was enabled is not verified
+	if err := db.SaveExecutionLog(ExecutionLog{TopicName: "house/heating", StrategyID: "thermostat", ExecutedAt: time.Now()}); err != nil {
+		t.Fatalf("SaveExecutionLog failed: %v", err)
+	}
+
+	start := time.Date(2024, 1, 1, 10, 0, 0, 123456789, time.Local)
+	record := func(m *Manager, minutes int, temp float64) {
+		t.Helper()
+		err := m.RecordExecution(topics.ExecutionRecord{
+			TopicName:    "house/heating",
+			StrategyID:   "thermostat",
+			TriggerTopic: "sensors/temp",
+			Inputs:       map[string]interface{}{"temp": temp, "mode": "auto"},
+			Outputs:      []strategy.EmitEvent{{Topic: "", Value: temp > 20}},
+			ExecutedAt:   start.Add(time.Duration(minutes) * time.Minute),
+		})
+		if err != nil {
+			t.Fatalf("RecordExecution failed: %v", err)
+		}
+	}
+	verify := func(m *Manager) ChainVerification {
+		t.Helper()
+		result, err := m.VerifyExecutionChain("house/heating")
+		if err != nil {
+			t.Fatalf("VerifyExecutionChain failed: %v", err)
+		}
+		return result
+	}
+
+	record(manager, 1, 19.5)
+	record(manager, 2, 21)
+	// A new manager continues the chain from the stored hash
+	restarted := &Manager{db: db, logger: manager.logger, hashChainEnabled: true}
+	record(restarted, 3, 22.25)
+
+	if got := verify(restarted); !got.Valid || got.Verified != 3 {
+		t.Fatalf("intact chain verification = %+v, want valid with 3 verified", got)
+	}
+
+	logs, err := manager.LoadExecutionLogs("house/heating", 0)
+	if err != nil {
+		t.Fatalf("LoadExecutionLogs failed: %v", err)
+	}
+	var tampered ExecutionLog
+	for _, l := range logs {
+		if l.InputValues["temp"] == 21.0 {
+			tampered = l
+		}
+	}
+	if tampered.ChainHash == "" {
+		t.Fatalf("no chained log with temp 21 in %+v", logs)
+	}
+
+	if _, err := db.db.Exec(`UPDATE execution_log SET input_values = ? WHERE id = ?`, `{"mode":"auto","temp":25}`, tampered.ID); err != nil {
+		t.Fatalf("failed to tamper with execution log: %v", err)
+	}
+	if got := verify(restarted); got.Valid || got.BrokenAt != tampered.ID {
+		t.Errorf("tampered chain verification = %+v, want broken at %d", got, tampered.ID)
+	}
+}
+
+func TestChainHash(t *testing.T) {
+	log := ExecutionLog{
+		TopicName:    "house/heating",
+		StrategyID:   "thermostat",
+		InputValues:  map[string]interface{}{"temp": 21},
+		OutputValues: []strategy.EmitEvent{{Topic: "", Value: true}},
+		ExecutedAt:   time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+	}
+	first, err := ChainHash("", log)
+	if err != nil {
+		t.Fatalf("ChainHash failed: %v", err)
+	}
+
+	// Hashes match the stored (JSON round-tripped) form of the values
+	stored := log
+	stored.InputValues = map[string]interface{}{"temp": 21.0}
+	stored.OutputValues = []interface{}{map[string]interface{}{"topic": "", "value": true}}
+	if again, _ := ChainHash("", stored); again != first {
+		t.Errorf("stored form hash = %s, want %s", again, first)
+	}
+
+	if chained, _ := ChainHash(first, log); chained == first {
+		t.Error("hash does not depend on the previous hash")
+	}
+}
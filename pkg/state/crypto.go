@@ -0,0 +1,97 @@
+package state
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// encryptedPrefix marks an encrypted parameters column. Values without it are
+// plaintext JSON written before encryption was enabled.
+const encryptedPrefix = "enc:v1:"
+
+// parameterCipher encrypts parameter columns with AES-256-GCM. A nil cipher
+// stores parameters as plaintext.
+type parameterCipher struct {
+	aead cipher.AEAD
+}
+
+// newParameterCipher derives an AES-256 key from the configured secret
+func newParameterCipher(secret string) (*parameterCipher, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("encryption key is empty")
+	}
+
+	key := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return &parameterCipher{aead: aead}, nil
+}
+
+// seal returns the column value for plaintext, encrypting it when a key is set
+func (c *parameterCipher) seal(plaintext []byte) (string, error) {
+	if c == nil {
+		return string(plaintext), nil
+	}
+
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := c.aead.Seal(nonce, nonce, plaintext, nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// open returns the plaintext of a column value, decrypting it if it carries
+// the encrypted prefix
+func (c *parameterCipher) open(stored string) ([]byte, error) {
+	if !strings.HasPrefix(stored, encryptedPrefix) {
+		return []byte(stored), nil
+	}
+	if c == nil {
+		return nil, fmt.Errorf("parameters are encrypted but no encryption key is configured")
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(stored, encryptedPrefix))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode encrypted parameters: %w", err)
+	}
+	nonceSize := c.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return nil, fmt.Errorf("encrypted parameters are too short")
+	}
+	plaintext, err := c.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt parameters (wrong encryption key?): %w", err)
+	}
+	return plaintext, nil
+}
+
+// marshalParameters encodes parameters as JSON for a parameters column
+func (c *parameterCipher) marshalParameters(parameters map[string]interface{}) (string, error) {
+	data, err := json.Marshal(parameters)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal parameters: %w", err)
+	}
+	return c.seal(data)
+}
+
+// unmarshalParameters decodes a parameters column into target
+func (c *parameterCipher) unmarshalParameters(stored string, target *map[string]interface{}) error {
+	data, err := c.open(stored)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
@@ -0,0 +1,92 @@
+package state
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// OutputDiff compares an execution's outputs with the topic's previous
+// execution. Keys are output topics; a key emitted by only one of the two
+// executions counts as changed.
+type OutputDiff struct {
+	Changed   []string `json:"changed"`
+	Unchanged []string `json:"unchanged"`
+}
+
+// DiffOutputs compares two executions' output values, which may be emit
+// events or their JSON-decoded form
+func DiffOutputs(previous, current interface{}) (*OutputDiff, error) {
+	before, err := outputsByTopic(previous)
+	if err != nil {
+		return nil, err
+	}
+	after, err := outputsByTopic(current)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &OutputDiff{Changed: []string{}, Unchanged: []string{}}
+	for key, value := range after {
+		if previousValue, ok := before[key]; ok && reflect.DeepEqual(previousValue, value) {
+			diff.Unchanged = append(diff.Unchanged, key)
+		} else {
+			diff.Changed = append(diff.Changed, key)
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			diff.Changed = append(diff.Changed, key)
+		}
+	}
+	sort.Strings(diff.Changed)
+	sort.Strings(diff.Unchanged)
+	return diff, nil
+}
+
+// outputsByTopic keys output values by topic. Values are normalized through
+// JSON so freshly emitted values compare equal to ones loaded from the log.
+// When a topic is emitted more than once the last value wins.
+func outputsByTopic(outputs interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(outputs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal output values: %w", err)
+	}
+	var events []struct {
+		Topic string      `json:"topic"`
+		Value interface{} `json:"value"`
+	}
+	if err := json.Unmarshal(data, &events); err != nil {
+		return nil, fmt.Errorf("failed to decode output values: %w", err)
+	}
+
+	byTopic := make(map[string]interface{}, len(events))
+	for _, event := range events {
+		byTopic[event.Topic] = event.Value
+	}
+	return byTopic, nil
+}
+
+func marshalOutputDiff(diff *OutputDiff) (interface{}, error) {
+	if diff == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(diff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal output diff: %w", err)
+	}
+	return string(data), nil
+}
+
+func unmarshalOutputDiff(data sql.NullString) (*OutputDiff, error) {
+	if !data.Valid || data.String == "" {
+		return nil, nil
+	}
+	var diff OutputDiff
+	if err := json.Unmarshal([]byte(data.String), &diff); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal output diff: %w", err)
+	}
+	return &diff, nil
+}
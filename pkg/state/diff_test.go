@@ -0,0 +1,140 @@
+package state
+
+import (
+	"log"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/strategy"
+	"github.com/denwilliams/go-mqtt-automation/pkg/topics"
+)
+
+func TestDiffOutputs(t *testing.T) {
+	tests := []struct {
+		name          string
+		previous      interface{}
+		current       interface{}
+		wantChanged   []string
+		wantUnchanged []string
+	}{
+		{
+			name:          "first execution",
+			current:       []strategy.EmitEvent{{Topic: "", Value: 1.0}},
+			wantChanged:   []string{""},
+			wantUnchanged: []string{},
+		},
+		{
+			name:          "changed and unchanged keys",
+			previous:      []strategy.EmitEvent{{Topic: "", Value: 21.5}, {Topic: "heat", Value: true}},
+			current:       []strategy.EmitEvent{{Topic: "", Value: 22.0}, {Topic: "heat", Value: true}},
+			wantChanged:   []string{""},
+			wantUnchanged: []string{"heat"},
+		},
+		{
+			name:          "added and removed keys",
+			previous:      []strategy.EmitEvent{{Topic: "a", Value: 1.0}, {Topic: "b", Value: 2.0}},
+			current:       []strategy.EmitEvent{{Topic: "b", Value: 2.0}, {Topic: "c", Value: 3.0}},
+			wantChanged:   []string{"a", "c"},
+			wantUnchanged: []string{"b"},
+		},
+		{
+			name: "decoded log compares equal to emitted values",
+			previous: []interface{}{
+				map[string]interface{}{"topic": "", "value": map[string]interface{}{"on": true, "level": 3.0}},
+			},
+			current:       []strategy.EmitEvent{{Topic: "", Value: map[string]interface{}{"on": true, "level": 3}}},
+			wantChanged:   []string{},
+			wantUnchanged: []string{""},
+		},
+		{
+			name:          "last emit of a topic wins",
+			previous:      []strategy.EmitEvent{{Topic: "", Value: 2.0}},
+			current:       []strategy.EmitEvent{{Topic: "", Value: 1.0}, {Topic: "", Value: 2.0}},
+			wantChanged:   []string{},
+			wantUnchanged: []string{""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff, err := DiffOutputs(tt.previous, tt.current)
+			if err != nil {
+				t.Fatalf("DiffOutputs failed: %v", err)
+			}
+			if !reflect.DeepEqual(diff.Changed, tt.wantChanged) {
+				t.Errorf("Changed = %q, want %q", diff.Changed, tt.wantChanged)
+			}
+			if !reflect.DeepEqual(diff.Unchanged, tt.wantUnchanged) {
+				t.Errorf("Unchanged = %q, want %q", diff.Unchanged, tt.wantUnchanged)
+			}
+		})
+	}
+}
+
+func TestManager_RecordExecutionOutputDiff(t *testing.T) {
+	db := setupTestSQLite(t)
+	manager := &Manager{db: db, logger: log.New(os.Stderr, "", 0), outputDiffEnabled: true}
+
+	if err := db.SaveStrategy(&strategy.Strategy{ID: "thermostat", Name: "Thermostat", Language: "javascript", CreatedAt: time.Now(), UpdatedAt: time.Now()}); err != nil {
+		t.Fatalf("SaveStrategy failed: %v", err)
+	}
+	if err := db.SaveTopic(topics.InternalTopicConfig{
+		BaseTopicConfig: topics.BaseTopicConfig{Name: "house/heating", Type: topics.TopicTypeInternal, CreatedAt: time.Now()},
+		StrategyID:      "thermostat",
+	}); err != nil {
+		t.Fatalf("SaveTopic failed: %v", err)
+	}
+
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	record := func(m *Manager, minutes int, outputs []strategy.EmitEvent, errorMessage string) {
+		t.Helper()
+		err := m.RecordExecution(topics.ExecutionRecord{
+			TopicName:  "house/heating",
+			StrategyID: "thermostat",
+			Outputs:    outputs,
+			Error:      errorMessage,
+			ExecutedAt: start.Add(time.Duration(minutes) * time.Minute),
+		})
+		if err != nil {
+			t.Fatalf("RecordExecution failed: %v", err)
+		}
+	}
+	latestDiff := func() *OutputDiff {
+		t.Helper()
+		logs, err := manager.LoadExecutionLogs("house/heating", 1)
+		if err != nil || len(logs) != 1 {
+			t.Fatalf("LoadExecutionLogs = %v, %v", logs, err)
+		}
+		return logs[0].OutputDiff
+	}
+
+	record(manager, 0, []strategy.EmitEvent{{Topic: "", Value: 20.0}, {Topic: "boost", Value: false}}, "")
+	record(manager, 1, []strategy.EmitEvent{{Topic: "", Value: 21.0}, {Topic: "boost", Value: false}}, "")
+	want := &OutputDiff{Changed: []string{""}, Unchanged: []string{"boost"}}
+	if diff := latestDiff(); !reflect.DeepEqual(diff, want) {
+		t.Errorf("diff of consecutive executions = %+v, want %+v", diff, want)
+	}
+
+	// Failed executions have no diff and are skipped when diffing the next one
+	record(manager, 2, nil, "boom")
+	if diff := latestDiff(); diff != nil {
+		t.Errorf("diff of a failed execution = %+v, want nil", diff)
+	}
+
+	// After a restart the previous outputs come from the execution log
+	restarted := &Manager{db: db, logger: log.New(os.Stderr, "", 0), outputDiffEnabled: true}
+	record(restarted, 3, []strategy.EmitEvent{{Topic: "", Value: 21.0}, {Topic: "boost", Value: true}}, "")
+	want = &OutputDiff{Changed: []string{"boost"}, Unchanged: []string{""}}
+	if diff := latestDiff(); !reflect.DeepEqual(diff, want) {
+		t.Errorf("diff after restart = %+v, want %+v", diff, want)
+	}
+
+	// Diffs are not stored when disabled
+	disabled := &Manager{db: db, logger: log.New(os.Stderr, "", 0)}
+	record(disabled, 4, []strategy.EmitEvent{{Topic: "", Value: 22.0}}, "")
+	if diff := latestDiff(); diff != nil {
+		t.Errorf("diff with output diffs disabled = %+v, want nil", diff)
+	}
+}
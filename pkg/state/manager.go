@@ -1,22 +1,106 @@
 package state
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"reflect"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/denwilliams/go-mqtt-automation/pkg/config"
 	"github.com/denwilliams/go-mqtt-automation/pkg/metrics"
 	"github.com/denwilliams/go-mqtt-automation/pkg/strategy"
+	"github.com/denwilliams/go-mqtt-automation/pkg/topics"
 )
 
 type Manager struct {
 	db     Database
 	logger *log.Logger
+
+	// replica serves read-only queries when a read replica is configured
+	replica Database
+
+	parametersEncrypted bool
+
+	// Topic history; lastHistoryValues caches each topic's latest recorded value
+	historyEnabled    bool
+	historyRetention  time.Duration
+	historyMutex      sync.Mutex
+	lastHistoryPrune  time.Time
+	lastHistoryValues map[string]interface{}
+
+	// System event log; events older than systemEventRetention are pruned
+	systemEventRetention time.Duration
+	systemEventMutex     sync.Mutex
+	lastSystemEventPrune time.Time
+
+	// Output diffs; lastOutputs caches each topic's latest successful outputs
+	outputDiffEnabled bool
+	outputsMutex      sync.Mutex
+	lastOutputs       map[string]interface{}
+
+	// Audit hash chain; lastChainHashes caches each topic's latest chain hash
+	hashChainEnabled bool
+	chainMutex       sync.Mutex
+	lastChainHashes  map[string]string
+
+	// Write batching; pendingStates is nil when writes go straight to the database
+	batchMutex    sync.Mutex
+	flushMutex    sync.Mutex
+	pendingStates map[string]interface{}
+	batchStop     chan struct{}
+	batchDone     chan struct{}
+}
+
+// parameterEncrypter is implemented by databases that can encrypt parameters
+// at rest
+type parameterEncrypter interface {
+	SetEncryptionKey(secret string) error
 }
 
+// historyPruneInterval is how often expired topic history is removed
+const historyPruneInterval = time.Hour
+
 func NewManager(cfg config.DatabaseConfig, logger *log.Logger) (*Manager, error) {
+	manager, err := OpenManager(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	// Run migrations, unless the schema is newer than this build
+	applyMigrations, err := manager.checkSchemaVersion(cfg.ForceSchemaVersion)
+	if err != nil {
+		manager.Close()
+		return nil, err
+	}
+	if applyMigrations {
+		if err := manager.MigrateUp(); err != nil {
+			manager.Close()
+			return nil, fmt.Errorf("failed to run database migrations: %w", err)
+		}
+	}
+
+	if cfg.WriteBatchInterval != "" {
+		interval, err := time.ParseDuration(cfg.WriteBatchInterval)
+		if err != nil {
+			manager.Close()
+			return nil, fmt.Errorf("invalid write batch interval: %w", err)
+		}
+		if interval > 0 {
+			manager.startWriteBatching(interval)
+		}
+	}
+
+	manager.logger.Printf("State manager initialized with %s database", cfg.Type)
+	return manager, nil
+}
+
+// OpenManager connects to the database without running migrations (used by
+// the migration command line options)
+func OpenManager(cfg config.DatabaseConfig, logger *log.Logger) (*Manager, error) {
 	if logger == nil {
 		logger = log.Default()
 	}
@@ -26,7 +110,7 @@ func NewManager(cfg config.DatabaseConfig, logger *log.Logger) (*Manager, error)
 
 	switch cfg.Type {
 	case "sqlite":
-		db, err = NewSQLiteDatabase(cfg.Connection)
+		db, err = NewSQLiteDatabase(cfg.Connection, cfg.SQLite)
 	case "postgres", "postgresql":
 		db, err = NewPostgreSQLDatabase(cfg.Connection)
 	default:
@@ -38,25 +122,176 @@ func NewManager(cfg config.DatabaseConfig, logger *log.Logger) (*Manager, error)
 	}
 
 	manager := &Manager{
-		db:     db,
-		logger: logger,
+		db:                db,
+		logger:            logger,
+		historyEnabled:    cfg.History.Enabled,
+		outputDiffEnabled: cfg.OutputDiff,
+		hashChainEnabled:  cfg.AuditHashChain,
 	}
 
-	// Run migrations
-	if err := db.Migrate(); err != nil {
-		return nil, fmt.Errorf("failed to run database migrations: %w", err)
+	if cfg.EncryptionKey != "" {
+		encrypter, ok := db.(parameterEncrypter)
+		if !ok {
+			db.Close()
+			return nil, fmt.Errorf("%s database does not support parameter encryption", cfg.Type)
+		}
+		if err := encrypter.SetEncryptionKey(cfg.EncryptionKey); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to configure parameter encryption: %w", err)
+		}
+		manager.parametersEncrypted = true
+	}
+
+	if cfg.History.Enabled {
+		retention, err := time.ParseDuration(cfg.History.Retention)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("invalid history retention: %w", err)
+		}
+		manager.historyRetention = retention
+	}
+
+	if cfg.SystemEvents.Retention != "" {
+		retention, err := time.ParseDuration(cfg.SystemEvents.Retention)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("invalid system event retention: %w", err)
+		}
+		manager.systemEventRetention = retention
+	}
+
+	if err := manager.openReplica(cfg); err != nil {
+		db.Close()
+		return nil, err
 	}
 
-	logger.Printf("State manager initialized with %s database", cfg.Type)
 	return manager, nil
 }
 
+// ParametersEncrypted reports whether topic and strategy parameters are
+// encrypted at rest
+func (m *Manager) ParametersEncrypted() bool {
+	return m.parametersEncrypted
+}
+
+// Schema Migrations
+
+// MigrateUp applies all pending migrations and logs the resulting schema version
+func (m *Manager) MigrateUp() error {
+	return m.runMigration("up", m.db.Migrate)
+}
+
+// MigrateDown rolls back the most recently applied migration
+func (m *Manager) MigrateDown() error {
+	return m.runMigration("down", m.db.MigrateDown)
+}
+
+// MigrateTo migrates up or down to a specific schema version
+func (m *Manager) MigrateTo(version uint) error {
+	return m.runMigration(fmt.Sprintf("to version %d", version), func() error {
+		return m.db.MigrateTo(version)
+	})
+}
+
+// SchemaVersion returns the current schema version and whether it is dirty
+// (a migration failed part way and needs manual repair)
+func (m *Manager) SchemaVersion() (uint, bool, error) {
+	return m.db.SchemaVersion()
+}
+
+// SchemaStatus returns the database schema version with the version this
+// build expects
+func (m *Manager) SchemaStatus() (SchemaStatus, error) {
+	current, dirty, err := m.db.SchemaVersion()
+	if err != nil {
+		return SchemaStatus{}, err
+	}
+	expected, err := m.db.ExpectedSchemaVersion()
+	if err != nil {
+		return SchemaStatus{}, err
+	}
+	return SchemaStatus{Current: current, Expected: expected, Dirty: dirty}, nil
+}
+
+// checkSchemaVersion refuses a schema newer than this build expects, which
+// this build cannot migrate and may fail against at runtime. With force it
+// only warns. It returns whether migrations should be applied.
+func (m *Manager) checkSchemaVersion(force bool) (bool, error) {
+	status, err := m.SchemaStatus()
+	if err != nil {
+		return false, err
+	}
+	if status.Drift() != "ahead" {
+		return true, nil
+	}
+
+	if !force {
+		return false, fmt.Errorf("%w: the database is at schema version %d but this build expects version %d. "+
+			"Upgrade the application, roll the database back with -migrate-to-version %d using the newer build, "+
+			"or start with -force to run against the newer schema anyway", ErrSchemaAhead, status.Current, status.Expected, status.Expected)
+	}
+	m.logger.Printf("Warning: database schema version %d is newer than version %d expected by this build; starting anyway because of -force", status.Current, status.Expected)
+	return false, nil
+}
+
+func (m *Manager) runMigration(direction string, migrate func() error) error {
+	before, _, err := m.db.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	if err := migrate(); err != nil {
+		// Report where the schema was left so the failure can be repaired
+		if version, dirty, versionErr := m.db.SchemaVersion(); versionErr == nil {
+			return fmt.Errorf("%w (schema version %d, dirty: %v)", err, version, dirty)
+		}
+		return err
+	}
+
+	after, _, err := m.db.SchemaVersion()
+	if err != nil {
+		return err
+	}
+
+	if before != after {
+		m.logger.Printf("Migrated database %s: schema version %d -> %d", direction, before, after)
+	} else {
+		m.logger.Printf("Database schema is at version %d (no changes)", after)
+	}
+	return nil
+}
+
 func (m *Manager) Close() error {
+	if err := m.Drain(); err != nil {
+		m.logger.Printf("Failed to write batched state on close: %v", err)
+	}
+	if m.replica != nil {
+		if err := m.replica.Close(); err != nil {
+			m.logger.Printf("Failed to close read replica: %v", err)
+		}
+	}
 	return m.db.Close()
 }
 
 // Topic State Management
+
+// SaveTopicState stores a topic's value. With write batching enabled the
+// value is queued and only the latest value per topic is written on flush.
+// History is recorded here, as values are saved, so it keeps every change
+// even when batching coalesces them.
 func (m *Manager) SaveTopicState(topicName string, value interface{}) error {
+	if m.historyEnabled {
+		unwrapped, _ := topics.UnwrapStoredValue(value)
+		m.recordTopicHistory(stateTopicName(topicName), unwrapped)
+	}
+
+	if m.queueTopicState(topicName, value) {
+		return nil
+	}
+	return m.writeTopicState(topicName, value)
+}
+
+func (m *Manager) writeTopicState(topicName string, value interface{}) error {
 	startTime := time.Now()
 
 	// Save to state table
@@ -72,14 +307,11 @@ func (m *Manager) SaveTopicState(topicName string, value interface{}) error {
 	metrics.RecordDatabaseQuery("save_topic_state", "write", time.Since(startTime).Seconds())
 
 	// Also update the last_value column in topics table (for API display)
-	// Extract actual topic name if it has a type prefix
-	actualTopicName := topicName
-	for _, prefix := range []string{"external:", "internal:", "child:", "system:", "topic:"} {
-		if strings.HasPrefix(topicName, prefix) {
-			actualTopicName = strings.TrimPrefix(topicName, prefix)
-			break
-		}
-	}
+	actualTopicName := stateTopicName(topicName)
+
+	// The state table keeps the value type tag; the topic and its history
+	// only need the value
+	value, _ = topics.UnwrapStoredValue(value)
 
 	updateStart := time.Now()
 	if err := m.db.UpdateTopicLastValue(actualTopicName, value); err != nil {
@@ -93,11 +325,166 @@ func (m *Manager) SaveTopicState(topicName string, value interface{}) error {
 	return nil
 }
 
+// stateTopicName returns the topic name of a state key, which may include a
+// type prefix (external:, internal:, child:, system:, topic:)
+func stateTopicName(key string) string {
+	for _, prefix := range []string{"external:", "internal:", "child:", "system:", "topic:"} {
+		if strings.HasPrefix(key, prefix) {
+			return strings.TrimPrefix(key, prefix)
+		}
+	}
+	return key
+}
+
+// recordTopicHistory appends a value to the topic history when it differs
+// from the last recorded value, and periodically removes entries older than
+// the configured retention
+func (m *Manager) recordTopicHistory(topicName string, value interface{}) {
+	now := time.Now()
+
+	m.historyMutex.Lock()
+	if m.lastHistoryValues == nil {
+		m.lastHistoryValues = make(map[string]interface{})
+	}
+	previous, ok := m.lastHistoryValues[topicName]
+	if ok && reflect.DeepEqual(previous, value) {
+		m.historyMutex.Unlock()
+		return
+	}
+	m.lastHistoryValues[topicName] = value
+	m.historyMutex.Unlock()
+
+	historyStart := time.Now()
+	if err := m.db.SaveTopicHistory(topicName, value, now); err != nil {
+		metrics.RecordDatabaseError("save_topic_history")
+		m.logger.Printf("Failed to save topic history for %s: %v", topicName, err)
+	} else {
+		metrics.RecordDatabaseQuery("save_topic_history", "write", time.Since(historyStart).Seconds())
+	}
+
+	if m.historyRetention <= 0 {
+		return
+	}
+
+	m.historyMutex.Lock()
+	due := now.Sub(m.lastHistoryPrune) >= historyPruneInterval
+	if due {
+		m.lastHistoryPrune = now
+	}
+	m.historyMutex.Unlock()
+
+	if due {
+		if err := m.PruneTopicHistory(now.Add(-m.historyRetention)); err != nil {
+			m.logger.Printf("Failed to prune topic history: %v", err)
+		}
+	}
+}
+
+// LoadTopicHistory returns the recorded values of a topic between from and to, oldest first
+func (m *Manager) LoadTopicHistory(topicName string, from, to time.Time) ([]TopicHistoryEntry, error) {
+	startTime := time.Now()
+
+	entries, err := readFrom(m, func(db Database) ([]TopicHistoryEntry, error) {
+		return db.LoadTopicHistory(topicName, from, to)
+	})
+	if err != nil {
+		metrics.RecordDatabaseError("load_topic_history")
+		return nil, err
+	}
+
+	metrics.RecordDatabaseQuery("load_topic_history", "read", time.Since(startTime).Seconds())
+	return entries, nil
+}
+
+// PruneTopicHistory removes topic history recorded before cutoff
+func (m *Manager) PruneTopicHistory(cutoff time.Time) error {
+	deleted, err := m.db.DeleteTopicHistoryBefore(cutoff)
+	if err != nil {
+		metrics.RecordDatabaseError("prune_topic_history")
+		return err
+	}
+
+	if deleted > 0 {
+		m.logger.Printf("Pruned %d topic history entries older than %s", deleted, cutoff.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// RecordSystemEvent appends an event to the system event log and
+// periodically removes events older than the configured retention
+func (m *Manager) RecordSystemEvent(eventType string, data interface{}, at time.Time) error {
+	startTime := time.Now()
+	if err := m.db.SaveSystemEvent(SystemEvent{Type: eventType, Data: data, OccurredAt: at}); err != nil {
+		metrics.RecordDatabaseError("save_system_event")
+		return fmt.Errorf("failed to save system event %s: %w", eventType, err)
+	}
+	metrics.RecordDatabaseQuery("save_system_event", "write", time.Since(startTime).Seconds())
+
+	if m.systemEventRetention <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	m.systemEventMutex.Lock()
+	due := now.Sub(m.lastSystemEventPrune) >= historyPruneInterval
+	if due {
+		m.lastSystemEventPrune = now
+	}
+	m.systemEventMutex.Unlock()
+
+	if due {
+		if err := m.PruneSystemEvents(now.Add(-m.systemEventRetention)); err != nil {
+			m.logger.Printf("Failed to prune system events: %v", err)
+		}
+	}
+	return nil
+}
+
+// LoadSystemEvents returns the system events matching query, newest first
+func (m *Manager) LoadSystemEvents(query SystemEventQuery) ([]SystemEvent, error) {
+	startTime := time.Now()
+
+	events, err := readFrom(m, func(db Database) ([]SystemEvent, error) {
+		return db.LoadSystemEvents(query)
+	})
+	if err != nil {
+		metrics.RecordDatabaseError("load_system_events")
+		return nil, err
+	}
+
+	metrics.RecordDatabaseQuery("load_system_events", "read", time.Since(startTime).Seconds())
+	return events, nil
+}
+
+// PruneSystemEvents removes system events that occurred before cutoff
+func (m *Manager) PruneSystemEvents(cutoff time.Time) error {
+	deleted, err := m.db.DeleteSystemEventsBefore(cutoff)
+	if err != nil {
+		metrics.RecordDatabaseError("prune_system_events")
+		return err
+	}
+
+	if deleted > 0 {
+		m.logger.Printf("Pruned %d system events older than %s", deleted, cutoff.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// IsHistoryEnabled reports whether topic value history is being recorded
+func (m *Manager) IsHistoryEnabled() bool {
+	return m.historyEnabled
+}
+
 func (m *Manager) LoadTopicState(topicName string) (interface{}, error) {
 	startTime := time.Now()
 
 	key := fmt.Sprintf("topic:%s", topicName)
-	value, err := m.db.LoadState(key)
+	if value, found := m.pendingTopicState(key); found {
+		return value, nil
+	}
+	value, err := readFrom(m, func(db Database) (interface{}, error) {
+		return db.LoadState(key)
+	})
 
 	duration := time.Since(startTime).Seconds()
 	if err != nil {
@@ -122,6 +509,9 @@ func (m *Manager) LoadTopicConfig(name string) (interface{}, error) {
 	return m.db.LoadTopic(name)
 }
 
+// LoadAllTopicConfigs reads the primary: topics are loaded at startup and
+// the topic list is rebuilt right after API writes, so a lagging replica
+// would return stale configs
 func (m *Manager) LoadAllTopicConfigs() ([]interface{}, error) {
 	return m.db.LoadAllTopics()
 }
@@ -148,6 +538,8 @@ func (m *Manager) LoadStrategy(id string) (*strategy.Strategy, error) {
 	return m.db.LoadStrategy(id)
 }
 
+// LoadAllStrategies reads the primary, for the same reason as
+// LoadAllTopicConfigs
 func (m *Manager) LoadAllStrategies() ([]*strategy.Strategy, error) {
 	return m.db.LoadAllStrategies()
 }
@@ -161,6 +553,40 @@ func (m *Manager) DeleteStrategy(id string) error {
 	return nil
 }
 
+// SaveStrategyFixture creates or replaces a named test fixture for a strategy
+func (m *Manager) SaveStrategyFixture(fixture StrategyFixture) error {
+	now := time.Now()
+	if fixture.CreatedAt.IsZero() {
+		fixture.CreatedAt = now
+	}
+	fixture.UpdatedAt = now
+
+	if err := m.db.SaveStrategyFixture(fixture); err != nil {
+		metrics.RecordDatabaseError("save_strategy_fixture")
+		return fmt.Errorf("failed to save fixture %s for strategy %s: %w", fixture.Name, fixture.StrategyID, err)
+	}
+	return nil
+}
+
+// LoadStrategyFixtures returns a strategy's test fixtures ordered by name
+func (m *Manager) LoadStrategyFixtures(strategyID string) ([]StrategyFixture, error) {
+	fixtures, err := m.db.LoadStrategyFixtures(strategyID)
+	if err != nil {
+		metrics.RecordDatabaseError("load_strategy_fixtures")
+		return nil, err
+	}
+	return fixtures, nil
+}
+
+// DeleteStrategyFixture removes a named test fixture from a strategy
+func (m *Manager) DeleteStrategyFixture(strategyID, name string) error {
+	if err := m.db.DeleteStrategyFixture(strategyID, name); err != nil {
+		metrics.RecordDatabaseError("delete_strategy_fixture")
+		return err
+	}
+	return nil
+}
+
 // General State Management
 func (m *Manager) SaveState(key string, value interface{}) error {
 	if err := m.db.SaveState(key, value); err != nil {
@@ -171,7 +597,9 @@ func (m *Manager) SaveState(key string, value interface{}) error {
 }
 
 func (m *Manager) LoadState(key string) (interface{}, error) {
-	return m.db.LoadState(key)
+	return readFrom(m, func(db Database) (interface{}, error) {
+		return db.LoadState(key)
+	})
 }
 
 func (m *Manager) DeleteState(key string) error {
@@ -182,6 +610,92 @@ func (m *Manager) DeleteState(key string) error {
 	return nil
 }
 
+// Topic Snapshots
+
+// snapshotKeyPrefix prefixes state keys holding topic snapshots
+const snapshotKeyPrefix = "snapshot:"
+
+// SaveTopicSnapshot persists a topic's recent values for crash recovery
+func (m *Manager) SaveTopicSnapshot(topicName string, snapshot topics.TopicSnapshot) error {
+	startTime := time.Now()
+	if err := m.db.SaveState(snapshotKeyPrefix+topicName, snapshot); err != nil {
+		metrics.RecordDatabaseError("save_topic_snapshot")
+		return err
+	}
+	metrics.RecordDatabaseQuery("save_topic_snapshot", "write", time.Since(startTime).Seconds())
+	return nil
+}
+
+// LoadTopicSnapshots returns all persisted topic snapshots keyed by topic name
+func (m *Manager) LoadTopicSnapshots() (map[string]topics.TopicSnapshot, error) {
+	allStates, err := m.db.LoadAllStates()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load states: %w", err)
+	}
+
+	snapshots := make(map[string]topics.TopicSnapshot)
+	for key, value := range allStates {
+		if !strings.HasPrefix(key, snapshotKeyPrefix) {
+			continue
+		}
+
+		// States are decoded generically; round-trip into the snapshot type
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal snapshot %s: %w", key, err)
+		}
+		var snapshot topics.TopicSnapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			m.logger.Printf("Skipping invalid topic snapshot %s: %v", key, err)
+			continue
+		}
+		snapshots[strings.TrimPrefix(key, snapshotKeyPrefix)] = snapshot
+	}
+	return snapshots, nil
+}
+
+// Strategy State
+
+// strategyStateKeyPrefix prefixes state keys holding strategy state
+const strategyStateKeyPrefix = "strategy_state:"
+
+func strategyStateKey(strategyID, topicName string) string {
+	return strategyStateKeyPrefix + strategyID + ":" + topicName
+}
+
+// LoadStrategyState returns the state a strategy keeps for a topic, or nil if
+// it has none. It is always read from the primary, since it is written back
+// after each execution.
+func (m *Manager) LoadStrategyState(strategyID, topicName string) (map[string]interface{}, error) {
+	value, err := m.db.LoadState(strategyStateKey(strategyID, topicName))
+	if errors.Is(err, ErrStateNotFound) || (err == nil && value == nil) {
+		return nil, nil
+	}
+	if err != nil {
+		metrics.RecordDatabaseError("load_strategy_state")
+		return nil, err
+	}
+	state, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid state of strategy %s for topic %s: %v", strategyID, topicName, value)
+	}
+	return state, nil
+}
+
+// SaveStrategyState replaces the state a strategy keeps for a topic. Empty
+// state is deleted.
+func (m *Manager) SaveStrategyState(strategyID, topicName string, state map[string]interface{}) error {
+	key := strategyStateKey(strategyID, topicName)
+	if len(state) == 0 {
+		return m.DeleteState(key)
+	}
+	if err := m.db.SaveState(key, state); err != nil {
+		metrics.RecordDatabaseError("save_strategy_state")
+		return err
+	}
+	return nil
+}
+
 // Execution Log Management
 func (m *Manager) SaveExecutionLog(log ExecutionLog) error {
 	if err := m.db.SaveExecutionLog(log); err != nil {
@@ -191,11 +705,84 @@ func (m *Manager) SaveExecutionLog(log ExecutionLog) error {
 	return nil
 }
 
+// RecordExecution saves a topic's strategy execution to the execution log,
+// with its output diff and chain hash when enabled
+func (m *Manager) RecordExecution(record topics.ExecutionRecord) error {
+	log := ExecutionLog{
+		TopicName:       record.TopicName,
+		StrategyID:      record.StrategyID,
+		TriggerTopic:    record.TriggerTopic,
+		InputValues:     record.Inputs,
+		OutputValues:    record.Outputs,
+		ErrorMessage:    record.Error,
+		ExecutionTimeMs: record.Duration.Milliseconds(),
+		LogMessages:     record.LogMessages,
+		ExecutedAt:      record.ExecutedAt,
+	}
+	if m.outputDiffEnabled && record.Error == "" {
+		log.OutputDiff = m.diffWithPreviousOutputs(record.TopicName, record.Outputs)
+	}
+	if m.hashChainEnabled {
+		return m.saveChainedExecutionLog(log)
+	}
+	return m.SaveExecutionLog(log)
+}
+
+// errFoundExecution stops streaming execution logs once a match is found
+var errFoundExecution = errors.New("found execution")
+
+// diffWithPreviousOutputs diffs outputs against the topic's previous
+// successful execution and remembers them for the next one. The previous
+// outputs are loaded from the execution log after a restart.
+func (m *Manager) diffWithPreviousOutputs(topicName string, outputs []strategy.EmitEvent) *OutputDiff {
+	m.outputsMutex.Lock()
+	defer m.outputsMutex.Unlock()
+
+	if m.lastOutputs == nil {
+		m.lastOutputs = make(map[string]interface{})
+	}
+	previous, ok := m.lastOutputs[topicName]
+	if !ok {
+		err := m.db.EachExecutionLog(topicName, 0, func(log ExecutionLog) error {
+			if log.ErrorMessage != "" {
+				return nil
+			}
+			previous = log.OutputValues
+			return errFoundExecution
+		})
+		if err != nil && !errors.Is(err, errFoundExecution) {
+			m.logger.Printf("Failed to load previous outputs of %s: %v", topicName, err)
+		}
+	}
+	m.lastOutputs[topicName] = outputs
+
+	diff, err := DiffOutputs(previous, outputs)
+	if err != nil {
+		m.logger.Printf("Failed to diff outputs of %s: %v", topicName, err)
+		return nil
+	}
+	return diff
+}
+
 func (m *Manager) LoadExecutionLogs(topicName string, limit int) ([]ExecutionLog, error) {
 	if limit <= 0 {
 		limit = 100 // Default limit
 	}
-	return m.db.LoadExecutionLogs(topicName, limit)
+	return readFrom(m, func(db Database) ([]ExecutionLog, error) {
+		return db.LoadExecutionLogs(topicName, limit)
+	})
+}
+
+// EachExecutionLog streams a topic's execution logs, newest first. A limit
+// <= 0 streams every log.
+func (m *Manager) EachExecutionLog(topicName string, limit int, fn func(ExecutionLog) error) error {
+	return m.db.EachExecutionLog(topicName, limit, fn)
+}
+
+// LoadStrategyUsage returns the topic count and last execution time of each
+// used strategy. Strategies missing from the map are unused.
+func (m *Manager) LoadStrategyUsage() (map[string]StrategyUsage, error) {
+	return readFrom(m, Database.LoadStrategyUsage)
 }
 
 // System Recovery
@@ -225,10 +812,30 @@ func (m *Manager) RestoreTopicStates() (map[string]interface{}, error) {
 }
 
 // Database maintenance
+
+// CleanupOldLogs removes execution logs recorded more than days ago. It
+// refuses while the audit hash chain is enabled, since removing the oldest
+// logs would break the chain's verification.
 func (m *Manager) CleanupOldLogs(days int) error {
-	// This would implement cleanup of old execution logs
-	// For now, just log the action
-	m.logger.Printf("Would cleanup execution logs older than %d days", days)
+	if days <= 0 {
+		return fmt.Errorf("invalid execution log retention: %d days", days)
+	}
+	if m.hashChainEnabled {
+		return fmt.Errorf("execution logs cannot be cleaned up while the audit hash chain is enabled")
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -days)
+	startTime := time.Now()
+	deleted, err := m.db.DeleteExecutionLogsOlderThan(cutoff)
+	if err != nil {
+		metrics.RecordDatabaseError("cleanup_execution_logs")
+		return fmt.Errorf("failed to clean up execution logs: %w", err)
+	}
+	metrics.RecordDatabaseQuery("cleanup_execution_logs", "write", time.Since(startTime).Seconds())
+
+	if deleted > 0 {
+		m.logger.Printf("Cleaned up %d execution logs older than %d days", deleted, days)
+	}
 	return nil
 }
 
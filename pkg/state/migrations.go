@@ -0,0 +1,131 @@
+package state
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+// ErrSchemaAhead is returned when the database schema is newer than the
+// migrations of this build, e.g. after downgrading the application
+var ErrSchemaAhead = errors.New("database schema is newer than this build supports")
+
+// SchemaStatus compares the database schema version with the version this
+// build's migrations bring it to
+type SchemaStatus struct {
+	Current  uint `json:"current"`
+	Expected uint `json:"expected"`
+	Dirty    bool `json:"dirty"`
+}
+
+// Drift reports how the schema compares: "current", "behind" (pending
+// migrations will be applied) or "ahead" (migrated by a newer build)
+func (s SchemaStatus) Drift() string {
+	switch {
+	case s.Current > s.Expected:
+		return "ahead"
+	case s.Current < s.Expected:
+		return "behind"
+	default:
+		return "current"
+	}
+}
+
+// highestMigrationVersion returns the highest migration version in dir
+func highestMigrationVersion(dir string) (uint, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	var latest uint
+	for _, entry := range entries {
+		migration, err := source.Parse(entry.Name())
+		if err != nil || migration.Direction != source.Up {
+			continue
+		}
+		if migration.Version > latest {
+			latest = migration.Version
+		}
+	}
+	return latest, nil
+}
+
+// migrateFactory creates a migrate instance for a database backend
+type migrateFactory func() (*migrate.Migrate, error)
+
+// migrateUp applies all pending migrations
+func migrateUp(newMigrate migrateFactory) error {
+	m, err := newMigrate()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
+		return fmt.Errorf("failed to run migrations: %w", err)
+	}
+
+	return nil
+}
+
+// migrateDown rolls back the most recently applied migration
+func migrateDown(newMigrate migrateFactory) error {
+	m, err := newMigrate()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if err := m.Steps(-1); err != nil {
+		if errors.Is(err, migrate.ErrNilVersion) {
+			return fmt.Errorf("no migrations to roll back")
+		}
+		return fmt.Errorf("failed to roll back migration: %w", err)
+	}
+
+	return nil
+}
+
+// migrateTo migrates up or down to the given version. Version 0 rolls back all migrations.
+func migrateTo(newMigrate migrateFactory, version uint) error {
+	m, err := newMigrate()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if version == 0 {
+		err = m.Down()
+	} else {
+		err = m.Migrate(version)
+	}
+	if err != nil && err != migrate.ErrNoChange {
+		return fmt.Errorf("failed to migrate to version %d: %w", version, err)
+	}
+
+	return nil
+}
+
+// schemaVersion returns the current schema version and whether the last
+// migration failed part way (dirty). Version 0 means no migrations are applied.
+func schemaVersion(newMigrate migrateFactory) (uint, bool, error) {
+	m, err := newMigrate()
+	if err != nil {
+		return 0, false, err
+	}
+	defer m.Close()
+
+	version, dirty, err := m.Version()
+	if err != nil {
+		if errors.Is(err, migrate.ErrNilVersion) {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	return version, dirty, nil
+}
@@ -0,0 +1,163 @@
+package state
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/config"
+)
+
+// latestMigrationVersion returns the number of generated SQLite migrations
+func latestMigrationVersion(t *testing.T) uint {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join(MigrationsDir, "sqlite", "*.up.sql"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("Failed to find SQLite migrations: %v", err)
+	}
+	return uint(len(files))
+}
+
+func openTestManager(t *testing.T) *Manager {
+	t.Helper()
+
+	manager, err := OpenManager(config.DatabaseConfig{
+		Type:       "sqlite",
+		Connection: filepath.Join(t.TempDir(), "test.db"),
+	}, nil)
+	if err != nil {
+		t.Fatalf("OpenManager failed: %v", err)
+	}
+	t.Cleanup(func() { manager.Close() })
+
+	return manager
+}
+
+func assertSchemaVersion(t *testing.T, manager *Manager, want uint) {
+	t.Helper()
+
+	version, dirty, err := manager.SchemaVersion()
+	if err != nil {
+		t.Fatalf("SchemaVersion failed: %v", err)
+	}
+	if version != want {
+		t.Errorf("schema version = %d, want %d", version, want)
+	}
+	if dirty {
+		t.Error("schema should not be dirty")
+	}
+}
+
+func TestManagerMigrateUpAndDown(t *testing.T) {
+	manager := openTestManager(t)
+	latest := latestMigrationVersion(t)
+
+	// A fresh database has no schema
+	assertSchemaVersion(t, manager, 0)
+
+	if err := manager.MigrateUp(); err != nil {
+		t.Fatalf("MigrateUp failed: %v", err)
+	}
+	assertSchemaVersion(t, manager, latest)
+
+	// Running again is a no-op
+	if err := manager.MigrateUp(); err != nil {
+		t.Fatalf("second MigrateUp failed: %v", err)
+	}
+	assertSchemaVersion(t, manager, latest)
+
+	if err := manager.MigrateDown(); err != nil {
+		t.Fatalf("MigrateDown failed: %v", err)
+	}
+	assertSchemaVersion(t, manager, latest-1)
+
+	if err := manager.MigrateUp(); err != nil {
+		t.Fatalf("MigrateUp after down failed: %v", err)
+	}
+	assertSchemaVersion(t, manager, latest)
+}
+
+func TestManagerMigrateTo(t *testing.T) {
+	manager := openTestManager(t)
+	latest := latestMigrationVersion(t)
+
+	steps := []uint{3, latest, 1, 0, latest}
+	for _, version := range steps {
+		if err := manager.MigrateTo(version); err != nil {
+			t.Fatalf("MigrateTo(%d) failed: %v", version, err)
+		}
+		assertSchemaVersion(t, manager, version)
+	}
+
+	if err := manager.MigrateTo(latest + 100); err == nil {
+		t.Error("MigrateTo should fail for an unknown version")
+	}
+	assertSchemaVersion(t, manager, latest)
+}
+
+func TestManagerMigrateDownWithoutMigrations(t *testing.T) {
+	manager := openTestManager(t)
+
+	if err := manager.MigrateDown(); err == nil {
+		t.Error("MigrateDown should fail when no migrations are applied")
+	}
+}
+
+func TestManagerCheckSchemaVersion(t *testing.T) {
+	latest := latestMigrationVersion(t)
+
+	tests := []struct {
+		name        string
+		version     uint // schema version before the check; 0 is a fresh database
+		force       bool
+		drift       string
+		wantMigrate bool
+		wantErr     bool
+	}{
+		{name: "behind", version: 0, drift: "behind", wantMigrate: true},
+		{name: "older schema", version: latest - 1, drift: "behind", wantMigrate: true},
+		{name: "matching", version: latest, drift: "current", wantMigrate: true},
+		{name: "ahead", version: latest + 1, drift: "ahead", wantErr: true},
+		{name: "ahead forced", version: latest + 1, force: true, drift: "ahead"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := openTestManager(t)
+			if tt.version > 0 {
+				if err := manager.MigrateUp(); err != nil {
+					t.Fatalf("MigrateUp failed: %v", err)
+				}
+				// Simulate a database migrated by an older or newer build
+				sqlite := manager.db.(*SQLiteDatabase)
+				if _, err := sqlite.db.Exec("UPDATE schema_migrations SET version = ?", tt.version); err != nil {
+					t.Fatalf("Failed to set schema version: %v", err)
+				}
+			}
+
+			status, err := manager.SchemaStatus()
+			if err != nil {
+				t.Fatalf("SchemaStatus failed: %v", err)
+			}
+			if status.Current != tt.version || status.Expected != latest || status.Drift() != tt.drift {
+				t.Errorf("SchemaStatus() = %+v (drift %s), want current %d, expected %d, drift %s",
+					status, status.Drift(), tt.version, latest, tt.drift)
+			}
+
+			migrate, err := manager.checkSchemaVersion(tt.force)
+			if tt.wantErr {
+				if !errors.Is(err, ErrSchemaAhead) {
+					t.Errorf("checkSchemaVersion() error = %v, want ErrSchemaAhead", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("checkSchemaVersion() failed: %v", err)
+			}
+			if migrate != tt.wantMigrate {
+				t.Errorf("checkSchemaVersion() = %v, want %v", migrate, tt.wantMigrate)
+			}
+		})
+	}
+}
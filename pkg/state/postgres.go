@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"path/filepath"
 	"time"
 
 	"github.com/denwilliams/go-mqtt-automation/pkg/strategy"
@@ -15,8 +16,20 @@ import (
 )
 
 type PostgreSQLDatabase struct {
-	db  *sql.DB
-	dsn string
+	db     *sql.DB
+	dsn    string
+	params *parameterCipher
+}
+
+// SetEncryptionKey encrypts topic and strategy parameters at rest with a key
+// derived from secret. Existing plaintext parameters are still readable.
+func (p *PostgreSQLDatabase) SetEncryptionKey(secret string) error {
+	params, err := newParameterCipher(secret)
+	if err != nil {
+		return err
+	}
+	p.params = params
+	return nil
 }
 
 func NewPostgreSQLDatabase(dsn string) (*PostgreSQLDatabase, error) {
@@ -44,35 +57,51 @@ func NewPostgreSQLDatabase(dsn string) (*PostgreSQLDatabase, error) {
 	return pgDB, nil
 }
 
-func (p *PostgreSQLDatabase) Migrate() error {
-	// Create a separate database connection for migrations to avoid connection interference
+// newMigrate creates a migrate instance on a separate database connection to
+// avoid interfering with the main connection. Closing it closes the connection.
+func (p *PostgreSQLDatabase) newMigrate() (*migrate.Migrate, error) {
 	migrationDB, err := sql.Open("postgres", p.dsn)
 	if err != nil {
-		return fmt.Errorf("failed to open migration database: %w", err)
+		return nil, fmt.Errorf("failed to open migration database: %w", err)
 	}
-	defer migrationDB.Close()
 
 	// Create postgres driver instance
 	driver, err := postgres.WithInstance(migrationDB, &postgres.Config{})
 	if err != nil {
-		return fmt.Errorf("failed to create postgres driver: %w", err)
+		migrationDB.Close()
+		return nil, fmt.Errorf("failed to create postgres driver: %w", err)
 	}
 
 	// Create migrate instance
 	m, err := migrate.NewWithDatabaseInstance(
-		"file://db/migrations/postgres",
+		"file://"+filepath.Join(MigrationsDir, "postgres"),
 		"postgres", driver)
 	if err != nil {
-		return fmt.Errorf("failed to create migrate instance: %w", err)
+		migrationDB.Close()
+		return nil, fmt.Errorf("failed to create migrate instance: %w", err)
 	}
-	defer m.Close()
 
-	// Run migrations
-	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-		return fmt.Errorf("failed to run migrations: %w", err)
-	}
+	return m, nil
+}
 
-	return nil
+func (p *PostgreSQLDatabase) Migrate() error {
+	return migrateUp(p.newMigrate)
+}
+
+func (p *PostgreSQLDatabase) MigrateDown() error {
+	return migrateDown(p.newMigrate)
+}
+
+func (p *PostgreSQLDatabase) MigrateTo(version uint) error {
+	return migrateTo(p.newMigrate, version)
+}
+
+func (p *PostgreSQLDatabase) SchemaVersion() (uint, bool, error) {
+	return schemaVersion(p.newMigrate)
+}
+
+func (p *PostgreSQLDatabase) ExpectedSchemaVersion() (uint, error) {
+	return highestMigrationVersion(filepath.Join(MigrationsDir, "postgres"))
 }
 
 // Migration helper methods removed - now handled by golang-migrate
@@ -103,7 +132,7 @@ func (p *PostgreSQLDatabase) saveBaseTopic(config topics.BaseTopicConfig) error
 
 	var lastValueJSON sql.NullString
 	if config.LastValue != nil {
-		lastValueBytes, marshalErr := json.Marshal(config.LastValue)
+		lastValueBytes, marshalErr := topics.CanonicalJSON(config.LastValue)
 		if marshalErr != nil {
 			return fmt.Errorf("failed to marshal last value: %w", marshalErr)
 		}
@@ -141,9 +170,9 @@ func (p *PostgreSQLDatabase) saveInternalTopic(config topics.InternalTopicConfig
 		return fmt.Errorf("failed to marshal input names: %w", err)
 	}
 
-	parametersJSON, err := json.Marshal(config.Parameters)
+	parametersJSON, err := p.params.marshalParameters(config.Parameters)
 	if err != nil {
-		return fmt.Errorf("failed to marshal parameters: %w", err)
+		return err
 	}
 
 	query := `
@@ -152,7 +181,7 @@ func (p *PostgreSQLDatabase) saveInternalTopic(config topics.InternalTopicConfig
 		WHERE name = $7
 	`
 
-	_, err = p.db.Exec(query, string(inputsJSON), string(inputNamesJSON), config.StrategyID, string(parametersJSON), config.EmitToMQTT, config.NoOpUnchanged, config.Name)
+	_, err = p.db.Exec(query, string(inputsJSON), string(inputNamesJSON), config.StrategyID, parametersJSON, config.EmitToMQTT, config.NoOpUnchanged, config.Name)
 	return err
 }
 
@@ -278,7 +307,7 @@ func (p *PostgreSQLDatabase) buildTopicConfig(name, topicType string, inputs, in
 
 		var parsedParameters map[string]interface{}
 		if parameters.Valid && parameters.String != "" {
-			if err := json.Unmarshal([]byte(parameters.String), &parsedParameters); err != nil {
+			if err := p.params.unmarshalParameters(parameters.String, &parsedParameters); err != nil {
 				return nil, fmt.Errorf("failed to unmarshal parameters: %w", err)
 			}
 		}
@@ -307,13 +336,17 @@ func (p *PostgreSQLDatabase) buildTopicConfig(name, topicType string, inputs, in
 }
 
 func (p *PostgreSQLDatabase) DeleteTopic(name string) error {
+	// Execution logs reference the topic
+	if _, err := p.db.Exec("DELETE FROM execution_log WHERE topic_name = $1", name); err != nil {
+		return fmt.Errorf("failed to delete execution logs: %w", err)
+	}
 	query := "DELETE FROM topics WHERE name = $1"
 	_, err := p.db.Exec(query, name)
 	return err
 }
 
 func (p *PostgreSQLDatabase) UpdateTopicLastValue(topicName string, value interface{}) error {
-	valueJSON, err := json.Marshal(value)
+	valueJSON, err := topics.CanonicalJSON(value)
 	if err != nil {
 		return fmt.Errorf("failed to marshal value: %w", err)
 	}
@@ -325,14 +358,19 @@ func (p *PostgreSQLDatabase) UpdateTopicLastValue(topicName string, value interf
 
 // Strategies
 func (p *PostgreSQLDatabase) SaveStrategy(strategy *strategy.Strategy) error {
-	parametersJSON, err := json.Marshal(strategy.Parameters)
+	parametersJSON, err := p.params.marshalParameters(strategy.Parameters)
+	if err != nil {
+		return err
+	}
+
+	allowedInputPatternsJSON, err := marshalAllowedInputPatterns(strategy.AllowedInputPatterns)
 	if err != nil {
-		return fmt.Errorf("failed to marshal parameters: %w", err)
+		return err
 	}
 
 	query := `
-		INSERT INTO strategies (id, name, description, code, language, parameters, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO strategies (id, name, description, code, language, parameters, allowed_input_patterns, library, log_level, file_path, timeout_ms, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 		ON CONFLICT (id)
 		DO UPDATE SET
 			name = EXCLUDED.name,
@@ -340,17 +378,22 @@ func (p *PostgreSQLDatabase) SaveStrategy(strategy *strategy.Strategy) error {
 			code = EXCLUDED.code,
 			language = EXCLUDED.language,
 			parameters = EXCLUDED.parameters,
+			allowed_input_patterns = EXCLUDED.allowed_input_patterns,
+			library = EXCLUDED.library,
+			log_level = EXCLUDED.log_level,
+			file_path = EXCLUDED.file_path,
+			timeout_ms = EXCLUDED.timeout_ms,
 			updated_at = EXCLUDED.updated_at
 	`
 
 	_, err = p.db.Exec(query, strategy.ID, strategy.Name, strategy.Description, strategy.Code, strategy.Language,
-		string(parametersJSON), strategy.CreatedAt, strategy.UpdatedAt)
+		parametersJSON, allowedInputPatternsJSON, strategy.Library, string(strategy.LogLevel), strategy.FilePath, nullableTimeout(strategy.TimeoutMs), strategy.CreatedAt, strategy.UpdatedAt)
 	return err
 }
 
 func (p *PostgreSQLDatabase) LoadStrategy(id string) (*strategy.Strategy, error) {
 	query := `
-		SELECT id, name, description, code, language, builtin, parameters, max_inputs, default_input_names, created_at, updated_at
+		SELECT id, name, description, code, language, builtin, parameters, max_inputs, default_input_names, allowed_input_patterns, library, log_level, file_path, timeout_ms, created_at, updated_at
 		FROM strategies
 		WHERE id = $1
 	`
@@ -359,10 +402,15 @@ func (p *PostgreSQLDatabase) LoadStrategy(id string) (*strategy.Strategy, error)
 	var parametersJSON sql.NullString
 	var maxInputs sql.NullInt64
 	var defaultInputNamesJSON sql.NullString
+	var allowedInputPatternsJSON sql.NullString
+	var library sql.NullBool
+	var logLevel sql.NullString
+	var filePath sql.NullString
+	var timeoutMs sql.NullInt64
 
 	err := p.db.QueryRow(query, id).Scan(
 		&strat.ID, &strat.Name, &strat.Description, &strat.Code, &strat.Language, &strat.Builtin,
-		&parametersJSON, &maxInputs, &defaultInputNamesJSON, &strat.CreatedAt, &strat.UpdatedAt,
+		&parametersJSON, &maxInputs, &defaultInputNamesJSON, &allowedInputPatternsJSON, &library, &logLevel, &filePath, &timeoutMs, &strat.CreatedAt, &strat.UpdatedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -372,7 +420,7 @@ func (p *PostgreSQLDatabase) LoadStrategy(id string) (*strategy.Strategy, error)
 	}
 
 	if parametersJSON.Valid && parametersJSON.String != "" {
-		if err := json.Unmarshal([]byte(parametersJSON.String), &strat.Parameters); err != nil {
+		if err := p.params.unmarshalParameters(parametersJSON.String, &strat.Parameters); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal parameters: %w", err)
 		}
 	}
@@ -389,12 +437,22 @@ func (p *PostgreSQLDatabase) LoadStrategy(id string) (*strategy.Strategy, error)
 		}
 	}
 
+	if allowedInputPatternsJSON.Valid && allowedInputPatternsJSON.String != "" {
+		if err := json.Unmarshal([]byte(allowedInputPatternsJSON.String), &strat.AllowedInputPatterns); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal allowed_input_patterns: %w", err)
+		}
+	}
+	strat.Library = library.Bool
+	strat.LogLevel = strategy.LogLevel(logLevel.String)
+	strat.FilePath = filePath.String
+	strat.TimeoutMs = int(timeoutMs.Int64)
+
 	return &strat, nil
 }
 
 func (p *PostgreSQLDatabase) LoadAllStrategies() ([]*strategy.Strategy, error) {
 	query := `
-		SELECT id, name, description, code, language, builtin, parameters, max_inputs, default_input_names, created_at, updated_at
+		SELECT id, name, description, code, language, builtin, parameters, max_inputs, default_input_names, allowed_input_patterns, library, log_level, file_path, timeout_ms, created_at, updated_at
 		FROM strategies
 		ORDER BY name
 	`
@@ -411,17 +469,22 @@ func (p *PostgreSQLDatabase) LoadAllStrategies() ([]*strategy.Strategy, error) {
 		var parametersJSON sql.NullString
 		var maxInputs sql.NullInt64
 		var defaultInputNamesJSON sql.NullString
+		var allowedInputPatternsJSON sql.NullString
+		var library sql.NullBool
+		var logLevel sql.NullString
+		var filePath sql.NullString
+		var timeoutMs sql.NullInt64
 
 		err := rows.Scan(
 			&strat.ID, &strat.Name, &strat.Description, &strat.Code, &strat.Language, &strat.Builtin,
-			&parametersJSON, &maxInputs, &defaultInputNamesJSON, &strat.CreatedAt, &strat.UpdatedAt,
+			&parametersJSON, &maxInputs, &defaultInputNamesJSON, &allowedInputPatternsJSON, &library, &logLevel, &filePath, &timeoutMs, &strat.CreatedAt, &strat.UpdatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan strategy: %w", err)
 		}
 
 		if parametersJSON.Valid && parametersJSON.String != "" {
-			if err := json.Unmarshal([]byte(parametersJSON.String), &strat.Parameters); err != nil {
+			if err := p.params.unmarshalParameters(parametersJSON.String, &strat.Parameters); err != nil {
 				return nil, fmt.Errorf("failed to unmarshal parameters for strategy %s: %w", strat.ID, err)
 			}
 		}
@@ -438,6 +501,16 @@ func (p *PostgreSQLDatabase) LoadAllStrategies() ([]*strategy.Strategy, error) {
 			}
 		}
 
+		if allowedInputPatternsJSON.Valid && allowedInputPatternsJSON.String != "" {
+			if err := json.Unmarshal([]byte(allowedInputPatternsJSON.String), &strat.AllowedInputPatterns); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal allowed_input_patterns for strategy %s: %w", strat.ID, err)
+			}
+		}
+		strat.Library = library.Bool
+		strat.LogLevel = strategy.LogLevel(logLevel.String)
+		strat.FilePath = filePath.String
+		strat.TimeoutMs = int(timeoutMs.Int64)
+
 		strategies = append(strategies, &strat)
 	}
 
@@ -445,6 +518,13 @@ func (p *PostgreSQLDatabase) LoadAllStrategies() ([]*strategy.Strategy, error) {
 }
 
 func (p *PostgreSQLDatabase) DeleteStrategy(id string) error {
+	// Execution logs and fixtures reference the strategy
+	if _, err := p.db.Exec("DELETE FROM execution_log WHERE strategy_id = $1", id); err != nil {
+		return fmt.Errorf("failed to delete execution logs: %w", err)
+	}
+	if _, err := p.db.Exec("DELETE FROM strategy_fixtures WHERE strategy_id = $1", id); err != nil {
+		return fmt.Errorf("failed to delete strategy fixtures: %w", err)
+	}
 	query := "DELETE FROM strategies WHERE id = $1"
 	_, err := p.db.Exec(query, id)
 	return err
@@ -452,7 +532,7 @@ func (p *PostgreSQLDatabase) DeleteStrategy(id string) error {
 
 // State
 func (p *PostgreSQLDatabase) SaveState(key string, value interface{}) error {
-	valueJSON, err := json.Marshal(value)
+	valueJSON, err := topics.CanonicalJSON(value)
 	if err != nil {
 		return fmt.Errorf("failed to marshal value: %w", err)
 	}
@@ -540,58 +620,351 @@ func (p *PostgreSQLDatabase) SaveExecutionLog(log ExecutionLog) error {
 		return fmt.Errorf("failed to marshal output values: %w", err)
 	}
 
+	logMessagesJSON, err := marshalLogMessages(log.LogMessages)
+	if err != nil {
+		return err
+	}
+
+	outputDiffJSON, err := marshalOutputDiff(log.OutputDiff)
+	if err != nil {
+		return err
+	}
+
 	query := `
 		INSERT INTO execution_log 
-		(topic_name, strategy_id, trigger_topic, input_values, output_values, error_message, execution_time_ms, executed_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		(topic_name, strategy_id, trigger_topic, input_values, output_values, error_message, execution_time_ms, log_messages, executed_at, output_diff, chain_hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 	`
 
 	_, err = p.db.Exec(query, log.TopicName, log.StrategyID, log.TriggerTopic,
 		string(inputValuesJSON), string(outputValuesJSON), log.ErrorMessage,
-		log.ExecutionTimeMs, log.ExecutedAt)
+		log.ExecutionTimeMs, logMessagesJSON, log.ExecutedAt, outputDiffJSON, nullString(log.ChainHash))
 	return err
 }
 
 func (p *PostgreSQLDatabase) LoadExecutionLogs(topicName string, limit int) ([]ExecutionLog, error) {
+	var logs []ExecutionLog
+	err := p.EachExecutionLog(topicName, limit, func(log ExecutionLog) error {
+		logs = append(logs, log)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+func (p *PostgreSQLDatabase) EachExecutionLog(topicName string, limit int, fn func(ExecutionLog) error) error {
+	return p.eachExecutionLog("executed_at DESC", topicName, limit, fn)
+}
+
+// EachExecutionLogByID calls fn for a topic's execution logs in reverse
+// insertion order
+func (p *PostgreSQLDatabase) EachExecutionLogByID(topicName string, fn func(ExecutionLog) error) error {
+	return p.eachExecutionLog("id DESC", topicName, 0, fn)
+}
+
+func (p *PostgreSQLDatabase) eachExecutionLog(orderBy, topicName string, limit int, fn func(ExecutionLog) error) error {
 	query := `
 		SELECT id, topic_name, strategy_id, trigger_topic, input_values, output_values,
-		       error_message, execution_time_ms, executed_at
+		       error_message, execution_time_ms, log_messages, executed_at, output_diff, chain_hash
 		FROM execution_log
 		WHERE topic_name = $1
-		ORDER BY executed_at DESC
+		ORDER BY ` + orderBy + `
 		LIMIT $2
 	`
 
-	rows, err := p.db.Query(query, topicName, limit)
+	// LIMIT NULL returns every row
+	var limitArg interface{}
+	if limit > 0 {
+		limitArg = limit
+	}
+
+	rows, err := p.db.Query(query, topicName, limitArg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query execution logs: %w", err)
+		return fmt.Errorf("failed to query execution logs: %w", err)
 	}
 	defer rows.Close()
 
-	var logs []ExecutionLog
 	for rows.Next() {
 		var log ExecutionLog
 		var inputValuesJSON, outputValuesJSON string
+		var logMessagesJSON, outputDiffJSON, chainHash sql.NullString
 
 		err := rows.Scan(
 			&log.ID, &log.TopicName, &log.StrategyID, &log.TriggerTopic,
 			&inputValuesJSON, &outputValuesJSON, &log.ErrorMessage,
-			&log.ExecutionTimeMs, &log.ExecutedAt,
+			&log.ExecutionTimeMs, &logMessagesJSON, &log.ExecutedAt, &outputDiffJSON, &chainHash,
 		)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan execution log: %w", err)
+			return fmt.Errorf("failed to scan execution log: %w", err)
 		}
 
 		if err := json.Unmarshal([]byte(inputValuesJSON), &log.InputValues); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal input values: %w", err)
+			return fmt.Errorf("failed to unmarshal input values: %w", err)
 		}
 
 		if err := json.Unmarshal([]byte(outputValuesJSON), &log.OutputValues); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal output values: %w", err)
+			return fmt.Errorf("failed to unmarshal output values: %w", err)
 		}
 
-		logs = append(logs, log)
+		if log.LogMessages, err = unmarshalLogMessages(logMessagesJSON); err != nil {
+			return err
+		}
+
+		if log.OutputDiff, err = unmarshalOutputDiff(outputDiffJSON); err != nil {
+			return err
+		}
+		log.ChainHash = chainHash.String
+
+		if err := fn(log); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// Execution log retention
+
+// DeleteExecutionLogsOlderThan removes execution logs recorded before cutoff,
+// returning how many were removed
+func (p *PostgreSQLDatabase) DeleteExecutionLogsOlderThan(cutoff time.Time) (int64, error) {
+	result, err := p.db.Exec("DELETE FROM execution_log WHERE executed_at < $1", cutoff)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Strategy usage
+func (p *PostgreSQLDatabase) LoadStrategyUsage() (map[string]StrategyUsage, error) {
+	query := `
+		SELECT s.id, COALESCE(t.usage_count, 0), e.last_executed_at
+		FROM strategies s
+		LEFT JOIN (
+			SELECT strategy_id, COUNT(*) AS usage_count FROM topics GROUP BY strategy_id
+		) t ON t.strategy_id = s.id
+		LEFT JOIN (
+			SELECT strategy_id, MAX(executed_at) AS last_executed_at FROM execution_log GROUP BY strategy_id
+		) e ON e.strategy_id = s.id
+	`
+
+	rows, err := p.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query strategy usage: %w", err)
+	}
+	defer rows.Close()
+
+	usage := make(map[string]StrategyUsage)
+	for rows.Next() {
+		var strategyID string
+		var entry StrategyUsage
+		var lastExecutedAt sql.NullTime
+		if err := rows.Scan(&strategyID, &entry.UsageCount, &lastExecutedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan strategy usage: %w", err)
+		}
+		if lastExecutedAt.Valid {
+			entry.LastExecutedAt = &lastExecutedAt.Time
+		}
+		usage[strategyID] = entry
+	}
+
+	return usage, rows.Err()
+}
+
+// Strategy test fixtures
+func (p *PostgreSQLDatabase) SaveStrategyFixture(fixture StrategyFixture) error {
+	inputsJSON, err := json.Marshal(fixture.Inputs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal fixture inputs: %w", err)
+	}
+	parametersJSON, err := p.params.marshalParameters(fixture.Parameters)
+	if err != nil {
+		return err
+	}
+	expectedJSON, err := json.Marshal(fixture.Expected)
+	if err != nil {
+		return fmt.Errorf("failed to marshal fixture expected output: %w", err)
+	}
+
+	query := `
+		INSERT INTO strategy_fixtures (strategy_id, name, inputs, parameters, trigger_topic, expected, fixed_time, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (strategy_id, name) DO UPDATE SET
+			inputs = EXCLUDED.inputs,
+			parameters = EXCLUDED.parameters,
+			trigger_topic = EXCLUDED.trigger_topic,
+			expected = EXCLUDED.expected,
+			fixed_time = EXCLUDED.fixed_time,
+			updated_at = EXCLUDED.updated_at
+	`
+	_, err = p.db.Exec(query, fixture.StrategyID, fixture.Name, string(inputsJSON), parametersJSON,
+		fixture.TriggerTopic, string(expectedJSON), fixtureTime(fixture.Now), fixture.CreatedAt.UTC(), fixture.UpdatedAt.UTC())
+	return err
+}
+
+func (p *PostgreSQLDatabase) LoadStrategyFixtures(strategyID string) ([]StrategyFixture, error) {
+	query := `
+		SELECT strategy_id, name, inputs, parameters, trigger_topic, expected, fixed_time, created_at, updated_at
+		FROM strategy_fixtures
+		WHERE strategy_id = $1
+		ORDER BY name
+	`
+
+	rows, err := p.db.Query(query, strategyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query strategy fixtures: %w", err)
+	}
+	defer rows.Close()
+
+	var fixtures []StrategyFixture
+	for rows.Next() {
+		var fixture StrategyFixture
+		var inputsJSON, parametersJSON, triggerTopic, expectedJSON sql.NullString
+		var fixedTime sql.NullTime
+
+		if err := rows.Scan(&fixture.StrategyID, &fixture.Name, &inputsJSON, &parametersJSON, &triggerTopic,
+			&expectedJSON, &fixedTime, &fixture.CreatedAt, &fixture.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan strategy fixture: %w", err)
+		}
+		if err := unmarshalFixture(&fixture, inputsJSON, triggerTopic, expectedJSON, fixedTime); err != nil {
+			return nil, err
+		}
+		if parametersJSON.Valid && parametersJSON.String != "" {
+			if err := p.params.unmarshalParameters(parametersJSON.String, &fixture.Parameters); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal fixture parameters: %w", err)
+			}
+		}
+
+		fixtures = append(fixtures, fixture)
+	}
+
+	return fixtures, rows.Err()
+}
+
+func (p *PostgreSQLDatabase) DeleteStrategyFixture(strategyID, name string) error {
+	_, err := p.db.Exec("DELETE FROM strategy_fixtures WHERE strategy_id = $1 AND name = $2", strategyID, name)
+	return err
+}
+
+// Topic history
+func (p *PostgreSQLDatabase) SaveTopicHistory(topicName string, value interface{}, recordedAt time.Time) error {
+	valueJSON, err := topics.CanonicalJSON(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history value: %w", err)
+	}
+
+	query := "INSERT INTO topic_history (topic_name, value, recorded_at) VALUES ($1, $2, $3)"
+	_, err = p.db.Exec(query, topicName, string(valueJSON), recordedAt.UTC())
+	return err
+}
+
+func (p *PostgreSQLDatabase) LoadTopicHistory(topicName string, from, to time.Time) ([]TopicHistoryEntry, error) {
+	query := `
+		SELECT id, topic_name, value, recorded_at
+		FROM topic_history
+		WHERE topic_name = $1 AND recorded_at >= $2 AND recorded_at <= $3
+		ORDER BY recorded_at ASC, id ASC
+	`
+
+	rows, err := p.db.Query(query, topicName, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query topic history: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []TopicHistoryEntry
+	for rows.Next() {
+		var entry TopicHistoryEntry
+		var valueJSON string
+
+		if err := rows.Scan(&entry.ID, &entry.TopicName, &valueJSON, &entry.RecordedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan topic history: %w", err)
+		}
+
+		if err := json.Unmarshal([]byte(valueJSON), &entry.Value); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal history value: %w", err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+func (p *PostgreSQLDatabase) DeleteTopicHistoryBefore(cutoff time.Time) (int64, error) {
+	result, err := p.db.Exec("DELETE FROM topic_history WHERE recorded_at < $1", cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// System event log
+func (p *PostgreSQLDatabase) SaveSystemEvent(event SystemEvent) error {
+	dataJSON, err := json.Marshal(event.Data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal system event data: %w", err)
+	}
+
+	query := "INSERT INTO system_events (event_type, data, occurred_at) VALUES ($1, $2, $3)"
+	_, err = p.db.Exec(query, event.Type, string(dataJSON), event.OccurredAt.UTC())
+	return err
+}
+
+func (p *PostgreSQLDatabase) LoadSystemEvents(query SystemEventQuery) ([]SystemEvent, error) {
+	sqlQuery := "SELECT id, event_type, data, occurred_at FROM system_events WHERE 1=1"
+	var args []interface{}
+	if query.Type != "" {
+		args = append(args, query.Type)
+		sqlQuery += fmt.Sprintf(" AND event_type = $%d", len(args))
+	}
+	if !query.From.IsZero() {
+		args = append(args, query.From.UTC())
+		sqlQuery += fmt.Sprintf(" AND occurred_at >= $%d", len(args))
+	}
+	if !query.To.IsZero() {
+		args = append(args, query.To.UTC())
+		sqlQuery += fmt.Sprintf(" AND occurred_at <= $%d", len(args))
+	}
+	sqlQuery += " ORDER BY occurred_at DESC, id DESC"
+	if query.Limit > 0 {
+		args = append(args, query.Limit)
+		sqlQuery += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := p.db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query system events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []SystemEvent
+	for rows.Next() {
+		var event SystemEvent
+		var dataJSON sql.NullString
+
+		if err := rows.Scan(&event.ID, &event.Type, &dataJSON, &event.OccurredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan system event: %w", err)
+		}
+
+		if dataJSON.Valid {
+			if err := json.Unmarshal([]byte(dataJSON.String), &event.Data); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal system event data: %w", err)
+			}
+		}
+
+		events = append(events, event)
 	}
 
-	return logs, rows.Err()
+	return events, rows.Err()
+}
+
+func (p *PostgreSQLDatabase) DeleteSystemEventsBefore(cutoff time.Time) (int64, error) {
+	result, err := p.db.Exec("DELETE FROM system_events WHERE occurred_at < $1", cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
@@ -0,0 +1,49 @@
+package state
+
+import (
+	"fmt"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/config"
+)
+
+// openReplica connects to the read replica configured for a Postgres
+// database. A replica that cannot be reached is logged and skipped so the
+// primary serves every read.
+func (m *Manager) openReplica(cfg config.DatabaseConfig) error {
+	if cfg.ReadReplica == "" {
+		return nil
+	}
+
+	replica, err := NewPostgreSQLDatabase(cfg.ReadReplica)
+	if err != nil {
+		m.logger.Printf("Warning: read replica unavailable, reading from the primary: %v", err)
+		return nil
+	}
+
+	if cfg.EncryptionKey != "" {
+		if err := replica.SetEncryptionKey(cfg.EncryptionKey); err != nil {
+			replica.Close()
+			return fmt.Errorf("failed to configure read replica parameter encryption: %w", err)
+		}
+	}
+
+	m.replica = replica
+	return nil
+}
+
+// readFrom runs a read-only query on the read replica, falling back to the
+// primary when there is no replica or the replica query fails (including
+// lookups of rows the replica has not caught up with yet)
+func readFrom[T any](m *Manager, read func(db Database) (T, error)) (T, error) {
+	if m.replica != nil {
+		if value, err := read(m.replica); err == nil {
+			return value, nil
+		}
+	}
+	return read(m.db)
+}
+
+// HasReadReplica reports whether reads are routed to a read replica
+func (m *Manager) HasReadReplica() bool {
+	return m.replica != nil
+}
@@ -0,0 +1,124 @@
+package state
+
+import (
+	"errors"
+	"log"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/strategy"
+)
+
+// callRecordingDatabase records the calls made to the wrapped database and
+// optionally fails every read
+type callRecordingDatabase struct {
+	Database
+
+	mutex     sync.Mutex
+	calls     []string
+	failReads bool
+}
+
+func (c *callRecordingDatabase) record(call string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.calls = append(c.calls, call)
+	if c.failReads {
+		return errors.New("replica unavailable")
+	}
+	return nil
+}
+
+func (c *callRecordingDatabase) recorded() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]string{}, c.calls...)
+}
+
+func (c *callRecordingDatabase) LoadState(key string) (interface{}, error) {
+	if err := c.record("LoadState"); err != nil {
+		return nil, err
+	}
+	return c.Database.LoadState(key)
+}
+
+func (c *callRecordingDatabase) LoadAllStrategies() ([]*strategy.Strategy, error) {
+	if err := c.record("LoadAllStrategies"); err != nil {
+		return nil, err
+	}
+	return c.Database.LoadAllStrategies()
+}
+
+func (c *callRecordingDatabase) SaveState(key string, value interface{}) error {
+	c.mutex.Lock()
+	c.calls = append(c.calls, "SaveState")
+	c.mutex.Unlock()
+	return c.Database.SaveState(key, value)
+}
+
+func newReplicaManager(t *testing.T, failReads bool) (*Manager, *callRecordingDatabase, *callRecordingDatabase) {
+	t.Helper()
+
+	primary := &callRecordingDatabase{Database: setupTestSQLite(t)}
+	replica := &callRecordingDatabase{Database: setupTestSQLite(t), failReads: failReads}
+	// The replica has already replicated this state
+	if err := replica.Database.SaveState("mode", "away"); err != nil {
+		t.Fatalf("SaveState failed: %v", err)
+	}
+	if err := primary.Database.SaveState("mode", "away"); err != nil {
+		t.Fatalf("SaveState failed: %v", err)
+	}
+
+	manager := &Manager{db: primary, replica: replica, logger: log.New(os.Stderr, "", 0)}
+	return manager, primary, replica
+}
+
+func TestManager_ReadReplica(t *testing.T) {
+	manager, primary, replica := newReplicaManager(t, false)
+
+	if err := manager.SaveState("mode", "home"); err != nil {
+		t.Fatalf("SaveState failed: %v", err)
+	}
+	value, err := manager.LoadState("mode")
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	if value != "away" {
+		t.Errorf("LoadState() = %v, want the replica's value away", value)
+	}
+	if _, err := manager.LoadAllStrategies(); err != nil {
+		t.Fatalf("LoadAllStrategies failed: %v", err)
+	}
+
+	// Startup loads and list rebuilds after writes must not see a stale replica
+	if got, want := primary.recorded(), []string{"SaveState", "LoadAllStrategies"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("primary calls = %v, want %v", got, want)
+	}
+	if got, want := replica.recorded(), []string{"LoadState"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("replica calls = %v, want %v", got, want)
+	}
+}
+
+func TestManager_ReadReplicaFallback(t *testing.T) {
+	manager, primary, replica := newReplicaManager(t, true)
+
+	if err := manager.SaveState("mode", "home"); err != nil {
+		t.Fatalf("SaveState failed: %v", err)
+	}
+	value, err := manager.LoadState("mode")
+	if err != nil {
+		t.Fatalf("LoadState failed: %v", err)
+	}
+	if value != "home" {
+		t.Errorf("LoadState() = %v, want the primary's value home", value)
+	}
+
+	if got, want := primary.recorded(), []string{"SaveState", "LoadState"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("primary calls = %v, want %v", got, want)
+	}
+	if got, want := replica.recorded(), []string{"LoadState"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("replica calls = %v, want %v", got, want)
+	}
+}
@@ -4,30 +4,51 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/denwilliams/go-mqtt-automation/pkg/config"
 	"github.com/denwilliams/go-mqtt-automation/pkg/strategy"
 	"github.com/denwilliams/go-mqtt-automation/pkg/topics"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/sqlite3"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
-	_ "github.com/mattn/go-sqlite3"
+	sqlite3driver "github.com/mattn/go-sqlite3"
 )
 
+// defaultSQLiteBusyTimeout is used when no busy_timeout is configured
+const defaultSQLiteBusyTimeout = 5000
+
 type SQLiteDatabase struct {
-	db   *sql.DB
-	path string
+	db     *sql.DB
+	path   string
+	dsn    string
+	params *parameterCipher
+}
+
+// SetEncryptionKey encrypts topic and strategy parameters at rest with a key
+// derived from secret. Existing plaintext parameters are still readable.
+func (s *SQLiteDatabase) SetEncryptionKey(secret string) error {
+	params, err := newParameterCipher(secret)
+	if err != nil {
+		return err
+	}
+	s.params = params
+	return nil
 }
 
-func NewSQLiteDatabase(dbPath string) (*SQLiteDatabase, error) {
+func NewSQLiteDatabase(dbPath string, sqliteConfig config.SQLiteConfig) (*SQLiteDatabase, error) {
 	// Ensure directory exists
 	if err := os.MkdirAll(filepath.Dir(dbPath), 0755); err != nil {
 		return nil, fmt.Errorf("failed to create database directory: %w", err)
 	}
 
-	db, err := sql.Open("sqlite3", dbPath+"?_foreign_keys=on&_journal_mode=WAL")
+	dsn := sqliteDSN(dbPath, sqliteConfig)
+	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -41,40 +62,76 @@ func NewSQLiteDatabase(dbPath string) (*SQLiteDatabase, error) {
 	sqliteDB := &SQLiteDatabase{
 		db:   db,
 		path: dbPath,
+		dsn:  dsn,
 	}
 
 	return sqliteDB, nil
 }
 
-func (s *SQLiteDatabase) Migrate() error {
-	// Create a separate database connection for migrations to avoid connection interference
-	migrationDB, err := sql.Open("sqlite3", s.path+"?_foreign_keys=on&_journal_mode=WAL")
+// sqliteDSN builds the connection string so the pragmas apply to every
+// pooled connection. Configured pragmas override the defaults.
+func sqliteDSN(dbPath string, sqliteConfig config.SQLiteConfig) string {
+	busyTimeout := sqliteConfig.BusyTimeout
+	if busyTimeout == 0 {
+		busyTimeout = defaultSQLiteBusyTimeout
+	}
+
+	params := url.Values{}
+	params.Set("_foreign_keys", "on")
+	params.Set("_journal_mode", "WAL")
+	params.Set("_busy_timeout", strconv.Itoa(busyTimeout))
+	for name, value := range sqliteConfig.Pragmas {
+		params.Set("_"+name, value)
+	}
+
+	return dbPath + "?" + params.Encode()
+}
+
+// newMigrate creates a migrate instance on a separate database connection to
+// avoid interfering with the main connection. Closing it closes the connection.
+func (s *SQLiteDatabase) newMigrate() (*migrate.Migrate, error) {
+	migrationDB, err := sql.Open("sqlite3", s.dsn)
 	if err != nil {
-		return fmt.Errorf("failed to open migration database: %w", err)
+		return nil, fmt.Errorf("failed to open migration database: %w", err)
 	}
-	defer migrationDB.Close()
 
 	// Create sqlite3 driver instance
 	driver, err := sqlite3.WithInstance(migrationDB, &sqlite3.Config{})
 	if err != nil {
-		return fmt.Errorf("failed to create sqlite3 driver: %w", err)
+		migrationDB.Close()
+		return nil, fmt.Errorf("failed to create sqlite3 driver: %w", err)
 	}
 
 	// Create migrate instance
 	m, err := migrate.NewWithDatabaseInstance(
-		"file://db/migrations/sqlite",
+		"file://"+filepath.Join(MigrationsDir, "sqlite"),
 		"sqlite3", driver)
 	if err != nil {
-		return fmt.Errorf("failed to create migrate instance: %w", err)
+		migrationDB.Close()
+		return nil, fmt.Errorf("failed to create migrate instance: %w", err)
 	}
-	defer m.Close()
 
-	// Run migrations
-	if err := m.Up(); err != nil && err != migrate.ErrNoChange {
-		return fmt.Errorf("failed to run migrations: %w", err)
-	}
+	return m, nil
+}
 
-	return nil
+func (s *SQLiteDatabase) Migrate() error {
+	return migrateUp(s.newMigrate)
+}
+
+func (s *SQLiteDatabase) MigrateDown() error {
+	return migrateDown(s.newMigrate)
+}
+
+func (s *SQLiteDatabase) MigrateTo(version uint) error {
+	return migrateTo(s.newMigrate, version)
+}
+
+func (s *SQLiteDatabase) SchemaVersion() (uint, bool, error) {
+	return schemaVersion(s.newMigrate)
+}
+
+func (s *SQLiteDatabase) ExpectedSchemaVersion() (uint, error) {
+	return highestMigrationVersion(filepath.Join(MigrationsDir, "sqlite"))
 }
 
 // Migration helper methods removed - now handled by golang-migrate
@@ -103,7 +160,7 @@ func (s *SQLiteDatabase) saveBaseTopic(config topics.BaseTopicConfig) error {
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	valueJSON, err := json.Marshal(config.LastValue)
+	valueJSON, err := topics.CanonicalJSON(config.LastValue)
 	if err != nil {
 		return fmt.Errorf("failed to marshal last value: %w", err)
 	}
@@ -137,7 +194,7 @@ func (s *SQLiteDatabase) saveInternalTopic(config topics.InternalTopicConfig) er
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	valueJSON, err := json.Marshal(config.LastValue)
+	valueJSON, err := topics.CanonicalJSON(config.LastValue)
 	if err != nil {
 		return fmt.Errorf("failed to marshal last value: %w", err)
 	}
@@ -152,9 +209,9 @@ func (s *SQLiteDatabase) saveInternalTopic(config topics.InternalTopicConfig) er
 		return fmt.Errorf("failed to marshal input names: %w", err)
 	}
 
-	parametersJSON, err := json.Marshal(config.Parameters)
+	parametersJSON, err := s.params.marshalParameters(config.Parameters)
 	if err != nil {
-		return fmt.Errorf("failed to marshal parameters: %w", err)
+		return err
 	}
 
 	tagsJSON, err := json.Marshal(config.Tags)
@@ -173,7 +230,7 @@ func (s *SQLiteDatabase) saveInternalTopic(config topics.InternalTopicConfig) er
 		string(inputsJSON),
 		string(inputNamesJSON),
 		config.StrategyID,
-		string(parametersJSON),
+		parametersJSON,
 		config.EmitToMQTT,
 		config.NoOpUnchanged,
 		string(valueJSON),
@@ -192,7 +249,7 @@ func (s *SQLiteDatabase) saveSystemTopic(config topics.SystemTopicConfig) error
 		return fmt.Errorf("failed to marshal config: %w", err)
 	}
 
-	valueJSON, err := json.Marshal(config.LastValue)
+	valueJSON, err := topics.CanonicalJSON(config.LastValue)
 	if err != nil {
 		return fmt.Errorf("failed to marshal last value: %w", err)
 	}
@@ -348,7 +405,7 @@ func (s *SQLiteDatabase) buildTopicConfig(name, topicType string, inputs, inputN
 
 		var parsedParameters map[string]interface{}
 		if parameters.Valid && parameters.String != "" {
-			if err := json.Unmarshal([]byte(parameters.String), &parsedParameters); err != nil {
+			if err := s.params.unmarshalParameters(parameters.String, &parsedParameters); err != nil {
 				return nil, fmt.Errorf("failed to unmarshal parameters: %w", err)
 			}
 		}
@@ -379,12 +436,16 @@ func (s *SQLiteDatabase) buildTopicConfig(name, topicType string, inputs, inputN
 }
 
 func (s *SQLiteDatabase) DeleteTopic(name string) error {
+	// Execution logs reference the topic
+	if _, err := s.db.Exec("DELETE FROM execution_log WHERE topic_name = ?", name); err != nil {
+		return fmt.Errorf("failed to delete execution logs: %w", err)
+	}
 	_, err := s.db.Exec("DELETE FROM topics WHERE name = ?", name)
 	return err
 }
 
 func (s *SQLiteDatabase) UpdateTopicLastValue(topicName string, value interface{}) error {
-	valueJSON, err := json.Marshal(value)
+	valueJSON, err := topics.CanonicalJSON(value)
 	if err != nil {
 		return fmt.Errorf("failed to marshal value: %w", err)
 	}
@@ -396,14 +457,19 @@ func (s *SQLiteDatabase) UpdateTopicLastValue(topicName string, value interface{
 
 // Strategies
 func (s *SQLiteDatabase) SaveStrategy(strategy *strategy.Strategy) error {
-	parametersJSON, err := json.Marshal(strategy.Parameters)
+	parametersJSON, err := s.params.marshalParameters(strategy.Parameters)
+	if err != nil {
+		return err
+	}
+
+	allowedInputPatternsJSON, err := marshalAllowedInputPatterns(strategy.AllowedInputPatterns)
 	if err != nil {
-		return fmt.Errorf("failed to marshal parameters: %w", err)
+		return err
 	}
 
 	query := `
-		INSERT OR REPLACE INTO strategies (id, name, description, code, language, parameters, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		INSERT OR REPLACE INTO strategies (id, name, description, code, language, parameters, allowed_input_patterns, library, log_level, file_path, timeout_ms, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	_, err = s.db.Exec(query,
@@ -412,7 +478,12 @@ func (s *SQLiteDatabase) SaveStrategy(strategy *strategy.Strategy) error {
 		strategy.Description,
 		strategy.Code,
 		strategy.Language,
-		string(parametersJSON),
+		parametersJSON,
+		allowedInputPatternsJSON,
+		strategy.Library,
+		string(strategy.LogLevel),
+		strategy.FilePath,
+		nullableTimeout(strategy.TimeoutMs),
 		strategy.CreatedAt,
 		strategy.UpdatedAt,
 	)
@@ -422,7 +493,7 @@ func (s *SQLiteDatabase) SaveStrategy(strategy *strategy.Strategy) error {
 
 func (s *SQLiteDatabase) LoadStrategy(id string) (*strategy.Strategy, error) {
 	query := `
-		SELECT id, name, description, code, language, builtin, parameters, max_inputs, default_input_names, created_at, updated_at
+		SELECT id, name, description, code, language, builtin, parameters, max_inputs, default_input_names, allowed_input_patterns, library, log_level, file_path, timeout_ms, created_at, updated_at
 		FROM strategies WHERE id = ?
 	`
 
@@ -432,9 +503,14 @@ func (s *SQLiteDatabase) LoadStrategy(id string) (*strategy.Strategy, error) {
 	var parametersJSON sql.NullString
 	var maxInputs sql.NullInt64
 	var defaultInputNamesJSON sql.NullString
+	var allowedInputPatternsJSON sql.NullString
+	var library sql.NullBool
+	var logLevel sql.NullString
+	var filePath sql.NullString
+	var timeoutMs sql.NullInt64
 
 	err := row.Scan(&strat.ID, &strat.Name, &strat.Description, &strat.Code, &strat.Language, &strat.Builtin,
-		&parametersJSON, &maxInputs, &defaultInputNamesJSON, &strat.CreatedAt, &strat.UpdatedAt)
+		&parametersJSON, &maxInputs, &defaultInputNamesJSON, &allowedInputPatternsJSON, &library, &logLevel, &filePath, &timeoutMs, &strat.CreatedAt, &strat.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("strategy not found: %s", id)
@@ -443,7 +519,7 @@ func (s *SQLiteDatabase) LoadStrategy(id string) (*strategy.Strategy, error) {
 	}
 
 	if parametersJSON.Valid && parametersJSON.String != "" {
-		if err := json.Unmarshal([]byte(parametersJSON.String), &strat.Parameters); err != nil {
+		if err := s.params.unmarshalParameters(parametersJSON.String, &strat.Parameters); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal parameters: %w", err)
 		}
 	}
@@ -460,12 +536,22 @@ func (s *SQLiteDatabase) LoadStrategy(id string) (*strategy.Strategy, error) {
 		}
 	}
 
+	if allowedInputPatternsJSON.Valid && allowedInputPatternsJSON.String != "" {
+		if err := json.Unmarshal([]byte(allowedInputPatternsJSON.String), &strat.AllowedInputPatterns); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal allowed_input_patterns: %w", err)
+		}
+	}
+	strat.Library = library.Bool
+	strat.LogLevel = strategy.LogLevel(logLevel.String)
+	strat.FilePath = filePath.String
+	strat.TimeoutMs = int(timeoutMs.Int64)
+
 	return &strat, nil
 }
 
 func (s *SQLiteDatabase) LoadAllStrategies() ([]*strategy.Strategy, error) {
 	query := `
-		SELECT id, name, description, code, language, builtin, parameters, max_inputs, default_input_names, created_at, updated_at
+		SELECT id, name, description, code, language, builtin, parameters, max_inputs, default_input_names, allowed_input_patterns, library, log_level, file_path, timeout_ms, created_at, updated_at
 		FROM strategies ORDER BY name
 	`
 
@@ -482,15 +568,20 @@ func (s *SQLiteDatabase) LoadAllStrategies() ([]*strategy.Strategy, error) {
 		var parametersJSON sql.NullString
 		var maxInputs sql.NullInt64
 		var defaultInputNamesJSON sql.NullString
+		var allowedInputPatternsJSON sql.NullString
+		var library sql.NullBool
+		var logLevel sql.NullString
+		var filePath sql.NullString
+		var timeoutMs sql.NullInt64
 
 		err := rows.Scan(&strat.ID, &strat.Name, &strat.Description, &strat.Code, &strat.Language, &strat.Builtin,
-			&parametersJSON, &maxInputs, &defaultInputNamesJSON, &strat.CreatedAt, &strat.UpdatedAt)
+			&parametersJSON, &maxInputs, &defaultInputNamesJSON, &allowedInputPatternsJSON, &library, &logLevel, &filePath, &timeoutMs, &strat.CreatedAt, &strat.UpdatedAt)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan strategy row: %w", err)
 		}
 
 		if parametersJSON.Valid && parametersJSON.String != "" {
-			if err := json.Unmarshal([]byte(parametersJSON.String), &strat.Parameters); err != nil {
+			if err := s.params.unmarshalParameters(parametersJSON.String, &strat.Parameters); err != nil {
 				return nil, fmt.Errorf("failed to unmarshal parameters: %w", err)
 			}
 		}
@@ -507,6 +598,16 @@ func (s *SQLiteDatabase) LoadAllStrategies() ([]*strategy.Strategy, error) {
 			}
 		}
 
+		if allowedInputPatternsJSON.Valid && allowedInputPatternsJSON.String != "" {
+			if err := json.Unmarshal([]byte(allowedInputPatternsJSON.String), &strat.AllowedInputPatterns); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal allowed_input_patterns: %w", err)
+			}
+		}
+		strat.Library = library.Bool
+		strat.LogLevel = strategy.LogLevel(logLevel.String)
+		strat.FilePath = filePath.String
+		strat.TimeoutMs = int(timeoutMs.Int64)
+
 		strategies = append(strategies, &strat)
 	}
 
@@ -514,13 +615,20 @@ func (s *SQLiteDatabase) LoadAllStrategies() ([]*strategy.Strategy, error) {
 }
 
 func (s *SQLiteDatabase) DeleteStrategy(id string) error {
+	// Execution logs and fixtures reference the strategy
+	if _, err := s.db.Exec("DELETE FROM execution_log WHERE strategy_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete execution logs: %w", err)
+	}
+	if _, err := s.db.Exec("DELETE FROM strategy_fixtures WHERE strategy_id = ?", id); err != nil {
+		return fmt.Errorf("failed to delete strategy fixtures: %w", err)
+	}
 	_, err := s.db.Exec("DELETE FROM strategies WHERE id = ?", id)
 	return err
 }
 
 // State
 func (s *SQLiteDatabase) SaveState(key string, value interface{}) error {
-	valueJSON, err := json.Marshal(value)
+	valueJSON, err := topics.CanonicalJSON(value)
 	if err != nil {
 		return fmt.Errorf("failed to marshal value: %w", err)
 	}
@@ -541,7 +649,7 @@ func (s *SQLiteDatabase) LoadState(key string) (interface{}, error) {
 	err := s.db.QueryRow(query, key).Scan(&valueJSON)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("state key not found: %s", key)
+			return nil, fmt.Errorf("%w: %s", ErrStateNotFound, key)
 		}
 		return nil, fmt.Errorf("failed to scan state: %w", err)
 	}
@@ -603,10 +711,20 @@ func (s *SQLiteDatabase) SaveExecutionLog(log ExecutionLog) error {
 		return fmt.Errorf("failed to marshal output values: %w", err)
 	}
 
+	logMessagesJSON, err := marshalLogMessages(log.LogMessages)
+	if err != nil {
+		return err
+	}
+
+	outputDiffJSON, err := marshalOutputDiff(log.OutputDiff)
+	if err != nil {
+		return err
+	}
+
 	query := `
 		INSERT INTO execution_log (topic_name, strategy_id, trigger_topic, input_values, 
-		                          output_values, error_message, execution_time_ms, executed_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		                          output_values, error_message, execution_time_ms, log_messages, executed_at, output_diff, chain_hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	_, err = s.db.Exec(query,
@@ -617,50 +735,368 @@ func (s *SQLiteDatabase) SaveExecutionLog(log ExecutionLog) error {
 		string(outputJSON),
 		log.ErrorMessage,
 		log.ExecutionTimeMs,
+		logMessagesJSON,
 		log.ExecutedAt,
+		outputDiffJSON,
+		nullString(log.ChainHash),
 	)
 
 	return err
 }
 
 func (s *SQLiteDatabase) LoadExecutionLogs(topicName string, limit int) ([]ExecutionLog, error) {
+	var logs []ExecutionLog
+	err := s.EachExecutionLog(topicName, limit, func(log ExecutionLog) error {
+		logs = append(logs, log)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+func (s *SQLiteDatabase) EachExecutionLog(topicName string, limit int, fn func(ExecutionLog) error) error {
+	return s.eachExecutionLog("executed_at DESC", topicName, limit, fn)
+}
+
+// EachExecutionLogByID calls fn for a topic's execution logs in reverse
+// insertion order
+func (s *SQLiteDatabase) EachExecutionLogByID(topicName string, fn func(ExecutionLog) error) error {
+	return s.eachExecutionLog("id DESC", topicName, 0, fn)
+}
+
+func (s *SQLiteDatabase) eachExecutionLog(orderBy, topicName string, limit int, fn func(ExecutionLog) error) error {
 	query := `
 		SELECT id, topic_name, strategy_id, trigger_topic, input_values, 
-		       output_values, error_message, execution_time_ms, executed_at
+		       output_values, error_message, execution_time_ms, log_messages, executed_at, output_diff, chain_hash
 		FROM execution_log 
 		WHERE topic_name = ? 
-		ORDER BY executed_at DESC 
+		ORDER BY ` + orderBy + ` 
 		LIMIT ?
 	`
 
+	if limit <= 0 {
+		limit = -1 // SQLite treats a negative limit as unlimited
+	}
+
 	rows, err := s.db.Query(query, topicName, limit)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query execution logs: %w", err)
+		return fmt.Errorf("failed to query execution logs: %w", err)
 	}
 	defer rows.Close()
 
-	var logs []ExecutionLog
-
 	for rows.Next() {
 		var log ExecutionLog
 		var inputJSON, outputJSON string
+		var logMessagesJSON, outputDiffJSON, chainHash sql.NullString
 
 		err := rows.Scan(&log.ID, &log.TopicName, &log.StrategyID, &log.TriggerTopic,
-			&inputJSON, &outputJSON, &log.ErrorMessage, &log.ExecutionTimeMs, &log.ExecutedAt)
+			&inputJSON, &outputJSON, &log.ErrorMessage, &log.ExecutionTimeMs, &logMessagesJSON, &log.ExecutedAt, &outputDiffJSON, &chainHash)
 		if err != nil {
-			return nil, fmt.Errorf("failed to scan execution log row: %w", err)
+			return fmt.Errorf("failed to scan execution log row: %w", err)
 		}
 
 		if err := json.Unmarshal([]byte(inputJSON), &log.InputValues); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal input values: %w", err)
+			return fmt.Errorf("failed to unmarshal input values: %w", err)
 		}
 
 		if err := json.Unmarshal([]byte(outputJSON), &log.OutputValues); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal output values: %w", err)
+			return fmt.Errorf("failed to unmarshal output values: %w", err)
 		}
 
-		logs = append(logs, log)
+		if log.LogMessages, err = unmarshalLogMessages(logMessagesJSON); err != nil {
+			return err
+		}
+
+		if log.OutputDiff, err = unmarshalOutputDiff(outputDiffJSON); err != nil {
+			return err
+		}
+		log.ChainHash = chainHash.String
+
+		if err := fn(log); err != nil {
+			return err
+		}
 	}
 
-	return logs, nil
+	return rows.Err()
+}
+
+// Execution log retention
+
+// DeleteExecutionLogsOlderThan removes execution logs recorded before cutoff,
+// returning how many were removed. Times are compared with julianday because
+// they are stored with the offset of the zone they were recorded in.
+func (s *SQLiteDatabase) DeleteExecutionLogsOlderThan(cutoff time.Time) (int64, error) {
+	result, err := s.db.Exec("DELETE FROM execution_log WHERE julianday(executed_at) < julianday(?)", cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// Strategy usage
+func (s *SQLiteDatabase) LoadStrategyUsage() (map[string]StrategyUsage, error) {
+	usage := make(map[string]StrategyUsage)
+
+	rows, err := s.db.Query(`
+		SELECT strategy_id, COUNT(*)
+		FROM topics
+		WHERE strategy_id IS NOT NULL AND strategy_id != ''
+		GROUP BY strategy_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query strategy usage counts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var strategyID string
+		var count int
+		if err := rows.Scan(&strategyID, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan strategy usage count: %w", err)
+		}
+		entry := usage[strategyID]
+		entry.UsageCount = count
+		usage[strategyID] = entry
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read strategy usage counts: %w", err)
+	}
+
+	// SQLite returns aggregated timestamps as text, so they are parsed here
+	execRows, err := s.db.Query(`
+		SELECT strategy_id, MAX(executed_at)
+		FROM execution_log
+		WHERE strategy_id IS NOT NULL AND strategy_id != ''
+		GROUP BY strategy_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query strategy executions: %w", err)
+	}
+	defer execRows.Close()
+
+	for execRows.Next() {
+		var strategyID string
+		var executedAt sql.NullString
+		if err := execRows.Scan(&strategyID, &executedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan strategy execution: %w", err)
+		}
+		if !executedAt.Valid {
+			continue
+		}
+		lastExecutedAt, err := parseSQLiteTime(executedAt.String)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse last execution of strategy %s: %w", strategyID, err)
+		}
+		entry := usage[strategyID]
+		entry.LastExecutedAt = &lastExecutedAt
+		usage[strategyID] = entry
+	}
+	if err := execRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read strategy executions: %w", err)
+	}
+
+	return usage, nil
+}
+
+// parseSQLiteTime parses a timestamp in one of the formats written by the sqlite3 driver
+func parseSQLiteTime(value string) (time.Time, error) {
+	value = strings.TrimSuffix(value, "Z")
+	for _, format := range sqlite3driver.SQLiteTimestampFormats {
+		if t, err := time.ParseInLocation(format, value, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised timestamp %q", value)
+}
+
+// Strategy test fixtures
+func (s *SQLiteDatabase) SaveStrategyFixture(fixture StrategyFixture) error {
+	inputsJSON, err := json.Marshal(fixture.Inputs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal fixture inputs: %w", err)
+	}
+	parametersJSON, err := s.params.marshalParameters(fixture.Parameters)
+	if err != nil {
+		return err
+	}
+	expectedJSON, err := json.Marshal(fixture.Expected)
+	if err != nil {
+		return fmt.Errorf("failed to marshal fixture expected output: %w", err)
+	}
+
+	query := `
+		INSERT OR REPLACE INTO strategy_fixtures (strategy_id, name, inputs, parameters, trigger_topic, expected, fixed_time, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`
+	_, err = s.db.Exec(query, fixture.StrategyID, fixture.Name, string(inputsJSON), parametersJSON,
+		fixture.TriggerTopic, string(expectedJSON), fixtureTime(fixture.Now), fixture.CreatedAt.UTC(), fixture.UpdatedAt.UTC())
+	return err
+}
+
+func (s *SQLiteDatabase) LoadStrategyFixtures(strategyID string) ([]StrategyFixture, error) {
+	query := `
+		SELECT strategy_id, name, inputs, parameters, trigger_topic, expected, fixed_time, created_at, updated_at
+		FROM strategy_fixtures
+		WHERE strategy_id = ?
+		ORDER BY name
+	`
+
+	rows, err := s.db.Query(query, strategyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query strategy fixtures: %w", err)
+	}
+	defer rows.Close()
+
+	var fixtures []StrategyFixture
+	for rows.Next() {
+		var fixture StrategyFixture
+		var inputsJSON, parametersJSON, triggerTopic, expectedJSON sql.NullString
+		var fixedTime sql.NullTime
+
+		if err := rows.Scan(&fixture.StrategyID, &fixture.Name, &inputsJSON, &parametersJSON, &triggerTopic,
+			&expectedJSON, &fixedTime, &fixture.CreatedAt, &fixture.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan strategy fixture: %w", err)
+		}
+		if err := unmarshalFixture(&fixture, inputsJSON, triggerTopic, expectedJSON, fixedTime); err != nil {
+			return nil, err
+		}
+		if parametersJSON.Valid && parametersJSON.String != "" {
+			if err := s.params.unmarshalParameters(parametersJSON.String, &fixture.Parameters); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal fixture parameters: %w", err)
+			}
+		}
+
+		fixtures = append(fixtures, fixture)
+	}
+
+	return fixtures, rows.Err()
+}
+
+func (s *SQLiteDatabase) DeleteStrategyFixture(strategyID, name string) error {
+	_, err := s.db.Exec("DELETE FROM strategy_fixtures WHERE strategy_id = ? AND name = ?", strategyID, name)
+	return err
+}
+
+// Topic history
+func (s *SQLiteDatabase) SaveTopicHistory(topicName string, value interface{}, recordedAt time.Time) error {
+	valueJSON, err := topics.CanonicalJSON(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal history value: %w", err)
+	}
+
+	_, err = s.db.Exec("INSERT INTO topic_history (topic_name, value, recorded_at) VALUES (?, ?, ?)",
+		topicName, string(valueJSON), recordedAt.UTC())
+	return err
+}
+
+func (s *SQLiteDatabase) LoadTopicHistory(topicName string, from, to time.Time) ([]TopicHistoryEntry, error) {
+	query := `
+		SELECT id, topic_name, value, recorded_at
+		FROM topic_history
+		WHERE topic_name = ? AND recorded_at >= ? AND recorded_at <= ?
+		ORDER BY recorded_at ASC, id ASC
+	`
+
+	rows, err := s.db.Query(query, topicName, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query topic history: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []TopicHistoryEntry
+
+	for rows.Next() {
+		var entry TopicHistoryEntry
+		var valueJSON string
+
+		if err := rows.Scan(&entry.ID, &entry.TopicName, &valueJSON, &entry.RecordedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan topic history row: %w", err)
+		}
+
+		if err := json.Unmarshal([]byte(valueJSON), &entry.Value); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal history value: %w", err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+func (s *SQLiteDatabase) DeleteTopicHistoryBefore(cutoff time.Time) (int64, error) {
+	result, err := s.db.Exec("DELETE FROM topic_history WHERE recorded_at < ?", cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// System event log
+func (s *SQLiteDatabase) SaveSystemEvent(event SystemEvent) error {
+	dataJSON, err := json.Marshal(event.Data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal system event data: %w", err)
+	}
+
+	_, err = s.db.Exec("INSERT INTO system_events (event_type, data, occurred_at) VALUES (?, ?, ?)",
+		event.Type, string(dataJSON), event.OccurredAt.UTC())
+	return err
+}
+
+func (s *SQLiteDatabase) LoadSystemEvents(query SystemEventQuery) ([]SystemEvent, error) {
+	sqlQuery := "SELECT id, event_type, data, occurred_at FROM system_events WHERE 1=1"
+	var args []interface{}
+	if query.Type != "" {
+		sqlQuery += " AND event_type = ?"
+		args = append(args, query.Type)
+	}
+	if !query.From.IsZero() {
+		sqlQuery += " AND occurred_at >= ?"
+		args = append(args, query.From.UTC())
+	}
+	if !query.To.IsZero() {
+		sqlQuery += " AND occurred_at <= ?"
+		args = append(args, query.To.UTC())
+	}
+	sqlQuery += " ORDER BY occurred_at DESC, id DESC"
+	if query.Limit > 0 {
+		sqlQuery += " LIMIT ?"
+		args = append(args, query.Limit)
+	}
+
+	rows, err := s.db.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query system events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []SystemEvent
+	for rows.Next() {
+		var event SystemEvent
+		var dataJSON sql.NullString
+
+		if err := rows.Scan(&event.ID, &event.Type, &dataJSON, &event.OccurredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan system event row: %w", err)
+		}
+
+		if dataJSON.Valid {
+			if err := json.Unmarshal([]byte(dataJSON.String), &event.Data); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal system event data: %w", err)
+			}
+		}
+
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+func (s *SQLiteDatabase) DeleteSystemEventsBefore(cutoff time.Time) (int64, error) {
+	result, err := s.db.Exec("DELETE FROM system_events WHERE occurred_at < ?", cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
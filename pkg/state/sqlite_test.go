@@ -0,0 +1,746 @@
+package state
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/config"
+	"github.com/denwilliams/go-mqtt-automation/pkg/strategy"
+	"github.com/denwilliams/go-mqtt-automation/pkg/topics"
+)
+
+func init() {
+	// Tests run from the package directory
+	MigrationsDir = "../../db/migrations"
+}
+
+func setupTestSQLite(t *testing.T) *SQLiteDatabase {
+	t.Helper()
+
+	db, err := NewSQLiteDatabase(filepath.Join(t.TempDir(), "test.db"), config.SQLiteConfig{})
+	if err != nil {
+		t.Fatalf("Failed to create SQLite database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Failed to migrate SQLite database: %v", err)
+	}
+
+	return db
+}
+
+func TestSQLiteDatabase_TopicHistory(t *testing.T) {
+	db := setupTestSQLite(t)
+
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	values := []interface{}{20.5, 21.0, map[string]interface{}{"on": true}, "idle"}
+	for i, value := range values {
+		if err := db.SaveTopicHistory("sensors/temp", value, base.Add(time.Duration(i)*time.Minute)); err != nil {
+			t.Fatalf("SaveTopicHistory failed: %v", err)
+		}
+	}
+	if err := db.SaveTopicHistory("sensors/other", 1.0, base); err != nil {
+		t.Fatalf("SaveTopicHistory failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     []interface{}
+	}{
+		{
+			name: "full range",
+			from: base,
+			to:   base.Add(time.Hour),
+			want: values,
+		},
+		{
+			name: "inner range is inclusive",
+			from: base.Add(time.Minute),
+			to:   base.Add(2 * time.Minute),
+			want: values[1:3],
+		},
+		{
+			name: "range before any values",
+			from: base.Add(-time.Hour),
+			to:   base.Add(-time.Minute),
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := db.LoadTopicHistory("sensors/temp", tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("LoadTopicHistory failed: %v", err)
+			}
+
+			if len(entries) != len(tt.want) {
+				t.Fatalf("got %d entries, want %d", len(entries), len(tt.want))
+			}
+
+			for i, entry := range entries {
+				if entry.TopicName != "sensors/temp" {
+					t.Errorf("entry %d topic = %s, want sensors/temp", i, entry.TopicName)
+				}
+				if !reflect.DeepEqual(entry.Value, tt.want[i]) {
+					t.Errorf("entry %d value = %v, want %v", i, entry.Value, tt.want[i])
+				}
+				if i > 0 && entry.RecordedAt.Before(entries[i-1].RecordedAt) {
+					t.Errorf("entries not ordered by time")
+				}
+			}
+		})
+	}
+
+	deleted, err := db.DeleteTopicHistoryBefore(base.Add(2 * time.Minute))
+	if err != nil {
+		t.Fatalf("DeleteTopicHistoryBefore failed: %v", err)
+	}
+	if deleted != 3 {
+		t.Errorf("deleted %d entries, want 3", deleted)
+	}
+
+	entries, err := db.LoadTopicHistory("sensors/temp", base, base.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("LoadTopicHistory failed: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("got %d entries after prune, want 2", len(entries))
+	}
+}
+
+func TestSQLiteDatabase_SystemEvents(t *testing.T) {
+	db := setupTestSQLite(t)
+
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	events := []SystemEvent{
+		{Type: "startup", Data: map[string]interface{}{"version": "1.2.0"}, OccurredAt: base},
+		{Type: "mqtt_connected", Data: map[string]interface{}{"broker": "tcp://localhost:1883"}, OccurredAt: base.Add(time.Minute)},
+		{Type: "error", Data: map[string]interface{}{"error": "boom"}, OccurredAt: base.Add(2 * time.Minute)},
+		{Type: "mqtt_connected", Data: nil, OccurredAt: base.Add(3 * time.Minute)},
+	}
+	for _, event := range events {
+		if err := db.SaveSystemEvent(event); err != nil {
+			t.Fatalf("SaveSystemEvent failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query SystemEventQuery
+		want  []string // event types, newest first
+	}{
+		{name: "all", query: SystemEventQuery{}, want: []string{"mqtt_connected", "error", "mqtt_connected", "startup"}},
+		{name: "by type", query: SystemEventQuery{Type: "mqtt_connected"}, want: []string{"mqtt_connected", "mqtt_connected"}},
+		{name: "time range is inclusive", query: SystemEventQuery{From: base.Add(time.Minute), To: base.Add(2 * time.Minute)}, want: []string{"error", "mqtt_connected"}},
+		{name: "limit", query: SystemEventQuery{Limit: 1}, want: []string{"mqtt_connected"}},
+		{name: "unknown type", query: SystemEventQuery{Type: "shutdown"}, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loaded, err := db.LoadSystemEvents(tt.query)
+			if err != nil {
+				t.Fatalf("LoadSystemEvents failed: %v", err)
+			}
+
+			var types []string
+			for _, event := range loaded {
+				types = append(types, event.Type)
+			}
+			if !reflect.DeepEqual(types, tt.want) {
+				t.Errorf("got events %v, want %v", types, tt.want)
+			}
+		})
+	}
+
+	loaded, err := db.LoadSystemEvents(SystemEventQuery{Type: "startup"})
+	if err != nil {
+		t.Fatalf("LoadSystemEvents failed: %v", err)
+	}
+	if len(loaded) != 1 || !reflect.DeepEqual(loaded[0].Data, events[0].Data) || !loaded[0].OccurredAt.Equal(base) {
+		t.Errorf("startup event = %+v, want data %v at %v", loaded, events[0].Data, base)
+	}
+
+	deleted, err := db.DeleteSystemEventsBefore(base.Add(2 * time.Minute))
+	if err != nil {
+		t.Fatalf("DeleteSystemEventsBefore failed: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("deleted %d events, want 2", deleted)
+	}
+}
+
+func TestManager_CleanupOldLogs(t *testing.T) {
+	now := time.Now()
+	east := time.FixedZone("UTC+10", 10*60*60)
+	west := time.FixedZone("UTC-5", -5*60*60)
+	logs := []ExecutionLog{
+		{TriggerTopic: "30 days", ExecutedAt: now.AddDate(0, 0, -30)},
+		{TriggerTopic: "8 days", ExecutedAt: now.AddDate(0, 0, -8)},
+		// Near the cutoff, recorded in zones whose local times are on the
+		// other side of it
+		{TriggerTopic: "7 days 3 hours", ExecutedAt: now.AddDate(0, 0, -7).Add(-3 * time.Hour).In(east)},
+		{TriggerTopic: "6 days 21 hours", ExecutedAt: now.AddDate(0, 0, -7).Add(3 * time.Hour).In(west)},
+		{TriggerTopic: "1 hour", ExecutedAt: now.Add(-time.Hour)},
+	}
+
+	all := []string{"1 hour", "6 days 21 hours", "7 days 3 hours", "8 days", "30 days"}
+
+	tests := []struct {
+		name      string
+		days      int
+		hashChain bool
+		want      []string // trigger topics of the surviving logs
+		wantErr   bool
+	}{
+		{name: "7 days", days: 7, want: []string{"1 hour", "6 days 21 hours"}},
+		{name: "60 days", days: 60, want: all},
+		{name: "invalid retention", days: 0, want: all, wantErr: true},
+		{name: "audit hash chain", days: 7, hashChain: true, want: all, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := &Manager{db: setupTestSQLite(t), logger: log.New(os.Stderr, "", 0), hashChainEnabled: tt.hashChain}
+			if err := manager.db.SaveTopic(topics.InternalTopicConfig{
+				BaseTopicConfig: topics.BaseTopicConfig{Name: "house/temp", Type: topics.TopicTypeInternal, CreatedAt: now},
+				StrategyID:      "alias",
+			}); err != nil {
+				t.Fatalf("SaveTopic failed: %v", err)
+			}
+			for _, entry := range logs {
+				entry.TopicName = "house/temp"
+				entry.StrategyID = "alias"
+				if err := manager.db.SaveExecutionLog(entry); err != nil {
+					t.Fatalf("SaveExecutionLog failed: %v", err)
+				}
+			}
+
+			err := manager.CleanupOldLogs(tt.days)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CleanupOldLogs error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			remaining, err := manager.db.LoadExecutionLogs("house/temp", 10)
+			if err != nil {
+				t.Fatalf("LoadExecutionLogs failed: %v", err)
+			}
+			var got []string
+			for _, entry := range remaining {
+				got = append(got, entry.TriggerTopic)
+			}
+			sort.Strings(got)
+			want := append([]string(nil), tt.want...)
+			sort.Strings(want)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("remaining logs = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestManager_RecordsTopicHistory(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		want    int
+	}{
+		{name: "history enabled", enabled: true, want: 3},
+		{name: "history disabled", enabled: false, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager, err := NewManager(config.DatabaseConfig{
+				Type:       "sqlite",
+				Connection: filepath.Join(t.TempDir(), "test.db"),
+				History: config.HistoryConfig{
+					Enabled:   tt.enabled,
+					Retention: "1h",
+				},
+			}, nil)
+			if err != nil {
+				t.Fatalf("NewManager failed: %v", err)
+			}
+			defer manager.Close()
+
+			from := time.Now().Add(-time.Minute)
+			for _, value := range []float64{1, 2, 3} {
+				if err := manager.SaveTopicState("external:sensors/temp", value); err != nil {
+					t.Fatalf("SaveTopicState failed: %v", err)
+				}
+			}
+
+			entries, err := manager.LoadTopicHistory("sensors/temp", from, time.Now().Add(time.Minute))
+			if err != nil {
+				t.Fatalf("LoadTopicHistory failed: %v", err)
+			}
+			if len(entries) != tt.want {
+				t.Errorf("got %d history entries, want %d", len(entries), tt.want)
+			}
+		})
+	}
+}
+
+func TestManager_TopicSnapshots(t *testing.T) {
+	manager := &Manager{db: setupTestSQLite(t), logger: log.New(os.Stderr, "", 0)}
+
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	snapshot := topics.TopicSnapshot{
+		LastOutput: map[string]interface{}{"count": 2.0},
+		Values: []topics.SnapshotValue{
+			{Value: 1.0, Timestamp: at},
+			{Value: 2.0, Timestamp: at.Add(time.Second)},
+		},
+	}
+	if err := manager.SaveTopicSnapshot("house/counter", snapshot); err != nil {
+		t.Fatalf("SaveTopicSnapshot failed: %v", err)
+	}
+	if err := manager.SaveTopicState("internal:house/counter", 2.0); err != nil {
+		t.Fatalf("SaveTopicState failed: %v", err)
+	}
+
+	snapshots, err := manager.LoadTopicSnapshots()
+	if err != nil {
+		t.Fatalf("LoadTopicSnapshots failed: %v", err)
+	}
+	if len(snapshots) != 1 {
+		t.Fatalf("got %d snapshots, want 1", len(snapshots))
+	}
+	got := snapshots["house/counter"]
+	if !reflect.DeepEqual(got.LastOutput, snapshot.LastOutput) || len(got.Values) != 2 ||
+		got.Values[1].Value != 2.0 || !got.Values[1].Timestamp.Equal(at.Add(time.Second)) {
+		t.Errorf("LoadTopicSnapshots() = %+v, want %+v", got, snapshot)
+	}
+}
+
+func TestManager_StrategyState(t *testing.T) {
+	manager := &Manager{db: setupTestSQLite(t), logger: log.New(os.Stderr, "", 0)}
+
+	if state, err := manager.LoadStrategyState("counter", "house/a"); err != nil || state != nil {
+		t.Fatalf("LoadStrategyState() = %v, %v; want no state", state, err)
+	}
+
+	want := map[string]interface{}{"count": 2.0, "window": []interface{}{1.0, 2.0}}
+	if err := manager.SaveStrategyState("counter", "house/a", want); err != nil {
+		t.Fatalf("SaveStrategyState failed: %v", err)
+	}
+	if err := manager.SaveStrategyState("counter", "house/b", map[string]interface{}{"count": 7.0}); err != nil {
+		t.Fatalf("SaveStrategyState failed: %v", err)
+	}
+	if state, err := manager.LoadStrategyState("counter", "house/a"); err != nil || !reflect.DeepEqual(state, want) {
+		t.Errorf("LoadStrategyState() = %v, %v; want %v", state, err, want)
+	}
+
+	// Saving empty state removes it
+	if err := manager.SaveStrategyState("counter", "house/a", map[string]interface{}{}); err != nil {
+		t.Fatalf("SaveStrategyState failed: %v", err)
+	}
+	if state, err := manager.LoadStrategyState("counter", "house/a"); err != nil || state != nil {
+		t.Errorf("LoadStrategyState() after clearing = %v, %v; want no state", state, err)
+	}
+	if state, _ := manager.LoadStrategyState("counter", "house/b"); state["count"] != 7.0 {
+		t.Errorf("other topic state = %v, want it kept", state)
+	}
+}
+
+func TestSQLiteDatabase_StrategyAllowedInputPatterns(t *testing.T) {
+	db := setupTestSQLite(t)
+
+	strat := &strategy.Strategy{
+		ID:                   "thermostat",
+		Name:                 "Thermostat",
+		Code:                 "function process(context) { return null; }",
+		Language:             "javascript",
+		AllowedInputPatterns: []string{"sensors/+/temperature"},
+		CreatedAt:            time.Now(),
+		UpdatedAt:            time.Now(),
+	}
+	if err := db.SaveStrategy(strat); err != nil {
+		t.Fatalf("SaveStrategy failed: %v", err)
+	}
+
+	loaded, err := db.LoadStrategy("thermostat")
+	if err != nil {
+		t.Fatalf("LoadStrategy failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.AllowedInputPatterns, strat.AllowedInputPatterns) {
+		t.Errorf("AllowedInputPatterns = %v, want %v", loaded.AllowedInputPatterns, strat.AllowedInputPatterns)
+	}
+}
+
+func TestManager_StrategyFixtures(t *testing.T) {
+	db := setupTestSQLite(t)
+	manager := &Manager{db: db, logger: log.New(os.Stderr, "", 0)}
+
+	strat := &strategy.Strategy{
+		ID:        "double",
+		Name:      "Double",
+		Code:      "function process(context) { return context.inputs.value * 2; }",
+		Language:  "javascript",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := db.SaveStrategy(strat); err != nil {
+		t.Fatalf("SaveStrategy failed: %v", err)
+	}
+
+	fixedTime := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	fixtures := []StrategyFixture{
+		{StrategyID: "double", Name: "two", Inputs: map[string]interface{}{"value": 2.0}, Expected: 4.0},
+		{StrategyID: "double", Name: "object", Inputs: map[string]interface{}{"value": 1.0}, Parameters: map[string]interface{}{"unit": "c"},
+			TriggerTopic: "sensors/value", Expected: map[string]interface{}{"on": true}, Now: &fixedTime},
+	}
+	for _, fixture := range fixtures {
+		if err := manager.SaveStrategyFixture(fixture); err != nil {
+			t.Fatalf("SaveStrategyFixture failed: %v", err)
+		}
+	}
+
+	// Saving under an existing name replaces the fixture
+	fixtures[0].Expected = 5.0
+	if err := manager.SaveStrategyFixture(fixtures[0]); err != nil {
+		t.Fatalf("SaveStrategyFixture failed: %v", err)
+	}
+
+	loaded, err := manager.LoadStrategyFixtures("double")
+	if err != nil {
+		t.Fatalf("LoadStrategyFixtures failed: %v", err)
+	}
+	if len(loaded) != 2 || loaded[0].Name != "object" || loaded[1].Name != "two" {
+		t.Fatalf("LoadStrategyFixtures() = %+v, want object and two", loaded)
+	}
+	if loaded[1].Expected != 5.0 || loaded[1].Inputs["value"] != 2.0 {
+		t.Errorf("replaced fixture = %+v", loaded[1])
+	}
+	if !reflect.DeepEqual(loaded[0].Expected, fixtures[1].Expected) || loaded[0].Parameters["unit"] != "c" ||
+		loaded[0].TriggerTopic != "sensors/value" || loaded[0].CreatedAt.IsZero() {
+		t.Errorf("fixture = %+v, want %+v", loaded[0], fixtures[1])
+	}
+	if loaded[0].Now == nil || !loaded[0].Now.Equal(fixedTime) || loaded[1].Now != nil {
+		t.Errorf("fixture fixed times = %v, %v, want %v and none", loaded[0].Now, loaded[1].Now, fixedTime)
+	}
+
+	if err := manager.DeleteStrategyFixture("double", "two"); err != nil {
+		t.Fatalf("DeleteStrategyFixture failed: %v", err)
+	}
+	if loaded, _ := manager.LoadStrategyFixtures("double"); len(loaded) != 1 {
+		t.Errorf("got %d fixtures after delete, want 1", len(loaded))
+	}
+
+	// Deleting the strategy removes its fixtures
+	if err := db.DeleteStrategy("double"); err != nil {
+		t.Fatalf("DeleteStrategy failed: %v", err)
+	}
+	if loaded, _ := manager.LoadStrategyFixtures("double"); len(loaded) != 0 {
+		t.Errorf("got %d fixtures after deleting the strategy, want 0", len(loaded))
+	}
+}
+
+func TestSQLiteDatabase_StrategyLibrary(t *testing.T) {
+	db := setupTestSQLite(t)
+
+	strat := &strategy.Strategy{
+		ID:        "lib/util",
+		Name:      "Util",
+		Code:      "exports.double = function(x) { return x * 2; };",
+		Language:  "javascript",
+		Library:   true,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := db.SaveStrategy(strat); err != nil {
+		t.Fatalf("SaveStrategy failed: %v", err)
+	}
+
+	loaded, err := db.LoadStrategy(strat.ID)
+	if err != nil {
+		t.Fatalf("LoadStrategy failed: %v", err)
+	}
+	if !loaded.Library {
+		t.Error("Library flag was not persisted")
+	}
+
+	all, err := db.LoadAllStrategies()
+	if err != nil {
+		t.Fatalf("LoadAllStrategies failed: %v", err)
+	}
+	for _, s := range all {
+		if s.ID == strat.ID && !s.Library {
+			t.Error("LoadAllStrategies did not load the Library flag")
+		}
+		if s.ID == "alias" && s.Library {
+			t.Error("existing strategies should not be libraries")
+		}
+	}
+}
+
+func TestSQLiteDatabase_StrategyTimeout(t *testing.T) {
+	db := setupTestSQLite(t)
+
+	strat := &strategy.Strategy{
+		ID:        "slow",
+		Name:      "Slow",
+		Code:      "function process(context) { return 1; }",
+		Language:  "javascript",
+		TimeoutMs: 250,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := db.SaveStrategy(strat); err != nil {
+		t.Fatalf("SaveStrategy failed: %v", err)
+	}
+
+	loaded, err := db.LoadStrategy(strat.ID)
+	if err != nil {
+		t.Fatalf("LoadStrategy failed: %v", err)
+	}
+	if loaded.TimeoutMs != 250 {
+		t.Errorf("TimeoutMs = %d, want 250", loaded.TimeoutMs)
+	}
+
+	all, err := db.LoadAllStrategies()
+	if err != nil {
+		t.Fatalf("LoadAllStrategies failed: %v", err)
+	}
+	for _, s := range all {
+		if s.ID == strat.ID && s.TimeoutMs != 250 {
+			t.Errorf("LoadAllStrategies TimeoutMs = %d, want 250", s.TimeoutMs)
+		}
+		if s.ID == "alias" && s.TimeoutMs != 0 {
+			t.Errorf("existing strategies should use the default timeout, got %d", s.TimeoutMs)
+		}
+	}
+}
+
+func TestSQLiteDatabase_EncryptedParameters(t *testing.T) {
+	db := setupTestSQLite(t)
+
+	newStrategy := func(id string, parameters map[string]interface{}) *strategy.Strategy {
+		return &strategy.Strategy{
+			ID:         id,
+			Name:       id,
+			Code:       "function process(context) { return null; }",
+			Language:   "javascript",
+			Parameters: parameters,
+			CreatedAt:  time.Now(),
+			UpdatedAt:  time.Now(),
+		}
+	}
+
+	// Written before encryption was enabled
+	legacyParams := map[string]interface{}{"threshold": float64(5)}
+	if err := db.SaveStrategy(newStrategy("legacy", legacyParams)); err != nil {
+		t.Fatalf("SaveStrategy failed: %v", err)
+	}
+
+	if err := db.SetEncryptionKey("correct horse battery staple"); err != nil {
+		t.Fatalf("SetEncryptionKey failed: %v", err)
+	}
+
+	strategyParams := map[string]interface{}{"api_key": "sk-live-12345"}
+	if err := db.SaveStrategy(newStrategy("weather", strategyParams)); err != nil {
+		t.Fatalf("SaveStrategy failed: %v", err)
+	}
+	topicParams := map[string]interface{}{"token": "hunter2"}
+	topic := topics.InternalTopicConfig{
+		BaseTopicConfig: topics.BaseTopicConfig{Name: "weather/today", Type: topics.TopicTypeInternal, CreatedAt: time.Now(), LastUpdated: time.Now()},
+		StrategyID:      "weather",
+		Parameters:      topicParams,
+	}
+	if err := db.SaveTopic(topic); err != nil {
+		t.Fatalf("SaveTopic failed: %v", err)
+	}
+
+	for _, tt := range []struct {
+		query  string
+		arg    string
+		secret string
+	}{
+		{query: "SELECT parameters FROM strategies WHERE id = ?", arg: "weather", secret: "sk-live-12345"},
+		{query: "SELECT parameters FROM topics WHERE name = ?", arg: "weather/today", secret: "hunter2"},
+	} {
+		var stored string
+		if err := db.db.QueryRow(tt.query, tt.arg).Scan(&stored); err != nil {
+			t.Fatalf("failed to read stored parameters: %v", err)
+		}
+		if !strings.HasPrefix(stored, encryptedPrefix) {
+			t.Errorf("stored parameters %q should start with %q", stored, encryptedPrefix)
+		}
+		if strings.Contains(stored, tt.secret) {
+			t.Errorf("stored parameters contain the plaintext secret: %q", stored)
+		}
+	}
+
+	loadedStrategy, err := db.LoadStrategy("weather")
+	if err != nil {
+		t.Fatalf("LoadStrategy failed: %v", err)
+	}
+	if !reflect.DeepEqual(loadedStrategy.Parameters, strategyParams) {
+		t.Errorf("strategy parameters = %v, want %v", loadedStrategy.Parameters, strategyParams)
+	}
+
+	loadedTopic, err := db.LoadTopic("weather/today")
+	if err != nil {
+		t.Fatalf("LoadTopic failed: %v", err)
+	}
+	if got := loadedTopic.(topics.InternalTopicConfig).Parameters; !reflect.DeepEqual(got, topicParams) {
+		t.Errorf("topic parameters = %v, want %v", got, topicParams)
+	}
+
+	legacy, err := db.LoadStrategy("legacy")
+	if err != nil {
+		t.Fatalf("LoadStrategy of plaintext parameters failed: %v", err)
+	}
+	if !reflect.DeepEqual(legacy.Parameters, legacyParams) {
+		t.Errorf("legacy parameters = %v, want %v", legacy.Parameters, legacyParams)
+	}
+
+	if err := db.SetEncryptionKey("wrong key"); err != nil {
+		t.Fatalf("SetEncryptionKey failed: %v", err)
+	}
+	if _, err := db.LoadStrategy("weather"); err == nil {
+		t.Error("LoadStrategy with the wrong key should fail")
+	}
+
+	db.params = nil
+	if _, err := db.LoadStrategy("weather"); err == nil {
+		t.Error("LoadStrategy of encrypted parameters without a key should fail")
+	}
+}
+
+func TestSQLitePragmas(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      config.SQLiteConfig
+		busyTimeout int
+		synchronous int
+		checkSync   bool
+	}{
+		{
+			name:        "default busy timeout",
+			config:      config.SQLiteConfig{},
+			busyTimeout: 5000,
+		},
+		{
+			name:        "configured busy timeout",
+			config:      config.SQLiteConfig{BusyTimeout: 1234},
+			busyTimeout: 1234,
+		},
+		{
+			name: "additional pragmas",
+			config: config.SQLiteConfig{
+				BusyTimeout: 2000,
+				Pragmas:     map[string]string{"synchronous": "OFF"},
+			},
+			busyTimeout: 2000,
+			synchronous: 0,
+			checkSync:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := NewSQLiteDatabase(filepath.Join(t.TempDir(), "test.db"), tt.config)
+			if err != nil {
+				t.Fatalf("Failed to create SQLite database: %v", err)
+			}
+			defer db.Close()
+
+			var busyTimeout int
+			if err := db.db.QueryRow("PRAGMA busy_timeout").Scan(&busyTimeout); err != nil {
+				t.Fatalf("Failed to query busy_timeout: %v", err)
+			}
+			if busyTimeout != tt.busyTimeout {
+				t.Errorf("busy_timeout = %d, want %d", busyTimeout, tt.busyTimeout)
+			}
+
+			var foreignKeys int
+			if err := db.db.QueryRow("PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+				t.Fatalf("Failed to query foreign_keys: %v", err)
+			}
+			if foreignKeys != 1 {
+				t.Errorf("foreign_keys = %d, want 1", foreignKeys)
+			}
+
+			if tt.checkSync {
+				var synchronous int
+				if err := db.db.QueryRow("PRAGMA synchronous").Scan(&synchronous); err != nil {
+					t.Fatalf("Failed to query synchronous: %v", err)
+				}
+				if synchronous != tt.synchronous {
+					t.Errorf("synchronous = %d, want %d", synchronous, tt.synchronous)
+				}
+			}
+		})
+	}
+}
+
+func TestSQLiteDatabase_StrategyUsage(t *testing.T) {
+	db := setupTestSQLite(t)
+
+	unused := &strategy.Strategy{
+		ID:        "unused",
+		Name:      "Unused",
+		Code:      "function process(context) { return null; }",
+		Language:  "javascript",
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := db.SaveStrategy(unused); err != nil {
+		t.Fatalf("SaveStrategy failed: %v", err)
+	}
+
+	for _, name := range []string{"test/a", "test/b"} {
+		topic := topics.InternalTopicConfig{
+			BaseTopicConfig: topics.BaseTopicConfig{
+				Name:      name,
+				Type:      topics.TopicTypeInternal,
+				CreatedAt: time.Now(),
+			},
+			StrategyID: "alias",
+		}
+		if err := db.SaveTopic(topic); err != nil {
+			t.Fatalf("SaveTopic failed: %v", err)
+		}
+	}
+
+	older := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+	for _, executedAt := range []time.Time{older, newer} {
+		err := db.SaveExecutionLog(ExecutionLog{
+			TopicName:  "test/a",
+			StrategyID: "alias",
+			ExecutedAt: executedAt,
+		})
+		if err != nil {
+			t.Fatalf("SaveExecutionLog failed: %v", err)
+		}
+	}
+
+	usage, err := db.LoadStrategyUsage()
+	if err != nil {
+		t.Fatalf("LoadStrategyUsage failed: %v", err)
+	}
+
+	if got := usage["unused"]; got.UsageCount != 0 || got.LastExecutedAt != nil {
+		t.Errorf("unused strategy usage = %+v, want zero", got)
+	}
+
+	alias := usage["alias"]
+	if alias.UsageCount != 2 {
+		t.Errorf("alias UsageCount = %d, want 2", alias.UsageCount)
+	}
+	if alias.LastExecutedAt == nil || !alias.LastExecutedAt.Equal(newer) {
+		t.Errorf("alias LastExecutedAt = %v, want %v", alias.LastExecutedAt, newer)
+	}
+}
@@ -1,11 +1,22 @@
 package state
 
 import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/denwilliams/go-mqtt-automation/pkg/strategy"
 )
 
+// MigrationsDir is the directory containing the generated database-specific
+// migration folders (sqlite, postgres). It is relative to the working directory.
+var MigrationsDir = "db/migrations"
+
+// ErrStateNotFound is returned when loading a state key that is not saved
+var ErrStateNotFound = errors.New("state key not found")
+
 type Database interface {
 	// Topics
 	SaveTopic(config interface{}) error
@@ -29,10 +40,41 @@ type Database interface {
 	// Execution logs
 	SaveExecutionLog(log ExecutionLog) error
 	LoadExecutionLogs(topicName string, limit int) ([]ExecutionLog, error)
+	// EachExecutionLog calls fn for a topic's execution logs, newest first,
+	// without buffering them. A limit <= 0 returns every log.
+	EachExecutionLog(topicName string, limit int, fn func(ExecutionLog) error) error
+	// EachExecutionLogByID calls fn for a topic's execution logs in reverse
+	// insertion order, the order audit hash chains are built in
+	EachExecutionLogByID(topicName string, fn func(ExecutionLog) error) error
+
+	// Execution log retention
+	DeleteExecutionLogsOlderThan(cutoff time.Time) (int64, error)
+
+	// Strategy usage
+	LoadStrategyUsage() (map[string]StrategyUsage, error)
+
+	// Strategy test fixtures
+	SaveStrategyFixture(fixture StrategyFixture) error
+	LoadStrategyFixtures(strategyID string) ([]StrategyFixture, error)
+	DeleteStrategyFixture(strategyID, name string) error
+
+	// Topic history
+	SaveTopicHistory(topicName string, value interface{}, recordedAt time.Time) error
+	LoadTopicHistory(topicName string, from, to time.Time) ([]TopicHistoryEntry, error)
+	DeleteTopicHistoryBefore(cutoff time.Time) (int64, error)
+
+	// System event log
+	SaveSystemEvent(event SystemEvent) error
+	LoadSystemEvents(query SystemEventQuery) ([]SystemEvent, error)
+	DeleteSystemEventsBefore(cutoff time.Time) (int64, error)
 
 	// Maintenance
 	Close() error
 	Migrate() error
+	MigrateDown() error
+	MigrateTo(version uint) error
+	SchemaVersion() (version uint, dirty bool, err error)
+	ExpectedSchemaVersion() (uint, error)
 }
 
 type ExecutionLog struct {
@@ -44,7 +86,62 @@ type ExecutionLog struct {
 	OutputValues    interface{}            `db:"output_values"`
 	ErrorMessage    string                 `db:"error_message"`
 	ExecutionTimeMs int64                  `db:"execution_time_ms"`
+	LogMessages     []strategy.LogMessage  `db:"log_messages"`
 	ExecutedAt      time.Time              `db:"executed_at"`
+
+	// OutputDiff compares OutputValues with the topic's previous execution;
+	// nil when output diffs are disabled or the execution failed
+	OutputDiff *OutputDiff `db:"output_diff"`
+
+	// ChainHash hashes this execution together with the topic's previous
+	// chain hash; empty when the audit hash chain is disabled
+	ChainHash string `db:"chain_hash"`
+}
+
+// StrategyUsage summarises how a strategy is used: how many topics reference it
+// and when it last ran according to the execution logs
+type StrategyUsage struct {
+	UsageCount     int
+	LastExecutedAt *time.Time
+}
+
+// StrategyFixture is a named regression test for a strategy: the inputs it
+// is run with and the output it is expected to return
+type StrategyFixture struct {
+	StrategyID   string                 `json:"strategy_id" db:"strategy_id"`
+	Name         string                 `json:"name" db:"name"`
+	Inputs       map[string]interface{} `json:"inputs" db:"inputs"`
+	Parameters   map[string]interface{} `json:"parameters,omitempty" db:"parameters"`
+	TriggerTopic string                 `json:"trigger_topic,omitempty" db:"trigger_topic"`
+	Expected     interface{}            `json:"expected" db:"expected"`
+	Now          *time.Time             `json:"now,omitempty" db:"fixed_time"` // getTime/getISO return this during the run
+	CreatedAt    time.Time              `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time              `json:"updated_at" db:"updated_at"`
+}
+
+type TopicHistoryEntry struct {
+	ID         int         `db:"id"`
+	TopicName  string      `db:"topic_name"`
+	Value      interface{} `db:"value"`
+	RecordedAt time.Time   `db:"recorded_at"`
+}
+
+// SystemEvent is an entry in the system event log (startup, error,
+// mqtt_connected, ...)
+type SystemEvent struct {
+	ID         int         `json:"id" db:"id"`
+	Type       string      `json:"type" db:"event_type"`
+	Data       interface{} `json:"data" db:"data"`
+	OccurredAt time.Time   `json:"timestamp" db:"occurred_at"`
+}
+
+// SystemEventQuery selects system events, newest first. Empty fields do not
+// filter; a Limit <= 0 returns every matching event.
+type SystemEventQuery struct {
+	Type  string
+	From  time.Time
+	To    time.Time
+	Limit int
 }
 
 type TopicState struct {
@@ -52,3 +149,76 @@ type TopicState struct {
 	Value     interface{} `db:"value"`
 	UpdatedAt time.Time   `db:"updated_at"`
 }
+
+// marshalAllowedInputPatterns encodes a strategy's allowed input patterns,
+// storing NULL when there are none
+func marshalAllowedInputPatterns(patterns []string) (interface{}, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(patterns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal allowed input patterns: %w", err)
+	}
+	return string(data), nil
+}
+
+// nullableTimeout stores a strategy timeout, or NULL when it uses the default
+func nullableTimeout(timeoutMs int) interface{} {
+	if timeoutMs <= 0 {
+		return nil
+	}
+	return timeoutMs
+}
+
+// marshalLogMessages encodes an execution's strategy log messages, storing
+// NULL when there are none
+func marshalLogMessages(messages []strategy.LogMessage) (interface{}, error) {
+	if len(messages) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(messages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal log messages: %w", err)
+	}
+	return string(data), nil
+}
+
+func unmarshalLogMessages(data sql.NullString) ([]strategy.LogMessage, error) {
+	if !data.Valid || data.String == "" {
+		return nil, nil
+	}
+	var messages []strategy.LogMessage
+	if err := json.Unmarshal([]byte(data.String), &messages); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal log messages: %w", err)
+	}
+	return messages, nil
+}
+
+// fixtureTime returns a fixture's fixed time as a nullable column value
+func fixtureTime(now *time.Time) sql.NullTime {
+	if now == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: now.UTC(), Valid: true}
+}
+
+// unmarshalFixture decodes the JSON columns of a strategy fixture
+func unmarshalFixture(fixture *StrategyFixture, inputs, triggerTopic, expected sql.NullString, fixedTime sql.NullTime) error {
+	if inputs.Valid && inputs.String != "" {
+		if err := json.Unmarshal([]byte(inputs.String), &fixture.Inputs); err != nil {
+			return fmt.Errorf("failed to unmarshal fixture inputs: %w", err)
+		}
+	}
+	if expected.Valid && expected.String != "" {
+		if err := json.Unmarshal([]byte(expected.String), &fixture.Expected); err != nil {
+			return fmt.Errorf("failed to unmarshal fixture expected output: %w", err)
+		}
+	}
+	fixture.TriggerTopic = triggerTopic.String
+	if fixedTime.Valid {
+		now := fixedTime.Time.UTC()
+		fixture.Now = &now
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package strategy
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned when a strategy is skipped because its circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// DefaultCircuitBreakerCooldown is how long an open circuit skips executions before retrying
+const DefaultCircuitBreakerCooldown = time.Minute
+
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// CircuitStatus is a snapshot of a circuit breaker
+type CircuitStatus struct {
+	State               CircuitState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	OpenedAt            *time.Time   `json:"opened_at,omitempty"`
+	LastError           string       `json:"last_error,omitempty"`
+}
+
+// circuitKey identifies a circuit: a strategy running for one topic, so a
+// strategy failing on one topic's inputs keeps running for its other topics
+type circuitKey struct {
+	strategyID string
+	topicName  string
+}
+
+// circuitBreaker tracks consecutive failures of a strategy for a topic. After
+// threshold failures it opens and rejects executions for the cooldown, then
+// allows a single trial execution (half-open) which closes or re-opens it.
+type circuitBreaker struct {
+	state               CircuitState
+	consecutiveFailures int
+	openedAt            time.Time
+	lastError           string
+	trialInFlight       bool
+	mutex               sync.Mutex
+}
+
+func newCircuitBreaker() *circuitBreaker {
+	return &circuitBreaker{state: CircuitClosed}
+}
+
+// allow reports whether an execution may proceed
+func (cb *circuitBreaker) allow(now time.Time, cooldown time.Duration) bool {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	switch cb.state {
+	case CircuitOpen:
+		if now.Sub(cb.openedAt) < cooldown {
+			return false
+		}
+		cb.state = CircuitHalfOpen
+		cb.trialInFlight = true
+		return true
+	case CircuitHalfOpen:
+		// Only one trial execution at a time
+		if cb.trialInFlight {
+			return false
+		}
+		cb.trialInFlight = true
+		return true
+	default:
+		return true
+	}
+}
+
+// recordSuccess closes the circuit
+func (cb *circuitBreaker) recordSuccess() {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	cb.state = CircuitClosed
+	cb.consecutiveFailures = 0
+	cb.trialInFlight = false
+	cb.lastError = ""
+}
+
+// recordFailure counts a failure and reports whether it opened the circuit
+func (cb *circuitBreaker) recordFailure(err error, now time.Time, threshold int) bool {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	cb.consecutiveFailures++
+	cb.lastError = err.Error()
+	cb.trialInFlight = false
+
+	if cb.state == CircuitHalfOpen || (cb.state == CircuitClosed && cb.consecutiveFailures >= threshold) {
+		cb.state = CircuitOpen
+		cb.openedAt = now
+		return true
+	}
+	return false
+}
+
+func (cb *circuitBreaker) status() CircuitStatus {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	status := CircuitStatus{
+		State:               cb.state,
+		ConsecutiveFailures: cb.consecutiveFailures,
+		LastError:           cb.lastError,
+	}
+	if !cb.openedAt.IsZero() {
+		openedAt := cb.openedAt
+		status.OpenedAt = &openedAt
+	}
+	return status
+}
@@ -0,0 +1,186 @@
+package strategy
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newBreakerTestEngine(t *testing.T, fail *bool, executions *int) (*Engine, *time.Time) {
+	t.Helper()
+
+	engine := NewEngine(nil)
+	engine.RegisterExecutor("mock", &mockExecutor{
+		executeFunc: func(strategy *Strategy, context ExecutionContext) ExecutionResult {
+			*executions++
+			if *fail {
+				return ExecutionResult{Error: errors.New("boom")}
+			}
+			return ExecutionResult{Result: "ok"}
+		},
+	})
+
+	if err := engine.AddStrategy(&Strategy{ID: "flaky", Name: "Flaky", Code: "code", Language: "mock"}); err != nil {
+		t.Fatalf("AddStrategy() failed: %v", err)
+	}
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	engine.now = func() time.Time { return now }
+	engine.SetCircuitBreaker(3, time.Minute)
+	return engine, &now
+}
+
+func TestCircuitBreakerOpensSkipsAndRecovers(t *testing.T) {
+	fail := true
+	executions := 0
+	engine, now := newBreakerTestEngine(t, &fail, &executions)
+
+	var opened []string
+	engine.SetCircuitOpenHandler(func(strategyID, topicName string, err error) {
+		opened = append(opened, strategyID)
+	})
+
+	// Consecutive failures open the circuit
+	for i := 0; i < 3; i++ {
+		if _, err := engine.ExecuteStrategy("flaky", nil, nil, "in", nil, nil); err == nil {
+			t.Fatalf("execution %d: expected error", i)
+		}
+	}
+	status := engine.CircuitStatus("flaky", "")
+	if status.State != CircuitOpen {
+		t.Fatalf("state = %s, want %s", status.State, CircuitOpen)
+	}
+	if status.ConsecutiveFailures != 3 || status.LastError != "boom" || status.OpenedAt == nil {
+		t.Errorf("unexpected status %+v", status)
+	}
+	if len(opened) != 1 || opened[0] != "flaky" {
+		t.Fatalf("open handler calls = %v, want [flaky]", opened)
+	}
+
+	// While open, executions are skipped
+	for i := 0; i < 5; i++ {
+		_, err := engine.ExecuteStrategy("flaky", nil, nil, "in", nil, nil)
+		if !errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("expected ErrCircuitOpen, got %v", err)
+		}
+	}
+	if executions != 3 {
+		t.Errorf("executions = %d, want 3", executions)
+	}
+	if len(opened) != 1 {
+		t.Errorf("open handler called %d times, want 1", len(opened))
+	}
+
+	// After the cooldown a failing trial re-opens the circuit
+	*now = now.Add(time.Minute)
+	if _, err := engine.ExecuteStrategy("flaky", nil, nil, "in", nil, nil); errors.Is(err, ErrCircuitOpen) || err == nil {
+		t.Fatalf("expected trial execution error, got %v", err)
+	}
+	if executions != 4 {
+		t.Errorf("executions = %d, want 4", executions)
+	}
+	if state := engine.CircuitStatus("flaky", "").State; state != CircuitOpen {
+		t.Fatalf("state after failed trial = %s, want %s", state, CircuitOpen)
+	}
+	if len(opened) != 2 {
+		t.Errorf("open handler called %d times, want 2", len(opened))
+	}
+
+	// A successful trial closes it again
+	fail = false
+	*now = now.Add(time.Minute)
+	if _, err := engine.ExecuteStrategy("flaky", nil, nil, "in", nil, nil); err != nil {
+		t.Fatalf("trial execution failed: %v", err)
+	}
+	status = engine.CircuitStatus("flaky", "")
+	if status.State != CircuitClosed || status.ConsecutiveFailures != 0 {
+		t.Errorf("unexpected status after recovery %+v", status)
+	}
+	if _, err := engine.ExecuteStrategy("flaky", nil, nil, "in", nil, nil); err != nil {
+		t.Errorf("execution after recovery failed: %v", err)
+	}
+}
+
+func TestCircuitBreakerSuccessResetsFailures(t *testing.T) {
+	fail := true
+	executions := 0
+	engine, _ := newBreakerTestEngine(t, &fail, &executions)
+
+	for i := 0; i < 2; i++ {
+		engine.ExecuteStrategy("flaky", nil, nil, "in", nil, nil)
+	}
+	fail = false
+	engine.ExecuteStrategy("flaky", nil, nil, "in", nil, nil)
+	fail = true
+	for i := 0; i < 2; i++ {
+		engine.ExecuteStrategy("flaky", nil, nil, "in", nil, nil)
+	}
+
+	if state := engine.CircuitStatus("flaky", "").State; state != CircuitClosed {
+		t.Errorf("state = %s, want %s", state, CircuitClosed)
+	}
+}
+
+func TestCircuitBreakerDisabled(t *testing.T) {
+	fail := true
+	executions := 0
+	engine, _ := newBreakerTestEngine(t, &fail, &executions)
+	engine.SetCircuitBreaker(0, time.Minute)
+
+	for i := 0; i < 10; i++ {
+		if _, err := engine.ExecuteStrategy("flaky", nil, nil, "in", nil, nil); errors.Is(err, ErrCircuitOpen) {
+			t.Fatal("circuit opened while disabled")
+		}
+	}
+	if executions != 10 {
+		t.Errorf("executions = %d, want 10", executions)
+	}
+}
+
+func TestCircuitBreakerPerTopic(t *testing.T) {
+	fail := true
+	executions := 0
+	engine, _ := newBreakerTestEngine(t, &fail, &executions)
+
+	execute := func(topicName string) error {
+		_, _, _, err := engine.ExecuteStrategyWithOptions("flaky", nil, nil, "in", nil, nil, ExecuteOptions{TopicName: topicName})
+		return err
+	}
+
+	// Failures for one topic open only that topic's circuit
+	for i := 0; i < 3; i++ {
+		execute("lights/kitchen")
+	}
+	if err := execute("lights/kitchen"); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected ErrCircuitOpen for lights/kitchen, got %v", err)
+	}
+	if err := execute("lights/hall"); errors.Is(err, ErrCircuitOpen) {
+		t.Fatal("circuit of lights/hall opened by failures of lights/kitchen")
+	}
+
+	statuses := engine.CircuitStatuses("flaky")
+	if statuses["lights/kitchen"].State != CircuitOpen || statuses["lights/hall"].State != CircuitClosed {
+		t.Errorf("unexpected statuses %+v", statuses)
+	}
+	if state := engine.CircuitStatus("flaky", "lights/hall").State; state != CircuitClosed {
+		t.Errorf("lights/hall state = %s, want %s", state, CircuitClosed)
+	}
+}
+
+func TestCircuitBreakerDisabledByDefault(t *testing.T) {
+	engine := NewEngine(nil)
+	engine.RegisterExecutor("mock", &mockExecutor{
+		executeFunc: func(strategy *Strategy, context ExecutionContext) ExecutionResult {
+			return ExecutionResult{Error: errors.New("boom")}
+		},
+	})
+	if err := engine.AddStrategy(&Strategy{ID: "flaky", Name: "Flaky", Code: "code", Language: "mock"}); err != nil {
+		t.Fatalf("AddStrategy() failed: %v", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		if _, err := engine.ExecuteStrategy("flaky", nil, nil, "in", nil, nil); errors.Is(err, ErrCircuitOpen) {
+			t.Fatal("circuit opened without a configured threshold")
+		}
+	}
+}
@@ -12,6 +12,33 @@ type Engine struct {
 	executors  map[string]LanguageExecutor
 	logger     *log.Logger
 	mutex      sync.RWMutex
+
+	// Circuit breakers per topic and strategy; a threshold of 0 (the
+	// default) disables them
+	breakers         map[circuitKey]*circuitBreaker
+	breakerThreshold int
+	breakerCooldown  time.Duration
+	onCircuitOpen    func(strategyID, topicName string, err error)
+	now              func() time.Time
+
+	// pool runs executions on dedicated workers; nil runs them inline
+	pool *executionPool
+
+	// lastErrors holds the error of each strategy whose latest execution failed
+	lastErrors   map[string]error
+	outcomeMutex sync.Mutex
+
+	// interrupted is set on forced shutdown; later executions fail fast
+	interrupted bool
+
+	// postProcessors is the default pipeline applied to emitted values
+	postProcessors []PostProcessor
+
+	// inputLimits bounds the inputs of each execution
+	inputLimits InputLimits
+
+	// topics lets strategies read topics that are not inputs
+	topics TopicReader
 }
 
 func NewEngine(logger *log.Logger) *Engine {
@@ -20,17 +47,82 @@ func NewEngine(logger *log.Logger) *Engine {
 	}
 
 	engine := &Engine{
-		strategies: make(map[string]*Strategy),
-		executors:  make(map[string]LanguageExecutor),
-		logger:     logger,
+		strategies:      make(map[string]*Strategy),
+		executors:       make(map[string]LanguageExecutor),
+		logger:          logger,
+		breakers:        make(map[circuitKey]*circuitBreaker),
+		lastErrors:      make(map[string]error),
+		breakerCooldown: DefaultCircuitBreakerCooldown,
+		now:             time.Now,
 	}
 
 	// Register default executors
-	engine.RegisterExecutor("javascript", NewJavaScriptExecutor())
+	jsExecutor := NewJavaScriptExecutor()
+	jsExecutor.SetModuleResolver(engine.resolveLibrary)
+	engine.RegisterExecutor("javascript", jsExecutor)
 
 	return engine
 }
 
+// SetCircuitBreaker configures how many consecutive failures of a strategy
+// for one topic open that pair's circuit and how long its executions are
+// skipped. A threshold of 0 disables it.
+func (e *Engine) SetCircuitBreaker(threshold int, cooldown time.Duration) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.breakerThreshold = threshold
+	e.breakerCooldown = cooldown
+}
+
+// SetCircuitOpenHandler sets a callback invoked once each time a circuit opens
+func (e *Engine) SetCircuitOpenHandler(handler func(strategyID, topicName string, err error)) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.onCircuitOpen = handler
+}
+
+// CircuitStatus returns the circuit breaker state of a strategy running for
+// a topic
+func (e *Engine) CircuitStatus(strategyID, topicName string) CircuitStatus {
+	e.mutex.RLock()
+	breaker, exists := e.breakers[circuitKey{strategyID: strategyID, topicName: topicName}]
+	e.mutex.RUnlock()
+
+	if !exists {
+		return CircuitStatus{State: CircuitClosed}
+	}
+	return breaker.status()
+}
+
+// CircuitStatuses returns the circuit breaker state of a strategy for each
+// topic it has run for, keyed by topic
+func (e *Engine) CircuitStatuses(strategyID string) map[string]CircuitStatus {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	statuses := make(map[string]CircuitStatus)
+	for key, breaker := range e.breakers {
+		if key.strategyID == strategyID {
+			statuses[key.topicName] = breaker.status()
+		}
+	}
+	return statuses
+}
+
+func (e *Engine) getBreaker(key circuitKey) *circuitBreaker {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	breaker, exists := e.breakers[key]
+	if !exists {
+		breaker = newCircuitBreaker()
+		e.breakers[key] = breaker
+	}
+	return breaker
+}
+
 func (e *Engine) RegisterExecutor(language string, executor LanguageExecutor) {
 	e.mutex.Lock()
 	defer e.mutex.Unlock()
@@ -61,6 +153,21 @@ func (e *Engine) AddStrategy(strategy *Strategy) error {
 	return nil
 }
 
+// resolveLibrary finds a library strategy for require()
+func (e *Engine) resolveLibrary(moduleID string) (*Strategy, error) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	strategy, exists := e.strategies[moduleID]
+	if !exists {
+		return nil, fmt.Errorf("library %s not found", moduleID)
+	}
+	if !strategy.Library {
+		return nil, fmt.Errorf("strategy %s is not a library", moduleID)
+	}
+	return strategy, nil
+}
+
 func (e *Engine) RemoveStrategy(strategyID string) error {
 	e.mutex.Lock()
 	defer e.mutex.Unlock()
@@ -70,6 +177,7 @@ func (e *Engine) RemoveStrategy(strategyID string) error {
 	}
 
 	delete(e.strategies, strategyID)
+	e.clearOutcome(strategyID)
 	e.logger.Printf("Removed strategy: %s", strategyID)
 
 	return nil
@@ -101,32 +209,100 @@ func (e *Engine) ListStrategies() map[string]*Strategy {
 	return result
 }
 
+// MergeParameters overlays topic parameters on a strategy's default parameters
+func MergeParameters(defaults, overrides map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(defaults)+len(overrides))
+	for k, v := range defaults {
+		merged[k] = v
+	}
+	for k, v := range overrides {
+		merged[k] = v
+	}
+	return merged
+}
+
 func (e *Engine) ExecuteStrategy(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) ([]EmitEvent, error) {
+	events, _, err := e.ExecuteStrategyWithLogs(strategyID, inputs, inputNames, triggerTopic, lastOutput, topicParameters)
+	return events, err
+}
+
+// ExecuteStrategyWithLogs executes a strategy like ExecuteStrategy and also
+// returns the messages it logged at or above its log level
+func (e *Engine) ExecuteStrategyWithLogs(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) ([]EmitEvent, []LogMessage, error) {
+	events, logMessages, _, err := e.ExecuteStrategyWithOptions(strategyID, inputs, inputNames, triggerTopic, lastOutput, topicParameters, ExecuteOptions{})
+	return events, logMessages, err
+}
+
+// TraceStrategy executes a strategy like ExecuteStrategyWithLogs and also
+// returns a trace of where the execution spent its time. The trace is nil if
+// the strategy's executor does not support tracing.
+func (e *Engine) TraceStrategy(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) ([]EmitEvent, []LogMessage, *ExecutionTrace, error) {
+	return e.ExecuteStrategyWithOptions(strategyID, inputs, inputNames, triggerTopic, lastOutput, topicParameters, ExecuteOptions{Trace: true})
+}
+
+// ExecuteStrategyWithOptions executes a strategy like ExecuteStrategyWithLogs
+// with the given options. The trace is nil unless options.Trace is set.
+func (e *Engine) ExecuteStrategyWithOptions(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}, options ExecuteOptions) ([]EmitEvent, []LogMessage, *ExecutionTrace, error) {
 	e.mutex.RLock()
+	if e.interrupted {
+		e.mutex.RUnlock()
+		return nil, nil, nil, fmt.Errorf("strategy %s not executed: %w", strategyID, ErrInterrupted)
+	}
 	strategy, exists := e.strategies[strategyID]
 	if !exists {
 		e.mutex.RUnlock()
-		return nil, fmt.Errorf("strategy %s not found", strategyID)
+		return nil, nil, nil, fmt.Errorf("strategy %s not found", strategyID)
+	}
+	if strategy.Library {
+		e.mutex.RUnlock()
+		return nil, nil, nil, fmt.Errorf("strategy %s is a library and cannot be executed directly", strategyID)
 	}
 
 	executor, executorExists := e.executors[strategy.Language]
 	if !executorExists {
 		e.mutex.RUnlock()
-		return nil, fmt.Errorf("no executor found for language %s", strategy.Language)
+		return nil, nil, nil, fmt.Errorf("no executor found for language %s", strategy.Language)
 	}
+	threshold, cooldown, onCircuitOpen := e.breakerThreshold, e.breakerCooldown, e.onCircuitOpen
+	pool := e.pool
+	postProcessors := e.postProcessors
+	inputLimits := e.inputLimits
+	topicReader := e.topics
 	e.mutex.RUnlock()
 
-	// Merge parameters: topic parameters override strategy defaults
-	mergedParameters := make(map[string]interface{})
-	// Start with strategy defaults
-	for k, v := range strategy.Parameters {
-		mergedParameters[k] = v
+	// The chain this execution belongs to may have run out of time already
+	var deadline time.Time
+	if options.Context != nil {
+		if options.Context.Err() != nil {
+			return nil, nil, nil, fmt.Errorf("strategy %s not executed: %w", strategyID, ErrDeadlineExceeded)
+		}
+		deadline, _ = options.Context.Deadline()
+	}
+
+	inputs, err := e.limitInputs(strategyID, inputLimits, inputs, triggerTopic)
+	if err != nil {
+		return nil, nil, nil, err
 	}
-	// Override with topic-specific parameters
-	for k, v := range topicParameters {
-		mergedParameters[k] = v
+
+	// A per-execution pipeline replaces the default one
+	if options.PostProcessors != nil {
+		var err error
+		if postProcessors, err = ParsePostProcessors(options.PostProcessors); err != nil {
+			return nil, nil, nil, fmt.Errorf("strategy %s: %w", strategyID, err)
+		}
 	}
 
+	// Skip execution while the circuit of this topic and strategy is open
+	var breaker *circuitBreaker
+	if threshold > 0 {
+		breaker = e.getBreaker(circuitKey{strategyID: strategyID, topicName: options.TopicName})
+		if !breaker.allow(e.now(), cooldown) {
+			return nil, nil, nil, fmt.Errorf("strategy %s skipped for %s: %w", strategyID, options.TopicName, ErrCircuitOpen)
+		}
+	}
+
+	mergedParameters := MergeParameters(strategy.Parameters, topicParameters)
+
 	// Ensure lastOutput is always an object (never nil)
 	if lastOutput == nil {
 		lastOutput = map[string]interface{}{}
@@ -146,24 +322,28 @@ func (e *Engine) ExecuteStrategy(strategyID string, inputs map[string]interface{
 		TriggeringValue: triggeringValue,
 		LastOutputs:     lastOutput,
 		Parameters:      mergedParameters,
-		TopicName:       "", // This would be set by the topic manager
+		TopicName:       options.TopicName,
+		Trace:           options.Trace,
+		Now:             options.Now,
+		Deadline:        deadline,
+		Topics:          topicReader,
 	}
 
 	e.logger.Printf("Executing strategy %s (%s) triggered by %s", strategy.Name, strategyID, triggerTopic)
 
 	// Execute the strategy
-	result := executor.Execute(strategy, context)
+	result := e.execute(pool, executor, strategy, context)
 
 	// Log execution details
 	if result.Error != nil {
-		e.logger.Printf("Strategy execution failed: %v", result.Error)
+		e.logger.Printf("Strategy execution failed: %s", ErrorDetails(result.Error))
 	} else {
 		e.logger.Printf("Strategy executed successfully in %v", result.ExecutionTime)
 	}
 
 	// Log any messages from the strategy
 	for _, msg := range result.LogMessages {
-		e.logger.Printf("Strategy log: %s", msg)
+		e.logger.Printf("Strategy log [%s]: %s", msg.Level, msg.Message)
 	}
 
 	// Handle emitted events (this would typically be handled by the topic manager)
@@ -174,8 +354,24 @@ func (e *Engine) ExecuteStrategy(strategyID string, inputs map[string]interface{
 		}
 	}
 
+	e.recordOutcome(strategyID, result.Error)
+
+	if breaker != nil {
+		if result.Error != nil {
+			if breaker.recordFailure(result.Error, e.now(), threshold) {
+				e.logger.Printf("Circuit breaker opened for strategy %s on %s after %d consecutive failures; skipping executions for %v",
+					strategyID, options.TopicName, breaker.status().ConsecutiveFailures, cooldown)
+				if onCircuitOpen != nil {
+					onCircuitOpen(strategyID, options.TopicName, result.Error)
+				}
+			}
+		} else {
+			breaker.recordSuccess()
+		}
+	}
+
 	if result.Error != nil {
-		return nil, result.Error
+		return nil, result.LogMessages, result.Trace, result.Error
 	}
 
 	// Prepare events to return
@@ -202,8 +398,72 @@ func (e *Engine) ExecuteStrategy(strategyID string, inputs map[string]interface{
 	for _, event := range eventMap {
 		events = append(events, event)
 	}
+	applyPostProcessors(postProcessors, events, context.CurrentTime())
+
+	return events, result.LogMessages, result.Trace, nil
+}
+
+// SetAsyncTimeout sets how long promises returned by async JavaScript
+// strategies may take to settle
+func (e *Engine) SetAsyncTimeout(timeout time.Duration) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for _, executor := range e.executors {
+		if jsExecutor, ok := executor.(*JavaScriptExecutor); ok {
+			jsExecutor.SetAsyncTimeout(timeout)
+		}
+	}
+}
+
+// SetProgramCacheSize sets how many compiled JavaScript strategies are kept
+// for reuse by validation and execution; 0 disables the cache
+func (e *Engine) SetProgramCacheSize(size int) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for _, executor := range e.executors {
+		if jsExecutor, ok := executor.(*JavaScriptExecutor); ok {
+			jsExecutor.SetProgramCacheSize(size)
+		}
+	}
+}
+
+// SetTopicReader sets where strategies read the values of topics with
+// context.getTopic
+func (e *Engine) SetTopicReader(reader TopicReader) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.topics = reader
+}
+
+// SetStateStore sets where JavaScript strategies persist the state they keep
+// with context.setState
+func (e *Engine) SetStateStore(store StateStore) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for _, executor := range e.executors {
+		if jsExecutor, ok := executor.(*JavaScriptExecutor); ok {
+			jsExecutor.SetStateStore(store)
+		}
+	}
+}
+
+// SetPostProcessors sets the default post-processor pipeline applied to the
+// values of every execution; see ParsePostProcessor for the specs
+func (e *Engine) SetPostProcessors(specs []string) error {
+	processors, err := ParsePostProcessors(specs)
+	if err != nil {
+		return err
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
 
-	return events, nil
+	e.postProcessors = processors
+	return nil
 }
 
 func (e *Engine) ValidateStrategy(strategy *Strategy) error {
@@ -227,6 +487,12 @@ func (e *Engine) validateStrategy(strategy *Strategy) error {
 	if strategy.Language == "" {
 		strategy.Language = "javascript" // Default language
 	}
+	if _, err := ParseLogLevel(string(strategy.LogLevel)); err != nil {
+		return err
+	}
+	if strategy.TimeoutMs < 0 {
+		return fmt.Errorf("strategy timeout must not be negative")
+	}
 
 	// Check if executor exists for the language
 	executor, exists := e.executors[strategy.Language]
@@ -235,7 +501,15 @@ func (e *Engine) validateStrategy(strategy *Strategy) error {
 	}
 
 	// Validate the code using the executor
-	if err := executor.Validate(strategy.Code); err != nil {
+	if strategy.Library {
+		libraryValidator, ok := executor.(LibraryValidator)
+		if !ok {
+			return fmt.Errorf("language %s does not support libraries", strategy.Language)
+		}
+		if err := libraryValidator.ValidateLibrary(strategy.Code); err != nil {
+			return fmt.Errorf("code validation failed: %w", err)
+		}
+	} else if err := executor.Validate(strategy.Code); err != nil {
 		return fmt.Errorf("code validation failed: %w", err)
 	}
 
@@ -264,6 +538,7 @@ func (e *Engine) UpdateStrategy(strategy *Strategy) error {
 	strategy.UpdatedAt = time.Now()
 
 	e.strategies[strategy.ID] = strategy
+	e.clearOutcome(strategy.ID)
 	e.logger.Printf("Updated strategy: %s (%s)", strategy.Name, strategy.ID)
 
 	return nil
@@ -303,6 +578,7 @@ func (e *Engine) ReloadStrategyFromDatabase(strategyID string, strategy *Strateg
 
 	// Update the in-memory strategy
 	e.strategies[strategy.ID] = strategy
+	e.clearOutcome(strategy.ID)
 	e.logger.Printf("Reloaded strategy from database: %s (%s)", strategy.Name, strategy.ID)
 
 	return nil
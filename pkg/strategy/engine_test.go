@@ -348,7 +348,7 @@ func TestExecuteStrategy(t *testing.T) {
 					},
 				},
 				ExecutionTime: time.Millisecond * 5,
-				LogMessages:   []string{"test log message"},
+				LogMessages:   []LogMessage{{Level: LogLevelInfo, Message: "test log message"}},
 			}
 		},
 	}
@@ -440,6 +440,52 @@ func TestExecuteStrategyWithError(t *testing.T) {
 	}
 }
 
+func TestGetFailedStrategyCount(t *testing.T) {
+	engine := NewEngine(nil)
+	engine.RegisterExecutor("mock", &mockExecutor{
+		executeFunc: func(strategy *Strategy, context ExecutionContext) ExecutionResult {
+			if context.InputValues["fail"] == true {
+				return ExecutionResult{Error: errors.New("execution failed")}
+			}
+			return ExecutionResult{Result: "ok"}
+		},
+	})
+	for _, id := range []string{"a", "b", "c", "idle"} {
+		if err := engine.AddStrategy(&Strategy{ID: id, Name: id, Code: "code", Language: "mock"}); err != nil {
+			t.Fatalf("Failed to add strategy: %v", err)
+		}
+	}
+
+	fail := map[string]interface{}{"fail": true}
+	engine.ExecuteStrategy("a", nil, nil, "", nil, nil)
+	engine.ExecuteStrategy("b", fail, nil, "", nil, nil)
+	engine.ExecuteStrategy("c", fail, nil, "", nil, nil)
+	if got := engine.GetFailedStrategyCount(); got != 2 {
+		t.Errorf("failed strategies = %d, want 2", got)
+	}
+	if engine.LastError("b") == nil || engine.LastError("a") != nil || engine.LastError("idle") != nil {
+		t.Errorf("LastError a=%v b=%v idle=%v", engine.LastError("a"), engine.LastError("b"), engine.LastError("idle"))
+	}
+
+	// Only the latest execution counts
+	engine.ExecuteStrategy("a", fail, nil, "", nil, nil)
+	engine.ExecuteStrategy("b", nil, nil, "", nil, nil)
+	if got := engine.GetFailedStrategyCount(); got != 2 {
+		t.Errorf("failed strategies after re-running = %d, want 2", got)
+	}
+
+	// Updating or removing a strategy forgets its failure
+	if err := engine.UpdateStrategy(&Strategy{ID: "a", Name: "a", Code: "fixed", Language: "mock"}); err != nil {
+		t.Fatalf("UpdateStrategy failed: %v", err)
+	}
+	if err := engine.RemoveStrategy("c"); err != nil {
+		t.Fatalf("RemoveStrategy failed: %v", err)
+	}
+	if got := engine.GetFailedStrategyCount(); got != 0 {
+		t.Errorf("failed strategies after update and removal = %d, want 0", got)
+	}
+}
+
 func TestUpdateStrategy(t *testing.T) {
 	engine := NewEngine(nil)
 
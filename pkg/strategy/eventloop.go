@@ -0,0 +1,134 @@
+package strategy
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// DefaultAsyncTimeout is how long a promise returned by process() may take to
+// settle
+const DefaultAsyncTimeout = 5 * time.Second
+
+// eventLoop runs the callbacks of asynchronous host functions (setTimeout) on
+// the execution's goroutine, so an async process() can settle. The VM is
+// only ever used by the goroutine running await.
+type eventLoop struct {
+	vm   *goja.Runtime
+	jobs chan func() error
+
+	// stop is closed when the execution ends or is interrupted
+	stop     chan struct{}
+	stopOnce sync.Once
+	reason   string
+
+	mutex  sync.Mutex
+	timers map[int64]*time.Timer
+	nextID int64
+}
+
+func newEventLoop(vm *goja.Runtime) *eventLoop {
+	loop := &eventLoop{
+		vm:     vm,
+		jobs:   make(chan func() error),
+		stop:   make(chan struct{}),
+		timers: make(map[int64]*time.Timer),
+	}
+	vm.Set("setTimeout", loop.setTimeout)
+	vm.Set("clearTimeout", loop.clearTimeout)
+	return loop
+}
+
+// setTimeout(fn, delayMs, ...args) schedules fn and returns a timer ID
+func (l *eventLoop) setTimeout(call goja.FunctionCall) goja.Value {
+	fn, ok := goja.AssertFunction(call.Argument(0))
+	if !ok {
+		panic(l.vm.NewTypeError("setTimeout callback is not a function"))
+	}
+	delay := time.Duration(call.Argument(1).ToFloat() * float64(time.Millisecond))
+	var args []goja.Value
+	if len(call.Arguments) > 2 {
+		args = call.Arguments[2:]
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.nextID++
+	id := l.nextID
+	l.timers[id] = time.AfterFunc(delay, func() {
+		job := func() error {
+			l.mutex.Lock()
+			delete(l.timers, id)
+			l.mutex.Unlock()
+			_, err := fn(goja.Undefined(), args...)
+			return err
+		}
+		select {
+		case l.jobs <- job:
+		case <-l.stop:
+		}
+	})
+	return l.vm.ToValue(id)
+}
+
+// clearTimeout(id) cancels a timer that has not fired
+func (l *eventLoop) clearTimeout(id int64) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if timer, ok := l.timers[id]; ok {
+		timer.Stop()
+		delete(l.timers, id)
+	}
+}
+
+func (l *eventLoop) pendingTimers() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return len(l.timers)
+}
+
+// await runs queued callbacks until promise settles, returning its value.
+// Promises that are rejected, cannot settle (nothing is pending) or take
+// longer than timeout fail.
+func (l *eventLoop) await(promise *goja.Promise, timeout time.Duration) (goja.Value, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for promise.State() == goja.PromiseStatePending {
+		if l.pendingTimers() == 0 {
+			return nil, fmt.Errorf("promise returned by process never settled")
+		}
+		select {
+		case job := <-l.jobs:
+			if err := job(); err != nil {
+				return nil, err
+			}
+		case <-deadline.C:
+			return nil, fmt.Errorf("promise returned by process did not settle within %v", timeout)
+		case <-l.stop:
+			return nil, fmt.Errorf("execution interrupted: %s", l.reason)
+		}
+	}
+
+	if promise.State() == goja.PromiseStateRejected {
+		return nil, fmt.Errorf("promise returned by process was rejected: %v", promise.Result())
+	}
+	return promise.Result(), nil
+}
+
+// close stops the pending timers and any await in progress
+func (l *eventLoop) close(reason string) {
+	l.stopOnce.Do(func() {
+		l.reason = reason
+		close(l.stop)
+	})
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for id, timer := range l.timers {
+		timer.Stop()
+		delete(l.timers, id)
+	}
+}
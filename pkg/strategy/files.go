@@ -0,0 +1,192 @@
+package strategy
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// fileReloadDelay groups the burst of events editors produce for one save
+const fileReloadDelay = 100 * time.Millisecond
+
+// FileLoader loads JavaScript strategies from .js files in a directory and
+// reloads them when the files change. The strategy ID is the file name
+// without its extension.
+type FileLoader struct {
+	engine   *Engine
+	dir      string
+	logger   *log.Logger
+	onReload func(*Strategy)
+
+	watcher *fsnotify.Watcher
+	timers  map[string]*time.Timer
+	mutex   sync.Mutex
+	wg      sync.WaitGroup
+}
+
+func NewFileLoader(engine *Engine, dir string, logger *log.Logger) *FileLoader {
+	if logger == nil {
+		logger = log.Default()
+	}
+
+	return &FileLoader{
+		engine: engine,
+		dir:    dir,
+		logger: logger,
+		timers: make(map[string]*time.Timer),
+	}
+}
+
+// SetReloadHandler sets a callback run after a file strategy is loaded into
+// the engine, e.g. to persist it
+func (l *FileLoader) SetReloadHandler(handler func(*Strategy)) {
+	l.onReload = handler
+}
+
+// LoadAll loads every .js file in the directory. Files that fail validation
+// are logged and skipped.
+func (l *FileLoader) LoadAll() error {
+	paths, err := filepath.Glob(filepath.Join(l.dir, "*.js"))
+	if err != nil {
+		return fmt.Errorf("failed to list strategy files: %w", err)
+	}
+
+	for _, path := range paths {
+		if err := l.LoadFile(path); err != nil {
+			l.logger.Printf("Failed to load strategy file %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// LoadFile loads or reloads the strategy in path. An existing strategy keeps
+// its metadata and only has its code replaced; if the new code fails
+// validation the previous version stays active.
+func (l *FileLoader) LoadFile(path string) error {
+	id := strings.TrimSuffix(filepath.Base(path), ".js")
+	if id == "" {
+		return fmt.Errorf("strategy file name is empty")
+	}
+
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read strategy file: %w", err)
+	}
+
+	var strat Strategy
+	if existing, err := l.engine.GetStrategy(id); err == nil {
+		if existing.Builtin {
+			return fmt.Errorf("strategy %s is builtin", id)
+		}
+		if existing.Code == string(code) && existing.FilePath == path {
+			return nil
+		}
+		strat = *existing
+	} else {
+		strat = Strategy{
+			ID:         id,
+			Name:       id,
+			Language:   "javascript",
+			Parameters: make(map[string]interface{}),
+		}
+	}
+	strat.Code = string(code)
+	strat.FilePath = path
+
+	if err := l.engine.AddStrategy(&strat); err != nil {
+		return err
+	}
+	if l.onReload != nil {
+		l.onReload(&strat)
+	}
+	return nil
+}
+
+// Start loads all strategy files and watches the directory for changes
+func (l *FileLoader) Start() error {
+	if err := l.LoadAll(); err != nil {
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	if err := watcher.Add(l.dir); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch strategy directory: %w", err)
+	}
+	l.watcher = watcher
+
+	l.wg.Add(1)
+	go l.watch()
+
+	l.logger.Printf("Watching strategy files in %s", l.dir)
+	return nil
+}
+
+// Stop stops watching for file changes
+func (l *FileLoader) Stop() {
+	if l.watcher == nil {
+		return
+	}
+	l.watcher.Close()
+	l.wg.Wait()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for path, timer := range l.timers {
+		timer.Stop()
+		delete(l.timers, path)
+	}
+}
+
+func (l *FileLoader) watch() {
+	defer l.wg.Done()
+
+	for {
+		select {
+		case event, ok := <-l.watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Ext(event.Name) != ".js" || event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			l.scheduleReload(event.Name)
+		case err, ok := <-l.watcher.Errors:
+			if !ok {
+				return
+			}
+			l.logger.Printf("Strategy file watcher error: %v", err)
+		}
+	}
+}
+
+// scheduleReload reloads path once events for it have settled
+func (l *FileLoader) scheduleReload(path string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if timer, exists := l.timers[path]; exists {
+		timer.Reset(fileReloadDelay)
+		return
+	}
+	l.timers[path] = time.AfterFunc(fileReloadDelay, func() {
+		l.mutex.Lock()
+		delete(l.timers, path)
+		l.mutex.Unlock()
+
+		if err := l.LoadFile(path); err != nil {
+			l.logger.Printf("Failed to reload strategy file %s: %v", path, err)
+			return
+		}
+		l.logger.Printf("Reloaded strategy file %s", path)
+	})
+}
@@ -0,0 +1,119 @@
+package strategy
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func waitForStrategyCode(t *testing.T, engine *Engine, id, code string) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if strat, err := engine.GetStrategy(id); err == nil && strat.Code == code {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	strat, _ := engine.GetStrategy(id)
+	t.Fatalf("strategy %s code was not reloaded; got %+v", id, strat)
+}
+
+func TestFileLoader_HotReload(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "doubler.js")
+	original := "function process(context) { return context.input * 2; }"
+	if err := os.WriteFile(path, []byte(original), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	engine := NewEngine(log.New(os.Stderr, "", 0))
+	loader := NewFileLoader(engine, dir, log.New(os.Stderr, "", 0))
+
+	var mutex sync.Mutex
+	var reloaded []string
+	loader.SetReloadHandler(func(strat *Strategy) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		reloaded = append(reloaded, strat.Code)
+	})
+
+	if err := loader.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer loader.Stop()
+
+	strat, err := engine.GetStrategy("doubler")
+	if err != nil {
+		t.Fatalf("strategy not loaded from file: %v", err)
+	}
+	if strat.Code != original || strat.FilePath != path || strat.Language != "javascript" {
+		t.Fatalf("unexpected strategy loaded: %+v", strat)
+	}
+
+	updated := "function process(context) { return context.input * 3; }"
+	if err := os.WriteFile(path, []byte(updated), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForStrategyCode(t, engine, "doubler", updated)
+
+	// Invalid code keeps the previous version
+	if err := os.WriteFile(path, []byte("function process(context) { return ;; +"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(3 * fileReloadDelay)
+	if strat, _ := engine.GetStrategy("doubler"); strat.Code != updated {
+		t.Errorf("invalid file replaced strategy code: %q", strat.Code)
+	}
+
+	// New files are picked up as well
+	added := filepath.Join(dir, "added.js")
+	addedCode := "function process(context) { return 1; }"
+	if err := os.WriteFile(added, []byte(addedCode), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitForStrategyCode(t, engine, "added", addedCode)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(reloaded) != 3 || reloaded[0] != original || reloaded[1] != updated || reloaded[2] != addedCode {
+		t.Errorf("reload handler calls = %q", reloaded)
+	}
+}
+
+func TestFileLoader_KeepsMetadata(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "existing.js")
+	if err := os.WriteFile(path, []byte("function process(context) { return 2; }"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	engine := NewEngine(log.New(os.Stderr, "", 0))
+	if err := engine.AddStrategy(&Strategy{
+		ID:          "existing",
+		Name:        "Existing Strategy",
+		Description: "from the database",
+		Code:        "function process(context) { return 1; }",
+		Language:    "javascript",
+		Parameters:  map[string]interface{}{"factor": 2},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	loader := NewFileLoader(engine, dir, log.New(os.Stderr, "", 0))
+	if err := loader.LoadFile(path); err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+
+	strat, _ := engine.GetStrategy("existing")
+	if strat.Name != "Existing Strategy" || strat.Description != "from the database" || strat.Parameters["factor"] != 2 {
+		t.Errorf("metadata not kept: %+v", strat)
+	}
+	if strat.Code != "function process(context) { return 2; }" || strat.FilePath != path {
+		t.Errorf("code not loaded from file: %+v", strat)
+	}
+}
@@ -0,0 +1,72 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrInterrupted is returned for executions started after the engine was
+// interrupted
+var ErrInterrupted = errors.New("engine interrupted")
+
+// ErrDeadlineExceeded is returned for executions stopped, or not started,
+// because the deadline of their propagation chain passed
+var ErrDeadlineExceeded = errors.New("chain deadline exceeded")
+
+// interruptGrace is how long Shutdown waits for draining to finish once
+// running executions have been interrupted
+const interruptGrace = 5 * time.Second
+
+// interrupter is implemented by executors that can stop in-flight executions
+type interrupter interface {
+	Interrupt(reason string) int
+}
+
+// Interrupt stops in-flight executions on executors that support it and fails
+// any executions started afterwards. It returns the number interrupted.
+func (e *Engine) Interrupt(reason string) int {
+	e.mutex.Lock()
+	e.interrupted = true
+	executors := make([]LanguageExecutor, 0, len(e.executors))
+	for _, executor := range e.executors {
+		executors = append(executors, executor)
+	}
+	e.mutex.Unlock()
+
+	count := 0
+	for _, executor := range executors {
+		if i, ok := executor.(interrupter); ok {
+			count += i.Interrupt(reason)
+		}
+	}
+	return count
+}
+
+// Shutdown runs drain, which waits for in-flight work to finish. If ctx is
+// done first, running executions are interrupted so drain can return, and
+// Shutdown waits a short grace period for it. It reports whether drain
+// finished before ctx was done.
+func (e *Engine) Shutdown(ctx context.Context, drain func()) bool {
+	done := make(chan struct{})
+	go func() {
+		drain()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+	}
+
+	count := e.Interrupt("shutdown timeout")
+	e.logger.Printf("Shutdown timeout reached; interrupted %d running strategy executions", count)
+
+	select {
+	case <-done:
+	case <-time.After(interruptGrace):
+		e.logger.Printf("Shutdown did not finish %v after interrupting strategy executions", interruptGrace)
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEngineShutdownInterruptsExecutions(t *testing.T) {
+	engine := NewEngine(nil)
+	if err := engine.AddStrategy(&Strategy{
+		ID:       "spin",
+		Name:     "Spin",
+		Code:     `function process(context) { while (true) {} }`,
+		Language: "javascript",
+	}); err != nil {
+		t.Fatalf("AddStrategy() failed: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	var execErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, execErr = engine.ExecuteStrategy("spin", nil, nil, "", nil, nil)
+	}()
+
+	// Let the execution start before the shutdown clock runs
+	time.Sleep(20 * time.Millisecond)
+
+	const timeout = 100 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	if engine.Shutdown(ctx, wg.Wait) {
+		t.Error("Shutdown() reported a clean drain, want timeout")
+	}
+	if elapsed := time.Since(start); elapsed > timeout+time.Second {
+		t.Errorf("Shutdown() took %v, want about %v", elapsed, timeout)
+	}
+	if execErr == nil {
+		t.Error("interrupted execution returned no error")
+	}
+
+	// Executions started after the interrupt fail fast
+	if _, err := engine.ExecuteStrategy("spin", nil, nil, "", nil, nil); !errors.Is(err, ErrInterrupted) {
+		t.Errorf("ExecuteStrategy() after interrupt error = %v, want ErrInterrupted", err)
+	}
+}
+
+func TestEngineShutdownDrains(t *testing.T) {
+	engine := NewEngine(nil)
+
+	drained := false
+	if !engine.Shutdown(context.Background(), func() { drained = true }) {
+		t.Error("Shutdown() reported a timeout for a finished drain")
+	}
+	if !drained {
+		t.Error("Shutdown() did not run drain")
+	}
+	if engine.Interrupt("test") != 0 {
+		t.Error("Interrupt() reported executions with none running")
+	}
+}
+
+func TestEngineChainDeadline(t *testing.T) {
+	engine := NewEngine(nil)
+	if err := engine.AddStrategy(&Strategy{
+		ID:       "spin",
+		Name:     "Spin",
+		Code:     `function process(context) { while (true) {} }`,
+		Language: "javascript",
+	}); err != nil {
+		t.Fatalf("AddStrategy() failed: %v", err)
+	}
+
+	// A running execution stops at the chain deadline, well before the
+	// executor's own timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, _, err := engine.ExecuteStrategyWithOptions("spin", nil, nil, "", nil, nil, ExecuteOptions{Context: ctx})
+	if !errors.Is(err, ErrDeadlineExceeded) {
+		t.Errorf("ExecuteStrategyWithOptions() error = %v, want ErrDeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("execution took %v, want it stopped at the deadline", elapsed)
+	}
+
+	// Executions of a chain past its deadline are not started
+	<-ctx.Done()
+	if _, _, _, err := engine.ExecuteStrategyWithOptions("spin", nil, nil, "", nil, nil, ExecuteOptions{Context: ctx}); !errors.Is(err, ErrDeadlineExceeded) {
+		t.Errorf("ExecuteStrategyWithOptions() after the deadline error = %v, want ErrDeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,312 @@
+package strategy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime/debug"
+	"runtime/metrics"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LanguageSubprocess runs JavaScript strategies in a separate worker process
+// with hard memory and CPU limits, for strategies that cannot be trusted to
+// share the server's process
+const LanguageSubprocess = "subprocess"
+
+// IsolatedWorkerEnv is set in the environment of isolated worker processes.
+// Binaries that register a SubprocessExecutor must check IsIsolatedWorker
+// at startup.
+const IsolatedWorkerEnv = "MQTT_AUTOMATION_STRATEGY_WORKER"
+
+// Exit codes a worker uses to report an exceeded limit
+const (
+	workerExitMemory = 3
+	workerExitCPU    = 4
+)
+
+// Default limits of isolated executions
+const (
+	DefaultIsolatedMemoryMB = 128
+	DefaultIsolatedCPUTime  = 10 * time.Second
+	DefaultIsolatedTimeout  = 30 * time.Second
+)
+
+// IsolationLimits bound a single isolated execution. Zero fields use the
+// defaults.
+type IsolationLimits struct {
+	MemoryMB int           `json:"memory_mb"` // heap size the strategy may use
+	CPUTime  time.Duration `json:"cpu_time"`  // CPU time the worker may use
+	Timeout  time.Duration `json:"timeout"`   // wall-clock time the worker may run
+}
+
+func (l IsolationLimits) withDefaults() IsolationLimits {
+	if l.MemoryMB <= 0 {
+		l.MemoryMB = DefaultIsolatedMemoryMB
+	}
+	if l.CPUTime <= 0 {
+		l.CPUTime = DefaultIsolatedCPUTime
+	}
+	if l.Timeout <= 0 {
+		l.Timeout = DefaultIsolatedTimeout
+	}
+	return l
+}
+
+// workerRequest is written to a worker's stdin
+type workerRequest struct {
+	Code     string           `json:"code"`
+	LogLevel LogLevel         `json:"log_level,omitempty"`
+	Context  ExecutionContext `json:"context"`
+	Now      time.Time        `json:"now"`
+	Limits   IsolationLimits  `json:"limits"`
+}
+
+// workerResponse is read from a worker's stdout
+type workerResponse struct {
+	Result        interface{}  `json:"result"`
+	Error         string       `json:"error,omitempty"`
+	Stack         string       `json:"stack,omitempty"`
+	LogMessages   []LogMessage `json:"log_messages"`
+	EmittedEvents []EmitEvent  `json:"emitted_events"`
+}
+
+// SubprocessExecutor runs each execution of a JavaScript strategy in a new
+// worker process. Inputs and outputs cross the process boundary as JSON, so
+// whole numbers come back as floats. require() and tracing are not
+// available to isolated strategies, context.getTopic returns null for every
+// topic, and state set with context.setState is not kept between their
+// executions.
+type SubprocessExecutor struct {
+	command   []string
+	limits    IsolationLimits
+	validator *JavaScriptExecutor
+
+	// running holds the interrupt of each in-flight worker
+	running      map[*workerRun]struct{}
+	runningMutex sync.Mutex
+}
+
+// workerRun is an in-flight worker process
+type workerRun struct {
+	cancel context.CancelFunc
+	reason string
+}
+
+// NewSubprocessExecutor creates an executor whose workers are the current
+// binary started with IsolatedWorkerEnv set
+func NewSubprocessExecutor(limits IsolationLimits) (*SubprocessExecutor, error) {
+	path, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate worker binary: %w", err)
+	}
+	return &SubprocessExecutor{
+		command:   []string{path},
+		limits:    limits.withDefaults(),
+		validator: NewJavaScriptExecutor(),
+		running:   make(map[*workerRun]struct{}),
+	}, nil
+}
+
+// SetCommand sets the command that starts a worker
+func (se *SubprocessExecutor) SetCommand(path string, args ...string) {
+	se.command = append([]string{path}, args...)
+}
+
+func (se *SubprocessExecutor) Validate(code string) error {
+	return se.validator.Validate(code)
+}
+
+func (se *SubprocessExecutor) Execute(strategy *Strategy, execContext ExecutionContext) ExecutionResult {
+	start := time.Now()
+	result := ExecutionResult{
+		LogMessages:   []LogMessage{},
+		EmittedEvents: []EmitEvent{},
+	}
+	defer func() {
+		result.ExecutionTime = time.Since(start)
+	}()
+
+	request, err := json.Marshal(workerRequest{
+		Code:     strategy.Code,
+		LogLevel: strategy.LogLevel,
+		Context:  execContext,
+		Now:      execContext.Now,
+		Limits:   se.limits,
+	})
+	if err != nil {
+		result.Error = fmt.Errorf("failed to encode isolated execution: %w", err)
+		return result
+	}
+
+	// The chain deadline applies when it is sooner than the worker timeout
+	deadline := time.Now().Add(se.limits.Timeout)
+	chainDeadline := !execContext.Deadline.IsZero() && execContext.Deadline.Before(deadline)
+	if chainDeadline {
+		deadline = execContext.Deadline
+	}
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	run := se.track(cancel)
+	defer se.untrack(run)
+
+	cmd := exec.CommandContext(ctx, se.command[0], se.command[1:]...)
+	cmd.Env = append(os.Environ(), IsolatedWorkerEnv+"=1")
+	cmd.Stdin = bytes.NewReader(request)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		result.Error = se.workerError(ctx, run, err, stderr.String(), chainDeadline)
+		return result
+	}
+
+	var response workerResponse
+	if err := json.Unmarshal(stdout.Bytes(), &response); err != nil {
+		result.Error = fmt.Errorf("invalid response from isolated worker: %w", err)
+		return result
+	}
+
+	result.Result = response.Result
+	if response.LogMessages != nil {
+		result.LogMessages = response.LogMessages
+	}
+	if response.EmittedEvents != nil {
+		result.EmittedEvents = response.EmittedEvents
+	}
+	if response.Error != "" {
+		result.Error = errors.New(response.Error)
+		if response.Stack != "" {
+			result.Error = &ScriptError{Err: result.Error, Stack: response.Stack}
+		}
+	}
+	return result
+}
+
+// workerError describes why a worker process failed
+func (se *SubprocessExecutor) workerError(ctx context.Context, run *workerRun, err error, stderr string, chainDeadline bool) error {
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		if chainDeadline {
+			return fmt.Errorf("isolated execution stopped: %w", ErrDeadlineExceeded)
+		}
+		return fmt.Errorf("isolated execution timeout after %v", se.limits.Timeout)
+	case context.Canceled:
+		se.runningMutex.Lock()
+		reason := run.reason
+		se.runningMutex.Unlock()
+		return fmt.Errorf("isolated execution interrupted: %s", reason)
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		switch exitErr.ExitCode() {
+		case workerExitMemory:
+			return fmt.Errorf("isolated execution exceeded the memory limit of %d MB", se.limits.MemoryMB)
+		case workerExitCPU:
+			return fmt.Errorf("isolated execution exceeded the CPU time limit of %v", se.limits.CPUTime)
+		}
+		if cpuLimitKilled(exitErr) {
+			return fmt.Errorf("isolated execution exceeded the CPU time limit of %v", se.limits.CPUTime)
+		}
+	}
+
+	if stderr = strings.TrimSpace(stderr); stderr != "" {
+		return fmt.Errorf("isolated worker failed: %w: %s", err, stderr)
+	}
+	return fmt.Errorf("isolated worker failed: %w", err)
+}
+
+func (se *SubprocessExecutor) track(cancel context.CancelFunc) *workerRun {
+	se.runningMutex.Lock()
+	defer se.runningMutex.Unlock()
+	run := &workerRun{cancel: cancel}
+	se.running[run] = struct{}{}
+	return run
+}
+
+func (se *SubprocessExecutor) untrack(run *workerRun) {
+	se.runningMutex.Lock()
+	defer se.runningMutex.Unlock()
+	delete(se.running, run)
+}
+
+// Interrupt kills every in-flight worker; each execution fails with an
+// interrupted error. It returns the number of executions interrupted.
+func (se *SubprocessExecutor) Interrupt(reason string) int {
+	se.runningMutex.Lock()
+	defer se.runningMutex.Unlock()
+	for run := range se.running {
+		run.reason = reason
+		run.cancel()
+	}
+	return len(se.running)
+}
+
+// IsIsolatedWorker reports whether this process was started as a worker
+func IsIsolatedWorker() bool {
+	return os.Getenv(IsolatedWorkerEnv) != ""
+}
+
+// RunIsolatedWorker executes the request read from stdin, writes the result
+// to stdout and returns the process exit code. Exceeding a limit exits the
+// process with a code the SubprocessExecutor recognizes.
+func RunIsolatedWorker(stdin io.Reader, stdout io.Writer) int {
+	var request workerRequest
+	if err := json.NewDecoder(stdin).Decode(&request); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid worker request: %v\n", err)
+		return 1
+	}
+	limits := request.Limits.withDefaults()
+
+	if err := limitCPUTime(limits.CPUTime, workerExitCPU); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to limit CPU time: %v\n", err)
+		return 1
+	}
+	memoryLimit := int64(limits.MemoryMB) << 20
+	debug.SetMemoryLimit(memoryLimit)
+	go watchMemory(memoryLimit)
+
+	execContext := request.Context
+	execContext.Now = request.Now
+	executor := NewJavaScriptExecutor()
+	executor.maxExecutionTime = limits.Timeout
+	result := executor.Execute(&Strategy{Code: request.Code, LogLevel: request.LogLevel}, execContext)
+
+	response := workerResponse{
+		Result:        result.Result,
+		LogMessages:   result.LogMessages,
+		EmittedEvents: result.EmittedEvents,
+	}
+	if result.Error != nil {
+		response.Error = result.Error.Error()
+		response.Stack = StackTrace(result.Error)
+	}
+	if err := json.NewEncoder(stdout).Encode(response); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to encode worker response: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// watchMemory exits the worker once its heap grows past limit. The memory
+// limit set on the runtime only makes the GC work harder; this makes it hard.
+func watchMemory(limit int64) {
+	samples := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	for {
+		metrics.Read(samples)
+		if int64(samples[0].Value.Uint64()) > limit {
+			fmt.Fprintf(os.Stderr, "memory limit of %d bytes exceeded\n", limit)
+			os.Exit(workerExitMemory)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
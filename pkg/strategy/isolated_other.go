@@ -0,0 +1,18 @@
+//go:build !unix
+
+package strategy
+
+import (
+	"os/exec"
+	"time"
+)
+
+// limitCPUTime is not supported on this platform; the wall-clock timeout
+// still bounds isolated executions
+func limitCPUTime(limit time.Duration, exitCode int) error {
+	return nil
+}
+
+func cpuLimitKilled(exitErr *exec.ExitError) bool {
+	return false
+}
@@ -0,0 +1,118 @@
+package strategy
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMain lets the test binary serve as the isolated worker
+func TestMain(m *testing.M) {
+	if IsIsolatedWorker() {
+		os.Exit(RunIsolatedWorker(os.Stdin, os.Stdout))
+	}
+	os.Exit(m.Run())
+}
+
+func newTestSubprocessExecutor(t *testing.T, limits IsolationLimits) *SubprocessExecutor {
+	t.Helper()
+	executor, err := NewSubprocessExecutor(limits)
+	if err != nil {
+		t.Fatalf("NewSubprocessExecutor() failed: %v", err)
+	}
+	return executor
+}
+
+func TestSubprocessExecutor_Execute(t *testing.T) {
+	executor := newTestSubprocessExecutor(t, IsolationLimits{})
+
+	strategy := &Strategy{
+		Code: `function process(context) {
+			log("summing");
+			context.emit("/count", Object.keys(context.inputs).length);
+			return {sum: context.inputs.a + context.inputs.b, time: getISO()};
+		}`,
+	}
+	fixed := time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC)
+	result := executor.Execute(strategy, ExecutionContext{
+		InputValues: map[string]interface{}{"a": 1.5, "b": 2.25},
+		Now:         fixed,
+	})
+	if result.Error != nil {
+		t.Fatalf("Execute() failed: %v", result.Error)
+	}
+
+	want := map[string]interface{}{"sum": 3.75, "time": "2024-06-01T12:30:00Z"}
+	if !reflect.DeepEqual(result.Result, want) {
+		t.Errorf("result = %v, want %v", result.Result, want)
+	}
+	if len(result.EmittedEvents) != 1 || result.EmittedEvents[0].Topic != "/count" || result.EmittedEvents[0].Value != 2.0 {
+		t.Errorf("emitted events = %v", result.EmittedEvents)
+	}
+	if len(result.LogMessages) != 1 || result.LogMessages[0].Message != "summing" {
+		t.Errorf("log messages = %v", result.LogMessages)
+	}
+}
+
+func TestSubprocessExecutor_ScriptError(t *testing.T) {
+	executor := newTestSubprocessExecutor(t, IsolationLimits{})
+
+	result := executor.Execute(&Strategy{Code: `function process(context) { throw new Error("broken sensor"); }`}, ExecutionContext{})
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "broken sensor") {
+		t.Fatalf("error = %v, want the thrown error", result.Error)
+	}
+	if StackTrace(result.Error) == "" {
+		t.Error("stack trace was not returned from the worker")
+	}
+}
+
+func TestSubprocessExecutor_Limits(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts workers that run until a limit is hit")
+	}
+
+	tests := []struct {
+		name    string
+		limits  IsolationLimits
+		code    string
+		wantErr string
+	}{
+		{
+			name:    "memory",
+			limits:  IsolationLimits{MemoryMB: 32},
+			code:    `function process(context) { var a = []; while (true) { a.push(new Array(10000).fill("x")); } }`,
+			wantErr: "memory limit of 32 MB",
+		},
+		{
+			name:    "cpu time",
+			limits:  IsolationLimits{CPUTime: time.Second},
+			code:    `function process(context) { while (true) {} }`,
+			wantErr: "CPU time limit",
+		},
+		{
+			name:    "timeout",
+			limits:  IsolationLimits{CPUTime: time.Minute, Timeout: 500 * time.Millisecond},
+			code:    `function process(context) { while (true) {} }`,
+			wantErr: "timeout",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := newTestSubprocessExecutor(t, tt.limits)
+			result := executor.Execute(&Strategy{Code: tt.code}, ExecutionContext{})
+			if result.Error == nil || !strings.Contains(result.Error.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want %q", result.Error, tt.wantErr)
+			}
+		})
+	}
+
+	// The executor keeps working after a worker was killed
+	executor := newTestSubprocessExecutor(t, IsolationLimits{})
+	result := executor.Execute(&Strategy{Code: `function process(context) { return 42.5; }`}, ExecutionContext{})
+	if result.Error != nil || result.Result != 42.5 {
+		t.Errorf("execution after a limit = %v, %v", result.Result, result.Error)
+	}
+}
@@ -0,0 +1,36 @@
+//go:build unix
+
+package strategy
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// limitCPUTime caps the CPU time of the current process. The kernel signals
+// SIGXCPU at the limit, which exits with exitCode, and kills the process a
+// second later if it is still running.
+func limitCPUTime(limit time.Duration, exitCode int) error {
+	seconds := uint64((limit + time.Second - 1) / time.Second)
+	if err := syscall.Setrlimit(syscall.RLIMIT_CPU, &syscall.Rlimit{Cur: seconds, Max: seconds + 1}); err != nil {
+		return err
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGXCPU)
+	go func() {
+		<-signals
+		os.Exit(exitCode)
+	}()
+	return nil
+}
+
+// cpuLimitKilled reports whether a worker was killed for exceeding its CPU
+// time limit
+func cpuLimitKilled(exitErr *exec.ExitError) bool {
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	return ok && status.Signaled() && (status.Signal() == syscall.SIGXCPU || status.Signal() == syscall.SIGKILL)
+}
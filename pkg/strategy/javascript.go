@@ -3,7 +3,9 @@ package strategy
 import (
 	"encoding/json"
 	"fmt"
+	"runtime/debug"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/dop251/goja"
@@ -11,62 +13,151 @@ import (
 
 type JavaScriptExecutor struct {
 	maxExecutionTime time.Duration
+	asyncTimeout     time.Duration
+	resolveModule    ModuleResolver
+	programs         *programCache
+	stateStore       StateStore
+
+	// running holds the VMs of in-flight executions and their event loops so
+	// they can be interrupted
+	running      map[*goja.Runtime]*eventLoop
+	runningMutex sync.Mutex
 }
 
 func NewJavaScriptExecutor() *JavaScriptExecutor {
 	return &JavaScriptExecutor{
 		maxExecutionTime: 30 * time.Second,
+		asyncTimeout:     DefaultAsyncTimeout,
+		programs:         newProgramCache(DefaultProgramCacheSize),
+		stateStore:       newMemoryStateStore(),
+		running:          make(map[*goja.Runtime]*eventLoop),
 	}
 }
 
+// SetAsyncTimeout sets how long a promise returned by an async process()
+// may take to settle. The execution timeout still applies.
+func (jse *JavaScriptExecutor) SetAsyncTimeout(timeout time.Duration) {
+	jse.asyncTimeout = timeout
+}
+
+// SetProgramCacheSize sets how many compiled strategies are kept, so
+// unchanged code is not compiled or validated again. 0 disables the cache.
+func (jse *JavaScriptExecutor) SetProgramCacheSize(size int) {
+	jse.programs.setSize(size)
+}
+
+// SetModuleResolver sets how require() finds library strategies
+func (jse *JavaScriptExecutor) SetModuleResolver(resolver ModuleResolver) {
+	jse.resolveModule = resolver
+}
+
+// SetStateStore sets where the state strategies keep with context.setState
+// is persisted. By default it is kept in memory.
+func (jse *JavaScriptExecutor) SetStateStore(store StateStore) {
+	jse.stateStore = store
+}
+
 func (jse *JavaScriptExecutor) Execute(strategy *Strategy, context ExecutionContext) ExecutionResult {
 	start := time.Now()
 
-	result := ExecutionResult{
-		LogMessages:   []string{},
-		EmittedEvents: []EmitEvent{},
-		ExecutionTime: 0,
+	var trace *ExecutionTrace
+	if context.Trace {
+		trace = newExecutionTrace()
 	}
 
 	// Create new VM
 	vm := goja.New()
-
-	// Set up execution timeout
-	done := make(chan bool)
-	timeout := time.After(jse.maxExecutionTime)
+	loop := newEventLoop(vm)
+	jse.track(vm, loop)
+
+	// Set up execution timeout. The script fills its own result, which it
+	// hands over when it finishes; a timed-out script may still be writing
+	// to it.
+	done := make(chan ExecutionResult, 1)
+	limit := jse.maxExecutionTime
+	if strategy.TimeoutMs > 0 {
+		limit = time.Duration(strategy.TimeoutMs) * time.Millisecond
+	}
+	timeoutErr := fmt.Errorf("execution timeout after %v", limit)
+	if !context.Deadline.IsZero() {
+		if remaining := time.Until(context.Deadline); remaining < limit {
+			limit = remaining
+			timeoutErr = fmt.Errorf("execution stopped after %v: %w", limit, ErrDeadlineExceeded)
+		}
+	}
+	timeout := time.After(limit)
 
 	go func() {
+		result := ExecutionResult{
+			LogMessages:   []LogMessage{},
+			EmittedEvents: []EmitEvent{},
+			Trace:         trace,
+		}
 		defer func() {
 			if r := recover(); r != nil {
-				result.Error = fmt.Errorf("JavaScript execution panic: %v", r)
+				// Keep the Go stack; the panic is a bug in a helper or the VM
+				result.Error = &ScriptError{
+					Err:   fmt.Errorf("JavaScript execution panic: %v", r),
+					Stack: string(debug.Stack()),
+				}
 			}
-			done <- true
+			loop.close("execution finished")
+			jse.untrack(vm)
+			done <- result
 		}()
 
 		// Set up the JavaScript environment
-		jse.setupEnvironment(vm, &context, &result)
+		mark := time.Now()
+		logger := &strategyLogger{threshold: strategy.LogLevel, result: &result}
+		state := &executionState{store: jse.stateStore, strategyID: strategy.ID, topicName: context.TopicName}
+		jse.setupEnvironment(vm, &context, &result, logger, state)
+		if trace != nil {
+			trace.lap(&mark, &trace.SetupMicros)
+		}
 
 		// Execute the strategy code
-		_, err := vm.RunString(strategy.Code)
+		entry := jse.programs.get(strategy.Code)
+		err := entry.compileErr
+		if err == nil {
+			_, err = vm.RunProgram(entry.program)
+		}
+		if trace != nil {
+			trace.lap(&mark, &trace.LoadMicros)
+		}
 		if err != nil {
-			result.Error = fmt.Errorf("JavaScript execution error: %w", err)
+			result.Error = fmt.Errorf("JavaScript execution error: %w", withStack(err))
 			return
 		}
 
 		// Call the process function if it exists
 		if processFunc := vm.Get("process"); processFunc != nil {
 			if fn, ok := goja.AssertFunction(processFunc); ok {
-				contextObj := jse.createContextObject(vm, context)
+				contextObj := jse.createContextObject(vm, context, logger, state)
 
 				// Call the process function directly with the context object
 				processResult, err := fn(goja.Undefined(), contextObj)
+
+				// An async process returns a promise; wait for its value
+				if err == nil {
+					if promise, ok := processResult.Export().(*goja.Promise); ok {
+						processResult, err = loop.await(promise, jse.asyncTimeout)
+					}
+				}
+				if trace != nil {
+					trace.lap(&mark, &trace.ProcessMicros)
+				}
 				if err != nil {
-					result.Error = fmt.Errorf("process function execution error: %w", err)
+					result.Error = fmt.Errorf("process function execution error: %w", withStack(err))
 					return
 				}
 
 				// Export the result
 				result.Result = processResult.Export()
+
+				// State is only kept by executions that succeed
+				if err := state.save(); err != nil {
+					result.Error = err
+				}
 			} else {
 				result.Error = fmt.Errorf("process function not found or not a function")
 			}
@@ -76,21 +167,73 @@ func (jse *JavaScriptExecutor) Execute(strategy *Strategy, context ExecutionCont
 	}()
 
 	select {
-	case <-done:
+	case result := <-done:
 		result.ExecutionTime = time.Since(start)
+		return result
 	case <-timeout:
-		result.Error = fmt.Errorf("execution timeout after %v", jse.maxExecutionTime)
-		result.ExecutionTime = jse.maxExecutionTime
+		// Stop the script so it does not keep running in the background
+		vm.Interrupt("execution timeout")
+		loop.close("execution timeout")
+		return ExecutionResult{
+			LogMessages:   []LogMessage{},
+			EmittedEvents: []EmitEvent{},
+			Error:         timeoutErr,
+			ExecutionTime: limit,
+		}
 	}
+}
+
+func (jse *JavaScriptExecutor) track(vm *goja.Runtime, loop *eventLoop) {
+	jse.runningMutex.Lock()
+	defer jse.runningMutex.Unlock()
+	jse.running[vm] = loop
+}
 
-	return result
+func (jse *JavaScriptExecutor) untrack(vm *goja.Runtime) {
+	jse.runningMutex.Lock()
+	defer jse.runningMutex.Unlock()
+	delete(jse.running, vm)
 }
 
+// Interrupt stops every in-flight execution; each fails with an interrupted
+// error. It returns the number of executions interrupted.
+func (jse *JavaScriptExecutor) Interrupt(reason string) int {
+	jse.runningMutex.Lock()
+	defer jse.runningMutex.Unlock()
+
+	for vm, loop := range jse.running {
+		vm.Interrupt(reason)
+		loop.close(reason)
+	}
+	return len(jse.running)
+}
+
+// Validate checks that code compiles and defines process(). The result is
+// cached with the compiled program, so unchanged code is validated once.
 func (jse *JavaScriptExecutor) Validate(code string) error {
+	entry := jse.programs.get(code)
+	if err, ok := jse.programs.validation(entry); ok {
+		return err
+	}
+	err := jse.validate(entry)
+	jse.programs.setValidation(entry, err)
+	return err
+}
+
+func (jse *JavaScriptExecutor) validate(entry *cachedProgram) error {
+	if entry.compileErr != nil {
+		return fmt.Errorf("JavaScript validation error: %w", entry.compileErr)
+	}
+
 	vm := goja.New()
 
-	// Try to compile the code
-	_, err := vm.RunString(code)
+	// Libraries are resolved at execution time; validation only needs require to exist
+	vm.Set("require", func(moduleID string) interface{} {
+		return map[string]interface{}{}
+	})
+
+	// Run the code to define process
+	_, err := vm.RunProgram(entry.program)
 	if err != nil {
 		return fmt.Errorf("JavaScript validation error: %w", err)
 	}
@@ -107,18 +250,45 @@ func (jse *JavaScriptExecutor) Validate(code string) error {
 	return nil
 }
 
-func (jse *JavaScriptExecutor) setupEnvironment(vm *goja.Runtime, context *ExecutionContext, result *ExecutionResult) {
-	// Set up console.log functionality
-	vm.Set("log", func(args ...interface{}) {
+// ValidateLibrary checks that library code compiles as a module
+func (jse *JavaScriptExecutor) ValidateLibrary(code string) error {
+	if _, err := goja.Compile("", wrapModule(code), false); err != nil {
+		return fmt.Errorf("JavaScript validation error: %w", err)
+	}
+	return nil
+}
+
+// strategyLogger records strategy log messages at or above the strategy's log level
+type strategyLogger struct {
+	threshold LogLevel
+	result    *ExecutionResult
+}
+
+// at returns a JavaScript log function that records messages at level
+func (sl *strategyLogger) at(level LogLevel) func(args ...interface{}) {
+	return func(args ...interface{}) {
+		sl.result.Trace.countLog(level)
+		if !sl.threshold.Allows(level) {
+			return
+		}
 		message := make([]string, len(args))
 		for i, arg := range args {
 			message[i] = fmt.Sprintf("%v", arg)
 		}
-		result.LogMessages = append(result.LogMessages, strings.Join(message, " "))
-	})
+		sl.result.LogMessages = append(sl.result.LogMessages, LogMessage{
+			Level:   level,
+			Message: strings.Join(message, " "),
+		})
+	}
+}
+
+func (jse *JavaScriptExecutor) setupEnvironment(vm *goja.Runtime, context *ExecutionContext, result *ExecutionResult, logger *strategyLogger, state *executionState) {
+	// Set up console.log functionality (logs at info level)
+	vm.Set("log", logger.at(LogLevelInfo))
 
 	// Set up emit functionality - supports both 1 and 2 argument patterns
 	vm.Set("emit", func(args ...interface{}) {
+		result.Trace.countEmit()
 		if len(args) == 1 {
 			// Single argument = emit to main topic (empty path)
 			result.EmittedEvents = append(result.EmittedEvents, EmitEvent{
@@ -136,13 +306,16 @@ func (jse *JavaScriptExecutor) setupEnvironment(vm *goja.Runtime, context *Execu
 		}
 	})
 
+	// Set up require for library strategies
+	vm.Set("require", newModuleLoader(vm, jse.resolveModule).requireF)
+
 	// Set up utility functions
 	vm.Set("getTime", func() int64 {
-		return time.Now().Unix()
+		return context.CurrentTime().Unix()
 	})
 
 	vm.Set("getISO", func() string {
-		return time.Now().Format(time.RFC3339)
+		return context.CurrentTime().Format(time.RFC3339)
 	})
 
 	vm.Set("parseJSON", func(jsonStr string) interface{} {
@@ -161,14 +334,31 @@ func (jse *JavaScriptExecutor) setupEnvironment(vm *goja.Runtime, context *Execu
 		return string(data)
 	})
 
+	vm.Set("convert", func(value interface{}, fromUnit, toUnit string) interface{} {
+		var v float64
+		switch n := value.(type) {
+		case int64:
+			v = float64(n)
+		case float64:
+			v = n
+		default:
+			return nil
+		}
+		result, ok := ConvertUnits(v, fromUnit, toUnit)
+		if !ok {
+			return nil
+		}
+		return result
+	})
+
 	// Note: Modern goja versions include a built-in Math object with all standard functions
 	// including sin, cos, tan, sqrt, pow, PI, E, etc. We don't need to override it.
 
 	// Set up context object that will be available to the script
-	vm.Set("context", jse.createContextObject(vm, *context))
+	vm.Set("context", jse.createContextObject(vm, *context, logger, state))
 }
 
-func (jse *JavaScriptExecutor) createContextObject(vm *goja.Runtime, context ExecutionContext) *goja.Object {
+func (jse *JavaScriptExecutor) createContextObject(vm *goja.Runtime, context ExecutionContext, logger *strategyLogger, state *executionState) *goja.Object {
 	obj := vm.NewObject()
 
 	// Set inputs
@@ -185,6 +375,21 @@ func (jse *JavaScriptExecutor) createContextObject(vm *goja.Runtime, context Exe
 	}
 	obj.Set("inputNames", inputNamesObj)
 
+	// input() looks an input up by name or topic; undefined if there is none
+	obj.Set("input", func(key string) goja.Value {
+		value, ok := context.Input(key)
+		if !ok {
+			return goja.Undefined()
+		}
+		return vm.ToValue(value)
+	})
+
+	// getTopic reads any topic's current value; null if there is no such topic
+	obj.Set("getTopic", func(name string) interface{} {
+		value, _ := context.TopicValue(name)
+		return value
+	})
+
 	// Set other context properties
 	obj.Set("triggeringTopic", context.TriggeringTopic)
 	obj.Set("triggeringValue", context.TriggeringValue)
@@ -200,11 +405,34 @@ func (jse *JavaScriptExecutor) createContextObject(vm *goja.Runtime, context Exe
 
 	// Add utility methods to context
 	obj.Set("log", vm.Get("log"))
+	obj.Set("debug", logger.at(LogLevelDebug))
+	obj.Set("info", logger.at(LogLevelInfo))
+	obj.Set("warn", logger.at(LogLevelWarn))
+	obj.Set("error", logger.at(LogLevelError))
 	obj.Set("emit", vm.Get("emit"))
 	obj.Set("getTime", vm.Get("getTime"))
 	obj.Set("getISO", vm.Get("getISO"))
 	obj.Set("parseJSON", vm.Get("parseJSON"))
 	obj.Set("stringify", vm.Get("stringify"))
+	obj.Set("convert", vm.Get("convert"))
+
+	// State kept across executions of the strategy for this topic
+	obj.Set("getState", func(key string) goja.Value {
+		value, ok, err := state.get(key)
+		if err != nil {
+			panic(vm.NewGoError(err))
+		}
+		if !ok {
+			return goja.Undefined()
+		}
+		return vm.ToValue(value)
+	})
+	obj.Set("setState", func(key string, value goja.Value) {
+		if err := state.set(key, value.Export()); err != nil {
+			panic(vm.NewGoError(err))
+		}
+	})
+	obj.Set("clearState", state.clear)
 
 	return obj
 }
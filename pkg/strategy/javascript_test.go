@@ -1,6 +1,8 @@
 package strategy
 
 import (
+	"encoding/json"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -157,12 +159,133 @@ func TestJavaScriptExecutor_Execute_WithLogging(t *testing.T) {
 		t.Errorf("expected 2 log messages, got %d", len(result.LogMessages))
 	}
 
-	if result.LogMessages[0] != "This is a test message" {
-		t.Errorf("first log message = %q, want 'This is a test message'", result.LogMessages[0])
+	if result.LogMessages[0].Message != "This is a test message" {
+		t.Errorf("first log message = %q, want 'This is a test message'", result.LogMessages[0].Message)
 	}
 
-	if result.LogMessages[1] != "Value is: 42" {
-		t.Errorf("second log message = %q, want 'Value is: 42'", result.LogMessages[1])
+	if result.LogMessages[1].Message != "Value is: 42" {
+		t.Errorf("second log message = %q, want 'Value is: 42'", result.LogMessages[1].Message)
+	}
+
+	if result.LogMessages[0].Level != LogLevelInfo {
+		t.Errorf("context.log level = %q, want %q", result.LogMessages[0].Level, LogLevelInfo)
+	}
+}
+
+func TestJavaScriptExecutor_Execute_LogLevels(t *testing.T) {
+	code := `function process(context) {
+		context.debug('debug message');
+		context.info('info message');
+		context.warn('warn message');
+		context.error('error message');
+		return null;
+	}`
+
+	tests := []struct {
+		name     string
+		logLevel LogLevel
+		want     []LogMessage
+	}{
+		{
+			name:     "default filters debug",
+			logLevel: "",
+			want: []LogMessage{
+				{Level: LogLevelInfo, Message: "info message"},
+				{Level: LogLevelWarn, Message: "warn message"},
+				{Level: LogLevelError, Message: "error message"},
+			},
+		},
+		{
+			name:     "info filters debug",
+			logLevel: LogLevelInfo,
+			want: []LogMessage{
+				{Level: LogLevelInfo, Message: "info message"},
+				{Level: LogLevelWarn, Message: "warn message"},
+				{Level: LogLevelError, Message: "error message"},
+			},
+		},
+		{
+			name:     "debug keeps everything",
+			logLevel: LogLevelDebug,
+			want: []LogMessage{
+				{Level: LogLevelDebug, Message: "debug message"},
+				{Level: LogLevelInfo, Message: "info message"},
+				{Level: LogLevelWarn, Message: "warn message"},
+				{Level: LogLevelError, Message: "error message"},
+			},
+		},
+		{
+			name:     "error keeps only errors",
+			logLevel: LogLevelError,
+			want: []LogMessage{
+				{Level: LogLevelError, Message: "error message"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := NewJavaScriptExecutor()
+			result := executor.Execute(&Strategy{Code: code, LogLevel: tt.logLevel}, ExecutionContext{})
+			if result.Error != nil {
+				t.Fatalf("Execute() failed: %v", result.Error)
+			}
+			if !reflect.DeepEqual(result.LogMessages, tt.want) {
+				t.Errorf("LogMessages = %v, want %v", result.LogMessages, tt.want)
+			}
+		})
+	}
+}
+
+func TestJavaScriptExecutor_Execute_Trace(t *testing.T) {
+	code := `var threshold = 3;
+	function process(context) {
+		for (var i = 0; i < threshold; i++) {
+			context.emit('count', i);
+			context.info('emitted', i);
+		}
+		context.debug('below the log level');
+		log('done');
+		emit('main');
+		return null;
+	}`
+
+	executor := NewJavaScriptExecutor()
+	result := executor.Execute(&Strategy{Code: code}, ExecutionContext{Trace: true})
+	if result.Error != nil {
+		t.Fatalf("Execute() failed: %v", result.Error)
+	}
+
+	trace := result.Trace
+	if trace == nil {
+		t.Fatal("Execute() returned no trace")
+	}
+	if trace.EmitCalls != 4 {
+		t.Errorf("EmitCalls = %d, want 4", trace.EmitCalls)
+	}
+	want := map[LogLevel]int{LogLevelInfo: 4, LogLevelDebug: 1}
+	if !reflect.DeepEqual(trace.LogCalls, want) {
+		t.Errorf("LogCalls = %v, want %v", trace.LogCalls, want)
+	}
+	if trace.SetupMicros < 0 || trace.LoadMicros < 0 || trace.ProcessMicros < 0 {
+		t.Errorf("negative phase times in %+v", trace)
+	}
+
+	// Tracing is opt-in
+	if result := executor.Execute(&Strategy{Code: code}, ExecutionContext{}); result.Trace != nil {
+		t.Errorf("untraced execution returned a trace: %+v", result.Trace)
+	}
+}
+
+func TestParseLogLevel(t *testing.T) {
+	if level, err := ParseLogLevel(""); err != nil || level != LogLevelInfo {
+		t.Errorf("ParseLogLevel(\"\") = %q, %v, want info", level, err)
+	}
+	if level, err := ParseLogLevel("warn"); err != nil || level != LogLevelWarn {
+		t.Errorf("ParseLogLevel(\"warn\") = %q, %v, want warn", level, err)
+	}
+	if _, err := ParseLogLevel("verbose"); err == nil {
+		t.Error("ParseLogLevel should reject an unknown level")
 	}
 }
 
@@ -202,6 +325,77 @@ func TestJavaScriptExecutor_Execute_WithEmit(t *testing.T) {
 	}
 }
 
+func TestJavaScriptExecutor_Execute_InjectedTime(t *testing.T) {
+	executor := NewJavaScriptExecutor()
+
+	strategy := &Strategy{
+		Code: `function process(context) {
+			return {time: getTime(), context_time: context.getTime(), iso: context.getISO()};
+		}`,
+	}
+
+	fixed := time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC)
+	result := executor.Execute(strategy, ExecutionContext{Now: fixed})
+	if result.Error != nil {
+		t.Fatalf("Execute() failed: %v", result.Error)
+	}
+	resultMap := result.Result.(map[string]interface{})
+	if resultMap["time"] != fixed.Unix() || resultMap["context_time"] != fixed.Unix() {
+		t.Errorf("getTime() = %v / %v, want injected %d", resultMap["time"], resultMap["context_time"], fixed.Unix())
+	}
+	if resultMap["iso"] != "2024-06-01T12:30:00Z" {
+		t.Errorf("getISO() = %v, want injected time", resultMap["iso"])
+	}
+
+	// Without an injected time the real time is used
+	before := time.Now().Unix()
+	result = executor.Execute(strategy, ExecutionContext{})
+	if result.Error != nil {
+		t.Fatalf("Execute() failed: %v", result.Error)
+	}
+	now, _ := result.Result.(map[string]interface{})["time"].(int64)
+	if now < before || now > time.Now().Unix() {
+		t.Errorf("getTime() = %d, want the real time (%d)", now, before)
+	}
+}
+
+func TestJavaScriptExecutor_Execute_InputHelper(t *testing.T) {
+	executor := NewJavaScriptExecutor()
+
+	strategy := &Strategy{
+		Code: `function process(context) {
+			return {
+				by_alias: context.input("living_room"),
+				by_aliased_path: context.input("sensors/living/temp"),
+				by_path: context.input("sensors/outside/temp"),
+				missing: context.input("garage") === undefined
+			};
+		}`,
+	}
+
+	context := ExecutionContext{
+		InputValues: map[string]interface{}{
+			"living_room":          21.5,
+			"sensors/outside/temp": 9.5,
+		},
+		InputNames: map[string]string{"sensors/living/temp": "living_room"},
+	}
+
+	result := executor.Execute(strategy, context)
+	if result.Error != nil {
+		t.Fatalf("Execute() failed: %v", result.Error)
+	}
+	want := map[string]interface{}{
+		"by_alias":        21.5,
+		"by_aliased_path": 21.5,
+		"by_path":         9.5,
+		"missing":         true,
+	}
+	if !reflect.DeepEqual(result.Result, want) {
+		t.Errorf("input() results = %#v, want %#v", result.Result, want)
+	}
+}
+
 func TestJavaScriptExecutor_Execute_WithUtilityFunctions(t *testing.T) {
 	executor := NewJavaScriptExecutor()
 
@@ -546,6 +740,44 @@ func TestJavaScriptExecutor_Execute_Timeout(t *testing.T) {
 	}
 }
 
+func TestJavaScriptExecutor_Execute_StrategyTimeout(t *testing.T) {
+	// Busy for 100ms, longer than the short timeout but well within the long one
+	code := `function process(context) {
+		var until = Date.now() + 100;
+		while (Date.now() < until) {}
+		return 'done';
+	}`
+
+	tests := []struct {
+		name      string
+		timeoutMs int
+		wantErr   bool
+	}{
+		{name: "10ms timeout", timeoutMs: 10, wantErr: true},
+		{name: "5s timeout", timeoutMs: 5000, wantErr: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := NewJavaScriptExecutor()
+			result := executor.Execute(&Strategy{Code: code, TimeoutMs: tt.timeoutMs}, ExecutionContext{InputValues: map[string]interface{}{}})
+
+			if tt.wantErr {
+				if result.Error == nil || !strings.Contains(result.Error.Error(), "execution timeout after 10ms") {
+					t.Errorf("expected a 10ms timeout error, got: %v", result.Error)
+				}
+				return
+			}
+			if result.Error != nil {
+				t.Fatalf("unexpected error: %v", result.Error)
+			}
+			if result.Result != "done" {
+				t.Errorf("result = %v, want done", result.Result)
+			}
+		})
+	}
+}
+
 func TestJavaScriptExecutor_Execute_ComplexStrategy(t *testing.T) {
 	executor := NewJavaScriptExecutor()
 
@@ -804,3 +1036,183 @@ func TestJavaScriptExecutor_Execute_WithLastOutputs(t *testing.T) {
 		t.Errorf("third execution change_count = %v, want 2", result3Map["change_count"])
 	}
 }
+
+// jsonStateStore saves strategy state as JSON, like the database does
+type jsonStateStore struct {
+	saved map[string][]byte
+}
+
+func (s *jsonStateStore) LoadStrategyState(strategyID, topicName string) (map[string]interface{}, error) {
+	data, ok := s.saved[strategyID+" "+topicName]
+	if !ok {
+		return nil, nil
+	}
+	var state map[string]interface{}
+	err := json.Unmarshal(data, &state)
+	return state, err
+}
+
+func (s *jsonStateStore) SaveStrategyState(strategyID, topicName string, state map[string]interface{}) error {
+	if len(state) == 0 {
+		delete(s.saved, strategyID+" "+topicName)
+		return nil
+	}
+	data, err := json.Marshal(state)
+	s.saved[strategyID+" "+topicName] = data
+	return err
+}
+
+func TestJavaScriptExecutor_Execute_State(t *testing.T) {
+	store := &jsonStateStore{saved: make(map[string][]byte)}
+	strategy := &Strategy{
+		ID: "counter",
+		Code: `function process(context) {
+			if (context.inputs.reset) {
+				context.clearState();
+				return 0;
+			}
+			var count = (context.getState('count') || 0) + 1;
+			context.setState('count', count);
+			context.setState('window', (context.getState('window') || []).concat([context.inputs.value]).slice(-2));
+			if (context.inputs.fail) {
+				throw new Error('failed after setting state');
+			}
+			return count;
+		}`,
+	}
+
+	execute := func(executor *JavaScriptExecutor, topic string, inputs map[string]interface{}) interface{} {
+		t.Helper()
+		result := executor.Execute(strategy, ExecutionContext{InputValues: inputs, TopicName: topic})
+		if result.Error != nil {
+			t.Fatalf("Execute for %q failed: %v", topic, result.Error)
+		}
+		return result.Result
+	}
+
+	executor := NewJavaScriptExecutor()
+	executor.SetStateStore(store)
+	if got := execute(executor, "room/a", map[string]interface{}{"value": 1}); got != int64(1) {
+		t.Errorf("first execution = %v, want 1", got)
+	}
+	if got := execute(executor, "room/a", map[string]interface{}{"value": 2}); got != int64(2) {
+		t.Errorf("second execution = %v, want 2", got)
+	}
+
+	// Each topic has its own state
+	if got := execute(executor, "room/b", map[string]interface{}{"value": 5}); got != int64(1) {
+		t.Errorf("other topic = %v, want its own count of 1", got)
+	}
+
+	// Failed executions do not change the state
+	if result := executor.Execute(strategy, ExecutionContext{InputValues: map[string]interface{}{"value": 9, "fail": true}, TopicName: "room/a"}); result.Error == nil {
+		t.Fatal("expected the execution to fail")
+	}
+
+	// State survives a restart through the store
+	restarted := NewJavaScriptExecutor()
+	restarted.SetStateStore(store)
+	if got := execute(restarted, "room/a", map[string]interface{}{"value": 3}); got != int64(3) {
+		t.Errorf("execution after restart = %v, want 3", got)
+	}
+	state, err := store.LoadStrategyState("counter", "room/a")
+	if err != nil {
+		t.Fatalf("LoadStrategyState failed: %v", err)
+	}
+	if want := []interface{}{2.0, 3.0}; !reflect.DeepEqual(state["window"], want) {
+		t.Errorf("saved window = %v, want %v", state["window"], want)
+	}
+
+	// Executions outside a topic neither see nor keep state
+	if got := execute(restarted, "", map[string]interface{}{"value": 4}); got != int64(1) {
+		t.Errorf("execution without a topic = %v, want 1", got)
+	}
+
+	execute(restarted, "room/a", map[string]interface{}{"reset": true})
+	if _, ok := store.saved["counter room/a"]; ok {
+		t.Error("clearState did not remove the saved state")
+	}
+	if _, ok := store.saved["counter room/b"]; !ok {
+		t.Error("clearState removed another topic's state")
+	}
+}
+
+func TestJavaScriptExecutor_Execute_Async(t *testing.T) {
+	tests := []struct {
+		name         string
+		code         string
+		asyncTimeout time.Duration
+		expected     interface{}
+		errorMsg     string
+	}{
+		{
+			name:     "resolves immediately",
+			code:     `async function process(context) { return context.inputs.value; }`,
+			expected: 1.5,
+		},
+		{
+			name: "awaits a timer",
+			code: `async function process(context) {
+				await new Promise(function(resolve) { setTimeout(resolve, 5); });
+				return context.inputs.value + 0.25;
+			}`,
+			expected: 1.75,
+		},
+		{
+			name: "resolves with an object",
+			code: `async function process(context) {
+				var value = await new Promise(function(resolve) { setTimeout(resolve, 1, 'later'); });
+				return { state: value };
+			}`,
+			expected: map[string]interface{}{"state": "later"},
+		},
+		{
+			name: "cleared timer never settles",
+			code: `async function process(context) {
+				await new Promise(function(resolve) { clearTimeout(setTimeout(resolve, 1)); });
+				return 'unreachable';
+			}`,
+			errorMsg: "never settled",
+		},
+		{
+			name:     "rejected",
+			code:     `async function process(context) { throw new Error('boom'); }`,
+			errorMsg: "rejected: Error: boom",
+		},
+		{
+			name: "slower than the async timeout",
+			code: `async function process(context) {
+				await new Promise(function(resolve) { setTimeout(resolve, 1000); });
+				return 'late';
+			}`,
+			asyncTimeout: 10 * time.Millisecond,
+			errorMsg:     "did not settle within 10ms",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := NewJavaScriptExecutor()
+			if tt.asyncTimeout > 0 {
+				executor.SetAsyncTimeout(tt.asyncTimeout)
+			}
+
+			result := executor.Execute(&Strategy{Code: tt.code}, ExecutionContext{
+				InputValues: map[string]interface{}{"value": 1.5},
+			})
+
+			if tt.errorMsg != "" {
+				if result.Error == nil || !strings.Contains(result.Error.Error(), tt.errorMsg) {
+					t.Fatalf("expected error containing %q, got %v", tt.errorMsg, result.Error)
+				}
+				return
+			}
+			if result.Error != nil {
+				t.Fatalf("unexpected error: %v", result.Error)
+			}
+			if !reflect.DeepEqual(result.Result, tt.expected) {
+				t.Errorf("expected result %#v, got %#v", tt.expected, result.Result)
+			}
+		})
+	}
+}
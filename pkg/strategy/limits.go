@@ -0,0 +1,101 @@
+package strategy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrInputLimitExceeded is returned for executions rejected because their
+// inputs exceed the engine's input limits
+var ErrInputLimitExceeded = errors.New("strategy input limit exceeded")
+
+// Input limit policies
+const (
+	InputLimitTruncate = "truncate"
+	InputLimitReject   = "reject"
+)
+
+// InputLimits bounds the inputs of an execution: how many there are and the
+// total JSON-encoded size of their values. 0 disables a limit.
+type InputLimits struct {
+	MaxInputs int
+	MaxBytes  int
+	// Policy is InputLimitTruncate (run with inputs dropped) or
+	// InputLimitReject (fail the execution)
+	Policy string
+}
+
+func (l InputLimits) enabled() bool {
+	return l.MaxInputs > 0 || l.MaxBytes > 0
+}
+
+// SetInputLimits sets the limits applied to the inputs of every execution
+func (e *Engine) SetInputLimits(limits InputLimits) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	e.inputLimits = limits
+}
+
+// limitInputs applies the input limits to an execution's inputs. Truncating
+// keeps the triggering input, then the others in name order while they fit.
+func (e *Engine) limitInputs(strategyID string, limits InputLimits, inputs map[string]interface{}, triggerTopic string) (map[string]interface{}, error) {
+	if !limits.enabled() {
+		return inputs, nil
+	}
+
+	names := make([]string, 0, len(inputs))
+	for name := range inputs {
+		if name != triggerTopic {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if _, ok := inputs[triggerTopic]; ok {
+		names = append([]string{triggerTopic}, names...)
+	}
+
+	sizes := make(map[string]int, len(names))
+	total := 0
+	for _, name := range names {
+		data, err := json.Marshal(inputs[name])
+		if err != nil {
+			// Values the JSON encoder rejects are still usable by strategies
+			data = []byte(fmt.Sprint(inputs[name]))
+		}
+		sizes[name] = len(data)
+		total += len(data)
+	}
+
+	countExceeded := limits.MaxInputs > 0 && len(names) > limits.MaxInputs
+	sizeExceeded := limits.MaxBytes > 0 && total > limits.MaxBytes
+	if !countExceeded && !sizeExceeded {
+		return inputs, nil
+	}
+
+	if limits.Policy == InputLimitReject {
+		return nil, fmt.Errorf("strategy %s has %d inputs of %d bytes (limits %d inputs, %d bytes): %w",
+			strategyID, len(names), total, limits.MaxInputs, limits.MaxBytes, ErrInputLimitExceeded)
+	}
+
+	kept := make(map[string]interface{}, len(names))
+	var dropped []string
+	size := 0
+	for i, name := range names {
+		fits := (limits.MaxInputs <= 0 || len(kept) < limits.MaxInputs) &&
+			(limits.MaxBytes <= 0 || size+sizes[name] <= limits.MaxBytes)
+		if fits || (i == 0 && name == triggerTopic) {
+			kept[name] = inputs[name]
+			size += sizes[name]
+			continue
+		}
+		dropped = append(dropped, name)
+	}
+
+	e.logger.Printf("Warning: strategy %s has %d inputs of %d bytes, over the input limits; dropped %d: %s",
+		strategyID, len(names), total, len(dropped), strings.Join(dropped, ", "))
+	return kept, nil
+}
@@ -0,0 +1,60 @@
+package strategy
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestEngineInputLimits(t *testing.T) {
+	engine := NewEngine(nil)
+	code := `function process(context) { return Object.keys(context.inputs).sort().join(","); }`
+	if err := engine.AddStrategy(&Strategy{ID: "keys", Name: "Keys", Code: code, Language: "javascript"}); err != nil {
+		t.Fatalf("Failed to add strategy: %v", err)
+	}
+
+	// Ten inputs of 3 bytes each, triggered by the eighth
+	inputs := make(map[string]interface{})
+	for i := 0; i < 10; i++ {
+		inputs[fmt.Sprintf("in/%02d", i)] = 1.5
+	}
+	all := "in/00,in/01,in/02,in/03,in/04,in/05,in/06,in/07,in/08,in/09"
+
+	tests := []struct {
+		name    string
+		limits  InputLimits
+		want    string
+		wantErr bool
+	}{
+		{name: "no limits", want: all},
+		{name: "within limits", limits: InputLimits{MaxInputs: 10, MaxBytes: 30, Policy: InputLimitTruncate}, want: all},
+		{name: "truncate by count keeps trigger", limits: InputLimits{MaxInputs: 3, Policy: InputLimitTruncate}, want: "in/00,in/01,in/07"},
+		{name: "truncate by size keeps trigger", limits: InputLimits{MaxBytes: 9, Policy: InputLimitTruncate}, want: "in/00,in/01,in/07"},
+		{name: "trigger kept over size limit", limits: InputLimits{MaxBytes: 2, Policy: InputLimitTruncate}, want: "in/07"},
+		{name: "reject by count", limits: InputLimits{MaxInputs: 9, Policy: InputLimitReject}, wantErr: true},
+		{name: "reject by size", limits: InputLimits{MaxBytes: 29, Policy: InputLimitReject}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine.SetInputLimits(tt.limits)
+			events, _, err := engine.ExecuteStrategyWithLogs("keys", inputs, nil, "in/07", nil, nil)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInputLimitExceeded) {
+					t.Fatalf("expected ErrInputLimitExceeded, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExecuteStrategyWithLogs failed: %v", err)
+			}
+			if len(events) != 1 || events[0].Value != tt.want {
+				t.Errorf("strategy saw inputs %v, want %s", events, tt.want)
+			}
+		})
+	}
+
+	if len(inputs) != 10 {
+		t.Errorf("caller's inputs were modified: %v", inputs)
+	}
+}
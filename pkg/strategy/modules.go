@@ -0,0 +1,92 @@
+package strategy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// ModuleResolver looks up a library strategy by ID for require()
+type ModuleResolver func(moduleID string) (*Strategy, error)
+
+// LibraryValidator is implemented by executors that support library strategies
+type LibraryValidator interface {
+	ValidateLibrary(code string) error
+}
+
+// wrapModule wraps library code in a CommonJS-style function so it gets its
+// own scope with module, exports and require
+func wrapModule(code string) string {
+	return "(function(module, exports, require) {\n" + code + "\n})"
+}
+
+// moduleLoader implements require() for a single execution. Each library is
+// evaluated at most once per execution and circular requires are rejected.
+type moduleLoader struct {
+	vm       *goja.Runtime
+	resolve  ModuleResolver
+	cache    map[string]goja.Value
+	loading  []string
+	requireF goja.Value
+}
+
+func newModuleLoader(vm *goja.Runtime, resolve ModuleResolver) *moduleLoader {
+	loader := &moduleLoader{
+		vm:      vm,
+		resolve: resolve,
+		cache:   make(map[string]goja.Value),
+	}
+	loader.requireF = vm.ToValue(loader.require)
+	return loader
+}
+
+func (l *moduleLoader) require(call goja.FunctionCall) goja.Value {
+	moduleID := call.Argument(0).String()
+
+	if exports, ok := l.cache[moduleID]; ok {
+		return exports
+	}
+
+	for _, loading := range l.loading {
+		if loading == moduleID {
+			chain := strings.Join(append(l.loading, moduleID), " -> ")
+			panic(l.vm.NewGoError(fmt.Errorf("circular require: %s", chain)))
+		}
+	}
+
+	if l.resolve == nil {
+		panic(l.vm.NewGoError(fmt.Errorf("cannot require %s: no module resolver configured", moduleID)))
+	}
+	library, err := l.resolve(moduleID)
+	if err != nil {
+		panic(l.vm.NewGoError(fmt.Errorf("cannot require %s: %w", moduleID, err)))
+	}
+	if library.Language != "" && library.Language != "javascript" {
+		panic(l.vm.NewGoError(fmt.Errorf("cannot require %s: library language %s is not javascript", moduleID, library.Language)))
+	}
+
+	l.loading = append(l.loading, moduleID)
+	defer func() {
+		l.loading = l.loading[:len(l.loading)-1]
+	}()
+
+	wrapper, err := l.vm.RunScript(moduleID, wrapModule(library.Code))
+	if err != nil {
+		panic(l.vm.NewGoError(fmt.Errorf("failed to load library %s: %w", moduleID, err)))
+	}
+	fn, ok := goja.AssertFunction(wrapper)
+	if !ok {
+		panic(l.vm.NewGoError(fmt.Errorf("failed to load library %s", moduleID)))
+	}
+
+	module := l.vm.NewObject()
+	module.Set("exports", l.vm.NewObject())
+	if _, err := fn(goja.Undefined(), module, module.Get("exports"), l.requireF); err != nil {
+		panic(err)
+	}
+
+	exports := module.Get("exports")
+	l.cache[moduleID] = exports
+	return exports
+}
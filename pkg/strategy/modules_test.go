@@ -0,0 +1,119 @@
+package strategy
+
+import (
+	"strings"
+	"testing"
+)
+
+func newLibraryTestEngine(t *testing.T, strategies ...*Strategy) *Engine {
+	t.Helper()
+
+	engine := NewEngine(nil)
+	for _, strat := range strategies {
+		if strat.Language == "" {
+			strat.Language = "javascript"
+		}
+		if err := engine.AddStrategy(strat); err != nil {
+			t.Fatalf("Failed to add strategy %s: %v", strat.ID, err)
+		}
+	}
+	return engine
+}
+
+func TestRequireLibrary(t *testing.T) {
+	engine := newLibraryTestEngine(t,
+		&Strategy{
+			ID:      "lib/math",
+			Name:    "Math helpers",
+			Library: true,
+			Code:    `exports.celsiusToFahrenheit = function(c) { return c * 9 / 5 + 32; };`,
+		},
+		&Strategy{
+			ID:      "lib/format",
+			Name:    "Format helpers",
+			Library: true,
+			Code: `var math = require("lib/math");
+module.exports = function(c) { return math.celsiusToFahrenheit(c) + "F"; };`,
+		},
+		&Strategy{
+			ID:   "fahrenheit",
+			Name: "Fahrenheit",
+			Code: `var format = require("lib/format");
+function process(context) { return format(context.inputs.temp); }`,
+		},
+	)
+
+	events, err := engine.ExecuteStrategy("fahrenheit", map[string]interface{}{"temp": 100}, nil, "temp", nil, nil)
+	if err != nil {
+		t.Fatalf("ExecuteStrategy() failed: %v", err)
+	}
+	if len(events) != 1 || events[0].Value != "212F" {
+		t.Errorf("events = %v, want a single main event with value 212F", events)
+	}
+}
+
+func TestRequireErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		strategies []*Strategy
+		wantErr    string
+	}{
+		{
+			name: "circular require",
+			strategies: []*Strategy{
+				{ID: "lib/a", Name: "A", Library: true, Code: `require("lib/b");`},
+				{ID: "lib/b", Name: "B", Library: true, Code: `require("lib/a");`},
+				{ID: "main", Name: "Main", Code: `require("lib/a"); function process(context) { return 1; }`},
+			},
+			wantErr: "circular require: lib/a -> lib/b -> lib/a",
+		},
+		{
+			name: "missing library",
+			strategies: []*Strategy{
+				{ID: "main", Name: "Main", Code: `var x = require("lib/missing"); function process(context) { return 1; }`},
+			},
+			wantErr: "library lib/missing not found",
+		},
+		{
+			name: "non-library strategy",
+			strategies: []*Strategy{
+				{ID: "other", Name: "Other", Code: `function process(context) { return 1; }`},
+				{ID: "main", Name: "Main", Code: `var x = require("other"); function process(context) { return 1; }`},
+			},
+			wantErr: "strategy other is not a library",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := newLibraryTestEngine(t, tt.strategies...)
+
+			_, err := engine.ExecuteStrategy("main", nil, nil, "in", nil, nil)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLibraryStrategyValidation(t *testing.T) {
+	engine := newLibraryTestEngine(t, &Strategy{
+		ID:      "lib/util",
+		Name:    "Util",
+		Library: true,
+		Code:    `exports.double = function(x) { return x * 2; };`,
+	})
+
+	// Libraries have no process function and cannot be executed directly
+	if _, err := engine.ExecuteStrategy("lib/util", nil, nil, "in", nil, nil); err == nil {
+		t.Error("expected executing a library to fail")
+	}
+
+	err := engine.AddStrategy(&Strategy{ID: "lib/broken", Name: "Broken", Language: "javascript", Library: true, Code: `exports.x = function( {`})
+	if err == nil {
+		t.Error("expected a library with a syntax error to be rejected")
+	}
+}
@@ -0,0 +1,48 @@
+package strategy
+
+// recordOutcome remembers the result of a strategy's latest execution
+func (e *Engine) recordOutcome(strategyID string, err error) {
+	e.outcomeMutex.Lock()
+	defer e.outcomeMutex.Unlock()
+
+	if err == nil {
+		delete(e.lastErrors, strategyID)
+		return
+	}
+	e.lastErrors[strategyID] = err
+}
+
+// clearOutcome forgets a strategy's last execution, used when its code
+// changes or it is removed
+func (e *Engine) clearOutcome(strategyID string) {
+	e.outcomeMutex.Lock()
+	defer e.outcomeMutex.Unlock()
+
+	delete(e.lastErrors, strategyID)
+}
+
+// LastError returns the error of a strategy's latest execution, or nil if it
+// succeeded or has not run
+func (e *Engine) LastError(strategyID string) error {
+	e.outcomeMutex.Lock()
+	defer e.outcomeMutex.Unlock()
+
+	return e.lastErrors[strategyID]
+}
+
+// GetFailedStrategyCount returns how many loaded strategies failed their
+// latest execution
+func (e *Engine) GetFailedStrategyCount() int {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	e.outcomeMutex.Lock()
+	defer e.outcomeMutex.Unlock()
+
+	failed := 0
+	for strategyID := range e.lastErrors {
+		if _, exists := e.strategies[strategyID]; exists {
+			failed++
+		}
+	}
+	return failed
+}
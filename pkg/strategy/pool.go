@@ -0,0 +1,105 @@
+package strategy
+
+import (
+	"runtime"
+	"sync"
+)
+
+// executionPool runs strategy executions on a fixed number of workers, each
+// locked to its own OS thread. Bounding the workers below GOMAXPROCS leaves
+// processors free for MQTT and web handling while CPU-heavy strategies run.
+type executionPool struct {
+	jobs    chan func()
+	wg      sync.WaitGroup
+	mutex   sync.RWMutex
+	stopped bool
+}
+
+func newExecutionPool(size int) *executionPool {
+	pool := &executionPool{jobs: make(chan func())}
+	for i := 0; i < size; i++ {
+		pool.wg.Add(1)
+		go pool.work()
+	}
+	return pool
+}
+
+func (p *executionPool) work() {
+	defer p.wg.Done()
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	for job := range p.jobs {
+		job()
+	}
+}
+
+// run executes job on a pool worker and waits for it to finish. Jobs
+// submitted after the pool is stopped run on the caller's goroutine.
+func (p *executionPool) run(job func()) {
+	done := make(chan struct{})
+
+	p.mutex.RLock()
+	if p.stopped {
+		p.mutex.RUnlock()
+		job()
+		return
+	}
+	p.jobs <- func() {
+		defer close(done)
+		job()
+	}
+	p.mutex.RUnlock()
+
+	<-done
+}
+
+// stop waits for queued executions to finish and stops the workers
+func (p *executionPool) stop() {
+	p.mutex.Lock()
+	p.stopped = true
+	close(p.jobs)
+	p.mutex.Unlock()
+
+	p.wg.Wait()
+}
+
+// SetExecutionPoolSize runs strategy executions on a dedicated pool of size
+// workers. A size of 0 stops the pool and runs executions on the caller's
+// goroutine.
+func (e *Engine) SetExecutionPoolSize(size int) {
+	e.mutex.Lock()
+	previous := e.pool
+	e.pool = nil
+	if size > 0 {
+		e.pool = newExecutionPool(size)
+	}
+	e.mutex.Unlock()
+
+	if previous != nil {
+		previous.stop()
+	}
+	if size > 0 && size >= runtime.GOMAXPROCS(0) {
+		e.logger.Printf("Warning: strategy execution pool size %d is not below GOMAXPROCS (%d); heavy strategies may still delay MQTT handling",
+			size, runtime.GOMAXPROCS(0))
+	}
+}
+
+// Close stops the execution pool, if any
+func (e *Engine) Close() {
+	e.SetExecutionPoolSize(0)
+}
+
+// execute runs the executor on the execution pool when one is configured
+func (e *Engine) execute(pool *executionPool, executor LanguageExecutor, strategy *Strategy, context ExecutionContext) ExecutionResult {
+	if pool == nil {
+		return executor.Execute(strategy, context)
+	}
+
+	var result ExecutionResult
+	pool.run(func() {
+		result = executor.Execute(strategy, context)
+	})
+	return result
+}
@@ -0,0 +1,127 @@
+package strategy
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// busyExecutor spins for duration and tracks how many executions overlap
+type busyExecutor struct {
+	duration time.Duration
+	running  atomic.Int32
+	peak     atomic.Int32
+}
+
+func (b *busyExecutor) Validate(code string) error { return nil }
+
+func (b *busyExecutor) Execute(strategy *Strategy, context ExecutionContext) ExecutionResult {
+	running := b.running.Add(1)
+	defer b.running.Add(-1)
+	for {
+		peak := b.peak.Load()
+		if running <= peak || b.peak.CompareAndSwap(peak, running) {
+			break
+		}
+	}
+
+	deadline := time.Now().Add(b.duration)
+	for time.Now().Before(deadline) {
+	}
+	return ExecutionResult{Result: "done"}
+}
+
+func newPoolTestEngine(t testing.TB, executor LanguageExecutor, poolSize int) *Engine {
+	t.Helper()
+
+	engine := NewEngine(nil)
+	engine.RegisterExecutor("busy", executor)
+	if err := engine.AddStrategy(&Strategy{ID: "heavy", Name: "Heavy", Code: "code", Language: "busy"}); err != nil {
+		t.Fatalf("AddStrategy() failed: %v", err)
+	}
+	engine.SetExecutionPoolSize(poolSize)
+	t.Cleanup(engine.Close)
+	return engine
+}
+
+func TestExecutionPoolLimitsConcurrency(t *testing.T) {
+	previous := runtime.GOMAXPROCS(4)
+	defer runtime.GOMAXPROCS(previous)
+
+	executor := &busyExecutor{duration: 20 * time.Millisecond}
+	engine := newPoolTestEngine(t, executor, 1)
+
+	// Simulates the MQTT read loop: measure how late a 1ms ticker fires
+	// while heavy strategies run
+	stop := make(chan struct{})
+	var maxDelay time.Duration
+	probeDone := make(chan struct{})
+	go func() {
+		defer close(probeDone)
+		last := time.Now()
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				if delay := now.Sub(last); delay > maxDelay {
+					maxDelay = delay
+				}
+				last = now
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := engine.ExecuteStrategy("heavy", nil, nil, "", nil, nil); err != nil {
+				t.Errorf("ExecuteStrategy() failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	close(stop)
+	<-probeDone
+
+	if peak := executor.peak.Load(); peak != 1 {
+		t.Errorf("peak concurrent executions = %d, want 1", peak)
+	}
+	if maxDelay > 50*time.Millisecond {
+		t.Errorf("message handling stalled for %s while strategies ran", maxDelay)
+	}
+}
+
+func TestExecutionPoolStop(t *testing.T) {
+	executor := &busyExecutor{}
+	engine := newPoolTestEngine(t, executor, 2)
+
+	engine.Close()
+	if engine.pool != nil {
+		t.Fatal("Close() left the execution pool running")
+	}
+
+	// Executions still run inline without a pool
+	events, err := engine.ExecuteStrategy("heavy", nil, nil, "", nil, nil)
+	if err != nil || len(events) != 1 || events[0].Value != "done" {
+		t.Errorf("ExecuteStrategy() = %v, %v", events, err)
+	}
+}
+
+func BenchmarkExecuteStrategyPooled(b *testing.B) {
+	engine := newPoolTestEngine(b, &busyExecutor{}, 2)
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := engine.ExecuteStrategy("heavy", nil, nil, "", nil, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,155 @@
+package strategy
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PostProcessor transforms a value a strategy produced before it becomes an
+// event. now is the execution time, honoring an injected time.
+type PostProcessor func(value interface{}, now time.Time) interface{}
+
+// PostProcessorNone selects an empty pipeline, disabling the default one
+const PostProcessorNone = "none"
+
+// ParsePostProcessor builds a built-in post-processor from its spec:
+//
+//	round[:decimals]   round numbers, to 2 decimals by default
+//	clamp:min:max      bound numbers to [min, max]
+//	timestamp[:field]  add the execution time to objects, wrapping other
+//	                   values as {"value": ..., field: ...}
+//
+// Numbers nested in objects and arrays are processed as well.
+func ParsePostProcessor(spec string) (PostProcessor, error) {
+	parts := strings.Split(strings.TrimSpace(spec), ":")
+	args := parts[1:]
+
+	switch parts[0] {
+	case "round":
+		decimals := 2
+		if len(args) > 1 {
+			return nil, fmt.Errorf("post-processor %q: round takes at most one argument", spec)
+		}
+		if len(args) == 1 {
+			var err error
+			if decimals, err = strconv.Atoi(args[0]); err != nil || decimals < 0 {
+				return nil, fmt.Errorf("post-processor %q: decimals must be a non-negative integer", spec)
+			}
+		}
+		scale := math.Pow(10, float64(decimals))
+		return func(value interface{}, _ time.Time) interface{} {
+			return mapNumbers(value, func(n float64) float64 {
+				return math.Round(n*scale) / scale
+			})
+		}, nil
+
+	case "clamp":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("post-processor %q: clamp needs min and max", spec)
+		}
+		min, minErr := strconv.ParseFloat(args[0], 64)
+		max, maxErr := strconv.ParseFloat(args[1], 64)
+		if minErr != nil || maxErr != nil {
+			return nil, fmt.Errorf("post-processor %q: min and max must be numbers", spec)
+		}
+		if min > max {
+			return nil, fmt.Errorf("post-processor %q: min is greater than max", spec)
+		}
+		return func(value interface{}, _ time.Time) interface{} {
+			return mapNumbers(value, func(n float64) float64 {
+				return math.Max(min, math.Min(max, n))
+			})
+		}, nil
+
+	case "timestamp":
+		field := "timestamp"
+		if len(args) > 1 {
+			return nil, fmt.Errorf("post-processor %q: timestamp takes at most one argument", spec)
+		}
+		if len(args) == 1 {
+			if args[0] == "" {
+				return nil, fmt.Errorf("post-processor %q: field name is empty", spec)
+			}
+			field = args[0]
+		}
+		return func(value interface{}, now time.Time) interface{} {
+			timestamp := now.UTC().Format(time.RFC3339Nano)
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return map[string]interface{}{"value": value, field: timestamp}
+			}
+			stamped := make(map[string]interface{}, len(object)+1)
+			for key, v := range object {
+				stamped[key] = v
+			}
+			stamped[field] = timestamp
+			return stamped
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown post-processor %q", spec)
+}
+
+// ParsePostProcessors builds a pipeline applied in the order given. The
+// single spec "none" yields an empty pipeline.
+func ParsePostProcessors(specs []string) ([]PostProcessor, error) {
+	if len(specs) == 1 && specs[0] == PostProcessorNone {
+		return []PostProcessor{}, nil
+	}
+
+	processors := make([]PostProcessor, 0, len(specs))
+	for _, spec := range specs {
+		processor, err := ParsePostProcessor(spec)
+		if err != nil {
+			return nil, err
+		}
+		processors = append(processors, processor)
+	}
+	return processors, nil
+}
+
+// applyPostProcessors runs every event value through the pipeline
+func applyPostProcessors(processors []PostProcessor, events []EmitEvent, now time.Time) {
+	for i := range events {
+		for _, processor := range processors {
+			events[i].Value = processor(events[i].Value, now)
+		}
+	}
+}
+
+// mapNumbers applies fn to every number in value, copying objects and arrays
+// rather than modifying the strategy's values in place
+func mapNumbers(value interface{}, fn func(float64) float64) interface{} {
+	switch v := value.(type) {
+	case float64:
+		return fn(v)
+	case float32:
+		return fn(float64(v))
+	case int64:
+		if result := fn(float64(v)); result != float64(v) {
+			return result
+		}
+		return v
+	case int:
+		if result := fn(float64(v)); result != float64(v) {
+			return result
+		}
+		return v
+	case map[string]interface{}:
+		mapped := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			mapped[key] = mapNumbers(item, fn)
+		}
+		return mapped
+	case []interface{}:
+		mapped := make([]interface{}, len(v))
+		for i, item := range v {
+			mapped[i] = mapNumbers(item, fn)
+		}
+		return mapped
+	}
+	return value
+}
@@ -0,0 +1,122 @@
+package strategy
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParsePostProcessors(t *testing.T) {
+	valid := [][]string{
+		nil,
+		{"round"},
+		{"round:0", "clamp:-10:10.5", "timestamp:at"},
+		{PostProcessorNone},
+	}
+	for _, specs := range valid {
+		if _, err := ParsePostProcessors(specs); err != nil {
+			t.Errorf("ParsePostProcessors(%v) failed: %v", specs, err)
+		}
+	}
+
+	invalid := [][]string{
+		{"floor"},
+		{"round:-1"},
+		{"round:two"},
+		{"clamp:10"},
+		{"clamp:10:1"},
+		{"timestamp:"},
+		{"round", PostProcessorNone},
+	}
+	for _, specs := range invalid {
+		if _, err := ParsePostProcessors(specs); err == nil {
+			t.Errorf("ParsePostProcessors(%v) accepted invalid specs", specs)
+		}
+	}
+}
+
+func TestPostProcessorBuiltins(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		spec  string
+		value interface{}
+		want  interface{}
+	}{
+		{spec: "round", value: 21.456, want: 21.46},
+		{spec: "round:0", value: 21.5, want: 22.0},
+		{spec: "round:1", value: []interface{}{1.25, "x", int64(3)}, want: []interface{}{1.3, "x", int64(3)}},
+		{spec: "clamp:0:100", value: 140.5, want: 100.0},
+		{spec: "clamp:0:100", value: int64(-5), want: 0.0},
+		{spec: "clamp:0:100", value: map[string]interface{}{"level": 50.5}, want: map[string]interface{}{"level": 50.5}},
+		{spec: "timestamp", value: map[string]interface{}{"on": true}, want: map[string]interface{}{"on": true, "timestamp": "2024-06-01T12:30:00Z"}},
+		{spec: "timestamp:at", value: 1.5, want: map[string]interface{}{"value": 1.5, "at": "2024-06-01T12:30:00Z"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			processor, err := ParsePostProcessor(tt.spec)
+			if err != nil {
+				t.Fatalf("ParsePostProcessor(%q) failed: %v", tt.spec, err)
+			}
+			if got := processor(tt.value, now); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s(%v) = %v, want %v", tt.spec, tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnginePostProcessorsRoundTimestamp(t *testing.T) {
+	engine := NewEngine(nil)
+	err := engine.AddStrategy(&Strategy{
+		ID:       "reading",
+		Name:     "Reading",
+		Language: "javascript",
+		Code: `function process(context) {
+			context.emit("/humidity", 55.555);
+			return {temp: 21.456};
+		}`,
+	})
+	if err != nil {
+		t.Fatalf("AddStrategy() failed: %v", err)
+	}
+	if err := engine.SetPostProcessors([]string{"round:1", "timestamp"}); err != nil {
+		t.Fatalf("SetPostProcessors() failed: %v", err)
+	}
+
+	now := time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC)
+	want := map[string]interface{}{
+		"":          map[string]interface{}{"temp": 21.5, "timestamp": "2024-06-01T12:30:00Z"},
+		"/humidity": map[string]interface{}{"value": 55.6, "timestamp": "2024-06-01T12:30:00Z"},
+	}
+
+	// Repeated executions at the same time produce identical output
+	for i := 0; i < 2; i++ {
+		events, _, _, err := engine.ExecuteStrategyWithOptions("reading", nil, nil, "", nil, nil, ExecuteOptions{Now: now})
+		if err != nil {
+			t.Fatalf("ExecuteStrategyWithOptions() failed: %v", err)
+		}
+		got := make(map[string]interface{})
+		for _, event := range events {
+			got[event.Topic] = event.Value
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("run %d: events = %v, want %v", i, got, want)
+		}
+	}
+
+	// A per-execution pipeline replaces the default one
+	events, _, _, err := engine.ExecuteStrategyWithOptions("reading", nil, nil, "", nil, nil, ExecuteOptions{Now: now, PostProcessors: []string{PostProcessorNone}})
+	if err != nil {
+		t.Fatalf("ExecuteStrategyWithOptions() failed: %v", err)
+	}
+	for _, event := range events {
+		if event.Topic == "" && !reflect.DeepEqual(event.Value, map[string]interface{}{"temp": 21.456}) {
+			t.Errorf("disabled pipeline changed the result: %v", event.Value)
+		}
+	}
+
+	if err := engine.SetPostProcessors([]string{"round:x"}); err == nil {
+		t.Error("SetPostProcessors() accepted an invalid spec")
+	}
+}
@@ -0,0 +1,99 @@
+package strategy
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+
+	"github.com/dop251/goja"
+)
+
+// DefaultProgramCacheSize is how many compiled strategies the JavaScript
+// executor keeps
+const DefaultProgramCacheSize = 1000
+
+// cachedProgram is strategy code compiled once, with the result of
+// validating it. Compilation and validation happen lazily, the first time
+// the code is validated or executed.
+type cachedProgram struct {
+	key        [sha256.Size]byte
+	program    *goja.Program
+	compileErr error
+
+	validated   bool
+	validateErr error
+}
+
+// programCache keeps compiled strategy code keyed by a hash of the code, so
+// unchanged code is neither recompiled nor revalidated. The least recently
+// used programs are evicted once it holds size programs.
+type programCache struct {
+	mutex   sync.Mutex
+	size    int
+	entries map[[sha256.Size]byte]*list.Element
+	order   *list.List // most recently used first
+
+	hits   uint64
+	misses uint64
+}
+
+func newProgramCache(size int) *programCache {
+	return &programCache{
+		size:    size,
+		entries: make(map[[sha256.Size]byte]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// setSize changes how many programs are kept; 0 disables caching
+func (c *programCache) setSize(size int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.size = size
+	c.evict()
+}
+
+// get returns the compiled program for code, compiling it on a miss
+func (c *programCache) get(code string) *cachedProgram {
+	key := sha256.Sum256([]byte(code))
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.entries[key]; ok {
+		c.hits++
+		c.order.MoveToFront(element)
+		return element.Value.(*cachedProgram)
+	}
+	c.misses++
+
+	entry := &cachedProgram{key: key}
+	entry.program, entry.compileErr = goja.Compile("", code, false)
+	if c.size > 0 {
+		c.entries[key] = c.order.PushFront(entry)
+		c.evict()
+	}
+	return entry
+}
+
+// validation returns the stored result of validating entry, if any
+func (c *programCache) validation(entry *cachedProgram) (error, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return entry.validateErr, entry.validated
+}
+
+func (c *programCache) setValidation(entry *cachedProgram, err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry.validated = true
+	entry.validateErr = err
+}
+
+func (c *programCache) evict() {
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedProgram).key)
+	}
+}
@@ -0,0 +1,81 @@
+package strategy
+
+import (
+	"testing"
+)
+
+func TestJavaScriptExecutor_ValidateCache(t *testing.T) {
+	executor := NewJavaScriptExecutor()
+	code := `function process(context) { return 1.5; }`
+
+	if err := executor.Validate(code); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if err := executor.Validate(code); err != nil {
+		t.Fatalf("second Validate failed: %v", err)
+	}
+	if executor.programs.hits != 1 || executor.programs.misses != 1 {
+		t.Errorf("identical code: hits %d, misses %d; want 1 and 1", executor.programs.hits, executor.programs.misses)
+	}
+
+	// Changed code is compiled and validated again, and so are its errors
+	changed := `function process(context) { return 2.5; }`
+	if err := executor.Validate(changed); err != nil {
+		t.Fatalf("Validate of changed code failed: %v", err)
+	}
+	if executor.programs.misses != 2 {
+		t.Errorf("changed code: misses %d, want 2", executor.programs.misses)
+	}
+	invalid := `var process = 1;`
+	for i := 0; i < 2; i++ {
+		if err := executor.Validate(invalid); err == nil {
+			t.Fatal("Validate accepted code without a process function")
+		}
+	}
+	if executor.programs.hits != 2 || executor.programs.misses != 3 {
+		t.Errorf("invalid code: hits %d, misses %d; want 2 and 3", executor.programs.hits, executor.programs.misses)
+	}
+
+	// Execution reuses the compiled program
+	result := executor.Execute(&Strategy{Code: code}, ExecutionContext{})
+	if result.Error != nil {
+		t.Fatalf("Execute failed: %v", result.Error)
+	}
+	if result.Result != 1.5 || executor.programs.hits != 3 {
+		t.Errorf("Execute result %v with %d hits, want 1.5 with 3", result.Result, executor.programs.hits)
+	}
+}
+
+func TestProgramCacheEviction(t *testing.T) {
+	cache := newProgramCache(2)
+	a, b, c := `var a = 1;`, `var b = 1;`, `var c = 1;`
+
+	cache.get(a)
+	cache.get(b)
+	cache.get(a) // a is now the most recently used
+	cache.get(c) // evicts b
+
+	if len(cache.entries) != 2 {
+		t.Fatalf("cache holds %d programs, want 2", len(cache.entries))
+	}
+	misses := cache.misses
+	cache.get(a)
+	if cache.misses != misses {
+		t.Error("recently used program was evicted")
+	}
+	cache.get(b)
+	if cache.misses != misses+1 {
+		t.Error("least recently used program was not evicted")
+	}
+
+	// A size of 0 disables caching
+	cache.setSize(0)
+	if len(cache.entries) != 0 {
+		t.Errorf("disabled cache holds %d programs", len(cache.entries))
+	}
+	cache.get(a)
+	cache.get(a)
+	if cache.misses != misses+3 {
+		t.Error("disabled cache returned a cached program")
+	}
+}
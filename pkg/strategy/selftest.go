@@ -0,0 +1,236 @@
+package strategy
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/dop251/goja"
+)
+
+// SelfTest is a test case embedded in a strategy's code as a top-level
+// `tests` array, e.g.
+//
+//	var tests = [{ name: "hot", inputs: { "sensors/temp": 30.5 }, expected: "on" }];
+type SelfTest struct {
+	Name         string                 `json:"name"`
+	Inputs       map[string]interface{} `json:"inputs"`
+	Parameters   map[string]interface{} `json:"parameters,omitempty"`
+	TriggerTopic string                 `json:"trigger_topic,omitempty"`
+	Expected     interface{}            `json:"expected"`
+}
+
+// SelfTestResult is the outcome of one embedded test
+type SelfTestResult struct {
+	Name     string      `json:"name"`
+	Passed   bool        `json:"passed"`
+	Expected interface{} `json:"expected"`
+	Actual   interface{} `json:"actual"`
+	Diff     []string    `json:"diff,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+// SelfTestReport summarizes a run of a strategy's embedded tests
+type SelfTestReport struct {
+	StrategyID string           `json:"strategy_id"`
+	Total      int              `json:"total"`
+	Passed     int              `json:"passed"`
+	Failed     int              `json:"failed"`
+	Results    []SelfTestResult `json:"results"`
+}
+
+// SelfTestExtractor is implemented by executors that can read the tests
+// embedded in strategy code
+type SelfTestExtractor interface {
+	ExtractSelfTests(code string) ([]SelfTest, error)
+}
+
+// ExtractSelfTests runs the top level of code and returns its `tests` array,
+// or nil when it defines none
+func (jse *JavaScriptExecutor) ExtractSelfTests(code string) ([]SelfTest, error) {
+	entry := jse.programs.get(code)
+	if entry.compileErr != nil {
+		return nil, fmt.Errorf("JavaScript validation error: %w", entry.compileErr)
+	}
+
+	vm := goja.New()
+	// Libraries are resolved at execution time; the tests only need require to exist
+	vm.Set("require", func(moduleID string) interface{} {
+		return map[string]interface{}{}
+	})
+	if _, err := vm.RunProgram(entry.program); err != nil {
+		return nil, fmt.Errorf("JavaScript execution error: %w", err)
+	}
+
+	// Evaluated rather than read from the global object so const and let
+	// declarations are found too
+	value, err := vm.RunString(`typeof tests === "undefined" ? undefined : tests`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tests: %w", err)
+	}
+	if goja.IsUndefined(value) || goja.IsNull(value) {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(value.Export())
+	if err != nil {
+		return nil, fmt.Errorf("tests are not JSON encodable: %w", err)
+	}
+	var tests []SelfTest
+	if err := json.Unmarshal(data, &tests); err != nil {
+		return nil, fmt.Errorf("tests must be an array of {name, inputs, parameters, trigger_topic, expected}: %w", err)
+	}
+	for i := range tests {
+		if tests[i].Name == "" {
+			tests[i].Name = fmt.Sprintf("test %d", i+1)
+		}
+	}
+	return tests, nil
+}
+
+// SelfTest runs the tests embedded in a strategy's code, comparing each
+// test's main output with its expected value
+func (e *Engine) SelfTest(strategyID string) (*SelfTestReport, error) {
+	strategy, err := e.GetStrategy(strategyID)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mutex.RLock()
+	extractor, ok := e.executors[strategy.Language].(SelfTestExtractor)
+	if !ok && strategy.Language == LanguageSubprocess {
+		// Isolated strategies are JavaScript run in a worker process
+		extractor, ok = e.executors["javascript"].(SelfTestExtractor)
+	}
+	e.mutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("self-tests are not supported for language %s", strategy.Language)
+	}
+
+	tests, err := extractor.ExtractSelfTests(strategy.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &SelfTestReport{
+		StrategyID: strategyID,
+		Total:      len(tests),
+		Results:    make([]SelfTestResult, 0, len(tests)),
+	}
+	for _, test := range tests {
+		result := e.runSelfTest(strategyID, test)
+		if result.Passed {
+			report.Passed++
+		} else {
+			report.Failed++
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report, nil
+}
+
+func (e *Engine) runSelfTest(strategyID string, test SelfTest) SelfTestResult {
+	result := SelfTestResult{Name: test.Name, Expected: test.Expected}
+
+	triggerTopic := test.TriggerTopic
+	if triggerTopic == "" {
+		triggerTopic = "test"
+	}
+	events, _, _, err := e.ExecuteStrategyWithOptions(strategyID, test.Inputs, nil, triggerTopic, nil, test.Parameters, ExecuteOptions{})
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	for _, event := range events {
+		if event.Topic == "" {
+			result.Actual = event.Value
+			break
+		}
+	}
+
+	actual, err := NormalizeJSON(result.Actual)
+	if err != nil {
+		result.Error = fmt.Sprintf("output is not JSON encodable: %v", err)
+		return result
+	}
+	result.Actual = actual
+	result.Diff = DiffValues("$", test.Expected, actual)
+	result.Passed = len(result.Diff) == 0
+	return result
+}
+
+// NormalizeJSON round-trips value through JSON, so strategy outputs compare
+// equal to values decoded from JSON (e.g. int64 and float64 numbers)
+func NormalizeJSON(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var normalized interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+// DiffValues lists the paths at which actual differs from expected; both
+// are JSON-decoded values
+func DiffValues(path string, expected, actual interface{}) []string {
+	switch exp := expected.(type) {
+	case map[string]interface{}:
+		act, ok := actual.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(exp)+len(act))
+		for key := range exp {
+			keys = append(keys, key)
+		}
+		for key := range act {
+			if _, exists := exp[key]; !exists {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+
+		var diffs []string
+		for _, key := range keys {
+			keyPath := path + "." + key
+			expValue, inExpected := exp[key]
+			actValue, inActual := act[key]
+			switch {
+			case !inActual:
+				diffs = append(diffs, fmt.Sprintf("%s: missing, expected %s", keyPath, formatDiffValue(expValue)))
+			case !inExpected:
+				diffs = append(diffs, fmt.Sprintf("%s: unexpected %s", keyPath, formatDiffValue(actValue)))
+			default:
+				diffs = append(diffs, DiffValues(keyPath, expValue, actValue)...)
+			}
+		}
+		return diffs
+	case []interface{}:
+		act, ok := actual.([]interface{})
+		if !ok || len(act) != len(exp) {
+			break
+		}
+		var diffs []string
+		for i := range exp {
+			diffs = append(diffs, DiffValues(fmt.Sprintf("%s[%d]", path, i), exp[i], act[i])...)
+		}
+		return diffs
+	}
+
+	if reflect.DeepEqual(expected, actual) {
+		return nil
+	}
+	return []string{fmt.Sprintf("%s: expected %s, got %s", path, formatDiffValue(expected), formatDiffValue(actual))}
+}
+
+func formatDiffValue(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
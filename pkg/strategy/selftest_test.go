@@ -0,0 +1,72 @@
+package strategy
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEngineSelfTest(t *testing.T) {
+	engine := NewEngine(nil)
+	code := `const tests = [
+		{ name: "cold", inputs: { "sensors/temp": 15.5 }, expected: { heat: true, setpoint: 18.5 } },
+		{ name: "warm", inputs: { "sensors/temp": 24.5 }, expected: { heat: true, setpoint: 18.5 } },
+		{ inputs: { "sensors/temp": 20.5 }, parameters: { setpoint: 21.5 }, expected: { heat: true, setpoint: 21.5 } }
+	];
+
+	function process(context) {
+		var setpoint = (context.parameters && context.parameters.setpoint) || 18.5;
+		return { heat: context.inputs["sensors/temp"] < setpoint, setpoint: setpoint };
+	}`
+	if err := engine.AddStrategy(&Strategy{ID: "heating", Name: "Heating", Code: code, Language: "javascript"}); err != nil {
+		t.Fatalf("Failed to add strategy: %v", err)
+	}
+
+	report, err := engine.SelfTest("heating")
+	if err != nil {
+		t.Fatalf("SelfTest failed: %v", err)
+	}
+	if report.Total != 3 || report.Passed != 2 || report.Failed != 1 {
+		t.Fatalf("report = %d total, %d passed, %d failed; want 3, 2, 1", report.Total, report.Passed, report.Failed)
+	}
+
+	tests := []struct {
+		name   string
+		passed bool
+		diff   []string
+	}{
+		{name: "cold", passed: true},
+		{name: "warm", passed: false, diff: []string{"$.heat: expected true, got false"}},
+		{name: "test 3", passed: true},
+	}
+	for i, tt := range tests {
+		result := report.Results[i]
+		if result.Name != tt.name || result.Passed != tt.passed || !reflect.DeepEqual(result.Diff, tt.diff) {
+			t.Errorf("result %d = %+v, want name %q passed %v diff %v", i, result, tt.name, tt.passed, tt.diff)
+		}
+	}
+}
+
+func TestEngineSelfTestWithoutTests(t *testing.T) {
+	engine := NewEngine(nil)
+	if err := engine.AddStrategy(&Strategy{ID: "plain", Name: "Plain", Code: "function process(context) { return 1.5; }", Language: "javascript"}); err != nil {
+		t.Fatalf("Failed to add strategy: %v", err)
+	}
+	if err := engine.AddStrategy(&Strategy{ID: "broken", Name: "Broken", Code: "var tests = 'not an array'; function process(context) {}", Language: "javascript"}); err != nil {
+		t.Fatalf("Failed to add strategy: %v", err)
+	}
+
+	report, err := engine.SelfTest("plain")
+	if err != nil {
+		t.Fatalf("SelfTest failed: %v", err)
+	}
+	if report.Total != 0 || len(report.Results) != 0 {
+		t.Errorf("report = %+v, want no tests", report)
+	}
+
+	if _, err := engine.SelfTest("broken"); err == nil {
+		t.Error("expected an error for a tests value that is not an array")
+	}
+	if _, err := engine.SelfTest("missing"); err == nil {
+		t.Error("expected an error for an unknown strategy")
+	}
+}
@@ -0,0 +1,69 @@
+package strategy
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// ScriptError is a strategy failure carrying the stack it was raised at, so
+// authors can find the failing call site
+type ScriptError struct {
+	Err error
+	// Stack lists one "at ..." frame per line, innermost first
+	Stack string
+}
+
+func (e *ScriptError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ScriptError) Unwrap() error {
+	return e.Err
+}
+
+// withStack attaches the JavaScript stack of a thrown exception to err.
+// Errors that are not JavaScript exceptions are returned unchanged.
+func withStack(err error) error {
+	var exception *goja.Exception
+	if !errors.As(err, &exception) {
+		return err
+	}
+
+	var stack bytes.Buffer
+	for _, frame := range exception.Stack() {
+		if stack.Len() > 0 {
+			stack.WriteByte('\n')
+		}
+		stack.WriteString("at ")
+		frame.Write(&stack)
+	}
+	if stack.Len() == 0 {
+		return err
+	}
+	return &ScriptError{Err: err, Stack: stack.String()}
+}
+
+// StackTrace returns the stack captured with err, or "" if there is none
+func StackTrace(err error) string {
+	var scriptErr *ScriptError
+	if errors.As(err, &scriptErr) {
+		return scriptErr.Stack
+	}
+	return ""
+}
+
+// ErrorDetails returns err's message followed by its stack trace, if one
+// was captured
+func ErrorDetails(err error) string {
+	if err == nil {
+		return ""
+	}
+	stack := StackTrace(err)
+	if stack == "" {
+		return err.Error()
+	}
+	return err.Error() + "\n" + strings.TrimRight(stack, "\n")
+}
@@ -0,0 +1,86 @@
+package strategy
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestJavaScriptExecutor_CapturesStack(t *testing.T) {
+	executor := NewJavaScriptExecutor()
+
+	strategy := &Strategy{
+		Code: `function inner(value) {
+	throw new Error("bad value " + value);
+}
+
+function outer(value) {
+	return inner(value);
+}
+
+function process(context) {
+	return outer(context.inputs.value);
+}`,
+	}
+
+	result := executor.Execute(strategy, ExecutionContext{InputValues: map[string]interface{}{"value": 42}})
+	if result.Error == nil {
+		t.Fatal("Execute() succeeded, want the thrown error")
+	}
+
+	var scriptErr *ScriptError
+	if !errors.As(result.Error, &scriptErr) {
+		t.Fatalf("error %v does not carry a stack", result.Error)
+	}
+	if !strings.Contains(result.Error.Error(), "bad value 42") {
+		t.Errorf("error = %q, want the thrown message", result.Error)
+	}
+
+	stack := StackTrace(result.Error)
+	lines := strings.Split(stack, "\n")
+	if len(lines) < 3 {
+		t.Fatalf("stack = %q, want inner, outer and process frames", stack)
+	}
+	for i, want := range []string{"at inner (", "at outer (", "at process ("} {
+		if !strings.HasPrefix(lines[i], want) {
+			t.Errorf("stack frame %d = %q, want prefix %q", i, lines[i], want)
+		}
+	}
+	// The innermost frame points at the throw
+	if !strings.Contains(lines[0], ":2:") {
+		t.Errorf("inner frame = %q, want line 2", lines[0])
+	}
+
+	details := ErrorDetails(result.Error)
+	if !strings.HasPrefix(details, result.Error.Error()+"\n") || !strings.HasSuffix(details, stack) {
+		t.Errorf("ErrorDetails() = %q", details)
+	}
+}
+
+func TestJavaScriptExecutor_CapturesTopLevelStack(t *testing.T) {
+	executor := NewJavaScriptExecutor()
+
+	result := executor.Execute(&Strategy{Code: `
+function explode() { null.field; }
+explode();
+function process(context) { return 1; }`}, ExecutionContext{})
+	if result.Error == nil {
+		t.Fatal("Execute() succeeded, want the top-level error")
+	}
+	if stack := StackTrace(result.Error); !strings.HasPrefix(stack, "at explode (") {
+		t.Errorf("stack = %q, want the explode frame first", stack)
+	}
+}
+
+func TestErrorDetailsWithoutStack(t *testing.T) {
+	err := errors.New("strategy missing")
+	if StackTrace(err) != "" {
+		t.Error("plain error reported a stack")
+	}
+	if got := ErrorDetails(err); got != "strategy missing" {
+		t.Errorf("ErrorDetails() = %q", got)
+	}
+	if got := ErrorDetails(nil); got != "" {
+		t.Errorf("ErrorDetails(nil) = %q", got)
+	}
+}
@@ -0,0 +1,122 @@
+package strategy
+
+import (
+	"fmt"
+	"sync"
+)
+
+// StateStore persists the key/value state strategies keep with
+// context.setState, per strategy and topic
+type StateStore interface {
+	LoadStrategyState(strategyID, topicName string) (map[string]interface{}, error)
+	// SaveStrategyState replaces the saved state; an empty state removes it
+	SaveStrategyState(strategyID, topicName string, state map[string]interface{}) error
+}
+
+// memoryStateStore keeps strategy state in memory until the process exits.
+// It is used until a persistent store is set.
+type memoryStateStore struct {
+	states map[string]map[string]interface{}
+	mutex  sync.Mutex
+}
+
+func newMemoryStateStore() *memoryStateStore {
+	return &memoryStateStore{states: make(map[string]map[string]interface{})}
+}
+
+func (s *memoryStateStore) LoadStrategyState(strategyID, topicName string) (map[string]interface{}, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return copyState(s.states[strategyID+"\x00"+topicName]), nil
+}
+
+func (s *memoryStateStore) SaveStrategyState(strategyID, topicName string, state map[string]interface{}) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := strategyID + "\x00" + topicName
+	if len(state) == 0 {
+		delete(s.states, key)
+		return nil
+	}
+	s.states[key] = copyState(state)
+	return nil
+}
+
+// executionState is the state seen by one execution. It is loaded when the
+// strategy first uses it and saved once the execution succeeds.
+type executionState struct {
+	store      StateStore
+	strategyID string
+	topicName  string
+
+	values map[string]interface{}
+	loaded bool
+	dirty  bool
+}
+
+func (s *executionState) load() error {
+	if s.loaded {
+		return nil
+	}
+	// Executions outside a topic, such as test runs, start empty and keep
+	// nothing
+	if s.store != nil && s.topicName != "" {
+		values, err := s.store.LoadStrategyState(s.strategyID, s.topicName)
+		if err != nil {
+			return fmt.Errorf("failed to load state of strategy %s for topic %s: %w", s.strategyID, s.topicName, err)
+		}
+		s.values = values
+	}
+	if s.values == nil {
+		s.values = make(map[string]interface{})
+	}
+	s.loaded = true
+	return nil
+}
+
+func (s *executionState) get(key string) (interface{}, bool, error) {
+	if err := s.load(); err != nil {
+		return nil, false, err
+	}
+	value, ok := s.values[key]
+	return value, ok, nil
+}
+
+func (s *executionState) set(key string, value interface{}) error {
+	if err := s.load(); err != nil {
+		return err
+	}
+	s.values[key] = value
+	s.dirty = true
+	return nil
+}
+
+func (s *executionState) clear() {
+	s.values = make(map[string]interface{})
+	s.loaded = true
+	s.dirty = true
+}
+
+// save writes changed state back to the store
+func (s *executionState) save() error {
+	if !s.dirty || s.store == nil || s.topicName == "" {
+		return nil
+	}
+	if err := s.store.SaveStrategyState(s.strategyID, s.topicName, s.values); err != nil {
+		return fmt.Errorf("failed to save state of strategy %s for topic %s: %w", s.strategyID, s.topicName, err)
+	}
+	return nil
+}
+
+func copyState(state map[string]interface{}) map[string]interface{} {
+	if state == nil {
+		return nil
+	}
+	copied := make(map[string]interface{}, len(state))
+	for key, value := range state {
+		copied[key] = value
+	}
+	return copied
+}
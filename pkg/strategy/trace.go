@@ -0,0 +1,41 @@
+package strategy
+
+import "time"
+
+// ExecutionTrace profiles a strategy execution: the wall-clock time of each
+// phase and how often the strategy called emit and the log functions
+type ExecutionTrace struct {
+	// SetupMicros is spent preparing the VM, LoadMicros running the strategy's
+	// top-level code and ProcessMicros inside process()
+	SetupMicros   int64 `json:"setup_us"`
+	LoadMicros    int64 `json:"load_us"`
+	ProcessMicros int64 `json:"process_us"`
+
+	EmitCalls int `json:"emit_calls"`
+	// LogCalls counts log calls by level, including messages below the
+	// strategy's log level that were not recorded
+	LogCalls map[LogLevel]int `json:"log_calls"`
+}
+
+func newExecutionTrace() *ExecutionTrace {
+	return &ExecutionTrace{LogCalls: make(map[LogLevel]int)}
+}
+
+// lap stores the microseconds elapsed since mark in phase and restarts mark
+func (t *ExecutionTrace) lap(mark *time.Time, phase *int64) {
+	now := time.Now()
+	*phase = now.Sub(*mark).Microseconds()
+	*mark = now
+}
+
+func (t *ExecutionTrace) countEmit() {
+	if t != nil {
+		t.EmitCalls++
+	}
+}
+
+func (t *ExecutionTrace) countLog(level LogLevel) {
+	if t != nil {
+		t.LogCalls[level]++
+	}
+}
@@ -1,7 +1,11 @@
 package strategy
 
 import (
+	"context"
+	"fmt"
 	"time"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/mqtt"
 )
 
 type Strategy struct {
@@ -14,8 +18,85 @@ type Strategy struct {
 	Parameters        map[string]interface{} `json:"parameters" db:"parameters"`
 	MaxInputs         int                    `json:"max_inputs" db:"max_inputs"`
 	DefaultInputNames []string               `json:"default_input_names" db:"default_input_names"`
-	CreatedAt         time.Time              `json:"created_at" db:"created_at"`
-	UpdatedAt         time.Time              `json:"updated_at" db:"updated_at"`
+	// AllowedInputPatterns restricts which topics may be wired as inputs (MQTT wildcards allowed)
+	AllowedInputPatterns []string `json:"allowed_input_patterns,omitempty" db:"allowed_input_patterns"`
+	// Library strategies hold shared helpers loaded with require() and cannot be executed directly
+	Library bool `json:"library" db:"library"`
+	// LogLevel is the lowest severity of strategy log messages that are kept (default info)
+	LogLevel LogLevel `json:"log_level,omitempty" db:"log_level"`
+	// FilePath is set for strategies whose code is loaded from a .js file on disk
+	FilePath string `json:"file_path,omitempty" db:"file_path"`
+	// TimeoutMs limits how long an execution may run; 0 uses the executor's default
+	TimeoutMs int       `json:"timeout_ms,omitempty" db:"timeout_ms"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// LogLevel is the severity of a message logged by a strategy
+type LogLevel string
+
+const (
+	LogLevelDebug LogLevel = "debug"
+	LogLevelInfo  LogLevel = "info"
+	LogLevelWarn  LogLevel = "warn"
+	LogLevelError LogLevel = "error"
+)
+
+var logLevelSeverity = map[LogLevel]int{
+	LogLevelDebug: 0,
+	LogLevelInfo:  1,
+	LogLevelWarn:  2,
+	LogLevelError: 3,
+}
+
+// ParseLogLevel validates a log level name, treating empty as LogLevelInfo
+func ParseLogLevel(level string) (LogLevel, error) {
+	if level == "" {
+		return LogLevelInfo, nil
+	}
+	if _, ok := logLevelSeverity[LogLevel(level)]; !ok {
+		return "", fmt.Errorf("invalid log level %q: expected debug, info, warn or error", level)
+	}
+	return LogLevel(level), nil
+}
+
+// Allows reports whether a message at level passes a threshold of l
+func (l LogLevel) Allows(level LogLevel) bool {
+	threshold, err := ParseLogLevel(string(l))
+	if err != nil {
+		threshold = LogLevelInfo
+	}
+	return logLevelSeverity[level] >= logLevelSeverity[threshold]
+}
+
+// LogMessage is a message logged by a strategy during execution
+type LogMessage struct {
+	Level   LogLevel `json:"level"`
+	Message string   `json:"message"`
+}
+
+// ValidateInputs checks that every input topic is allowed by the strategy's
+// AllowedInputPatterns. A wildcard input is allowed only when a pattern
+// covers every topic it matches. Strategies without patterns accept any input.
+func (s *Strategy) ValidateInputs(inputs []string) error {
+	if len(s.AllowedInputPatterns) == 0 {
+		return nil
+	}
+
+	for _, input := range inputs {
+		allowed := false
+		for _, pattern := range s.AllowedInputPatterns {
+			if mqtt.SubscriptionCovers(pattern, input) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("input %s is not allowed by strategy %s (allowed patterns: %v)", input, s.ID, s.AllowedInputPatterns)
+		}
+	}
+
+	return nil
 }
 
 type ExecutionContext struct {
@@ -26,14 +107,86 @@ type ExecutionContext struct {
 	LastOutputs     interface{}            `json:"last_outputs"`
 	Parameters      map[string]interface{} `json:"parameters"`
 	TopicName       string                 `json:"topic_name"`
+
+	// Trace asks the executor to profile the execution into ExecutionResult.Trace
+	Trace bool `json:"-"`
+
+	// Now fixes the time returned by getTime/getISO so test runs are
+	// deterministic. Zero uses the real time.
+	Now time.Time `json:"-"`
+
+	// Deadline stops the execution when reached, if sooner than the
+	// executor's own timeout. Zero has no deadline.
+	Deadline time.Time `json:"-"`
+
+	// Topics gives read access to the current values of all topics. Nil
+	// makes every topic read as missing.
+	Topics TopicReader `json:"-"`
+}
+
+// TopicReader reads the current values of topics for strategies
+type TopicReader interface {
+	// TopicValue returns a topic's last value, and false if the topic does
+	// not exist
+	TopicValue(name string) (interface{}, bool)
+}
+
+// TopicValue returns a topic's last value from the context's topic reader
+func (c ExecutionContext) TopicValue(name string) (interface{}, bool) {
+	if c.Topics == nil {
+		return nil, false
+	}
+	return c.Topics.TopicValue(name)
+}
+
+// Input returns an input value by its name, or by topic for inputs keyed by
+// topic. A named input can also be looked up by its topic.
+func (c ExecutionContext) Input(key string) (interface{}, bool) {
+	if value, ok := c.InputValues[key]; ok {
+		return value, true
+	}
+	if name, ok := c.InputNames[key]; ok {
+		value, ok := c.InputValues[name]
+		return value, ok
+	}
+	return nil, false
+}
+
+// CurrentTime returns the injected time, or the real time if none is set
+func (c ExecutionContext) CurrentTime() time.Time {
+	if c.Now.IsZero() {
+		return time.Now()
+	}
+	return c.Now
+}
+
+// ExecuteOptions adjust a single strategy execution
+type ExecuteOptions struct {
+	// Trace profiles the execution
+	Trace bool
+	// Now fixes the time seen by the strategy; zero uses the real time
+	Now time.Time
+	// PostProcessors replaces the engine's default post-processor pipeline
+	// when non-nil
+	PostProcessors []string
+	// TopicName is the topic the execution is for. It is seen by the strategy
+	// as context.topicName and scopes the state the strategy keeps.
+	TopicName string
+	// Context carries the deadline of the propagation chain the execution is
+	// part of. Executions are not started once it is done, and its deadline
+	// cuts short the executor's timeout.
+	Context context.Context
 }
 
 type ExecutionResult struct {
 	Result        interface{}   `json:"result"`
 	Error         error         `json:"error,omitempty"`
-	LogMessages   []string      `json:"log_messages,omitempty"`
+	LogMessages   []LogMessage  `json:"log_messages,omitempty"`
 	EmittedEvents []EmitEvent   `json:"emitted_events,omitempty"`
 	ExecutionTime time.Duration `json:"execution_time"`
+
+	// Trace is set when the context asked for tracing
+	Trace *ExecutionTrace `json:"trace,omitempty"`
 }
 
 type EmitEvent struct {
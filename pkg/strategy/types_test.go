@@ -0,0 +1,59 @@
+package strategy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStrategyValidateInputs(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		inputs   []string
+		wantErr  string
+	}{
+		{
+			name:   "no patterns allows any input",
+			inputs: []string{"anything/at/all"},
+		},
+		{
+			name:     "exact and wildcard matches",
+			patterns: []string{"sensors/+/temperature", "zigbee/#"},
+			inputs:   []string{"sensors/kitchen/temperature", "zigbee/lamp/state"},
+		},
+		{
+			name:     "disallowed input",
+			patterns: []string{"sensors/+/temperature"},
+			inputs:   []string{"sensors/kitchen/temperature", "sensors/kitchen/humidity"},
+			wantErr:  "input sensors/kitchen/humidity is not allowed by strategy thermostat",
+		},
+		{
+			name:     "wildcard inputs within the patterns",
+			patterns: []string{"sensors/+/temperature", "zigbee/#"},
+			inputs:   []string{"sensors/+/temperature", "zigbee/+/state", "zigbee/#"},
+		},
+		{
+			name:     "wildcard input broader than the pattern",
+			patterns: []string{"sensors/+"},
+			inputs:   []string{"sensors/#"},
+			wantErr:  "input sensors/# is not allowed by strategy thermostat",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategy := &Strategy{ID: "thermostat", AllowedInputPatterns: tt.patterns}
+
+			err := strategy.ValidateInputs(tt.inputs)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateInputs() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateInputs() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package strategy
+
+import "strings"
+
+// unit converts a value of one unit to and from its dimension's base unit
+type unit struct {
+	dimension string
+	toBase    func(float64) float64
+	fromBase  func(float64) float64
+}
+
+// linearUnit is a unit that is a fixed multiple of the base unit
+func linearUnit(dimension string, factor float64) unit {
+	return unit{
+		dimension: dimension,
+		toBase:    func(v float64) float64 { return v * factor },
+		fromBase:  func(v float64) float64 { return v / factor },
+	}
+}
+
+// units maps unit names and aliases to their conversions. Base units are
+// celsius, metres and pascals.
+var units = func() map[string]unit {
+	celsius := unit{
+		dimension: "temperature",
+		toBase:    func(v float64) float64 { return v },
+		fromBase:  func(v float64) float64 { return v },
+	}
+	fahrenheit := unit{
+		dimension: "temperature",
+		toBase:    func(v float64) float64 { return (v - 32) * 5 / 9 },
+		fromBase:  func(v float64) float64 { return v*9/5 + 32 },
+	}
+	kelvin := unit{
+		dimension: "temperature",
+		toBase:    func(v float64) float64 { return v - 273.15 },
+		fromBase:  func(v float64) float64 { return v + 273.15 },
+	}
+
+	byName := map[string]unit{}
+	add := func(u unit, names ...string) {
+		for _, name := range names {
+			byName[name] = u
+		}
+	}
+
+	add(celsius, "c", "celsius", "°c")
+	add(fahrenheit, "f", "fahrenheit", "°f")
+	add(kelvin, "k", "kelvin")
+
+	add(linearUnit("distance", 1), "m", "metre", "meter", "metres", "meters")
+	add(linearUnit("distance", 1000), "km", "kilometre", "kilometer", "kilometres", "kilometers")
+	add(linearUnit("distance", 0.01), "cm", "centimetre", "centimeter", "centimetres", "centimeters")
+	add(linearUnit("distance", 0.001), "mm", "millimetre", "millimeter", "millimetres", "millimeters")
+	add(linearUnit("distance", 1609.344), "mi", "mile", "miles")
+	add(linearUnit("distance", 0.9144), "yd", "yard", "yards")
+	add(linearUnit("distance", 0.3048), "ft", "foot", "feet")
+	add(linearUnit("distance", 0.0254), "in", "inch", "inches")
+
+	add(linearUnit("pressure", 1), "pa", "pascal", "pascals")
+	add(linearUnit("pressure", 100), "hpa", "hectopascal", "hectopascals")
+	add(linearUnit("pressure", 1000), "kpa", "kilopascal", "kilopascals")
+	add(linearUnit("pressure", 100000), "bar")
+	add(linearUnit("pressure", 100), "mbar", "millibar", "millibars")
+	add(linearUnit("pressure", 6894.757293168), "psi")
+	add(linearUnit("pressure", 3386.389), "inhg")
+	add(linearUnit("pressure", 133.322387415), "mmhg")
+	add(linearUnit("pressure", 101325), "atm")
+
+	return byName
+}()
+
+// ConvertUnits converts value between two units of the same dimension
+// (temperature, distance or pressure). Unit names are case-insensitive. It
+// reports false for unknown units or units of different dimensions.
+func ConvertUnits(value float64, fromUnit, toUnit string) (float64, bool) {
+	from, ok := units[strings.ToLower(strings.TrimSpace(fromUnit))]
+	if !ok {
+		return 0, false
+	}
+	to, ok := units[strings.ToLower(strings.TrimSpace(toUnit))]
+	if !ok || to.dimension != from.dimension {
+		return 0, false
+	}
+	return to.fromBase(from.toBase(value)), true
+}
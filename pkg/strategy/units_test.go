@@ -0,0 +1,86 @@
+package strategy
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConvertUnits(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  float64
+		from   string
+		to     string
+		want   float64
+		wantOK bool
+	}{
+		{name: "celsius to fahrenheit", value: 100, from: "C", to: "F", want: 212, wantOK: true},
+		{name: "fahrenheit to celsius", value: 32, from: "fahrenheit", to: "celsius", want: 0, wantOK: true},
+		{name: "celsius to kelvin", value: 25, from: "c", to: "k", want: 298.15, wantOK: true},
+		{name: "kelvin to fahrenheit", value: 0, from: "K", to: "F", want: -459.67, wantOK: true},
+		{name: "miles to kilometres", value: 1, from: "mi", to: "km", want: 1.609344, wantOK: true},
+		{name: "feet to inches", value: 3, from: "ft", to: "in", want: 36, wantOK: true},
+		{name: "hpa to inhg", value: 1013.25, from: "hPa", to: "inHg", want: 29.9213, wantOK: true},
+		{name: "atm to psi", value: 1, from: "atm", to: "psi", want: 14.6959, wantOK: true},
+		{name: "same unit", value: 42, from: "bar", to: "bar", want: 42, wantOK: true},
+		{name: "unknown unit", value: 1, from: "furlong", to: "m"},
+		{name: "different dimensions", value: 1, from: "c", to: "m"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ConvertUnits(tt.value, tt.from, tt.to)
+			if ok != tt.wantOK {
+				t.Fatalf("ConvertUnits() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if ok && math.Abs(got-tt.want) > 0.0001 {
+				t.Errorf("ConvertUnits() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJavaScriptExecutor_Execute_Convert(t *testing.T) {
+	executor := NewJavaScriptExecutor()
+
+	strategy := &Strategy{
+		Code: `function process(context) {
+			return {
+				fahrenheit: context.convert(context.inputs["temp"], "c", "f"),
+				unknown: context.convert(1, "c", "parsec"),
+				invalid: context.convert("hot", "c", "f")
+			};
+		}`,
+	}
+
+	result := executor.Execute(strategy, ExecutionContext{
+		InputValues: map[string]interface{}{"temp": 20},
+	})
+	if result.Error != nil {
+		t.Fatalf("Execute() failed: %v", result.Error)
+	}
+
+	resultMap, ok := result.Result.(map[string]interface{})
+	if !ok {
+		t.Fatalf("result is not a map: %T", result.Result)
+	}
+
+	var fahrenheit float64
+	switch v := resultMap["fahrenheit"].(type) {
+	case int64:
+		fahrenheit = float64(v)
+	case float64:
+		fahrenheit = v
+	default:
+		t.Fatalf("convert() returned %v (%T), want number", v, v)
+	}
+	if fahrenheit != 68 {
+		t.Errorf("convert() = %v, want 68", fahrenheit)
+	}
+	if resultMap["unknown"] != nil {
+		t.Errorf("convert() unknown pair = %v, want null", resultMap["unknown"])
+	}
+	if resultMap["invalid"] != nil {
+		t.Errorf("convert() non-numeric value = %v, want null", resultMap["invalid"])
+	}
+}
@@ -0,0 +1,99 @@
+package topics
+
+import (
+	"fmt"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/metrics"
+)
+
+// BackpressurePolicy is what happens to inbound MQTT messages while the
+// maximum number of strategy executions are in flight
+type BackpressurePolicy string
+
+const (
+	// BackpressureDefer waits for an execution to finish before processing the
+	// message, which in turn slows reading from the broker (default)
+	BackpressureDefer BackpressurePolicy = "defer"
+	// BackpressureDrop discards the message
+	BackpressureDrop BackpressurePolicy = "drop"
+)
+
+// ParseBackpressurePolicy validates a backpressure policy name, treating empty
+// as BackpressureDefer
+func ParseBackpressurePolicy(policy string) (BackpressurePolicy, error) {
+	switch BackpressurePolicy(policy) {
+	case "", BackpressureDefer:
+		return BackpressureDefer, nil
+	case BackpressureDrop:
+		return BackpressureDrop, nil
+	default:
+		return "", fmt.Errorf("invalid backpressure policy %q: expected defer or drop", policy)
+	}
+}
+
+// executionLimiter is a semaphore bounding concurrent strategy executions
+type executionLimiter struct {
+	slots  chan struct{}
+	policy BackpressurePolicy
+}
+
+// SetMaxInFlight limits the number of strategy executions running at once.
+// While the limit is reached, inbound MQTT messages are deferred or dropped
+// according to policy. A limit of 0 removes the limit.
+func (m *Manager) SetMaxInFlight(limit int, policy BackpressurePolicy) error {
+	if limit < 0 {
+		return fmt.Errorf("invalid max in-flight executions: %d", limit)
+	}
+	parsed, err := ParseBackpressurePolicy(string(policy))
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.limiter = nil
+	if limit > 0 {
+		m.limiter = &executionLimiter{slots: make(chan struct{}, limit), policy: parsed}
+	}
+	return nil
+}
+
+func (m *Manager) executionLimiter() *executionLimiter {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.limiter
+}
+
+// acquireExecution waits for an execution slot, returning the function
+// releasing it
+func (m *Manager) acquireExecution() func() {
+	limiter := m.executionLimiter()
+	if limiter == nil {
+		return func() {}
+	}
+	limiter.slots <- struct{}{}
+	return func() { <-limiter.slots }
+}
+
+// admitMQTTMessage applies backpressure to an inbound message, reporting
+// whether it should be processed. Deferred messages wait here until an
+// execution slot frees up.
+func (m *Manager) admitMQTTMessage(topic string) bool {
+	limiter := m.executionLimiter()
+	if limiter == nil || len(limiter.slots) < cap(limiter.slots) {
+		return true
+	}
+
+	metrics.RecordMQTTBackpressure(string(limiter.policy))
+	if limiter.policy == BackpressureDrop {
+		m.logger.Printf("Dropping MQTT message for %s: %d strategy executions in flight", topic, cap(limiter.slots))
+		return false
+	}
+
+	// Taking and returning a slot waits for one to be free
+	limiter.slots <- struct{}{}
+	<-limiter.slots
+	return true
+}
@@ -0,0 +1,130 @@
+package topics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/metrics"
+	"github.com/denwilliams/go-mqtt-automation/pkg/mqtt"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func backpressureCount(t *testing.T, action BackpressurePolicy) float64 {
+	t.Helper()
+
+	var metric dto.Metric
+	if err := metrics.MQTTBackpressure.WithLabelValues(string(action)).Write(&metric); err != nil {
+		t.Fatalf("failed to read metric: %v", err)
+	}
+	return metric.GetCounter().GetValue()
+}
+
+// newSaturatedManager returns a manager allowing one execution in flight and
+// starts an execution that runs until the returned release function is called
+func newSaturatedManager(t *testing.T, policy BackpressurePolicy) (*Manager, func()) {
+	t.Helper()
+
+	manager := NewManager(nil)
+	started := make(chan struct{})
+	release := make(chan struct{})
+	manager.SetStrategyExecutor(&mockStrategyExecutor{
+		executeFunc: func(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+			if strategyID == "slow" {
+				close(started)
+				<-release
+			}
+			return "ok", nil
+		},
+	})
+	if err := manager.SetMaxInFlight(1, policy); err != nil {
+		t.Fatalf("SetMaxInFlight failed: %v", err)
+	}
+
+	mustAddExternalTopic(t, manager, "sensors/slow")
+	if _, err := manager.AddInternalTopic("house/slow", []string{"sensors/slow"}, nil, "slow", nil, false, false); err != nil {
+		t.Fatalf("AddInternalTopic failed: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		manager.HandleMQTTMessage(mqtt.Event{Topic: "sensors/slow", Payload: []byte("1")})
+	}()
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatal("slow execution did not start")
+	}
+
+	return manager, func() {
+		close(release)
+		<-done
+	}
+}
+
+func TestMaxInFlightDrop(t *testing.T) {
+	manager, release := newSaturatedManager(t, BackpressureDrop)
+	before := backpressureCount(t, BackpressureDrop)
+
+	if err := manager.HandleMQTTMessage(mqtt.Event{Topic: "sensors/other", Payload: []byte("2")}); err != nil {
+		t.Fatalf("HandleMQTTMessage failed: %v", err)
+	}
+	if topic := manager.GetExternalTopic("sensors/other"); topic != nil {
+		t.Errorf("message processed while saturated: %v", topic.LastValue())
+	}
+	if got := backpressureCount(t, BackpressureDrop) - before; got != 1 {
+		t.Errorf("drop count increased by %v, want 1", got)
+	}
+
+	// Messages are processed again once the execution finishes
+	release()
+	if err := manager.HandleMQTTMessage(mqtt.Event{Topic: "sensors/other", Payload: []byte("3")}); err != nil {
+		t.Fatalf("HandleMQTTMessage failed: %v", err)
+	}
+	if topic := manager.GetExternalTopic("sensors/other"); topic == nil || topic.LastValue() != 3.0 {
+		t.Errorf("message not processed after the execution finished")
+	}
+}
+
+func TestMaxInFlightDefer(t *testing.T) {
+	manager, release := newSaturatedManager(t, BackpressureDefer)
+	before := backpressureCount(t, BackpressureDefer)
+
+	handled := make(chan struct{})
+	go func() {
+		defer close(handled)
+		manager.HandleMQTTMessage(mqtt.Event{Topic: "sensors/other", Payload: []byte("2")})
+	}()
+
+	select {
+	case <-handled:
+		t.Fatal("message processed while saturated")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release()
+	select {
+	case <-handled:
+	case <-time.After(time.Second):
+		t.Fatal("deferred message was not processed after the execution finished")
+	}
+	if topic := manager.GetExternalTopic("sensors/other"); topic == nil || topic.LastValue() != 2.0 {
+		t.Errorf("deferred message not applied")
+	}
+	if got := backpressureCount(t, BackpressureDefer) - before; got != 1 {
+		t.Errorf("defer count increased by %v, want 1", got)
+	}
+}
+
+func TestSetMaxInFlightValidation(t *testing.T) {
+	manager := NewManager(nil)
+	if err := manager.SetMaxInFlight(-1, BackpressureDefer); err == nil {
+		t.Error("SetMaxInFlight accepted a negative limit")
+	}
+	if err := manager.SetMaxInFlight(4, "queue"); err == nil {
+		t.Error("SetMaxInFlight accepted an invalid policy")
+	}
+	if err := manager.SetMaxInFlight(0, ""); err != nil {
+		t.Errorf("SetMaxInFlight(0) failed: %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package topics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// CanonicalJSON serializes value so that equal values always produce
+// identical bytes, whatever order their maps were built in. Object keys are
+// sorted at every level, including maps with non-string keys (which are
+// formatted with fmt), negative zero is written as 0 and HTML characters are
+// not escaped.
+func CanonicalJSON(value interface{}) ([]byte, error) {
+	normalized, err := canonicalValue(reflect.ValueOf(value))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(normalized); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// canonicalValue rewrites maps as map[string]interface{}, which encoding/json
+// always writes with sorted keys, recursing through maps and slices
+func canonicalValue(value reflect.Value) (interface{}, error) {
+	if !value.IsValid() {
+		return nil, nil
+	}
+	switch value.Kind() {
+	case reflect.Interface, reflect.Pointer, reflect.Map, reflect.Slice:
+		if value.IsNil() {
+			return nil, nil
+		}
+	}
+	if marshaler, ok := value.Interface().(json.Marshaler); ok {
+		// Custom encodings are written as they are
+		return marshaler, nil
+	}
+
+	switch value.Kind() {
+	case reflect.Interface, reflect.Pointer:
+		return canonicalValue(value.Elem())
+	case reflect.Map:
+		object := make(map[string]interface{}, value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			key := fmt.Sprint(iter.Key().Interface())
+			if _, exists := object[key]; exists {
+				return nil, fmt.Errorf("duplicate JSON object key %q", key)
+			}
+			element, err := canonicalValue(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			object[key] = element
+		}
+		return object, nil
+	case reflect.Slice, reflect.Array:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			// Byte slices encode as base64 strings
+			return value.Interface(), nil
+		}
+		elements := make([]interface{}, value.Len())
+		for i := range elements {
+			element, err := canonicalValue(value.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = element
+		}
+		return elements, nil
+	case reflect.Float32, reflect.Float64:
+		if value.Float() == 0 {
+			return 0, nil
+		}
+	}
+
+	return value.Interface(), nil
+}
+
+// SetCanonicalJSON makes MQTT payloads use CanonicalJSON, so equal values are
+// always published as identical bytes
+func (m *Manager) SetCanonicalJSON(enabled bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.canonicalJSON = enabled
+}
+
+// marshalPayload serializes a value published to MQTT
+func (m *Manager) marshalPayload(value interface{}) ([]byte, error) {
+	m.mutex.RLock()
+	canonical := m.canonicalJSON
+	m.mutex.RUnlock()
+
+	if canonical {
+		return CanonicalJSON(value)
+	}
+	return json.Marshal(value)
+}
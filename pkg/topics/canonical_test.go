@@ -0,0 +1,84 @@
+package topics
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCanonicalJSON(t *testing.T) {
+	// Same content, built in opposite orders
+	first := map[string]interface{}{}
+	for _, key := range []string{"zeta", "alpha", "mid"} {
+		first[key] = map[string]interface{}{"b": 2.0, "a": []interface{}{map[string]interface{}{"y": 1.0, "x": true}}}
+	}
+	second := map[string]interface{}{}
+	for _, key := range []string{"mid", "alpha", "zeta"} {
+		second[key] = map[string]interface{}{"a": []interface{}{map[string]interface{}{"x": true, "y": 1.0}}, "b": 2.0}
+	}
+
+	firstJSON, err := CanonicalJSON(first)
+	if err != nil {
+		t.Fatalf("CanonicalJSON failed: %v", err)
+	}
+	secondJSON, err := CanonicalJSON(second)
+	if err != nil {
+		t.Fatalf("CanonicalJSON failed: %v", err)
+	}
+	if string(firstJSON) != string(secondJSON) {
+		t.Errorf("equal maps serialized differently:\n%s\n%s", firstJSON, secondJSON)
+	}
+	want := `{"alpha":{"a":[{"x":true,"y":1}],"b":2},"mid":{"a":[{"x":true,"y":1}],"b":2},"zeta":{"a":[{"x":true,"y":1}],"b":2}}`
+	if string(firstJSON) != want {
+		t.Errorf("CanonicalJSON() = %s, want %s", firstJSON, want)
+	}
+
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    string
+		wantErr bool
+	}{
+		{name: "non-string keys", value: map[interface{}]interface{}{2: "b", 1: "a", "c": 3}, want: `{"1":"a","2":"b","c":3}`},
+		{name: "negative zero", value: []interface{}{math.Copysign(0, -1), 1.5}, want: `[0,1.5]`},
+		{name: "html is not escaped", value: "<on> & <off>", want: `"<on> & <off>"`},
+		{name: "nil", value: nil, want: `null`},
+		{name: "bytes", value: []byte("hi"), want: `"aGk="`},
+		{name: "duplicate keys", value: map[interface{}]interface{}{1: "a", "1": "b"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CanonicalJSON(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CanonicalJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(got) != tt.want {
+				t.Errorf("CanonicalJSON() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInternalTopicCanonicalPayload(t *testing.T) {
+	manager := NewManager(nil)
+	publisher := &mockPublisher{}
+	manager.SetMQTTClient(publisher)
+	manager.SetCanonicalJSON(true)
+	manager.SetStrategyExecutor(&mockStrategyExecutor{
+		executeFunc: func(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+			return map[string]interface{}{"state": "<on>", "brightness": 80.0}, nil
+		},
+	})
+
+	sensor := mustAddExternalTopic(t, manager, "sensors/motion")
+	if _, err := manager.AddInternalTopic("lights/hall", []string{"sensors/motion"}, nil, "test", nil, true, false); err != nil {
+		t.Fatalf("AddInternalTopic failed: %v", err)
+	}
+	if err := sensor.Emit(true); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+
+	if got, want := string(publisher.published["lights/hall"]), `{"brightness":80,"state":"<on>"}`; got != want {
+		t.Errorf("published %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,113 @@
+package topics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DeleteCascade is what happens to the internal topics that have a deleted
+// topic as an input
+type DeleteCascade string
+
+const (
+	// CascadeWarn leaves dependents unchanged and logs a warning
+	CascadeWarn DeleteCascade = "warn"
+	// CascadeDetach removes the deleted topic from the dependents' inputs
+	CascadeDetach DeleteCascade = "detach"
+	// CascadeDelete deletes the dependents too, and in turn their dependents
+	CascadeDelete DeleteCascade = "delete"
+)
+
+// ParseDeleteCascade validates a delete cascade mode; empty means warn
+func ParseDeleteCascade(value string) (DeleteCascade, error) {
+	switch cascade := DeleteCascade(value); cascade {
+	case "":
+		return CascadeWarn, nil
+	case CascadeWarn, CascadeDetach, CascadeDelete:
+		return cascade, nil
+	default:
+		return "", fmt.Errorf("invalid cascade %q (must be delete, detach or warn)", value)
+	}
+}
+
+// CascadeResult reports how a topic's removal affected its dependents
+type CascadeResult struct {
+	// Deleted lists the topics removed, starting with the requested one
+	Deleted []string `json:"deleted"`
+	// Detached lists the dependents whose input on a deleted topic was removed
+	Detached []string `json:"detached,omitempty"`
+	// Dependents lists the dependents left with an input on a deleted topic
+	Dependents []string `json:"dependents,omitempty"`
+}
+
+// RemoveTopicCascade removes a topic and applies cascade to the internal
+// topics that have it as an input. Wildcard inputs are not references to a
+// single topic and are left alone. The caller persists the changes to the
+// deleted and detached topics.
+func (m *Manager) RemoveTopicCascade(name string, cascade DeleteCascade) (CascadeResult, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, exists := m.topics[name]; !exists {
+		return CascadeResult{}, fmt.Errorf("topic %s not found", name)
+	}
+
+	result := CascadeResult{}
+	pending := []string{name}
+	removed := map[string]bool{name: true}
+	for len(pending) > 0 {
+		current := pending[0]
+		pending = pending[1:]
+
+		dependents := m.dependentsOfUnsafe(current, removed)
+		if err := m.removeTopicUnsafe(current); err != nil {
+			return result, err
+		}
+		result.Deleted = append(result.Deleted, current)
+
+		for _, dependent := range dependents {
+			switch cascade {
+			case CascadeDelete:
+				removed[dependent.Name()] = true
+				pending = append(pending, dependent.Name())
+			case CascadeDetach:
+				dependent.RemoveInput(current)
+				result.Detached = append(result.Detached, dependent.Name())
+			default:
+				result.Dependents = append(result.Dependents, dependent.Name())
+			}
+		}
+	}
+
+	if len(result.Dependents) > 0 {
+		m.logger.Printf("Warning: topics still use deleted topic %s as an input: %s", name, strings.Join(result.Dependents, ", "))
+	}
+	if len(result.Detached) > 0 {
+		if m.minimalSubs {
+			m.syncSubscriptionsUnsafe()
+		}
+		m.logger.Printf("Detached deleted topic %s from the inputs of: %s", name, strings.Join(result.Detached, ", "))
+	}
+	return result, nil
+}
+
+// dependentsOfUnsafe returns the internal topics with name as an input,
+// other than those in skip, sorted by name. Must be called with the
+// manager's mutex held.
+func (m *Manager) dependentsOfUnsafe(name string, skip map[string]bool) []*InternalTopic {
+	var dependents []*InternalTopic
+	for topicName, topic := range m.internalTopics {
+		if skip[topicName] {
+			continue
+		}
+		for _, input := range topic.GetInputs() {
+			if input == name {
+				dependents = append(dependents, topic)
+				break
+			}
+		}
+	}
+	sort.Slice(dependents, func(i, j int) bool { return dependents[i].Name() < dependents[j].Name() })
+	return dependents
+}
@@ -0,0 +1,93 @@
+package topics
+
+import (
+	"bytes"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRemoveTopicCascade(t *testing.T) {
+	tests := []struct {
+		cascade     DeleteCascade
+		want        CascadeResult
+		wantTopics  []string
+		wantInputsA []string
+		wantWarning bool
+	}{
+		{
+			cascade:     CascadeDelete,
+			want:        CascadeResult{Deleted: []string{"sensors/temp", "a", "b"}},
+			wantTopics:  []string{"c", "sensors/hum"},
+			wantInputsA: nil,
+		},
+		{
+			cascade:     CascadeDetach,
+			want:        CascadeResult{Deleted: []string{"sensors/temp"}, Detached: []string{"a"}},
+			wantTopics:  []string{"a", "b", "c", "sensors/hum"},
+			wantInputsA: []string{"sensors/hum"},
+		},
+		{
+			cascade:     CascadeWarn,
+			want:        CascadeResult{Deleted: []string{"sensors/temp"}, Dependents: []string{"a"}},
+			wantTopics:  []string{"a", "b", "c", "sensors/hum"},
+			wantInputsA: []string{"sensors/temp", "sensors/hum"},
+			wantWarning: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.cascade), func(t *testing.T) {
+			var logs bytes.Buffer
+			manager := NewManager(log.New(&logs, "", 0))
+			mustAddExternalTopic(t, manager, "sensors/temp")
+			mustAddExternalTopic(t, manager, "sensors/hum")
+			a := mustAddInternalTopic(t, manager, "a", []string{"sensors/temp", "sensors/hum"})
+			a.SetInputName("sensors/temp", "temperature")
+			mustAddInternalTopic(t, manager, "b", []string{"a"})
+			// Wildcards do not reference the deleted topic itself
+			mustAddInternalTopic(t, manager, "c", []string{"sensors/+"})
+
+			result, err := manager.RemoveTopicCascade("sensors/temp", tt.cascade)
+			if err != nil {
+				t.Fatalf("RemoveTopicCascade failed: %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.want) {
+				t.Errorf("result = %+v, want %+v", result, tt.want)
+			}
+
+			var remaining []string
+			for _, name := range []string{"a", "b", "c", "sensors/hum", "sensors/temp"} {
+				if manager.GetTopic(name) != nil {
+					remaining = append(remaining, name)
+				}
+			}
+			if !reflect.DeepEqual(remaining, tt.wantTopics) {
+				t.Errorf("remaining topics = %v, want %v", remaining, tt.wantTopics)
+			}
+			if tt.wantInputsA != nil {
+				if inputs := a.GetInputs(); !reflect.DeepEqual(inputs, tt.wantInputsA) {
+					t.Errorf("a inputs = %v, want %v", inputs, tt.wantInputsA)
+				}
+			}
+			if tt.cascade == CascadeDetach {
+				if _, ok := a.GetInputNames()["sensors/temp"]; ok {
+					t.Error("detached input kept its name")
+				}
+			}
+			if warned := strings.Contains(logs.String(), "Warning: topics still use deleted topic sensors/temp as an input: a"); warned != tt.wantWarning {
+				t.Errorf("warning logged = %v, want %v:\n%s", warned, tt.wantWarning, logs.String())
+			}
+		})
+	}
+}
+
+func TestParseDeleteCascade(t *testing.T) {
+	if cascade, err := ParseDeleteCascade(""); err != nil || cascade != CascadeWarn {
+		t.Errorf("ParseDeleteCascade(\"\") = %q, %v; want warn", cascade, err)
+	}
+	if _, err := ParseDeleteCascade("orphan"); err == nil {
+		t.Error("expected an error for an unknown cascade")
+	}
+}
@@ -0,0 +1,208 @@
+package topics
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// InputType is a type hint for an internal topic input. Input values are
+// coerced to the hinted type before the strategy runs.
+type InputType string
+
+const (
+	InputTypeNumber InputType = "number"
+	InputTypeBool   InputType = "bool"
+	InputTypeJSON   InputType = "json"
+	InputTypeString InputType = "string"
+)
+
+// ParseInputType validates an input type hint
+func ParseInputType(value string) (InputType, error) {
+	switch inputType := InputType(value); inputType {
+	case InputTypeNumber, InputTypeBool, InputTypeJSON, InputTypeString:
+		return inputType, nil
+	default:
+		return "", fmt.Errorf("invalid input type %q (must be number, bool, json or string)", value)
+	}
+}
+
+// ParseInputTypes reads input type hints keyed by input topic, as stored in
+// the topic config (a map decoded from JSON) or set directly
+func ParseInputTypes(value interface{}) (map[string]InputType, error) {
+	hints := make(map[string]InputType)
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case map[string]InputType:
+		for input, inputType := range v {
+			if _, err := ParseInputType(string(inputType)); err != nil {
+				return nil, fmt.Errorf("input %s: %w", input, err)
+			}
+			hints[input] = inputType
+		}
+	case map[string]string:
+		for input, inputType := range v {
+			parsed, err := ParseInputType(inputType)
+			if err != nil {
+				return nil, fmt.Errorf("input %s: %w", input, err)
+			}
+			hints[input] = parsed
+		}
+	case map[string]interface{}:
+		for input, raw := range v {
+			inputType, _ := raw.(string)
+			parsed, err := ParseInputType(inputType)
+			if err != nil {
+				return nil, fmt.Errorf("input %s: %w", input, err)
+			}
+			hints[input] = parsed
+		}
+	default:
+		return nil, fmt.Errorf("invalid input types: %v", value)
+	}
+	return hints, nil
+}
+
+// CoerceInput converts an input value to the hinted type. Nil values are
+// left as nil.
+func CoerceInput(value interface{}, inputType InputType) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	switch inputType {
+	case InputTypeNumber:
+		return coerceNumber(value)
+	case InputTypeBool:
+		return coerceBool(value)
+	case InputTypeJSON:
+		text, ok := value.(string)
+		if !ok {
+			// Already decoded
+			return value, nil
+		}
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(text), &decoded); err != nil {
+			return nil, fmt.Errorf("cannot parse %q as JSON: %w", text, err)
+		}
+		return decoded, nil
+	case InputTypeString:
+		return coerceString(value)
+	default:
+		return nil, fmt.Errorf("invalid input type %q", inputType)
+	}
+}
+
+func coerceNumber(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case float32:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case bool:
+		if v {
+			return 1.0, nil
+		}
+		return 0.0, nil
+	case string:
+		number, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert %q to a number", v)
+		}
+		return number, nil
+	default:
+		return nil, fmt.Errorf("cannot convert %T to a number", value)
+	}
+}
+
+func coerceBool(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case float64:
+		return v != 0, nil
+	case int:
+		return v != 0, nil
+	case int64:
+		return v != 0, nil
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true", "1", "on", "yes":
+			return true, nil
+		case "false", "0", "off", "no":
+			return false, nil
+		}
+		return nil, fmt.Errorf("cannot convert %q to a bool", v)
+	default:
+		return nil, fmt.Errorf("cannot convert %T to a bool", value)
+	}
+}
+
+func coerceString(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert %T to a string: %w", value, err)
+		}
+		return string(data), nil
+	}
+}
+
+// GetInputTypes returns the input type hints keyed by input topic
+func (it *InternalTopic) GetInputTypes() map[string]InputType {
+	hints, err := ParseInputTypes(it.config.Config["input_types"])
+	if err != nil {
+		return nil
+	}
+	return hints
+}
+
+// SetInputTypes sets (or clears, when empty) the input type hints.
+func (it *InternalTopic) SetInputTypes(hints map[string]InputType) error {
+	if _, err := ParseInputTypes(hints); err != nil {
+		return err
+	}
+	if it.config.Config == nil {
+		it.config.Config = make(map[string]interface{})
+	}
+	if len(hints) == 0 {
+		delete(it.config.Config, "input_types")
+		return nil
+	}
+
+	stored := make(map[string]string, len(hints))
+	for input, inputType := range hints {
+		stored[input] = string(inputType)
+	}
+	it.config.Config["input_types"] = stored
+	return nil
+}
+
+// coerceInputValue applies the type hint for inputTopic, logging and keeping
+// the original value when coercion fails
+func (it *InternalTopic) coerceInputValue(hints map[string]InputType, inputTopic string, value interface{}) interface{} {
+	inputType, ok := hints[inputTopic]
+	if !ok {
+		return value
+	}
+
+	coerced, err := CoerceInput(value, inputType)
+	if err != nil {
+		it.manager.logger.Printf("Warning: topic %s input %s: %v", it.config.Name, inputTopic, err)
+		return value
+	}
+	return coerced
+}
@@ -0,0 +1,102 @@
+package topics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCoerceInput(t *testing.T) {
+	tests := []struct {
+		name      string
+		value     interface{}
+		inputType InputType
+		want      interface{}
+		wantErr   bool
+	}{
+		{name: "string to number", value: "21.5", inputType: InputTypeNumber, want: 21.5},
+		{name: "padded string to number", value: " 7 ", inputType: InputTypeNumber, want: 7.0},
+		{name: "number stays number", value: 3.0, inputType: InputTypeNumber, want: 3.0},
+		{name: "bool to number", value: true, inputType: InputTypeNumber, want: 1.0},
+		{name: "malformed number", value: "warm", inputType: InputTypeNumber, wantErr: true},
+		{name: "string true to bool", value: "true", inputType: InputTypeBool, want: true},
+		{name: "ON to bool", value: "ON", inputType: InputTypeBool, want: true},
+		{name: "string 0 to bool", value: "0", inputType: InputTypeBool, want: false},
+		{name: "number to bool", value: 2.0, inputType: InputTypeBool, want: true},
+		{name: "malformed bool", value: "maybe", inputType: InputTypeBool, wantErr: true},
+		{name: "string to json", value: `{"on":true}`, inputType: InputTypeJSON, want: map[string]interface{}{"on": true}},
+		{name: "decoded json stays", value: []interface{}{1.0}, inputType: InputTypeJSON, want: []interface{}{1.0}},
+		{name: "malformed json", value: "{", inputType: InputTypeJSON, wantErr: true},
+		{name: "number to string", value: 21.5, inputType: InputTypeString, want: "21.5"},
+		{name: "object to string", value: map[string]interface{}{"a": 1.0}, inputType: InputTypeString, want: `{"a":1}`},
+		{name: "nil stays nil", value: nil, inputType: InputTypeNumber, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := CoerceInput(tt.value, tt.inputType)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CoerceInput() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CoerceInput() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInternalTopicInputTypes(t *testing.T) {
+	manager := NewManager(nil)
+
+	var executedInputs map[string]interface{}
+	manager.SetStrategyExecutor(&mockStrategyExecutor{
+		executeFunc: func(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+			executedInputs = inputs
+			return "ok", nil
+		},
+	})
+
+	temperature := mustAddExternalTopic(t, manager, "sensors/temp")
+	motion := mustAddExternalTopic(t, manager, "sensors/motion")
+	humidity := mustAddExternalTopic(t, manager, "sensors/humidity")
+	inputs := []string{"sensors/temp", "sensors/motion", "sensors/humidity"}
+	topic, err := manager.AddInternalTopic("house/comfort", inputs, map[string]string{"sensors/temp": "temp"}, "test", nil, false, false)
+	if err != nil {
+		t.Fatalf("Failed to add internal topic: %v", err)
+	}
+	if err := topic.SetInputTypes(map[string]InputType{
+		"sensors/temp":     InputTypeNumber,
+		"sensors/motion":   InputTypeBool,
+		"sensors/humidity": InputTypeNumber,
+	}); err != nil {
+		t.Fatalf("SetInputTypes failed: %v", err)
+	}
+
+	temperature.config.LastValue = "21.5"
+	motion.config.LastValue = "on"
+	if err := humidity.Emit("n/a"); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"temp":             21.5,
+		"sensors/motion":   true,
+		"sensors/humidity": "n/a", // malformed values are passed through unchanged
+	}
+	if !reflect.DeepEqual(executedInputs, want) {
+		t.Errorf("strategy inputs = %#v, want %#v", executedInputs, want)
+	}
+
+	// Hints loaded from the database decode as a generic map
+	topic.GetConfig().Config["input_types"] = map[string]interface{}{"sensors/temp": "string"}
+	temperature.config.LastValue = 21.5
+	if err := humidity.Emit("40"); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	if executedInputs["temp"] != "21.5" || executedInputs["sensors/humidity"] != "40" {
+		t.Errorf("strategy inputs with stored hints = %#v", executedInputs)
+	}
+
+	if err := topic.SetInputTypes(map[string]InputType{"sensors/temp": "float"}); err == nil {
+		t.Error("SetInputTypes accepted an invalid type")
+	}
+}
@@ -0,0 +1,204 @@
+package topics
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/mqtt"
+)
+
+// ErrDependencyCycle is returned for topics whose inputs would make updates
+// propagate in a loop
+var ErrDependencyCycle = errors.New("dependency cycle")
+
+// DependencyCycle is a loop of topics, each triggering the next, that starts
+// and ends with the same topic
+type DependencyCycle []string
+
+func (c DependencyCycle) String() string {
+	return strings.Join(c, " -> ")
+}
+
+// topicDependencies is an internal topic's place in the dependency graph
+type topicDependencies struct {
+	inputs []string
+	// strategy is set for topics that run a strategy, and so may emit
+	// derived child topics
+	strategy bool
+}
+
+// CheckDependencyCycle reports whether giving the internal topic name the
+// inputs and strategy would create a dependency cycle, without changing it
+func (m *Manager) CheckDependencyCycle(name string, inputs []string, strategyID string) error {
+	inputs, _ = m.normalizeInputs(inputs, nil)
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if cycle := m.findCycleUnsafe(name, topicDependencies{inputs: inputs, strategy: strategyID != ""}); cycle != nil {
+		return fmt.Errorf("topic %s would create a dependency cycle: %s: %w", name, cycle, ErrDependencyCycle)
+	}
+	return nil
+}
+
+// DetectCycles returns the dependency cycles among the current topics: the
+// shortest cycle through each topic that is on one, without duplicates
+func (m *Manager) DetectCycles() []DependencyCycle {
+	m.mutex.RLock()
+	index := m.dependencyIndexUnsafe("", topicDependencies{})
+	nodes := make([]string, 0, len(m.topics))
+	for name := range m.topics {
+		nodes = append(nodes, name)
+	}
+	m.mutex.RUnlock()
+	sort.Strings(nodes)
+
+	cycles := []DependencyCycle{}
+	seen := make(map[string]bool)
+	for _, node := range nodes {
+		cycle := shortestCycle(index.successors, node)
+		if cycle == nil {
+			continue
+		}
+		key := canonicalCycle(cycle).String()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		cycles = append(cycles, canonicalCycle(cycle))
+	}
+	return cycles
+}
+
+// findCycleUnsafe returns a cycle through name if it had the given
+// dependencies, or nil. Only the topics reachable from name are visited. Must
+// be called with the manager's mutex held.
+func (m *Manager) findCycleUnsafe(name string, deps topicDependencies) DependencyCycle {
+	return shortestCycle(m.dependencyIndexUnsafe(name, deps).successors, name)
+}
+
+// dependencyIndex finds the internal topics a topic's updates trigger
+type dependencyIndex struct {
+	// exact maps an input topic to the topics that have it as an input
+	exact map[string][]string
+	// wildcards are the wildcard inputs, tested against each topic visited
+	wildcards []wildcardDependency
+	// derived maps a strategy topic to the derived topics it emits
+	derived map[string][]string
+}
+
+type wildcardDependency struct {
+	pattern string
+	topic   string
+}
+
+// dependencyIndexUnsafe indexes the internal topics' inputs, with override
+// (when set) given deps in place of its current inputs. Wildcard inputs are
+// triggered by every topic they match, and derived topics by the strategy
+// topic they are under. Must be called with the manager's mutex held.
+func (m *Manager) dependencyIndexUnsafe(override string, deps topicDependencies) *dependencyIndex {
+	internal := make(map[string]topicDependencies, len(m.internalTopics)+1)
+	for name, topic := range m.internalTopics {
+		internal[name] = topicDependencies{inputs: topic.GetInputs(), strategy: topic.config.StrategyID != ""}
+	}
+	if override != "" {
+		internal[override] = deps
+	}
+
+	index := &dependencyIndex{
+		exact:   make(map[string][]string),
+		derived: make(map[string][]string),
+	}
+	for name, topic := range internal {
+		for _, input := range topic.inputs {
+			if strings.ContainsAny(input, "+#") {
+				index.wildcards = append(index.wildcards, wildcardDependency{pattern: input, topic: name})
+			} else {
+				index.exact[input] = append(index.exact[input], name)
+			}
+		}
+
+		// Derived topics have neither a strategy nor inputs; the closest
+		// strategy topic above one emits it
+		if topic.strategy || len(topic.inputs) > 0 {
+			continue
+		}
+		for parent := name; strings.Contains(parent, "/"); {
+			parent = parent[:strings.LastIndex(parent, "/")]
+			if internal[parent].strategy {
+				index.derived[parent] = append(index.derived[parent], name)
+				break
+			}
+		}
+	}
+	return index
+}
+
+// successors returns the topics an update to node triggers, sorted
+func (ix *dependencyIndex) successors(node string) []string {
+	targets := make(map[string]bool)
+	for _, topic := range ix.exact[node] {
+		targets[topic] = true
+	}
+	for _, topic := range ix.derived[node] {
+		targets[topic] = true
+	}
+	for _, wildcard := range ix.wildcards {
+		if mqtt.TopicMatches(wildcard.pattern, node) {
+			targets[wildcard.topic] = true
+		}
+	}
+
+	next := make([]string, 0, len(targets))
+	for topic := range targets {
+		next = append(next, topic)
+	}
+	sort.Strings(next)
+	return next
+}
+
+// shortestCycle returns the shortest path from start back to itself following
+// successors, or nil
+func shortestCycle(successors func(string) []string, start string) DependencyCycle {
+	previous := map[string]string{}
+	queue := []string{start}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, next := range successors(node) {
+			if next == start {
+				cycle := DependencyCycle{start}
+				for step := node; step != start; step = previous[step] {
+					cycle = append(cycle, step)
+				}
+				// Built backwards; reverse all but the start
+				for i, j := 1, len(cycle)-1; i < j; i, j = i+1, j-1 {
+					cycle[i], cycle[j] = cycle[j], cycle[i]
+				}
+				return append(cycle, start)
+			}
+			if _, visited := previous[next]; !visited {
+				previous[next] = node
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
+
+// canonicalCycle rotates a cycle to start at its smallest topic name, so the
+// same cycle found from different topics compares equal
+func canonicalCycle(cycle DependencyCycle) DependencyCycle {
+	loop := cycle[:len(cycle)-1]
+	first := 0
+	for i, name := range loop {
+		if name < loop[first] {
+			first = i
+		}
+	}
+	rotated := append(DependencyCycle{}, loop[first:]...)
+	rotated = append(rotated, loop[:first]...)
+	return append(rotated, rotated[0])
+}
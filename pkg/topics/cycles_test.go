@@ -0,0 +1,164 @@
+package topics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"reflect"
+	"testing"
+)
+
+func TestAddInternalTopicRejectsCycles(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, manager *Manager)
+		topic   string
+		inputs  []string
+		wantErr string
+	}{
+		{
+			name: "direct cycle",
+			setup: func(t *testing.T, manager *Manager) {
+				mustAddInternalTopic(t, manager, "a", []string{"b"})
+			},
+			topic:   "b",
+			inputs:  []string{"a"},
+			wantErr: "adding topic b would create a dependency cycle: b -> a -> b: dependency cycle",
+		},
+		{
+			name: "indirect cycle",
+			setup: func(t *testing.T, manager *Manager) {
+				mustAddInternalTopic(t, manager, "a", []string{"c"})
+				mustAddInternalTopic(t, manager, "b", []string{"a"})
+			},
+			topic:   "c",
+			inputs:  []string{"sensors/temp", "b"},
+			wantErr: "adding topic c would create a dependency cycle: c -> a -> b -> c: dependency cycle",
+		},
+		{
+			name:    "wildcard matching itself",
+			topic:   "rooms/summary",
+			inputs:  []string{"rooms/+"},
+			wantErr: "adding topic rooms/summary would create a dependency cycle: rooms/summary -> rooms/summary: dependency cycle",
+		},
+		{
+			name: "cycle through a derived topic",
+			setup: func(t *testing.T, manager *Manager) {
+				mustAddInternalTopic(t, manager, "hvac", []string{"hvac/guard"})
+				if err := manager.createOrUpdateDerivedTopic(context.Background(), "hvac/mode", "heat", false); err != nil {
+					t.Fatalf("createOrUpdateDerivedTopic failed: %v", err)
+				}
+			},
+			topic:   "hvac/guard",
+			inputs:  []string{"hvac/mode"},
+			wantErr: "adding topic hvac/guard would create a dependency cycle: hvac/guard -> hvac -> hvac/mode -> hvac/guard: dependency cycle",
+		},
+		{
+			name: "acyclic chain",
+			setup: func(t *testing.T, manager *Manager) {
+				mustAddInternalTopic(t, manager, "a", []string{"sensors/temp"})
+				mustAddInternalTopic(t, manager, "b", []string{"a", "sensors/+"})
+			},
+			topic:  "c",
+			inputs: []string{"a", "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewManager(nil)
+			mustAddExternalTopic(t, manager, "sensors/temp")
+			if tt.setup != nil {
+				tt.setup(t, manager)
+			}
+
+			_, err := manager.AddInternalTopic(tt.topic, tt.inputs, nil, "test-strategy", nil, false, false)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("AddInternalTopic failed: %v", err)
+				}
+				if cycles := manager.DetectCycles(); len(cycles) != 0 {
+					t.Errorf("DetectCycles() = %v, want none", cycles)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr || !errors.Is(err, ErrDependencyCycle) {
+				t.Fatalf("AddInternalTopic error = %v, want %q", err, tt.wantErr)
+			}
+			if manager.GetTopic(tt.topic) != nil {
+				t.Error("topic was added despite the cycle")
+			}
+		})
+	}
+}
+
+func TestReloadTopicFromDatabaseRejectsCycles(t *testing.T) {
+	manager := NewManager(nil)
+	mustAddInternalTopic(t, manager, "a", []string{"b"})
+	b := mustAddInternalTopic(t, manager, "b", []string{"sensors/temp"})
+	manager.SetStateManager(&mockStateManager{
+		loadConfigFunc: func(topicName string) (interface{}, error) {
+			return InternalTopicConfig{
+				BaseTopicConfig: BaseTopicConfig{Name: "b", Type: TopicTypeInternal},
+				Inputs:          []string{"a"},
+				StrategyID:      "test-strategy",
+			}, nil
+		},
+	})
+
+	err := manager.ReloadTopicFromDatabase("b")
+	if err == nil || !errors.Is(err, ErrDependencyCycle) {
+		t.Fatalf("ReloadTopicFromDatabase error = %v, want a dependency cycle", err)
+	}
+	if inputs := b.GetInputs(); !reflect.DeepEqual(inputs, []string{"sensors/temp"}) {
+		t.Errorf("inputs = %v after a rejected reload, want them unchanged", inputs)
+	}
+}
+
+func TestDetectCycles(t *testing.T) {
+	manager := NewManager(nil)
+	mustAddInternalTopic(t, manager, "a", []string{"b"})
+	b := mustAddInternalTopic(t, manager, "b", []string{"sensors/temp"})
+	c := mustAddInternalTopic(t, manager, "c", []string{"a"})
+	mustAddInternalTopic(t, manager, "d", []string{"c"})
+
+	// Inputs changed directly are not checked
+	b.AddInput("c")
+	c.AddInput("c")
+
+	want := []DependencyCycle{
+		{"a", "c", "b", "a"},
+		{"c", "c"},
+	}
+	if cycles := manager.DetectCycles(); !reflect.DeepEqual(cycles, want) {
+		t.Errorf("DetectCycles() = %v, want %v", cycles, want)
+	}
+}
+
+func mustAddInternalTopic(t *testing.T, manager *Manager, name string, inputs []string) *InternalTopic {
+	t.Helper()
+
+	topic, err := manager.AddInternalTopic(name, inputs, nil, "test-strategy", nil, false, false)
+	if err != nil {
+		t.Fatalf("Failed to add internal topic %s: %v", name, err)
+	}
+	return topic
+}
+
+func BenchmarkAddInternalTopics(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		manager := NewManager(log.New(io.Discard, "", 0))
+		for j := 0; j < 1000; j++ {
+			// A chain of rooms, each also summarised by a wildcard topic
+			inputs := []string{fmt.Sprintf("rooms/%04d/temp", j), "sensors/+/temp"}
+			if j > 0 {
+				inputs = append(inputs, fmt.Sprintf("rooms/%04d", j-1))
+			}
+			if _, err := manager.AddInternalTopic(fmt.Sprintf("rooms/%04d", j), inputs, nil, "test-strategy", nil, false, false); err != nil {
+				b.Fatalf("AddInternalTopic failed: %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package topics
+
+import (
+	"fmt"
+	"time"
+)
+
+// DeadLetter describes a failed strategy execution, published so the failing
+// inputs can be inspected and replayed
+type DeadLetter struct {
+	TopicName    string                 `json:"topic"`
+	StrategyID   string                 `json:"strategy_id"`
+	TriggerTopic string                 `json:"trigger_topic"`
+	Inputs       map[string]interface{} `json:"inputs"`
+	Error        string                 `json:"error"`
+	FailedAt     time.Time              `json:"failed_at"`
+}
+
+// SetDeadLetterTopic sets the MQTT topic failed strategy executions are
+// published to. An empty topic disables dead-lettering.
+func (m *Manager) SetDeadLetterTopic(topic string) error {
+	if topic != "" {
+		if err := m.ValidateTopicName(topic); err != nil {
+			return fmt.Errorf("invalid dead letter topic: %w", err)
+		}
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.deadLetterTopic = topic
+	return nil
+}
+
+// DeadLetterTopic returns the configured dead letter topic, if any
+func (m *Manager) DeadLetterTopic() string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.deadLetterTopic
+}
+
+// publishDeadLetter publishes a failed execution to the dead letter topic
+func (m *Manager) publishDeadLetter(record ExecutionRecord) {
+	topic := m.DeadLetterTopic()
+	if topic == "" || m.mqttClient == nil {
+		return
+	}
+
+	payload, err := m.marshalPayload(DeadLetter{
+		TopicName:    record.TopicName,
+		StrategyID:   record.StrategyID,
+		TriggerTopic: record.TriggerTopic,
+		Inputs:       record.Inputs,
+		Error:        record.Error,
+		FailedAt:     record.ExecutedAt,
+	})
+	if err != nil {
+		m.logger.Printf("Failed to encode dead letter for topic %s: %v", record.TopicName, err)
+		return
+	}
+
+	if err := m.mqttClient.Publish(topic, payload, false); err != nil {
+		m.logger.Printf("Failed to publish dead letter for topic %s: %v", record.TopicName, err)
+	}
+}
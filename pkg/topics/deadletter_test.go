@@ -0,0 +1,82 @@
+package topics
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestInternalTopicDeadLetter(t *testing.T) {
+	manager := NewManager(nil)
+	publisher := &mockPublisher{}
+	manager.SetMQTTClient(publisher)
+	manager.SetStrategyExecutor(&mockStrategyExecutor{
+		executeFunc: func(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+			if strategyID == "broken" {
+				return nil, errors.New("boom")
+			}
+			return "ok", nil
+		},
+	})
+	if err := manager.SetDeadLetterTopic("automation/dead-letter"); err != nil {
+		t.Fatalf("SetDeadLetterTopic failed: %v", err)
+	}
+
+	sensor := mustAddExternalTopic(t, manager, "sensors/temp")
+	if _, err := manager.AddInternalTopic("house/broken", []string{"sensors/temp"}, map[string]string{"sensors/temp": "temp"}, "broken", nil, false, false); err != nil {
+		t.Fatalf("Failed to add internal topic: %v", err)
+	}
+
+	if err := sensor.Emit(21.5); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+
+	payload, ok := publisher.published["automation/dead-letter"]
+	if !ok {
+		t.Fatalf("no dead letter published; published %v", publisher.published)
+	}
+	var letter DeadLetter
+	if err := json.Unmarshal(payload, &letter); err != nil {
+		t.Fatalf("invalid dead letter payload %s: %v", payload, err)
+	}
+	if letter.TopicName != "house/broken" || letter.StrategyID != "broken" || letter.TriggerTopic != "sensors/temp" {
+		t.Errorf("dead letter = %+v", letter)
+	}
+	if letter.Inputs["temp"] != 21.5 {
+		t.Errorf("dead letter inputs = %v, want temp 21.5", letter.Inputs)
+	}
+	if letter.Error != "boom" || letter.FailedAt.IsZero() {
+		t.Errorf("dead letter error = %q at %v", letter.Error, letter.FailedAt)
+	}
+}
+
+func TestInternalTopicDeadLetterDisabled(t *testing.T) {
+	manager := NewManager(nil)
+	publisher := &mockPublisher{}
+	manager.SetMQTTClient(publisher)
+	manager.SetStrategyExecutor(&mockStrategyExecutor{
+		executeFunc: func(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+			return nil, errors.New("boom")
+		},
+	})
+
+	sensor := mustAddExternalTopic(t, manager, "sensors/temp")
+	if _, err := manager.AddInternalTopic("house/broken", []string{"sensors/temp"}, nil, "broken", nil, false, false); err != nil {
+		t.Fatalf("Failed to add internal topic: %v", err)
+	}
+	sensor.Emit(21.5)
+
+	if len(publisher.published) != 0 {
+		t.Errorf("published %v without a dead letter topic", publisher.published)
+	}
+}
+
+func TestSetDeadLetterTopicRejectsWildcards(t *testing.T) {
+	manager := NewManager(nil)
+	if err := manager.SetDeadLetterTopic("automation/+/dead"); err == nil {
+		t.Error("expected wildcard dead letter topic to be rejected")
+	}
+	if err := manager.SetDeadLetterTopic(""); err != nil {
+		t.Errorf("clearing the dead letter topic failed: %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package topics
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// chainKey marks the context of a propagation chain, so updates further down
+// the chain share the deadline of the update that started it
+type chainKey struct{}
+
+// chainContext returns the context of the propagation chain an update belongs
+// to. Updates outside a chain start one, limited to deadline when it is set.
+func chainContext(ctx context.Context, deadline time.Duration) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if ctx.Value(chainKey{}) != nil {
+		return ctx, func() {}
+	}
+
+	ctx = context.WithValue(ctx, chainKey{}, true)
+	if deadline <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, deadline)
+}
+
+// SetChainDeadline limits how long a whole propagation chain may run, from
+// the update that starts it through every dependent topic it triggers.
+// Executions still pending when it passes are skipped. 0 disables the limit.
+func (m *Manager) SetChainDeadline(deadline time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.chainDeadline = deadline
+}
+
+// logChainDeadline reports the dependents skipped because the chain passed
+// its deadline
+func (m *Manager) logChainDeadline(topicName string, skipped []*InternalTopic) {
+	names := make([]string, len(skipped))
+	for i, topic := range skipped {
+		names[i] = topic.Name()
+	}
+	m.logger.Printf("Warning: propagation chain passed its deadline at %s; skipped %d dependent topics: %s",
+		topicName, len(skipped), strings.Join(names, ", "))
+}
@@ -0,0 +1,66 @@
+package topics
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChainDeadlineSkipsRemainingExecutions(t *testing.T) {
+	var logs bytes.Buffer
+	manager := NewManager(log.New(&logs, "", 0))
+	manager.SetChainDeadline(20 * time.Millisecond)
+
+	var executed []string
+	manager.SetStrategyExecutor(&mockStrategyExecutor{
+		executeFunc: func(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+			executed = append(executed, strategyID)
+			if strategyID == "step-2" {
+				// Outlasts the chain deadline
+				time.Sleep(50 * time.Millisecond)
+			}
+			return inputs[triggerTopic], nil
+		},
+	})
+
+	source := mustAddExternalTopic(t, manager, "sensors/temp")
+	input := "sensors/temp"
+	for i := 1; i <= 5; i++ {
+		name := fmt.Sprintf("chain/%d", i)
+		if _, err := manager.AddInternalTopic(name, []string{input}, nil, fmt.Sprintf("step-%d", i), nil, false, false); err != nil {
+			t.Fatalf("Failed to add internal topic %s: %v", name, err)
+		}
+		input = name
+	}
+
+	if err := source.Emit(21.5); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+
+	if want := []string{"step-1", "step-2"}; !reflect.DeepEqual(executed, want) {
+		t.Errorf("executed = %v, want %v", executed, want)
+	}
+	if value := manager.GetTopic("chain/2").LastValue(); value != 21.5 {
+		t.Errorf("chain/2 = %v, want the value emitted before the deadline", value)
+	}
+	if value := manager.GetTopic("chain/3").LastValue(); value != nil {
+		t.Errorf("chain/3 = %v, want no value", value)
+	}
+	if !strings.Contains(logs.String(), "passed its deadline at chain/2; skipped 1 dependent topics: chain/3") {
+		t.Errorf("logs do not report the skipped work:\n%s", logs.String())
+	}
+
+	// The next update starts a new chain with its own deadline
+	executed = nil
+	manager.SetChainDeadline(0)
+	if err := source.Emit(22.5); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	if len(executed) != 5 {
+		t.Errorf("executed = %v without a deadline, want all 5 steps", executed)
+	}
+}
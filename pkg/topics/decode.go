@@ -0,0 +1,158 @@
+package topics
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// InputDecoder decodes an encoded internal topic input before the strategy
+// runs. Decoding happens before type hints are applied.
+type InputDecoder string
+
+const (
+	InputDecoderNone      InputDecoder = "none"
+	InputDecoderBase64    InputDecoder = "base64"
+	InputDecoderHex       InputDecoder = "hex"
+	InputDecoderURL       InputDecoder = "url"
+	InputDecoderJSONParse InputDecoder = "json-parse"
+)
+
+// ParseInputDecoder validates an input decoder
+func ParseInputDecoder(value string) (InputDecoder, error) {
+	switch decoder := InputDecoder(value); decoder {
+	case InputDecoderNone, InputDecoderBase64, InputDecoderHex, InputDecoderURL, InputDecoderJSONParse:
+		return decoder, nil
+	default:
+		return "", fmt.Errorf("invalid input decoder %q (must be none, base64, hex, url or json-parse)", value)
+	}
+}
+
+// ParseInputDecoders reads input decoders keyed by input topic, as stored in
+// the topic config (a map decoded from JSON) or set directly
+func ParseInputDecoders(value interface{}) (map[string]InputDecoder, error) {
+	decoders := make(map[string]InputDecoder)
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case map[string]InputDecoder:
+		for input, decoder := range v {
+			if _, err := ParseInputDecoder(string(decoder)); err != nil {
+				return nil, fmt.Errorf("input %s: %w", input, err)
+			}
+			decoders[input] = decoder
+		}
+	case map[string]string:
+		for input, decoder := range v {
+			parsed, err := ParseInputDecoder(decoder)
+			if err != nil {
+				return nil, fmt.Errorf("input %s: %w", input, err)
+			}
+			decoders[input] = parsed
+		}
+	case map[string]interface{}:
+		for input, raw := range v {
+			decoder, _ := raw.(string)
+			parsed, err := ParseInputDecoder(decoder)
+			if err != nil {
+				return nil, fmt.Errorf("input %s: %w", input, err)
+			}
+			decoders[input] = parsed
+		}
+	default:
+		return nil, fmt.Errorf("invalid input decoders: %v", value)
+	}
+	return decoders, nil
+}
+
+// DecodeInput decodes an input value. Only strings are decoded; nil values
+// are left as nil.
+func DecodeInput(value interface{}, decoder InputDecoder) (interface{}, error) {
+	if value == nil || decoder == InputDecoderNone {
+		return value, nil
+	}
+	text, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("cannot %s-decode %T", decoder, value)
+	}
+
+	switch decoder {
+	case InputDecoderBase64:
+		text = strings.TrimSpace(text)
+		for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+			if decoded, err := encoding.DecodeString(text); err == nil {
+				return string(decoded), nil
+			}
+		}
+		return nil, fmt.Errorf("cannot decode %q as base64", text)
+	case InputDecoderHex:
+		decoded, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(text), "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("cannot decode %q as hex: %w", text, err)
+		}
+		return string(decoded), nil
+	case InputDecoderURL:
+		decoded, err := url.QueryUnescape(text)
+		if err != nil {
+			return nil, fmt.Errorf("cannot URL-decode %q: %w", text, err)
+		}
+		return decoded, nil
+	case InputDecoderJSONParse:
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(text), &decoded); err != nil {
+			return nil, fmt.Errorf("cannot parse %q as JSON: %w", text, err)
+		}
+		return decoded, nil
+	default:
+		return nil, fmt.Errorf("invalid input decoder %q", decoder)
+	}
+}
+
+// GetInputDecoders returns the input decoders keyed by input topic
+func (it *InternalTopic) GetInputDecoders() map[string]InputDecoder {
+	decoders, err := ParseInputDecoders(it.config.Config["input_decoders"])
+	if err != nil {
+		return nil
+	}
+	return decoders
+}
+
+// SetInputDecoders sets (or clears, when empty) the input decoders.
+func (it *InternalTopic) SetInputDecoders(decoders map[string]InputDecoder) error {
+	if _, err := ParseInputDecoders(decoders); err != nil {
+		return err
+	}
+	if it.config.Config == nil {
+		it.config.Config = make(map[string]interface{})
+	}
+	if len(decoders) == 0 {
+		delete(it.config.Config, "input_decoders")
+		return nil
+	}
+
+	stored := make(map[string]string, len(decoders))
+	for input, decoder := range decoders {
+		stored[input] = string(decoder)
+	}
+	it.config.Config["input_decoders"] = stored
+	return nil
+}
+
+// decodeInputValue applies the decoder for inputTopic, logging and keeping
+// the original value when decoding fails
+func (it *InternalTopic) decodeInputValue(decoders map[string]InputDecoder, inputTopic string, value interface{}) interface{} {
+	decoder, ok := decoders[inputTopic]
+	if !ok {
+		return value
+	}
+
+	decoded, err := DecodeInput(value, decoder)
+	if err != nil {
+		it.manager.logger.Printf("Warning: topic %s input %s: %v", it.config.Name, inputTopic, err)
+		return value
+	}
+	return decoded
+}
@@ -0,0 +1,99 @@
+package topics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeInput(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   interface{}
+		decoder InputDecoder
+		want    interface{}
+		wantErr bool
+	}{
+		{name: "base64", value: "aGVsbG8gd29ybGQ=", decoder: InputDecoderBase64, want: "hello world"},
+		{name: "unpadded base64", value: "aGVsbG8", decoder: InputDecoderBase64, want: "hello"},
+		{name: "url-safe base64", value: "Pz8_", decoder: InputDecoderBase64, want: "???"},
+		{name: "malformed base64", value: "not base64!", decoder: InputDecoderBase64, wantErr: true},
+		{name: "hex", value: "48656c6c6f", decoder: InputDecoderHex, want: "Hello"},
+		{name: "hex with prefix", value: "0x32312e35", decoder: InputDecoderHex, want: "21.5"},
+		{name: "malformed hex", value: "zz", decoder: InputDecoderHex, wantErr: true},
+		{name: "url", value: "living%20room+lamp", decoder: InputDecoderURL, want: "living room lamp"},
+		{name: "json-parse", value: `{"on":true}`, decoder: InputDecoderJSONParse, want: map[string]interface{}{"on": true}},
+		{name: "malformed json", value: "{", decoder: InputDecoderJSONParse, wantErr: true},
+		{name: "none passes through", value: 21.5, decoder: InputDecoderNone, want: 21.5},
+		{name: "non-string", value: 21.5, decoder: InputDecoderHex, wantErr: true},
+		{name: "nil stays nil", value: nil, decoder: InputDecoderBase64, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeInput(tt.value, tt.decoder)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeInput() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodeInput() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInternalTopicInputDecoders(t *testing.T) {
+	manager := NewManager(nil)
+
+	var executedInputs map[string]interface{}
+	manager.SetStrategyExecutor(&mockStrategyExecutor{
+		executeFunc: func(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+			executedInputs = inputs
+			return "ok", nil
+		},
+	})
+
+	payload := mustAddExternalTopic(t, manager, "devices/lora/payload")
+	raw := mustAddExternalTopic(t, manager, "devices/serial/raw")
+	inputs := []string{"devices/lora/payload", "devices/serial/raw"}
+	topic, err := manager.AddInternalTopic("devices/decoded", inputs, map[string]string{"devices/lora/payload": "payload"}, "test", nil, false, false)
+	if err != nil {
+		t.Fatalf("Failed to add internal topic: %v", err)
+	}
+	if err := topic.SetInputDecoders(map[string]InputDecoder{
+		"devices/lora/payload": InputDecoderBase64,
+		"devices/serial/raw":   InputDecoderHex,
+	}); err != nil {
+		t.Fatalf("SetInputDecoders failed: %v", err)
+	}
+	// Decoding happens before type hints
+	if err := topic.SetInputTypes(map[string]InputType{"devices/serial/raw": InputTypeNumber}); err != nil {
+		t.Fatalf("SetInputTypes failed: %v", err)
+	}
+
+	payload.config.LastValue = "eyJ0ZW1wIjoyMS41fQ=="
+	if err := raw.Emit("32312e35"); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+
+	want := map[string]interface{}{
+		"payload":            `{"temp":21.5}`,
+		"devices/serial/raw": 21.5,
+	}
+	if !reflect.DeepEqual(executedInputs, want) {
+		t.Errorf("strategy inputs = %#v, want %#v", executedInputs, want)
+	}
+
+	// Decoders loaded from the database decode as a generic map
+	topic.GetConfig().Config["input_decoders"] = map[string]interface{}{"devices/lora/payload": "json-parse"}
+	payload.config.LastValue = `{"temp":19.5}`
+	if err := raw.Emit("n/a"); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	if !reflect.DeepEqual(executedInputs["payload"], map[string]interface{}{"temp": 19.5}) || executedInputs["devices/serial/raw"] != "n/a" {
+		t.Errorf("strategy inputs with stored decoders = %#v", executedInputs)
+	}
+
+	if err := topic.SetInputDecoders(map[string]InputDecoder{"devices/serial/raw": "rot13"}); err == nil {
+		t.Error("SetInputDecoders accepted an invalid decoder")
+	}
+}
@@ -0,0 +1,72 @@
+package topics
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// MaxDisplayNameLength is the longest accepted display name in characters
+const MaxDisplayNameLength = 128
+
+// ParseDisplayName validates a display name and trims surrounding whitespace.
+// An empty display name is valid and means the topic has none.
+func ParseDisplayName(value string) (string, error) {
+	name := strings.TrimSpace(value)
+	if len([]rune(name)) > MaxDisplayNameLength {
+		return "", fmt.Errorf("display name must be at most %d characters", MaxDisplayNameLength)
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return "", fmt.Errorf("display name must not contain control characters")
+		}
+	}
+	return name, nil
+}
+
+// DisplayName returns the display name stored in a topic config, if any
+func DisplayName(config map[string]interface{}) string {
+	name, _ := config["display_name"].(string)
+	return name
+}
+
+// GetDisplayName returns the topic's display name. The canonical name is
+// still the topic's identity.
+func (it *InternalTopic) GetDisplayName() string {
+	return DisplayName(it.config.Config)
+}
+
+// SetDisplayName sets (or clears, when empty) the topic's display name.
+func (it *InternalTopic) SetDisplayName(displayName string) error {
+	name, err := ParseDisplayName(displayName)
+	if err != nil {
+		return err
+	}
+	if it.config.Config == nil {
+		it.config.Config = make(map[string]interface{})
+	}
+	if name == "" {
+		delete(it.config.Config, "display_name")
+	} else {
+		it.config.Config["display_name"] = name
+	}
+	return nil
+}
+
+// FindTopicByDisplayName returns the canonical name of the internal topic
+// with the given display name
+func (m *Manager) FindTopicByDisplayName(displayName string) (string, bool) {
+	if displayName == "" {
+		return "", false
+	}
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	for name, topic := range m.internalTopics {
+		if topic.GetDisplayName() == displayName {
+			return name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,179 @@
+package topics
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PublishEncoding controls how an internal topic's value is serialized when
+// it is published to MQTT
+type PublishEncoding string
+
+const (
+	// PublishEncodingJSON publishes JSON (default)
+	PublishEncodingJSON PublishEncoding = "json"
+	// PublishEncodingCSV publishes arrays as a single comma-separated line
+	PublishEncodingCSV PublishEncoding = "csv"
+	// PublishEncodingKV publishes maps as comma-separated key=value pairs,
+	// sorted by key
+	PublishEncodingKV PublishEncoding = "kv"
+	// PublishEncodingPlain publishes scalars as bare text, e.g. strings
+	// without quotes
+	PublishEncodingPlain PublishEncoding = "plain"
+)
+
+// ParsePublishEncoding validates a publish encoding, treating empty as
+// PublishEncodingJSON
+func ParsePublishEncoding(encoding string) (PublishEncoding, error) {
+	switch PublishEncoding(encoding) {
+	case "", PublishEncodingJSON:
+		return PublishEncodingJSON, nil
+	case PublishEncodingCSV, PublishEncodingKV, PublishEncodingPlain:
+		return PublishEncoding(encoding), nil
+	default:
+		return "", fmt.Errorf("invalid publish encoding %q: expected json, csv, kv or plain", encoding)
+	}
+}
+
+// GetPublishEncoding returns how the topic's value is serialized for MQTT
+func (it *InternalTopic) GetPublishEncoding() PublishEncoding {
+	encoding, _ := it.config.Config["publish_encoding"].(string)
+	parsed, err := ParsePublishEncoding(encoding)
+	if err != nil {
+		return PublishEncodingJSON
+	}
+	return parsed
+}
+
+// SetPublishEncoding sets the topic's publish encoding. An empty encoding
+// uses JSON.
+func (it *InternalTopic) SetPublishEncoding(encoding PublishEncoding) error {
+	parsed, err := ParsePublishEncoding(string(encoding))
+	if err != nil {
+		return err
+	}
+	if parsed == PublishEncodingJSON {
+		delete(it.config.Config, "publish_encoding")
+		return nil
+	}
+
+	if it.config.Config == nil {
+		it.config.Config = make(map[string]interface{})
+	}
+	it.config.Config["publish_encoding"] = string(parsed)
+	return nil
+}
+
+// encodePayload serializes a value published to MQTT with encoding. Values
+// the encoding does not apply to (e.g. a map published as csv) fall back to
+// JSON.
+func (m *Manager) encodePayload(value interface{}, encoding PublishEncoding) ([]byte, error) {
+	var payload []byte
+	var ok bool
+	var err error
+	switch encoding {
+	case PublishEncodingCSV:
+		payload, ok, err = encodeCSV(value)
+	case PublishEncodingKV:
+		payload, ok, err = encodeKV(value)
+	case PublishEncodingPlain:
+		var text string
+		text, ok = plainText(value)
+		payload = []byte(text)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return m.marshalPayload(value)
+	}
+	return payload, nil
+}
+
+// encodeCSV writes the elements of an array as one CSV record
+func encodeCSV(value interface{}) ([]byte, bool, error) {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() || (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) || v.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, false, nil
+	}
+
+	record := make([]string, v.Len())
+	for i := range record {
+		field, err := encodeField(v.Index(i).Interface())
+		if err != nil {
+			return nil, false, err
+		}
+		record[i] = field
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write(record); err != nil {
+		return nil, false, err
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, false, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), true, nil
+}
+
+// encodeKV writes a map as key=value pairs sorted by key
+func encodeKV(value interface{}) ([]byte, bool, error) {
+	v := reflect.ValueOf(value)
+	if !v.IsValid() || v.Kind() != reflect.Map {
+		return nil, false, nil
+	}
+
+	pairs := make([]string, 0, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		field, err := encodeField(iter.Value().Interface())
+		if err != nil {
+			return nil, false, err
+		}
+		pairs = append(pairs, fmt.Sprint(iter.Key().Interface())+"="+field)
+	}
+	sort.Strings(pairs)
+	return []byte(strings.Join(pairs, ",")), true, nil
+}
+
+// encodeField writes a scalar as plain text and anything nested as JSON
+func encodeField(value interface{}) (string, error) {
+	if text, ok := plainText(value); ok {
+		return text, nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// plainText formats a scalar without JSON quoting; nil is empty
+func plainText(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case nil:
+		return "", true
+	case string:
+		return v, true
+	case bool:
+		return strconv.FormatBool(v), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), true
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprint(v), true
+	case json.Number:
+		return v.String(), true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,81 @@
+package topics
+
+import (
+	"testing"
+)
+
+func TestEncodePayload(t *testing.T) {
+	mapValue := map[string]interface{}{"temp": 21.5, "state": "on", "tags": []interface{}{"a", "b"}}
+	arrayValue := []interface{}{21.5, "living room, north", true, nil, map[string]interface{}{"x": 1.5}}
+
+	tests := []struct {
+		name     string
+		value    interface{}
+		encoding PublishEncoding
+		want     string
+	}{
+		{name: "map as json", value: mapValue, encoding: PublishEncodingJSON, want: `{"state":"on","tags":["a","b"],"temp":21.5}`},
+		{name: "array as json", value: arrayValue, encoding: PublishEncodingJSON, want: `[21.5,"living room, north",true,null,{"x":1.5}]`},
+		{name: "map as csv falls back to json", value: mapValue, encoding: PublishEncodingCSV, want: `{"state":"on","tags":["a","b"],"temp":21.5}`},
+		{name: "array as csv", value: arrayValue, encoding: PublishEncodingCSV, want: `21.5,"living room, north",true,,"{""x"":1.5}"`},
+		{name: "typed array as csv", value: []float64{1.5, 2, -3.25}, encoding: PublishEncodingCSV, want: `1.5,2,-3.25`},
+		{name: "map as kv", value: mapValue, encoding: PublishEncodingKV, want: `state=on,tags=["a","b"],temp=21.5`},
+		{name: "array as kv falls back to json", value: arrayValue, encoding: PublishEncodingKV, want: `[21.5,"living room, north",true,null,{"x":1.5}]`},
+		{name: "map as plain falls back to json", value: mapValue, encoding: PublishEncodingPlain, want: `{"state":"on","tags":["a","b"],"temp":21.5}`},
+		{name: "array as plain falls back to json", value: arrayValue, encoding: PublishEncodingPlain, want: `[21.5,"living room, north",true,null,{"x":1.5}]`},
+		{name: "string as plain", value: "on", encoding: PublishEncodingPlain, want: `on`},
+		{name: "number as plain", value: 21.5, encoding: PublishEncodingPlain, want: `21.5`},
+		{name: "nil as plain", value: nil, encoding: PublishEncodingPlain, want: ``},
+	}
+
+	manager := NewManager(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := manager.encodePayload(tt.value, tt.encoding)
+			if err != nil {
+				t.Fatalf("encodePayload() failed: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("encodePayload() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInternalTopicPublishEncoding(t *testing.T) {
+	manager := NewManager(nil)
+	publisher := &mockPublisher{}
+	manager.SetMQTTClient(publisher)
+	manager.SetStrategyExecutor(&mockStrategyExecutor{
+		executeFunc: func(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+			return map[string]interface{}{"state": "on", "brightness": 80.5}, nil
+		},
+	})
+
+	sensor := mustAddExternalTopic(t, manager, "sensors/motion")
+	topic, err := manager.AddInternalTopic("lights/hall", []string{"sensors/motion"}, nil, "test", nil, true, false)
+	if err != nil {
+		t.Fatalf("AddInternalTopic failed: %v", err)
+	}
+	if err := topic.SetPublishEncoding(PublishEncodingKV); err != nil {
+		t.Fatalf("SetPublishEncoding failed: %v", err)
+	}
+	if err := sensor.Emit(true); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	if got, want := string(publisher.published["lights/hall"]), `brightness=80.5,state=on`; got != want {
+		t.Errorf("published %s, want %s", got, want)
+	}
+
+	// JSON is the default and is not stored
+	if err := topic.SetPublishEncoding(""); err != nil {
+		t.Fatalf("SetPublishEncoding failed: %v", err)
+	}
+	if _, stored := topic.GetConfig().Config["publish_encoding"]; stored || topic.GetPublishEncoding() != PublishEncodingJSON {
+		t.Errorf("expected json encoding to be the unstored default")
+	}
+
+	if err := topic.SetPublishEncoding("xml"); err == nil {
+		t.Error("SetPublishEncoding accepted an invalid encoding")
+	}
+}
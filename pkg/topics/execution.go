@@ -0,0 +1,80 @@
+package topics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/strategy"
+)
+
+// LogReportingExecutor is implemented by strategy executors that also return
+// the messages a strategy logged during execution
+type LogReportingExecutor interface {
+	ExecuteStrategyWithLogs(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) ([]strategy.EmitEvent, []strategy.LogMessage, error)
+}
+
+// ExecutionRecord describes one strategy execution of an internal topic
+type ExecutionRecord struct {
+	TopicName    string
+	StrategyID   string
+	TriggerTopic string
+	Inputs       map[string]interface{}
+	Outputs      []strategy.EmitEvent
+	LogMessages  []strategy.LogMessage
+	Error        string
+	Duration     time.Duration
+	ExecutedAt   time.Time
+}
+
+// ExecutionRecorder stores strategy executions in the execution log
+type ExecutionRecorder interface {
+	RecordExecution(record ExecutionRecord) error
+}
+
+// SetExecutionRecorder sets where strategy executions are recorded
+func (m *Manager) SetExecutionRecorder(recorder ExecutionRecorder) {
+	m.executionRecorder = recorder
+}
+
+// executeStrategyWithLogs executes a strategy for a topic, also returning its
+// log messages when the executor reports them. When the executor supports
+// options, the topic name scopes the strategy's state, a non-nil
+// postProcessors replaces the executor's default pipeline, and the chain
+// deadline of ctx stops the execution.
+func (m *Manager) executeStrategyWithLogs(ctx context.Context, topicName, strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}, postProcessors []string) ([]strategy.EmitEvent, []strategy.LogMessage, error) {
+	if m.strategyExecutor == nil {
+		return nil, nil, fmt.Errorf("strategy executor not configured")
+	}
+
+	release := m.acquireExecution()
+	defer release()
+
+	// Waiting for an execution slot may have used up the rest of the chain
+	if ctx.Err() != nil {
+		return nil, nil, fmt.Errorf("strategy %s not executed: %w", strategyID, strategy.ErrDeadlineExceeded)
+	}
+
+	_, hasDeadline := ctx.Deadline()
+	if executor, ok := m.strategyExecutor.(OptionsExecutor); ok && (postProcessors != nil || hasDeadline || topicName != "") {
+		events, logs, _, err := executor.ExecuteStrategyWithOptions(strategyID, inputs, inputNames, triggerTopic, lastOutput, topicParameters, strategy.ExecuteOptions{PostProcessors: postProcessors, TopicName: topicName, Context: ctx})
+		return events, logs, err
+	}
+	if executor, ok := m.strategyExecutor.(LogReportingExecutor); ok {
+		return executor.ExecuteStrategyWithLogs(strategyID, inputs, inputNames, triggerTopic, lastOutput, topicParameters)
+	}
+	events, err := m.strategyExecutor.ExecuteStrategy(strategyID, inputs, inputNames, triggerTopic, lastOutput, topicParameters)
+	return events, nil, err
+}
+
+// recordExecution adds an execution to the execution log. Failures are logged
+// rather than returned so they never fail the execution itself.
+func (m *Manager) recordExecution(record ExecutionRecord) {
+	m.stats.recordExecution(record.Duration)
+	if m.executionRecorder == nil {
+		return
+	}
+	if err := m.executionRecorder.RecordExecution(record); err != nil {
+		m.logger.Printf("Failed to record execution of topic %s: %v", record.TopicName, err)
+	}
+}
@@ -1,14 +1,26 @@
 package topics
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"reflect"
+	"sync"
 	"time"
 )
 
 type ExternalTopic struct {
 	config  BaseTopicConfig
 	manager *Manager
+
+	// restored is set while the value restored from the database has not yet
+	// been replaced by an MQTT message
+	restored bool
+
+	// valueMutex guards the value and its update time, which dependents
+	// read while MQTT workers update them
+	valueMutex sync.RWMutex
 }
 
 func NewExternalTopic(name string) *ExternalTopic {
@@ -32,29 +44,65 @@ func (et *ExternalTopic) Type() TopicType {
 }
 
 func (et *ExternalTopic) LastValue() interface{} {
+	et.valueMutex.RLock()
+	defer et.valueMutex.RUnlock()
 	return et.config.LastValue
 }
 
 func (et *ExternalTopic) LastUpdated() time.Time {
+	et.valueMutex.RLock()
+	defer et.valueMutex.RUnlock()
 	return et.config.LastUpdated
 }
 
+// setValue stores value updated at updated, returning the previous value
+func (et *ExternalTopic) setValue(value interface{}, updated time.Time) interface{} {
+	et.valueMutex.Lock()
+	defer et.valueMutex.Unlock()
+
+	previousValue := et.config.LastValue
+	et.config.LastValue = value
+	et.config.LastUpdated = updated
+	return previousValue
+}
+
+// touch moves the update time of an unchanged value
+func (et *ExternalTopic) touch(updated time.Time) {
+	et.valueMutex.Lock()
+	defer et.valueMutex.Unlock()
+
+	et.config.LastUpdated = updated
+}
+
 func (et *ExternalTopic) SetManager(manager *Manager) {
 	et.manager = manager
 }
 
 func (et *ExternalTopic) Emit(value interface{}) error {
-	previousValue := et.config.LastValue
-	et.config.LastValue = value
-	et.config.LastUpdated = time.Now()
+	return et.emit(nil, value, false, time.Now())
+}
+
+// emit stores a value updated at updated and notifies dependents; retained is
+// set for values of retained MQTT messages, and ctx is the propagation chain
+// the update is part of, if any
+func (et *ExternalTopic) emit(ctx context.Context, value interface{}, retained bool, updated time.Time) error {
+	value, ok := validateTopicValue(et.manager, et.config.Name, et.config.Config, value, et.LastValue())
+	if !ok {
+		return nil
+	}
+
+	et.restored = false
+	previousValue := et.setValue(value, updated)
 
 	if et.manager != nil {
 		event := TopicEvent{
 			TopicName:     et.config.Name,
 			Value:         value,
 			PreviousValue: previousValue,
-			Timestamp:     et.config.LastUpdated,
+			Timestamp:     updated,
 			TriggerTopic:  et.config.Name,
+			Retained:      retained,
+			ctx:           ctx,
 		}
 
 		if err := et.manager.NotifyTopicUpdate(event); err != nil {
@@ -70,15 +118,91 @@ func (et *ExternalTopic) Emit(value interface{}) error {
 	return nil
 }
 
-func (et *ExternalTopic) UpdateFromMQTT(payload []byte) error {
-	// Try to parse as JSON first, fall back to string
+// UpdateFromMQTT stores a payload received from MQTT; retained is set for
+// retained messages the broker replays on subscribe
+func (et *ExternalTopic) UpdateFromMQTT(payload []byte, retained bool) error {
 	var value interface{}
-	if err := json.Unmarshal(payload, &value); err != nil {
-		// If JSON parsing fails, treat as string
+	if et.IsBinary() {
+		// Binary payloads are kept as base64 so they survive JSON encoding
+		value = base64.StdEncoding.EncodeToString(payload)
+	} else if err := json.Unmarshal(payload, &value); err != nil {
+		// Try to parse as JSON first, fall back to string
 		value = string(payload)
 	}
+	// Read before ingress strategies, which may drop the timestamp
+	updated := et.updateTime(value, time.Now())
 
-	return et.Emit(value)
+	value, keep, err := et.applyIngress(value)
+	if err != nil {
+		return err
+	}
+	if !keep {
+		return nil
+	}
+
+	// After a restart the first message is usually the broker's retained
+	// replay of the value that was just restored
+	restored := et.restored
+	et.restored = false
+	if restored && et.manager != nil && et.manager.DedupeAcrossRestart() && reflect.DeepEqual(value, et.LastValue()) {
+		et.touch(updated)
+		return nil
+	}
+
+	// Skip notifying dependents when the payload repeats the current value
+	if et.IsDedupeIncoming() && !et.LastUpdated().IsZero() && reflect.DeepEqual(value, et.LastValue()) {
+		et.touch(updated)
+		return nil
+	}
+
+	return et.emit(nil, value, retained, updated)
+}
+
+// IsDedupeIncoming reports whether identical consecutive MQTT payloads are ignored
+func (et *ExternalTopic) IsDedupeIncoming() bool {
+	dedupe, _ := et.config.Config["dedupe_incoming"].(bool)
+	return dedupe
+}
+
+// SetDedupeIncoming controls whether identical consecutive MQTT payloads notify dependents.
+func (et *ExternalTopic) SetDedupeIncoming(dedupe bool) {
+	if et.config.Config == nil {
+		et.config.Config = make(map[string]interface{})
+	}
+	et.config.Config["dedupe_incoming"] = dedupe
+}
+
+// IsBinary reports whether MQTT payloads are treated as raw bytes. The value
+// of a binary topic is the base64 encoding of the last payload.
+func (et *ExternalTopic) IsBinary() bool {
+	binary, _ := et.config.Config["binary"].(bool)
+	return binary
+}
+
+// SetBinary controls whether MQTT payloads are treated as raw bytes.
+func (et *ExternalTopic) SetBinary(binary bool) {
+	if et.config.Config == nil {
+		et.config.Config = make(map[string]interface{})
+	}
+	if binary {
+		et.config.Config["binary"] = true
+	} else {
+		delete(et.config.Config, "binary")
+	}
+}
+
+// GetTTL returns how long the topic may go without an update before it is
+// reported as stale (0 means never)
+func (et *ExternalTopic) GetTTL() time.Duration {
+	return configTTL(et.config.Config)
+}
+
+// SetTTL sets the topic's staleness TTL.
+func (et *ExternalTopic) SetTTL(ttl time.Duration) {
+	if et.config.Config == nil {
+		et.config.Config = make(map[string]interface{})
+	}
+	setConfigTTL(et.config.Config, ttl)
 }
 
 func (et *ExternalTopic) GetConfig() BaseTopicConfig {
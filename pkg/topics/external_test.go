@@ -0,0 +1,279 @@
+package topics
+
+import (
+	"bytes"
+	"encoding/base64"
+	"reflect"
+	"testing"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/mqtt"
+)
+
+func TestExternalTopicDedupeIncoming(t *testing.T) {
+	tests := []struct {
+		name      string
+		dedupe    bool
+		payloads  []string
+		wantCalls int
+	}{
+		{
+			name:      "default notifies on every message",
+			dedupe:    false,
+			payloads:  []string{"21.5", "21.5", "22"},
+			wantCalls: 3,
+		},
+		{
+			name:      "dedupe notifies only on change",
+			dedupe:    true,
+			payloads:  []string{"21.5", "21.5", "22"},
+			wantCalls: 2,
+		},
+		{
+			name:      "dedupe compares structured payloads",
+			dedupe:    true,
+			payloads:  []string{`{"on":true}`, `{"on":true}`, `{"on":false}`, `{"on":false}`},
+			wantCalls: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewManager(nil)
+
+			executions := 0
+			manager.SetStrategyExecutor(&mockStrategyExecutor{
+				executeFunc: func(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+					executions++
+					return inputs["sensors/temp"], nil
+				},
+			})
+
+			sensor := mustAddExternalTopic(t, manager, "sensors/temp")
+			sensor.SetDedupeIncoming(tt.dedupe)
+
+			if _, err := manager.AddInternalTopic("processed/temp", []string{"sensors/temp"}, nil, "test-strategy", nil, false, false); err != nil {
+				t.Fatalf("Failed to add internal topic: %v", err)
+			}
+
+			for _, payload := range tt.payloads {
+				if err := sensor.UpdateFromMQTT([]byte(payload), false); err != nil {
+					t.Fatalf("UpdateFromMQTT(%q) failed: %v", payload, err)
+				}
+			}
+
+			if executions != tt.wantCalls {
+				t.Errorf("executions = %d, want %d", executions, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestExternalTopicDedupeAcrossRestart(t *testing.T) {
+	tests := []struct {
+		name      string
+		dedupe    bool
+		restored  interface{}
+		payloads  []string
+		wantCalls int
+	}{
+		{
+			name:      "disabled triggers on retained replay",
+			restored:  21.5,
+			payloads:  []string{"21.5"},
+			wantCalls: 1,
+		},
+		{
+			name:      "retained replay of restored value is ignored",
+			dedupe:    true,
+			restored:  21.5,
+			payloads:  []string{"21.5"},
+			wantCalls: 0,
+		},
+		{
+			name:      "only the first message is compared",
+			dedupe:    true,
+			restored:  21.5,
+			payloads:  []string{"21.5", "21.5"},
+			wantCalls: 1,
+		},
+		{
+			name:      "changed value triggers",
+			dedupe:    true,
+			restored:  21.5,
+			payloads:  []string{"22"},
+			wantCalls: 1,
+		},
+		{
+			name:      "structured values are compared",
+			dedupe:    true,
+			restored:  map[string]interface{}{"on": true},
+			payloads:  []string{`{"on":true}`, `{"on":false}`},
+			wantCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewManager(nil)
+			manager.SetDedupeAcrossRestart(tt.dedupe)
+			manager.SetStateManager(&mockStateManager{
+				restoreStatesFunc: func() (map[string]interface{}, error) {
+					return map[string]interface{}{"external:sensors/temp": tt.restored}, nil
+				},
+			})
+
+			executions := 0
+			manager.SetStrategyExecutor(&mockStrategyExecutor{
+				executeFunc: func(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+					executions++
+					return inputs["sensors/temp"], nil
+				},
+			})
+
+			// Startup order: load topics, seed restored values, then connect
+			if _, err := manager.AddInternalTopic("processed/temp", []string{"sensors/temp"}, nil, "test-strategy", nil, false, false); err != nil {
+				t.Fatalf("Failed to add internal topic: %v", err)
+			}
+			if err := manager.RestoreTopicStatesFromDatabase(); err != nil {
+				t.Fatalf("RestoreTopicStatesFromDatabase failed: %v", err)
+			}
+
+			for _, payload := range tt.payloads {
+				if err := manager.HandleMQTTMessage(mqtt.Event{Topic: "sensors/temp", Payload: []byte(payload)}); err != nil {
+					t.Fatalf("HandleMQTTMessage(%q) failed: %v", payload, err)
+				}
+			}
+
+			if executions != tt.wantCalls {
+				t.Errorf("executions = %d, want %d", executions, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestExternalTopicDedupeIncomingFlag(t *testing.T) {
+	topic := NewExternalTopic("sensors/temp")
+
+	if topic.IsDedupeIncoming() {
+		t.Error("dedupe should be disabled by default")
+	}
+
+	topic.SetDedupeIncoming(true)
+	if !topic.IsDedupeIncoming() {
+		t.Error("dedupe should be enabled after SetDedupeIncoming(true)")
+	}
+
+	if topic.GetConfig().Config["dedupe_incoming"] != true {
+		t.Error("dedupe flag should be stored in the topic config")
+	}
+}
+
+func TestExternalTopicBinaryPayload(t *testing.T) {
+	// Invalid UTF-8 and a NUL byte would be mangled by a string conversion
+	payload := []byte{0xff, 0x00, 0xfe, 'J', 'P', 'G', 0x80}
+
+	manager := NewManager(nil)
+	var savedValue interface{}
+	manager.SetStateManager(&mockStateManager{
+		saveFunc: func(topicName string, value interface{}) error {
+			savedValue = value
+			return nil
+		},
+	})
+	var strategyInput interface{}
+	manager.SetStrategyExecutor(&mockStrategyExecutor{
+		executeFunc: func(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+			strategyInput = inputs["cameras/front/snapshot"]
+			return nil, nil
+		},
+	})
+	manager.SetBinaryTopicPatterns([]string{"cameras/+/snapshot"})
+	if _, err := manager.AddInternalTopic("cameras/front/latest", []string{"cameras/front/snapshot"}, nil, "test-strategy", nil, false, false); err != nil {
+		t.Fatalf("Failed to add internal topic: %v", err)
+	}
+
+	if err := manager.HandleMQTTMessage(mqtt.Event{Topic: "cameras/front/snapshot", Payload: payload}); err != nil {
+		t.Fatalf("HandleMQTTMessage failed: %v", err)
+	}
+
+	topic := manager.GetExternalTopic("cameras/front/snapshot")
+	if topic == nil || !topic.IsBinary() {
+		t.Fatal("topic matching a binary pattern should be created in binary mode")
+	}
+
+	for name, value := range map[string]interface{}{"last value": topic.LastValue(), "saved state": savedValue, "strategy input": strategyInput} {
+		encoded, ok := value.(string)
+		if !ok {
+			t.Errorf("%s = %T, want a base64 string", name, value)
+			continue
+		}
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			t.Errorf("%s is not valid base64: %v", name, err)
+			continue
+		}
+		if !bytes.Equal(decoded, payload) {
+			t.Errorf("%s decodes to %v, want %v", name, decoded, payload)
+		}
+	}
+
+	// Topics not matching a binary pattern keep parsing JSON
+	if err := manager.HandleMQTTMessage(mqtt.Event{Topic: "sensors/temp", Payload: []byte("21.5")}); err != nil {
+		t.Fatalf("HandleMQTTMessage failed: %v", err)
+	}
+	if value := manager.GetTopic("sensors/temp").LastValue(); value != 21.5 {
+		t.Errorf("non-binary value = %v, want 21.5", value)
+	}
+
+	// Binary mode can be turned off per topic
+	topic.SetBinary(false)
+	if topic.IsBinary() {
+		t.Error("IsBinary() should be false after SetBinary(false)")
+	}
+}
+
+func TestInternalTopicIgnoreRetainedTrigger(t *testing.T) {
+	tests := []struct {
+		name     string
+		ignore   bool
+		wantSeen []interface{}
+	}{
+		{name: "default triggers on retained replays", wantSeen: []interface{}{1.5, 2.5}},
+		{name: "ignore retained triggers only on live messages", ignore: true, wantSeen: []interface{}{2.5}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewManager(nil)
+
+			var seen []interface{}
+			manager.SetStrategyExecutor(&mockStrategyExecutor{
+				executeFunc: func(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+					seen = append(seen, inputs["alarms/door"])
+					return inputs["alarms/door"], nil
+				},
+			})
+
+			alert, err := manager.AddInternalTopic("alerts/door", []string{"alarms/door"}, nil, "test-strategy", nil, false, false)
+			if err != nil {
+				t.Fatalf("Failed to add internal topic: %v", err)
+			}
+			alert.SetIgnoreRetainedTrigger(tt.ignore)
+
+			if err := manager.HandleMQTTMessage(mqtt.Event{Topic: "alarms/door", Payload: []byte("1.5"), Retained: true}); err != nil {
+				t.Fatalf("HandleMQTTMessage failed: %v", err)
+			}
+			// The retained value is stored either way
+			if got := manager.GetExternalTopic("alarms/door").LastValue(); got != 1.5 {
+				t.Errorf("stored value = %v, want 1.5", got)
+			}
+			if err := manager.HandleMQTTMessage(mqtt.Event{Topic: "alarms/door", Payload: []byte("2.5")}); err != nil {
+				t.Fatalf("HandleMQTTMessage failed: %v", err)
+			}
+
+			if !reflect.DeepEqual(seen, tt.wantSeen) {
+				t.Errorf("strategy executed with %v, want %v", seen, tt.wantSeen)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package topics
+
+import (
+	"sort"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/mqtt"
+)
+
+// GraphNode is a topic in the dependency graph. Inputs that match no known
+// topic are included with an empty Type.
+type GraphNode struct {
+	Name string    `json:"name"`
+	Type TopicType `json:"type"`
+}
+
+// GraphEdge links an input topic to an internal topic that depends on it.
+// Label is the input's name, when one is configured.
+type GraphEdge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Label string `json:"label,omitempty"`
+}
+
+// DependencyGraph is the graph of topics and the inputs of internal topics
+type DependencyGraph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// BuildDependencyGraph returns every topic and an edge for each internal
+// topic input, sorted by name. Wildcard inputs have an edge from every topic
+// they match.
+func (m *Manager) BuildDependencyGraph() DependencyGraph {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	nodes := make(map[string]TopicType, len(m.topics))
+	for name, topic := range m.topics {
+		nodes[name] = topic.Type()
+	}
+
+	var edges []GraphEdge
+	for name, internalTopic := range m.internalTopics {
+		inputNames := internalTopic.GetInputNames()
+		for _, input := range internalTopic.GetInputs() {
+			matched := false
+			for candidate := range m.topics {
+				if candidate == input || mqtt.TopicMatches(input, candidate) {
+					edges = append(edges, GraphEdge{From: candidate, To: name, Label: inputNames[input]})
+					matched = true
+				}
+			}
+			if !matched {
+				if _, exists := nodes[input]; !exists {
+					nodes[input] = ""
+				}
+				edges = append(edges, GraphEdge{From: input, To: name, Label: inputNames[input]})
+			}
+		}
+	}
+
+	graph := DependencyGraph{
+		Nodes: make([]GraphNode, 0, len(nodes)),
+		Edges: edges,
+	}
+	for name, topicType := range nodes {
+		graph.Nodes = append(graph.Nodes, GraphNode{Name: name, Type: topicType})
+	}
+	sort.Slice(graph.Nodes, func(i, j int) bool { return graph.Nodes[i].Name < graph.Nodes[j].Name })
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].To != graph.Edges[j].To {
+			return graph.Edges[i].To < graph.Edges[j].To
+		}
+		return graph.Edges[i].From < graph.Edges[j].From
+	})
+	if graph.Edges == nil {
+		graph.Edges = []GraphEdge{}
+	}
+	return graph
+}
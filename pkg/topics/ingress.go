@@ -0,0 +1,106 @@
+package topics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/mqtt"
+)
+
+// IngressStrategy transforms the MQTT payloads of topics matching Pattern
+// with a strategy before they are stored on the external topic
+type IngressStrategy struct {
+	Pattern    string
+	StrategyID string
+}
+
+// SetIngressStrategies sets the ingress strategies of external topics. The
+// first rule whose pattern matches a topic applies.
+func (m *Manager) SetIngressStrategies(rules []IngressStrategy) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.ingressRules = rules
+}
+
+// ingressStrategyFor returns the ingress strategy configured for a topic
+// name, or "" when none matches
+func (m *Manager) ingressStrategyFor(name string) string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	for _, rule := range m.ingressRules {
+		if mqtt.TopicMatches(rule.Pattern, name) {
+			return rule.StrategyID
+		}
+	}
+	return ""
+}
+
+// GetIngressStrategy returns the strategy that transforms the topic's MQTT
+// payloads: the topic's own, else the first matching configured rule
+func (et *ExternalTopic) GetIngressStrategy() string {
+	if strategyID, _ := et.config.Config["ingress_strategy"].(string); strategyID != "" {
+		return strategyID
+	}
+	if et.manager != nil {
+		return et.manager.ingressStrategyFor(et.config.Name)
+	}
+	return ""
+}
+
+// SetIngressStrategy sets (or clears, when empty) the topic's ingress
+// strategy.
+func (et *ExternalTopic) SetIngressStrategy(strategyID string) {
+	if et.config.Config == nil {
+		et.config.Config = make(map[string]interface{})
+	}
+	if strategyID == "" {
+		delete(et.config.Config, "ingress_strategy")
+		return
+	}
+	et.config.Config["ingress_strategy"] = strategyID
+}
+
+// applyIngress runs the topic's ingress strategy on a parsed payload. The
+// strategy receives the payload as the topic's input and its main output
+// becomes the stored value; when it emits nothing the message is dropped,
+// reported by keep being false.
+func (et *ExternalTopic) applyIngress(value interface{}) (result interface{}, keep bool, err error) {
+	strategyID := et.GetIngressStrategy()
+	if strategyID == "" || et.manager == nil {
+		return value, true, nil
+	}
+
+	inputs := map[string]interface{}{et.config.Name: value}
+	start := time.Now()
+	events, logs, err := et.manager.executeStrategyWithLogs(context.Background(), et.config.Name, strategyID, inputs, nil, et.config.Name, et.config.LastValue, nil, nil)
+
+	record := ExecutionRecord{
+		TopicName:    et.config.Name,
+		StrategyID:   strategyID,
+		TriggerTopic: et.config.Name,
+		Inputs:       inputs,
+		Outputs:      events,
+		LogMessages:  logs,
+		Duration:     time.Since(start),
+		ExecutedAt:   start,
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	et.manager.recordExecution(record)
+
+	if err != nil {
+		return nil, false, fmt.Errorf("ingress strategy %s failed for topic %s: %w", strategyID, et.config.Name, err)
+	}
+
+	// Last value wins, as for internal topics; only the main output is used
+	for _, event := range events {
+		if event.Topic == "" {
+			result, keep = event.Value, true
+		}
+	}
+	return result, keep, nil
+}
@@ -0,0 +1,67 @@
+package topics
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/mqtt"
+	"github.com/denwilliams/go-mqtt-automation/pkg/strategy"
+)
+
+func TestExternalTopicIngressStrategy(t *testing.T) {
+	manager := NewManager(nil)
+	engine := strategy.NewEngine(nil)
+	code := `function process(context) {
+		var raw = context.triggeringValue;
+		if (raw === "ping") return;
+		if (raw === "bad") throw new Error("unreadable payload");
+		var parts = raw.split(";");
+		return { temperature: parseFloat(parts[0]), humidity: parseFloat(parts[1]) };
+	}`
+	if err := engine.AddStrategy(&strategy.Strategy{ID: "decode-vendor", Name: "Decode vendor", Code: code, Language: "javascript"}); err != nil {
+		t.Fatalf("Failed to add strategy: %v", err)
+	}
+	manager.SetStrategyExecutor(engine)
+	manager.SetIngressStrategies([]IngressStrategy{{Pattern: "vendor/+/raw", StrategyID: "decode-vendor"}})
+
+	if err := manager.HandleMQTTMessage(mqtt.Event{Topic: "vendor/kitchen/raw", Payload: []byte("21.5;45.5")}); err != nil {
+		t.Fatalf("HandleMQTTMessage failed: %v", err)
+	}
+	topic := manager.GetExternalTopic("vendor/kitchen/raw")
+	want := map[string]interface{}{"temperature": 21.5, "humidity": 45.5}
+	if !reflect.DeepEqual(topic.LastValue(), want) {
+		t.Fatalf("stored value = %#v, want %#v", topic.LastValue(), want)
+	}
+	if got := topic.GetIngressStrategy(); got != "decode-vendor" {
+		t.Errorf("GetIngressStrategy() = %q, want decode-vendor", got)
+	}
+
+	// Emitting nothing drops the message; a failing strategy stores nothing
+	if err := manager.HandleMQTTMessage(mqtt.Event{Topic: "vendor/kitchen/raw", Payload: []byte("ping")}); err != nil {
+		t.Fatalf("HandleMQTTMessage failed: %v", err)
+	}
+	if err := manager.HandleMQTTMessage(mqtt.Event{Topic: "vendor/kitchen/raw", Payload: []byte("bad")}); err == nil {
+		t.Error("expected an error from the failing ingress strategy")
+	}
+	if !reflect.DeepEqual(topic.LastValue(), want) {
+		t.Errorf("stored value changed to %#v", topic.LastValue())
+	}
+
+	// Topics that match no rule are stored as parsed
+	if err := manager.HandleMQTTMessage(mqtt.Event{Topic: "sensors/kitchen/temp", Payload: []byte("21.5")}); err != nil {
+		t.Fatalf("HandleMQTTMessage failed: %v", err)
+	}
+	if got := manager.GetExternalTopic("sensors/kitchen/temp").LastValue(); got != 21.5 {
+		t.Errorf("unmatched topic value = %#v, want 21.5", got)
+	}
+
+	// A topic's own ingress strategy overrides the rules
+	override := mustAddExternalTopic(t, manager, "sensors/hall/raw")
+	override.SetIngressStrategy("decode-vendor")
+	if err := override.UpdateFromMQTT([]byte("19.5;50.5"), false); err != nil {
+		t.Fatalf("UpdateFromMQTT failed: %v", err)
+	}
+	if got := override.LastValue(); !reflect.DeepEqual(got, map[string]interface{}{"temperature": 19.5, "humidity": 50.5}) {
+		t.Errorf("override value = %#v", got)
+	}
+}
@@ -0,0 +1,41 @@
+package topics
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ValidateInputNames checks that input names (input topic -> friendly name)
+// identify a single input each. Strategy inputs are keyed by name, or by
+// topic for unnamed inputs, so a name shared by two inputs or equal to
+// another unnamed input's topic would hide one of the values.
+func ValidateInputNames(inputs []string, inputNames map[string]string) error {
+	// Sort for deterministic error messages
+	namedTopics := make([]string, 0, len(inputNames))
+	for inputTopic := range inputNames {
+		namedTopics = append(namedTopics, inputTopic)
+	}
+	sort.Strings(namedTopics)
+
+	named := make(map[string]string, len(inputNames)) // name -> input topic
+	for _, inputTopic := range namedTopics {
+		name := inputNames[inputTopic]
+		if name == "" {
+			return fmt.Errorf("input %s has an empty name", inputTopic)
+		}
+		if other, exists := named[name]; exists {
+			return fmt.Errorf("input name %q is used by both %s and %s", name, other, inputTopic)
+		}
+		named[name] = inputTopic
+	}
+
+	for _, inputTopic := range inputs {
+		if _, hasName := inputNames[inputTopic]; hasName {
+			continue
+		}
+		if other, exists := named[inputTopic]; exists {
+			return fmt.Errorf("input name %q of %s collides with the unnamed input %s", inputTopic, other, inputTopic)
+		}
+	}
+	return nil
+}
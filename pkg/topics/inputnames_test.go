@@ -0,0 +1,42 @@
+package topics
+
+import (
+	"testing"
+)
+
+func TestValidateInputNames(t *testing.T) {
+	inputs := []string{"sensors/living/temp", "sensors/kitchen/temp", "sensors/outside/temp"}
+
+	tests := []struct {
+		name       string
+		inputNames map[string]string
+		wantErr    bool
+	}{
+		{name: "no names"},
+		{name: "unique names", inputNames: map[string]string{"sensors/living/temp": "living_room", "sensors/kitchen/temp": "kitchen"}},
+		{name: "duplicate name", inputNames: map[string]string{"sensors/living/temp": "temp", "sensors/kitchen/temp": "temp"}, wantErr: true},
+		{name: "name is another input's topic", inputNames: map[string]string{"sensors/living/temp": "sensors/outside/temp"}, wantErr: true},
+		{name: "name is a renamed input's topic", inputNames: map[string]string{"sensors/living/temp": "sensors/kitchen/temp", "sensors/kitchen/temp": "kitchen"}},
+		{name: "empty name", inputNames: map[string]string{"sensors/living/temp": ""}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateInputNames(inputs, tt.inputNames)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateInputNames() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAddInternalTopicRejectsInputNameCollisions(t *testing.T) {
+	manager := NewManager(nil)
+	inputNames := map[string]string{"sensors/a": "temp", "sensors/b": "temp"}
+	if _, err := manager.AddInternalTopic("house/avg", []string{"sensors/a", "sensors/b"}, inputNames, "test", nil, false, false); err == nil {
+		t.Fatal("AddInternalTopic accepted colliding input names")
+	}
+	if manager.GetTopic("house/avg") != nil {
+		t.Error("topic with colliding input names was added")
+	}
+}
@@ -3,8 +3,10 @@ package topics
 import (
 	"fmt"
 	"log"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 // Test data flow through a chain of dependent topics
@@ -64,7 +66,7 @@ func TestChainedTopicDataFlow(t *testing.T) {
 
 	// Set up chain: sensor -> double -> add10 -> format
 	// 1. External topic (sensor input)
-	sensorTopic := manager.AddExternalTopic("sensor/temperature")
+	sensorTopic := mustAddExternalTopic(t, manager, "sensor/temperature")
 
 	// 2. First internal topic (doubles the sensor value)
 	doubledTopic, err := manager.AddInternalTopic("processed/doubled", []string{"sensor/temperature"}, nil, "multiply-by-2", nil, false, false)
@@ -195,7 +197,7 @@ func TestBranchingTopicChains(t *testing.T) {
 	manager.SetStrategyExecutor(mockExec)
 
 	// Set up branching chains from single sensor
-	sensorTopic := manager.AddExternalTopic("sensor/room-temp")
+	sensorTopic := mustAddExternalTopic(t, manager, "sensor/room-temp")
 
 	// Branch 1: Temperature conversion
 	fahrenheitTopic, err := manager.AddInternalTopic("converted/fahrenheit", []string{"sensor/room-temp"}, nil, "celsius-to-fahrenheit", nil, false, false)
@@ -330,9 +332,9 @@ func TestConvergentTopicChains(t *testing.T) {
 	manager.SetStrategyExecutor(mockExec)
 
 	// Create multiple sensor inputs
-	sensor1 := manager.AddExternalTopic("sensors/living-room/temp")
-	sensor2 := manager.AddExternalTopic("sensors/kitchen/temp")
-	sensor3 := manager.AddExternalTopic("sensors/bedroom/temp")
+	sensor1 := mustAddExternalTopic(t, manager, "sensors/living-room/temp")
+	sensor2 := mustAddExternalTopic(t, manager, "sensors/kitchen/temp")
+	sensor3 := mustAddExternalTopic(t, manager, "sensors/bedroom/temp")
 
 	// Convergent topic that averages all temperatures
 	avgTopic, err := manager.AddInternalTopic("calculated/average-temp",
@@ -418,6 +420,90 @@ func TestConvergentTopicChains(t *testing.T) {
 	}
 }
 
+// Test that a coalescing window turns a burst of input changes into a single
+// execution over all of them
+func TestConvergentTopicCoalescing(t *testing.T) {
+	manager := NewManager(log.New(log.Writer(), "TEST-COALESCE: ", log.LstdFlags))
+	clock := newFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	manager.SetClock(clock)
+
+	executions := make(chan map[string]interface{}, 10)
+	manager.SetStrategyExecutor(&mockStrategyExecutor{
+		executeFunc: func(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+			executions <- inputs
+			return nil, nil
+		},
+	})
+
+	sensorNames := []string{"sensors/living-room/temp", "sensors/kitchen/temp", "sensors/bedroom/temp"}
+	sensors := make([]*ExternalTopic, len(sensorNames))
+	for i, name := range sensorNames {
+		sensors[i] = mustAddExternalTopic(t, manager, name)
+	}
+
+	avgTopic, err := manager.AddInternalTopic("calculated/average-temp", sensorNames, nil, "average-temperature", nil, false, false)
+	if err != nil {
+		t.Fatalf("Failed to add average topic: %v", err)
+	}
+	avgTopic.SetCoalesceWindow(100 * time.Millisecond)
+
+	if _, err := ParseCoalesceWindow("-1s"); err == nil {
+		t.Error("ParseCoalesceWindow should reject a negative window")
+	}
+
+	// All three sensors update within the window
+	for i, value := range []float64{20.0, 22.0, 19.0} {
+		if err := sensors[i].Emit(value); err != nil {
+			t.Fatalf("Failed to emit sensor value: %v", err)
+		}
+	}
+
+	select {
+	case inputs := <-executions:
+		t.Fatalf("executed before the window closed with %v", inputs)
+	default:
+	}
+
+	clock.WaitForWaiter(t)
+	clock.Advance(100 * time.Millisecond)
+
+	select {
+	case inputs := <-executions:
+		want := map[string]interface{}{
+			"sensors/living-room/temp": 20.0,
+			"sensors/kitchen/temp":     22.0,
+			"sensors/bedroom/temp":     19.0,
+		}
+		if !reflect.DeepEqual(inputs, want) {
+			t.Errorf("coalesced inputs = %v, want %v", inputs, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("coalesced execution did not run")
+	}
+
+	select {
+	case inputs := <-executions:
+		t.Errorf("unexpected second execution with %v", inputs)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// A later change opens a new window
+	if err := sensors[0].Emit(21.0); err != nil {
+		t.Fatalf("Failed to emit sensor value: %v", err)
+	}
+	clock.WaitForWaiter(t)
+	clock.Advance(100 * time.Millisecond)
+
+	select {
+	case inputs := <-executions:
+		if inputs["sensors/living-room/temp"] != 21.0 {
+			t.Errorf("living-room input = %v, want 21", inputs["sensors/living-room/temp"])
+		}
+	case <-time.After(time.Second):
+		t.Fatal("second coalesced execution did not run")
+	}
+}
+
 // Test complex multi-level chain with error handling
 func TestComplexChainWithErrors(t *testing.T) {
 	manager := NewManager(log.New(log.Writer(), "TEST-ERROR: ", log.LstdFlags))
@@ -464,7 +550,7 @@ func TestComplexChainWithErrors(t *testing.T) {
 	manager.SetStrategyExecutor(mockExec)
 
 	// Set up validation chain
-	sensorTopic := manager.AddExternalTopic("sensor/raw-temp")
+	sensorTopic := mustAddExternalTopic(t, manager, "sensor/raw-temp")
 
 	validatedTopic, err := manager.AddInternalTopic("validated/temp", []string{"sensor/raw-temp"}, nil, "validate-sensor", nil, false, false)
 	if err != nil {
@@ -1,10 +1,12 @@
 package topics
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/denwilliams/go-mqtt-automation/pkg/metrics"
@@ -13,8 +15,61 @@ import (
 )
 
 type InternalTopic struct {
-	config  InternalTopicConfig
-	manager *Manager
+	config    InternalTopicConfig
+	manager   *Manager
+	schedule  *scheduleRunner
+	republish *scheduleRunner
+
+	// lastMQTTTopic is where the current value was last published, used by
+	// periodic republishing
+	lastMQTTTopic string
+	mqttMutex     sync.Mutex
+
+	// groupFresh maps each input to the topic that delivered a value since the
+	// last execution of a group topic
+	groupFresh map[string]string
+	groupMutex sync.Mutex
+
+	// coalesceTriggers are the inputs received while a coalescing window is
+	// open; closing coalesceStop cancels the pending execution
+	coalesceTriggers []string
+	coalesceStop     chan struct{}
+	coalesceMutex    sync.Mutex
+
+	// lastError is the error from the most recent execution, if it failed
+	lastError  string
+	errorMutex sync.RWMutex
+
+	// recentValues are the latest emitted values kept for the topic snapshot
+	recentValues  []SnapshotValue
+	snapshotMutex sync.Mutex
+
+	// ambiguousTriggers are the trigger topics already reported as matching
+	// several wildcard inputs
+	ambiguousTriggers map[string]bool
+	ambiguousMutex    sync.Mutex
+
+	// rateSample is the previous input of a rate transform
+	rateSample *rateSample
+	rateMutex  sync.Mutex
+
+	// parametersMutex guards replacing the parameter overrides; the map
+	// itself is never modified once set
+	parametersMutex sync.RWMutex
+
+	// valueMutex guards the value and its update time, which other topics
+	// read while the topic executes
+	valueMutex sync.RWMutex
+
+	// processMutex runs the topic's executions one at a time, as inputs
+	// arrive concurrently from the MQTT workers, schedules and system topics
+	processMutex sync.Mutex
+}
+
+// processingKey marks a propagation chain in which the topic is executing, so
+// a dependency cycle back to it does not wait on its own processMutex
+type processingKey struct {
+	topic *InternalTopic
 }
 
 func NewInternalTopic(name string, inputs []string, strategyID string) *InternalTopic {
@@ -45,13 +100,28 @@ func (it *InternalTopic) Type() TopicType {
 }
 
 func (it *InternalTopic) LastValue() interface{} {
+	it.valueMutex.RLock()
+	defer it.valueMutex.RUnlock()
 	return it.config.LastValue
 }
 
 func (it *InternalTopic) LastUpdated() time.Time {
+	it.valueMutex.RLock()
+	defer it.valueMutex.RUnlock()
 	return it.config.LastUpdated
 }
 
+// setValue stores value updated at updated, returning the previous value
+func (it *InternalTopic) setValue(value interface{}, updated time.Time) interface{} {
+	it.valueMutex.Lock()
+	defer it.valueMutex.Unlock()
+
+	previousValue := it.config.LastValue
+	it.config.LastValue = value
+	it.config.LastUpdated = updated
+	return previousValue
+}
+
 func (it *InternalTopic) SetManager(manager *Manager) {
 	it.manager = manager
 }
@@ -73,28 +143,41 @@ func (it *InternalTopic) IsNoOpUnchanged() bool {
 }
 
 func (it *InternalTopic) Emit(value interface{}) error {
-	previousValue := it.config.LastValue
+	return it.emit(context.Background(), value, "")
+}
+
+// emit sets the topic value. ctx is the propagation chain the value is part
+// of. triggerTopic is the input that caused the emission, used to expand the
+// MQTT topic template.
+func (it *InternalTopic) emit(ctx context.Context, value interface{}, triggerTopic string) error {
+	previousValue := it.LastValue()
+
+	value, ok := validateTopicValue(it.manager, it.config.Name, it.config.Config, value, previousValue)
+	if !ok {
+		return nil
+	}
 
 	// Check if we should skip unchanged values
 	if it.config.NoOpUnchanged && it.valuesEqual(value, previousValue) {
 		return nil // Skip emission
 	}
 
-	it.config.LastValue = value
-	it.config.LastUpdated = time.Now()
+	updated := time.Now()
+	it.setValue(value, updated)
 
 	if it.manager != nil {
 		event := TopicEvent{
 			TopicName:     it.config.Name,
 			Value:         value,
 			PreviousValue: previousValue,
-			Timestamp:     it.config.LastUpdated,
+			Timestamp:     updated,
 			TriggerTopic:  it.config.Name,
+			ctx:           ctx,
 		}
 
 		// Emit to MQTT if configured
 		if it.config.EmitToMQTT {
-			if err := it.emitToMQTT(value); err != nil {
+			if err := it.emitToMQTT(value, triggerTopic); err != nil {
 				return fmt.Errorf("failed to emit to MQTT: %w", err)
 			}
 		}
@@ -107,26 +190,70 @@ func (it *InternalTopic) Emit(value interface{}) error {
 		if err := it.manager.SaveTopicState(it.config.Name, value); err != nil {
 			return fmt.Errorf("failed to save topic state: %w", err)
 		}
+
+		if err := it.recordSnapshot(value, updated); err != nil {
+			return fmt.Errorf("failed to save topic snapshot: %w", err)
+		}
 	}
 
 	return nil
 }
 
 func (it *InternalTopic) ProcessInputs(triggerTopic string) error {
-	startTime := time.Now()
+	return it.processInputsInChain(context.Background(), triggerTopic)
+}
 
+// processInputsInChain processes the inputs as part of the propagation chain
+// ctx. Coalesced executions run later, outside the chain.
+func (it *InternalTopic) processInputsInChain(ctx context.Context, triggerTopic string) error {
 	if it.manager == nil {
 		return fmt.Errorf("topic manager not set")
 	}
 
+	// Coalesced topics execute once after near-simultaneous input changes
+	if window := it.GetCoalesceWindow(); window > 0 && triggerTopic != ScheduledTrigger {
+		it.coalesceInput(triggerTopic, window)
+		return nil
+	}
+
+	return it.processInputs(ctx, triggerTopic)
+}
+
+func (it *InternalTopic) processInputs(ctx context.Context, triggerTopic string) error {
+	if ctx.Value(processingKey{it}) == nil {
+		it.processMutex.Lock()
+		defer it.processMutex.Unlock()
+		ctx = context.WithValue(ctx, processingKey{it}, true)
+	}
+
+	startTime := time.Now()
+
+	// Group topics wait for a fresh value from every input
+	var groupTopics map[string]string
+	if it.IsGroup() && triggerTopic != ScheduledTrigger {
+		var ready bool
+		if groupTopics, ready = it.recordGroupInput(triggerTopic); !ready {
+			return nil
+		}
+	}
+
 	// Collect input values using named inputs if available
+	triggeredInput := it.triggeredWildcardInput(triggerTopic)
 	inputValues := make(map[string]interface{})
+	inputTypes := it.GetInputTypes()
+	inputDecoders := it.GetInputDecoders()
+	var missingInputs []string
 	for _, inputTopic := range it.config.Inputs {
 		var value interface{}
 		var actualTopic string
 
-		// Check if this is a wildcard pattern that matches the trigger topic
-		if inputTopic != triggerTopic && mqtt.TopicMatches(inputTopic, triggerTopic) {
+		if freshTopic, ok := groupTopics[inputTopic]; ok {
+			// Group members use the topic that delivered the fresh value
+			if topic := it.manager.GetTopic(freshTopic); topic != nil {
+				value = topic.LastValue()
+			}
+			actualTopic = freshTopic
+		} else if inputTopic == triggeredInput {
 			// This is a wildcard match - use the triggering topic's value
 			topic := it.manager.GetTopic(triggerTopic)
 			if topic != nil {
@@ -142,9 +269,14 @@ func (it *InternalTopic) ProcessInputs(triggerTopic string) error {
 				value = topic.LastValue()
 			} else {
 				value = nil
+				if !strings.ContainsAny(inputTopic, "+#") {
+					missingInputs = append(missingInputs, inputTopic)
+				}
 			}
 			actualTopic = inputTopic
 		}
+		value = it.decodeInputValue(inputDecoders, inputTopic, value)
+		value = it.coerceInputValue(inputTypes, inputTopic, value)
 
 		// Use named input if available, otherwise use actual topic path
 		if it.config.InputNames != nil {
@@ -158,15 +290,62 @@ func (it *InternalTopic) ProcessInputs(triggerTopic string) error {
 		}
 	}
 
-	// Execute strategy with topic parameters
-	emittedEvents, err := it.manager.ExecuteStrategy(it.config.StrategyID, inputValues, it.config.InputNames, triggerTopic, it.config.LastValue, it.config.Parameters)
+	if len(missingInputs) > 0 {
+		switch it.GetMissingInputPolicy() {
+		case MissingInputSkip:
+			return nil
+		case MissingInputError:
+			err := fmt.Errorf("input topics do not exist: %s", strings.Join(missingInputs, ", "))
+			metrics.RecordTopicProcessingError(it.config.StrategyID, "missing_input")
+			it.setLastError(err)
+			return err
+		}
+	}
+
+	// Execute the built-in transform or logic, or the strategy with topic
+	// parameters
+	var emittedEvents []strategy.EmitEvent
+	var logMessages []strategy.LogMessage
+	var err error
+	if transform := it.GetTransform(); transform != nil {
+		emittedEvents, err = it.applyTransform(transform, inputValues)
+	} else if logic := it.GetLogic(); logic != nil {
+		emittedEvents, err = it.applyLogic(logic, inputValues)
+	} else {
+		emittedEvents, logMessages, err = it.manager.executeStrategyWithLogs(ctx, it.config.Name, it.config.StrategyID, inputValues, it.config.InputNames, triggerTopic, it.LastValue(), it.GetParameters(), it.GetPostProcessors())
+	}
+	if errors.Is(err, strategy.ErrCircuitOpen) {
+		// The engine already reported the open circuit; skip quietly
+		return nil
+	}
+
+	record := ExecutionRecord{
+		TopicName:    it.config.Name,
+		StrategyID:   it.config.StrategyID,
+		TriggerTopic: triggerTopic,
+		Inputs:       inputValues,
+		Outputs:      emittedEvents,
+		LogMessages:  logMessages,
+		Duration:     time.Since(startTime),
+		ExecutedAt:   startTime,
+	}
+	if err != nil {
+		record.Error = strategy.ErrorDetails(err)
+	}
+	it.manager.recordExecution(record)
+
 	if err != nil {
 		metrics.RecordTopicProcessingError(it.config.StrategyID, "strategy_execution")
+		it.setLastError(err)
+		it.manager.publishDeadLetter(record)
 		return fmt.Errorf("strategy execution failed: %w", err)
 	}
 
 	// Process all emitted events
-	err = it.processEmittedEvents(emittedEvents)
+	err = it.processEmittedEvents(ctx, emittedEvents, triggerTopic)
+	if err == nil && !hasMainEvent(emittedEvents) {
+		err = it.applyNullPolicy(ctx)
+	}
 
 	// Record metrics
 	duration := time.Since(startTime).Seconds()
@@ -174,40 +353,99 @@ func (it *InternalTopic) ProcessInputs(triggerTopic string) error {
 
 	if err != nil {
 		metrics.RecordTopicProcessingError(it.config.StrategyID, "emit_events")
+		it.setLastError(err)
 		return err
 	}
 
+	it.setLastError(nil)
 	return nil
 }
 
-func (it *InternalTopic) emitToMQTT(value interface{}) error {
-	startTime := time.Now()
+// LastError returns the error from the most recent execution, or an empty
+// string if it succeeded
+func (it *InternalTopic) LastError() string {
+	it.errorMutex.RLock()
+	defer it.errorMutex.RUnlock()
+
+	return it.lastError
+}
+
+func (it *InternalTopic) setLastError(err error) {
+	it.errorMutex.Lock()
+	defer it.errorMutex.Unlock()
 
+	if err == nil {
+		it.lastError = ""
+	} else {
+		it.lastError = err.Error()
+	}
+}
+
+// GetTTL returns how long the topic may go without an update before it is
+// reported as stale (0 means never)
+func (it *InternalTopic) GetTTL() time.Duration {
+	return configTTL(it.config.Config)
+}
+
+// SetTTL sets the topic's staleness TTL.
+func (it *InternalTopic) SetTTL(ttl time.Duration) {
+	if it.config.Config == nil {
+		it.config.Config = make(map[string]interface{})
+	}
+	setConfigTTL(it.config.Config, ttl)
+}
+
+func (it *InternalTopic) emitToMQTT(value interface{}, triggerTopic string) error {
 	if it.manager == nil || it.manager.mqttClient == nil {
 		return fmt.Errorf("MQTT client not available")
 	}
 
-	// Serialize value to JSON
-	payload, err := json.Marshal(value)
+	// Publish to the topic name unless an MQTT topic template is configured
+	mqttTopic := it.config.Name
+	if template := it.GetMQTTTopicTemplate(); template != "" {
+		expanded, err := ExpandTopicTemplate(template, triggerTopic)
+		if err != nil {
+			return err
+		}
+		mqttTopic = expanded
+	}
+
+	return it.publishToMQTT(mqttTopic, value)
+}
+
+func (it *InternalTopic) publishToMQTT(mqttTopic string, value interface{}) error {
+	startTime := time.Now()
+	mqttTopic = it.manager.NormalizeTopic(mqttTopic)
+
+	// Serialize value with the topic's publish encoding
+	payload, err := it.manager.encodePayload(value, it.GetPublishEncoding())
 	if err != nil {
 		return fmt.Errorf("failed to serialize value: %w", err)
 	}
 
-	// Publish to MQTT
-	err = it.manager.mqttClient.Publish(it.config.Name, payload, false)
+	// Publish to MQTT, at the topic's own QoS when it has one
+	if qos, ok := it.GetQoS(); ok {
+		err = it.manager.mqttClient.PublishWithQoS(mqttTopic, payload, qos, false)
+	} else {
+		err = it.manager.mqttClient.Publish(mqttTopic, payload, false)
+	}
 
 	// Record metrics
 	duration := time.Since(startTime).Seconds()
 	if err != nil {
-		metrics.RecordMQTTPublishError(it.config.Name)
+		metrics.RecordMQTTPublishError(mqttTopic)
 		return err
 	}
 
-	metrics.RecordMQTTPublish(it.config.Name, duration)
+	metrics.RecordMQTTPublish(mqttTopic, duration)
+
+	it.mqttMutex.Lock()
+	it.lastMQTTTopic = mqttTopic
+	it.mqttMutex.Unlock()
 
 	// Log successful MQTT emission
 	if it.manager.logger != nil {
-		it.manager.logger.Printf("Published to MQTT topic: %s (%d bytes)", it.config.Name, len(payload))
+		it.manager.logger.Printf("Published to MQTT topic: %s (%d bytes)", mqttTopic, len(payload))
 	}
 
 	return nil
@@ -224,12 +462,508 @@ func (it *InternalTopic) GetConfig() InternalTopicConfig {
 
 func (it *InternalTopic) UpdateConfig(config InternalTopicConfig) {
 	it.config = config
+	it.restartSchedule()
+	it.restartRepublish()
+
+	it.groupMutex.Lock()
+	it.groupFresh = nil
+	it.groupMutex.Unlock()
+}
+
+// GetSchedule returns the interval or cron expression that triggers
+// ProcessInputs independently of input changes, if any
+func (it *InternalTopic) GetSchedule() string {
+	schedule, _ := it.config.Config["schedule"].(string)
+	return schedule
+}
+
+// SetSchedule sets (or clears, when empty) the topic's execution schedule.
+func (it *InternalTopic) SetSchedule(schedule string) error {
+	if schedule != "" {
+		if _, err := ParseSchedule(schedule); err != nil {
+			return err
+		}
+	}
+
+	if it.config.Config == nil {
+		it.config.Config = make(map[string]interface{})
+	}
+	if schedule == "" {
+		delete(it.config.Config, "schedule")
+	} else {
+		it.config.Config["schedule"] = schedule
+	}
+
+	it.restartSchedule()
+	return nil
+}
+
+func (it *InternalTopic) restartSchedule() {
+	it.stopSchedule()
+
+	spec := it.GetSchedule()
+	if spec == "" || it.manager == nil {
+		return
+	}
+
+	schedule, err := ParseSchedule(spec)
+	if err != nil {
+		it.manager.logger.Printf("Invalid schedule for topic %s: %v", it.config.Name, err)
+		return
+	}
+
+	it.schedule = newScheduleRunner(schedule, it.manager.clock, func(t time.Time) {
+		if err := it.ProcessInputs(ScheduledTrigger); err != nil {
+			it.manager.logger.Printf("Error in scheduled execution for topic %s: %v", it.config.Name, err)
+		}
+	})
+	it.schedule.start()
+}
+
+func (it *InternalTopic) stopSchedule() {
+	if it.schedule != nil {
+		it.schedule.stop()
+		it.schedule = nil
+	}
+}
+
+// GetRepublishInterval returns how often the current value is republished to
+// MQTT regardless of changes (0 means never)
+func (it *InternalTopic) GetRepublishInterval() time.Duration {
+	interval, _ := it.config.Config["republish_interval"].(string)
+	duration, err := ParseRepublishInterval(interval)
+	if err != nil {
+		return 0
+	}
+	return duration
+}
+
+// SetRepublishInterval sets (or clears, when zero) the periodic republish
+// interval.
+func (it *InternalTopic) SetRepublishInterval(interval time.Duration) {
+	if it.config.Config == nil {
+		it.config.Config = make(map[string]interface{})
+	}
+	if interval <= 0 {
+		delete(it.config.Config, "republish_interval")
+	} else {
+		it.config.Config["republish_interval"] = interval.String()
+	}
+	it.restartRepublish()
+}
+
+// ParseRepublishInterval validates a republish interval, treating empty as disabled
+func ParseRepublishInterval(interval string) (time.Duration, error) {
+	if interval == "" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(interval)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("invalid republish interval %q: expected a positive duration such as 60s", interval)
+	}
+	return duration, nil
+}
+
+func (it *InternalTopic) restartRepublish() {
+	it.stopRepublish()
+
+	interval := it.GetRepublishInterval()
+	if interval == 0 || it.manager == nil {
+		return
+	}
+
+	it.republish = newScheduleRunner(intervalSchedule{interval: interval}, it.manager.clock, func(t time.Time) {
+		if err := it.republishValue(); err != nil {
+			it.manager.logger.Printf("Error republishing topic %s: %v", it.config.Name, err)
+		}
+	})
+	it.republish.start()
+}
+
+func (it *InternalTopic) stopRepublish() {
+	if it.republish != nil {
+		it.republish.stop()
+		it.republish = nil
+	}
+}
+
+// republishValue publishes the current value again to where it was last
+// published, keeping MQTT consumers in sync without a value change
+func (it *InternalTopic) republishValue() error {
+	if !it.config.EmitToMQTT || it.LastUpdated().IsZero() {
+		return nil
+	}
+
+	it.mqttMutex.Lock()
+	mqttTopic := it.lastMQTTTopic
+	it.mqttMutex.Unlock()
+
+	if mqttTopic == "" {
+		if it.GetMQTTTopicTemplate() != "" {
+			// A templated topic cannot be resolved until a trigger has published it
+			return nil
+		}
+		mqttTopic = it.config.Name
+	}
+
+	if it.manager == nil || it.manager.mqttClient == nil {
+		return fmt.Errorf("MQTT client not available")
+	}
+	return it.publishToMQTT(mqttTopic, it.LastValue())
+}
+
+// GetMQTTTopicTemplate returns the MQTT topic the main value is published to,
+// which may reference segments of the triggering input (e.g. status/{segment:1})
+func (it *InternalTopic) GetMQTTTopicTemplate() string {
+	template, _ := it.config.Config["mqtt_topic"].(string)
+	return template
+}
+
+// SetMQTTTopicTemplate sets (or clears, when empty) the MQTT topic template.
+func (it *InternalTopic) SetMQTTTopicTemplate(template string) {
+	if it.config.Config == nil {
+		it.config.Config = make(map[string]interface{})
+	}
+	if template == "" {
+		delete(it.config.Config, "mqtt_topic")
+	} else {
+		it.config.Config["mqtt_topic"] = template
+	}
+}
+
+// GetNullPolicy returns how the topic handles a strategy producing no main value
+func (it *InternalTopic) GetNullPolicy() NullPolicy {
+	policy, _ := it.config.Config["null_policy"].(string)
+	if parsed, err := ParseNullPolicy(policy); err == nil {
+		return parsed
+	}
+	return NullPolicyKeep
+}
+
+// SetNullPolicy sets the topic's null policy.
+func (it *InternalTopic) SetNullPolicy(policy NullPolicy) error {
+	parsed, err := ParseNullPolicy(string(policy))
+	if err != nil {
+		return err
+	}
+
+	if it.config.Config == nil {
+		it.config.Config = make(map[string]interface{})
+	}
+	if parsed == NullPolicyKeep {
+		delete(it.config.Config, "null_policy")
+	} else {
+		it.config.Config["null_policy"] = string(parsed)
+	}
+	return nil
+}
+
+// GetMissingInputPolicy returns how the topic handles inputs that do not
+// exist yet, falling back to the manager's default
+func (it *InternalTopic) GetMissingInputPolicy() MissingInputPolicy {
+	if policy, _ := it.config.Config["missing_input_policy"].(string); policy != "" {
+		if parsed, err := ParseMissingInputPolicy(policy); err == nil {
+			return parsed
+		}
+	}
+	if it.manager != nil {
+		return it.manager.missingInputPolicy()
+	}
+	return MissingInputNil
+}
+
+// SetMissingInputPolicy sets the topic's missing input policy. An empty policy
+// uses the manager's default.
+func (it *InternalTopic) SetMissingInputPolicy(policy MissingInputPolicy) error {
+	if policy == "" {
+		delete(it.config.Config, "missing_input_policy")
+		return nil
+	}
+	parsed, err := ParseMissingInputPolicy(string(policy))
+	if err != nil {
+		return err
+	}
+
+	if it.config.Config == nil {
+		it.config.Config = make(map[string]interface{})
+	}
+	it.config.Config["missing_input_policy"] = string(parsed)
+	return nil
+}
+
+// IsGroup reports whether the topic only executes once every input has
+// delivered a fresh value since the last execution
+func (it *InternalTopic) IsGroup() bool {
+	group, _ := it.config.Config["group"].(bool)
+	return group
+}
+
+// SetGroup enables or disables group mode.
+func (it *InternalTopic) SetGroup(group bool) {
+	if it.config.Config == nil {
+		it.config.Config = make(map[string]interface{})
+	}
+	if group {
+		it.config.Config["group"] = true
+	} else {
+		delete(it.config.Config, "group")
+	}
+
+	it.groupMutex.Lock()
+	it.groupFresh = nil
+	it.groupMutex.Unlock()
+}
+
+// IsIgnoreRetainedTrigger reports whether retained MQTT messages replayed by
+// the broker are kept from triggering the topic
+func (it *InternalTopic) IsIgnoreRetainedTrigger() bool {
+	ignore, _ := it.config.Config["ignore_retained_trigger"].(bool)
+	return ignore
+}
+
+// SetIgnoreRetainedTrigger controls whether retained MQTT messages trigger
+// the topic. Their values are still stored and read as inputs when a live
+// message triggers it.
+func (it *InternalTopic) SetIgnoreRetainedTrigger(ignore bool) {
+	if it.config.Config == nil {
+		it.config.Config = make(map[string]interface{})
+	}
+	if ignore {
+		it.config.Config["ignore_retained_trigger"] = true
+	} else {
+		delete(it.config.Config, "ignore_retained_trigger")
+	}
+}
+
+// recordGroupInput marks the inputs matching triggerTopic as fresh. Once all
+// inputs but passive ones are fresh it returns the topics that delivered them
+// and starts a new set.
+func (it *InternalTopic) recordGroupInput(triggerTopic string) (map[string]string, bool) {
+	it.groupMutex.Lock()
+	defer it.groupMutex.Unlock()
+
+	it.markGroupInput(triggerTopic)
+
+	// Passive inputs never trigger, so the group does not wait for them
+	passive := it.GetPassiveInputs()
+	for _, inputTopic := range it.config.Inputs {
+		if containsString(passive, inputTopic) {
+			continue
+		}
+		if _, fresh := it.groupFresh[inputTopic]; !fresh {
+			return nil, false
+		}
+	}
+
+	members := it.groupFresh
+	it.groupFresh = nil
+	return members, true
+}
+
+// markGroupInput marks the inputs matching triggerTopic as fresh. The caller
+// must hold groupMutex.
+func (it *InternalTopic) markGroupInput(triggerTopic string) {
+	if it.groupFresh == nil {
+		it.groupFresh = make(map[string]string)
+	}
+	for _, inputTopic := range it.config.Inputs {
+		if inputTopic == triggerTopic || mqtt.TopicMatches(inputTopic, triggerTopic) {
+			it.groupFresh[inputTopic] = triggerTopic
+		}
+	}
+}
+
+// GetCoalesceWindow returns how long the topic waits after an input change for
+// further changes before executing once (0 means execute immediately)
+func (it *InternalTopic) GetCoalesceWindow() time.Duration {
+	window, _ := it.config.Config["coalesce_window"].(string)
+	duration, err := ParseCoalesceWindow(window)
+	if err != nil {
+		return 0
+	}
+	return duration
+}
+
+// SetCoalesceWindow sets (or clears, when zero) the coalescing window.
+func (it *InternalTopic) SetCoalesceWindow(window time.Duration) {
+	if it.config.Config == nil {
+		it.config.Config = make(map[string]interface{})
+	}
+	if window <= 0 {
+		delete(it.config.Config, "coalesce_window")
+	} else {
+		it.config.Config["coalesce_window"] = window.String()
+	}
+}
+
+// ParseCoalesceWindow validates a coalescing window, treating empty as disabled
+func ParseCoalesceWindow(window string) (time.Duration, error) {
+	if window == "" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(window)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("invalid coalesce window %q: expected a positive duration such as 200ms", window)
+	}
+	return duration, nil
+}
+
+// coalesceInput records an input change and, unless a window is already open
+// or its execution is still running, schedules a single execution for when
+// the window closes
+func (it *InternalTopic) coalesceInput(triggerTopic string, window time.Duration) {
+	it.coalesceMutex.Lock()
+	defer it.coalesceMutex.Unlock()
+
+	it.coalesceTriggers = append(it.coalesceTriggers, triggerTopic)
+	if it.coalesceStop != nil {
+		return
+	}
+	it.openCoalesceWindow(window)
+}
+
+// openCoalesceWindow schedules the execution of the inputs received until
+// window closes. Must be called with coalesceMutex held.
+func (it *InternalTopic) openCoalesceWindow(window time.Duration) {
+	stop := make(chan struct{})
+	it.coalesceStop = stop
+	closed := it.manager.clock.After(window)
+
+	go func() {
+		select {
+		case <-stop:
+			return
+		case <-closed:
+		}
+		if err := it.flushCoalesced(stop); err != nil {
+			it.manager.logger.Printf("Error in coalesced execution for topic %s: %v", it.config.Name, err)
+		}
+	}()
+}
+
+// flushCoalesced executes the topic once for every input received during the
+// window, using the latest change as the trigger. The window stays taken
+// while the execution runs, so executions never overlap; inputs received
+// meanwhile open the next window once it returns.
+func (it *InternalTopic) flushCoalesced(stop chan struct{}) error {
+	it.coalesceMutex.Lock()
+	if it.coalesceStop != stop {
+		// Cancelled after the window closed
+		it.coalesceMutex.Unlock()
+		return nil
+	}
+	triggers := it.coalesceTriggers
+	it.coalesceTriggers = nil
+	it.coalesceMutex.Unlock()
+
+	var err error
+	if len(triggers) > 0 {
+		latest := triggers[len(triggers)-1]
+		if it.IsGroup() {
+			// Earlier changes still count towards the group
+			it.groupMutex.Lock()
+			for _, trigger := range triggers[:len(triggers)-1] {
+				it.markGroupInput(trigger)
+			}
+			it.groupMutex.Unlock()
+		}
+
+		err = it.processInputs(context.Background(), latest)
+	}
+
+	it.coalesceMutex.Lock()
+	defer it.coalesceMutex.Unlock()
+	if it.coalesceStop == stop {
+		it.coalesceStop = nil
+		if len(it.coalesceTriggers) > 0 {
+			it.openCoalesceWindow(it.GetCoalesceWindow())
+		}
+	}
+	return err
+}
+
+// stopCoalesce cancels any pending coalesced execution
+func (it *InternalTopic) stopCoalesce() {
+	it.coalesceMutex.Lock()
+	defer it.coalesceMutex.Unlock()
+
+	if it.coalesceStop != nil {
+		close(it.coalesceStop)
+		it.coalesceStop = nil
+	}
+	it.coalesceTriggers = nil
+}
+
+func (it *InternalTopic) applyNullPolicy(ctx context.Context) error {
+	switch it.GetNullPolicy() {
+	case NullPolicyEmitNull:
+		if err := it.emit(ctx, nil, ""); err != nil {
+			return fmt.Errorf("failed to emit null: %w", err)
+		}
+	case NullPolicyClear:
+		if it.LastValue() == nil {
+			return nil
+		}
+		it.setValue(nil, time.Now())
+		if err := it.manager.SaveTopicState(it.config.Name, nil); err != nil {
+			return fmt.Errorf("failed to save topic state: %w", err)
+		}
+	}
+	return nil
+}
+
+func hasMainEvent(events []strategy.EmitEvent) bool {
+	for _, event := range events {
+		if event.Topic == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// GetParameters returns the topic's parameter overrides. The map must not be
+// modified.
+func (it *InternalTopic) GetParameters() map[string]interface{} {
+	it.parametersMutex.RLock()
+	defer it.parametersMutex.RUnlock()
+	return it.config.Parameters
 }
 
 func (it *InternalTopic) SetParameters(parameters map[string]interface{}) {
+	it.parametersMutex.Lock()
+	defer it.parametersMutex.Unlock()
 	it.config.Parameters = parameters
 }
 
+// UpdateParameters merges changes into the topic's parameter overrides, where
+// a nil value removes an override, and returns the merged overrides. They are
+// passed to persist (when non-nil) before taking effect, so a failed write
+// changes nothing; otherwise the next execution uses them.
+func (it *InternalTopic) UpdateParameters(changes map[string]interface{}, persist func(parameters map[string]interface{}) error) (map[string]interface{}, error) {
+	it.parametersMutex.Lock()
+	defer it.parametersMutex.Unlock()
+
+	merged := make(map[string]interface{}, len(it.config.Parameters)+len(changes))
+	for key, value := range it.config.Parameters {
+		merged[key] = value
+	}
+	for key, value := range changes {
+		if value == nil {
+			delete(merged, key)
+		} else {
+			merged[key] = value
+		}
+	}
+
+	if persist != nil {
+		if err := persist(merged); err != nil {
+			return nil, err
+		}
+	}
+	it.config.Parameters = merged
+	return merged, nil
+}
+
 func (it *InternalTopic) SetEmitToMQTT(emit bool) {
 	it.config.EmitToMQTT = emit
 }
@@ -238,16 +972,21 @@ func (it *InternalTopic) SetNoOpUnchanged(noop bool) {
 	it.config.NoOpUnchanged = noop
 }
 
-func (it *InternalTopic) processEmittedEvents(events []strategy.EmitEvent) error {
+func (it *InternalTopic) processEmittedEvents(ctx context.Context, events []strategy.EmitEvent, triggerTopic string) error {
+	events, err := it.checkOutputSchema(events)
+	if err != nil {
+		return err
+	}
+
 	for _, event := range events {
 		if event.Topic == "" {
 			// Empty topic means main topic (this internal topic)
-			if err := it.Emit(event.Value); err != nil {
+			if err := it.emit(ctx, event.Value, triggerTopic); err != nil {
 				return fmt.Errorf("failed to emit to main topic: %w", err)
 			}
 		} else {
 			// Handle subtopic emission
-			if err := it.emitToSubtopic(event.Topic, event.Value); err != nil {
+			if err := it.emitToSubtopic(ctx, event.Topic, event.Value, triggerTopic); err != nil {
 				return fmt.Errorf("failed to emit to subtopic %s: %w", event.Topic, err)
 			}
 		}
@@ -255,24 +994,101 @@ func (it *InternalTopic) processEmittedEvents(events []strategy.EmitEvent) error
 	return nil
 }
 
-func (it *InternalTopic) emitToSubtopic(topicPath string, value interface{}) error {
+func (it *InternalTopic) emitToSubtopic(ctx context.Context, topicPath string, value interface{}, triggerTopic string) error {
 	if it.manager == nil {
 		return fmt.Errorf("manager not available")
 	}
 
-	// Determine the full topic name
-	var fullTopicName string
-	if strings.HasPrefix(topicPath, "/") {
-		// Relative path - append to current topic name
-		fullTopicName = it.config.Name + topicPath
-	} else {
-		// Absolute path - use as is
-		fullTopicName = topicPath
+	// Expand placeholders referencing the triggering input, e.g. status/{segment:1}
+	topicPath, err := ExpandTopicTemplate(topicPath, triggerTopic)
+	if err != nil {
+		return err
+	}
+
+	fullTopicName, err := ResolveTopicPath(it.config.Name, topicPath)
+	if err != nil {
+		return err
+	}
+
+	// Create or update the subtopic as a derived internal topic. Child topics
+	// inherit the parent's MQTT emission setting unless overridden by path.
+	emitToMQTT := it.config.EmitToMQTT
+	if override, ok := it.ChildMQTTOverrides()[topicPath]; ok {
+		emitToMQTT = override
+	}
+	return it.manager.createOrUpdateDerivedTopic(ctx, fullTopicName, value, emitToMQTT)
+}
+
+// ResolveTopicPath resolves an emitted topic path against the emitting topic's
+// name. Paths starting with "/" are children of the topic and paths starting
+// with "../" navigate up from it, so "../humidity" emitted by
+// "house/kitchen/temp" targets "house/kitchen/humidity". In relative paths
+// "." segments are dropped and ".." removes the preceding level. Other paths
+// are absolute topic names and are used as is.
+func ResolveTopicPath(topicName, topicPath string) (string, error) {
+	if !strings.HasPrefix(topicPath, "/") && !strings.HasPrefix(topicPath, "../") {
+		return topicPath, nil
 	}
 
-	// Create or update the subtopic as a derived internal topic
-	// Child topics inherit MQTT emission setting from parent
-	return it.manager.createOrUpdateDerivedTopic(fullTopicName, value, it.config.EmitToMQTT)
+	resolved := strings.Split(topicName, "/")
+	for _, segment := range strings.Split(strings.TrimPrefix(topicPath, "/"), "/") {
+		switch segment {
+		case ".":
+		case "..":
+			if len(resolved) == 0 {
+				return "", fmt.Errorf("topic path %s navigates above the root of %s", topicPath, topicName)
+			}
+			resolved = resolved[:len(resolved)-1]
+		default:
+			resolved = append(resolved, segment)
+		}
+	}
+	if len(resolved) == 0 {
+		return "", fmt.Errorf("topic path %s resolves to an empty topic from %s", topicPath, topicName)
+	}
+
+	target := strings.Join(resolved, "/")
+	if target == topicName {
+		return "", fmt.Errorf("topic path %s resolves to %s itself", topicPath, topicName)
+	}
+	return target, nil
+}
+
+// ChildMQTTOverrides returns per-child MQTT publish settings keyed by the
+// emitted path (e.g. "/battery"), overriding the inherited EmitToMQTT
+func (it *InternalTopic) ChildMQTTOverrides() map[string]bool {
+	return ParseChildMQTTOverrides(it.config.Config["child_mqtt_overrides"])
+}
+
+// SetChildMQTTOverrides sets (or clears, when empty) the per-child MQTT
+// publish overrides.
+func (it *InternalTopic) SetChildMQTTOverrides(overrides map[string]bool) {
+	if len(overrides) == 0 {
+		delete(it.config.Config, "child_mqtt_overrides")
+		return
+	}
+	if it.config.Config == nil {
+		it.config.Config = make(map[string]interface{})
+	}
+	it.config.Config["child_mqtt_overrides"] = overrides
+}
+
+// ParseChildMQTTOverrides reads child MQTT overrides from a topic config
+// value, which is a map[string]interface{} after a JSON round trip
+func ParseChildMQTTOverrides(value interface{}) map[string]bool {
+	switch v := value.(type) {
+	case map[string]bool:
+		return v
+	case map[string]interface{}:
+		overrides := make(map[string]bool, len(v))
+		for path, emit := range v {
+			if b, ok := emit.(bool); ok {
+				overrides[path] = b
+			}
+		}
+		return overrides
+	}
+	return nil
 }
 
 func (it *InternalTopic) SetStrategyID(strategyID string) {
@@ -0,0 +1,670 @@
+package topics
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/strategy"
+)
+
+func TestInternalTopicNullPolicy(t *testing.T) {
+	// Returns the input value, or null/undefined when the input is "none"/"skip"
+	code := `function process(context) {
+		var value = context.inputs["sensors/temp"];
+		if (value === "none") { return null; }
+		if (value === "skip") { return; }
+		return value;
+	}`
+
+	tests := []struct {
+		name           string
+		policy         NullPolicy
+		input          string
+		wantValue      interface{}
+		wantNotified   bool
+		wantStateSaved bool
+	}{
+		{name: "keep ignores null", policy: NullPolicyKeep, input: "none", wantValue: "21.5"},
+		{name: "keep ignores undefined", policy: NullPolicyKeep, input: "skip", wantValue: "21.5"},
+		{name: "emit-null on null", policy: NullPolicyEmitNull, input: "none", wantValue: nil, wantNotified: true, wantStateSaved: true},
+		{name: "emit-null on undefined", policy: NullPolicyEmitNull, input: "skip", wantValue: nil, wantNotified: true, wantStateSaved: true},
+		{name: "clear on null", policy: NullPolicyClear, input: "none", wantValue: nil, wantStateSaved: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewManager(nil)
+
+			engine := strategy.NewEngine(nil)
+			for id, strategyCode := range map[string]string{
+				"passthrough": code,
+				"watcher":     `function process(context) { return context.inputs["processed/temp"]; }`,
+			} {
+				if err := engine.AddStrategy(&strategy.Strategy{ID: id, Name: id, Code: strategyCode, Language: "javascript"}); err != nil {
+					t.Fatalf("Failed to add strategy %s: %v", id, err)
+				}
+			}
+
+			var notifications []interface{}
+			manager.SetStrategyExecutor(&recordingExecutor{engine: engine, strategyID: "watcher", record: func(inputs map[string]interface{}) {
+				notifications = append(notifications, inputs["processed/temp"])
+			}})
+
+			var saved []interface{}
+			manager.SetStateManager(&mockStateManager{
+				saveFunc: func(topicName string, value interface{}) error {
+					if topicName == "internal:processed/temp" {
+						saved = append(saved, value)
+					}
+					return nil
+				},
+			})
+
+			sensor := mustAddExternalTopic(t, manager, "sensors/temp")
+			topic, err := manager.AddInternalTopic("processed/temp", []string{"sensors/temp"}, nil, "passthrough", nil, false, false)
+			if err != nil {
+				t.Fatalf("Failed to add internal topic: %v", err)
+			}
+			if err := topic.SetNullPolicy(tt.policy); err != nil {
+				t.Fatalf("SetNullPolicy failed: %v", err)
+			}
+			if _, err := manager.AddInternalTopic("watcher", []string{"processed/temp"}, nil, "watcher", nil, false, false); err != nil {
+				t.Fatalf("Failed to add watcher topic: %v", err)
+			}
+
+			if err := sensor.Emit("21.5"); err != nil {
+				t.Fatalf("Emit failed: %v", err)
+			}
+			notifications, saved = nil, nil
+
+			if err := sensor.Emit(tt.input); err != nil {
+				t.Fatalf("Emit failed: %v", err)
+			}
+
+			if topic.LastValue() != tt.wantValue {
+				t.Errorf("LastValue() = %v, want %v", topic.LastValue(), tt.wantValue)
+			}
+			if gotNotified := len(notifications) > 0; gotNotified != tt.wantNotified {
+				t.Errorf("dependents notified = %v, want %v", gotNotified, tt.wantNotified)
+			}
+			if gotSaved := len(saved) > 0; gotSaved != tt.wantStateSaved {
+				t.Errorf("state saved = %v, want %v", gotSaved, tt.wantStateSaved)
+			}
+		})
+	}
+}
+
+func TestInternalTopicNullPolicyConfig(t *testing.T) {
+	topic := NewInternalTopic("processed/temp", nil, "passthrough")
+
+	if topic.GetNullPolicy() != NullPolicyKeep {
+		t.Errorf("default policy = %q, want %q", topic.GetNullPolicy(), NullPolicyKeep)
+	}
+
+	if err := topic.SetNullPolicy(NullPolicyClear); err != nil {
+		t.Fatalf("SetNullPolicy failed: %v", err)
+	}
+	if topic.GetConfig().Config["null_policy"] != "clear" {
+		t.Error("null policy should be stored in the topic config")
+	}
+
+	if err := topic.SetNullPolicy("discard"); err == nil {
+		t.Error("SetNullPolicy should reject an unknown policy")
+	}
+	if topic.GetNullPolicy() != NullPolicyClear {
+		t.Error("invalid policy should not replace the existing one")
+	}
+}
+
+func TestInternalTopicMissingInputPolicy(t *testing.T) {
+	tests := []struct {
+		name         string
+		global       MissingInputPolicy
+		topic        MissingInputPolicy
+		wantExecuted bool
+		wantErr      bool
+	}{
+		{name: "nil passes nil", global: MissingInputNil, wantExecuted: true},
+		{name: "skip-execution skips", global: MissingInputSkip},
+		{name: "error reports and skips", global: MissingInputError, wantErr: true},
+		{name: "topic overrides global", global: MissingInputError, topic: MissingInputNil, wantExecuted: true},
+		{name: "topic skip with global nil", global: MissingInputNil, topic: MissingInputSkip},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewManager(nil)
+			if err := manager.SetMissingInputPolicy(tt.global); err != nil {
+				t.Fatalf("SetMissingInputPolicy failed: %v", err)
+			}
+
+			var executedInputs map[string]interface{}
+			manager.SetStrategyExecutor(&mockStrategyExecutor{
+				executeFunc: func(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+					executedInputs = inputs
+					return "ok", nil
+				},
+			})
+
+			manager.AddExternalTopic("sensors/temp")
+			topic, err := manager.AddInternalTopic("comfort", []string{"sensors/temp", "sensors/humdity", "sensors/+/battery"}, nil, "comfort", nil, false, false)
+			if err != nil {
+				t.Fatalf("Failed to add internal topic: %v", err)
+			}
+			if err := topic.SetMissingInputPolicy(tt.topic); err != nil {
+				t.Fatalf("SetMissingInputPolicy failed: %v", err)
+			}
+
+			err = topic.ProcessInputs("sensors/temp")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ProcessInputs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !strings.Contains(err.Error(), "sensors/humdity") {
+				t.Errorf("error %q should name the missing input", err)
+			}
+			if tt.wantErr && strings.Contains(err.Error(), "sensors/+/battery") {
+				t.Errorf("error %q should not treat wildcard inputs as missing", err)
+			}
+
+			if executed := executedInputs != nil; executed != tt.wantExecuted {
+				t.Fatalf("strategy executed = %v, want %v", executed, tt.wantExecuted)
+			}
+			if tt.wantExecuted {
+				if value, ok := executedInputs["sensors/humdity"]; !ok || value != nil {
+					t.Errorf("missing input = %v (present %v), want nil", value, ok)
+				}
+			}
+		})
+	}
+}
+
+func TestParseMissingInputPolicy(t *testing.T) {
+	if policy, err := ParseMissingInputPolicy(""); err != nil || policy != MissingInputNil {
+		t.Errorf("ParseMissingInputPolicy(\"\") = %q, %v, want %q", policy, err, MissingInputNil)
+	}
+	if _, err := ParseMissingInputPolicy("ignore"); err == nil {
+		t.Error("ParseMissingInputPolicy should reject an unknown policy")
+	}
+
+	topic := NewInternalTopic("comfort", nil, "comfort")
+	if err := topic.SetMissingInputPolicy("ignore"); err == nil {
+		t.Error("SetMissingInputPolicy should reject an unknown policy")
+	}
+	if err := topic.SetMissingInputPolicy(MissingInputSkip); err != nil {
+		t.Fatalf("SetMissingInputPolicy failed: %v", err)
+	}
+	if topic.GetConfig().Config["missing_input_policy"] != "skip-execution" {
+		t.Error("missing input policy should be stored in the topic config")
+	}
+}
+
+// recordingExecutor wraps an engine and records the inputs passed to one strategy
+type recordingExecutor struct {
+	engine     *strategy.Engine
+	strategyID string
+	record     func(inputs map[string]interface{})
+}
+
+func (r *recordingExecutor) ExecuteStrategy(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) ([]strategy.EmitEvent, error) {
+	if strategyID == r.strategyID {
+		r.record(inputs)
+	}
+	return r.engine.ExecuteStrategy(strategyID, inputs, inputNames, triggerTopic, lastOutput, topicParameters)
+}
+
+func (r *recordingExecutor) GetStrategy(strategyID string) (*strategy.Strategy, error) {
+	return r.engine.GetStrategy(strategyID)
+}
+
+// mockPublisher records MQTT publishes and the QoS of those with an
+// explicit QoS
+type mockPublisher struct {
+	published map[string][]byte
+	qos       map[string]byte
+}
+
+func (m *mockPublisher) Publish(topic string, payload []byte, retain bool) error {
+	if m.published == nil {
+		m.published = make(map[string][]byte)
+	}
+	m.published[topic] = payload
+	return nil
+}
+
+func (m *mockPublisher) PublishWithQoS(topic string, payload []byte, qos byte, retain bool) error {
+	if m.qos == nil {
+		m.qos = make(map[string]byte)
+	}
+	m.qos[topic] = qos
+	return m.Publish(topic, payload, retain)
+}
+
+func TestInternalTopicTemplatedMQTTOutput(t *testing.T) {
+	tests := []struct {
+		name          string
+		code          string
+		mqttTopic     string
+		wantPublished map[string]string
+	}{
+		{
+			name:          "subtopic emit uses triggering input segment",
+			code:          `function process(context) { context.emit("status/{segment:1}", context.triggeringValue); }`,
+			wantPublished: map[string]string{"status/kitchen": "21.5"},
+		},
+		{
+			name:          "main value published to templated MQTT topic",
+			code:          `function process(context) { return context.triggeringValue; }`,
+			mqttTopic:     "status/{segment:1}/temp",
+			wantPublished: map[string]string{"status/kitchen/temp": "21.5"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewManager(nil)
+			publisher := &mockPublisher{}
+			manager.SetMQTTClient(publisher)
+
+			engine := strategy.NewEngine(nil)
+			if err := engine.AddStrategy(&strategy.Strategy{ID: "per-room", Name: "Per room", Code: tt.code, Language: "javascript"}); err != nil {
+				t.Fatalf("Failed to add strategy: %v", err)
+			}
+			manager.SetStrategyExecutor(engine)
+
+			sensor := mustAddExternalTopic(t, manager, "sensors/kitchen/temp")
+			topic, err := manager.AddInternalTopic("rooms", []string{"sensors/+/temp"}, nil, "per-room", nil, true, false)
+			if err != nil {
+				t.Fatalf("Failed to add internal topic: %v", err)
+			}
+			topic.SetMQTTTopicTemplate(tt.mqttTopic)
+
+			if err := sensor.Emit(21.5); err != nil {
+				t.Fatalf("Emit failed: %v", err)
+			}
+
+			for mqttTopic, want := range tt.wantPublished {
+				payload, ok := publisher.published[mqttTopic]
+				if !ok {
+					t.Errorf("nothing published to %s (published: %v)", mqttTopic, publisher.published)
+					continue
+				}
+				if string(payload) != want {
+					t.Errorf("payload on %s = %s, want %s", mqttTopic, payload, want)
+				}
+			}
+			if _, ok := publisher.published["rooms"]; ok && tt.mqttTopic != "" {
+				t.Error("main value should not be published to the topic name when a template is set")
+			}
+		})
+	}
+}
+
+func TestInternalTopicChildMQTTOverrides(t *testing.T) {
+	manager := NewManager(nil)
+	publisher := &mockPublisher{}
+	manager.SetMQTTClient(publisher)
+
+	engine := strategy.NewEngine(nil)
+	code := `function process(context) {
+		context.emit("/battery", 80);
+		context.emit("/debug", "raw");
+		context.emit("/alarm", true);
+		return context.triggeringValue;
+	}`
+	if err := engine.AddStrategy(&strategy.Strategy{ID: "split", Name: "Split", Code: code, Language: "javascript"}); err != nil {
+		t.Fatalf("Failed to add strategy: %v", err)
+	}
+	manager.SetStrategyExecutor(engine)
+
+	sensor := mustAddExternalTopic(t, manager, "sensors/car")
+
+	// Publishing parent with one child suppressed
+	publishing, err := manager.AddInternalTopic("car", []string{"sensors/car"}, nil, "split", nil, true, false)
+	if err != nil {
+		t.Fatalf("Failed to add internal topic: %v", err)
+	}
+	publishing.SetChildMQTTOverrides(map[string]bool{"/debug": false})
+
+	// Silent parent with one child force-enabled, as loaded from the database
+	silent, err := manager.AddInternalTopic("van", []string{"sensors/car"}, nil, "split", nil, false, false)
+	if err != nil {
+		t.Fatalf("Failed to add internal topic: %v", err)
+	}
+	silent.GetConfig().Config["child_mqtt_overrides"] = map[string]interface{}{"/alarm": true}
+
+	if err := sensor.Emit(1); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+
+	wantPublished := map[string]bool{
+		"car":         true,
+		"car/battery": true,
+		"car/alarm":   true,
+		"car/debug":   false,
+		"van":         false,
+		"van/battery": false,
+		"van/debug":   false,
+		"van/alarm":   true,
+	}
+	for mqttTopic, want := range wantPublished {
+		if _, got := publisher.published[mqttTopic]; got != want {
+			t.Errorf("published to %s = %v, want %v", mqttTopic, got, want)
+		}
+	}
+
+	// Derived topics are still created when not published
+	if child := manager.GetTopic("car/debug"); child == nil || child.LastValue() != "raw" {
+		t.Errorf("car/debug = %v, want derived topic with value raw", child)
+	}
+}
+
+func TestResolveTopicPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{path: "/battery", want: "house/kitchen/temp/battery"},
+		{path: "../humidity", want: "house/kitchen/humidity"},
+		{path: "../../hallway/temp", want: "house/hallway/temp"},
+		{path: "/../humidity", want: "house/kitchen/humidity"},
+		{path: "../humidity/./raw", want: "house/kitchen/humidity/raw"},
+		{path: "alerts/kitchen", want: "alerts/kitchen"},
+		{path: "../../../../escape", wantErr: true},
+		{path: "../temp", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := ResolveTopicPath("house/kitchen/temp", tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveTopicPath() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ResolveTopicPath() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInternalTopicEmitToSibling(t *testing.T) {
+	manager := NewManager(nil)
+
+	engine := strategy.NewEngine(nil)
+	code := `function process(context) {
+		context.emit("../humidity", context.triggeringValue + 20);
+		return context.triggeringValue;
+	}`
+	if err := engine.AddStrategy(&strategy.Strategy{ID: "sibling", Name: "Sibling", Code: code, Language: "javascript"}); err != nil {
+		t.Fatalf("Failed to add strategy: %v", err)
+	}
+	manager.SetStrategyExecutor(engine)
+
+	sensor := mustAddExternalTopic(t, manager, "sensors/kitchen")
+	if _, err := manager.AddInternalTopic("house/kitchen/temp", []string{"sensors/kitchen"}, nil, "sibling", nil, false, false); err != nil {
+		t.Fatalf("Failed to add internal topic: %v", err)
+	}
+
+	if err := sensor.Emit(21); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+
+	sibling := manager.GetTopic("house/kitchen/humidity")
+	if sibling == nil {
+		t.Fatal("sibling topic house/kitchen/humidity was not created")
+	}
+	if got := sibling.LastValue(); fmt.Sprint(got) != "41" {
+		t.Errorf("house/kitchen/humidity = %#v, want 41", got)
+	}
+}
+
+func TestInternalTopicGroup(t *testing.T) {
+	manager := NewManager(nil)
+
+	var executions []map[string]interface{}
+	manager.SetStrategyExecutor(&mockStrategyExecutor{
+		executeFunc: func(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+			executions = append(executions, inputs)
+			return "ok", nil
+		},
+	})
+
+	lat := mustAddExternalTopic(t, manager, "gps/lat")
+	lon := mustAddExternalTopic(t, manager, "gps/lon")
+	topic, err := manager.AddInternalTopic("gps/position", []string{"gps/lat", "gps/lon"}, map[string]string{"gps/lat": "lat", "gps/lon": "lon"}, "position", nil, false, false)
+	if err != nil {
+		t.Fatalf("Failed to add internal topic: %v", err)
+	}
+	topic.SetGroup(true)
+	if !topic.IsGroup() {
+		t.Fatal("IsGroup() should be true after SetGroup(true)")
+	}
+
+	steps := []struct {
+		topic          *ExternalTopic
+		value          interface{}
+		wantExecutions int
+	}{
+		{lat, -33.8, 0},
+		{lat, -33.9, 0}, // still waiting on lon
+		{lon, 151.2, 1},
+		{lon, 151.3, 1}, // lat is no longer fresh
+		{lat, -34.0, 2},
+	}
+
+	for i, step := range steps {
+		if err := step.topic.Emit(step.value); err != nil {
+			t.Fatalf("step %d: Emit failed: %v", i, err)
+		}
+		if len(executions) != step.wantExecutions {
+			t.Fatalf("step %d: executions = %d, want %d", i, len(executions), step.wantExecutions)
+		}
+	}
+
+	// Each execution receives the latest value of every member
+	want := []map[string]interface{}{
+		{"lat": -33.9, "lon": 151.2},
+		{"lat": -34.0, "lon": 151.3},
+	}
+	if !reflect.DeepEqual(executions, want) {
+		t.Errorf("executions = %v, want %v", executions, want)
+	}
+
+	// Without group mode every input triggers execution
+	topic.SetGroup(false)
+	if err := lat.Emit(-34.1); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	if len(executions) != 3 {
+		t.Errorf("executions = %d, want 3 after disabling group mode", len(executions))
+	}
+}
+
+func TestInternalTopicGroupWildcard(t *testing.T) {
+	manager := NewManager(nil)
+
+	var executions []map[string]interface{}
+	manager.SetStrategyExecutor(&mockStrategyExecutor{
+		executeFunc: func(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+			executions = append(executions, inputs)
+			return "ok", nil
+		},
+	})
+
+	temp := mustAddExternalTopic(t, manager, "sensors/kitchen/temperature")
+	mode := mustAddExternalTopic(t, manager, "heating/mode")
+	topic, err := manager.AddInternalTopic("heating/kitchen", []string{"sensors/+/temperature", "heating/mode"}, nil, "heating", nil, false, false)
+	if err != nil {
+		t.Fatalf("Failed to add internal topic: %v", err)
+	}
+	topic.SetGroup(true)
+
+	if err := temp.Emit(21.5); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	if len(executions) != 0 {
+		t.Fatalf("executions = %d, want 0 before all members are fresh", len(executions))
+	}
+	if err := mode.Emit("auto"); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	if len(executions) != 1 {
+		t.Fatalf("executions = %d, want 1", len(executions))
+	}
+
+	// The wildcard member is reported under the topic that delivered the value
+	want := map[string]interface{}{"sensors/kitchen/temperature": 21.5, "heating/mode": "auto"}
+	if !reflect.DeepEqual(executions[0], want) {
+		t.Errorf("inputs = %v, want %v", executions[0], want)
+	}
+}
+
+func TestInternalTopicUpdateParameters(t *testing.T) {
+	manager := NewManager(nil)
+
+	var executedParameters map[string]interface{}
+	manager.SetStrategyExecutor(&mockStrategyExecutor{
+		executeFunc: func(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+			executedParameters = topicParameters
+			return "ok", nil
+		},
+	})
+
+	sensor := mustAddExternalTopic(t, manager, "sensors/temp")
+	topic, err := manager.AddInternalTopic("house/temp", []string{"sensors/temp"}, nil, "test", map[string]interface{}{"offset": 0.5, "unit": "c"}, false, false)
+	if err != nil {
+		t.Fatalf("Failed to add internal topic: %v", err)
+	}
+
+	// A failed write leaves the parameters unchanged
+	if _, err := topic.UpdateParameters(map[string]interface{}{"offset": 9.5}, func(map[string]interface{}) error {
+		return fmt.Errorf("disk full")
+	}); err == nil {
+		t.Fatal("UpdateParameters ignored the persist error")
+	}
+
+	var persisted map[string]interface{}
+	merged, err := topic.UpdateParameters(map[string]interface{}{"offset": 1.5, "unit": nil, "scale": 2.5}, func(parameters map[string]interface{}) error {
+		persisted = parameters
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateParameters failed: %v", err)
+	}
+
+	want := map[string]interface{}{"offset": 1.5, "scale": 2.5}
+	if !reflect.DeepEqual(merged, want) || !reflect.DeepEqual(persisted, want) {
+		t.Errorf("merged = %v, persisted = %v, want %v", merged, persisted, want)
+	}
+
+	if err := sensor.Emit(21.5); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	if !reflect.DeepEqual(executedParameters, want) {
+		t.Errorf("strategy parameters = %v, want %v", executedParameters, want)
+	}
+}
+
+func TestStrategyReadsOtherTopics(t *testing.T) {
+	manager := NewManager(nil)
+	engine := strategy.NewEngine(nil)
+	engine.SetTopicReader(manager)
+	code := `function process(context) {
+		return {
+			mode: context.getTopic("house/mode"),
+			missing: context.getTopic("house/unknown"),
+			topic: context.topicName
+		};
+	}`
+	if err := engine.AddStrategy(&strategy.Strategy{ID: "lights", Name: "Lights", Code: code, Language: "javascript"}); err != nil {
+		t.Fatalf("Failed to add strategy: %v", err)
+	}
+	manager.SetStrategyExecutor(engine)
+
+	sensor := mustAddExternalTopic(t, manager, "sensors/hall/motion")
+	mode := mustAddExternalTopic(t, manager, "house/mode")
+	topic, err := manager.AddInternalTopic("lights/hall", []string{"sensors/hall/motion"}, nil, "lights", nil, false, false)
+	if err != nil {
+		t.Fatalf("Failed to add internal topic: %v", err)
+	}
+
+	if err := mode.Emit("away"); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	if value := topic.LastValue(); value != nil {
+		t.Fatalf("topic = %v after a topic it reads changed, want it not triggered", value)
+	}
+
+	if err := sensor.Emit(true); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	want := map[string]interface{}{"mode": "away", "missing": nil, "topic": "lights/hall"}
+	if value := topic.LastValue(); !reflect.DeepEqual(value, want) {
+		t.Errorf("topic = %v, want %v", value, want)
+	}
+
+	// Removed topics read as null rather than their last value
+	if err := manager.RemoveTopic("house/mode"); err != nil {
+		t.Fatalf("RemoveTopic failed: %v", err)
+	}
+	if err := sensor.Emit(false); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	if value, _ := topic.LastValue().(map[string]interface{}); value["mode"] != nil {
+		t.Errorf("mode = %v after the topic was removed, want null", value["mode"])
+	}
+}
+
+func TestInternalTopicExecutesOneAtATime(t *testing.T) {
+	const perInput = 20
+	inputs := []string{"sensors/a", "sensors/b", "sensors/c", "sensors/d"}
+
+	manager := NewManager(nil)
+	var active, maxActive int32
+	manager.SetStrategyExecutor(&mockStrategyExecutor{
+		executeFunc: func(strategyID string, inputValues map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+			if current := atomic.AddInt32(&active, 1); current > atomic.LoadInt32(&maxActive) {
+				atomic.StoreInt32(&maxActive, current)
+			}
+			defer atomic.AddInt32(&active, -1)
+			time.Sleep(100 * time.Microsecond)
+
+			// Counts executions through its previous output
+			count, _ := lastOutput.(int)
+			return count + 1, nil
+		},
+	})
+
+	sensors := make([]*ExternalTopic, len(inputs))
+	for i, input := range inputs {
+		sensors[i] = mustAddExternalTopic(t, manager, input)
+	}
+	topic := mustAddInternalTopic(t, manager, "sensors/count", inputs)
+
+	// Inputs on different MQTT topics arrive on different workers
+	var wg sync.WaitGroup
+	for _, sensor := range sensors {
+		wg.Add(1)
+		go func(sensor *ExternalTopic) {
+			defer wg.Done()
+			for i := 0; i < perInput; i++ {
+				if err := sensor.Emit(i); err != nil {
+					t.Errorf("Emit failed: %v", err)
+				}
+			}
+		}(sensor)
+	}
+	wg.Wait()
+
+	if maxActive != 1 {
+		t.Errorf("%d executions ran at once, want 1", maxActive)
+	}
+	if want := len(inputs) * perInput; topic.LastValue() != want {
+		t.Errorf("count = %v, want %d", topic.LastValue(), want)
+	}
+}
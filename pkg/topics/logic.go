@@ -0,0 +1,312 @@
+package topics
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/strategy"
+)
+
+// LogicStrategyID is the strategy ID of internal topics computed by a boolean
+// logic expression rather than a strategy
+const LogicStrategyID = "logic"
+
+// LogicExpression is a boolean expression over a topic's inputs, such as
+// "living_room_motion AND NOT away_mode". It supports AND, OR and NOT (or
+// &&, || and !), parentheses and the literals true and false; keywords are
+// case-insensitive. Any other word is an input, referred to by its input
+// name or, for unnamed inputs, its topic.
+type LogicExpression struct {
+	source string
+	root   logicNode
+}
+
+type logicNode interface {
+	eval(values map[string]interface{}) (bool, error)
+}
+
+type logicInput string
+
+type logicLiteral bool
+
+type logicNot struct {
+	operand logicNode
+}
+
+type logicBinary struct {
+	and         bool
+	left, right logicNode
+}
+
+func (n logicInput) eval(values map[string]interface{}) (bool, error) {
+	value, ok := values[string(n)]
+	if !ok || value == nil {
+		return false, fmt.Errorf("input %s has no value", string(n))
+	}
+	b, ok := value.(bool)
+	if !ok {
+		return false, fmt.Errorf("input %s is not a boolean: %v", string(n), value)
+	}
+	return b, nil
+}
+
+func (n logicLiteral) eval(map[string]interface{}) (bool, error) {
+	return bool(n), nil
+}
+
+func (n logicNot) eval(values map[string]interface{}) (bool, error) {
+	value, err := n.operand.eval(values)
+	return !value, err
+}
+
+// eval evaluates both operands, so a missing or invalid input is reported
+// whatever the other operand's value
+func (n logicBinary) eval(values map[string]interface{}) (bool, error) {
+	left, err := n.left.eval(values)
+	if err != nil {
+		return false, err
+	}
+	right, err := n.right.eval(values)
+	if err != nil {
+		return false, err
+	}
+	if n.and {
+		return left && right, nil
+	}
+	return left || right, nil
+}
+
+// ParseLogicExpression parses a boolean logic expression
+func ParseLogicExpression(source string) (*LogicExpression, error) {
+	tokens, err := tokenizeLogic(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid logic expression %q: %w", source, err)
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("logic expression is empty")
+	}
+
+	parser := &logicParser{tokens: tokens}
+	root, err := parser.parseOr()
+	if err == nil && parser.pos < len(tokens) {
+		err = fmt.Errorf("unexpected %q", tokens[parser.pos])
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid logic expression %q: %w", source, err)
+	}
+	return &LogicExpression{source: source, root: root}, nil
+}
+
+// String returns the expression as written
+func (e *LogicExpression) String() string {
+	return e.source
+}
+
+// Inputs returns the inputs the expression refers to, in order of first use
+func (e *LogicExpression) Inputs() []string {
+	var inputs []string
+	var walk func(node logicNode)
+	walk = func(node logicNode) {
+		switch n := node.(type) {
+		case logicInput:
+			if !containsString(inputs, string(n)) {
+				inputs = append(inputs, string(n))
+			}
+		case logicNot:
+			walk(n.operand)
+		case logicBinary:
+			walk(n.left)
+			walk(n.right)
+		}
+	}
+	walk(e.root)
+	return inputs
+}
+
+// Evaluate computes the expression from input values keyed as a strategy
+// receives them. Every input it refers to must have a boolean value.
+func (e *LogicExpression) Evaluate(values map[string]interface{}) (bool, error) {
+	return e.root.eval(values)
+}
+
+// tokenizeLogic splits an expression into parentheses, operators and words
+func tokenizeLogic(source string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(source); {
+		switch c := source[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')' || c == '!':
+			tokens = append(tokens, source[i:i+1])
+			i++
+		case c == '&' || c == '|':
+			if i+1 >= len(source) || source[i+1] != c {
+				return nil, fmt.Errorf("unexpected %q", c)
+			}
+			tokens = append(tokens, source[i:i+2])
+			i += 2
+		default:
+			start := i
+			for i < len(source) && !strings.ContainsRune(" \t\n\r()!&|", rune(source[i])) {
+				i++
+			}
+			tokens = append(tokens, source[start:i])
+		}
+	}
+	return tokens, nil
+}
+
+type logicParser struct {
+	tokens []string
+	pos    int
+}
+
+// accept consumes the next token if it is one of the operators given
+func (p *logicParser) accept(operators ...string) bool {
+	if p.pos >= len(p.tokens) {
+		return false
+	}
+	for _, operator := range operators {
+		if strings.EqualFold(p.tokens[p.pos], operator) {
+			p.pos++
+			return true
+		}
+	}
+	return false
+}
+
+func (p *logicParser) parseOr() (logicNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("OR", "||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicBinary{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *logicParser) parseAnd() (logicNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("AND", "&&") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = logicBinary{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *logicParser) parseNot() (logicNode, error) {
+	if p.accept("NOT", "!") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return logicNot{operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *logicParser) parsePrimary() (logicNode, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	if p.accept("(") {
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, fmt.Errorf("missing )")
+		}
+		return node, nil
+	}
+
+	token := p.tokens[p.pos]
+	switch strings.ToUpper(token) {
+	case "TRUE":
+		p.pos++
+		return logicLiteral(true), nil
+	case "FALSE":
+		p.pos++
+		return logicLiteral(false), nil
+	case "AND", "OR", "NOT", "&&", "||", ")":
+		return nil, fmt.Errorf("unexpected %q", token)
+	}
+	p.pos++
+	return logicInput(token), nil
+}
+
+// ValidateLogic checks that a logic expression parses and refers only to the
+// given inputs, by input name or, for unnamed inputs, by topic
+func ValidateLogic(expression string, inputs []string, inputNames map[string]string) error {
+	logic, err := ParseLogicExpression(expression)
+	if err != nil {
+		return err
+	}
+	known := make([]string, 0, len(inputs))
+	for _, input := range inputs {
+		if name, ok := inputNames[input]; ok {
+			known = append(known, name)
+		} else {
+			known = append(known, input)
+		}
+	}
+	for _, input := range logic.Inputs() {
+		if !containsString(known, input) {
+			return fmt.Errorf("logic expression refers to %s, which is not an input of the topic", input)
+		}
+	}
+	return nil
+}
+
+// GetLogic returns the topic's logic expression, or nil if it runs a strategy
+func (it *InternalTopic) GetLogic() *LogicExpression {
+	source, _ := it.config.Config["logic"].(string)
+	if source == "" {
+		return nil
+	}
+	logic, err := ParseLogicExpression(source)
+	if err != nil {
+		return nil
+	}
+	return logic
+}
+
+// SetLogic sets (or clears, when empty) the boolean expression computing the
+// topic's value.
+func (it *InternalTopic) SetLogic(expression string) error {
+	if it.config.Config == nil {
+		it.config.Config = make(map[string]interface{})
+	}
+	if expression == "" {
+		delete(it.config.Config, "logic")
+		return nil
+	}
+	if it.GetTransform() != nil {
+		return fmt.Errorf("topic %s cannot have both a transform and a logic expression", it.config.Name)
+	}
+	if err := ValidateLogic(expression, it.config.Inputs, it.config.InputNames); err != nil {
+		return err
+	}
+	it.config.Config["logic"] = expression
+	return nil
+}
+
+// applyLogic computes the topic's value from its logic expression
+func (it *InternalTopic) applyLogic(logic *LogicExpression, inputValues map[string]interface{}) ([]strategy.EmitEvent, error) {
+	value, err := logic.Evaluate(inputValues)
+	if err != nil {
+		return nil, fmt.Errorf("logic %q: %w", logic.String(), err)
+	}
+	return []strategy.EmitEvent{{Topic: "", Value: value}}, nil
+}
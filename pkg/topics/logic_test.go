@@ -0,0 +1,143 @@
+package topics
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLogicExpressionEvaluate(t *testing.T) {
+	values := map[string]interface{}{
+		"motion":       true,
+		"away_mode":    false,
+		"door/open":    true,
+		"night":        false,
+		"temperature":  21.5,
+		"status":       "on",
+		"disconnected": nil,
+	}
+
+	tests := []struct {
+		expression string
+		want       bool
+		wantErr    string
+	}{
+		{expression: "motion", want: true},
+		{expression: "motion AND NOT away_mode", want: true},
+		{expression: "motion and away_mode", want: false},
+		{expression: "away_mode OR night", want: false},
+		{expression: "away_mode || door/open", want: true},
+		{expression: "!motion", want: false},
+		{expression: "NOT NOT motion", want: true},
+		{expression: "motion && !(away_mode || night)", want: true},
+		// AND binds tighter than OR
+		{expression: "motion OR away_mode AND night", want: true},
+		{expression: "(motion OR away_mode) AND night", want: false},
+		{expression: "night OR TRUE", want: true},
+		{expression: "motion AND false", want: false},
+		{expression: "motion AND missing", wantErr: "input missing has no value"},
+		{expression: "disconnected OR motion", wantErr: "input disconnected has no value"},
+		{expression: "motion OR temperature", wantErr: "input temperature is not a boolean: 21.5"},
+		{expression: "NOT status", wantErr: "input status is not a boolean: on"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expression, func(t *testing.T) {
+			logic, err := ParseLogicExpression(tt.expression)
+			if err != nil {
+				t.Fatalf("ParseLogicExpression failed: %v", err)
+			}
+			got, err := logic.Evaluate(values)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("Evaluate error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Evaluate failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLogicExpression(t *testing.T) {
+	logic, err := ParseLogicExpression("motion AND NOT (away OR motion)")
+	if err != nil {
+		t.Fatalf("ParseLogicExpression failed: %v", err)
+	}
+	if inputs := logic.Inputs(); !reflect.DeepEqual(inputs, []string{"motion", "away"}) {
+		t.Errorf("Inputs() = %v, want [motion away]", inputs)
+	}
+
+	invalid := []string{
+		"",
+		"motion AND",
+		"AND motion",
+		"motion away",
+		"(motion OR away",
+		"motion OR away)",
+		"motion & away",
+		"NOT",
+	}
+	for _, expression := range invalid {
+		if _, err := ParseLogicExpression(expression); err == nil {
+			t.Errorf("ParseLogicExpression(%q) accepted an invalid expression", expression)
+		}
+	}
+}
+
+func TestInternalTopicLogic(t *testing.T) {
+	manager := NewManager(nil)
+	motion := mustAddExternalTopic(t, manager, "sensors/living_room/motion")
+	away := mustAddExternalTopic(t, manager, "house/away")
+	light, err := manager.AddInternalTopic("lights/living_room", []string{"sensors/living_room/motion", "house/away"}, map[string]string{"sensors/living_room/motion": "living_room_motion"}, LogicStrategyID, nil, false, false)
+	if err != nil {
+		t.Fatalf("Failed to add internal topic: %v", err)
+	}
+
+	if err := light.SetLogic("living_room_motion AND NOT away_mode"); err == nil || !strings.Contains(err.Error(), "away_mode") {
+		t.Fatalf("SetLogic error = %v, want an unknown input error", err)
+	}
+	// Unnamed inputs are referred to by topic
+	if err := light.SetLogic("living_room_motion AND NOT house/away"); err != nil {
+		t.Fatalf("SetLogic failed: %v", err)
+	}
+
+	if err := away.Emit(false); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	// Motion has no value yet, so the expression fails
+	if light.LastValue() != nil || light.LastError() == "" {
+		t.Errorf("value = %v, error = %q; want no value and an error", light.LastValue(), light.LastError())
+	}
+
+	steps := []struct {
+		topic *ExternalTopic
+		value interface{}
+		want  bool
+	}{
+		{topic: motion, value: true, want: true},
+		{topic: away, value: true, want: false},
+		{topic: away, value: false, want: true},
+		{topic: motion, value: false, want: false},
+	}
+	for _, step := range steps {
+		if err := step.topic.Emit(step.value); err != nil {
+			t.Fatalf("Emit failed: %v", err)
+		}
+		if light.LastValue() != step.want {
+			t.Errorf("after %s = %v, value = %v, want %v", step.topic.Name(), step.value, light.LastValue(), step.want)
+		}
+	}
+
+	if err := light.SetLogic(""); err != nil {
+		t.Fatalf("SetLogic failed: %v", err)
+	}
+	if light.GetLogic() != nil {
+		t.Error("logic was not cleared")
+	}
+}
@@ -1,6 +1,7 @@
 package topics
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"strings"
@@ -25,16 +26,52 @@ type StateManager interface {
 	RestoreTopicStates() (map[string]interface{}, error)
 }
 
+// MQTTPublisher publishes topic values to the MQTT broker
+type MQTTPublisher interface {
+	// Publish publishes at the QoS configured for the topic
+	Publish(topic string, payload []byte, retain bool) error
+	PublishWithQoS(topic string, payload []byte, qos byte, retain bool) error
+}
+
+// Subscriber adds MQTT subscriptions for internal topic inputs at runtime
+type Subscriber interface {
+	AddSubscription(pattern string) bool
+}
+
 type Manager struct {
-	topics           map[string]Topic
-	externalTopics   map[string]*ExternalTopic
-	internalTopics   map[string]*InternalTopic
-	systemTopics     map[string]*SystemTopic
-	strategyExecutor StrategyExecutor
-	stateManager     StateManager
-	mqttClient       *mqtt.Client
-	logger           *log.Logger
-	mutex            sync.RWMutex
+	topics            map[string]Topic
+	externalTopics    map[string]*ExternalTopic
+	internalTopics    map[string]*InternalTopic
+	systemTopics      map[string]*SystemTopic
+	strategyExecutor  StrategyExecutor
+	stateManager      StateManager
+	executionRecorder ExecutionRecorder
+	eventRecorder     SystemEventRecorder
+	snapshotStore     SnapshotStore
+	mqttClient        MQTTPublisher
+	subscriber        Subscriber
+	minimalSubs       bool
+	logger            *log.Logger
+	clock             Clock
+	binaryPatterns    []string
+	ingressRules      []IngressStrategy
+	timestampRules    []PayloadTimestamp
+	missingInputs     MissingInputPolicy
+	maxNameLength     int
+	deadLetterTopic   string
+	dedupeRestart     bool
+	chainDeadline     time.Duration
+	writableTargets   []string
+	limiter           *executionLimiter
+	canonicalJSON     bool
+	warmup            warmupBuffer
+	normalizer        topicNormalizer
+	sinks             eventSinks
+	stats             systemStats
+	// systemStarted is set by StartSystemTopics; system topic tickers do not
+	// run before it
+	systemStarted bool
+	mutex         sync.RWMutex
 }
 
 func NewManager(logger *log.Logger) *Manager {
@@ -48,6 +85,9 @@ func NewManager(logger *log.Logger) *Manager {
 		internalTopics: make(map[string]*InternalTopic),
 		systemTopics:   make(map[string]*SystemTopic),
 		logger:         logger,
+		clock:          realClock{},
+		missingInputs:  MissingInputNil,
+		maxNameLength:  DefaultMaxTopicNameLength,
 	}
 }
 
@@ -59,18 +99,117 @@ func (m *Manager) SetStateManager(stateManager StateManager) {
 	m.stateManager = stateManager
 }
 
-func (m *Manager) SetMQTTClient(client *mqtt.Client) {
+func (m *Manager) SetMQTTClient(client MQTTPublisher) {
 	m.mqttClient = client
 }
 
-func (m *Manager) AddExternalTopic(name string) *ExternalTopic {
+// SetSubscriber sets how input patterns not covered by the MQTT subscriptions
+// are subscribed, and subscribes the inputs of existing internal topics
+func (m *Manager) SetSubscriber(subscriber Subscriber) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.subscriber = subscriber
+	if m.minimalSubs {
+		m.syncSubscriptionsUnsafe()
+		return
+	}
+	for _, topic := range m.internalTopics {
+		m.subscribeInputsUnsafe(topic.config.Inputs)
+	}
+}
+
+// subscribeInputsUnsafe subscribes to inputs that may arrive over MQTT. Inputs
+// matching internal or system topics are produced locally and skipped.
+// Assumes the lock is already held.
+func (m *Manager) subscribeInputsUnsafe(inputs []string) {
+	if m.subscriber == nil {
+		return
+	}
+	if m.minimalSubs {
+		// The whole set is re-derived so unused patterns are dropped too
+		m.syncSubscriptionsUnsafe()
+		return
+	}
+
+	for _, input := range inputs {
+		if !m.isLocalInputUnsafe(input) && m.subscriber.AddSubscription(input) {
+			m.logger.Printf("Subscribed to new input pattern: %s", input)
+		}
+	}
+}
+
+// isLocalInputUnsafe reports whether input matches an internal or system
+// topic, so its values are produced locally. Assumes the lock is already held.
+func (m *Manager) isLocalInputUnsafe(input string) bool {
+	for name, topic := range m.topics {
+		if topic.Type() != TopicTypeExternal && mqtt.TopicMatches(input, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetClock replaces the clock used for topic schedules (used by tests)
+func (m *Manager) SetClock(clock Clock) {
+	m.clock = clock
+}
+
+// SetBinaryTopicPatterns sets the MQTT topic patterns whose external topics
+// are created in binary mode
+func (m *Manager) SetBinaryTopicPatterns(patterns []string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.binaryPatterns = patterns
+}
+
+// SetMissingInputPolicy sets the default policy for internal topics whose
+// inputs do not exist yet. Topics can override it with their own policy.
+func (m *Manager) SetMissingInputPolicy(policy MissingInputPolicy) error {
+	parsed, err := ParseMissingInputPolicy(string(policy))
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.missingInputs = parsed
+	return nil
+}
+
+func (m *Manager) missingInputPolicy() MissingInputPolicy {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.missingInputs
+}
+
+func (m *Manager) isBinaryTopic(name string) bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	for _, pattern := range m.binaryPatterns {
+		if mqtt.TopicMatches(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Manager) AddExternalTopic(name string) (*ExternalTopic, error) {
 	m.mutex.Lock()
 	defer func() {
 		m.mutex.Unlock()
 	}()
 
 	if topic, exists := m.externalTopics[name]; exists {
-		return topic
+		return topic, nil
+	}
+
+	if err := ValidateTopicName(name, m.maxNameLength); err != nil {
+		return nil, err
 	}
 
 	topic := NewExternalTopic(name)
@@ -80,27 +219,78 @@ func (m *Manager) AddExternalTopic(name string) *ExternalTopic {
 	m.topics[name] = topic
 
 	m.logger.Printf("Added external topic: %s", name)
-	return topic
+	return topic, nil
+}
+
+// SetMaxTopicNameLength sets the longest accepted topic name (0 means unlimited)
+func (m *Manager) SetMaxTopicNameLength(maxLength int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.maxNameLength = maxLength
+}
+
+// ValidateTopicName checks a topic name against the naming rules and the
+// configured maximum length
+func (m *Manager) ValidateTopicName(name string) error {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return ValidateTopicName(name, m.maxNameLength)
+}
+
+// ValidateTopicInputs checks a topic's inputs against its strategy's input
+// limit and allowed input patterns
+func (m *Manager) ValidateTopicInputs(strategyID string, inputs []string) error {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.validateTopicInputs(strategyID, inputs)
+}
+
+func (m *Manager) validateTopicInputs(strategyID string, inputs []string) error {
+	// Validation requires the strategy executor to look up the strategy
+	if m.strategyExecutor == nil {
+		return nil
+	}
+
+	strategy, err := m.strategyExecutor.GetStrategy(strategyID)
+	if err != nil {
+		return nil
+	}
+
+	// Only validate if MaxInputs is set (non-zero), 0 or NULL means unlimited
+	if strategy.MaxInputs > 0 && len(inputs) > strategy.MaxInputs {
+		return fmt.Errorf("strategy %s allows maximum %d inputs, but %d inputs provided", strategyID, strategy.MaxInputs, len(inputs))
+	}
+
+	return strategy.ValidateInputs(inputs)
 }
 
 func (m *Manager) AddInternalTopic(name string, inputs []string, inputNames map[string]string, strategyID string, parameters map[string]interface{}, emitToMQTT bool, noOpUnchanged bool) (*InternalTopic, error) {
+	inputs, inputNames = m.normalizeInputs(inputs, inputNames)
+
 	m.mutex.Lock()
 	defer func() {
 		m.mutex.Unlock()
 	}()
 
+	if err := ValidateTopicName(name, m.maxNameLength); err != nil {
+		return nil, err
+	}
+
 	if _, exists := m.topics[name]; exists {
 		return nil, fmt.Errorf("topic %s already exists", name)
 	}
 
-	// Validate max inputs if strategy executor is available
-	if m.strategyExecutor != nil {
-		if strategy, err := m.strategyExecutor.GetStrategy(strategyID); err == nil {
-			// Only validate if MaxInputs is set (non-zero), 0 or NULL means unlimited
-			if strategy.MaxInputs > 0 && len(inputs) > strategy.MaxInputs {
-				return nil, fmt.Errorf("strategy %s allows maximum %d inputs, but %d inputs provided", strategyID, strategy.MaxInputs, len(inputs))
-			}
-		}
+	if err := m.validateTopicInputs(strategyID, inputs); err != nil {
+		return nil, err
+	}
+	if err := ValidateInputNames(inputs, inputNames); err != nil {
+		return nil, fmt.Errorf("topic %s: %w", name, err)
+	}
+	if cycle := m.findCycleUnsafe(name, topicDependencies{inputs: inputs, strategy: strategyID != ""}); cycle != nil {
+		return nil, fmt.Errorf("adding topic %s would create a dependency cycle: %s: %w", name, cycle, ErrDependencyCycle)
 	}
 
 	topic := NewInternalTopic(name, inputs, strategyID)
@@ -120,8 +310,11 @@ func (m *Manager) AddInternalTopic(name string, inputs []string, inputNames map[
 	topic.SetEmitToMQTT(emitToMQTT)
 	topic.SetNoOpUnchanged(noOpUnchanged)
 
+	topic.warnOverlappingWildcards()
+
 	m.internalTopics[name] = topic
 	m.topics[name] = topic
+	m.subscribeInputsUnsafe(inputs)
 
 	m.logger.Printf("Added internal topic: %s with inputs: %v (MQTT: %v, NoOp: %v)", name, inputs, emitToMQTT, noOpUnchanged)
 	return topic, nil
@@ -153,22 +346,35 @@ func (m *Manager) RemoveTopic(name string) error {
 		m.mutex.Unlock()
 	}()
 
+	return m.removeTopicUnsafe(name)
+}
+
+// removeTopicUnsafe removes a topic. Must be called with the manager's mutex
+// held.
+func (m *Manager) removeTopicUnsafe(name string) error {
 	topic, exists := m.topics[name]
 	if !exists {
 		return fmt.Errorf("topic %s not found", name)
 	}
 
-	// Stop system topics if running
+	// Stop system topics if running. An emit in flight is not waited for, as
+	// it needs the lock held here; it finishes on its own.
 	if systemTopic, ok := topic.(*SystemTopic); ok {
-		systemTopic.Stop()
+		systemTopic.halt()
 		delete(m.systemTopics, name)
 	} else if _, ok := topic.(*ExternalTopic); ok {
 		delete(m.externalTopics, name)
-	} else if _, ok := topic.(*InternalTopic); ok {
+	} else if internalTopic, ok := topic.(*InternalTopic); ok {
+		internalTopic.stopSchedule()
+		internalTopic.stopRepublish()
+		internalTopic.stopCoalesce()
 		delete(m.internalTopics, name)
 	}
 
 	delete(m.topics, name)
+	if m.minimalSubs {
+		m.syncSubscriptionsUnsafe()
+	}
 	m.logger.Printf("Removed topic: %s", name)
 	return nil
 }
@@ -182,6 +388,16 @@ func (m *Manager) GetTopic(name string) Topic {
 	return m.topics[name]
 }
 
+// TopicValue returns a topic's last value, and false if there is no such
+// topic. It lets strategies read topics that are not their inputs.
+func (m *Manager) TopicValue(name string) (interface{}, bool) {
+	topic := m.GetTopic(name)
+	if topic == nil {
+		return nil, false
+	}
+	return topic.LastValue(), true
+}
+
 func (m *Manager) GetExternalTopic(name string) *ExternalTopic {
 	m.mutex.RLock()
 	defer func() {
@@ -245,27 +461,37 @@ func (m *Manager) NotifyTopicUpdate(event TopicEvent) error {
 	// Skip noisy system topics like tickers, schedulers, etc.
 	// Note: We call shouldLogTopicUpdate while holding the lock to avoid double-locking
 	shouldLog := m.shouldLogTopicUpdateUnsafe(event.TopicName)
+	chainDeadline := m.chainDeadline
 
 	dependents := make([]*InternalTopic, 0)
 	for _, internalTopic := range m.internalTopics {
-		for _, input := range internalTopic.GetInputs() {
-			// Check for exact match or wildcard match
-			if input == event.TopicName || mqtt.TopicMatches(input, event.TopicName) {
-				dependents = append(dependents, internalTopic)
-				break
-			}
+		if event.Retained && internalTopic.IsIgnoreRetainedTrigger() {
+			continue
+		}
+		// Exact or wildcard matches of inputs that are not passive
+		if internalTopic.TriggeredBy(event.TopicName) {
+			dependents = append(dependents, internalTopic)
 		}
 	}
 	m.mutex.RUnlock()
 
+	m.publishToSinks(event)
+
 	// Log the topic update if needed (do this after releasing the lock)
 	if shouldLog {
 		m.logger.Printf("Topic update: %s = %v", event.TopicName, event.Value)
 	}
 
+	ctx, cancel := chainContext(event.ctx, chainDeadline)
+	defer cancel()
+
 	// Process dependent topics
-	for _, dependent := range dependents {
-		if err := dependent.ProcessInputs(event.TopicName); err != nil {
+	for i, dependent := range dependents {
+		if ctx.Err() != nil {
+			m.logChainDeadline(event.TopicName, dependents[i:])
+			break
+		}
+		if err := dependent.processInputsInChain(ctx, event.TopicName); err != nil {
 			m.logger.Printf("Error processing inputs for topic %s: %v", dependent.Name(), err)
 		}
 	}
@@ -273,6 +499,18 @@ func (m *Manager) NotifyTopicUpdate(event TopicEvent) error {
 	return nil
 }
 
+// EffectiveParameters returns the parameters a strategy receives for a topic:
+// the strategy defaults overlaid with the topic's own parameters
+func (m *Manager) EffectiveParameters(strategyID string, topicParameters map[string]interface{}) map[string]interface{} {
+	var defaults map[string]interface{}
+	if m.strategyExecutor != nil {
+		if strat, err := m.strategyExecutor.GetStrategy(strategyID); err == nil {
+			defaults = strat.Parameters
+		}
+	}
+	return strategy.MergeParameters(defaults, topicParameters)
+}
+
 func (m *Manager) ExecuteStrategy(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) ([]strategy.EmitEvent, error) {
 	if m.strategyExecutor == nil {
 		return nil, fmt.Errorf("strategy executor not configured")
@@ -298,6 +536,10 @@ func (m *Manager) SaveTopicState(topicName string, value interface{}) error {
 		} else {
 			stateKey = "internal:" + topicName
 		}
+		// Tag the value with its type so it can be migrated if the type changes
+		if valueType, ok := internalTopic.GetValueType(); ok && value != nil {
+			value = StoredValue{ValueType: valueType, Value: value}
+		}
 	} else if _, exists := m.systemTopics[topicName]; exists {
 		stateKey = "system:" + topicName
 	} else {
@@ -314,34 +556,38 @@ func (m *Manager) LoadTopicState(topicName string) (interface{}, error) {
 		return nil, fmt.Errorf("state manager not configured")
 	}
 
-	return m.stateManager.LoadTopicState(topicName)
+	state, err := m.stateManager.LoadTopicState(topicName)
+	if err != nil {
+		return nil, err
+	}
+	value, _ := UnwrapStoredValue(state)
+	return value, nil
 }
 
+// InitializeSystemTopics creates the default system topics without starting
+// their tickers. StartSystemTopics starts them once strategies and topics
+// have loaded, so no tick reaches a topic that is not ready for it.
 func (m *Manager) InitializeSystemTopics(cfg config.SystemTopicsConfig) error {
 	systemTopics := CreateDefaultSystemTopics(cfg)
 
 	for _, topic := range systemTopics {
 		m.AddSystemTopic(topic.Name(), topic.config.Config)
-
-		// Start ticker topics
-		if topic.config.Interval != "" {
-			if err := topic.Start(); err != nil {
-				m.logger.Printf("Failed to start system topic %s: %v", topic.Name(), err)
-			}
-		}
 	}
 
 	return nil
 }
 
+// StartSystemTopics starts the tickers of all system topics. System topics
+// reloaded from the database after this start straight away.
 func (m *Manager) StartSystemTopics() error {
-	m.mutex.RLock()
+	m.mutex.Lock()
 	defer func() {
-		m.mutex.RUnlock()
+		m.mutex.Unlock()
 	}()
 
+	m.systemStarted = true
 	for _, topic := range m.systemTopics {
-		if topic.config.Interval != "" && !topic.IsRunning() {
+		if topic.isScheduled() && !topic.IsRunning() {
 			if err := topic.Start(); err != nil {
 				m.logger.Printf("Failed to start system topic %s: %v", topic.Name(), err)
 			}
@@ -351,28 +597,66 @@ func (m *Manager) StartSystemTopics() error {
 	return nil
 }
 
+// StopSystemTopics stops the tickers of all system topics and waits for
+// emits in flight. They notify the manager, so they are waited for after
+// the lock is released.
 func (m *Manager) StopSystemTopics() {
-	m.mutex.RLock()
-	defer func() {
-		m.mutex.RUnlock()
-	}()
-
+	m.mutex.Lock()
+	m.systemStarted = false
+	var stopped []*scheduleRunner
 	for _, topic := range m.systemTopics {
-		if topic.IsRunning() {
-			topic.Stop()
+		if runner := topic.halt(); runner != nil {
+			stopped = append(stopped, runner)
 		}
 	}
+	m.mutex.Unlock()
+
+	for _, runner := range stopped {
+		runner.wait()
+	}
+}
+
+// StopSchedules stops the scheduled executions, periodic republishing and
+// pending coalesced executions of all internal topics
+func (m *Manager) StopSchedules() {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	for _, topic := range m.internalTopics {
+		topic.stopSchedule()
+		topic.stopRepublish()
+		topic.stopCoalesce()
+	}
 }
 
 func (m *Manager) HandleMQTTMessage(event mqtt.Event) error {
+	m.stats.recordMessage()
+	event.Topic = m.NormalizeTopic(event.Topic)
+	if m.bufferWarmupMessage(event) {
+		return nil
+	}
+	return m.processMQTTMessage(event)
+}
+
+func (m *Manager) processMQTTMessage(event mqtt.Event) error {
+	if !m.admitMQTTMessage(event.Topic) {
+		return nil
+	}
+
 	// Find or create external topic
 	topic := m.GetExternalTopic(event.Topic)
 	if topic == nil {
-		topic = m.AddExternalTopic(event.Topic)
+		var err error
+		if topic, err = m.AddExternalTopic(event.Topic); err != nil {
+			return fmt.Errorf("rejected MQTT topic: %w", err)
+		}
+		if m.isBinaryTopic(event.Topic) {
+			topic.SetBinary(true)
+		}
 	}
 
 	// Update topic with MQTT payload
-	return topic.UpdateFromMQTT(event.Payload)
+	return topic.UpdateFromMQTT(event.Payload, event.Retained)
 }
 
 func (m *Manager) GetTopicCount() map[TopicType]int {
@@ -418,6 +702,10 @@ func (m *Manager) shouldLogTopicUpdateUnsafe(topicName string) bool {
 			return false
 		}
 
+		if strings.HasPrefix(topicName, "system/stats/") {
+			return false
+		}
+
 		// Log other system topics by default (for now)
 		return true
 	}
@@ -426,19 +714,30 @@ func (m *Manager) shouldLogTopicUpdateUnsafe(topicName string) bool {
 	return false
 }
 
-// createOrUpdateDerivedTopic creates or updates a derived internal topic (from strategy emissions)
-func (m *Manager) createOrUpdateDerivedTopic(topicName string, value interface{}, emitToMQTT bool) error {
+// createOrUpdateDerivedTopic creates or updates a derived internal topic (from
+// strategy emissions). ctx is the propagation chain of the emission.
+func (m *Manager) createOrUpdateDerivedTopic(ctx context.Context, topicName string, value interface{}, emitToMQTT bool) error {
 	m.mutex.Lock()
 
 	// Check if topic already exists as an internal topic
 	if existingTopic, exists := m.internalTopics[topicName]; exists {
-		// Update existing derived internal topic directly
-		previousValue := existingTopic.config.LastValue
-		existingTopic.config.LastValue = value
-		existingTopic.config.LastUpdated = time.Now()
+		// Topics computed by a strategy are only written when allowed
+		derived := existingTopic.config.StrategyID == ""
+		if !derived && !m.isWritableTarget(topicName) {
+			m.mutex.Unlock()
+			return notWritableError(existingTopic)
+		}
 
-		// Update MQTT emission setting to match parent topic
-		existingTopic.config.EmitToMQTT = emitToMQTT
+		// Update existing internal topic directly
+		previousValue := existingTopic.setValue(value, time.Now())
+
+		// Derived topics follow the parent's MQTT emission setting; writable
+		// targets keep their own
+		if derived {
+			existingTopic.config.EmitToMQTT = emitToMQTT
+		} else {
+			emitToMQTT = existingTopic.config.EmitToMQTT
+		}
 
 		// Determine state key while holding lock
 		var stateKey string
@@ -460,7 +759,7 @@ func (m *Manager) createOrUpdateDerivedTopic(topicName string, value interface{}
 
 		// Emit to MQTT if enabled (no lock needed for MQTT client)
 		if emitToMQTT {
-			if err := existingTopic.emitToMQTT(value); err != nil {
+			if err := existingTopic.emitToMQTT(value, ""); err != nil {
 				return fmt.Errorf("failed to emit to MQTT: %w", err)
 			}
 		}
@@ -472,6 +771,7 @@ func (m *Manager) createOrUpdateDerivedTopic(topicName string, value interface{}
 			PreviousValue: previousValue,
 			Timestamp:     time.Now(),
 			TriggerTopic:  topicName,
+			ctx:           ctx,
 		}
 
 		if err := m.NotifyTopicUpdate(event); err != nil {
@@ -484,10 +784,15 @@ func (m *Manager) createOrUpdateDerivedTopic(topicName string, value interface{}
 	// Continue with topic creation (lock is still held)
 	// Note: We manually unlock before NotifyTopicUpdate to prevent deadlock
 
-	// Check if topic exists in the main topics map
-	if _, exists := m.topics[topicName]; exists {
-		// Topic exists but not as internal - this shouldn't happen for derived topics
-		return fmt.Errorf("topic %s already exists as a different type", topicName)
+	// Existing external topics are written when allowed; system topics never
+	if existingTopic, exists := m.topics[topicName]; exists {
+		externalTopic, isExternal := existingTopic.(*ExternalTopic)
+		writable := isExternal && m.isWritableTarget(topicName)
+		m.mutex.Unlock()
+		if !writable {
+			return notWritableError(existingTopic)
+		}
+		return externalTopic.writeValue(ctx, value)
 	}
 
 	// Create new derived internal topic (read-only, no strategy)
@@ -526,6 +831,13 @@ func (m *Manager) createOrUpdateDerivedTopic(topicName string, value interface{}
 		}
 	}
 
+	// Emit to MQTT if enabled, so the first value is published like updates
+	if emitToMQTT {
+		if err := newTopic.emitToMQTT(value, ""); err != nil {
+			return fmt.Errorf("failed to emit to MQTT: %w", err)
+		}
+	}
+
 	// Notify other topics that might depend on this new derived topic
 	event := TopicEvent{
 		TopicName:     topicName,
@@ -533,6 +845,7 @@ func (m *Manager) createOrUpdateDerivedTopic(topicName string, value interface{}
 		PreviousValue: nil,
 		Timestamp:     time.Now(),
 		TriggerTopic:  topicName,
+		ctx:           ctx,
 	}
 
 	if err := m.NotifyTopicUpdate(event); err != nil {
@@ -554,16 +867,30 @@ func (m *Manager) ReloadTopicFromDatabase(topicName string) error {
 		return fmt.Errorf("failed to load topic config from database: %w", err)
 	}
 
+	// A running system topic is stopped before taking the lock, since its
+	// emit in flight needs the lock to finish
+	if _, ok := configInterface.(SystemTopicConfig); ok {
+		if existingTopic := m.GetSystemTopic(topicName); existingTopic != nil {
+			existingTopic.Stop()
+		}
+	}
+
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
 	// Handle different topic types
 	switch cfg := configInterface.(type) {
 	case InternalTopicConfig:
+		cfg.Inputs, cfg.InputNames = m.normalizeInputs(cfg.Inputs, cfg.InputNames)
+		if cycle := m.findCycleUnsafe(topicName, topicDependencies{inputs: cfg.Inputs, strategy: cfg.StrategyID != ""}); cycle != nil {
+			return fmt.Errorf("reloading topic %s would create a dependency cycle: %s: %w", topicName, cycle, ErrDependencyCycle)
+		}
+
 		// Update existing internal topic or create new one
 		if existingTopic, exists := m.internalTopics[topicName]; exists {
 			// Update existing topic
 			existingTopic.UpdateConfig(cfg)
+			m.subscribeInputsUnsafe(cfg.Inputs)
 			m.logger.Printf("Reloaded internal topic from database: %s", topicName)
 		} else {
 			// Create new internal topic
@@ -571,8 +898,11 @@ func (m *Manager) ReloadTopicFromDatabase(topicName string) error {
 				config:  cfg,
 				manager: m,
 			}
+			newTopic.restartSchedule()
+			newTopic.restartRepublish()
 			m.internalTopics[topicName] = newTopic
 			m.topics[topicName] = newTopic
+			m.subscribeInputsUnsafe(cfg.Inputs)
 			m.logger.Printf("Created new internal topic from database: %s", topicName)
 		}
 
@@ -597,14 +927,12 @@ func (m *Manager) ReloadTopicFromDatabase(topicName string) error {
 	case SystemTopicConfig:
 		// Update existing system topic or create new one
 		if existingTopic, exists := m.systemTopics[topicName]; exists {
-			// Stop existing topic before updating
-			if existingTopic.IsRunning() {
-				existingTopic.Stop()
-			}
+			// Stop it again in case it was restarted since
+			existingTopic.halt()
 			// Update existing topic
 			existingTopic.UpdateConfig(cfg)
-			// Restart if it has an interval
-			if cfg.Interval != "" {
+			// Restart if it has a schedule and system topics are running
+			if existingTopic.isScheduled() && m.systemStarted {
 				if startErr := existingTopic.Start(); startErr != nil {
 					m.logger.Printf("Failed to restart system topic %s: %v", topicName, startErr)
 				}
@@ -616,8 +944,8 @@ func (m *Manager) ReloadTopicFromDatabase(topicName string) error {
 			newTopic.SetManager(m)
 			m.systemTopics[topicName] = newTopic
 			m.topics[topicName] = newTopic
-			// Start if it has an interval
-			if cfg.Interval != "" {
+			// Start if it has a schedule and system topics are running
+			if newTopic.isScheduled() && m.systemStarted {
 				if startErr := newTopic.Start(); startErr != nil {
 					m.logger.Printf("Failed to start new system topic %s: %v", topicName, startErr)
 				}
@@ -660,8 +988,27 @@ func (m *Manager) GetExternalTopics() []BaseTopicConfig {
 	return externalTopics
 }
 
+// SetDedupeAcrossRestart controls whether the first MQTT message for an
+// external topic after its value is restored is ignored when it repeats the
+// restored value, so retained replays don't trigger dependents on startup
+func (m *Manager) SetDedupeAcrossRestart(dedupe bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.dedupeRestart = dedupe
+}
+
+// DedupeAcrossRestart reports whether retained replays of restored values are ignored
+func (m *Manager) DedupeAcrossRestart() bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.dedupeRestart
+}
+
 // RestoreTopicStatesFromDatabase restores last known values for all topics from the state database
-// This should be called after loading topic configurations but before processing any events
+// This must be called after loading topic configurations but before the MQTT
+// client connects, so retained replays are compared against restored values
 func (m *Manager) RestoreTopicStatesFromDatabase() error {
 	if m.stateManager == nil {
 		return nil
@@ -694,7 +1041,9 @@ func (m *Manager) RestoreTopicStatesFromDatabase() error {
 
 	restoredCount := 0
 	skippedCount := 0
-	for stateKey, value := range savedStates {
+	for stateKey, state := range savedStates {
+		value, storedType := UnwrapStoredValue(state)
+
 		// Parse the state key to determine topic type and name
 		var topicType, topicName string
 		if strings.HasPrefix(stateKey, "external:") {
@@ -744,9 +1093,16 @@ func (m *Manager) RestoreTopicStatesFromDatabase() error {
 			case *ExternalTopic:
 				t.config.LastValue = value
 				t.config.LastUpdated = time.Now()
+				t.restored = true
 				restoredCount++
 			case *InternalTopic:
-				t.config.LastValue = value
+				migrated, err := t.migrateStoredValue(value, storedType)
+				if err != nil {
+					m.logger.Printf("Warning: discarding stored value of %s: %v", topicName, err)
+					skippedCount++
+					continue
+				}
+				t.config.LastValue = migrated
 				t.config.LastUpdated = time.Now()
 				restoredCount++
 			case *SystemTopic:
@@ -758,9 +1114,15 @@ func (m *Manager) RestoreTopicStatesFromDatabase() error {
 			// Topic not in memory - create based on type
 			switch topicType {
 			case "external":
-				externalTopic := m.AddExternalTopic(topicName)
+				externalTopic, err := m.AddExternalTopic(topicName)
+				if err != nil {
+					m.logger.Printf("Skipping restore of external topic %s: %v", topicName, err)
+					skippedCount++
+					continue
+				}
 				externalTopic.config.LastValue = value
 				externalTopic.config.LastUpdated = time.Now()
+				externalTopic.restored = true
 				m.logger.Printf("Restored external topic: %s", topicName)
 				restoredCount++
 			case "child":
@@ -798,5 +1160,7 @@ func (m *Manager) RestoreTopicStatesFromDatabase() error {
 	}
 
 	m.logger.Printf("Restored state for %d topics (%d skipped)", restoredCount, skippedCount)
-	return nil
+
+	// Snapshots are written on every emit, so they override the last values
+	return m.restoreSnapshots()
 }
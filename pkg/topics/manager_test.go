@@ -1,12 +1,16 @@
 package topics
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/denwilliams/go-mqtt-automation/pkg/mqtt"
 	"github.com/denwilliams/go-mqtt-automation/pkg/strategy"
 )
 
@@ -142,13 +146,85 @@ func TestNewManagerWithCustomLogger(t *testing.T) {
 	}
 }
 
-func TestAddExternalTopic(t *testing.T) {
+// mustAddExternalTopic adds an external topic, failing the test on error
+func mustAddExternalTopic(t testing.TB, manager *Manager, name string) *ExternalTopic {
+	t.Helper()
+
+	topic, err := manager.AddExternalTopic(name)
+	if err != nil {
+		t.Fatalf("AddExternalTopic(%q) failed: %v", name, err)
+	}
+	return topic
+}
+
+func TestValidateTopicName(t *testing.T) {
+	tests := []struct {
+		name      string
+		topicName string
+		maxLength int
+		wantErr   string
+	}{
+		{name: "valid", topicName: "home/living-room/temperature_1", maxLength: 256},
+		{name: "valid unicode", topicName: "maison/salle à manger", maxLength: 256},
+		{name: "empty", topicName: "", wantErr: "required"},
+		{name: "single-level wildcard", topicName: "sensors/+/temp", wantErr: "wildcards"},
+		{name: "multi-level wildcard", topicName: "sensors/#", wantErr: "wildcards"},
+		{name: "leading slash", topicName: "/sensors/temp", wantErr: "start or end with /"},
+		{name: "trailing slash", topicName: "sensors/temp/", wantErr: "start or end with /"},
+		{name: "control character", topicName: "sensors/\ttemp", wantErr: "control characters"},
+		{name: "null byte", topicName: "sensors/temp\x00", wantErr: "control characters"},
+		{name: "invalid utf-8", topicName: "sensors/\xfftemp", wantErr: "UTF-8"},
+		{name: "too long", topicName: strings.Repeat("a", 11), maxLength: 10, wantErr: "maximum of 10"},
+		{name: "unlimited length", topicName: strings.Repeat("a", 1000), maxLength: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTopicName(tt.topicName, tt.maxLength)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateTopicName() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ValidateTopicName() error = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAddTopicRejectsInvalidNames(t *testing.T) {
 	manager := NewManager(nil)
+	manager.SetMaxTopicNameLength(20)
 
-	topic := manager.AddExternalTopic("sensors/temperature")
+	for _, name := range []string{"", "sensors/#", "/sensors/temp", "sensors/\ntemp", "sensors/living-room/temperature"} {
+		if _, err := manager.AddExternalTopic(name); err == nil {
+			t.Errorf("AddExternalTopic(%q) should fail", name)
+		}
+		if _, err := manager.AddInternalTopic(name, nil, nil, "", nil, false, false); err == nil {
+			t.Errorf("AddInternalTopic(%q) should fail", name)
+		}
+	}
+	if len(manager.topics) != 0 {
+		t.Errorf("invalid topics were added: %v", manager.topics)
+	}
 
-	if topic == nil {
-		t.Fatal("AddExternalTopic() returned nil")
+	if err := manager.HandleMQTTMessage(mqtt.Event{Topic: "/sensors/temp", Payload: []byte("1")}); err == nil {
+		t.Error("HandleMQTTMessage should reject an invalid topic name")
+	}
+
+	if _, err := manager.AddExternalTopic("sensors/temp"); err != nil {
+		t.Errorf("AddExternalTopic of a valid name failed: %v", err)
+	}
+}
+
+func TestAddExternalTopic(t *testing.T) {
+	manager := NewManager(nil)
+
+	topic, err := manager.AddExternalTopic("sensors/temperature")
+	if err != nil {
+		t.Fatalf("AddExternalTopic() failed: %v", err)
 	}
 
 	if topic.Name() != "sensors/temperature" {
@@ -169,7 +245,7 @@ func TestAddExternalTopic(t *testing.T) {
 	}
 
 	// Adding same topic should return existing one
-	topic2 := manager.AddExternalTopic("sensors/temperature")
+	topic2, _ := manager.AddExternalTopic("sensors/temperature")
 	if topic2 != topic {
 		t.Error("AddExternalTopic() should return existing topic for same name")
 	}
@@ -221,6 +297,142 @@ func TestAddInternalTopic(t *testing.T) {
 	}
 }
 
+func TestAddInternalTopicAllowedInputPatterns(t *testing.T) {
+	manager := NewManager(nil)
+
+	engine := strategy.NewEngine(nil)
+	if err := engine.AddStrategy(&strategy.Strategy{
+		ID:                   "thermostat",
+		Name:                 "Thermostat",
+		Code:                 "function process(context) { return null; }",
+		Language:             "javascript",
+		AllowedInputPatterns: []string{"sensors/+/temperature"},
+	}); err != nil {
+		t.Fatalf("Failed to add strategy: %v", err)
+	}
+	manager.SetStrategyExecutor(engine)
+
+	if _, err := manager.AddInternalTopic("heating/kitchen", []string{"sensors/kitchen/temperature"}, nil, "thermostat", nil, false, false); err != nil {
+		t.Errorf("Expected allowed input to be accepted, got: %v", err)
+	}
+
+	_, err := manager.AddInternalTopic("heating/lounge", []string{"sensors/lounge/temperature", "sensors/lounge/humidity"}, nil, "thermostat", nil, false, false)
+	if err == nil {
+		t.Fatal("Expected disallowed input to be rejected")
+	}
+	if !strings.Contains(err.Error(), "sensors/lounge/humidity is not allowed") {
+		t.Errorf("Error should name the disallowed input, got: %v", err)
+	}
+	if manager.GetTopic("heating/lounge") != nil {
+		t.Error("Rejected topic should not be added")
+	}
+
+	if err := manager.ValidateTopicInputs("thermostat", []string{"lights/kitchen"}); err == nil {
+		t.Error("ValidateTopicInputs should reject a disallowed input")
+	}
+}
+
+// recordingSubscriber records the patterns the manager asks to subscribe to
+type recordingSubscriber struct {
+	patterns []string
+}
+
+func (r *recordingSubscriber) AddSubscription(pattern string) bool {
+	r.patterns = append(r.patterns, pattern)
+	return true
+}
+
+func TestAddInternalTopicSubscribesInputs(t *testing.T) {
+	manager := NewManager(nil)
+	subscriber := &recordingSubscriber{}
+
+	// Inputs of topics that exist before the subscriber is set are subscribed too
+	if _, err := manager.AddInternalTopic("calculated/average", []string{"sensors/temp"}, nil, "average", nil, false, false); err != nil {
+		t.Fatalf("AddInternalTopic failed: %v", err)
+	}
+	manager.SetSubscriber(subscriber)
+	if !reflect.DeepEqual(subscriber.patterns, []string{"sensors/temp"}) {
+		t.Fatalf("subscriptions after SetSubscriber = %v, want [sensors/temp]", subscriber.patterns)
+	}
+
+	// A novel wildcard input is subscribed; an internal topic input is not
+	subscriber.patterns = nil
+	if _, err := manager.AddInternalTopic("alerts/battery", []string{"zigbee/+/battery", "calculated/average"}, nil, "alias", nil, false, false); err != nil {
+		t.Fatalf("AddInternalTopic failed: %v", err)
+	}
+	if !reflect.DeepEqual(subscriber.patterns, []string{"zigbee/+/battery"}) {
+		t.Errorf("subscriptions = %v, want [zigbee/+/battery]", subscriber.patterns)
+	}
+}
+
+// recordingSetter records the subscription sets the manager derives
+type recordingSetter struct {
+	recordingSubscriber
+	sets [][]string
+}
+
+func (r *recordingSetter) SetSubscriptions(patterns []string) {
+	r.sets = append(r.sets, patterns)
+}
+
+func (r *recordingSetter) current() []string {
+	if len(r.sets) == 0 {
+		return nil
+	}
+	return r.sets[len(r.sets)-1]
+}
+
+func TestMinimalSubscriptions(t *testing.T) {
+	manager := NewManager(nil)
+	setter := &recordingSetter{}
+	manager.SetMinimalSubscriptions(true)
+	manager.SetSubscriber(setter)
+
+	if got := setter.current(); len(got) != 0 {
+		t.Fatalf("subscriptions with no topics = %v, want none", got)
+	}
+
+	if _, err := manager.AddInternalTopic("house/temp", []string{"sensors/kitchen/temp", "sensors/lounge/temp"}, nil, "average", nil, false, false); err != nil {
+		t.Fatalf("AddInternalTopic failed: %v", err)
+	}
+	if _, err := manager.AddInternalTopic("house/alert", []string{"house/temp", "zigbee/+/battery"}, nil, "alias", nil, false, false); err != nil {
+		t.Fatalf("AddInternalTopic failed: %v", err)
+	}
+	want := []string{"sensors/kitchen/temp", "sensors/lounge/temp", "zigbee/+/battery"}
+	if got := setter.current(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("subscriptions = %v, want %v", got, want)
+	}
+
+	// A broader input replaces the patterns it covers
+	if _, err := manager.AddInternalTopic("house/any", []string{"sensors/+/temp"}, nil, "alias", nil, false, false); err != nil {
+		t.Fatalf("AddInternalTopic failed: %v", err)
+	}
+	want = []string{"sensors/+/temp", "zigbee/+/battery"}
+	if got := setter.current(); !reflect.DeepEqual(got, want) {
+		t.Errorf("subscriptions with a broader input = %v, want %v", got, want)
+	}
+
+	// Removing topics drops the patterns only they used
+	if err := manager.RemoveTopic("house/any"); err != nil {
+		t.Fatalf("RemoveTopic failed: %v", err)
+	}
+	if err := manager.RemoveTopic("house/alert"); err != nil {
+		t.Fatalf("RemoveTopic failed: %v", err)
+	}
+	want = []string{"sensors/kitchen/temp", "sensors/lounge/temp"}
+	if got := setter.current(); !reflect.DeepEqual(got, want) {
+		t.Errorf("subscriptions after removal = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(manager.RequiredSubscriptions(), want) {
+		t.Errorf("RequiredSubscriptions() = %v, want %v", manager.RequiredSubscriptions(), want)
+	}
+
+	// Minimal subscriptions never add individual patterns
+	if len(setter.patterns) != 0 {
+		t.Errorf("AddSubscription called with %v", setter.patterns)
+	}
+}
+
 func TestAddSystemTopic(t *testing.T) {
 	manager := NewManager(nil)
 
@@ -263,7 +475,7 @@ func TestRemoveTopic(t *testing.T) {
 	manager := NewManager(nil)
 
 	// Add topics of different types
-	extTopic := manager.AddExternalTopic("sensors/temp")
+	extTopic := mustAddExternalTopic(t, manager, "sensors/temp")
 	intTopic, _ := manager.AddInternalTopic("calc/avg", []string{"input"}, nil, "strategy", nil, false, false)
 	sysTopic := manager.AddSystemTopic("system/test", map[string]interface{}{})
 
@@ -316,7 +528,7 @@ func TestGetTopic(t *testing.T) {
 	manager := NewManager(nil)
 
 	// Add test topic
-	added := manager.AddExternalTopic("test/topic")
+	added := mustAddExternalTopic(t, manager, "test/topic")
 
 	// Get existing topic
 	retrieved := manager.GetTopic("test/topic")
@@ -418,7 +630,7 @@ func TestNotifyTopicUpdate(t *testing.T) {
 	manager.SetStrategyExecutor(mockExec)
 
 	// Add external topic (source) and set its value
-	extTopic := manager.AddExternalTopic("sensors/temp")
+	extTopic := mustAddExternalTopic(t, manager, "sensors/temp")
 	extTopic.Emit(25.5) // Set the value first
 
 	// Add internal topic that depends on the external topic
@@ -528,6 +740,68 @@ func TestExecuteStrategy(t *testing.T) {
 	}
 }
 
+func TestEffectiveParameters(t *testing.T) {
+	manager := NewManager(nil)
+
+	engine := strategy.NewEngine(nil)
+	if err := engine.AddStrategy(&strategy.Strategy{
+		ID:       "dimmer",
+		Name:     "Dimmer",
+		Code:     "function process(context) { return null; }",
+		Language: "javascript",
+		Parameters: map[string]interface{}{
+			"brightness": 50,
+			"transition": 2,
+		},
+	}); err != nil {
+		t.Fatalf("Failed to add strategy: %v", err)
+	}
+	manager.SetStrategyExecutor(engine)
+
+	tests := []struct {
+		name      string
+		overrides map[string]interface{}
+		expected  map[string]interface{}
+	}{
+		{
+			name:      "defaults only",
+			overrides: nil,
+			expected:  map[string]interface{}{"brightness": 50, "transition": 2},
+		},
+		{
+			name:      "topic overrides default",
+			overrides: map[string]interface{}{"brightness": 80},
+			expected:  map[string]interface{}{"brightness": 80, "transition": 2},
+		},
+		{
+			name:      "topic adds parameter",
+			overrides: map[string]interface{}{"room": "kitchen"},
+			expected:  map[string]interface{}{"brightness": 50, "transition": 2, "room": "kitchen"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			effective := manager.EffectiveParameters("dimmer", tt.overrides)
+			if !reflect.DeepEqual(effective, tt.expected) {
+				t.Errorf("EffectiveParameters() = %v, want %v", effective, tt.expected)
+			}
+		})
+	}
+
+	// Merging must not modify the strategy defaults
+	strat, _ := engine.GetStrategy("dimmer")
+	if strat.Parameters["brightness"] != 50 {
+		t.Errorf("strategy defaults were modified: %v", strat.Parameters)
+	}
+
+	// Unknown strategies fall back to the topic parameters
+	effective := manager.EffectiveParameters("missing", map[string]interface{}{"a": 1})
+	if !reflect.DeepEqual(effective, map[string]interface{}{"a": 1}) {
+		t.Errorf("EffectiveParameters() for unknown strategy = %v", effective)
+	}
+}
+
 func TestSaveTopicState(t *testing.T) {
 	manager := NewManager(nil)
 
@@ -673,7 +947,7 @@ func TestInternalTopicTriggering(t *testing.T) {
 	}
 
 	// Create external topic to trigger the chain
-	batteryTopic := manager.AddExternalTopic("sensors/battery_level")
+	batteryTopic := mustAddExternalTopic(t, manager, "sensors/battery_level")
 	err = batteryTopic.Emit(25.0)
 	if err != nil {
 		t.Fatalf("Failed to emit to external topic: %v", err)
@@ -756,7 +1030,7 @@ func TestDerivedTopicCreationAndTriggering(t *testing.T) {
 	}
 
 	// Trigger the chain
-	dataTopic := manager.AddExternalTopic("sensors/raw_data")
+	dataTopic := mustAddExternalTopic(t, manager, "sensors/raw_data")
 	err = dataTopic.Emit("test-data")
 	if err != nil {
 		t.Fatalf("Failed to emit to trigger topic: %v", err)
@@ -842,7 +1116,7 @@ func TestDerivedTopicUpdateTriggering(t *testing.T) {
 	}
 
 	// First trigger - create external topic and trigger update
-	triggerTopic := manager.AddExternalTopic("trigger")
+	triggerTopic := mustAddExternalTopic(t, manager, "trigger")
 	err = triggerTopic.Emit("first")
 	if err != nil {
 		t.Fatalf("Failed to trigger first time: %v", err)
@@ -891,3 +1165,49 @@ func TestDerivedTopicUpdateTriggering(t *testing.T) {
 		t.Errorf("Last input source/output = %v, want 'output-1'", inputMap["source/output"])
 	}
 }
+
+func TestDerivedTopicConflictReleasesLock(t *testing.T) {
+	manager := NewManager(nil)
+	manager.AddSystemTopic("system/heartbeat", map[string]interface{}{"interval": "1h"})
+
+	if err := manager.createOrUpdateDerivedTopic(context.Background(), "system/heartbeat", "on", false); err == nil {
+		t.Fatal("expected an error creating a derived topic over a system topic")
+	}
+
+	// The manager must still be usable after the rejected write
+	done := make(chan error, 1)
+	go func() {
+		_, err := manager.AddExternalTopic("sensors/motion")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("AddExternalTopic failed: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("manager lock was not released after the rejected write")
+	}
+}
+
+func TestDerivedTopicCreationPublishesToMQTT(t *testing.T) {
+	for _, emitToMQTT := range []bool{true, false} {
+		t.Run(fmt.Sprintf("emit_to_mqtt=%v", emitToMQTT), func(t *testing.T) {
+			manager := NewManager(nil)
+			publisher := &mockPublisher{}
+			manager.SetMQTTClient(publisher)
+
+			// The first value of a new derived topic is published like later ones
+			if err := manager.createOrUpdateDerivedTopic(context.Background(), "automation/status", "idle", emitToMQTT); err != nil {
+				t.Fatalf("createOrUpdateDerivedTopic failed: %v", err)
+			}
+			payload, published := publisher.published["automation/status"]
+			if published != emitToMQTT {
+				t.Fatalf("published = %v, want %v", published, emitToMQTT)
+			}
+			if published && string(payload) != `"idle"` {
+				t.Errorf("payload = %s, want \"idle\"", payload)
+			}
+		})
+	}
+}
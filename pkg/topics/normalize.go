@@ -0,0 +1,94 @@
+package topics
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/mqtt"
+)
+
+// TopicNormalization rewrites MQTT topic names under a prefix, so devices
+// sending inconsistent casing or stray whitespace map to a single topic
+type TopicNormalization struct {
+	// Prefix selects the topics the rule applies to; empty matches all. It is
+	// matched after the rule's own normalization, so "zigbee2mqtt/" also
+	// matches " Zigbee2MQTT/..." when trimming and lowercasing.
+	Prefix    string
+	Trim      bool // trim whitespace around each topic level
+	Lowercase bool
+}
+
+func (n TopicNormalization) apply(topic string) string {
+	if n.Trim {
+		levels := strings.Split(topic, "/")
+		for i, level := range levels {
+			levels[i] = strings.TrimSpace(level)
+		}
+		topic = strings.Join(levels, "/")
+	}
+	if n.Lowercase {
+		topic = strings.ToLower(topic)
+	}
+	return topic
+}
+
+// topicNormalizer holds the normalization rules. It has its own lock so
+// topics can be normalized while the manager lock is held.
+type topicNormalizer struct {
+	mutex sync.RWMutex
+	rules []TopicNormalization
+}
+
+// SetTopicNormalization sets the rules applied to MQTT topic names. The
+// first rule whose prefix matches a topic is applied.
+func (m *Manager) SetTopicNormalization(rules []TopicNormalization) {
+	m.normalizer.mutex.Lock()
+	defer m.normalizer.mutex.Unlock()
+
+	m.normalizer.rules = append([]TopicNormalization(nil), rules...)
+}
+
+// NormalizeTopic returns the normalized form of an MQTT topic name or
+// pattern. Inbound messages, internal topic inputs and published topics are
+// all normalized, so a topic is known by the same name in every direction.
+// The $share/{group}/ prefix of a shared subscription is removed, so it maps
+// to the topics it receives.
+func (m *Manager) NormalizeTopic(topic string) string {
+	topic = mqtt.SubscriptionFilter(topic)
+
+	m.normalizer.mutex.RLock()
+	defer m.normalizer.mutex.RUnlock()
+
+	for _, rule := range m.normalizer.rules {
+		normalized := rule.apply(topic)
+		prefix := rule.apply(rule.Prefix)
+		if strings.HasPrefix(normalized, prefix) {
+			return normalized
+		}
+	}
+	return topic
+}
+
+// normalizeInputs normalizes input topics and the keys of their input names
+func (m *Manager) normalizeInputs(inputs []string, inputNames map[string]string) ([]string, map[string]string) {
+	m.normalizer.mutex.RLock()
+	enabled := len(m.normalizer.rules) > 0
+	m.normalizer.mutex.RUnlock()
+	if !enabled {
+		return inputs, inputNames
+	}
+
+	normalizedInputs := make([]string, len(inputs))
+	for i, input := range inputs {
+		normalizedInputs[i] = m.NormalizeTopic(input)
+	}
+	if inputNames == nil {
+		return normalizedInputs, nil
+	}
+
+	normalizedNames := make(map[string]string, len(inputNames))
+	for input, name := range inputNames {
+		normalizedNames[m.NormalizeTopic(input)] = name
+	}
+	return normalizedInputs, normalizedNames
+}
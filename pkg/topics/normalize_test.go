@@ -0,0 +1,114 @@
+package topics
+
+import (
+	"testing"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/mqtt"
+)
+
+func TestNormalizeTopic(t *testing.T) {
+	manager := NewManager(nil)
+	manager.SetTopicNormalization([]TopicNormalization{
+		{Prefix: "zigbee/", Trim: true, Lowercase: true},
+		{Prefix: "sensors/", Trim: true},
+	})
+
+	tests := []struct {
+		topic string
+		want  string
+	}{
+		{topic: "Zigbee/Kitchen/Temp ", want: "zigbee/kitchen/temp"},
+		{topic: " zigbee / hall /temp", want: "zigbee/hall/temp"},
+		{topic: "sensors/Kitchen /Temp", want: "sensors/Kitchen/Temp"},
+		{topic: "Sensors/kitchen", want: "Sensors/kitchen"}, // the trim-only rule is case-sensitive
+		{topic: "other/Topic ", want: "other/Topic "},
+		{topic: "$share/automation/Zigbee/Hall", want: "zigbee/hall"}, // shared subscription prefix is removed
+		{topic: "$share/automation/#", want: "#"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.topic, func(t *testing.T) {
+			if got := manager.NormalizeTopic(tt.topic); got != tt.want {
+				t.Errorf("NormalizeTopic(%q) = %q, want %q", tt.topic, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandleMQTTMessageNormalizesTopics(t *testing.T) {
+	manager := NewManager(nil)
+	manager.SetTopicNormalization([]TopicNormalization{{Trim: true, Lowercase: true}})
+
+	for _, topic := range []string{"Sensors/Kitchen/Temp", "sensors/kitchen/temp ", " SENSORS/kitchen/TEMP"} {
+		if err := manager.HandleMQTTMessage(mqtt.Event{Topic: topic, Payload: []byte("21.5")}); err != nil {
+			t.Fatalf("HandleMQTTMessage(%q) failed: %v", topic, err)
+		}
+	}
+
+	if count := manager.GetTopicCount()[TopicTypeExternal]; count != 1 {
+		t.Errorf("got %d external topics, want 1", count)
+	}
+	if topic := manager.GetExternalTopic("sensors/kitchen/temp"); topic == nil || topic.LastValue() != 21.5 {
+		t.Errorf("normalized external topic = %v", topic)
+	}
+}
+
+func TestSharedSubscriptionMapsToBareTopic(t *testing.T) {
+	manager := NewManager(nil)
+
+	subscription := "$share/automation/sensors/kitchen/temp"
+	if err := manager.HandleMQTTMessage(mqtt.Event{Topic: subscription, Payload: []byte("21.5")}); err != nil {
+		t.Fatalf("HandleMQTTMessage failed: %v", err)
+	}
+
+	if topic := manager.GetExternalTopic("sensors/kitchen/temp"); topic == nil || topic.LastValue() != 21.5 {
+		t.Errorf("external topic sensors/kitchen/temp = %v", topic)
+	}
+	if topic := manager.GetExternalTopic(subscription); topic != nil {
+		t.Errorf("external topic created under shared subscription name %s", subscription)
+	}
+}
+
+func TestNormalizedInputsAndPublish(t *testing.T) {
+	manager := NewManager(nil)
+	manager.SetTopicNormalization([]TopicNormalization{{Prefix: "house/", Trim: true, Lowercase: true}})
+	publisher := &mockPublisher{}
+	manager.SetMQTTClient(publisher)
+	manager.SetStrategyExecutor(&mockStrategyExecutor{
+		executeFunc: func(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+			return inputs["temp"], nil
+		},
+	})
+
+	// Inputs and their names are normalized so they match inbound topics
+	topic, err := manager.AddInternalTopic("Comfort/Living", []string{"House/Living/Temp "}, map[string]string{"House/Living/Temp ": "temp"}, "test", nil, true, false)
+	if err != nil {
+		t.Fatalf("Failed to add internal topic: %v", err)
+	}
+	if inputs := topic.GetInputs(); len(inputs) != 1 || inputs[0] != "house/living/temp" {
+		t.Errorf("inputs = %v, want normalized", inputs)
+	}
+
+	if err := manager.HandleMQTTMessage(mqtt.Event{Topic: "HOUSE/living/temp", Payload: []byte("20")}); err != nil {
+		t.Fatalf("HandleMQTTMessage failed: %v", err)
+	}
+	if topic.LastValue() != 20.0 {
+		t.Errorf("internal topic value = %v, want 20", topic.LastValue())
+	}
+	if _, ok := publisher.published["Comfort/Living"]; !ok {
+		t.Errorf("published %v, want Comfort/Living (outside the normalized prefix)", publisher.published)
+	}
+
+	// Published topics under a normalized prefix are normalized too
+	publisher.published = nil
+	mirror, err := manager.AddInternalTopic("House/Mirror", []string{"house/living/temp"}, map[string]string{"house/living/temp": "temp"}, "test", nil, true, false)
+	if err != nil {
+		t.Fatalf("Failed to add internal topic: %v", err)
+	}
+	if err := mirror.ProcessInputs("house/living/temp"); err != nil {
+		t.Fatalf("ProcessInputs failed: %v", err)
+	}
+	if _, ok := publisher.published["house/mirror"]; !ok {
+		t.Errorf("published %v, want house/mirror", publisher.published)
+	}
+}
@@ -0,0 +1,235 @@
+package topics
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/metrics"
+	"github.com/denwilliams/go-mqtt-automation/pkg/strategy"
+)
+
+// OutputSchemaAction is what happens when a strategy's output fails the
+// topic's output schema
+type OutputSchemaAction string
+
+const (
+	// OutputSchemaReject fails the execution: nothing it emitted is applied
+	// and the error is recorded on the topic
+	OutputSchemaReject OutputSchemaAction = "reject"
+	// OutputSchemaDrop drops the nonconforming value; other emitted values
+	// are still applied
+	OutputSchemaDrop OutputSchemaAction = "drop"
+)
+
+// ParseOutputSchemaAction validates an output schema action, treating empty
+// as reject
+func ParseOutputSchemaAction(value string) (OutputSchemaAction, error) {
+	switch action := OutputSchemaAction(value); action {
+	case "":
+		return OutputSchemaReject, nil
+	case OutputSchemaReject, OutputSchemaDrop:
+		return action, nil
+	default:
+		return "", fmt.Errorf("invalid output schema action %q (must be reject or drop)", value)
+	}
+}
+
+// OutputSchema is the shape an internal topic's value must have, checked
+// before the strategy's output is emitted so malformed values never reach
+// downstream consumers. It is a subset of JSON Schema. Nil outputs are left
+// to the topic's null policy.
+type OutputSchema struct {
+	Type       string                   `json:"type,omitempty"`       // number, integer, string, boolean, object, array or null
+	Required   []string                 `json:"required,omitempty"`   // object properties that must be present
+	Properties map[string]*OutputSchema `json:"properties,omitempty"` // schemas of object properties
+	Items      *OutputSchema            `json:"items,omitempty"`      // schema of every array item
+	Action     OutputSchemaAction       `json:"action,omitempty"`     // top level only; empty rejects
+}
+
+// ParseOutputSchema reads an output schema as stored in the topic config (a
+// map decoded from JSON) or set directly
+func ParseOutputSchema(value interface{}) (*OutputSchema, error) {
+	var schema OutputSchema
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case *OutputSchema:
+		if v == nil {
+			return nil, nil
+		}
+		schema = *v
+	case OutputSchema:
+		schema = v
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid output schema: %w", err)
+		}
+		if err := json.Unmarshal(data, &schema); err != nil {
+			return nil, fmt.Errorf("invalid output schema: %w", err)
+		}
+	}
+
+	if _, err := ParseOutputSchemaAction(string(schema.Action)); err != nil {
+		return nil, err
+	}
+	if err := schema.validate("value"); err != nil {
+		return nil, err
+	}
+	return &schema, nil
+}
+
+func (s *OutputSchema) validate(path string) error {
+	switch s.Type {
+	case "", "number", "integer", "string", "boolean", "object", "array", "null":
+	default:
+		return fmt.Errorf("invalid output schema: %s has unknown type %q", path, s.Type)
+	}
+	for name, property := range s.Properties {
+		if property == nil {
+			return fmt.Errorf("invalid output schema: %s.%s has no schema", path, name)
+		}
+		if err := property.validate(path + "." + name); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		return s.Items.validate(path + "[]")
+	}
+	return nil
+}
+
+// Check returns why value does not conform to the schema, or nil if it does
+func (s *OutputSchema) Check(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	return s.check("value", value)
+}
+
+func (s *OutputSchema) check(path string, value interface{}) error {
+	if s.Type != "" && outputSchemaType(value, s.Type == "integer") != s.Type {
+		return fmt.Errorf("%s is %s, expected %s", path, outputSchemaType(value, false), s.Type)
+	}
+
+	if object, ok := value.(map[string]interface{}); ok {
+		for _, name := range s.Required {
+			if _, ok := object[name]; !ok {
+				return fmt.Errorf("%s is missing required property %s", path, name)
+			}
+		}
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := object[name]; ok {
+				if err := s.Properties[name].check(path+"."+name, property); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if items, ok := value.([]interface{}); ok && s.Items != nil {
+		for i, item := range items {
+			if err := s.Items.check(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// outputSchemaType names the JSON type of a value. Whole numbers are
+// "integer" only when wantInteger is set, since an integer is also a number.
+func outputSchemaType(value interface{}, wantInteger bool) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	if number, ok := validationNumber(value); ok {
+		if wantInteger && number == math.Trunc(number) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// GetOutputSchema returns the topic's output schema, or nil if none is set
+func (it *InternalTopic) GetOutputSchema() *OutputSchema {
+	schema, err := ParseOutputSchema(it.config.Config["output_schema"])
+	if err != nil {
+		return nil
+	}
+	return schema
+}
+
+// SetOutputSchema sets (or clears, when nil) the schema the strategy's output
+// must conform to.
+func (it *InternalTopic) SetOutputSchema(schema *OutputSchema) error {
+	if it.config.Config == nil {
+		it.config.Config = make(map[string]interface{})
+	}
+	if schema == nil {
+		delete(it.config.Config, "output_schema")
+		return nil
+	}
+	if _, err := ParseOutputSchema(schema); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return fmt.Errorf("invalid output schema: %w", err)
+	}
+	var stored map[string]interface{}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("invalid output schema: %w", err)
+	}
+	it.config.Config["output_schema"] = stored
+	return nil
+}
+
+// checkOutputSchema checks the values emitted to the topic itself against
+// its output schema, returning the events to apply. Failures are logged and
+// counted; under the reject action the whole execution fails.
+func (it *InternalTopic) checkOutputSchema(events []strategy.EmitEvent) ([]strategy.EmitEvent, error) {
+	schema := it.GetOutputSchema()
+	if schema == nil {
+		return events, nil
+	}
+	action, _ := ParseOutputSchemaAction(string(schema.Action))
+
+	accepted := make([]strategy.EmitEvent, 0, len(events))
+	for _, event := range events {
+		if event.Topic != "" {
+			accepted = append(accepted, event)
+			continue
+		}
+		err := schema.Check(event.Value)
+		if err == nil {
+			accepted = append(accepted, event)
+			continue
+		}
+
+		metrics.RecordTopicValidationFailure(it.config.Name, "output_schema_"+string(action))
+		if action == OutputSchemaReject {
+			return nil, fmt.Errorf("output does not match the output schema: %w", err)
+		}
+		it.manager.logger.Printf("Topic %s dropped output that does not match the output schema: %v", it.config.Name, err)
+	}
+	return accepted, nil
+}
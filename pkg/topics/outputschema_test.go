@@ -0,0 +1,133 @@
+package topics
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOutputSchemaCheck(t *testing.T) {
+	reading := &OutputSchema{
+		Type:     "object",
+		Required: []string{"temp", "unit"},
+		Properties: map[string]*OutputSchema{
+			"temp":    {Type: "number"},
+			"unit":    {Type: "string"},
+			"samples": {Type: "array", Items: &OutputSchema{Type: "integer"}},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		schema  *OutputSchema
+		value   interface{}
+		wantErr string
+	}{
+		{name: "conforming object", schema: reading, value: map[string]interface{}{"temp": 21.5, "unit": "C"}},
+		{name: "conforming items", schema: reading, value: map[string]interface{}{"temp": 21.5, "unit": "C", "samples": []interface{}{21.0, int64(22)}}},
+		{name: "missing property", schema: reading, value: map[string]interface{}{"temp": 21.5}, wantErr: "missing required property unit"},
+		{name: "wrong property type", schema: reading, value: map[string]interface{}{"temp": "21.5", "unit": "C"}, wantErr: "value.temp is string, expected number"},
+		{name: "wrong item type", schema: reading, value: map[string]interface{}{"temp": 21.5, "unit": "C", "samples": []interface{}{21.5}}, wantErr: "value.samples[0] is number, expected integer"},
+		{name: "not an object", schema: reading, value: 21.5, wantErr: "value is number, expected object"},
+		{name: "nil is left to the null policy", schema: reading, value: nil},
+		{name: "boolean", schema: &OutputSchema{Type: "boolean"}, value: true},
+		{name: "whole number is a number", schema: &OutputSchema{Type: "number"}, value: int64(3)},
+		{name: "untyped accepts anything", schema: &OutputSchema{}, value: "on"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.schema.Check(tt.value)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Check(%v) = %v, want nil", tt.value, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Check(%v) = %v, want error containing %q", tt.value, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseOutputSchema(t *testing.T) {
+	// Schemas loaded from the database decode as a generic map
+	schema, err := ParseOutputSchema(map[string]interface{}{
+		"type":       "object",
+		"required":   []interface{}{"state"},
+		"properties": map[string]interface{}{"state": map[string]interface{}{"type": "string"}},
+		"action":     "drop",
+	})
+	if err != nil {
+		t.Fatalf("ParseOutputSchema failed: %v", err)
+	}
+	if schema.Type != "object" || schema.Action != OutputSchemaDrop || schema.Properties["state"].Type != "string" {
+		t.Errorf("schema = %+v", schema)
+	}
+
+	invalid := []interface{}{
+		map[string]interface{}{"type": "float"},
+		map[string]interface{}{"properties": map[string]interface{}{"state": map[string]interface{}{"type": "text"}}},
+		map[string]interface{}{"action": "ignore"},
+		"not a schema",
+	}
+	for _, value := range invalid {
+		if _, err := ParseOutputSchema(value); err == nil {
+			t.Errorf("ParseOutputSchema(%v) accepted an invalid schema", value)
+		}
+	}
+}
+
+func TestInternalTopicOutputSchema(t *testing.T) {
+	tests := []struct {
+		name        string
+		action      OutputSchemaAction
+		result      interface{}
+		wantValue   interface{}
+		wantBattery bool
+		wantErr     bool
+	}{
+		{name: "conforming output is emitted", result: map[string]interface{}{"state": "on"}, wantValue: map[string]interface{}{"state": "on"}, wantBattery: true},
+		{name: "reject fails the execution", result: map[string]interface{}{"level": 3.5}, wantErr: true},
+		{name: "drop skips only the value", action: OutputSchemaDrop, result: "on", wantBattery: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewManager(nil)
+			manager.SetStrategyExecutor(&mockStrategyExecutor{
+				executeFunc: func(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+					return tt.result, nil
+				},
+			})
+
+			sensor := mustAddExternalTopic(t, manager, "sensors/light")
+			topic, err := manager.AddInternalTopic("house/light", []string{"sensors/light"}, nil, "parent-strategy", nil, false, false)
+			if err != nil {
+				t.Fatalf("Failed to add internal topic: %v", err)
+			}
+			schema := &OutputSchema{Type: "object", Required: []string{"state"}, Action: tt.action}
+			if err := topic.SetOutputSchema(schema); err != nil {
+				t.Fatalf("SetOutputSchema failed: %v", err)
+			}
+
+			err = topic.processInputs(context.Background(), sensor.Name())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("processInputs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !strings.Contains(topic.LastError(), "output schema") {
+				t.Errorf("last error = %q, want the schema failure", topic.LastError())
+			}
+
+			if value := topic.LastValue(); !reflect.DeepEqual(value, tt.wantValue) {
+				t.Errorf("topic value = %v, want %v", value, tt.wantValue)
+			}
+			// Values emitted to other topics are only applied when the execution is not rejected
+			if battery := manager.GetTopic("house/light/battery"); (battery != nil) != tt.wantBattery {
+				t.Errorf("battery topic exists = %v, want %v", battery != nil, tt.wantBattery)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package topics
+
+import (
+	"fmt"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/mqtt"
+)
+
+// ParsePassiveInputs reads a passive input list as stored in the topic config
+// (decoded from JSON) or set directly
+func ParsePassiveInputs(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []string:
+		return v, nil
+	case []interface{}:
+		inputs := make([]string, 0, len(v))
+		for _, item := range v {
+			input, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("passive input %v is not a string", item)
+			}
+			inputs = append(inputs, input)
+		}
+		return inputs, nil
+	default:
+		return nil, fmt.Errorf("passive inputs must be a list, got %T", value)
+	}
+}
+
+// ValidatePassiveInputs checks that every passive input is one of inputs
+func ValidatePassiveInputs(inputs, passive []string) error {
+	for _, input := range passive {
+		if !containsString(inputs, input) {
+			return fmt.Errorf("passive input %s is not an input of the topic", input)
+		}
+	}
+	return nil
+}
+
+// GetPassiveInputs returns the inputs whose updates do not trigger the topic
+func (it *InternalTopic) GetPassiveInputs() []string {
+	inputs, err := ParsePassiveInputs(it.config.Config["passive_inputs"])
+	if err != nil || len(inputs) == 0 {
+		return nil
+	}
+	return inputs
+}
+
+// SetPassiveInputs sets (or clears, when empty) the inputs whose updates only
+// change the values the strategy sees, without triggering it.
+func (it *InternalTopic) SetPassiveInputs(inputs []string) error {
+	if err := ValidatePassiveInputs(it.GetInputs(), inputs); err != nil {
+		return err
+	}
+	if it.config.Config == nil {
+		it.config.Config = make(map[string]interface{})
+	}
+	if len(inputs) == 0 {
+		delete(it.config.Config, "passive_inputs")
+		return nil
+	}
+	it.config.Config["passive_inputs"] = append([]string(nil), inputs...)
+	return nil
+}
+
+// TriggeredBy reports whether an update to topicName triggers the topic: it
+// must match an input that is not passive
+func (it *InternalTopic) TriggeredBy(topicName string) bool {
+	passive := it.GetPassiveInputs()
+	for _, input := range it.GetInputs() {
+		if input != topicName && !mqtt.TopicMatches(input, topicName) {
+			continue
+		}
+		if !containsString(passive, input) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
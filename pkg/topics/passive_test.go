@@ -0,0 +1,112 @@
+package topics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPassiveInputsDoNotTrigger(t *testing.T) {
+	tests := []struct {
+		name    string
+		inputs  []string
+		passive []string
+		group   bool
+		updates []string
+		want    []string // trigger topic of each execution
+	}{
+		{
+			name:    "passive input",
+			inputs:  []string{"sensors/motion", "sensors/lux"},
+			passive: []string{"sensors/lux"},
+			updates: []string{"sensors/lux", "sensors/motion", "sensors/lux", "sensors/motion"},
+			want:    []string{"sensors/motion", "sensors/motion"},
+		},
+		{
+			name:    "no passive inputs",
+			inputs:  []string{"sensors/motion", "sensors/lux"},
+			updates: []string{"sensors/lux", "sensors/motion"},
+			want:    []string{"sensors/lux", "sensors/motion"},
+		},
+		{
+			name:    "passive wildcard overlapping a trigger input",
+			inputs:  []string{"sensors/motion", "sensors/+"},
+			passive: []string{"sensors/+"},
+			updates: []string{"sensors/lux", "sensors/motion"},
+			want:    []string{"sensors/motion"},
+		},
+		{
+			name:    "group does not wait for passive inputs",
+			inputs:  []string{"sensors/motion", "sensors/door", "sensors/lux"},
+			passive: []string{"sensors/lux"},
+			group:   true,
+			updates: []string{"sensors/lux", "sensors/motion", "sensors/lux", "sensors/door"},
+			want:    []string{"sensors/door"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewManager(nil)
+			var executed []string
+			var lastLux interface{}
+			manager.SetStrategyExecutor(&mockStrategyExecutor{
+				executeFunc: func(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+					executed = append(executed, triggerTopic)
+					lastLux = inputs["sensors/lux"]
+					return true, nil
+				},
+			})
+			sources := map[string]*ExternalTopic{}
+			for _, name := range []string{"sensors/motion", "sensors/door", "sensors/lux"} {
+				sources[name] = mustAddExternalTopic(t, manager, name)
+			}
+			topic := mustAddInternalTopic(t, manager, "lights/hall", tt.inputs)
+			if err := topic.SetPassiveInputs(tt.passive); err != nil {
+				t.Fatalf("SetPassiveInputs failed: %v", err)
+			}
+			topic.SetGroup(tt.group)
+
+			for i, name := range tt.updates {
+				if err := sources[name].Emit(float64(i)); err != nil {
+					t.Fatalf("Emit failed: %v", err)
+				}
+			}
+
+			if !reflect.DeepEqual(executed, tt.want) {
+				t.Errorf("executions triggered by %v, want %v", executed, tt.want)
+			}
+			// Passive updates still reach the strategy on the next trigger
+			if containsString(tt.passive, "sensors/lux") && lastLux != float64(2) {
+				t.Errorf("sensors/lux = %v at the last execution, want the passive update 2", lastLux)
+			}
+		})
+	}
+}
+
+func TestSetPassiveInputs(t *testing.T) {
+	manager := NewManager(nil)
+	topic := mustAddInternalTopic(t, manager, "lights/hall", []string{"sensors/motion", "sensors/lux"})
+
+	if err := topic.SetPassiveInputs([]string{"sensors/door"}); err == nil {
+		t.Error("expected an error for a passive input that is not an input")
+	}
+	if err := topic.SetPassiveInputs([]string{"sensors/lux"}); err != nil {
+		t.Fatalf("SetPassiveInputs failed: %v", err)
+	}
+	if passive := topic.GetPassiveInputs(); !reflect.DeepEqual(passive, []string{"sensors/lux"}) {
+		t.Errorf("GetPassiveInputs() = %v", passive)
+	}
+
+	// Stored config is decoded from JSON when loaded
+	topic.config.Config["passive_inputs"] = []interface{}{"sensors/lux"}
+	if !topic.TriggeredBy("sensors/motion") || topic.TriggeredBy("sensors/lux") || topic.TriggeredBy("sensors/door") {
+		t.Error("TriggeredBy does not follow the passive inputs")
+	}
+
+	if err := topic.SetPassiveInputs(nil); err != nil {
+		t.Fatalf("SetPassiveInputs failed: %v", err)
+	}
+	if _, ok := topic.config.Config["passive_inputs"]; ok {
+		t.Error("clearing passive inputs left them in the config")
+	}
+}
@@ -0,0 +1,68 @@
+package topics
+
+import (
+	"fmt"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/strategy"
+)
+
+// OptionsExecutor is implemented by strategy executors that accept
+// per-execution options, such as a topic's post-processor pipeline
+type OptionsExecutor interface {
+	ExecuteStrategyWithOptions(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}, options strategy.ExecuteOptions) ([]strategy.EmitEvent, []strategy.LogMessage, *strategy.ExecutionTrace, error)
+}
+
+// ParsePostProcessors reads a post-processor list as stored in the topic
+// config (decoded from JSON) or set directly, validating each spec
+func ParsePostProcessors(value interface{}) ([]string, error) {
+	var specs []string
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case []string:
+		specs = v
+	case []interface{}:
+		specs = make([]string, 0, len(v))
+		for _, item := range v {
+			spec, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("post-processor %v is not a string", item)
+			}
+			specs = append(specs, spec)
+		}
+	default:
+		return nil, fmt.Errorf("post-processors must be a list, got %T", value)
+	}
+
+	if _, err := strategy.ParsePostProcessors(specs); err != nil {
+		return nil, err
+	}
+	return specs, nil
+}
+
+// GetPostProcessors returns the topic's post-processor pipeline, or nil if
+// the engine's default pipeline applies
+func (it *InternalTopic) GetPostProcessors() []string {
+	specs, err := ParsePostProcessors(it.config.Config["post_processors"])
+	if err != nil || len(specs) == 0 {
+		return nil
+	}
+	return specs
+}
+
+// SetPostProcessors sets the pipeline that replaces the default one for this
+// topic; empty restores the default and ["none"] disables post-processing.
+func (it *InternalTopic) SetPostProcessors(specs []string) error {
+	if _, err := ParsePostProcessors(specs); err != nil {
+		return err
+	}
+	if it.config.Config == nil {
+		it.config.Config = make(map[string]interface{})
+	}
+	if len(specs) == 0 {
+		delete(it.config.Config, "post_processors")
+		return nil
+	}
+	it.config.Config["post_processors"] = append([]string(nil), specs...)
+	return nil
+}
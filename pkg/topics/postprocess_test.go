@@ -0,0 +1,65 @@
+package topics
+
+import (
+	"testing"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/strategy"
+)
+
+func TestInternalTopicPostProcessors(t *testing.T) {
+	manager := NewManager(nil)
+	engine := strategy.NewEngine(nil)
+	if err := engine.AddStrategy(&strategy.Strategy{ID: "double", Name: "double", Code: `function process(context) { return context.inputs["sensors/temp"] * 2.111; }`, Language: "javascript"}); err != nil {
+		t.Fatalf("Failed to add strategy: %v", err)
+	}
+	if err := engine.SetPostProcessors([]string{"round:1"}); err != nil {
+		t.Fatalf("SetPostProcessors failed: %v", err)
+	}
+	manager.SetStrategyExecutor(engine)
+
+	sensor := mustAddExternalTopic(t, manager, "sensors/temp")
+	topic, err := manager.AddInternalTopic("house/temp", []string{"sensors/temp"}, nil, "double", nil, false, false)
+	if err != nil {
+		t.Fatalf("Failed to add internal topic: %v", err)
+	}
+
+	// The engine's default pipeline applies
+	if err := sensor.Emit(10.0); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	if topic.LastValue() != 21.1 {
+		t.Errorf("default pipeline value = %v, want 21.1", topic.LastValue())
+	}
+
+	// The topic's pipeline replaces it
+	if err := topic.SetPostProcessors([]string{"clamp:0:20"}); err != nil {
+		t.Fatalf("SetPostProcessors failed: %v", err)
+	}
+	if err := sensor.Emit(11.0); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	if topic.LastValue() != 20.0 {
+		t.Errorf("topic pipeline value = %v, want 20", topic.LastValue())
+	}
+
+	if err := topic.SetPostProcessors([]string{"clamp"}); err == nil {
+		t.Error("SetPostProcessors accepted an invalid spec")
+	}
+	if err := topic.SetPostProcessors(nil); err != nil || topic.GetPostProcessors() != nil {
+		t.Errorf("post-processors were not cleared: %v", topic.GetPostProcessors())
+	}
+}
+
+func TestParsePostProcessorsFromConfig(t *testing.T) {
+	// Lists loaded from the database decode as []interface{}
+	specs, err := ParsePostProcessors([]interface{}{"round:2", "timestamp"})
+	if err != nil || len(specs) != 2 || specs[1] != "timestamp" {
+		t.Errorf("ParsePostProcessors = %v, %v", specs, err)
+	}
+
+	for _, value := range []interface{}{"round", []interface{}{1.0}, []string{"unknown"}} {
+		if _, err := ParsePostProcessors(value); err == nil {
+			t.Errorf("ParsePostProcessors(%v) accepted an invalid list", value)
+		}
+	}
+}
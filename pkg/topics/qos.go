@@ -0,0 +1,60 @@
+package topics
+
+import "fmt"
+
+// ParseQoS validates an internal topic's publish QoS. ok is false when none
+// is set, in which case the MQTT client's configured QoS is used.
+func ParseQoS(value interface{}) (qos byte, ok bool, err error) {
+	var level int
+	switch v := value.(type) {
+	case nil:
+		return 0, false, nil
+	case int:
+		level = v
+	case *int:
+		if v == nil {
+			return 0, false, nil
+		}
+		level = *v
+	case float64:
+		if v != float64(int(v)) {
+			return 0, false, fmt.Errorf("invalid qos: %v", v)
+		}
+		level = int(v)
+	default:
+		return 0, false, fmt.Errorf("invalid qos: %v", value)
+	}
+
+	if level < 0 || level > 2 {
+		return 0, false, fmt.Errorf("qos must be 0, 1 or 2")
+	}
+	return byte(level), true, nil
+}
+
+// GetQoS returns the QoS the topic publishes its values at, if it has its own
+func (it *InternalTopic) GetQoS() (byte, bool) {
+	qos, ok, err := ParseQoS(it.config.Config["qos"])
+	if err != nil {
+		return 0, false
+	}
+	return qos, ok
+}
+
+// SetQoS sets (or clears, when nil) the QoS the topic publishes its values
+// at.
+func (it *InternalTopic) SetQoS(qos *int) error {
+	level, ok, err := ParseQoS(qos)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		delete(it.config.Config, "qos")
+		return nil
+	}
+
+	if it.config.Config == nil {
+		it.config.Config = make(map[string]interface{})
+	}
+	it.config.Config["qos"] = int(level)
+	return nil
+}
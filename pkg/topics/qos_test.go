@@ -0,0 +1,77 @@
+package topics
+
+import "testing"
+
+func TestInternalTopicQoS(t *testing.T) {
+	manager := NewManager(nil)
+	publisher := &mockPublisher{}
+	manager.SetMQTTClient(publisher)
+	manager.SetStrategyExecutor(&mockStrategyExecutor{
+		executeFunc: func(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+			return "on", nil
+		},
+	})
+
+	sensor := mustAddExternalTopic(t, manager, "sensors/motion")
+	plain, err := manager.AddInternalTopic("lights/hall", []string{"sensors/motion"}, nil, "test", nil, true, false)
+	if err != nil {
+		t.Fatalf("AddInternalTopic failed: %v", err)
+	}
+	atLeastOnce, err := manager.AddInternalTopic("alarms/siren", []string{"sensors/motion"}, nil, "test", nil, true, false)
+	if err != nil {
+		t.Fatalf("AddInternalTopic failed: %v", err)
+	}
+	one := 1
+	if err := atLeastOnce.SetQoS(&one); err != nil {
+		t.Fatalf("SetQoS failed: %v", err)
+	}
+
+	if err := sensor.Emit(true); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	if _, explicit := publisher.qos["lights/hall"]; explicit {
+		t.Error("topic without a qos published with an explicit QoS")
+	}
+	if _, published := publisher.published["lights/hall"]; !published {
+		t.Error("topic without a qos was not published")
+	}
+	if got, ok := publisher.qos["alarms/siren"]; !ok || got != 1 {
+		t.Errorf("alarms/siren published at QoS %d (explicit %v), want 1", got, ok)
+	}
+
+	// The client's QoS is the default and is not stored
+	if err := plain.SetQoS(nil); err != nil {
+		t.Fatalf("SetQoS failed: %v", err)
+	}
+	if _, ok := plain.GetQoS(); ok {
+		t.Error("expected no qos after clearing it")
+	}
+
+	three := 3
+	if err := plain.SetQoS(&three); err == nil {
+		t.Error("SetQoS accepted an invalid qos")
+	}
+}
+
+func TestParseQoS(t *testing.T) {
+	tests := []struct {
+		value   interface{}
+		want    byte
+		wantOK  bool
+		wantErr bool
+	}{
+		{value: nil},
+		{value: 2, want: 2, wantOK: true},
+		{value: float64(1), want: 1, wantOK: true}, // decoded from JSON
+		{value: 1.5, wantErr: true},
+		{value: -1, wantErr: true},
+		{value: "1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, ok, err := ParseQoS(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want || ok != tt.wantOK {
+			t.Errorf("ParseQoS(%v) = %d, %v, %v; want %d, %v, error %v", tt.value, got, ok, err, tt.want, tt.wantOK, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package topics
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ScheduledTrigger is the trigger topic passed to strategies when an execution
+// was started by the topic's schedule rather than an input change
+const ScheduledTrigger = "$schedule"
+
+// Clock abstracts time so schedules can be driven by a fake clock in tests
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// Schedule computes the next time a scheduled execution should fire
+type Schedule interface {
+	Next(from time.Time) time.Time
+}
+
+type intervalSchedule struct {
+	interval time.Duration
+}
+
+func (s intervalSchedule) Next(from time.Time) time.Time {
+	return from.Add(s.interval)
+}
+
+// ParseSchedule parses either a Go duration ("5m") or a five-field cron
+// expression ("*/5 * * * *")
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, fmt.Errorf("schedule is empty")
+	}
+
+	if interval, err := time.ParseDuration(spec); err == nil {
+		if interval <= 0 {
+			return nil, fmt.Errorf("schedule interval must be positive: %s", spec)
+		}
+		return intervalSchedule{interval: interval}, nil
+	}
+
+	return parseCron(spec)
+}
+
+// scheduleRunner fires a callback each time its schedule elapses until
+// stopped. It drives scheduled and republishing internal topics as well as
+// system topics.
+type scheduleRunner struct {
+	schedule Schedule
+	clock    Clock
+	fire     func(t time.Time)
+	stopChan chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func newScheduleRunner(schedule Schedule, clock Clock, fire func(t time.Time)) *scheduleRunner {
+	return &scheduleRunner{
+		schedule: schedule,
+		clock:    clock,
+		fire:     fire,
+		stopChan: make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+func (sr *scheduleRunner) start() {
+	go sr.run()
+}
+
+// stop signals the runner to exit. It does not wait for an in-flight execution,
+// so it is safe to call while holding the manager lock.
+func (sr *scheduleRunner) stop() {
+	sr.stopOnce.Do(func() {
+		close(sr.stopChan)
+	})
+}
+
+// wait blocks until a stopped runner has exited, including any execution in
+// flight when it was stopped
+func (sr *scheduleRunner) wait() {
+	<-sr.done
+}
+
+func (sr *scheduleRunner) run() {
+	defer close(sr.done)
+
+	for {
+		now := sr.clock.Now()
+		next := sr.schedule.Next(now)
+		if next.IsZero() {
+			// The expression can never match; idle until stopped
+			<-sr.stopChan
+			return
+		}
+
+		select {
+		case <-sr.stopChan:
+			return
+		case t := <-sr.clock.After(next.Sub(now)):
+			select {
+			case <-sr.stopChan:
+				return
+			default:
+			}
+			sr.fire(t)
+		}
+	}
+}
+
+// cronSchedule is a minimal five-field cron expression (minute hour day-of-month month day-of-week)
+type cronSchedule struct {
+	minutes  map[int]bool
+	hours    map[int]bool
+	days     map[int]bool
+	months   map[int]bool
+	weekdays map[int]bool
+	daysAny  bool
+	wdaysAny bool
+}
+
+func parseCron(spec string) (*cronSchedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q: expected a duration or 5 cron fields", spec)
+	}
+
+	bounds := []struct{ min, max int }{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+	sets := make([]map[int]bool, 5)
+	for i, field := range fields {
+		set, err := parseCronField(field, bounds[i].min, bounds[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron field %q: %w", field, err)
+		}
+		sets[i] = set
+	}
+
+	return &cronSchedule{
+		minutes:  sets[0],
+		hours:    sets[1],
+		days:     sets[2],
+		months:   sets[3],
+		weekdays: sets[4],
+		daysAny:  fields[2] == "*",
+		wdaysAny: fields[4] == "*",
+	}, nil
+}
+
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	set := make(map[int]bool)
+
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			parsed, err := strconv.Atoi(part[idx+1:])
+			if err != nil || parsed <= 0 {
+				return nil, fmt.Errorf("invalid step %q", part[idx+1:])
+			}
+			step = parsed
+			part = part[:idx]
+		}
+
+		lo, hi := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, fmt.Errorf("invalid range start %q", bounds[0])
+			}
+			if hi, err = strconv.Atoi(bounds[1]); err != nil {
+				return nil, fmt.Errorf("invalid range end %q", bounds[1])
+			}
+		default:
+			value, err := strconv.Atoi(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q", part)
+			}
+			lo, hi = value, value
+		}
+
+		if lo < min || hi > max || lo > hi {
+			return nil, fmt.Errorf("value out of range %d-%d", min, max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+
+	return set, nil
+}
+
+// Next returns the first minute after from that matches the expression
+func (cs *cronSchedule) Next(from time.Time) time.Time {
+	t := from.Truncate(time.Minute).Add(time.Minute)
+
+	// Search at most a little over four years ahead (covers Feb 29)
+	for i := 0; i < 4*366*24*60; i++ {
+		if cs.matches(t) {
+			return t
+		}
+		t = t.Add(time.Minute)
+	}
+
+	return time.Time{}
+}
+
+func (cs *cronSchedule) matches(t time.Time) bool {
+	if !cs.minutes[t.Minute()] || !cs.hours[t.Hour()] || !cs.months[int(t.Month())] {
+		return false
+	}
+
+	dayMatch := cs.days[t.Day()]
+	weekdayMatch := cs.weekdays[int(t.Weekday())]
+
+	// Standard cron semantics: when both day fields are restricted, either may match
+	if cs.daysAny || cs.wdaysAny {
+		return dayMatch && weekdayMatch
+	}
+	return dayMatch || weekdayMatch
+}
@@ -0,0 +1,309 @@
+package topics
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced Clock for schedule tests
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+	added   chan struct{}
+}
+
+type fakeWaiter struct {
+	at time.Time
+	ch chan time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now, added: make(chan struct{}, 100)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, fakeWaiter{at: c.now.Add(d), ch: ch})
+	c.added <- struct{}{}
+	return ch
+}
+
+// Advance moves the clock forward and fires any waiters that are due
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	remaining := c.waiters[:0]
+	for _, w := range c.waiters {
+		if !w.at.After(c.now) {
+			w.ch <- c.now
+		} else {
+			remaining = append(remaining, w)
+		}
+	}
+	c.waiters = remaining
+}
+
+// WaitForWaiter blocks until a schedule runner is waiting on the clock
+func (c *fakeClock) WaitForWaiter(t *testing.T) {
+	t.Helper()
+	select {
+	case <-c.added:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for schedule to wait on clock")
+	}
+}
+
+func TestParseSchedule(t *testing.T) {
+	base := time.Date(2024, 1, 1, 10, 7, 30, 0, time.UTC) // Monday
+
+	tests := []struct {
+		name    string
+		spec    string
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "interval", spec: "5m", want: base.Add(5 * time.Minute)},
+		{name: "every minute", spec: "* * * * *", want: time.Date(2024, 1, 1, 10, 8, 0, 0, time.UTC)},
+		{name: "step", spec: "*/15 * * * *", want: time.Date(2024, 1, 1, 10, 15, 0, 0, time.UTC)},
+		{name: "fixed time next day", spec: "30 6 * * *", want: time.Date(2024, 1, 2, 6, 30, 0, 0, time.UTC)},
+		{name: "weekday", spec: "0 9 * * 6", want: time.Date(2024, 1, 6, 9, 0, 0, 0, time.UTC)},
+		{name: "list", spec: "0 8,12 * * *", want: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)},
+		{name: "empty", spec: "", wantErr: true},
+		{name: "negative interval", spec: "-5s", wantErr: true},
+		{name: "wrong field count", spec: "* * *", wantErr: true},
+		{name: "out of range", spec: "60 * * * *", wantErr: true},
+		{name: "invalid value", spec: "a * * * *", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseSchedule(%q) expected error", tt.spec)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSchedule(%q) failed: %v", tt.spec, err)
+			}
+			if got := schedule.Next(base); !got.Equal(tt.want) {
+				t.Errorf("Next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInternalTopicSchedule(t *testing.T) {
+	manager := NewManager(nil)
+	clock := newFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	manager.SetClock(clock)
+
+	type execution struct {
+		trigger string
+		value   interface{}
+	}
+	executions := make(chan execution, 10)
+	manager.SetStrategyExecutor(&mockStrategyExecutor{
+		executeFunc: func(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+			executions <- execution{trigger: triggerTopic, value: inputs["sensors/temp"]}
+			return inputs["sensors/temp"], nil
+		},
+	})
+
+	sensor := mustAddExternalTopic(t, manager, "sensors/temp")
+	topic, err := manager.AddInternalTopic("processed/temp", []string{"sensors/temp"}, nil, "test-strategy", nil, false, false)
+	if err != nil {
+		t.Fatalf("Failed to add internal topic: %v", err)
+	}
+
+	if err := topic.SetSchedule("not a schedule"); err == nil {
+		t.Error("SetSchedule should reject an invalid schedule")
+	}
+	if err := topic.SetSchedule("1m"); err != nil {
+		t.Fatalf("SetSchedule failed: %v", err)
+	}
+	if topic.GetSchedule() != "1m" {
+		t.Errorf("GetSchedule() = %q, want %q", topic.GetSchedule(), "1m")
+	}
+
+	if err := sensor.Emit(21.5); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	if got := <-executions; got.trigger != "sensors/temp" {
+		t.Errorf("input change trigger = %q, want %q", got.trigger, "sensors/temp")
+	}
+
+	clock.WaitForWaiter(t)
+	clock.Advance(time.Minute)
+
+	select {
+	case got := <-executions:
+		if got.trigger != ScheduledTrigger {
+			t.Errorf("scheduled trigger = %q, want %q", got.trigger, ScheduledTrigger)
+		}
+		if got.value != 21.5 {
+			t.Errorf("scheduled input value = %v, want 21.5", got.value)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("scheduled execution did not run")
+	}
+
+	// Removing the topic stops the schedule
+	clock.WaitForWaiter(t)
+	if err := manager.RemoveTopic("processed/temp"); err != nil {
+		t.Fatalf("RemoveTopic failed: %v", err)
+	}
+	clock.Advance(time.Minute)
+
+	select {
+	case got := <-executions:
+		t.Errorf("unexpected execution after removal: %+v", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+type publishedMessage struct {
+	topic   string
+	payload string
+}
+
+// channelPublisher delivers each publish on a channel so tests can wait for
+// publishes made from schedule goroutines
+type channelPublisher struct {
+	published chan publishedMessage
+}
+
+func (p *channelPublisher) Publish(topic string, payload []byte, retain bool) error {
+	p.published <- publishedMessage{topic: topic, payload: string(payload)}
+	return nil
+}
+
+func (p *channelPublisher) PublishWithQoS(topic string, payload []byte, qos byte, retain bool) error {
+	return p.Publish(topic, payload, retain)
+}
+
+func TestInternalTopicRepublish(t *testing.T) {
+	manager := NewManager(nil)
+	clock := newFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	manager.SetClock(clock)
+	publisher := &channelPublisher{published: make(chan publishedMessage, 10)}
+	manager.SetMQTTClient(publisher)
+	manager.SetStrategyExecutor(&mockStrategyExecutor{
+		executeFunc: func(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+			return inputs["sensors/temp"], nil
+		},
+	})
+
+	sensor := mustAddExternalTopic(t, manager, "sensors/temp")
+	topic, err := manager.AddInternalTopic("processed/temp", []string{"sensors/temp"}, nil, "test-strategy", nil, true, false)
+	if err != nil {
+		t.Fatalf("Failed to add internal topic: %v", err)
+	}
+
+	if _, err := ParseRepublishInterval("never"); err == nil {
+		t.Error("ParseRepublishInterval should reject an invalid interval")
+	}
+	topic.SetRepublishInterval(time.Minute)
+	if topic.GetRepublishInterval() != time.Minute {
+		t.Errorf("GetRepublishInterval() = %v, want 1m", topic.GetRepublishInterval())
+	}
+
+	expectPublish := func(want publishedMessage) {
+		t.Helper()
+		select {
+		case got := <-publisher.published:
+			if got != want {
+				t.Errorf("published %+v, want %+v", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for publish of %+v", want)
+		}
+	}
+	expectNoPublish := func() {
+		t.Helper()
+		select {
+		case got := <-publisher.published:
+			t.Errorf("unexpected publish %+v", got)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	// Nothing is republished before the topic has a value
+	clock.WaitForWaiter(t)
+	clock.Advance(time.Minute)
+	clock.WaitForWaiter(t)
+	expectNoPublish()
+
+	if err := sensor.Emit(21.5); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	expectPublish(publishedMessage{topic: "processed/temp", payload: "21.5"})
+
+	// The unchanged value is republished at each interval
+	for i := 0; i < 3; i++ {
+		clock.Advance(time.Minute)
+		expectPublish(publishedMessage{topic: "processed/temp", payload: "21.5"})
+		clock.WaitForWaiter(t)
+	}
+
+	// Less than an interval does not republish
+	clock.Advance(30 * time.Second)
+	expectNoPublish()
+
+	// Disabling stops republishing
+	topic.SetRepublishInterval(0)
+	clock.Advance(time.Minute)
+	expectNoPublish()
+}
+
+func TestSystemTopicCronSchedule(t *testing.T) {
+	manager := NewManager(nil)
+	clock := newFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	manager.SetClock(clock)
+
+	triggers := make(chan string, 10)
+	manager.SetStrategyExecutor(&mockStrategyExecutor{
+		executeFunc: func(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+			triggers <- triggerTopic
+			return nil, nil
+		},
+	})
+
+	ticker := manager.AddSystemTopic("system/cron/five_minutes", map[string]interface{}{"cron": "*/5 * * * *"})
+	mustAddInternalTopic(t, manager, "automation/every_five_minutes", []string{"system/cron/five_minutes"})
+
+	// Stopping and starting again must keep emitting
+	for i := 0; i < 2; i++ {
+		// Forget waiters of the stopped runner
+		for len(clock.added) > 0 {
+			<-clock.added
+		}
+		if err := ticker.Start(); err != nil {
+			t.Fatalf("Start failed: %v", err)
+		}
+		clock.WaitForWaiter(t)
+		clock.Advance(5 * time.Minute)
+
+		select {
+		case got := <-triggers:
+			if got != "system/cron/five_minutes" {
+				t.Errorf("trigger = %q, want system/cron/five_minutes", got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("start %d: cron system topic did not emit", i+1)
+		}
+		ticker.Stop()
+	}
+}
@@ -0,0 +1,112 @@
+package topics
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/metrics"
+)
+
+// DefaultSinkBufferSize is how many events a sink may fall behind by before
+// further events are dropped
+const DefaultSinkBufferSize = 1000
+
+// EventSink receives every topic update, e.g. to forward it to Kafka, NATS or
+// a webhook. Each sink is called from its own goroutine, one event at a time.
+type EventSink interface {
+	HandleEvent(event TopicEvent) error
+}
+
+// sinkWorker delivers events to one sink from a buffered queue
+type sinkWorker struct {
+	name   string
+	sink   EventSink
+	events chan TopicEvent
+	done   chan struct{}
+}
+
+// eventSinks holds the registered sinks. It has its own lock so events can be
+// queued without the manager lock.
+type eventSinks struct {
+	mutex   sync.RWMutex
+	workers []*sinkWorker
+}
+
+// RegisterSink forwards every topic update to sink. Delivery is asynchronous:
+// a slow sink never blocks propagation, and events that arrive while its
+// buffer of bufferSize events (0 uses DefaultSinkBufferSize) is full are
+// dropped.
+func (m *Manager) RegisterSink(name string, sink EventSink, bufferSize int) error {
+	if bufferSize <= 0 {
+		bufferSize = DefaultSinkBufferSize
+	}
+
+	m.sinks.mutex.Lock()
+	defer m.sinks.mutex.Unlock()
+
+	for _, worker := range m.sinks.workers {
+		if worker.name == name {
+			return fmt.Errorf("event sink %s is already registered", name)
+		}
+	}
+
+	worker := &sinkWorker{
+		name:   name,
+		sink:   sink,
+		events: make(chan TopicEvent, bufferSize),
+		done:   make(chan struct{}),
+	}
+	m.sinks.workers = append(m.sinks.workers, worker)
+	go m.runSink(worker)
+	return nil
+}
+
+func (m *Manager) runSink(worker *sinkWorker) {
+	defer close(worker.done)
+	for event := range worker.events {
+		if err := worker.sink.HandleEvent(event); err != nil {
+			metrics.RecordEventSink(worker.name, "failed")
+			m.logger.Printf("Event sink %s failed to handle %s: %v", worker.name, event.TopicName, err)
+			continue
+		}
+		metrics.RecordEventSink(worker.name, "delivered")
+	}
+}
+
+// publishToSinks queues an event for every registered sink without blocking
+func (m *Manager) publishToSinks(event TopicEvent) {
+	m.sinks.mutex.RLock()
+	defer m.sinks.mutex.RUnlock()
+
+	for _, worker := range m.sinks.workers {
+		select {
+		case worker.events <- event:
+		default:
+			metrics.RecordEventSink(worker.name, "dropped")
+			m.logger.Printf("Event sink %s is full; dropped update of %s", worker.name, event.TopicName)
+		}
+	}
+}
+
+// CloseSinks stops accepting events and waits for the sinks to handle the
+// events already queued. It reports whether they finished before ctx was done;
+// undelivered events are abandoned.
+func (m *Manager) CloseSinks(ctx context.Context) bool {
+	m.sinks.mutex.Lock()
+	workers := m.sinks.workers
+	m.sinks.workers = nil
+	m.sinks.mutex.Unlock()
+
+	for _, worker := range workers {
+		close(worker.events)
+	}
+	for _, worker := range workers {
+		select {
+		case <-worker.done:
+		case <-ctx.Done():
+			return false
+		}
+	}
+	return true
+}
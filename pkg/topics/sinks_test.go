@@ -0,0 +1,159 @@
+package topics
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mockSink struct {
+	mutex  sync.Mutex
+	events []TopicEvent
+	block  chan struct{} // when set, HandleEvent waits until it is closed
+}
+
+func (s *mockSink) HandleEvent(event TopicEvent) error {
+	if s.block != nil {
+		<-s.block
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *mockSink) received() []TopicEvent {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]TopicEvent(nil), s.events...)
+}
+
+func TestManagerRegisterSink(t *testing.T) {
+	manager := NewManager(nil)
+	sink := &mockSink{}
+	if err := manager.RegisterSink("mock", sink, 0); err != nil {
+		t.Fatalf("RegisterSink failed: %v", err)
+	}
+	if err := manager.RegisterSink("mock", sink, 0); err == nil {
+		t.Error("RegisterSink accepted a duplicate name")
+	}
+
+	sensor := mustAddExternalTopic(t, manager, "sensors/temp")
+	for _, value := range []float64{20.5, 21.5} {
+		if err := sensor.Emit(value); err != nil {
+			t.Fatalf("Emit failed: %v", err)
+		}
+	}
+
+	if !manager.CloseSinks(context.Background()) {
+		t.Fatal("CloseSinks did not drain the sinks")
+	}
+	events := sink.received()
+	if len(events) != 2 {
+		t.Fatalf("sink received %d events, want 2", len(events))
+	}
+	if events[1].TopicName != "sensors/temp" || events[1].Value != 21.5 || events[1].PreviousValue != 20.5 {
+		t.Errorf("event = %+v", events[1])
+	}
+}
+
+func TestManagerSlowSinkDoesNotBlock(t *testing.T) {
+	manager := NewManager(nil)
+	var downstreamRuns int
+	manager.SetStrategyExecutor(&mockStrategyExecutor{
+		executeFunc: func(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+			downstreamRuns++
+			return inputs["sensors/temp"], nil
+		},
+	})
+
+	slow := &mockSink{block: make(chan struct{})}
+	if err := manager.RegisterSink("slow", slow, 2); err != nil {
+		t.Fatalf("RegisterSink failed: %v", err)
+	}
+
+	sensor := mustAddExternalTopic(t, manager, "sensors/temp")
+	if _, err := manager.AddInternalTopic("house/temp", []string{"sensors/temp"}, nil, "test", nil, false, false); err != nil {
+		t.Fatalf("Failed to add internal topic: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			if err := sensor.Emit(float64(i) + 0.5); err != nil {
+				t.Errorf("Emit failed: %v", err)
+			}
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("a blocked sink blocked propagation")
+	}
+	if downstreamRuns != 10 {
+		t.Errorf("downstream ran %d times, want 10", downstreamRuns)
+	}
+
+	// Of the 20 updates only the one in flight and the 2 buffered are
+	// delivered; the rest were dropped rather than queued
+	close(slow.block)
+	manager.CloseSinks(context.Background())
+	if got := len(slow.received()); got == 0 || got > 3 {
+		t.Errorf("slow sink received %d events, want at most 3", got)
+	}
+}
+
+func TestWebhookSinkRetries(t *testing.T) {
+	var mutex sync.Mutex
+	var attempts int
+	var body webhookEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		attempts++
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sink, err := NewWebhookSink(server.URL, WebhookOptions{Headers: map[string]string{"Authorization": "Bearer secret"}})
+	if err != nil {
+		t.Fatalf("NewWebhookSink failed: %v", err)
+	}
+	var waits []time.Duration
+	sink.sleep = func(d time.Duration) { waits = append(waits, d) }
+
+	event := TopicEvent{TopicName: "house/temp", Value: 21.5, Timestamp: time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)}
+	if err := sink.HandleEvent(event); err != nil {
+		t.Fatalf("HandleEvent failed: %v", err)
+	}
+	if attempts != 3 || body.Topic != "house/temp" || body.Value != 21.5 {
+		t.Errorf("attempts = %d, body = %+v", attempts, body)
+	}
+	if len(waits) != 2 || waits[1] != 2*waits[0] {
+		t.Errorf("retry waits = %v, want doubling backoff", waits)
+	}
+
+	// Client errors are not retried
+	attempts = 0
+	sink.options.Headers = nil
+	if err := sink.HandleEvent(event); err == nil {
+		t.Error("HandleEvent succeeded on 401")
+	}
+	if attempts != 1 {
+		t.Errorf("401 was attempted %d times, want 1", attempts)
+	}
+}
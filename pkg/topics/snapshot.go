@@ -0,0 +1,168 @@
+package topics
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaxSnapshotSize is the largest number of recent values kept in a snapshot
+const MaxSnapshotSize = 100
+
+// TopicSnapshot is the recent history of an internal topic persisted so its
+// strategy context survives a crash
+type TopicSnapshot struct {
+	LastOutput interface{}     `json:"last_output"`
+	Values     []SnapshotValue `json:"values"`
+}
+
+// SnapshotValue is one value recorded in a topic snapshot
+type SnapshotValue struct {
+	Value     interface{} `json:"value"`
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+// SnapshotStore persists topic snapshots
+type SnapshotStore interface {
+	SaveTopicSnapshot(topicName string, snapshot TopicSnapshot) error
+	LoadTopicSnapshots() (map[string]TopicSnapshot, error)
+}
+
+// SetSnapshotStore sets where topic snapshots are persisted
+func (m *Manager) SetSnapshotStore(store SnapshotStore) {
+	m.snapshotStore = store
+}
+
+// ParseSnapshotSize validates a snapshot size option. JSON numbers decode as
+// float64, so whole floats are accepted.
+func ParseSnapshotSize(value interface{}) (int, error) {
+	var size int
+	switch v := value.(type) {
+	case nil:
+		return 0, nil
+	case int:
+		size = v
+	case float64:
+		if v != float64(int(v)) {
+			return 0, fmt.Errorf("invalid snapshot size: %v", v)
+		}
+		size = int(v)
+	default:
+		return 0, fmt.Errorf("invalid snapshot size: %v", value)
+	}
+
+	if size < 0 || size > MaxSnapshotSize {
+		return 0, fmt.Errorf("snapshot size must be between 0 and %d", MaxSnapshotSize)
+	}
+	return size, nil
+}
+
+// GetSnapshotSize returns how many recent values are persisted for recovery
+// (0 disables snapshots)
+func (it *InternalTopic) GetSnapshotSize() int {
+	size, err := ParseSnapshotSize(it.config.Config["snapshot_size"])
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+// SetSnapshotSize sets (or clears, when zero) the number of recent values
+// persisted for recovery.
+func (it *InternalTopic) SetSnapshotSize(size int) error {
+	if _, err := ParseSnapshotSize(size); err != nil {
+		return err
+	}
+	if it.config.Config == nil {
+		it.config.Config = make(map[string]interface{})
+	}
+	if size == 0 {
+		delete(it.config.Config, "snapshot_size")
+	} else {
+		it.config.Config["snapshot_size"] = size
+	}
+
+	it.snapshotMutex.Lock()
+	if len(it.recentValues) > size {
+		it.recentValues = append([]SnapshotValue(nil), it.recentValues[len(it.recentValues)-size:]...)
+	}
+	it.snapshotMutex.Unlock()
+	return nil
+}
+
+// RecentValues returns the values kept in the topic's snapshot, oldest first
+func (it *InternalTopic) RecentValues() []SnapshotValue {
+	it.snapshotMutex.Lock()
+	defer it.snapshotMutex.Unlock()
+
+	return append([]SnapshotValue(nil), it.recentValues...)
+}
+
+// recordSnapshot adds an emitted value to the snapshot and persists it. The
+// write bypasses state batching so the snapshot is current after a crash.
+func (it *InternalTopic) recordSnapshot(value interface{}, timestamp time.Time) error {
+	size := it.GetSnapshotSize()
+	if size == 0 || it.manager == nil || it.manager.snapshotStore == nil {
+		return nil
+	}
+
+	it.snapshotMutex.Lock()
+	it.recentValues = append(it.recentValues, SnapshotValue{Value: value, Timestamp: timestamp})
+	if len(it.recentValues) > size {
+		it.recentValues = it.recentValues[len(it.recentValues)-size:]
+	}
+	snapshot := TopicSnapshot{
+		LastOutput: value,
+		Values:     append([]SnapshotValue(nil), it.recentValues...),
+	}
+	it.snapshotMutex.Unlock()
+
+	return it.manager.snapshotStore.SaveTopicSnapshot(it.config.Name, snapshot)
+}
+
+// restoreSnapshot loads a persisted snapshot into the topic, making the last
+// output available to its strategy again
+func (it *InternalTopic) restoreSnapshot(snapshot TopicSnapshot) {
+	size := it.GetSnapshotSize()
+	values := snapshot.Values
+	if len(values) > size {
+		values = values[len(values)-size:]
+	}
+
+	it.snapshotMutex.Lock()
+	it.recentValues = append([]SnapshotValue(nil), values...)
+	it.snapshotMutex.Unlock()
+
+	updated := it.LastUpdated()
+	if len(values) > 0 {
+		updated = values[len(values)-1].Timestamp
+	}
+	it.setValue(snapshot.LastOutput, updated)
+}
+
+// restoreSnapshots loads persisted snapshots into internal topics that keep
+// them
+func (m *Manager) restoreSnapshots() error {
+	if m.snapshotStore == nil {
+		return nil
+	}
+
+	snapshots, err := m.snapshotStore.LoadTopicSnapshots()
+	if err != nil {
+		return fmt.Errorf("failed to load topic snapshots: %w", err)
+	}
+
+	restored := 0
+	for name, snapshot := range snapshots {
+		topic := m.GetInternalTopic(name)
+		if topic == nil || topic.GetSnapshotSize() == 0 {
+			continue
+		}
+		topic.restoreSnapshot(snapshot)
+		restored++
+	}
+
+	if restored > 0 {
+		m.logger.Printf("Restored snapshots for %d topics", restored)
+	}
+	return nil
+}
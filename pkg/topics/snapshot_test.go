@@ -0,0 +1,149 @@
+package topics
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+)
+
+// memorySnapshotStore keeps snapshots as JSON, like the state database
+type memorySnapshotStore struct {
+	mutex     sync.Mutex
+	snapshots map[string][]byte
+}
+
+func (s *memorySnapshotStore) SaveTopicSnapshot(topicName string, snapshot TopicSnapshot) error {
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.snapshots[topicName] = data
+	return nil
+}
+
+func (s *memorySnapshotStore) LoadTopicSnapshots() (map[string]TopicSnapshot, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	result := make(map[string]TopicSnapshot)
+	for name, data := range s.snapshots {
+		var snapshot TopicSnapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			return nil, err
+		}
+		result[name] = snapshot
+	}
+	return result, nil
+}
+
+// newCounterManager returns a manager with a "counter" topic that adds one to
+// its last output on every input
+func newCounterManager(t *testing.T, store SnapshotStore, lastOutputs *[]interface{}) (*Manager, *ExternalTopic, *InternalTopic) {
+	t.Helper()
+
+	manager := NewManager(nil)
+	manager.SetStateManager(&mockStateManager{})
+	manager.SetSnapshotStore(store)
+	manager.SetStrategyExecutor(&mockStrategyExecutor{
+		executeFunc: func(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+			*lastOutputs = append(*lastOutputs, lastOutput)
+			count, _ := lastOutput.(float64)
+			return count + 1, nil
+		},
+	})
+
+	input := mustAddExternalTopic(t, manager, "sensors/door")
+	counter, err := manager.AddInternalTopic("counter", []string{"sensors/door"}, nil, "count", nil, false, false)
+	if err != nil {
+		t.Fatalf("Failed to add internal topic: %v", err)
+	}
+	if err := counter.SetSnapshotSize(2); err != nil {
+		t.Fatalf("SetSnapshotSize failed: %v", err)
+	}
+	return manager, input, counter
+}
+
+func TestInternalTopicSnapshotRestore(t *testing.T) {
+	store := &memorySnapshotStore{snapshots: make(map[string][]byte)}
+
+	var before []interface{}
+	_, input, counter := newCounterManager(t, store, &before)
+	for i := 0; i < 3; i++ {
+		if err := input.Emit("open"); err != nil {
+			t.Fatalf("Emit failed: %v", err)
+		}
+	}
+	if counter.LastValue() != 3.0 {
+		t.Fatalf("counter = %v before restart, want 3", counter.LastValue())
+	}
+
+	// Simulate a restart: a new manager whose topic starts without a value
+	var after []interface{}
+	restarted, input, counter := newCounterManager(t, store, &after)
+	if err := restarted.RestoreTopicStatesFromDatabase(); err != nil {
+		t.Fatalf("RestoreTopicStatesFromDatabase failed: %v", err)
+	}
+
+	recent := counter.RecentValues()
+	if len(recent) != 2 || recent[0].Value != 2.0 || recent[1].Value != 3.0 {
+		t.Errorf("RecentValues() = %+v, want [2 3]", recent)
+	}
+
+	if err := input.Emit("open"); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	if len(after) != 1 || after[0] != 3.0 {
+		t.Errorf("first execution after restart got lastOutput %v, want 3", after)
+	}
+	if counter.LastValue() != 4.0 {
+		t.Errorf("counter = %v after restart, want 4", counter.LastValue())
+	}
+}
+
+func TestInternalTopicSnapshotDisabled(t *testing.T) {
+	store := &memorySnapshotStore{snapshots: make(map[string][]byte)}
+
+	manager := NewManager(nil)
+	manager.SetSnapshotStore(store)
+	manager.SetStrategyExecutor(&mockStrategyExecutor{})
+	input := mustAddExternalTopic(t, manager, "sensors/door")
+	if _, err := manager.AddInternalTopic("plain", []string{"sensors/door"}, nil, "test", nil, false, false); err != nil {
+		t.Fatalf("Failed to add internal topic: %v", err)
+	}
+
+	if err := input.Emit("open"); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	if len(store.snapshots) != 0 {
+		t.Errorf("snapshot saved for topic without snapshot_size: %v", store.snapshots)
+	}
+}
+
+func TestParseSnapshotSize(t *testing.T) {
+	tests := []struct {
+		value   interface{}
+		want    int
+		wantErr bool
+	}{
+		{value: nil, want: 0},
+		{value: 5, want: 5},
+		{value: 10.0, want: 10},
+		{value: 2.5, wantErr: true},
+		{value: -1, wantErr: true},
+		{value: MaxSnapshotSize + 1, wantErr: true},
+		{value: "5", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSnapshotSize(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseSnapshotSize(%v) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseSnapshotSize(%v) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package topics
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Aggregates reported by the system/stats/ topics
+const (
+	StatTopicCount        = "topic_count"         // topics of every type
+	StatMessagesPerMinute = "messages_per_minute" // MQTT messages received since the last update
+	StatAvgExecutionMs    = "avg_execution_ms"    // mean execution time since the last update
+)
+
+// systemStats counts the activity the stats topics report on
+type systemStats struct {
+	mutex         sync.Mutex
+	messages      uint64
+	executions    uint64
+	executionTime time.Duration
+}
+
+// statsSnapshot is the counters at a point in time. Stats topics report the
+// change between their last snapshot and the current one.
+type statsSnapshot struct {
+	at            time.Time
+	messages      uint64
+	executions    uint64
+	executionTime time.Duration
+}
+
+func (s *systemStats) recordMessage() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.messages++
+}
+
+func (s *systemStats) recordExecution(duration time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.executions++
+	s.executionTime += duration
+}
+
+func (s *systemStats) snapshot(at time.Time) statsSnapshot {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return statsSnapshot{
+		at:            at,
+		messages:      s.messages,
+		executions:    s.executions,
+		executionTime: s.executionTime,
+	}
+}
+
+// computeStat returns the value of an aggregate between two snapshots
+func (m *Manager) computeStat(stat string, previous, current statsSnapshot) interface{} {
+	switch stat {
+	case StatTopicCount:
+		m.mutex.RLock()
+		defer m.mutex.RUnlock()
+		return len(m.topics)
+	case StatMessagesPerMinute:
+		elapsed := current.at.Sub(previous.at)
+		if elapsed <= 0 {
+			return 0.0
+		}
+		return roundStat(float64(current.messages-previous.messages) / elapsed.Minutes())
+	case StatAvgExecutionMs:
+		executions := current.executions - previous.executions
+		if executions == 0 {
+			return 0.0
+		}
+		elapsed := current.executionTime - previous.executionTime
+		return roundStat(float64(elapsed) / float64(time.Millisecond) / float64(executions))
+	default:
+		return nil
+	}
+}
+
+func roundStat(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+// statValue computes the aggregate a stats topic reports, returning false for
+// other system topics
+func (st *SystemTopic) statValue(at time.Time) (interface{}, bool) {
+	stat, _ := st.config.Config["stat"].(string)
+	if stat == "" || st.manager == nil {
+		return nil, false
+	}
+
+	current := st.manager.stats.snapshot(at)
+	value := st.manager.computeStat(stat, st.lastStats, current)
+	st.lastStats = current
+	return value, value != nil
+}
@@ -0,0 +1,79 @@
+package topics
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/config"
+	"github.com/denwilliams/go-mqtt-automation/pkg/mqtt"
+)
+
+func TestStatsTopicsComputeAggregates(t *testing.T) {
+	manager := NewManager(nil)
+	for _, st := range CreateDefaultSystemTopics(config.SystemTopicsConfig{StatsInterval: "30s"}) {
+		manager.AddSystemTopic(st.Name(), st.config.Config)
+	}
+	stats := map[string]*SystemTopic{}
+	for _, stat := range []string{StatTopicCount, StatMessagesPerMinute, StatAvgExecutionMs} {
+		stats[stat] = manager.GetSystemTopic("system/stats/" + stat)
+		if stats[stat] == nil {
+			t.Fatalf("system/stats/%s was not created", stat)
+		}
+	}
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, st := range stats {
+		st.lastStats = manager.stats.snapshot(start)
+	}
+
+	// 45 messages on 3 topics and 4 executions over 30 seconds
+	for i := 0; i < 45; i++ {
+		event := mqtt.Event{Topic: fmt.Sprintf("sensors/%d", i%3), Payload: []byte("21.5")}
+		if err := manager.HandleMQTTMessage(event); err != nil {
+			t.Fatalf("HandleMQTTMessage failed: %v", err)
+		}
+	}
+	for _, ms := range []int{10, 20, 30, 45} {
+		manager.recordExecution(ExecutionRecord{Duration: time.Duration(ms) * time.Millisecond})
+	}
+
+	at := start.Add(30 * time.Second)
+	want := map[string]interface{}{
+		StatTopicCount:        12, // 6 event topics, 3 stats topics and 3 sensors
+		StatMessagesPerMinute: 90.0,
+		StatAvgExecutionMs:    26.25,
+	}
+	for stat, expected := range want {
+		value, ok := stats[stat].statValue(at)
+		if !ok || value != expected {
+			t.Errorf("%s = %v (%v), want %v", stat, value, ok, expected)
+		}
+	}
+
+	// The next update only covers activity since this one
+	at = at.Add(30 * time.Second)
+	if value, _ := stats[StatMessagesPerMinute].statValue(at); value != 0.0 {
+		t.Errorf("messages_per_minute with no new messages = %v, want 0", value)
+	}
+	if value, _ := stats[StatAvgExecutionMs].statValue(at); value != 0.0 {
+		t.Errorf("avg_execution_ms with no new executions = %v, want 0", value)
+	}
+}
+
+func TestStatsTopicEmitsOnTicker(t *testing.T) {
+	manager := NewManager(nil)
+	topic := manager.AddSystemTopic("system/stats/topic_count", map[string]interface{}{
+		"interval": "10ms",
+		"stat":     StatTopicCount,
+	})
+	if err := topic.Start(); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	topic.Stop()
+
+	if topic.LastValue() != 1 {
+		t.Errorf("topic_count = %v, want 1", topic.LastValue())
+	}
+}
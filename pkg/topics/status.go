@@ -0,0 +1,100 @@
+package topics
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/strategy"
+)
+
+// TopicStatus is a computed health indicator for a topic
+type TopicStatus string
+
+const (
+	TopicStatusOK TopicStatus = "ok"
+	// TopicStatusStale means the topic has not updated within its TTL
+	TopicStatusStale TopicStatus = "stale"
+	// TopicStatusError means the topic's last execution failed
+	TopicStatusError TopicStatus = "error"
+	// TopicStatusCircuitOpen means the topic's strategy is skipped by its circuit breaker
+	TopicStatusCircuitOpen TopicStatus = "circuit-open"
+)
+
+// CircuitStatusProvider is implemented by strategy executors that track
+// circuit breaker state (such as strategy.Engine)
+type CircuitStatusProvider interface {
+	CircuitStatus(strategyID, topicName string) strategy.CircuitStatus
+}
+
+// TopicStatus reports the health of a topic. The most severe condition wins:
+// circuit-open, then error, then stale.
+func (m *Manager) TopicStatus(name string) (TopicStatus, error) {
+	m.mutex.RLock()
+	topic, exists := m.topics[name]
+	internalTopic := m.internalTopics[name]
+	executor := m.strategyExecutor
+	m.mutex.RUnlock()
+
+	if !exists {
+		return "", fmt.Errorf("topic %s not found", name)
+	}
+
+	var config map[string]interface{}
+	switch t := topic.(type) {
+	case *InternalTopic:
+		config = t.config.Config
+	case *ExternalTopic:
+		config = t.config.Config
+	}
+
+	if internalTopic != nil {
+		if provider, ok := executor.(CircuitStatusProvider); ok {
+			if provider.CircuitStatus(internalTopic.GetStrategyID(), name).State == strategy.CircuitOpen {
+				return TopicStatusCircuitOpen, nil
+			}
+		}
+		if internalTopic.LastError() != "" {
+			return TopicStatusError, nil
+		}
+	}
+
+	if ttl := configTTL(config); ttl > 0 {
+		lastUpdated := topic.LastUpdated()
+		if lastUpdated.IsZero() || m.clock.Now().Sub(lastUpdated) > ttl {
+			return TopicStatusStale, nil
+		}
+	}
+
+	return TopicStatusOK, nil
+}
+
+// ParseTTL validates a topic TTL, treating empty as no TTL
+func ParseTTL(ttl string) (time.Duration, error) {
+	if ttl == "" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(ttl)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("invalid ttl %q: expected a positive duration such as 5m", ttl)
+	}
+	return duration, nil
+}
+
+// configTTL returns the TTL stored in a topic config, or 0 if none is set
+func configTTL(config map[string]interface{}) time.Duration {
+	ttl, _ := config["ttl"].(string)
+	duration, err := ParseTTL(ttl)
+	if err != nil {
+		return 0
+	}
+	return duration
+}
+
+// setConfigTTL stores (or clears, when zero) a TTL in a topic config
+func setConfigTTL(config map[string]interface{}, ttl time.Duration) {
+	if ttl <= 0 {
+		delete(config, "ttl")
+	} else {
+		config["ttl"] = ttl.String()
+	}
+}
@@ -0,0 +1,147 @@
+package topics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/strategy"
+)
+
+func TestTopicStatus(t *testing.T) {
+	manager := NewManager(nil)
+	clock := newFakeClock(time.Now())
+	manager.SetClock(clock)
+
+	engine := strategy.NewEngine(nil)
+	engine.SetCircuitBreaker(2, time.Minute)
+	for id, code := range map[string]string{
+		"passthrough": `function process(context) { return context.inputs["sensors/temp"]; }`,
+		"failing":     `function process(context) { throw new Error("boom"); }`,
+	} {
+		if err := engine.AddStrategy(&strategy.Strategy{ID: id, Name: id, Code: code, Language: "javascript"}); err != nil {
+			t.Fatalf("Failed to add strategy %s: %v", id, err)
+		}
+	}
+	manager.SetStrategyExecutor(engine)
+
+	sensor := mustAddExternalTopic(t, manager, "sensors/temp")
+	healthy, err := manager.AddInternalTopic("processed/temp", []string{"sensors/temp"}, nil, "passthrough", nil, false, false)
+	if err != nil {
+		t.Fatalf("Failed to add internal topic: %v", err)
+	}
+	broken, err := manager.AddInternalTopic("processed/broken", []string{"sensors/temp"}, nil, "failing", nil, false, false)
+	if err != nil {
+		t.Fatalf("Failed to add internal topic: %v", err)
+	}
+
+	assertStatus := func(name string, want TopicStatus) {
+		t.Helper()
+		got, err := manager.TopicStatus(name)
+		if err != nil {
+			t.Fatalf("TopicStatus(%s) failed: %v", name, err)
+		}
+		if got != want {
+			t.Errorf("TopicStatus(%s) = %s, want %s", name, got, want)
+		}
+	}
+
+	// Without a TTL a topic that never updated is ok
+	assertStatus("sensors/temp", TopicStatusOK)
+
+	// With a TTL a topic that never updated is stale
+	sensor.SetTTL(time.Minute)
+	assertStatus("sensors/temp", TopicStatusStale)
+
+	if err := sensor.Emit(21.5); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	assertStatus("sensors/temp", TopicStatusOK)
+	assertStatus("processed/temp", TopicStatusOK)
+
+	// The first failure marks the topic as errored
+	if broken.LastError() == "" {
+		t.Error("LastError() should record the failed execution")
+	}
+	assertStatus("processed/broken", TopicStatusError)
+
+	// The second failure opens the strategy's circuit
+	if err := broken.ProcessInputs("sensors/temp"); err == nil {
+		t.Fatal("expected execution to fail")
+	}
+	assertStatus("processed/broken", TopicStatusCircuitOpen)
+
+	// Exceeding the TTL makes the topic stale
+	healthy.SetTTL(time.Minute)
+	clock.Advance(2 * time.Minute)
+	assertStatus("sensors/temp", TopicStatusStale)
+	assertStatus("processed/temp", TopicStatusStale)
+
+	// A successful execution clears the error
+	if err := healthy.ProcessInputs("sensors/temp"); err != nil {
+		t.Fatalf("ProcessInputs failed: %v", err)
+	}
+	if healthy.LastError() != "" {
+		t.Errorf("LastError() = %q, want empty", healthy.LastError())
+	}
+
+	if _, err := manager.TopicStatus("missing"); err == nil {
+		t.Error("TopicStatus should fail for an unknown topic")
+	}
+}
+
+func TestTopicStatusErrorWithoutCircuitBreaker(t *testing.T) {
+	manager := NewManager(nil)
+	fail := true
+	manager.SetStrategyExecutor(&mockStrategyExecutor{
+		executeFunc: func(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+			if fail {
+				return nil, errors.New("boom")
+			}
+			return "ok", nil
+		},
+	})
+
+	manager.AddExternalTopic("sensors/temp")
+	topic, err := manager.AddInternalTopic("processed/temp", []string{"sensors/temp"}, nil, "mock", nil, false, false)
+	if err != nil {
+		t.Fatalf("Failed to add internal topic: %v", err)
+	}
+
+	topic.ProcessInputs("sensors/temp")
+	if status, _ := manager.TopicStatus("processed/temp"); status != TopicStatusError {
+		t.Errorf("status = %s, want %s", status, TopicStatusError)
+	}
+
+	fail = false
+	if err := topic.ProcessInputs("sensors/temp"); err != nil {
+		t.Fatalf("ProcessInputs failed: %v", err)
+	}
+	if status, _ := manager.TopicStatus("processed/temp"); status != TopicStatusOK {
+		t.Errorf("status = %s, want %s", status, TopicStatusOK)
+	}
+}
+
+func TestParseTTL(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"", 0, false},
+		{"5m", 5 * time.Minute, false},
+		{"0s", 0, true},
+		{"-1m", 0, true},
+		{"soon", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTTL(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseTTL(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParseTTL(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
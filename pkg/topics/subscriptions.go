@@ -0,0 +1,78 @@
+package topics
+
+import (
+	"sort"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/mqtt"
+)
+
+// SubscriptionSetter replaces the MQTT subscriptions with an exact set of
+// patterns. Subscribers implementing it are used for minimal subscriptions.
+type SubscriptionSetter interface {
+	SetSubscriptions(patterns []string)
+}
+
+// SetMinimalSubscriptions enables subscribing only to the patterns referenced
+// by internal topic inputs. The set is re-derived whenever topics change, so
+// patterns no longer used are unsubscribed.
+func (m *Manager) SetMinimalSubscriptions(enabled bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.minimalSubs = enabled
+	if enabled {
+		m.syncSubscriptionsUnsafe()
+	}
+}
+
+// RequiredSubscriptions returns the smallest set of patterns covering every
+// internal topic input that arrives over MQTT, sorted
+func (m *Manager) RequiredSubscriptions() []string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.requiredSubscriptionsUnsafe()
+}
+
+func (m *Manager) requiredSubscriptionsUnsafe() []string {
+	inputs := make(map[string]bool)
+	for _, topic := range m.internalTopics {
+		for _, input := range topic.config.Inputs {
+			if !m.isLocalInputUnsafe(input) {
+				inputs[input] = true
+			}
+		}
+	}
+
+	required := make([]string, 0, len(inputs))
+	for input := range inputs {
+		covered := false
+		for other := range inputs {
+			// Identical patterns are deduplicated by the map, so a pattern
+			// covering another is always broader
+			if other != input && mqtt.SubscriptionCovers(other, input) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			required = append(required, input)
+		}
+	}
+	sort.Strings(required)
+	return required
+}
+
+// syncSubscriptionsUnsafe hands the required subscriptions to the subscriber.
+// Assumes the lock is already held.
+func (m *Manager) syncSubscriptionsUnsafe() {
+	if m.subscriber == nil {
+		return
+	}
+	setter, ok := m.subscriber.(SubscriptionSetter)
+	if !ok {
+		m.logger.Printf("Warning: minimal subscriptions are not supported by the MQTT subscriber")
+		return
+	}
+	setter.SetSubscriptions(m.requiredSubscriptionsUnsafe())
+}
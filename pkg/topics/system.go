@@ -2,7 +2,6 @@ package topics
 
 import (
 	"fmt"
-	"sync"
 	"time"
 
 	"github.com/denwilliams/go-mqtt-automation/pkg/config"
@@ -11,10 +10,9 @@ import (
 type SystemTopic struct {
 	config    SystemTopicConfig
 	manager   *Manager
-	ticker    *time.Ticker
-	stopChan  chan bool
+	runner    *scheduleRunner
 	isRunning bool
-	wg        sync.WaitGroup
+	lastStats statsSnapshot // counters at the last update of a stats topic
 }
 
 func NewSystemTopic(name string, config map[string]interface{}) *SystemTopic {
@@ -28,7 +26,6 @@ func NewSystemTopic(name string, config map[string]interface{}) *SystemTopic {
 				Config:      config,
 			},
 		},
-		stopChan:  make(chan bool),
 		isRunning: false,
 	}
 
@@ -90,70 +87,94 @@ func (st *SystemTopic) Emit(value interface{}) error {
 	return nil
 }
 
-func (st *SystemTopic) Start() error {
-	if st.isRunning {
-		return nil
-	}
+// isScheduled reports whether the topic emits on an interval or cron schedule
+// rather than only on events
+func (st *SystemTopic) isScheduled() bool {
+	return st.config.Interval != "" || st.config.Cron != ""
+}
 
+// schedule parses the topic's interval, or its cron expression when it has
+// no interval
+func (st *SystemTopic) schedule() (Schedule, error) {
 	if st.config.Interval != "" {
 		duration, err := time.ParseDuration(st.config.Interval)
 		if err != nil {
-			return fmt.Errorf("invalid interval duration: %w", err)
+			return nil, fmt.Errorf("invalid interval duration: %w", err)
+		}
+		if duration <= 0 {
+			return nil, fmt.Errorf("invalid interval duration: %s", st.config.Interval)
 		}
+		return intervalSchedule{interval: duration}, nil
+	}
+	return parseCron(st.config.Cron)
+}
 
-		st.ticker = time.NewTicker(duration)
-		st.isRunning = true
+func (st *SystemTopic) Start() error {
+	if st.isRunning || !st.isScheduled() {
+		return nil
+	}
 
-		st.wg.Add(1)
-		go st.runTicker()
-	} else if st.config.Cron != "" {
-		// TODO: Implement cron scheduling
-		return fmt.Errorf("cron scheduling not yet implemented")
+	schedule, err := st.schedule()
+	if err != nil {
+		return err
 	}
 
+	var clock Clock = realClock{}
+	if st.manager != nil {
+		clock = st.manager.clock
+		st.lastStats = st.manager.stats.snapshot(time.Now())
+	}
+
+	st.runner = newScheduleRunner(schedule, clock, st.tick)
+	st.isRunning = true
+	st.runner.start()
+
 	return nil
 }
 
+// Stop stops the topic and waits for an emit in flight to finish. That emit
+// notifies the manager, so Stop must not be called with the manager's lock
+// held; use halt there instead.
 func (st *SystemTopic) Stop() {
-	if !st.isRunning {
-		return
+	if runner := st.halt(); runner != nil {
+		runner.wait()
 	}
+}
 
-	close(st.stopChan)
-	if st.ticker != nil {
-		st.ticker.Stop()
-		st.ticker = nil
+// halt stops the topic without waiting, returning the stopped runner (nil if
+// the topic was not running) for the caller to wait on once it has released
+// the manager's lock
+func (st *SystemTopic) halt() *scheduleRunner {
+	if !st.isRunning {
+		return nil
 	}
-	st.isRunning = false
 
-	// Wait for the goroutine to finish
-	st.wg.Wait()
+	runner := st.runner
+	runner.stop()
+	st.isRunning = false
+	st.runner = nil
+	return runner
 }
 
 func (st *SystemTopic) IsRunning() bool {
 	return st.isRunning
 }
 
-func (st *SystemTopic) runTicker() {
-	defer st.wg.Done()
-
-	for {
-		select {
-		case <-st.stopChan:
-			return
-		case t := <-st.ticker.C:
-			value := map[string]interface{}{
-				"timestamp": t.Unix(),
-				"iso_time":  t.Format(time.RFC3339),
-				"topic":     st.config.Name,
-			}
+// tick emits the topic's value each time its schedule elapses
+func (st *SystemTopic) tick(t time.Time) {
+	value, ok := st.statValue(t)
+	if !ok {
+		value = map[string]interface{}{
+			"timestamp": t.Unix(),
+			"iso_time":  t.Format(time.RFC3339),
+			"topic":     st.config.Name,
+		}
+	}
 
-			if err := st.Emit(value); err != nil {
-				// Log error but continue running
-				if st.manager != nil && st.manager.logger != nil {
-					st.manager.logger.Printf("Error emitting system topic %s: %v", st.config.Name, err)
-				}
-			}
+	if err := st.Emit(value); err != nil {
+		// Log error but continue running
+		if st.manager != nil && st.manager.logger != nil {
+			st.manager.logger.Printf("Error emitting system topic %s: %v", st.config.Name, err)
 		}
 	}
 }
@@ -190,6 +211,26 @@ func CreateDefaultSystemTopics(cfg config.SystemTopicsConfig) []*SystemTopic {
 		topics = append(topics, NewSystemTopic(name, config))
 	}
 
+	// Create aggregate stats topics
+	if cfg.StatsInterval != "" && cfg.StatsInterval != "off" {
+		statTopics := []struct {
+			stat        string
+			description string
+		}{
+			{StatTopicCount, "Number of topics"},
+			{StatMessagesPerMinute, "MQTT messages received per minute"},
+			{StatAvgExecutionMs, "Average strategy execution time in milliseconds"},
+		}
+		for _, st := range statTopics {
+			config := map[string]interface{}{
+				"interval":    cfg.StatsInterval,
+				"stat":        st.stat,
+				"description": st.description,
+			}
+			topics = append(topics, NewSystemTopic("system/stats/"+st.stat, config))
+		}
+	}
+
 	// Create event topics
 	eventTopics := []struct {
 		name        string
@@ -199,6 +240,8 @@ func CreateDefaultSystemTopics(cfg config.SystemTopicsConfig) []*SystemTopic {
 		{"system/events/shutdown", "System shutdown event"},
 		{"system/events/error", "System error event"},
 		{"system/events/heartbeat", "System heartbeat"},
+		{"system/events/mqtt_connected", "MQTT broker connected"},
+		{"system/events/mqtt_disconnected", "MQTT broker connection lost"},
 	}
 
 	for _, et := range eventTopics {
@@ -211,14 +254,32 @@ func CreateDefaultSystemTopics(cfg config.SystemTopicsConfig) []*SystemTopic {
 	return topics
 }
 
-// EmitSystemEvent is a helper to emit system events
+// SystemEventRecorder stores system events in the system event log
+type SystemEventRecorder interface {
+	RecordSystemEvent(eventType string, data interface{}, at time.Time) error
+}
+
+// SetSystemEventRecorder sets where system events are recorded
+func (m *Manager) SetSystemEventRecorder(recorder SystemEventRecorder) {
+	m.eventRecorder = recorder
+}
+
+// EmitSystemEvent is a helper to emit system events. Events other than
+// heartbeats are also recorded in the system event log.
 func (st *SystemTopic) EmitSystemEvent(eventType string, data interface{}) error {
+	now := time.Now()
 	event := map[string]interface{}{
 		"event_type": eventType,
-		"timestamp":  time.Now().Unix(),
-		"iso_time":   time.Now().Format(time.RFC3339),
+		"timestamp":  now.Unix(),
+		"iso_time":   now.Format(time.RFC3339),
 		"data":       data,
 	}
 
+	if st.manager != nil && st.manager.eventRecorder != nil && eventType != "heartbeat" {
+		if err := st.manager.eventRecorder.RecordSystemEvent(eventType, data, now); err != nil {
+			st.manager.logger.Printf("Failed to record system event %s: %v", eventType, err)
+		}
+	}
+
 	return st.Emit(event)
 }
@@ -0,0 +1,142 @@
+package topics
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/config"
+)
+
+func TestSystemTopicsTickOnlyOnceStarted(t *testing.T) {
+	manager := NewManager(nil)
+	var mutex sync.Mutex
+	triggers := make(map[string]int)
+	executions := func() map[string]int {
+		mutex.Lock()
+		defer mutex.Unlock()
+		copied := make(map[string]int, len(triggers))
+		for topic, n := range triggers {
+			copied[topic] = n
+		}
+		return copied
+	}
+	manager.SetStrategyExecutor(&mockStrategyExecutor{
+		executeFunc: func(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			triggers[triggerTopic]++
+			return true, nil
+		},
+	})
+	manager.SetStateManager(&mockStateManager{
+		loadConfigFunc: func(topicName string) (interface{}, error) {
+			return SystemTopicConfig{
+				BaseTopicConfig: BaseTopicConfig{Name: topicName, Type: TopicTypeSystem, Config: map[string]interface{}{"interval": "10ms"}},
+				Interval:        "10ms",
+			}, nil
+		},
+	})
+
+	// Phase one: system topics are created, then the topics using them load
+	if err := manager.InitializeSystemTopics(config.SystemTopicsConfig{TickerIntervals: []string{"10ms"}}); err != nil {
+		t.Fatalf("InitializeSystemTopics failed: %v", err)
+	}
+	if err := manager.ReloadTopicFromDatabase("system/ticker/custom"); err != nil {
+		t.Fatalf("ReloadTopicFromDatabase failed: %v", err)
+	}
+	mustAddInternalTopic(t, manager, "automation/tick", []string{"system/ticker/10ms"})
+	mustAddInternalTopic(t, manager, "automation/custom", []string{"system/ticker/custom"})
+
+	time.Sleep(50 * time.Millisecond)
+	if triggered := executions(); len(triggered) != 0 {
+		t.Fatalf("executions %v before the system topics were started, want none", triggered)
+	}
+	for _, name := range []string{"system/ticker/10ms", "system/ticker/custom"} {
+		if manager.systemTopics[name].IsRunning() {
+			t.Errorf("%s is running before the system topics were started", name)
+		}
+	}
+
+	// Phase two: tickers start once everything has loaded
+	if err := manager.StartSystemTopics(); err != nil {
+		t.Fatalf("StartSystemTopics failed: %v", err)
+	}
+	defer manager.StopSystemTopics()
+
+	deadline := time.Now().Add(time.Second)
+	for triggered := executions(); triggered["system/ticker/10ms"] == 0 || triggered["system/ticker/custom"] == 0; triggered = executions() {
+		if time.Now().After(deadline) {
+			t.Fatal("tickers did not trigger their dependents after starting")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestStopSystemTopicsWithTickInFlight(t *testing.T) {
+	manager := NewManager(nil)
+	inFlight := make(chan struct{}, 1)
+	release := make(chan struct{})
+	manager.SetStrategyExecutor(&mockStrategyExecutor{
+		executeFunc: func(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+			select {
+			case inFlight <- struct{}{}:
+			default:
+			}
+			<-release
+			return true, nil
+		},
+	})
+	manager.SetStateManager(&mockStateManager{
+		loadConfigFunc: func(topicName string) (interface{}, error) {
+			return SystemTopicConfig{
+				BaseTopicConfig: BaseTopicConfig{Name: topicName, Type: TopicTypeSystem, Config: map[string]interface{}{"interval": "1ms"}},
+				Interval:        "1ms",
+			}, nil
+		},
+	})
+
+	if err := manager.InitializeSystemTopics(config.SystemTopicsConfig{TickerIntervals: []string{"1ms"}}); err != nil {
+		t.Fatalf("InitializeSystemTopics failed: %v", err)
+	}
+	if err := manager.ReloadTopicFromDatabase("system/ticker/custom"); err != nil {
+		t.Fatalf("ReloadTopicFromDatabase failed: %v", err)
+	}
+	mustAddInternalTopic(t, manager, "automation/tick", []string{"system/ticker/1ms"})
+	mustAddInternalTopic(t, manager, "automation/custom", []string{"system/ticker/custom"})
+
+	if err := manager.StartSystemTopics(); err != nil {
+		t.Fatalf("StartSystemTopics failed: %v", err)
+	}
+
+	select {
+	case <-inFlight:
+	case <-time.After(time.Second):
+		t.Fatal("no tick reached its dependent")
+	}
+
+	// Reloading and stopping while a tick is blocked in its dependent must not
+	// deadlock on the manager lock the tick needs to finish
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		if err := manager.ReloadTopicFromDatabase("system/ticker/custom"); err != nil {
+			t.Errorf("ReloadTopicFromDatabase failed: %v", err)
+		}
+		manager.StopSystemTopics()
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("stopping system topics with a tick in flight deadlocked")
+	}
+	for _, name := range []string{"system/ticker/1ms", "system/ticker/custom"} {
+		if manager.GetSystemTopic(name).IsRunning() {
+			t.Errorf("%s is still running after StopSystemTopics", name)
+		}
+	}
+}
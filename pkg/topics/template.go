@@ -0,0 +1,52 @@
+package topics
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// topicTemplatePattern matches placeholders such as {segment:1} or {segment:-1}
+var topicTemplatePattern = regexp.MustCompile(`\{segment:(-?\d+)\}`)
+
+// IsTopicTemplate reports whether a topic contains triggering-input placeholders
+func IsTopicTemplate(topic string) bool {
+	return topicTemplatePattern.MatchString(topic)
+}
+
+// ExpandTopicTemplate replaces {segment:N} placeholders with segments of the
+// triggering input topic. Segments are zero-based; negative indexes count from
+// the end, so for sensors/kitchen/temp {segment:1} is "kitchen" and
+// {segment:-1} is "temp".
+func ExpandTopicTemplate(template, triggerTopic string) (string, error) {
+	if !IsTopicTemplate(template) {
+		return template, nil
+	}
+
+	if triggerTopic == "" || triggerTopic == ScheduledTrigger {
+		return "", fmt.Errorf("topic template %s requires a triggering input topic", template)
+	}
+
+	segments := strings.Split(triggerTopic, "/")
+	var expandErr error
+
+	result := topicTemplatePattern.ReplaceAllStringFunc(template, func(placeholder string) string {
+		index, _ := strconv.Atoi(topicTemplatePattern.FindStringSubmatch(placeholder)[1])
+		if index < 0 {
+			index += len(segments)
+		}
+		if index < 0 || index >= len(segments) {
+			if expandErr == nil {
+				expandErr = fmt.Errorf("topic template %s: %s is out of range for trigger topic %s", template, placeholder, triggerTopic)
+			}
+			return placeholder
+		}
+		return segments[index]
+	})
+
+	if expandErr != nil {
+		return "", expandErr
+	}
+	return result, nil
+}
@@ -0,0 +1,43 @@
+package topics
+
+import (
+	"testing"
+)
+
+func TestExpandTopicTemplate(t *testing.T) {
+	tests := []struct {
+		name         string
+		template     string
+		triggerTopic string
+		want         string
+		wantErr      bool
+	}{
+		{name: "plain topic unchanged", template: "status/all", triggerTopic: "sensors/kitchen/temp", want: "status/all"},
+		{name: "plain topic without trigger", template: "status/all", triggerTopic: "", want: "status/all"},
+		{name: "single segment", template: "status/{segment:1}", triggerTopic: "sensors/kitchen/temp", want: "status/kitchen"},
+		{name: "multiple segments", template: "{segment:0}/{segment:1}/avg", triggerTopic: "sensors/kitchen/temp", want: "sensors/kitchen/avg"},
+		{name: "negative index", template: "last/{segment:-1}", triggerTopic: "sensors/kitchen/temp", want: "last/temp"},
+		{name: "relative subtopic", template: "/{segment:1}", triggerTopic: "sensors/kitchen/temp", want: "/kitchen"},
+		{name: "out of range", template: "status/{segment:5}", triggerTopic: "sensors/kitchen/temp", wantErr: true},
+		{name: "missing trigger", template: "status/{segment:1}", triggerTopic: "", wantErr: true},
+		{name: "scheduled trigger", template: "status/{segment:1}", triggerTopic: ScheduledTrigger, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExpandTopicTemplate(tt.template, tt.triggerTopic)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ExpandTopicTemplate(%q, %q) expected error, got %q", tt.template, tt.triggerTopic, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ExpandTopicTemplate(%q, %q) failed: %v", tt.template, tt.triggerTopic, err)
+			}
+			if got != tt.want {
+				t.Errorf("ExpandTopicTemplate(%q, %q) = %q, want %q", tt.template, tt.triggerTopic, got, tt.want)
+			}
+		})
+	}
+}
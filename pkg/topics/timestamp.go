@@ -0,0 +1,140 @@
+package topics
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/mqtt"
+)
+
+// DefaultTimestampMaxSkew is how far a payload timestamp may be from the
+// receive time when its rule sets no limit
+const DefaultTimestampMaxSkew = 5 * time.Minute
+
+// PayloadTimestamp takes the update time of external topics matching Pattern
+// from the timestamp at Pointer (a JSON pointer) in their payloads.
+// Timestamps further than MaxSkew from the receive time, in either direction,
+// are ignored.
+type PayloadTimestamp struct {
+	Pattern string
+	Pointer string
+	MaxSkew time.Duration
+}
+
+// SetPayloadTimestamps sets the payload timestamp rules of external topics.
+// The first rule whose pattern matches a topic applies.
+func (m *Manager) SetPayloadTimestamps(rules []PayloadTimestamp) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.timestampRules = rules
+}
+
+// payloadTimestampFor returns the payload timestamp rule for a topic name
+func (m *Manager) payloadTimestampFor(name string) (PayloadTimestamp, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	for _, rule := range m.timestampRules {
+		if mqtt.TopicMatches(rule.Pattern, name) {
+			return rule, true
+		}
+	}
+	return PayloadTimestamp{}, false
+}
+
+// updateTime returns when a payload's value was produced: its embedded
+// timestamp when a rule applies and the timestamp is within the allowed
+// skew of received, otherwise received
+func (et *ExternalTopic) updateTime(value interface{}, received time.Time) time.Time {
+	if et.manager == nil {
+		return received
+	}
+	rule, ok := et.manager.payloadTimestampFor(et.config.Name)
+	if !ok {
+		return received
+	}
+
+	raw, err := ResolveJSONPointer(value, rule.Pointer)
+	if err == nil {
+		var timestamp time.Time
+		if timestamp, err = ParsePayloadTimestamp(raw); err == nil {
+			maxSkew := rule.MaxSkew
+			if maxSkew <= 0 {
+				maxSkew = DefaultTimestampMaxSkew
+			}
+			skew := timestamp.Sub(received)
+			if skew <= maxSkew && skew >= -maxSkew {
+				return timestamp
+			}
+			err = fmt.Errorf("timestamp %s is %v from the receive time (max %v)", timestamp.Format(time.RFC3339Nano), skew.Round(time.Millisecond), maxSkew)
+		}
+	}
+	et.manager.logger.Printf("Warning: topic %s payload timestamp %s ignored: %v", et.config.Name, rule.Pointer, err)
+	return received
+}
+
+// ResolveJSONPointer returns the part of a JSON-decoded value that an RFC 6901
+// pointer such as "/readings/0/ts" refers to. The empty pointer refers to the
+// whole value.
+func ResolveJSONPointer(value interface{}, pointer string) (interface{}, error) {
+	if pointer == "" {
+		return value, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", pointer)
+	}
+
+	current := value
+	for _, token := range strings.Split(pointer[1:], "/") {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch v := current.(type) {
+		case map[string]interface{}:
+			next, ok := v[token]
+			if !ok {
+				return nil, fmt.Errorf("%s: no field %q", pointer, token)
+			}
+			current = next
+		case []interface{}:
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, fmt.Errorf("%s: no element %q", pointer, token)
+			}
+			current = v[index]
+		default:
+			return nil, fmt.Errorf("%s: %q is not in an object or array", pointer, token)
+		}
+	}
+	return current, nil
+}
+
+// ParsePayloadTimestamp reads an RFC 3339 string or a Unix time in seconds
+// or, for values beyond year 33658 in seconds, milliseconds
+func ParsePayloadTimestamp(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case string:
+		if timestamp, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return timestamp, nil
+		}
+		number, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("cannot parse timestamp %q", v)
+		}
+		return unixTimestamp(number), nil
+	case float64:
+		return unixTimestamp(v), nil
+	default:
+		return time.Time{}, fmt.Errorf("cannot parse timestamp %v", value)
+	}
+}
+
+func unixTimestamp(number float64) time.Time {
+	if math.Abs(number) >= 1e12 {
+		return time.UnixMilli(int64(number))
+	}
+	seconds, fraction := math.Modf(number)
+	return time.Unix(int64(seconds), int64(fraction*1e9))
+}
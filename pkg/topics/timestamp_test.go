@@ -0,0 +1,118 @@
+package topics
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExternalTopicPayloadTimestamp(t *testing.T) {
+	embedded := time.Now().Add(-30 * time.Second).Truncate(time.Millisecond)
+
+	tests := []struct {
+		name        string
+		topic       string
+		payload     string
+		wantTime    time.Time // zero expects the receive time
+		wantWarning bool
+	}{
+		{
+			name:     "RFC 3339 timestamp",
+			topic:    "sensors/temp",
+			payload:  fmt.Sprintf(`{"value": 21.5, "meta": {"ts": %q}}`, embedded.Format(time.RFC3339Nano)),
+			wantTime: embedded,
+		},
+		{
+			name:     "Unix milliseconds",
+			topic:    "sensors/temp",
+			payload:  fmt.Sprintf(`{"value": 21.5, "meta": {"ts": %d}}`, embedded.UnixMilli()),
+			wantTime: embedded,
+		},
+		{
+			name:        "too far in the future",
+			topic:       "sensors/temp",
+			payload:     fmt.Sprintf(`{"value": 21.5, "meta": {"ts": %d}}`, time.Now().Add(time.Hour).Unix()),
+			wantWarning: true,
+		},
+		{
+			name:        "too far in the past",
+			topic:       "sensors/temp",
+			payload:     `{"value": 21.5, "meta": {"ts": "2001-01-01T00:00:00Z"}}`,
+			wantWarning: true,
+		},
+		{
+			name:        "missing timestamp",
+			topic:       "sensors/temp",
+			payload:     `{"value": 21.5}`,
+			wantWarning: true,
+		},
+		{
+			name:    "topic without a rule",
+			topic:   "switches/hall",
+			payload: fmt.Sprintf(`{"on": true, "meta": {"ts": %d}}`, embedded.Unix()),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			manager := NewManager(log.New(&logs, "", 0))
+			manager.SetPayloadTimestamps([]PayloadTimestamp{{Pattern: "sensors/#", Pointer: "/meta/ts", MaxSkew: 10 * time.Minute}})
+			topic := mustAddExternalTopic(t, manager, tt.topic)
+
+			before := time.Now()
+			if err := topic.UpdateFromMQTT([]byte(tt.payload), false); err != nil {
+				t.Fatalf("UpdateFromMQTT failed: %v", err)
+			}
+			after := time.Now()
+
+			updated := topic.LastUpdated()
+			if !tt.wantTime.IsZero() {
+				if !updated.Equal(tt.wantTime) {
+					t.Errorf("LastUpdated = %v, want the embedded %v", updated, tt.wantTime)
+				}
+			} else if updated.Before(before) || updated.After(after) {
+				t.Errorf("LastUpdated = %v, want the receive time", updated)
+			}
+			if warned := strings.Contains(logs.String(), "payload timestamp /meta/ts ignored"); warned != tt.wantWarning {
+				t.Errorf("warning logged = %v, want %v:\n%s", warned, tt.wantWarning, logs.String())
+			}
+		})
+	}
+}
+
+func TestResolveJSONPointer(t *testing.T) {
+	value := map[string]interface{}{
+		"readings": []interface{}{map[string]interface{}{"ts": 1.5}},
+		"a/b":      "slash",
+		"m~n":      "tilde",
+	}
+
+	tests := []struct {
+		pointer string
+		want    interface{}
+		wantErr bool
+	}{
+		{pointer: "/readings/0/ts", want: 1.5},
+		{pointer: "/a~1b", want: "slash"},
+		{pointer: "/m~0n", want: "tilde"},
+		{pointer: "/readings/1", wantErr: true},
+		{pointer: "/missing", wantErr: true},
+		{pointer: "/a~1b/deeper", wantErr: true},
+		{pointer: "readings", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ResolveJSONPointer(value, tt.pointer)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ResolveJSONPointer(%q) error = %v, wantErr %v", tt.pointer, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("ResolveJSONPointer(%q) = %v, want %v", tt.pointer, got, tt.want)
+		}
+	}
+}
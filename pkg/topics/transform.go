@@ -0,0 +1,200 @@
+package topics
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/strategy"
+)
+
+// TransformStrategyID is the strategy ID of internal topics computed by a
+// built-in transform rather than a strategy
+const TransformStrategyID = "transform"
+
+// TransformType names a built-in transform
+type TransformType string
+
+const (
+	// TransformRate emits the rate of change of its input per Transform.Per,
+	// e.g. power from an energy counter
+	TransformRate TransformType = "rate"
+)
+
+// CounterReset is how a rate transform handles an input that decreased
+type CounterReset string
+
+const (
+	// CounterResetSkip emits nothing and uses the new value as the baseline
+	CounterResetSkip CounterReset = "skip"
+	// CounterResetFromZero assumes the counter restarted at zero, so the new
+	// value is the increase since the reset
+	CounterResetFromZero CounterReset = "from-zero"
+	// CounterResetNegative emits the negative rate, for inputs that are not
+	// counters
+	CounterResetNegative CounterReset = "negative"
+)
+
+// Transform computes an internal topic's value from its single input
+// without a strategy
+type Transform struct {
+	Type  TransformType `json:"type"`
+	Reset CounterReset  `json:"reset,omitempty"` // empty uses CounterResetSkip
+	Per   string        `json:"per,omitempty"`   // rate unit as a duration; empty is per second
+}
+
+// ParseTransform reads a transform as stored in the topic config (a map
+// decoded from JSON) or set directly
+func ParseTransform(value interface{}) (*Transform, error) {
+	var transform Transform
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case *Transform:
+		if v == nil {
+			return nil, nil
+		}
+		transform = *v
+	case Transform:
+		transform = v
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid transform: %w", err)
+		}
+		if err := json.Unmarshal(data, &transform); err != nil {
+			return nil, fmt.Errorf("invalid transform: %w", err)
+		}
+	}
+
+	if transform.Type != TransformRate {
+		return nil, fmt.Errorf("invalid transform type %q: expected rate", transform.Type)
+	}
+	switch transform.Reset {
+	case "", CounterResetSkip, CounterResetFromZero, CounterResetNegative:
+	default:
+		return nil, fmt.Errorf("invalid counter reset %q: expected skip, from-zero or negative", transform.Reset)
+	}
+	if _, err := transform.per(); err != nil {
+		return nil, err
+	}
+	return &transform, nil
+}
+
+// per returns the duration the rate is expressed per
+func (t *Transform) per() (time.Duration, error) {
+	if t.Per == "" {
+		return time.Second, nil
+	}
+	per, err := time.ParseDuration(t.Per)
+	if err != nil || per <= 0 {
+		return 0, fmt.Errorf("invalid transform per %q: expected a positive duration", t.Per)
+	}
+	return per, nil
+}
+
+// rateSample is the input value a rate is computed from
+type rateSample struct {
+	value float64
+	at    time.Time
+}
+
+// rate returns the rate of change from the previous sample to value at now,
+// and whether one should be emitted
+func (t *Transform) rate(previous rateSample, value float64, now time.Time) (float64, bool) {
+	elapsed := now.Sub(previous.at)
+	if elapsed <= 0 {
+		return 0, false
+	}
+	per, _ := t.per()
+
+	delta := value - previous.value
+	if delta < 0 {
+		switch t.Reset {
+		case CounterResetFromZero:
+			delta = value
+		case CounterResetNegative:
+		default:
+			return 0, false
+		}
+	}
+	return delta * per.Seconds() / elapsed.Seconds(), true
+}
+
+// GetTransform returns the topic's built-in transform, or nil if it runs a
+// strategy
+func (it *InternalTopic) GetTransform() *Transform {
+	transform, err := ParseTransform(it.config.Config["transform"])
+	if err != nil {
+		return nil
+	}
+	return transform
+}
+
+// SetTransform sets (or clears, when nil) the built-in transform computing the
+// topic's value.
+func (it *InternalTopic) SetTransform(transform *Transform) error {
+	if it.config.Config == nil {
+		it.config.Config = make(map[string]interface{})
+	}
+	it.rateMutex.Lock()
+	it.rateSample = nil
+	it.rateMutex.Unlock()
+
+	if transform == nil {
+		delete(it.config.Config, "transform")
+		return nil
+	}
+	if _, err := ParseTransform(transform); err != nil {
+		return err
+	}
+	if len(it.config.Inputs) != 1 {
+		return fmt.Errorf("transform %s needs exactly one input, got %d", transform.Type, len(it.config.Inputs))
+	}
+
+	data, err := json.Marshal(transform)
+	if err != nil {
+		return fmt.Errorf("invalid transform: %w", err)
+	}
+	var stored map[string]interface{}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("invalid transform: %w", err)
+	}
+	it.config.Config["transform"] = stored
+	return nil
+}
+
+// applyTransform computes the topic's value from its input. The first value
+// only sets the baseline, so nothing is emitted for it.
+func (it *InternalTopic) applyTransform(transform *Transform, inputValues map[string]interface{}) ([]strategy.EmitEvent, error) {
+	if len(it.config.Inputs) != 1 {
+		return nil, fmt.Errorf("transform %s needs exactly one input, got %d", transform.Type, len(it.config.Inputs))
+	}
+	input := it.config.Inputs[0]
+	if name, ok := it.config.InputNames[input]; ok {
+		input = name
+	}
+	raw := inputValues[input]
+	if raw == nil {
+		return nil, nil
+	}
+	value, ok := validationNumber(raw)
+	if !ok {
+		return nil, fmt.Errorf("transform %s input %v is not a number", transform.Type, raw)
+	}
+
+	now := it.manager.clock.Now()
+	it.rateMutex.Lock()
+	defer it.rateMutex.Unlock()
+
+	previous := it.rateSample
+	it.rateSample = &rateSample{value: value, at: now}
+	if previous == nil {
+		return nil, nil
+	}
+	rate, ok := transform.rate(*previous, value, now)
+	if !ok {
+		return nil, nil
+	}
+	return []strategy.EmitEvent{{Topic: "", Value: rate}}, nil
+}
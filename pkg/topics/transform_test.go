@@ -0,0 +1,125 @@
+package topics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTransformRate(t *testing.T) {
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	previous := rateSample{value: 100, at: start}
+
+	tests := []struct {
+		name      string
+		transform Transform
+		value     float64
+		elapsed   time.Duration
+		want      float64
+		wantEmit  bool
+	}{
+		{name: "per second", transform: Transform{Type: TransformRate}, value: 112.5, elapsed: 5 * time.Second, want: 2.5, wantEmit: true},
+		{name: "per hour", transform: Transform{Type: TransformRate, Per: "1h"}, value: 100.5, elapsed: 30 * time.Minute, want: 1, wantEmit: true},
+		{name: "no time elapsed", transform: Transform{Type: TransformRate}, value: 112.5, wantEmit: false},
+		{name: "reset skipped", transform: Transform{Type: TransformRate}, value: 4, elapsed: 2 * time.Second, wantEmit: false},
+		{name: "reset from zero", transform: Transform{Type: TransformRate, Reset: CounterResetFromZero}, value: 4, elapsed: 2 * time.Second, want: 2, wantEmit: true},
+		{name: "negative rate", transform: Transform{Type: TransformRate, Reset: CounterResetNegative}, value: 96, elapsed: 2 * time.Second, want: -2, wantEmit: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, emit := tt.transform.rate(previous, tt.value, start.Add(tt.elapsed))
+			if emit != tt.wantEmit || got != tt.want {
+				t.Errorf("rate() = %v, %v; want %v, %v", got, emit, tt.want, tt.wantEmit)
+			}
+		})
+	}
+}
+
+func TestParseTransform(t *testing.T) {
+	// Transforms loaded from the database decode as a generic map
+	transform, err := ParseTransform(map[string]interface{}{"type": "rate", "reset": "from-zero", "per": "1m"})
+	if err != nil {
+		t.Fatalf("ParseTransform failed: %v", err)
+	}
+	if transform.Type != TransformRate || transform.Reset != CounterResetFromZero || transform.Per != "1m" {
+		t.Errorf("transform = %+v", transform)
+	}
+
+	invalid := []interface{}{
+		map[string]interface{}{"type": "delta"},
+		map[string]interface{}{"type": "rate", "reset": "wrap"},
+		map[string]interface{}{"type": "rate", "per": "-1s"},
+		"rate",
+	}
+	for _, value := range invalid {
+		if _, err := ParseTransform(value); err == nil {
+			t.Errorf("ParseTransform(%v) accepted an invalid transform", value)
+		}
+	}
+}
+
+func TestInternalTopicRateTransform(t *testing.T) {
+	manager := NewManager(nil)
+	clock := newFakeClock(time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC))
+	manager.SetClock(clock)
+
+	counter := mustAddExternalTopic(t, manager, "meters/energy")
+	power, err := manager.AddInternalTopic("house/power", []string{"meters/energy"}, map[string]string{"meters/energy": "energy"}, TransformStrategyID, nil, false, false)
+	if err != nil {
+		t.Fatalf("Failed to add internal topic: %v", err)
+	}
+	// Energy in Wh, so the rate per hour is the power in W
+	if err := power.SetTransform(&Transform{Type: TransformRate, Per: "1h"}); err != nil {
+		t.Fatalf("SetTransform failed: %v", err)
+	}
+
+	// The first reading only sets the baseline
+	if err := counter.Emit(1000.0); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	if power.LastValue() != nil {
+		t.Fatalf("baseline emitted %v", power.LastValue())
+	}
+
+	readings := []struct {
+		after time.Duration
+		value float64
+		want  float64
+	}{
+		{after: 10 * time.Second, value: 1001.5, want: 540},
+		{after: 30 * time.Second, value: 1006.5, want: 600},
+		{after: time.Minute, value: 1006.5, want: 0},
+	}
+	for _, reading := range readings {
+		clock.Advance(reading.after)
+		if err := counter.Emit(reading.value); err != nil {
+			t.Fatalf("Emit failed: %v", err)
+		}
+		if power.LastValue() != reading.want {
+			t.Errorf("rate after %v reading %v = %v, want %v", reading.after, reading.value, power.LastValue(), reading.want)
+		}
+	}
+
+	// A counter reset is skipped by default and becomes the new baseline
+	clock.Advance(10 * time.Second)
+	if err := counter.Emit(0.5); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	if power.LastValue() != 0.0 {
+		t.Errorf("counter reset emitted %v", power.LastValue())
+	}
+	clock.Advance(10 * time.Second)
+	if err := counter.Emit(2.0); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	if power.LastValue() != 540.0 {
+		t.Errorf("rate after reset = %v, want 540", power.LastValue())
+	}
+
+	if _, err := manager.AddInternalTopic("house/two", []string{"meters/energy", "meters/gas"}, nil, TransformStrategyID, nil, false, false); err != nil {
+		t.Fatalf("Failed to add internal topic: %v", err)
+	}
+	if err := manager.GetInternalTopic("house/two").SetTransform(&Transform{Type: TransformRate}); err == nil {
+		t.Error("SetTransform accepted a topic with two inputs")
+	}
+}
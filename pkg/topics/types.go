@@ -1,8 +1,13 @@
 package topics
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 type TopicType string
@@ -13,6 +18,88 @@ const (
 	TopicTypeSystem   TopicType = "system"
 )
 
+// NullPolicy controls what an internal topic does when its strategy produces
+// no main value (returns null or undefined)
+type NullPolicy string
+
+const (
+	// NullPolicyKeep leaves the previous value in place (default)
+	NullPolicyKeep NullPolicy = "keep"
+	// NullPolicyEmitNull sets the value to null and notifies dependents
+	NullPolicyEmitNull NullPolicy = "emit-null"
+	// NullPolicyClear resets the stored value to null without notifying dependents
+	NullPolicyClear NullPolicy = "clear"
+)
+
+// ParseNullPolicy validates a null policy name, treating empty as NullPolicyKeep
+func ParseNullPolicy(policy string) (NullPolicy, error) {
+	switch NullPolicy(policy) {
+	case "", NullPolicyKeep:
+		return NullPolicyKeep, nil
+	case NullPolicyEmitNull, NullPolicyClear:
+		return NullPolicy(policy), nil
+	default:
+		return "", fmt.Errorf("invalid null policy %q: expected keep, emit-null or clear", policy)
+	}
+}
+
+// MissingInputPolicy controls what an internal topic does when one of its
+// (non-wildcard) input topics does not exist yet
+type MissingInputPolicy string
+
+const (
+	// MissingInputNil passes nil for the missing input (default)
+	MissingInputNil MissingInputPolicy = "nil"
+	// MissingInputSkip skips execution until every input exists
+	MissingInputSkip MissingInputPolicy = "skip-execution"
+	// MissingInputError reports an error and skips execution
+	MissingInputError MissingInputPolicy = "error"
+)
+
+// ParseMissingInputPolicy validates a missing input policy name, treating
+// empty as MissingInputNil
+func ParseMissingInputPolicy(policy string) (MissingInputPolicy, error) {
+	switch MissingInputPolicy(policy) {
+	case "", MissingInputNil:
+		return MissingInputNil, nil
+	case MissingInputSkip, MissingInputError:
+		return MissingInputPolicy(policy), nil
+	default:
+		return "", fmt.Errorf("invalid missing input policy %q: expected nil, skip-execution or error", policy)
+	}
+}
+
+// DefaultMaxTopicNameLength is the longest topic name accepted unless the
+// manager is configured otherwise
+const DefaultMaxTopicNameLength = 256
+
+// ValidateTopicName checks that name can be used as a topic: non-empty, at most
+// maxLength bytes (0 means unlimited), without MQTT wildcards, leading or
+// trailing slashes, or control characters
+func ValidateTopicName(name string, maxLength int) error {
+	if name == "" {
+		return fmt.Errorf("topic name is required")
+	}
+	if maxLength > 0 && len(name) > maxLength {
+		return fmt.Errorf("topic name is %d characters long, exceeding the maximum of %d", len(name), maxLength)
+	}
+	if !utf8.ValidString(name) {
+		return fmt.Errorf("topic name %q is not valid UTF-8", name)
+	}
+	if strings.ContainsAny(name, "+#") {
+		return fmt.Errorf("topic name %q must not contain MQTT wildcards (+ or #)", name)
+	}
+	if strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") {
+		return fmt.Errorf("topic name %q must not start or end with /", name)
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("topic name %q must not contain control characters", name)
+		}
+	}
+	return nil
+}
+
 type Topic interface {
 	Name() string
 	Type() TopicType
@@ -22,6 +109,10 @@ type Topic interface {
 	SetManager(manager *Manager)
 }
 
+// BaseTopicConfig holds the fields common to every topic type. Config keeps
+// the per-topic settings made through the topic's setters (TTLs, schedules,
+// transforms and so on) and is saved with the topic, so the settings are
+// persisted with it.
 type BaseTopicConfig struct {
 	Name        string                 `json:"name" db:"name"`
 	Type        TopicType              `json:"type" db:"type"`
@@ -54,6 +145,12 @@ type TopicEvent struct {
 	PreviousValue interface{}
 	Timestamp     time.Time
 	TriggerTopic  string
+	// Retained is set when the value came from a retained MQTT message
+	Retained bool
+
+	// ctx is the propagation chain the update is part of; nil for updates
+	// that start a chain
+	ctx context.Context
 }
 
 func (btc *BaseTopicConfig) MarshalConfig() (string, error) {
@@ -0,0 +1,296 @@
+package topics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/metrics"
+)
+
+// ValidationAction is what happens to a topic value that fails the topic's
+// validation rules
+type ValidationAction string
+
+const (
+	// ValidationDrop ignores the value; the topic keeps its last value and
+	// dependents are not notified
+	ValidationDrop ValidationAction = "drop"
+	// ValidationClamp bounds numbers to min/max. Values that cannot be
+	// clamped (enum or pattern failures) are dropped.
+	ValidationClamp ValidationAction = "clamp"
+	// ValidationKeepLast emits the last valid value in place of the invalid
+	// one, so the topic stays fresh. Dropped when there is no last value.
+	ValidationKeepLast ValidationAction = "keep-last"
+)
+
+// ParseValidationAction validates a validation action, treating empty as drop
+func ParseValidationAction(value string) (ValidationAction, error) {
+	switch action := ValidationAction(value); action {
+	case "":
+		return ValidationDrop, nil
+	case ValidationDrop, ValidationClamp, ValidationKeepLast:
+		return action, nil
+	default:
+		return "", fmt.Errorf("invalid validation action %q (must be drop, clamp or keep-last)", value)
+	}
+}
+
+// ValidationRules reject bad values (such as sensor glitches) before they are
+// stored or propagate to dependent topics. Nil values are not validated.
+type ValidationRules struct {
+	Min     *float64         `json:"min,omitempty"`     // numbers below min fail
+	Max     *float64         `json:"max,omitempty"`     // numbers above max fail
+	Enum    []interface{}    `json:"enum,omitempty"`    // the value must equal one of these
+	Pattern string           `json:"pattern,omitempty"` // strings must match this regular expression
+	Action  ValidationAction `json:"action,omitempty"`  // empty drops invalid values
+}
+
+// ParseValidationRules reads validation rules as stored in the topic config
+// (a map decoded from JSON) or set directly
+func ParseValidationRules(value interface{}) (*ValidationRules, error) {
+	var rules ValidationRules
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case *ValidationRules:
+		if v == nil {
+			return nil, nil
+		}
+		rules = *v
+	case ValidationRules:
+		rules = v
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid validation rules: %w", err)
+		}
+		if err := json.Unmarshal(data, &rules); err != nil {
+			return nil, fmt.Errorf("invalid validation rules: %w", err)
+		}
+	}
+
+	if _, err := ParseValidationAction(string(rules.Action)); err != nil {
+		return nil, err
+	}
+	if rules.Min != nil && rules.Max != nil && *rules.Min > *rules.Max {
+		return nil, fmt.Errorf("invalid validation rules: min %v is greater than max %v", *rules.Min, *rules.Max)
+	}
+	if rules.Pattern != "" {
+		if _, err := compileValidationPattern(rules.Pattern); err != nil {
+			return nil, fmt.Errorf("invalid validation pattern %q: %w", rules.Pattern, err)
+		}
+	}
+	return &rules, nil
+}
+
+// Check returns why value fails the rules, or nil if it is valid
+func (r *ValidationRules) Check(value interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	if r.Min != nil || r.Max != nil {
+		number, ok := validationNumber(value)
+		if !ok {
+			return fmt.Errorf("%v is not a number", value)
+		}
+		if r.Min != nil && number < *r.Min {
+			return fmt.Errorf("%v is below the minimum %v", number, *r.Min)
+		}
+		if r.Max != nil && number > *r.Max {
+			return fmt.Errorf("%v is above the maximum %v", number, *r.Max)
+		}
+	}
+
+	if len(r.Enum) > 0 && !enumContains(r.Enum, value) {
+		return fmt.Errorf("%v is not an allowed value", value)
+	}
+
+	if r.Pattern != "" {
+		text, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%v is not a string", value)
+		}
+		pattern, err := compileValidationPattern(r.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid validation pattern %q: %w", r.Pattern, err)
+		}
+		if !pattern.MatchString(text) {
+			return fmt.Errorf("%q does not match %s", text, r.Pattern)
+		}
+	}
+
+	return nil
+}
+
+// apply validates value, returning the value to emit and whether to emit at
+// all. last is the topic's current value, used by keep-last. The returned
+// action is the one taken for an invalid value.
+func (r *ValidationRules) apply(value, last interface{}) (interface{}, bool, ValidationAction, error) {
+	err := r.Check(value)
+	if err == nil {
+		return value, true, "", nil
+	}
+
+	switch r.Action {
+	case ValidationClamp:
+		if clamped, ok := r.clamp(value); ok && r.Check(clamped) == nil {
+			return clamped, true, ValidationClamp, err
+		}
+	case ValidationKeepLast:
+		if last != nil {
+			return last, true, ValidationKeepLast, err
+		}
+	}
+	return nil, false, ValidationDrop, err
+}
+
+// clamp bounds a number to min/max
+func (r *ValidationRules) clamp(value interface{}) (interface{}, bool) {
+	number, ok := validationNumber(value)
+	if !ok {
+		return nil, false
+	}
+	if r.Min != nil && number < *r.Min {
+		number = *r.Min
+	}
+	if r.Max != nil && number > *r.Max {
+		number = *r.Max
+	}
+	return number, true
+}
+
+// validationNumber returns a numeric value as a float64. Strings and bools
+// are not numbers here, so "21.5" fails a min/max rule.
+func validationNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case json.Number:
+		number, err := v.Float64()
+		return number, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// enumContains compares values by their JSON encoding, so numbers match
+// regardless of their Go type
+func enumContains(enum []interface{}, value interface{}) bool {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range enum {
+		candidate, err := json.Marshal(allowed)
+		if err == nil && bytes.Equal(candidate, encoded) {
+			return true
+		}
+	}
+	return false
+}
+
+var validationPatterns sync.Map // pattern -> *regexp.Regexp
+
+func compileValidationPattern(pattern string) (*regexp.Regexp, error) {
+	if cached, ok := validationPatterns.Load(pattern); ok {
+		return cached.(*regexp.Regexp), nil
+	}
+	compiled, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	validationPatterns.Store(pattern, compiled)
+	return compiled, nil
+}
+
+// configValidationRules returns the validation rules stored in a topic
+// config, or nil if none are set
+func configValidationRules(config map[string]interface{}) *ValidationRules {
+	rules, err := ParseValidationRules(config["validation"])
+	if err != nil {
+		return nil
+	}
+	return rules
+}
+
+// setConfigValidationRules stores (or clears, when nil) validation rules in
+// a topic config, in the form they are loaded from the database
+func setConfigValidationRules(config map[string]interface{}, rules *ValidationRules) error {
+	if rules == nil {
+		delete(config, "validation")
+		return nil
+	}
+	if _, err := ParseValidationRules(rules); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(rules)
+	if err != nil {
+		return fmt.Errorf("invalid validation rules: %w", err)
+	}
+	var stored map[string]interface{}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return fmt.Errorf("invalid validation rules: %w", err)
+	}
+	config["validation"] = stored
+	return nil
+}
+
+// validateTopicValue applies a topic's validation rules to a new value,
+// returning the value to emit and whether to emit it. Failures are logged
+// and counted.
+func validateTopicValue(m *Manager, topicName string, config map[string]interface{}, value, last interface{}) (interface{}, bool) {
+	rules := configValidationRules(config)
+	if rules == nil {
+		return value, true
+	}
+
+	result, ok, action, err := rules.apply(value, last)
+	if err == nil {
+		return result, ok
+	}
+
+	metrics.RecordTopicValidationFailure(topicName, string(action))
+	if m != nil {
+		m.logger.Printf("Topic %s value failed validation (%s): %v", topicName, action, err)
+	}
+	return result, ok
+}
+
+// GetValidationRules returns the topic's validation rules, or nil if none are set
+func (et *ExternalTopic) GetValidationRules() *ValidationRules {
+	return configValidationRules(et.config.Config)
+}
+
+// SetValidationRules sets (or clears, when nil) the rules values from MQTT
+// must pass.
+func (et *ExternalTopic) SetValidationRules(rules *ValidationRules) error {
+	if et.config.Config == nil {
+		et.config.Config = make(map[string]interface{})
+	}
+	return setConfigValidationRules(et.config.Config, rules)
+}
+
+// GetValidationRules returns the topic's validation rules, or nil if none are set
+func (it *InternalTopic) GetValidationRules() *ValidationRules {
+	return configValidationRules(it.config.Config)
+}
+
+// SetValidationRules sets (or clears, when nil) the rules emitted values
+// must pass.
+func (it *InternalTopic) SetValidationRules(rules *ValidationRules) error {
+	if it.config.Config == nil {
+		it.config.Config = make(map[string]interface{})
+	}
+	return setConfigValidationRules(it.config.Config, rules)
+}
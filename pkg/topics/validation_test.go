@@ -0,0 +1,191 @@
+package topics
+
+import (
+	"testing"
+)
+
+func float64Ptr(v float64) *float64 {
+	return &v
+}
+
+func TestValidationRulesCheck(t *testing.T) {
+	rules := &ValidationRules{Min: float64Ptr(-40), Max: float64Ptr(60)}
+	enum := &ValidationRules{Enum: []interface{}{"on", "off", 1.0}}
+	pattern := &ValidationRules{Pattern: `^[A-Z]{3}$`}
+
+	tests := []struct {
+		name    string
+		rules   *ValidationRules
+		value   interface{}
+		wantErr bool
+	}{
+		{name: "in range", rules: rules, value: 21.5},
+		{name: "at minimum", rules: rules, value: -40.0},
+		{name: "int in range", rules: rules, value: 20},
+		{name: "below minimum", rules: rules, value: -85.0, wantErr: true},
+		{name: "above maximum", rules: rules, value: 850.0, wantErr: true},
+		{name: "string is not a number", rules: rules, value: "21.5", wantErr: true},
+		{name: "nil is not validated", rules: rules, value: nil},
+		{name: "allowed enum", rules: enum, value: "on"},
+		{name: "allowed numeric enum", rules: enum, value: 1},
+		{name: "disallowed enum", rules: enum, value: "maybe", wantErr: true},
+		{name: "matching pattern", rules: pattern, value: "ABC"},
+		{name: "mismatched pattern", rules: pattern, value: "abc", wantErr: true},
+		{name: "pattern needs a string", rules: pattern, value: 123.0, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rules.Check(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Check(%v) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseValidationRules(t *testing.T) {
+	// Rules loaded from the database decode as a generic map
+	rules, err := ParseValidationRules(map[string]interface{}{"min": 0.0, "max": 100.0, "action": "clamp"})
+	if err != nil {
+		t.Fatalf("ParseValidationRules failed: %v", err)
+	}
+	if *rules.Min != 0 || *rules.Max != 100 || rules.Action != ValidationClamp {
+		t.Errorf("rules = %+v", rules)
+	}
+
+	invalid := []interface{}{
+		map[string]interface{}{"action": "ignore"},
+		map[string]interface{}{"min": 10.0, "max": 1.0},
+		map[string]interface{}{"pattern": "("},
+		"not rules",
+	}
+	for _, value := range invalid {
+		if _, err := ParseValidationRules(value); err == nil {
+			t.Errorf("ParseValidationRules(%v) accepted invalid rules", value)
+		}
+	}
+}
+
+func TestExternalTopicValidationClamp(t *testing.T) {
+	manager := NewManager(nil)
+	sensor := mustAddExternalTopic(t, manager, "sensors/temp")
+	if err := sensor.SetValidationRules(&ValidationRules{Min: float64Ptr(-40), Max: float64Ptr(60), Action: ValidationClamp}); err != nil {
+		t.Fatalf("SetValidationRules failed: %v", err)
+	}
+
+	if err := sensor.UpdateFromMQTT([]byte("850"), false); err != nil {
+		t.Fatalf("UpdateFromMQTT failed: %v", err)
+	}
+	if sensor.LastValue() != 60.0 {
+		t.Errorf("out of range value = %v, want clamped to 60", sensor.LastValue())
+	}
+
+	if err := sensor.Emit(-85.0); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	if sensor.LastValue() != -40.0 {
+		t.Errorf("out of range value = %v, want clamped to -40", sensor.LastValue())
+	}
+
+	// Values that cannot be clamped are dropped
+	if err := sensor.UpdateFromMQTT([]byte("error"), false); err != nil {
+		t.Fatalf("UpdateFromMQTT failed: %v", err)
+	}
+	if sensor.LastValue() != -40.0 {
+		t.Errorf("non-numeric value replaced the last value: %v", sensor.LastValue())
+	}
+}
+
+func TestInternalTopicValidationDrop(t *testing.T) {
+	manager := NewManager(nil)
+	publisher := &mockPublisher{}
+	manager.SetMQTTClient(publisher)
+
+	var result interface{}
+	var downstreamRuns int
+	manager.SetStrategyExecutor(&mockStrategyExecutor{
+		executeFunc: func(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+			if strategyID == "count" {
+				downstreamRuns++
+				return nil, nil
+			}
+			return result, nil
+		},
+	})
+
+	sensor := mustAddExternalTopic(t, manager, "sensors/mode")
+	topic, err := manager.AddInternalTopic("house/mode", []string{"sensors/mode"}, nil, "test", nil, true, false)
+	if err != nil {
+		t.Fatalf("Failed to add internal topic: %v", err)
+	}
+	if err := topic.SetValidationRules(&ValidationRules{Enum: []interface{}{"home", "away"}}); err != nil {
+		t.Fatalf("SetValidationRules failed: %v", err)
+	}
+
+	if _, err := manager.AddInternalTopic("house/downstream", []string{"house/mode"}, nil, "count", nil, false, false); err != nil {
+		t.Fatalf("Failed to add downstream topic: %v", err)
+	}
+
+	// A valid value is emitted and propagates
+	result = "home"
+	if err := sensor.Emit("home"); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	if topic.LastValue() != "home" || downstreamRuns != 1 {
+		t.Fatalf("valid value: last value %v, downstream runs %d", topic.LastValue(), downstreamRuns)
+	}
+	publisher.published = nil
+
+	// A disallowed value is dropped before it is stored or propagated
+	result = "holiday"
+	if err := sensor.Emit("holiday"); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	if topic.LastValue() != "home" {
+		t.Errorf("dropped value replaced the last value: %v", topic.LastValue())
+	}
+	if downstreamRuns != 1 {
+		t.Errorf("dropped value triggered %d downstream runs, want 1", downstreamRuns)
+	}
+	if len(publisher.published) != 0 {
+		t.Errorf("dropped value was published: %v", publisher.published)
+	}
+}
+
+func TestExternalTopicValidationKeepLast(t *testing.T) {
+	manager := NewManager(nil)
+	sensor := mustAddExternalTopic(t, manager, "sensors/humidity")
+	if err := sensor.SetValidationRules(&ValidationRules{Max: float64Ptr(100), Action: ValidationKeepLast}); err != nil {
+		t.Fatalf("SetValidationRules failed: %v", err)
+	}
+
+	// With no last value an invalid value is dropped
+	if err := sensor.Emit(140.0); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	if sensor.LastValue() != nil || !sensor.LastUpdated().IsZero() {
+		t.Errorf("invalid first value was stored: %v", sensor.LastValue())
+	}
+
+	if err := sensor.Emit(45.0); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	updated := sensor.LastUpdated()
+	if err := sensor.Emit(140.0); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	if sensor.LastValue() != 45.0 {
+		t.Errorf("keep-last value = %v, want 45", sensor.LastValue())
+	}
+	if sensor.LastUpdated().Before(updated) {
+		t.Error("keep-last did not refresh the topic")
+	}
+
+	if err := sensor.SetValidationRules(nil); err != nil {
+		t.Fatalf("clearing validation rules failed: %v", err)
+	}
+	if sensor.GetValidationRules() != nil {
+		t.Error("validation rules were not cleared")
+	}
+}
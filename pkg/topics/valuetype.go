@@ -0,0 +1,81 @@
+package topics
+
+import "fmt"
+
+// StoredValue is the state stored for a topic with a value type: the value
+// tagged with the type it was stored as, so it can be migrated when the
+// topic's value type changes
+type StoredValue struct {
+	ValueType InputType   `json:"__value_type"`
+	Value     interface{} `json:"__value"`
+}
+
+// UnwrapStoredValue returns the value of stored state and the value type it
+// is tagged with, which is empty for untagged state
+func UnwrapStoredValue(state interface{}) (interface{}, InputType) {
+	switch v := state.(type) {
+	case StoredValue:
+		return v.Value, v.ValueType
+	case *StoredValue:
+		return v.Value, v.ValueType
+	case map[string]interface{}:
+		if len(v) != 2 {
+			break
+		}
+		valueType, ok := v["__value_type"].(string)
+		value, hasValue := v["__value"]
+		if ok && hasValue {
+			return value, InputType(valueType)
+		}
+	}
+	return state, ""
+}
+
+// GetValueType returns the type the topic's values are stored and restored
+// as, if it has one
+func (it *InternalTopic) GetValueType() (InputType, bool) {
+	valueType, _ := it.config.Config["value_type"].(string)
+	parsed, err := ParseInputType(valueType)
+	if err != nil {
+		return "", false
+	}
+	return parsed, true
+}
+
+// SetValueType sets (or clears, when empty) the type the topic's values are
+// stored and restored as.
+func (it *InternalTopic) SetValueType(valueType string) error {
+	if valueType == "" {
+		delete(it.config.Config, "value_type")
+		return nil
+	}
+	if _, err := ParseInputType(valueType); err != nil {
+		return err
+	}
+
+	if it.config.Config == nil {
+		it.config.Config = make(map[string]interface{})
+	}
+	it.config.Config["value_type"] = valueType
+	return nil
+}
+
+// migrateStoredValue converts a restored value to the topic's value type when
+// it was stored as a different (or no) type. Values that cannot be converted
+// are returned as an error, so the caller discards them.
+func (it *InternalTopic) migrateStoredValue(value interface{}, storedType InputType) (interface{}, error) {
+	valueType, ok := it.GetValueType()
+	if !ok || storedType == valueType {
+		return value, nil
+	}
+
+	migrated, err := CoerceInput(value, valueType)
+	if err != nil {
+		from := string(storedType)
+		if from == "" {
+			from = "untyped"
+		}
+		return nil, fmt.Errorf("cannot migrate stored %s value to %s: %w", from, valueType, err)
+	}
+	return migrated, nil
+}
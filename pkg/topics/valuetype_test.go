@@ -0,0 +1,95 @@
+package topics
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestRestoreMigratesValueType(t *testing.T) {
+	tests := []struct {
+		name      string
+		valueType string
+		stored    string // state as decoded from the database
+		want      interface{}
+	}{
+		{name: "untagged string to number", valueType: "number", stored: `"21.5"`, want: 21.5},
+		{name: "tagged number to bool", valueType: "bool", stored: `{"__value_type":"number","__value":1}`, want: true},
+		{name: "tagged number to string", valueType: "string", stored: `{"__value_type":"number","__value":21.5}`, want: "21.5"},
+		{name: "same type is kept", valueType: "number", stored: `{"__value_type":"number","__value":3.5}`, want: 3.5},
+		{name: "no value type is kept", stored: `{"__value_type":"number","__value":3.5}`, want: 3.5},
+		{name: "unconvertible value is discarded", valueType: "number", stored: `{"__value_type":"string","__value":"warm"}`, want: nil},
+		{name: "malformed json is discarded", valueType: "json", stored: `"{not json"`, want: nil},
+		{name: "plain object is not a tag", valueType: "json", stored: `{"__value":1}`, want: map[string]interface{}{"__value": 1.0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stored interface{}
+			if err := json.Unmarshal([]byte(tt.stored), &stored); err != nil {
+				t.Fatalf("invalid stored state: %v", err)
+			}
+
+			manager := NewManager(nil)
+			manager.SetStateManager(&mockStateManager{
+				restoreStatesFunc: func() (map[string]interface{}, error) {
+					return map[string]interface{}{"internal:derived/temp": stored}, nil
+				},
+			})
+			topic, err := manager.AddInternalTopic("derived/temp", []string{"sensors/temp"}, nil, "test-strategy", nil, false, false)
+			if err != nil {
+				t.Fatalf("Failed to add internal topic: %v", err)
+			}
+			if err := topic.SetValueType(tt.valueType); err != nil {
+				t.Fatalf("SetValueType failed: %v", err)
+			}
+
+			if err := manager.RestoreTopicStatesFromDatabase(); err != nil {
+				t.Fatalf("RestoreTopicStatesFromDatabase failed: %v", err)
+			}
+			if got := topic.LastValue(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("restored value = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveTopicStateTagsValueType(t *testing.T) {
+	saved := make(map[string]interface{})
+	manager := NewManager(nil)
+	manager.SetStateManager(&mockStateManager{
+		saveFunc: func(topicName string, value interface{}) error {
+			saved[topicName] = value
+			return nil
+		},
+	})
+	topic, err := manager.AddInternalTopic("derived/temp", []string{"sensors/temp"}, nil, "test-strategy", nil, false, false)
+	if err != nil {
+		t.Fatalf("Failed to add internal topic: %v", err)
+	}
+	if _, err := manager.AddInternalTopic("derived/raw", []string{"sensors/temp"}, nil, "test-strategy", nil, false, false); err != nil {
+		t.Fatalf("Failed to add internal topic: %v", err)
+	}
+	if err := topic.SetValueType("number"); err != nil {
+		t.Fatalf("SetValueType failed: %v", err)
+	}
+
+	if err := manager.SaveTopicState("derived/temp", 21.5); err != nil {
+		t.Fatalf("SaveTopicState failed: %v", err)
+	}
+	if err := manager.SaveTopicState("derived/raw", 21.5); err != nil {
+		t.Fatalf("SaveTopicState failed: %v", err)
+	}
+
+	want := StoredValue{ValueType: InputTypeNumber, Value: 21.5}
+	if got := saved["internal:derived/temp"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("saved state = %#v, want %#v", got, want)
+	}
+	if got := saved["internal:derived/raw"]; got != 21.5 {
+		t.Errorf("saved state without a value type = %#v, want 21.5", got)
+	}
+
+	if err := topic.SetValueType("kelvin"); err == nil {
+		t.Error("expected an error for an invalid value type")
+	}
+}
@@ -0,0 +1,90 @@
+package topics
+
+import (
+	"sync"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/mqtt"
+)
+
+// MaxWarmupMessages bounds the MQTT messages buffered during startup warmup;
+// later messages are dropped until the buffer is flushed
+const MaxWarmupMessages = 10000
+
+// warmupBuffer holds inbound MQTT messages until the manager is ready. While
+// active, every message is queued, including those arriving during a flush,
+// so buffered messages are never overtaken.
+type warmupBuffer struct {
+	mutex    sync.Mutex
+	active   bool
+	flushing bool
+	events   []mqtt.Event
+	dropped  int
+}
+
+// BeginWarmup buffers inbound MQTT messages until EndWarmup is called, so
+// retained messages arriving while topics and strategies are still loading
+// are processed once initialization has completed
+func (m *Manager) BeginWarmup() {
+	m.warmup.mutex.Lock()
+	defer m.warmup.mutex.Unlock()
+
+	m.warmup.active = true
+}
+
+// EndWarmup processes the buffered MQTT messages in the order they arrived
+// and stops buffering. It returns the number of messages processed.
+func (m *Manager) EndWarmup() int {
+	m.warmup.mutex.Lock()
+	if !m.warmup.active || m.warmup.flushing {
+		m.warmup.mutex.Unlock()
+		return 0
+	}
+	m.warmup.flushing = true
+	if m.warmup.dropped > 0 {
+		m.logger.Printf("Warning: dropped %d MQTT messages received during startup warmup (buffer limit %d)",
+			m.warmup.dropped, MaxWarmupMessages)
+	}
+	m.warmup.mutex.Unlock()
+
+	processed := 0
+	for {
+		m.warmup.mutex.Lock()
+		events := m.warmup.events
+		m.warmup.events = nil
+		if len(events) == 0 {
+			m.warmup.active = false
+			m.warmup.flushing = false
+			m.warmup.dropped = 0
+			m.warmup.mutex.Unlock()
+			break
+		}
+		m.warmup.mutex.Unlock()
+
+		for _, event := range events {
+			if err := m.processMQTTMessage(event); err != nil {
+				m.logger.Printf("Error handling buffered MQTT message for topic %s: %v", event.Topic, err)
+			}
+		}
+		processed += len(events)
+	}
+
+	m.logger.Printf("Startup warmup complete; processed %d buffered MQTT messages", processed)
+	return processed
+}
+
+// bufferWarmupMessage queues event while warming up, reporting whether it
+// was taken
+func (m *Manager) bufferWarmupMessage(event mqtt.Event) bool {
+	m.warmup.mutex.Lock()
+	defer m.warmup.mutex.Unlock()
+
+	if !m.warmup.active {
+		return false
+	}
+	if len(m.warmup.events) >= MaxWarmupMessages {
+		m.warmup.dropped++
+		return true
+	}
+	m.warmup.events = append(m.warmup.events, event)
+	return true
+}
@@ -0,0 +1,62 @@
+package topics
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/mqtt"
+)
+
+func TestManagerWarmupBuffersMessages(t *testing.T) {
+	manager := NewManager(nil)
+
+	var triggers []string
+	manager.SetStrategyExecutor(&mockStrategyExecutor{
+		executeFunc: func(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+			triggers = append(triggers, fmt.Sprintf("%s=%v", triggerTopic, inputs[triggerTopic]))
+			return "ok", nil
+		},
+	})
+	if _, err := manager.AddInternalTopic("house/sensors", []string{"sensors/+"}, nil, "test", nil, false, false); err != nil {
+		t.Fatalf("Failed to add internal topic: %v", err)
+	}
+
+	manager.BeginWarmup()
+	messages := []mqtt.Event{
+		{Topic: "sensors/temp", Payload: []byte("20")},
+		{Topic: "sensors/humidity", Payload: []byte("40")},
+		{Topic: "sensors/temp", Payload: []byte("21")},
+	}
+	for _, event := range messages {
+		if err := manager.HandleMQTTMessage(event); err != nil {
+			t.Fatalf("HandleMQTTMessage failed: %v", err)
+		}
+	}
+
+	if len(triggers) != 0 {
+		t.Fatalf("executed %v during warmup, want nothing", triggers)
+	}
+	if topic := manager.GetTopic("sensors/temp"); topic != nil {
+		t.Errorf("external topic created during warmup: %v", topic)
+	}
+
+	if processed := manager.EndWarmup(); processed != len(messages) {
+		t.Errorf("EndWarmup() processed %d messages, want %d", processed, len(messages))
+	}
+	want := []string{"sensors/temp=20", "sensors/humidity=40", "sensors/temp=21"}
+	if !reflect.DeepEqual(triggers, want) {
+		t.Errorf("executions = %v, want %v", triggers, want)
+	}
+
+	// Messages after warmup are processed immediately
+	if err := manager.HandleMQTTMessage(mqtt.Event{Topic: "sensors/temp", Payload: []byte("22")}); err != nil {
+		t.Fatalf("HandleMQTTMessage failed: %v", err)
+	}
+	if len(triggers) != 4 || triggers[3] != "sensors/temp=22" {
+		t.Errorf("executions after warmup = %v", triggers)
+	}
+	if processed := manager.EndWarmup(); processed != 0 {
+		t.Errorf("second EndWarmup() processed %d messages, want 0", processed)
+	}
+}
@@ -0,0 +1,120 @@
+package topics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Defaults of webhook sinks
+const (
+	DefaultWebhookRetries = 3
+	DefaultWebhookBackoff = time.Second
+	DefaultWebhookTimeout = 10 * time.Second
+)
+
+// WebhookOptions configure a WebhookSink. Zero fields use the defaults.
+type WebhookOptions struct {
+	Headers map[string]string
+	Retries int           // attempts after the first; negative disables retries
+	Backoff time.Duration // wait before the first retry, doubling after each
+	Timeout time.Duration // timeout of each request
+}
+
+// webhookEvent is the JSON body POSTed for a topic update
+type webhookEvent struct {
+	Topic         string      `json:"topic"`
+	Value         interface{} `json:"value"`
+	PreviousValue interface{} `json:"previous_value"`
+	Timestamp     time.Time   `json:"timestamp"`
+	TriggerTopic  string      `json:"trigger_topic,omitempty"`
+}
+
+// WebhookSink POSTs each topic update as JSON to a URL. Connection errors,
+// 429 and 5xx responses are retried; other failures are not.
+type WebhookSink struct {
+	url     string
+	options WebhookOptions
+	client  *http.Client
+	sleep   func(time.Duration)
+}
+
+// NewWebhookSink creates a sink that POSTs events to url
+func NewWebhookSink(url string, options WebhookOptions) (*WebhookSink, error) {
+	if url == "" {
+		return nil, fmt.Errorf("webhook URL is required")
+	}
+	if options.Retries == 0 {
+		options.Retries = DefaultWebhookRetries
+	}
+	if options.Retries < 0 {
+		options.Retries = 0
+	}
+	if options.Backoff <= 0 {
+		options.Backoff = DefaultWebhookBackoff
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultWebhookTimeout
+	}
+
+	return &WebhookSink{
+		url:     url,
+		options: options,
+		client:  &http.Client{Timeout: options.Timeout},
+		sleep:   time.Sleep,
+	}, nil
+}
+
+func (ws *WebhookSink) HandleEvent(event TopicEvent) error {
+	body, err := json.Marshal(webhookEvent{
+		Topic:         event.TopicName,
+		Value:         event.Value,
+		PreviousValue: event.PreviousValue,
+		Timestamp:     event.Timestamp,
+		TriggerTopic:  event.TriggerTopic,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	backoff := ws.options.Backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := ws.post(body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= ws.options.Retries {
+			return fmt.Errorf("webhook delivery failed after %d attempts: %w", attempt+1, err)
+		}
+		ws.sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post sends one request, reporting whether a failure is worth retrying
+func (ws *WebhookSink) post(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, ws.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range ws.options.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := ws.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("webhook returned %s", resp.Status)
+}
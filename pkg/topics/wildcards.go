@@ -0,0 +1,80 @@
+package topics
+
+import (
+	"strings"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/mqtt"
+)
+
+// isWildcard reports whether topic is a pattern rather than a topic name
+func isWildcard(topic string) bool {
+	return strings.ContainsAny(topic, "+#")
+}
+
+// OverlappingWildcardInputs returns the pairs of wildcard inputs that can
+// match the same topic, in input order
+func OverlappingWildcardInputs(inputs []string) [][2]string {
+	var overlaps [][2]string
+	for i, first := range inputs {
+		if !isWildcard(first) {
+			continue
+		}
+		for _, second := range inputs[i+1:] {
+			if isWildcard(second) && mqtt.PatternsOverlap(first, second) {
+				overlaps = append(overlaps, [2]string{first, second})
+			}
+		}
+	}
+	return overlaps
+}
+
+// warnOverlappingWildcards logs each pair of wildcard inputs that can match
+// the same trigger topic
+func (it *InternalTopic) warnOverlappingWildcards() {
+	for _, pair := range OverlappingWildcardInputs(it.config.Inputs) {
+		it.manager.logger.Printf("Warning: topic %s wildcard inputs %s and %s overlap; a trigger matching both is bound to %s only",
+			it.config.Name, pair[0], pair[1], pair[0])
+	}
+}
+
+// triggeredWildcardInput returns the wildcard input that receives the
+// trigger topic's value: the first input, in input order, whose pattern
+// matches it. Later wildcard inputs that also match are resolved like any
+// wildcard input that was not triggered (a nil value).
+func (it *InternalTopic) triggeredWildcardInput(triggerTopic string) string {
+	if triggerTopic == ScheduledTrigger {
+		return ""
+	}
+
+	var matched []string
+	for _, inputTopic := range it.config.Inputs {
+		if inputTopic != triggerTopic && isWildcard(inputTopic) && mqtt.TopicMatches(inputTopic, triggerTopic) {
+			matched = append(matched, inputTopic)
+		}
+	}
+	if len(matched) == 0 {
+		return ""
+	}
+	if len(matched) > 1 {
+		it.warnAmbiguousTrigger(triggerTopic, matched)
+	}
+	return matched[0]
+}
+
+// warnAmbiguousTrigger logs, once per trigger topic, that the trigger matched
+// several wildcard inputs
+func (it *InternalTopic) warnAmbiguousTrigger(triggerTopic string, matched []string) {
+	it.ambiguousMutex.Lock()
+	defer it.ambiguousMutex.Unlock()
+
+	if it.ambiguousTriggers[triggerTopic] {
+		return
+	}
+	if it.ambiguousTriggers == nil {
+		it.ambiguousTriggers = make(map[string]bool)
+	}
+	it.ambiguousTriggers[triggerTopic] = true
+
+	it.manager.logger.Printf("Warning: topic %s trigger %s matches wildcard inputs %s; using %s",
+		it.config.Name, triggerTopic, strings.Join(matched, ", "), matched[0])
+}
@@ -0,0 +1,65 @@
+package topics
+
+import (
+	"bytes"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOverlappingWildcardInputs(t *testing.T) {
+	inputs := []string{"sensors/+/temp", "sensors/kitchen/temp", "sensors/kitchen/#", "devices/+"}
+	want := [][2]string{{"sensors/+/temp", "sensors/kitchen/#"}}
+	if got := OverlappingWildcardInputs(inputs); !reflect.DeepEqual(got, want) {
+		t.Errorf("OverlappingWildcardInputs() = %v, want %v", got, want)
+	}
+}
+
+func TestInternalTopicOverlappingWildcardInputs(t *testing.T) {
+	var logs bytes.Buffer
+	manager := NewManager(log.New(&logs, "", 0))
+
+	var executedInputs map[string]interface{}
+	manager.SetStrategyExecutor(&mockStrategyExecutor{
+		executeFunc: func(strategyID string, inputs map[string]interface{}, inputNames map[string]string, triggerTopic string, lastOutput interface{}, topicParameters map[string]interface{}) (interface{}, error) {
+			executedInputs = inputs
+			return "ok", nil
+		},
+	})
+
+	inputs := []string{"sensors/+/temp", "sensors/kitchen/#"}
+	names := map[string]string{"sensors/+/temp": "anyTemp", "sensors/kitchen/#": "kitchen"}
+	if _, err := manager.AddInternalTopic("house/temp", inputs, names, "test", nil, false, false); err != nil {
+		t.Fatalf("Failed to add internal topic: %v", err)
+	}
+	if !strings.Contains(logs.String(), "wildcard inputs sensors/+/temp and sensors/kitchen/# overlap") {
+		t.Errorf("no overlap warning when adding the topic; logs:\n%s", logs.String())
+	}
+
+	kitchen := mustAddExternalTopic(t, manager, "sensors/kitchen/temp")
+	for i := 0; i < 2; i++ {
+		if err := kitchen.Emit(21.5); err != nil {
+			t.Fatalf("Emit failed: %v", err)
+		}
+
+		// The first matching input in input order receives the trigger value
+		want := map[string]interface{}{"anyTemp": 21.5, "kitchen": nil}
+		if !reflect.DeepEqual(executedInputs, want) {
+			t.Errorf("strategy inputs = %#v, want %#v", executedInputs, want)
+		}
+	}
+
+	if got := strings.Count(logs.String(), "trigger sensors/kitchen/temp matches wildcard inputs sensors/+/temp, sensors/kitchen/#; using sensors/+/temp"); got != 1 {
+		t.Errorf("ambiguous trigger warned %d times, want once; logs:\n%s", got, logs.String())
+	}
+
+	// A trigger matching only one wildcard input is not ambiguous
+	hallway := mustAddExternalTopic(t, manager, "sensors/hallway/temp")
+	if err := hallway.Emit(19.0); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	if executedInputs["anyTemp"] != 19.0 || strings.Contains(logs.String(), "trigger sensors/hallway/temp") {
+		t.Errorf("strategy inputs = %#v; logs:\n%s", executedInputs, logs.String())
+	}
+}
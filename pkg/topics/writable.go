@@ -0,0 +1,51 @@
+package topics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/mqtt"
+)
+
+// ErrTopicNotWritable is returned when a strategy emits to an existing topic
+// it may not write to
+var ErrTopicNotWritable = errors.New("topic is not writable")
+
+// SetWritableTargets sets the MQTT topic patterns of existing topics that
+// strategies may emit to. Without a match only the derived topics strategies
+// create themselves can be emitted to; system topics are never writable.
+func (m *Manager) SetWritableTargets(patterns []string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.writableTargets = append([]string(nil), patterns...)
+}
+
+// isWritableTarget reports whether strategies may emit to an existing topic.
+// The caller must hold the manager's lock.
+func (m *Manager) isWritableTarget(topicName string) bool {
+	for _, pattern := range m.writableTargets {
+		if pattern == topicName || mqtt.TopicMatches(pattern, topicName) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeValue stores a value a strategy emitted to a writable external topic
+// and notifies its dependents as part of the chain ctx. The value is not
+// published to MQTT.
+func (et *ExternalTopic) writeValue(ctx context.Context, value interface{}) error {
+	if err := et.emit(ctx, value, false, time.Now()); err != nil {
+		return fmt.Errorf("failed to write external topic %s: %w", et.config.Name, err)
+	}
+	return nil
+}
+
+// notWritableError reports an emit to an existing topic that is not a
+// writable target
+func notWritableError(topic Topic) error {
+	return fmt.Errorf("cannot emit to %s topic %s: %w", topic.Type(), topic.Name(), ErrTopicNotWritable)
+}
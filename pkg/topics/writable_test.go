@@ -0,0 +1,59 @@
+package topics
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/strategy"
+)
+
+func TestEmitToExistingTopic(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   string
+		writable []string
+		wantErr  bool
+	}{
+		{name: "writable external topic", target: "house/mode", writable: []string{"house/+"}},
+		{name: "read-only external topic", target: "house/mode", writable: []string{"house/alarm"}, wantErr: true},
+		{name: "writable internal topic", target: "house/occupied", writable: []string{"house/occupied"}},
+		{name: "read-only internal topic", target: "house/occupied", wantErr: true},
+		{name: "system topics are never writable", target: "system/heartbeat", writable: []string{"#"}, wantErr: true},
+		{name: "derived topics are always writable", target: "automation/status"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewManager(nil)
+			manager.SetWritableTargets(tt.writable)
+			mustAddExternalTopic(t, manager, "house/mode")
+			mustAddExternalTopic(t, manager, "sensors/motion")
+			mustAddInternalTopic(t, manager, "house/occupied", []string{"sensors/motion"})
+			manager.AddSystemTopic("system/heartbeat", map[string]interface{}{"interval": "1h"})
+			emitter := mustAddInternalTopic(t, manager, "automation", []string{"sensors/motion"})
+			if err := manager.createOrUpdateDerivedTopic(context.Background(), "automation/status", "idle", false); err != nil {
+				t.Fatalf("createOrUpdateDerivedTopic failed: %v", err)
+			}
+			before := manager.GetTopic(tt.target).LastValue()
+
+			err := emitter.processEmittedEvents(context.Background(), []strategy.EmitEvent{{Topic: tt.target, Value: "away"}}, "sensors/motion")
+			value := manager.GetTopic(tt.target).LastValue()
+			if tt.wantErr {
+				if !errors.Is(err, ErrTopicNotWritable) {
+					t.Fatalf("emit error = %v, want ErrTopicNotWritable", err)
+				}
+				if value != before {
+					t.Errorf("value = %v after a rejected emit, want %v", value, before)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("emit failed: %v", err)
+			}
+			if value != "away" {
+				t.Errorf("value = %v, want away", value)
+			}
+		})
+	}
+}
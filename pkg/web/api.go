@@ -1,13 +1,16 @@
 package web
 
 import (
+	"encoding/csv"
 	"encoding/json"
+	"fmt"
 	"net/http"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/denwilliams/go-mqtt-automation/pkg/state"
+	"github.com/denwilliams/go-mqtt-automation/pkg/strategy"
 	"github.com/denwilliams/go-mqtt-automation/pkg/topics"
 )
 
@@ -76,6 +79,7 @@ type TopicListResponse struct {
 
 type TopicSummary struct {
 	Name        string                 `json:"name"`
+	DisplayName string                 `json:"display_name,omitempty"`
 	Type        string                 `json:"type"`
 	LastValue   interface{}            `json:"last_value"`
 	LastUpdated time.Time              `json:"last_updated"`
@@ -84,35 +88,120 @@ type TopicSummary struct {
 	StrategyID  string                 `json:"strategy_id,omitempty"`
 	Parameters  map[string]interface{} `json:"parameters,omitempty"`
 	EmitToMQTT  bool                   `json:"emit_to_mqtt,omitempty"`
+	Status      topics.TopicStatus     `json:"status,omitempty"`
 	Tags        []string               `json:"tags,omitempty"`
+
+	// hasStatus is set for topics whose status is reported
+	hasStatus bool
 }
 
 type TopicDetail struct {
-	Name          string                 `json:"name"`
-	Type          string                 `json:"type"`
-	LastValue     interface{}            `json:"last_value"`
-	LastUpdated   time.Time              `json:"last_updated"`
-	CreatedAt     time.Time              `json:"created_at"`
-	Inputs        []string               `json:"inputs,omitempty"`
-	InputNames    map[string]string      `json:"input_names,omitempty"`
-	StrategyID    string                 `json:"strategy_id,omitempty"`
-	Parameters    map[string]interface{} `json:"parameters,omitempty"`
-	EmitToMQTT    bool                   `json:"emit_to_mqtt,omitempty"`
-	NoOpUnchanged bool                   `json:"noop_unchanged,omitempty"`
-	Config        map[string]interface{} `json:"config,omitempty"`
-	Tags          []string               `json:"tags,omitempty"`
+	Name                string                         `json:"name"`
+	DisplayName         string                         `json:"display_name,omitempty"`
+	Type                string                         `json:"type"`
+	LastValue           interface{}                    `json:"last_value"`
+	LastUpdated         time.Time                      `json:"last_updated"`
+	CreatedAt           time.Time                      `json:"created_at"`
+	Inputs              []string                       `json:"inputs,omitempty"`
+	InputNames          map[string]string              `json:"input_names,omitempty"`
+	StrategyID          string                         `json:"strategy_id,omitempty"`
+	Parameters          map[string]interface{}         `json:"parameters,omitempty"`
+	EffectiveParameters map[string]interface{}         `json:"effective_parameters,omitempty"`
+	EmitToMQTT          bool                           `json:"emit_to_mqtt,omitempty"`
+	NoOpUnchanged       bool                           `json:"noop_unchanged,omitempty"`
+	Schedule            string                         `json:"schedule,omitempty"`
+	NullPolicy          string                         `json:"null_policy,omitempty"`
+	MQTTTopic           string                         `json:"mqtt_topic,omitempty"`
+	PublishEncoding     string                         `json:"publish_encoding,omitempty"`
+	QoS                 *int                           `json:"qos,omitempty"`
+	Group               bool                           `json:"group,omitempty"`
+	IgnoreRetained      bool                           `json:"ignore_retained_trigger,omitempty"`
+	PassiveInputs       []string                       `json:"passive_inputs,omitempty"`
+	TTL                 string                         `json:"ttl,omitempty"`
+	RepublishInterval   string                         `json:"republish_interval,omitempty"`
+	CoalesceWindow      string                         `json:"coalesce_window,omitempty"`
+	MissingInputPolicy  string                         `json:"missing_input_policy,omitempty"`
+	ChildMQTTOverrides  map[string]bool                `json:"child_mqtt_overrides,omitempty"`
+	SnapshotSize        int                            `json:"snapshot_size,omitempty"`
+	InputTypes          map[string]topics.InputType    `json:"input_types,omitempty"`
+	ValueType           string                         `json:"value_type,omitempty"`
+	InputDecoders       map[string]topics.InputDecoder `json:"input_decoders,omitempty"`
+	Validation          *topics.ValidationRules        `json:"validation,omitempty"`
+	OutputSchema        *topics.OutputSchema           `json:"output_schema,omitempty"`
+	PostProcessors      []string                       `json:"post_processors,omitempty"`
+	Transform           *topics.Transform              `json:"transform,omitempty"`
+	Logic               string                         `json:"logic,omitempty"`
+	RecentValues        []topics.SnapshotValue         `json:"recent_values,omitempty"`
+	Binary              bool                           `json:"binary,omitempty"` // last_value is base64-encoded bytes
+	IngressStrategy     string                         `json:"ingress_strategy,omitempty"`
+	Status              topics.TopicStatus             `json:"status,omitempty"`
+	Config              map[string]interface{}         `json:"config,omitempty"`
+	Tags                []string                       `json:"tags,omitempty"`
 }
 
 type TopicCreateRequest struct {
-	Name          string                 `json:"name"`
-	Type          string                 `json:"type"`
-	Inputs        []string               `json:"inputs,omitempty"`
-	InputNames    map[string]string      `json:"input_names,omitempty"`
-	StrategyID    string                 `json:"strategy_id,omitempty"`
-	Parameters    map[string]interface{} `json:"parameters,omitempty"`
-	EmitToMQTT    bool                   `json:"emit_to_mqtt,omitempty"`
-	NoOpUnchanged bool                   `json:"noop_unchanged,omitempty"`
-	Tags          []string               `json:"tags,omitempty"`
+	Name               string                         `json:"name"`
+	DisplayName        string                         `json:"display_name,omitempty"` // shown in place of the name; the name stays the identity
+	Type               string                         `json:"type"`
+	Inputs             []string                       `json:"inputs,omitempty"`
+	InputNames         map[string]string              `json:"input_names,omitempty"`
+	StrategyID         string                         `json:"strategy_id,omitempty"`
+	Parameters         map[string]interface{}         `json:"parameters,omitempty"`
+	EmitToMQTT         *bool                          `json:"emit_to_mqtt,omitempty"` // nil uses web.default_emit_to_mqtt
+	NoOpUnchanged      bool                           `json:"noop_unchanged,omitempty"`
+	Schedule           string                         `json:"schedule,omitempty"`
+	NullPolicy         string                         `json:"null_policy,omitempty"`
+	MQTTTopic          string                         `json:"mqtt_topic,omitempty"`
+	PublishEncoding    string                         `json:"publish_encoding,omitempty"`        // json (default), csv, kv or plain
+	QoS                *int                           `json:"qos,omitempty"`                     // publish QoS; nil uses the MQTT client's
+	Group              bool                           `json:"group,omitempty"`                   // wait for a fresh value from every input
+	IgnoreRetained     bool                           `json:"ignore_retained_trigger,omitempty"` // retained MQTT replays do not trigger the topic
+	PassiveInputs      []string                       `json:"passive_inputs,omitempty"`          // inputs whose updates do not trigger the topic
+	TTL                string                         `json:"ttl,omitempty"`                     // report the topic stale after this long without an update
+	RepublishInterval  string                         `json:"republish_interval,omitempty"`      // republish the current value to MQTT this often
+	CoalesceWindow     string                         `json:"coalesce_window,omitempty"`         // execute once for input changes within this window
+	MissingInputPolicy string                         `json:"missing_input_policy,omitempty"`    // empty uses topics.missing_input_policy
+	ChildMQTTOverrides map[string]bool                `json:"child_mqtt_overrides,omitempty"`    // emitted path -> publish to MQTT
+	SnapshotSize       int                            `json:"snapshot_size,omitempty"`           // persist this many recent values for crash recovery
+	InputTypes         map[string]topics.InputType    `json:"input_types,omitempty"`             // input topic -> number, bool, json or string
+	ValueType          string                         `json:"value_type,omitempty"`              // number, bool, json or string; stored values are migrated when it changes
+	InputDecoders      map[string]topics.InputDecoder `json:"input_decoders,omitempty"`          // input topic -> none, base64, hex, url or json-parse
+	Validation         *topics.ValidationRules        `json:"validation,omitempty"`              // reject emitted values outside min/max, enum or pattern
+	OutputSchema       *topics.OutputSchema           `json:"output_schema,omitempty"`           // reject strategy outputs that do not match this schema
+	PostProcessors     []string                       `json:"post_processors,omitempty"`         // replaces strategies.post_processors; ["none"] disables it
+	Transform          *topics.Transform              `json:"transform,omitempty"`               // compute the value with a built-in transform instead of a strategy
+	Logic              string                         `json:"logic,omitempty"`                   // compute the value from a boolean expression over the inputs instead of a strategy
+	Tags               []string                       `json:"tags,omitempty"`
+}
+
+type TopicHistoryResponse struct {
+	Topic   string              `json:"topic"`
+	From    time.Time           `json:"from"`
+	To      time.Time           `json:"to"`
+	History []TopicHistoryPoint `json:"history"`
+}
+
+type TopicHistoryPoint struct {
+	Value     interface{} `json:"value"`
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+type TopicLogsResponse struct {
+	Topic string              `json:"topic"`
+	Logs  []ExecutionLogEntry `json:"logs"`
+}
+
+type ExecutionLogEntry struct {
+	ID              int                    `json:"id"`
+	StrategyID      string                 `json:"strategy_id"`
+	TriggerTopic    string                 `json:"trigger_topic"`
+	InputValues     map[string]interface{} `json:"input_values"`
+	OutputValues    interface{}            `json:"output_values"`
+	Error           string                 `json:"error,omitempty"`
+	ExecutionTimeMs int64                  `json:"execution_time_ms"`
+	LogMessages     []strategy.LogMessage  `json:"log_messages"`
+	ExecutedAt      time.Time              `json:"executed_at"`
+	OutputDiff      *state.OutputDiff      `json:"output_diff,omitempty"`
 }
 
 // Strategy structures
@@ -122,40 +211,54 @@ type StrategyListResponse struct {
 }
 
 type StrategySummary struct {
-	ID                string    `json:"id"`
-	Name              string    `json:"name"`
-	Description       string    `json:"description"`
-	Language          string    `json:"language"`
-	Builtin           bool      `json:"builtin"`
-	CreatedAt         time.Time `json:"created_at"`
-	UpdatedAt         time.Time `json:"updated_at"`
-	MaxInputs         int       `json:"max_inputs"`
-	DefaultInputNames []string  `json:"default_input_names"`
+	ID                string                            `json:"id"`
+	Name              string                            `json:"name"`
+	Description       string                            `json:"description"`
+	Language          string                            `json:"language"`
+	Builtin           bool                              `json:"builtin"`
+	CreatedAt         time.Time                         `json:"created_at"`
+	UpdatedAt         time.Time                         `json:"updated_at"`
+	MaxInputs         int                               `json:"max_inputs"`
+	DefaultInputNames []string                          `json:"default_input_names"`
+	Library           bool                              `json:"library"`
+	Circuits          map[string]strategy.CircuitStatus `json:"circuits"` // keyed by topic
+	UsageCount        int                               `json:"usage_count"`
+	LastExecutedAt    *time.Time                        `json:"last_executed_at"`
 }
 
 type StrategyDetail struct {
-	ID                string                 `json:"id"`
-	Name              string                 `json:"name"`
-	Description       string                 `json:"description"`
-	Code              string                 `json:"code"`
-	Language          string                 `json:"language"`
-	Builtin           bool                   `json:"builtin"`
-	Parameters        map[string]interface{} `json:"parameters"`
-	MaxInputs         int                    `json:"max_inputs"`
-	DefaultInputNames []string               `json:"default_input_names"`
-	CreatedAt         time.Time              `json:"created_at"`
-	UpdatedAt         time.Time              `json:"updated_at"`
+	ID                   string                            `json:"id"`
+	Name                 string                            `json:"name"`
+	Description          string                            `json:"description"`
+	Code                 string                            `json:"code"`
+	Language             string                            `json:"language"`
+	Builtin              bool                              `json:"builtin"`
+	Parameters           map[string]interface{}            `json:"parameters"`
+	MaxInputs            int                               `json:"max_inputs"`
+	DefaultInputNames    []string                          `json:"default_input_names"`
+	AllowedInputPatterns []string                          `json:"allowed_input_patterns,omitempty"`
+	Library              bool                              `json:"library"`
+	LogLevel             strategy.LogLevel                 `json:"log_level"`
+	TimeoutMs            int                               `json:"timeout_ms,omitempty"`
+	FilePath             string                            `json:"file_path,omitempty"` // set when the code is loaded from disk
+	Circuits             map[string]strategy.CircuitStatus `json:"circuits"`            // keyed by topic
+	CreatedAt            time.Time                         `json:"created_at"`
+	UpdatedAt            time.Time                         `json:"updated_at"`
 }
 
 type StrategyCreateRequest struct {
-	ID                string                 `json:"id"`
-	Name              string                 `json:"name"`
-	Description       string                 `json:"description"`
-	Code              string                 `json:"code"`
-	Language          string                 `json:"language"`
-	Parameters        map[string]interface{} `json:"parameters,omitempty"`
-	MaxInputs         int                    `json:"max_inputs,omitempty"`
-	DefaultInputNames []string               `json:"default_input_names,omitempty"`
+	ID                   string                 `json:"id"`
+	Name                 string                 `json:"name"`
+	Description          string                 `json:"description"`
+	Code                 string                 `json:"code"`
+	Language             string                 `json:"language"`
+	Parameters           map[string]interface{} `json:"parameters,omitempty"`
+	MaxInputs            int                    `json:"max_inputs,omitempty"`
+	DefaultInputNames    []string               `json:"default_input_names,omitempty"`
+	AllowedInputPatterns []string               `json:"allowed_input_patterns,omitempty"`
+	Library              bool                   `json:"library,omitempty"`
+	LogLevel             string                 `json:"log_level,omitempty"`  // debug, info (default), warn or error
+	TimeoutMs            int                    `json:"timeout_ms,omitempty"` // 0 uses the default execution timeout
 }
 
 // System structures
@@ -165,7 +268,13 @@ type SystemInfoResponse struct {
 	BuildDate     string `json:"build_date"`
 	GoVersion     string `json:"go_version"`
 	DatabaseType  string `json:"database_type"`
-	MQTTConnected bool   `json:"mqtt_connected"`
+	SchemaVersion uint   `json:"schema_version"`
+	SchemaDirty   bool   `json:"schema_dirty"`
+	// ExpectedSchemaVersion is the schema version this build migrates to;
+	// SchemaDrift is current, behind or ahead of it
+	ExpectedSchemaVersion uint   `json:"expected_schema_version"`
+	SchemaDrift           string `json:"schema_drift"`
+	MQTTConnected         bool   `json:"mqtt_connected"`
 }
 
 // Helper functions
@@ -192,7 +301,7 @@ func writeAPIError(w http.ResponseWriter, status int, code, message string, deta
 func writeJSONResponse(w http.ResponseWriter, status int, data interface{}) {
 	// Add CORS headers
 	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
@@ -232,7 +341,7 @@ func (s *Server) handleAPIDashboard(w http.ResponseWriter, r *http.Request) {
 	// Handle CORS preflight requests
 	if r.Method == "OPTIONS" {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 		w.WriteHeader(http.StatusOK)
 		return
@@ -247,8 +356,9 @@ func (s *Server) handleAPIDashboard(w http.ResponseWriter, r *http.Request) {
 	topicCounts := s.topicManager.GetTopicCount()
 	total := topicCounts[topics.TopicTypeExternal] + topicCounts[topics.TopicTypeInternal] + topicCounts[topics.TopicTypeSystem]
 
-	// Get strategy count
+	// Get strategy counts; strategies whose latest execution failed count as failed
 	strategyCount := s.strategyEngine.GetStrategyCount()
+	failedStrategies := s.strategyEngine.GetFailedStrategyCount()
 
 	// Get MQTT status
 	mqttConnected := false
@@ -271,8 +381,8 @@ func (s *Server) handleAPIDashboard(w http.ResponseWriter, r *http.Request) {
 			},
 			Strategies: StrategyStats{
 				Total:  strategyCount,
-				Active: strategyCount, // TODO: Track active vs failed
-				Failed: 0,
+				Active: strategyCount - failedStrategies,
+				Failed: failedStrategies,
 			},
 			MQTT: MQTTStats{
 				Connected:         mqttConnected,
@@ -302,189 +412,52 @@ func (s *Server) handleAPITopicsList(w http.ResponseWriter, r *http.Request) {
 	topicType := r.URL.Query().Get("type")
 	nameFilter := r.URL.Query().Get("name")
 	tagFilter := r.URL.Query().Get("tag")
+	statusFilter := topics.TopicStatus(r.URL.Query().Get("status"))
+	switch statusFilter {
+	case "", topics.TopicStatusOK, topics.TopicStatusStale, topics.TopicStatusError, topics.TopicStatusCircuitOpen:
+	default:
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", "status must be ok, stale, error or circuit-open", nil)
+		return
+	}
 
-	// Get all topics from database (already ordered by name)
-	allTopicConfigs, err := s.stateManager.LoadAllTopicConfigs()
+	// Database and in-memory topics merged and sorted by name (cached)
+	allTopics, err := s.topicSummaries()
 	if err != nil {
 		s.logger.Printf("Failed to load topics from database: %v", err)
 		writeAPIError(w, http.StatusInternalServerError, "DATABASE_ERROR", "Failed to load topics", nil)
 		return
 	}
 
-	// Get child topics and external topics from in-memory topic manager
-	childTopics := s.topicManager.GetChildTopics()
-	externalTopics := s.topicManager.GetExternalTopics()
-
-	// Convert to slice for filtering and pagination
-	// Allocate space for database topics, child topics, and external topics
-	topicList := make([]TopicSummary, 0, len(allTopicConfigs)+len(childTopics)+len(externalTopics))
-
-	// Process database topics
-	for _, config := range allTopicConfigs {
-		// Extract common fields based on config type
-		var summary TopicSummary
-
-		switch cfg := config.(type) {
-		case topics.BaseTopicConfig:
-			summary = TopicSummary{
-				Name:        cfg.Name,
-				Type:        string(cfg.Type),
-				LastValue:   cfg.LastValue,
-				LastUpdated: cfg.LastUpdated,
-				Tags:        cfg.Tags,
-			}
-		case topics.InternalTopicConfig:
-			summary = TopicSummary{
-				Name:        cfg.Name,
-				Type:        string(cfg.Type),
-				LastValue:   cfg.LastValue,
-				LastUpdated: cfg.LastUpdated,
-				Inputs:      cfg.Inputs,
-				InputNames:  cfg.InputNames,
-				StrategyID:  cfg.StrategyID,
-				Parameters:  cfg.Parameters,
-				EmitToMQTT:  cfg.EmitToMQTT,
-				Tags:        cfg.Tags,
-			}
-		case topics.SystemTopicConfig:
-			summary = TopicSummary{
-				Name:        cfg.Name,
-				Type:        string(cfg.Type),
-				LastValue:   cfg.LastValue,
-				LastUpdated: cfg.LastUpdated,
-				Tags:        cfg.Tags,
-			}
-		default:
-			s.logger.Printf("Unknown topic config type: %T", cfg)
-			continue
-		}
-
-		// Apply type filter if specified
-		if topicType != "" && summary.Type != topicType {
-			continue
-		}
-
-		// Apply name filter if specified (case-insensitive substring match)
-		if nameFilter != "" && !strings.Contains(strings.ToLower(summary.Name), strings.ToLower(nameFilter)) {
-			continue
-		}
-
-		// Apply tag filter if specified
-		if tagFilter != "" {
-			found := false
-			for _, tag := range summary.Tags {
-				if strings.EqualFold(tag, tagFilter) {
-					found = true
-					break
-				}
-			}
-			if !found {
-				continue
+	topicList := allTopics
+	if topicType != "" || nameFilter != "" || tagFilter != "" || statusFilter != "" {
+		topicList = make([]TopicSummary, 0, len(allTopics))
+		for _, summary := range allTopics {
+			if matchesTopicFilters(summary, topicType, nameFilter, tagFilter) && s.matchesStatusFilter(summary, statusFilter) {
+				topicList = append(topicList, summary)
 			}
 		}
-
-		topicList = append(topicList, summary)
-	}
-
-	// Add child topics from in-memory topic manager
-	for _, childConfig := range childTopics {
-		summary := TopicSummary{
-			Name:        childConfig.Name,
-			Type:        string(childConfig.Type),
-			LastValue:   childConfig.LastValue,
-			LastUpdated: childConfig.LastUpdated,
-			Inputs:      childConfig.Inputs,
-			InputNames:  childConfig.InputNames,
-			StrategyID:  childConfig.StrategyID,
-			Parameters:  childConfig.Parameters,
-			EmitToMQTT:  childConfig.EmitToMQTT,
-			Tags:        childConfig.Tags,
-		}
-
-		// Apply type filter if specified
-		if topicType != "" && summary.Type != topicType {
-			continue
-		}
-
-		// Apply name filter if specified (case-insensitive substring match)
-		if nameFilter != "" && !strings.Contains(strings.ToLower(summary.Name), strings.ToLower(nameFilter)) {
-			continue
-		}
-
-		// Apply tag filter if specified
-		if tagFilter != "" {
-			found := false
-			for _, tag := range summary.Tags {
-				if strings.EqualFold(tag, tagFilter) {
-					found = true
-					break
-				}
-			}
-			if !found {
-				continue
-			}
-		}
-
-		topicList = append(topicList, summary)
-	}
-
-	// Add external topics from in-memory topic manager
-	for _, externalConfig := range externalTopics {
-		summary := TopicSummary{
-			Name:        externalConfig.Name,
-			Type:        string(externalConfig.Type),
-			LastValue:   externalConfig.LastValue,
-			LastUpdated: externalConfig.LastUpdated,
-			Tags:        externalConfig.Tags,
-		}
-
-		// Apply type filter if specified
-		if topicType != "" && summary.Type != topicType {
-			continue
-		}
-
-		// Apply name filter if specified (case-insensitive substring match)
-		if nameFilter != "" && !strings.Contains(strings.ToLower(summary.Name), strings.ToLower(nameFilter)) {
-			continue
-		}
-
-		// Apply tag filter if specified
-		if tagFilter != "" {
-			found := false
-			for _, tag := range summary.Tags {
-				if strings.EqualFold(tag, tagFilter) {
-					found = true
-					break
-				}
-			}
-			if !found {
-				continue
-			}
-		}
-
-		topicList = append(topicList, summary)
 	}
 
-	// Sort topics by name since we merged from multiple sources
-	sort.Slice(topicList, func(i, j int) bool {
-		return topicList[i].Name < topicList[j].Name
-	})
-
 	total := len(topicList)
 	start := (page - 1) * limit
 	end := start + limit
 
+	var pageTopics []TopicSummary
 	if start >= total {
-		topicList = []TopicSummary{}
+		pageTopics = []TopicSummary{}
 	} else {
 		if end > total {
 			end = total
 		}
-		topicList = topicList[start:end]
+		// Copy the page so refreshing values doesn't modify the cache
+		pageTopics = append([]TopicSummary(nil), topicList[start:end]...)
+	}
+	for i := range pageTopics {
+		s.refreshTopicSummary(&pageTopics[i])
 	}
 
 	response := TopicListResponse{
-		Topics: topicList,
+		Topics: pageTopics,
 		Pagination: PaginationResponse{
 			Page:  page,
 			Limit: limit,
@@ -503,16 +476,199 @@ func (s *Server) handleAPITopicsCreate(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !s.createTopic(w, req) {
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	writeAPIResponse(w, map[string]string{"message": "Topic created successfully"})
+}
+
+// createTopic validates and creates an internal topic, writing the error
+// response and returning false if it cannot be created
+func (s *Server) createTopic(w http.ResponseWriter, req TopicCreateRequest) bool {
 	// Validate required fields
 	if req.Name == "" {
 		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Topic name is required", nil)
-		return
+		return false
+	}
+	if err := s.topicManager.ValidateTopicName(req.Name); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return false
 	}
 
 	// Only support internal topics for creation via API
 	if req.Type != "internal" {
 		writeAPIError(w, http.StatusBadRequest, "INVALID_TYPE", "Only internal topics can be created via API", nil)
-		return
+		return false
+	}
+
+	topicConfig := make(map[string]interface{})
+	displayName, ok := s.validateDisplayName(w, req.DisplayName, req.Name)
+	if !ok {
+		return false
+	}
+	if displayName != "" {
+		topicConfig["display_name"] = displayName
+	}
+	if req.Schedule != "" {
+		if _, err := topics.ParseSchedule(req.Schedule); err != nil {
+			writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+			return false
+		}
+		topicConfig["schedule"] = req.Schedule
+	}
+	nullPolicy, err := topics.ParseNullPolicy(req.NullPolicy)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return false
+	}
+	if nullPolicy != topics.NullPolicyKeep {
+		topicConfig["null_policy"] = string(nullPolicy)
+	}
+	if req.MQTTTopic != "" {
+		topicConfig["mqtt_topic"] = req.MQTTTopic
+	}
+	if req.Group {
+		topicConfig["group"] = true
+	}
+	if req.IgnoreRetained {
+		topicConfig["ignore_retained_trigger"] = true
+	}
+	ttl, err := topics.ParseTTL(req.TTL)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return false
+	}
+	if ttl > 0 {
+		topicConfig["ttl"] = req.TTL
+	}
+	republishInterval, err := topics.ParseRepublishInterval(req.RepublishInterval)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return false
+	}
+	if republishInterval > 0 {
+		topicConfig["republish_interval"] = req.RepublishInterval
+	}
+	coalesceWindow, err := topics.ParseCoalesceWindow(req.CoalesceWindow)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return false
+	}
+	if coalesceWindow > 0 {
+		topicConfig["coalesce_window"] = req.CoalesceWindow
+	}
+	if req.MissingInputPolicy != "" {
+		if _, err := topics.ParseMissingInputPolicy(req.MissingInputPolicy); err != nil {
+			writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+			return false
+		}
+		topicConfig["missing_input_policy"] = req.MissingInputPolicy
+	}
+	publishEncoding, err := topics.ParsePublishEncoding(req.PublishEncoding)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return false
+	}
+	if publishEncoding != topics.PublishEncodingJSON {
+		topicConfig["publish_encoding"] = string(publishEncoding)
+	}
+	if qos, ok, err := topics.ParseQoS(req.QoS); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return false
+	} else if ok {
+		topicConfig["qos"] = int(qos)
+	}
+	if len(req.ChildMQTTOverrides) > 0 {
+		topicConfig["child_mqtt_overrides"] = req.ChildMQTTOverrides
+	}
+	if _, err := topics.ParseSnapshotSize(req.SnapshotSize); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return false
+	}
+	if req.SnapshotSize > 0 {
+		topicConfig["snapshot_size"] = req.SnapshotSize
+	}
+	if _, err := topics.ParseInputTypes(req.InputTypes); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return false
+	}
+	if len(req.InputTypes) > 0 {
+		topicConfig["input_types"] = req.InputTypes
+	}
+	if req.ValueType != "" {
+		if _, err := topics.ParseInputType(req.ValueType); err != nil {
+			writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+			return false
+		}
+		topicConfig["value_type"] = req.ValueType
+	}
+	if _, err := topics.ParseInputDecoders(req.InputDecoders); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return false
+	}
+	if len(req.InputDecoders) > 0 {
+		topicConfig["input_decoders"] = req.InputDecoders
+	}
+	if _, err := topics.ParseValidationRules(req.Validation); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return false
+	}
+	if req.Validation != nil {
+		topicConfig["validation"] = req.Validation
+	}
+	if _, err := topics.ParseOutputSchema(req.OutputSchema); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return false
+	}
+	if req.OutputSchema != nil {
+		topicConfig["output_schema"] = req.OutputSchema
+	}
+	if _, err := topics.ParsePostProcessors(req.PostProcessors); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return false
+	}
+	if len(req.PostProcessors) > 0 {
+		topicConfig["post_processors"] = req.PostProcessors
+	}
+	if !s.validateTransform(w, &req) {
+		return false
+	}
+	if req.Transform != nil {
+		topicConfig["transform"] = req.Transform
+	}
+	if !s.validateLogic(w, &req) {
+		return false
+	}
+	if req.Logic != "" {
+		topicConfig["logic"] = req.Logic
+	}
+
+	if err := s.topicManager.ValidateTopicInputs(req.StrategyID, req.Inputs); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return false
+	}
+	if err := topics.ValidateInputNames(req.Inputs, req.InputNames); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return false
+	}
+	if err := topics.ValidatePassiveInputs(req.Inputs, req.PassiveInputs); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return false
+	}
+	if len(req.PassiveInputs) > 0 {
+		topicConfig["passive_inputs"] = req.PassiveInputs
+	}
+	if err := s.topicManager.CheckDependencyCycle(req.Name, req.Inputs, req.StrategyID); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return false
+	}
+
+	// Use the configured default when emit_to_mqtt is omitted
+	emitToMQTT := s.config.Web.DefaultEmitToMQTT
+	if req.EmitToMQTT != nil {
+		emitToMQTT = *req.EmitToMQTT
 	}
 
 	// Create the topic config
@@ -522,14 +678,14 @@ func (s *Server) handleAPITopicsCreate(w http.ResponseWriter, r *http.Request) {
 			Type:        topics.TopicTypeInternal,
 			CreatedAt:   time.Now(),
 			LastUpdated: time.Now(),
-			Config:      make(map[string]interface{}),
+			Config:      topicConfig,
 			Tags:        req.Tags,
 		},
 		Inputs:        req.Inputs,
 		InputNames:    req.InputNames,
 		StrategyID:    req.StrategyID,
 		Parameters:    req.Parameters,
-		EmitToMQTT:    req.EmitToMQTT,
+		EmitToMQTT:    emitToMQTT,
 		NoOpUnchanged: req.NoOpUnchanged,
 	}
 
@@ -537,23 +693,78 @@ func (s *Server) handleAPITopicsCreate(w http.ResponseWriter, r *http.Request) {
 	if err := s.stateManager.SaveTopicConfig(config); err != nil {
 		s.logger.Printf("Failed to save topic to database: %v", err)
 		writeAPIError(w, http.StatusInternalServerError, "DATABASE_ERROR", "Failed to save topic", nil)
-		return
+		return false
 	}
+	s.invalidateTopicList()
 
 	// Create in-memory version
-	_, err := s.topicManager.AddInternalTopic(req.Name, req.Inputs, req.InputNames, req.StrategyID, req.Parameters, req.EmitToMQTT, req.NoOpUnchanged)
+	topic, err := s.topicManager.AddInternalTopic(req.Name, req.Inputs, req.InputNames, req.StrategyID, req.Parameters, emitToMQTT, req.NoOpUnchanged)
+	if err == nil {
+		err = topic.SetNullPolicy(nullPolicy)
+	}
+	if err == nil {
+		topic.SetMQTTTopicTemplate(req.MQTTTopic)
+		topic.SetGroup(req.Group)
+		topic.SetIgnoreRetainedTrigger(req.IgnoreRetained)
+		topic.SetTTL(ttl)
+		topic.SetRepublishInterval(republishInterval)
+		topic.SetCoalesceWindow(coalesceWindow)
+		topic.SetChildMQTTOverrides(req.ChildMQTTOverrides)
+		err = topic.SetMissingInputPolicy(topics.MissingInputPolicy(req.MissingInputPolicy))
+	}
+	if err == nil {
+		err = topic.SetPublishEncoding(publishEncoding)
+	}
+	if err == nil {
+		err = topic.SetQoS(req.QoS)
+	}
+	if err == nil {
+		err = topic.SetSnapshotSize(req.SnapshotSize)
+	}
+	if err == nil {
+		err = topic.SetPassiveInputs(req.PassiveInputs)
+	}
+	if err == nil {
+		err = topic.SetInputTypes(req.InputTypes)
+	}
+	if err == nil {
+		err = topic.SetValueType(req.ValueType)
+	}
+	if err == nil {
+		err = topic.SetInputDecoders(req.InputDecoders)
+	}
+	if err == nil {
+		err = topic.SetValidationRules(req.Validation)
+	}
+	if err == nil {
+		err = topic.SetOutputSchema(req.OutputSchema)
+	}
+	if err == nil {
+		err = topic.SetPostProcessors(req.PostProcessors)
+	}
+	if err == nil {
+		err = topic.SetTransform(req.Transform)
+	}
+	if err == nil {
+		err = topic.SetLogic(req.Logic)
+	}
+	if err == nil {
+		err = topic.SetDisplayName(displayName)
+	}
+	if err == nil {
+		err = topic.SetSchedule(req.Schedule)
+	}
 	if err != nil {
 		s.logger.Printf("Failed to create topic in memory: %v", err)
 		// Try to reload from database instead
 		if reloadErr := s.topicManager.ReloadTopicFromDatabase(req.Name); reloadErr != nil {
 			s.logger.Printf("Failed to reload topic from database: %v", reloadErr)
 			writeAPIError(w, http.StatusInternalServerError, "TOPIC_LOAD_ERROR", "Topic saved but failed to load in memory", nil)
-			return
+			return false
 		}
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	writeAPIResponse(w, map[string]string{"message": "Topic created successfully"})
+	return true
 }
 
 // Topic detail endpoint
@@ -567,6 +778,47 @@ func (s *Server) handleAPITopicDetail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Handle history sub-path (topic names may contain slashes, so match the suffix)
+	if r.Method == "GET" && strings.HasSuffix(topicName, "/history") {
+		s.handleAPITopicHistory(w, r, strings.TrimSuffix(topicName, "/history"))
+		return
+	}
+	if r.Method == "PATCH" && strings.HasSuffix(topicName, "/parameters") {
+		s.handleAPITopicParametersPatch(w, r, strings.TrimSuffix(topicName, "/parameters"))
+		return
+	}
+	if r.Method == "GET" && strings.HasSuffix(topicName, "/logs/verify") {
+		s.handleAPITopicLogsVerify(w, r, strings.TrimSuffix(topicName, "/logs/verify"))
+		return
+	}
+	if r.Method == "GET" && strings.HasSuffix(topicName, "/logs.csv") {
+		s.handleAPITopicLogsCSV(w, r, strings.TrimSuffix(topicName, "/logs.csv"))
+		return
+	}
+	if r.Method == "GET" && strings.HasSuffix(topicName, "/logs") {
+		if strings.Contains(r.Header.Get("Accept"), "text/csv") {
+			s.handleAPITopicLogsCSV(w, r, strings.TrimSuffix(topicName, "/logs"))
+		} else {
+			s.handleAPITopicLogs(w, r, strings.TrimSuffix(topicName, "/logs"))
+		}
+		return
+	}
+
+	// ?by=display_name looks the topic up by its display name instead
+	switch by := r.URL.Query().Get("by"); by {
+	case "", "name":
+	case "display_name":
+		name, found := s.topicManager.FindTopicByDisplayName(topicName)
+		if !found {
+			writeAPIError(w, http.StatusNotFound, "NOT_FOUND", "Topic not found", nil)
+			return
+		}
+		topicName = name
+	default:
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("Invalid lookup %q (must be name or display_name)", by), nil)
+		return
+	}
+
 	switch r.Method {
 	case "GET":
 		s.handleAPITopicGet(w, r, topicName)
@@ -579,7 +831,70 @@ func (s *Server) handleAPITopicDetail(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// validateDisplayName checks a requested display name is valid and not used
+// by another topic, writing the error response when it is not
+func (s *Server) validateDisplayName(w http.ResponseWriter, displayName, topicName string) (string, bool) {
+	name, err := topics.ParseDisplayName(displayName)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return "", false
+	}
+	if owner, found := s.topicManager.FindTopicByDisplayName(name); found && owner != topicName {
+		writeAPIError(w, http.StatusConflict, "CONFLICT", fmt.Sprintf("Display name %q is already used by topic %s", name, owner), nil)
+		return "", false
+	}
+	return name, true
+}
+
+// validateTransform checks a requested built-in transform, writing the error
+// response when it is invalid. Transform topics without a strategy get
+// topics.TransformStrategyID.
+func (s *Server) validateTransform(w http.ResponseWriter, req *TopicCreateRequest) bool {
+	if req.Transform == nil {
+		return true
+	}
+	if _, err := topics.ParseTransform(req.Transform); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return false
+	}
+	if len(req.Inputs) != 1 {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("Transform %s needs exactly one input", req.Transform.Type), nil)
+		return false
+	}
+	if req.StrategyID == "" {
+		req.StrategyID = topics.TransformStrategyID
+	}
+	return true
+}
+
+// validateLogic checks a requested logic expression, writing the error
+// response when it is invalid. Logic topics without a strategy get
+// topics.LogicStrategyID.
+func (s *Server) validateLogic(w http.ResponseWriter, req *TopicCreateRequest) bool {
+	if req.Logic == "" {
+		return true
+	}
+	if req.Transform != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", "A topic cannot have both a transform and a logic expression", nil)
+		return false
+	}
+	if err := topics.ValidateLogic(req.Logic, req.Inputs, req.InputNames); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return false
+	}
+	if req.StrategyID == "" {
+		req.StrategyID = topics.LogicStrategyID
+	}
+	return true
+}
+
 func (s *Server) handleAPITopicGet(w http.ResponseWriter, r *http.Request, topicName string) {
+	reveal, ok := s.revealParameters(r)
+	if !ok {
+		writeAPIError(w, http.StatusForbidden, "FORBIDDEN", "Revealing parameters requires the admin token", nil)
+		return
+	}
+
 	// Load from database (source of truth)
 	configInterface, err := s.stateManager.LoadTopicConfig(topicName)
 	if err != nil {
@@ -599,24 +914,65 @@ func (s *Server) handleAPITopicGet(w http.ResponseWriter, r *http.Request, topic
 	detail.Type = string(topic.Type())
 	detail.LastValue = topic.LastValue()
 	detail.LastUpdated = topic.LastUpdated()
+	detail.Status, _ = s.topicManager.TopicStatus(topicName)
+	if externalTopic, ok := topic.(*topics.ExternalTopic); ok {
+		detail.Binary = externalTopic.IsBinary()
+		detail.IngressStrategy = externalTopic.GetIngressStrategy()
+	}
 
 	// Handle different topic types
 	switch cfg := configInterface.(type) {
 	case topics.InternalTopicConfig:
+		detail.DisplayName = topics.DisplayName(cfg.Config)
 		detail.CreatedAt = cfg.CreatedAt
 		detail.Inputs = cfg.Inputs
 		detail.InputNames = cfg.InputNames
 		detail.StrategyID = cfg.StrategyID
 		detail.Parameters = cfg.Parameters
+		detail.EffectiveParameters = s.topicManager.EffectiveParameters(cfg.StrategyID, cfg.Parameters)
+		if !reveal {
+			detail.Parameters = redactParameters(detail.Parameters)
+			detail.EffectiveParameters = redactParameters(detail.EffectiveParameters)
+		}
 		detail.EmitToMQTT = cfg.EmitToMQTT
 		detail.NoOpUnchanged = cfg.NoOpUnchanged
+		detail.Schedule, _ = cfg.Config["schedule"].(string)
+		detail.NullPolicy, _ = cfg.Config["null_policy"].(string)
+		detail.MQTTTopic, _ = cfg.Config["mqtt_topic"].(string)
+		detail.PublishEncoding, _ = cfg.Config["publish_encoding"].(string)
+		if qos, ok, _ := topics.ParseQoS(cfg.Config["qos"]); ok {
+			level := int(qos)
+			detail.QoS = &level
+		}
+		detail.Group, _ = cfg.Config["group"].(bool)
+		detail.IgnoreRetained, _ = cfg.Config["ignore_retained_trigger"].(bool)
+		detail.PassiveInputs, _ = topics.ParsePassiveInputs(cfg.Config["passive_inputs"])
+		detail.TTL, _ = cfg.Config["ttl"].(string)
+		detail.RepublishInterval, _ = cfg.Config["republish_interval"].(string)
+		detail.CoalesceWindow, _ = cfg.Config["coalesce_window"].(string)
+		detail.MissingInputPolicy, _ = cfg.Config["missing_input_policy"].(string)
+		detail.ChildMQTTOverrides = topics.ParseChildMQTTOverrides(cfg.Config["child_mqtt_overrides"])
+		detail.SnapshotSize, _ = topics.ParseSnapshotSize(cfg.Config["snapshot_size"])
+		detail.InputTypes, _ = topics.ParseInputTypes(cfg.Config["input_types"])
+		detail.ValueType, _ = cfg.Config["value_type"].(string)
+		detail.InputDecoders, _ = topics.ParseInputDecoders(cfg.Config["input_decoders"])
+		detail.Validation, _ = topics.ParseValidationRules(cfg.Config["validation"])
+		detail.OutputSchema, _ = topics.ParseOutputSchema(cfg.Config["output_schema"])
+		detail.PostProcessors, _ = topics.ParsePostProcessors(cfg.Config["post_processors"])
+		detail.Transform, _ = topics.ParseTransform(cfg.Config["transform"])
+		detail.Logic, _ = cfg.Config["logic"].(string)
+		if internalTopic, ok := topic.(*topics.InternalTopic); ok {
+			detail.RecentValues = internalTopic.RecentValues()
+		}
 		detail.Config = cfg.Config
 		detail.Tags = cfg.Tags
 	case topics.BaseTopicConfig:
+		detail.DisplayName = topics.DisplayName(cfg.Config)
 		detail.CreatedAt = cfg.CreatedAt
 		detail.Config = cfg.Config
 		detail.Tags = cfg.Tags
 	case topics.SystemTopicConfig:
+		detail.DisplayName = topics.DisplayName(cfg.Config)
 		detail.CreatedAt = cfg.CreatedAt
 		detail.Config = cfg.Config
 		detail.Tags = cfg.Tags
@@ -632,6 +988,11 @@ func (s *Server) handleAPITopicUpdate(w http.ResponseWriter, r *http.Request, to
 		return
 	}
 
+	if err := s.topicManager.ValidateTopicName(topicName); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return
+	}
+
 	// Get existing topic
 	topic := s.topicManager.GetInternalTopic(topicName)
 	if topic == nil {
@@ -639,13 +1000,228 @@ func (s *Server) handleAPITopicUpdate(w http.ResponseWriter, r *http.Request, to
 		return
 	}
 
+	displayName, ok := s.validateDisplayName(w, req.DisplayName, topicName)
+	if !ok {
+		return
+	}
+	if req.Schedule != "" {
+		if _, err := topics.ParseSchedule(req.Schedule); err != nil {
+			writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+			return
+		}
+	}
+	nullPolicy, err := topics.ParseNullPolicy(req.NullPolicy)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return
+	}
+	if _, err := topics.ParseTTL(req.TTL); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return
+	}
+	if _, err := topics.ParseRepublishInterval(req.RepublishInterval); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return
+	}
+	if _, err := topics.ParseCoalesceWindow(req.CoalesceWindow); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return
+	}
+	if _, err := topics.ParseMissingInputPolicy(req.MissingInputPolicy); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return
+	}
+	publishEncoding, err := topics.ParsePublishEncoding(req.PublishEncoding)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return
+	}
+	qos, hasQoS, err := topics.ParseQoS(req.QoS)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return
+	}
+	if _, err := topics.ParseSnapshotSize(req.SnapshotSize); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return
+	}
+	if _, err := topics.ParseInputTypes(req.InputTypes); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return
+	}
+	if req.ValueType != "" {
+		if _, err := topics.ParseInputType(req.ValueType); err != nil {
+			writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+			return
+		}
+	}
+	if _, err := topics.ParseInputDecoders(req.InputDecoders); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return
+	}
+	if _, err := topics.ParseValidationRules(req.Validation); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return
+	}
+	if _, err := topics.ParseOutputSchema(req.OutputSchema); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return
+	}
+	if err := s.topicManager.ValidateTopicInputs(req.StrategyID, req.Inputs); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return
+	}
+	if err := topics.ValidateInputNames(req.Inputs, req.InputNames); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return
+	}
+	if err := topics.ValidatePassiveInputs(req.Inputs, req.PassiveInputs); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return
+	}
+	if err := s.topicManager.CheckDependencyCycle(topicName, req.Inputs, req.StrategyID); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return
+	}
+	if _, err := topics.ParsePostProcessors(req.PostProcessors); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return
+	}
+	if !s.validateTransform(w, &req) {
+		return
+	}
+	if !s.validateLogic(w, &req) {
+		return
+	}
+
 	// Update config
 	config := topic.GetConfig()
+	if config.Config == nil {
+		config.Config = make(map[string]interface{})
+	}
+	if displayName != "" {
+		config.Config["display_name"] = displayName
+	} else {
+		delete(config.Config, "display_name")
+	}
+	if req.Schedule != "" {
+		config.Config["schedule"] = req.Schedule
+	} else {
+		delete(config.Config, "schedule")
+	}
+	if nullPolicy != topics.NullPolicyKeep {
+		config.Config["null_policy"] = string(nullPolicy)
+	} else {
+		delete(config.Config, "null_policy")
+	}
+	if req.MQTTTopic != "" {
+		config.Config["mqtt_topic"] = req.MQTTTopic
+	} else {
+		delete(config.Config, "mqtt_topic")
+	}
+	if req.Group {
+		config.Config["group"] = true
+	} else {
+		delete(config.Config, "group")
+	}
+	if req.IgnoreRetained {
+		config.Config["ignore_retained_trigger"] = true
+	} else {
+		delete(config.Config, "ignore_retained_trigger")
+	}
+	if len(req.PassiveInputs) > 0 {
+		config.Config["passive_inputs"] = req.PassiveInputs
+	} else {
+		delete(config.Config, "passive_inputs")
+	}
+	if req.TTL != "" {
+		config.Config["ttl"] = req.TTL
+	} else {
+		delete(config.Config, "ttl")
+	}
+	if req.RepublishInterval != "" {
+		config.Config["republish_interval"] = req.RepublishInterval
+	} else {
+		delete(config.Config, "republish_interval")
+	}
+	if req.CoalesceWindow != "" {
+		config.Config["coalesce_window"] = req.CoalesceWindow
+	} else {
+		delete(config.Config, "coalesce_window")
+	}
+	if req.MissingInputPolicy != "" {
+		config.Config["missing_input_policy"] = req.MissingInputPolicy
+	} else {
+		delete(config.Config, "missing_input_policy")
+	}
+	if publishEncoding != topics.PublishEncodingJSON {
+		config.Config["publish_encoding"] = string(publishEncoding)
+	} else {
+		delete(config.Config, "publish_encoding")
+	}
+	if hasQoS {
+		config.Config["qos"] = int(qos)
+	} else {
+		delete(config.Config, "qos")
+	}
+	if len(req.ChildMQTTOverrides) > 0 {
+		config.Config["child_mqtt_overrides"] = req.ChildMQTTOverrides
+	} else {
+		delete(config.Config, "child_mqtt_overrides")
+	}
+	if req.SnapshotSize > 0 {
+		config.Config["snapshot_size"] = req.SnapshotSize
+	} else {
+		delete(config.Config, "snapshot_size")
+	}
+	if len(req.InputTypes) > 0 {
+		config.Config["input_types"] = req.InputTypes
+	} else {
+		delete(config.Config, "input_types")
+	}
+	if req.ValueType != "" {
+		config.Config["value_type"] = req.ValueType
+	} else {
+		delete(config.Config, "value_type")
+	}
+	if len(req.InputDecoders) > 0 {
+		config.Config["input_decoders"] = req.InputDecoders
+	} else {
+		delete(config.Config, "input_decoders")
+	}
+	if req.Validation != nil {
+		config.Config["validation"] = req.Validation
+	} else {
+		delete(config.Config, "validation")
+	}
+	if req.OutputSchema != nil {
+		config.Config["output_schema"] = req.OutputSchema
+	} else {
+		delete(config.Config, "output_schema")
+	}
+	if len(req.PostProcessors) > 0 {
+		config.Config["post_processors"] = req.PostProcessors
+	} else {
+		delete(config.Config, "post_processors")
+	}
+	if req.Transform != nil {
+		config.Config["transform"] = req.Transform
+	} else {
+		delete(config.Config, "transform")
+	}
+	if req.Logic != "" {
+		config.Config["logic"] = req.Logic
+	} else {
+		delete(config.Config, "logic")
+	}
 	config.Inputs = req.Inputs
 	config.InputNames = req.InputNames
 	config.StrategyID = req.StrategyID
+	restoreRedactedParameters(req.Parameters, config.Parameters)
 	config.Parameters = req.Parameters
-	config.EmitToMQTT = req.EmitToMQTT
+	if req.EmitToMQTT != nil {
+		config.EmitToMQTT = *req.EmitToMQTT
+	}
 	config.NoOpUnchanged = req.NoOpUnchanged
 	config.Type = topics.TopicTypeInternal
 	config.Tags = req.Tags
@@ -656,6 +1232,7 @@ func (s *Server) handleAPITopicUpdate(w http.ResponseWriter, r *http.Request, to
 		writeAPIError(w, http.StatusInternalServerError, "DATABASE_ERROR", "Failed to save topic", nil)
 		return
 	}
+	s.invalidateTopicList()
 
 	// Reload in-memory version
 	if err := s.topicManager.ReloadTopicFromDatabase(topicName); err != nil {
@@ -665,18 +1242,314 @@ func (s *Server) handleAPITopicUpdate(w http.ResponseWriter, r *http.Request, to
 	writeAPIResponse(w, map[string]string{"message": "Topic updated successfully"})
 }
 
+// TopicParametersResponse is the response of a topic parameters update
+type TopicParametersResponse struct {
+	Parameters          map[string]interface{} `json:"parameters"`
+	EffectiveParameters map[string]interface{} `json:"effective_parameters"`
+}
+
+// handleAPITopicParametersPatch updates only an internal topic's parameter
+// overrides. The body is a JSON object of overrides to set; null removes one.
+// The change is persisted and used by the next execution without reloading
+// the topic.
+func (s *Server) handleAPITopicParametersPatch(w http.ResponseWriter, r *http.Request, topicName string) {
+	var changes map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&changes); err != nil || changes == nil {
+		writeAPIError(w, http.StatusBadRequest, "INVALID_JSON", "Request body must be a JSON object of parameters", nil)
+		return
+	}
+
+	topic := s.topicManager.GetInternalTopic(topicName)
+	if topic == nil {
+		writeAPIError(w, http.StatusNotFound, "NOT_FOUND", "Topic not found", nil)
+		return
+	}
+
+	// Redacted values sent back by a client keep the stored value
+	for key, value := range changes {
+		if value == redactedParameterValue {
+			delete(changes, key)
+		}
+	}
+
+	parameters, err := topic.UpdateParameters(changes, func(parameters map[string]interface{}) error {
+		config := topic.GetConfig()
+		config.Parameters = parameters
+		return s.stateManager.SaveTopicConfig(config)
+	})
+	if err != nil {
+		s.logger.Printf("Failed to save parameters of topic %s: %v", topicName, err)
+		writeAPIError(w, http.StatusInternalServerError, "DATABASE_ERROR", "Failed to save topic", nil)
+		return
+	}
+	s.invalidateTopicList()
+
+	response := TopicParametersResponse{
+		Parameters:          parameters,
+		EffectiveParameters: s.topicManager.EffectiveParameters(topic.GetConfig().StrategyID, parameters),
+	}
+	if reveal, _ := s.revealParameters(r); !reveal {
+		response.Parameters = redactParameters(response.Parameters)
+		response.EffectiveParameters = redactParameters(response.EffectiveParameters)
+	}
+	writeAPIResponse(w, response)
+}
+
+// handleAPITopicDelete deletes a topic. ?cascade=delete|detach|warn chooses
+// what happens to the topics that have it as an input (default warn); when
+// it is given the response reports the affected topics.
 func (s *Server) handleAPITopicDelete(w http.ResponseWriter, r *http.Request, topicName string) {
+	cascade, err := topics.ParseDeleteCascade(r.URL.Query().Get("cascade"))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return
+	}
+
 	// Delete from database first
 	if err := s.stateManager.DeleteTopicConfig(topicName); err != nil {
 		s.logger.Printf("Failed to delete topic from database: %v", err)
 		writeAPIError(w, http.StatusInternalServerError, "DATABASE_ERROR", "Failed to delete topic", nil)
 		return
 	}
+	s.invalidateTopicList()
 
-	// Remove from memory
-	if err := s.topicManager.RemoveTopic(topicName); err != nil {
+	// Remove from memory, then persist the changes to its dependents
+	result, err := s.topicManager.RemoveTopicCascade(topicName, cascade)
+	if err != nil {
 		s.logger.Printf("Failed to remove topic from memory: %v", err)
+		result = topics.CascadeResult{Deleted: []string{topicName}}
+	}
+	for _, name := range result.Deleted[1:] {
+		if err := s.stateManager.DeleteTopicConfig(name); err != nil {
+			s.logger.Printf("Failed to delete dependent topic %s from database: %v", name, err)
+		}
+	}
+	for _, name := range result.Detached {
+		if topic := s.topicManager.GetInternalTopic(name); topic != nil {
+			if err := s.stateManager.SaveTopicConfig(topic.GetConfig()); err != nil {
+				s.logger.Printf("Failed to save detached topic %s to database: %v", name, err)
+			}
+		}
+	}
+
+	if r.URL.Query().Get("cascade") == "" {
+		w.WriteHeader(http.StatusNoContent)
+		return
 	}
+	writeAPIResponse(w, result)
+}
 
-	w.WriteHeader(http.StatusNoContent)
+func (s *Server) handleAPITopicHistory(w http.ResponseWriter, r *http.Request, topicName string) {
+	if !s.stateManager.IsHistoryEnabled() {
+		writeAPIError(w, http.StatusNotFound, "HISTORY_DISABLED", "Topic history is not enabled", nil)
+		return
+	}
+
+	// Default to the last 24 hours
+	to := time.Now()
+	if v := r.URL.Query().Get("to"); v != "" {
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid 'to' time, expected RFC3339", nil)
+			return
+		}
+		to = parsed
+	}
+
+	from := to.Add(-24 * time.Hour)
+	if v := r.URL.Query().Get("from"); v != "" {
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid 'from' time, expected RFC3339", nil)
+			return
+		}
+		from = parsed
+	}
+
+	if from.After(to) {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", "'from' must not be after 'to'", nil)
+		return
+	}
+
+	entries, err := s.stateManager.LoadTopicHistory(topicName, from, to)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, "DATABASE_ERROR", "Failed to load topic history", nil)
+		return
+	}
+
+	history := make([]TopicHistoryPoint, 0, len(entries))
+	for _, entry := range entries {
+		history = append(history, TopicHistoryPoint{
+			Value:     entry.Value,
+			Timestamp: entry.RecordedAt,
+		})
+	}
+
+	writeAPIResponse(w, TopicHistoryResponse{
+		Topic:   topicName,
+		From:    from,
+		To:      to,
+		History: history,
+	})
+}
+
+// handleAPITopicLogs returns a topic's most recent strategy executions. The
+// optional level parameter drops strategy log messages below that severity.
+func (s *Server) handleAPITopicLogs(w http.ResponseWriter, r *http.Request, topicName string) {
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 {
+			writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", "limit must be a positive integer", nil)
+			return
+		}
+		limit = parsed
+	}
+
+	level := strategy.LogLevelDebug
+	if v := r.URL.Query().Get("level"); v != "" {
+		parsed, err := strategy.ParseLogLevel(v)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+			return
+		}
+		level = parsed
+	}
+
+	logs, err := s.stateManager.LoadExecutionLogs(topicName, limit)
+	if err != nil {
+		s.logger.Printf("Failed to load execution logs for %s: %v", topicName, err)
+		writeAPIError(w, http.StatusInternalServerError, "DATABASE_ERROR", "Failed to load execution logs", nil)
+		return
+	}
+
+	entries := make([]ExecutionLogEntry, 0, len(logs))
+	for _, log := range logs {
+		messages := make([]strategy.LogMessage, 0, len(log.LogMessages))
+		for _, msg := range log.LogMessages {
+			if level.Allows(msg.Level) {
+				messages = append(messages, msg)
+			}
+		}
+
+		entries = append(entries, ExecutionLogEntry{
+			ID:              log.ID,
+			StrategyID:      log.StrategyID,
+			TriggerTopic:    log.TriggerTopic,
+			InputValues:     log.InputValues,
+			OutputValues:    log.OutputValues,
+			Error:           log.ErrorMessage,
+			ExecutionTimeMs: log.ExecutionTimeMs,
+			LogMessages:     messages,
+			ExecutedAt:      log.ExecutedAt,
+			OutputDiff:      log.OutputDiff,
+		})
+	}
+
+	writeAPIResponse(w, TopicLogsResponse{
+		Topic: topicName,
+		Logs:  entries,
+	})
+}
+
+// TopicLogsVerifyResponse reports whether a topic's execution log audit hash
+// chain is intact
+type TopicLogsVerifyResponse struct {
+	Topic   string `json:"topic"`
+	Enabled bool   `json:"enabled"`
+	state.ChainVerification
+}
+
+// handleAPITopicLogsVerify recomputes a topic's execution log hash chain
+func (s *Server) handleAPITopicLogsVerify(w http.ResponseWriter, r *http.Request, topicName string) {
+	result, err := s.stateManager.VerifyExecutionChain(topicName)
+	if err != nil {
+		s.logger.Printf("Failed to verify execution logs for %s: %v", topicName, err)
+		writeAPIError(w, http.StatusInternalServerError, "DATABASE_ERROR", "Failed to verify execution logs", nil)
+		return
+	}
+
+	writeAPIResponse(w, TopicLogsVerifyResponse{
+		Topic:             topicName,
+		Enabled:           s.stateManager.AuditHashChainEnabled(),
+		ChainVerification: result,
+	})
+}
+
+// executionLogCSVHeader lists the columns of the execution log CSV export.
+// Inputs and outputs are flattened to JSON objects keyed by input name and
+// output topic.
+var executionLogCSVHeader = []string{"executed_at", "strategy_id", "trigger_topic", "duration_ms", "error", "inputs", "outputs"}
+
+// handleAPITopicLogsCSV streams a topic's execution logs as CSV, newest first.
+// Every log is exported unless limit is given.
+func (s *Server) handleAPITopicLogsCSV(w http.ResponseWriter, r *http.Request, topicName string) {
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 {
+			writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", "limit must be a positive integer", nil)
+			return
+		}
+		limit = parsed
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+strings.ReplaceAll(topicName, "/", "_")+`-logs.csv"`)
+
+	writer := csv.NewWriter(w)
+	flusher, _ := w.(http.Flusher)
+	if err := writer.Write(executionLogCSVHeader); err != nil {
+		return
+	}
+
+	rows := 0
+	err := s.stateManager.EachExecutionLog(topicName, limit, func(log state.ExecutionLog) error {
+		if err := writer.Write(executionLogCSVRow(log)); err != nil {
+			return err
+		}
+		rows++
+		if rows%100 == 0 {
+			writer.Flush()
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		return writer.Error()
+	})
+	writer.Flush()
+	if err != nil {
+		// Headers are already sent, so the export is truncated
+		s.logger.Printf("Failed to export execution logs for %s: %v", topicName, err)
+	}
+}
+
+// executionLogCSVRow formats one execution log as a CSV row
+func executionLogCSVRow(log state.ExecutionLog) []string {
+	inputs, _ := json.Marshal(log.InputValues)
+	outputs, _ := json.Marshal(flattenExecutionOutputs(log.OutputValues))
+
+	return []string{
+		log.ExecutedAt.UTC().Format(time.RFC3339Nano),
+		log.StrategyID,
+		log.TriggerTopic,
+		strconv.FormatInt(log.ExecutionTimeMs, 10),
+		log.ErrorMessage,
+		string(inputs),
+		string(outputs),
+	}
+}
+
+// flattenExecutionOutputs turns the stored emit events into an object keyed
+// by output topic, using "" for the topic's main value
+func flattenExecutionOutputs(outputs interface{}) map[string]interface{} {
+	flattened := make(map[string]interface{})
+	events, _ := outputs.([]interface{})
+	for _, event := range events {
+		if e, ok := event.(map[string]interface{}); ok {
+			topic, _ := e["topic"].(string)
+			flattened[topic] = e["value"]
+		}
+	}
+	return flattened
 }
@@ -3,6 +3,7 @@ package web
 import (
 	"encoding/json"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 
@@ -26,6 +27,18 @@ func (s *Server) handleAPIStrategiesList(w http.ResponseWriter, r *http.Request)
 	page, limit := parsePagination(r)
 	languageFilter := r.URL.Query().Get("language")
 	typeFilter := r.URL.Query().Get("type") // "all", "builtin", or "custom"
+	sortBy := r.URL.Query().Get("sort")     // "name", "usage_count" or "last_executed_at"
+	order := r.URL.Query().Get("order")     // "asc" or "desc"
+
+	less, ok := strategySortFuncs[sortBy]
+	if !ok {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", "sort must be one of name, usage_count or last_executed_at", nil)
+		return
+	}
+	if order != "" && order != "asc" && order != "desc" {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", "order must be asc or desc", nil)
+		return
+	}
 
 	// Get all strategies from database (already ordered by name)
 	allStrategies, err := s.stateManager.LoadAllStrategies()
@@ -35,6 +48,13 @@ func (s *Server) handleAPIStrategiesList(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	usage, err := s.stateManager.LoadStrategyUsage()
+	if err != nil {
+		s.logger.Printf("Failed to load strategy usage from database: %v", err)
+		writeAPIError(w, http.StatusInternalServerError, "DATABASE_ERROR", "Failed to load strategy usage", nil)
+		return
+	}
+
 	// Convert to slice for filtering and pagination
 	strategyList := make([]StrategySummary, 0, len(allStrategies))
 	for _, strat := range allStrategies {
@@ -61,10 +81,22 @@ func (s *Server) handleAPIStrategiesList(w http.ResponseWriter, r *http.Request)
 			UpdatedAt:         strat.UpdatedAt,
 			MaxInputs:         strat.MaxInputs,
 			DefaultInputNames: strat.DefaultInputNames,
+			Library:           strat.Library,
+			Circuits:          s.strategyEngine.CircuitStatuses(strat.ID),
+			UsageCount:        usage[strat.ID].UsageCount,
+			LastExecutedAt:    usage[strat.ID].LastExecutedAt,
 		}
 		strategyList = append(strategyList, summary)
 	}
 
+	// Stable sort keeps the name ordering from the database for ties
+	sort.SliceStable(strategyList, func(i, j int) bool {
+		if order == "desc" {
+			return less(strategyList[j], strategyList[i])
+		}
+		return less(strategyList[i], strategyList[j])
+	})
+
 	total := len(strategyList)
 	start := (page - 1) * limit
 	end := start + limit
@@ -91,6 +123,25 @@ func (s *Server) handleAPIStrategiesList(w http.ResponseWriter, r *http.Request)
 	writeAPIResponse(w, response)
 }
 
+// strategySortFuncs maps the strategy list sort parameter to its ordering
+var strategySortFuncs = map[string]func(a, b StrategySummary) bool{
+	"": func(a, b StrategySummary) bool {
+		return a.Name < b.Name
+	},
+	"name": func(a, b StrategySummary) bool {
+		return a.Name < b.Name
+	},
+	"usage_count": func(a, b StrategySummary) bool {
+		return a.UsageCount < b.UsageCount
+	},
+	"last_executed_at": func(a, b StrategySummary) bool {
+		if a.LastExecutedAt == nil || b.LastExecutedAt == nil {
+			return a.LastExecutedAt == nil && b.LastExecutedAt != nil
+		}
+		return a.LastExecutedAt.Before(*b.LastExecutedAt)
+	},
+}
+
 func (s *Server) handleAPIStrategiesCreate(w http.ResponseWriter, r *http.Request) {
 	var req StrategyCreateRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -98,18 +149,39 @@ func (s *Server) handleAPIStrategiesCreate(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if !s.createStrategy(w, req) {
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	writeAPIResponse(w, map[string]string{"message": "Strategy created successfully"})
+}
+
+// createStrategy validates and creates a strategy, writing the error response
+// and returning false if it cannot be created
+func (s *Server) createStrategy(w http.ResponseWriter, req StrategyCreateRequest) bool {
 	// Validate required fields
 	if req.ID == "" {
 		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Strategy ID is required", nil)
-		return
+		return false
 	}
 	if req.Name == "" {
 		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Strategy name is required", nil)
-		return
+		return false
 	}
 	if req.Code == "" {
 		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Strategy code is required", nil)
-		return
+		return false
+	}
+
+	logLevel, err := strategy.ParseLogLevel(req.LogLevel)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return false
+	}
+	if req.TimeoutMs < 0 {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Strategy timeout must not be negative", nil)
+		return false
 	}
 
 	// Set defaults
@@ -122,23 +194,27 @@ func (s *Server) handleAPIStrategiesCreate(w http.ResponseWriter, r *http.Reques
 
 	// Create strategy
 	strat := &strategy.Strategy{
-		ID:                req.ID,
-		Name:              req.Name,
-		Description:       req.Description,
-		Code:              req.Code,
-		Language:          req.Language,
-		Parameters:        req.Parameters,
-		MaxInputs:         req.MaxInputs,
-		DefaultInputNames: req.DefaultInputNames,
-		CreatedAt:         time.Now(),
-		UpdatedAt:         time.Now(),
+		ID:                   req.ID,
+		Name:                 req.Name,
+		Description:          req.Description,
+		Code:                 req.Code,
+		Language:             req.Language,
+		Parameters:           req.Parameters,
+		MaxInputs:            req.MaxInputs,
+		DefaultInputNames:    req.DefaultInputNames,
+		AllowedInputPatterns: req.AllowedInputPatterns,
+		Library:              req.Library,
+		LogLevel:             logLevel,
+		TimeoutMs:            req.TimeoutMs,
+		CreatedAt:            time.Now(),
+		UpdatedAt:            time.Now(),
 	}
 
 	// Save to database first
 	if err := s.stateManager.SaveStrategy(strat); err != nil {
 		s.logger.Printf("Failed to save strategy to database: %v", err)
 		writeAPIError(w, http.StatusInternalServerError, "DATABASE_ERROR", "Failed to save strategy", nil)
-		return
+		return false
 	}
 
 	// Add to in-memory engine
@@ -148,12 +224,11 @@ func (s *Server) handleAPIStrategiesCreate(w http.ResponseWriter, r *http.Reques
 		if reloadErr := s.strategyEngine.ReloadStrategyFromDatabase(strat.ID, strat); reloadErr != nil {
 			s.logger.Printf("Failed to reload strategy from database: %v", reloadErr)
 			writeAPIError(w, http.StatusInternalServerError, "STRATEGY_LOAD_ERROR", "Strategy saved but failed to load in memory", nil)
-			return
+			return false
 		}
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	writeAPIResponse(w, map[string]string{"message": "Strategy created successfully"})
+	return true
 }
 
 // Strategy detail endpoint
@@ -168,13 +243,31 @@ func (s *Server) handleAPIStrategyDetail(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Handle sub-paths like /test
+	// Handle sub-paths like /test, /test/run-fixtures, /selftest and /fixtures/{name}
 	if len(parts) > 1 && parts[1] == "test" {
-		if r.Method == "POST" {
-			s.handleAPIStrategyTest(w, r, strategyID)
+		if r.Method != "POST" {
+			writeAPIError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed", nil)
+		} else if len(parts) > 2 && parts[2] == "run-fixtures" {
+			s.handleAPIStrategyRunFixtures(w, r, strategyID)
 		} else {
+			s.handleAPIStrategyTest(w, r, strategyID)
+		}
+		return
+	}
+	if len(parts) > 1 && parts[1] == "selftest" {
+		if r.Method != "POST" {
 			writeAPIError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed", nil)
+			return
+		}
+		s.handleAPIStrategySelfTest(w, r, strategyID)
+		return
+	}
+	if len(parts) > 1 && parts[1] == "fixtures" {
+		fixtureName := ""
+		if len(parts) > 2 {
+			fixtureName = parts[2]
 		}
+		s.handleAPIStrategyFixtures(w, r, strategyID, fixtureName)
 		return
 	}
 
@@ -191,6 +284,12 @@ func (s *Server) handleAPIStrategyDetail(w http.ResponseWriter, r *http.Request)
 }
 
 func (s *Server) handleAPIStrategyGet(w http.ResponseWriter, r *http.Request, strategyID string) {
+	reveal, ok := s.revealParameters(r)
+	if !ok {
+		writeAPIError(w, http.StatusForbidden, "FORBIDDEN", "Revealing parameters requires the admin token", nil)
+		return
+	}
+
 	// Load from database (source of truth)
 	strat, err := s.stateManager.LoadStrategy(strategyID)
 	if err != nil {
@@ -199,17 +298,26 @@ func (s *Server) handleAPIStrategyGet(w http.ResponseWriter, r *http.Request, st
 	}
 
 	detail := StrategyDetail{
-		ID:                strat.ID,
-		Name:              strat.Name,
-		Description:       strat.Description,
-		Code:              strat.Code,
-		Language:          strat.Language,
-		Builtin:           strat.Builtin,
-		Parameters:        strat.Parameters,
-		MaxInputs:         strat.MaxInputs,
-		DefaultInputNames: strat.DefaultInputNames,
-		CreatedAt:         strat.CreatedAt,
-		UpdatedAt:         strat.UpdatedAt,
+		ID:                   strat.ID,
+		Name:                 strat.Name,
+		Description:          strat.Description,
+		Code:                 strat.Code,
+		Language:             strat.Language,
+		Builtin:              strat.Builtin,
+		Parameters:           strat.Parameters,
+		MaxInputs:            strat.MaxInputs,
+		DefaultInputNames:    strat.DefaultInputNames,
+		AllowedInputPatterns: strat.AllowedInputPatterns,
+		Library:              strat.Library,
+		LogLevel:             strat.LogLevel,
+		TimeoutMs:            strat.TimeoutMs,
+		FilePath:             strat.FilePath,
+		Circuits:             s.strategyEngine.CircuitStatuses(strat.ID),
+		CreatedAt:            strat.CreatedAt,
+		UpdatedAt:            strat.UpdatedAt,
+	}
+	if !reveal {
+		detail.Parameters = redactParameters(detail.Parameters)
 	}
 
 	writeAPIResponse(w, detail)
@@ -229,18 +337,41 @@ func (s *Server) handleAPIStrategyUpdate(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
+	logLevel, err := strategy.ParseLogLevel(req.LogLevel)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return
+	}
+	if req.TimeoutMs < 0 {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Strategy timeout must not be negative", nil)
+		return
+	}
+
+	// The code of file-backed strategies is edited on disk
+	if existingStrategy.FilePath != "" && req.Code != existingStrategy.Code {
+		writeAPIError(w, http.StatusConflict, "CONFLICT", "Strategy code is loaded from "+existingStrategy.FilePath+" and must be edited there", nil)
+		return
+	}
+
+	restoreRedactedParameters(req.Parameters, existingStrategy.Parameters)
+
 	// Update strategy fields
 	strat := &strategy.Strategy{
-		ID:                strategyID,
-		Name:              req.Name,
-		Description:       req.Description,
-		Code:              req.Code,
-		Language:          req.Language,
-		Parameters:        req.Parameters,
-		MaxInputs:         req.MaxInputs,
-		DefaultInputNames: req.DefaultInputNames,
-		CreatedAt:         existingStrategy.CreatedAt, // Keep original creation time
-		UpdatedAt:         time.Now(),
+		ID:                   strategyID,
+		Name:                 req.Name,
+		Description:          req.Description,
+		Code:                 req.Code,
+		Language:             req.Language,
+		Parameters:           req.Parameters,
+		MaxInputs:            req.MaxInputs,
+		DefaultInputNames:    req.DefaultInputNames,
+		AllowedInputPatterns: req.AllowedInputPatterns,
+		Library:              req.Library,
+		LogLevel:             logLevel,
+		TimeoutMs:            req.TimeoutMs,
+		FilePath:             existingStrategy.FilePath,
+		CreatedAt:            existingStrategy.CreatedAt, // Keep original creation time
+		UpdatedAt:            time.Now(),
 	}
 
 	// Set defaults
@@ -286,14 +417,20 @@ func (s *Server) handleAPIStrategyDelete(w http.ResponseWriter, r *http.Request,
 type StrategyTestRequest struct {
 	Inputs     map[string]interface{} `json:"inputs"`
 	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	// Trace returns a profile of the execution with the result
+	Trace bool `json:"trace,omitempty"`
+	// Now fixes the time returned by getTime/getISO for a deterministic run
+	Now *time.Time `json:"now,omitempty"`
 }
 
 type StrategyTestResponse struct {
-	Result          interface{}          `json:"result"`
-	LogMessages     []string             `json:"log_messages"`
-	EmittedEvents   []strategy.EmitEvent `json:"emitted_events"`
-	ExecutionTimeMS int64                `json:"execution_time_ms"`
-	Error           string               `json:"error,omitempty"`
+	Result          interface{}              `json:"result"`
+	LogMessages     []strategy.LogMessage    `json:"log_messages"`
+	EmittedEvents   []strategy.EmitEvent     `json:"emitted_events"`
+	ExecutionTimeMS int64                    `json:"execution_time_ms"`
+	Error           string                   `json:"error,omitempty"`
+	Stack           string                   `json:"stack,omitempty"` // JavaScript stack of a thrown error
+	Trace           *strategy.ExecutionTrace `json:"trace,omitempty"`
 }
 
 func (s *Server) handleAPIStrategyTest(w http.ResponseWriter, r *http.Request, strategyID string) {
@@ -314,14 +451,21 @@ func (s *Server) handleAPIStrategyTest(w http.ResponseWriter, r *http.Request, s
 	_ = strat.Parameters // Using the strategy's default parameters
 
 	// Execute strategy (use request parameters if provided, otherwise use strategy defaults)
-	events, err := s.strategyEngine.ExecuteStrategy(strategyID, req.Inputs, nil, "test", nil, req.Parameters)
+	options := strategy.ExecuteOptions{Trace: req.Trace}
+	if req.Now != nil {
+		options.Now = *req.Now
+	}
+	events, logMessages, trace, err := s.strategyEngine.ExecuteStrategyWithOptions(strategyID, req.Inputs, nil, "test", nil, req.Parameters, options)
 
 	response := StrategyTestResponse{
+		LogMessages:   logMessages,
 		EmittedEvents: events,
+		Trace:         trace,
 	}
 
 	if err != nil {
 		response.Error = err.Error()
+		response.Stack = strategy.StackTrace(err)
 		writeAPIError(w, http.StatusBadRequest, "STRATEGY_EXECUTION_ERROR", "Strategy execution failed", response)
 		return
 	}
@@ -5,8 +5,11 @@ import (
 	"net/http"
 	"os"
 	"runtime"
+	"strconv"
 	"time"
 
+	"github.com/denwilliams/go-mqtt-automation/pkg/mqtt"
+	"github.com/denwilliams/go-mqtt-automation/pkg/state"
 	"github.com/denwilliams/go-mqtt-automation/pkg/topics"
 )
 
@@ -24,18 +27,44 @@ func (s *Server) handleAPISystemInfo(w http.ResponseWriter, r *http.Request) {
 		mqttConnected = s.mqttClient.IsConnected()
 	}
 
+	schema, err := s.stateManager.SchemaStatus()
+	if err != nil {
+		s.logger.Printf("Failed to read schema version: %v", err)
+	}
+
 	response := SystemInfoResponse{
-		Version:       "1.0.0",   // TODO: Get from build info
-		Uptime:        "0m",      // TODO: Calculate actual uptime
-		BuildDate:     "unknown", // TODO: Get from build info
-		GoVersion:     runtime.Version(),
-		DatabaseType:  "sqlite", // TODO: Get from config
-		MQTTConnected: mqttConnected,
+		Version:               "1.0.0",   // TODO: Get from build info
+		Uptime:                "0m",      // TODO: Calculate actual uptime
+		BuildDate:             "unknown", // TODO: Get from build info
+		GoVersion:             runtime.Version(),
+		DatabaseType:          "sqlite", // TODO: Get from config
+		SchemaVersion:         schema.Current,
+		SchemaDirty:           schema.Dirty,
+		ExpectedSchemaVersion: schema.Expected,
+		SchemaDrift:           schema.Drift(),
+		MQTTConnected:         mqttConnected,
 	}
 
 	writeAPIResponse(w, response)
 }
 
+func (s *Server) handleAPIMQTTStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeAPIError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed", nil)
+		return
+	}
+
+	if s.mqttClient == nil {
+		writeAPIResponse(w, mqtt.Status{
+			Broker: s.config.MQTT.Broker,
+			State:  mqtt.ConnectionStateClosed.String(),
+		})
+		return
+	}
+
+	writeAPIResponse(w, s.mqttClient.Status())
+}
+
 // System stats structures
 type SystemStatsResponse struct {
 	Topics     TopicStatsDetail    `json:"topics"`
@@ -125,28 +154,123 @@ func (s *Server) handleAPISystemActivity(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// For now, return empty activities
-	// TODO: Implement actual activity logging and retrieval
 	response := ActivityResponse{
-		Activities: []ActivityItem{
-			{
-				Timestamp: time.Now().Add(-5 * time.Minute),
-				Type:      "system_start",
-				Message:   "System started successfully",
-				Level:     "info",
-			},
-			{
-				Timestamp: time.Now().Add(-2 * time.Minute),
-				Type:      "mqtt_connect",
-				Message:   "Connected to MQTT broker",
-				Level:     "info",
-			},
-		},
+		Activities: s.recentActivity(activityLimit),
 	}
 
 	writeAPIResponse(w, response)
 }
 
+// activityLimit is how many recent system events the activity views show
+const activityLimit = 50
+
+// recentActivity returns the latest system events, newest first
+func (s *Server) recentActivity(limit int) []ActivityItem {
+	events, err := s.stateManager.LoadSystemEvents(state.SystemEventQuery{Limit: limit})
+	if err != nil {
+		s.logger.Printf("Failed to load system events: %v", err)
+	}
+
+	activities := make([]ActivityItem, 0, len(events))
+	for _, event := range events {
+		activities = append(activities, activityFromEvent(event))
+	}
+	return activities
+}
+
+// activityFromEvent describes a system event for the activity views
+func activityFromEvent(event state.SystemEvent) ActivityItem {
+	item := ActivityItem{
+		Timestamp: event.OccurredAt,
+		Type:      event.Type,
+		Message:   event.Type,
+		Level:     "info",
+	}
+
+	data, _ := event.Data.(map[string]interface{})
+	switch event.Type {
+	case "startup":
+		item.Message = "System started"
+		if version, ok := data["version"].(string); ok && version != "" {
+			item.Message = fmt.Sprintf("System started (version %s)", version)
+		}
+	case "shutdown":
+		item.Message = "System shutting down"
+	case "mqtt_connected":
+		item.Message = "Connected to MQTT broker"
+	case "mqtt_disconnected":
+		item.Message = "Lost connection to MQTT broker"
+		item.Level = "warning"
+	case "error":
+		item.Message = "System error"
+		item.Level = "error"
+	}
+	if message, ok := data["error"].(string); ok && message != "" {
+		item.Message = fmt.Sprintf("%s: %s", item.Message, message)
+	}
+	return item
+}
+
+// SystemEventsResponse is the response of GET /api/v1/system/events
+type SystemEventsResponse struct {
+	Events []state.SystemEvent `json:"events"`
+	Count  int                 `json:"count"`
+}
+
+// handleAPISystemEvents returns the system event log, newest first, filtered
+// by ?type=, ?from= and ?to= (RFC3339) and capped by ?limit= (default 100)
+func (s *Server) handleAPISystemEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeAPIError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed", nil)
+		return
+	}
+
+	query := state.SystemEventQuery{
+		Type:  r.URL.Query().Get("type"),
+		Limit: 100,
+	}
+	if v := r.URL.Query().Get("from"); v != "" {
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid 'from' time, expected RFC3339", nil)
+			return
+		}
+		query.From = parsed
+	}
+	if v := r.URL.Query().Get("to"); v != "" {
+		parsed, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid 'to' time, expected RFC3339", nil)
+			return
+		}
+		query.To = parsed
+	}
+	if !query.From.IsZero() && !query.To.IsZero() && query.From.After(query.To) {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", "'from' must not be after 'to'", nil)
+		return
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Invalid 'limit', expected a positive integer", nil)
+			return
+		}
+		query.Limit = limit
+	}
+
+	events, err := s.stateManager.LoadSystemEvents(query)
+	if err != nil {
+		s.logger.Printf("Failed to load system events: %v", err)
+		writeAPIError(w, http.StatusInternalServerError, "DATABASE_ERROR", "Failed to load system events", nil)
+		return
+	}
+	if events == nil {
+		events = []state.SystemEvent{}
+	}
+
+	writeAPIResponse(w, SystemEventsResponse{Events: events, Count: len(events)})
+}
+
 // Combined system endpoint structure
 type CombinedSystemResponse struct {
 	Info interface{}    `json:"info"`
@@ -157,7 +281,7 @@ type CombinedSystemResponse struct {
 func (s *Server) handleAPISystem(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "OPTIONS" {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 		w.WriteHeader(http.StatusOK)
 		return
@@ -183,6 +307,11 @@ func (s *Server) handleAPISystem(w http.ResponseWriter, r *http.Request) {
 	// Get current PID
 	pid := os.Getpid()
 
+	schema, err := s.stateManager.SchemaStatus()
+	if err != nil {
+		s.logger.Printf("Failed to read schema version: %v", err)
+	}
+
 	// Create extended system info that matches React component interface
 	extendedInfo := map[string]interface{}{
 		"system": map[string]interface{}{
@@ -194,10 +323,14 @@ func (s *Server) handleAPISystem(w http.ResponseWriter, r *http.Request) {
 			"goroutines":   runtime.NumGoroutine(),
 		},
 		"database": map[string]interface{}{
-			"type":             s.getDatabaseType(),
-			"status":           "connected",
-			"total_topics":     topicCounts[topics.TopicTypeExternal] + topicCounts[topics.TopicTypeInternal] + topicCounts[topics.TopicTypeSystem],
-			"total_strategies": len(allStrategies),
+			"type":                    s.getDatabaseType(),
+			"status":                  "connected",
+			"total_topics":            topicCounts[topics.TopicTypeExternal] + topicCounts[topics.TopicTypeInternal] + topicCounts[topics.TopicTypeSystem],
+			"total_strategies":        len(allStrategies),
+			"schema_version":          schema.Current,
+			"schema_dirty":            schema.Dirty,
+			"expected_schema_version": schema.Expected,
+			"schema_drift":            schema.Drift(),
 		},
 		"mqtt": map[string]interface{}{
 			"broker_url":         s.getMQTTBrokerURL(),
@@ -216,31 +349,9 @@ func (s *Server) handleAPISystem(w http.ResponseWriter, r *http.Request) {
 		},
 	}
 
-	// Mock logs for now
-	logs := []ActivityItem{
-		{
-			Timestamp: time.Now().Add(-5 * time.Minute),
-			Type:      "info",
-			Message:   "System started successfully",
-			Level:     "info",
-		},
-		{
-			Timestamp: time.Now().Add(-2 * time.Minute),
-			Type:      "info",
-			Message:   "Connected to MQTT broker",
-			Level:     "info",
-		},
-		{
-			Timestamp: time.Now().Add(-1 * time.Minute),
-			Type:      "info",
-			Message:   "All strategies loaded successfully",
-			Level:     "info",
-		},
-	}
-
 	response := CombinedSystemResponse{
 		Info: extendedInfo,
-		Logs: logs,
+		Logs: s.recentActivity(activityLimit),
 	}
 
 	writeAPIResponse(w, response)
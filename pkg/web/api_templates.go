@@ -0,0 +1,211 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/config"
+)
+
+// DeviceTemplateSummary describes a device template. Names still contain
+// the {device} placeholder.
+type DeviceTemplateSummary struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Strategies  []string `json:"strategies"`
+	Topics      []string `json:"topics"`
+}
+
+// TemplateInstantiateResponse lists what instantiating a device template created
+type TemplateInstantiateResponse struct {
+	Template         string   `json:"template"`
+	Device           string   `json:"device"`
+	Strategies       []string `json:"strategies"`                  // strategies created
+	ReusedStrategies []string `json:"reused_strategies,omitempty"` // strategies that already existed
+	Topics           []string `json:"topics"`
+}
+
+// handleAPITemplates lists the configured device templates
+func (s *Server) handleAPITemplates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeAPIError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed", nil)
+		return
+	}
+
+	summaries := make([]DeviceTemplateSummary, 0, len(s.config.DeviceTemplates))
+	for name, template := range s.config.DeviceTemplates {
+		summary := DeviceTemplateSummary{
+			Name:        name,
+			Description: template.Description,
+			Strategies:  make([]string, 0, len(template.Strategies)),
+			Topics:      make([]string, 0, len(template.Topics)),
+		}
+		for _, entry := range template.Strategies {
+			id, _ := entry["id"].(string)
+			summary.Strategies = append(summary.Strategies, id)
+		}
+		for _, entry := range template.Topics {
+			topicName, _ := entry["name"].(string)
+			summary.Topics = append(summary.Topics, topicName)
+		}
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool { return summaries[i].Name < summaries[j].Name })
+
+	writeAPIResponse(w, summaries)
+}
+
+// handleAPITemplateDetail handles POST /api/v1/templates/{name}/instantiate
+func (s *Server) handleAPITemplateDetail(w http.ResponseWriter, r *http.Request) {
+	// Handle CORS preflight requests
+	if r.Method == "OPTIONS" {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/templates/"), "/")
+	name, action, _ := strings.Cut(path, "/")
+	if action != "instantiate" {
+		writeAPIError(w, http.StatusNotFound, "NOT_FOUND", "Endpoint not found", nil)
+		return
+	}
+	if r.Method != "POST" {
+		writeAPIError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed", nil)
+		return
+	}
+
+	template, ok := s.config.DeviceTemplates[name]
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, "NOT_FOUND", fmt.Sprintf("Device template %s not found", name), nil)
+		return
+	}
+	device := r.URL.Query().Get("device")
+	if err := validateDeviceName(device); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", err.Error(), nil)
+		return
+	}
+
+	strategyRequests := make([]StrategyCreateRequest, len(template.Strategies))
+	for i, entry := range template.Strategies {
+		if err := expandDeviceEntry(entry, device, &strategyRequests[i]); err != nil {
+			writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("Device template %s strategy %d: %v", name, i+1, err), nil)
+			return
+		}
+	}
+	topicRequests := make([]TopicCreateRequest, len(template.Topics))
+	for i, entry := range template.Topics {
+		if err := expandDeviceEntry(entry, device, &topicRequests[i]); err != nil {
+			writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("Device template %s topic %d: %v", name, i+1, err), nil)
+			return
+		}
+		if topicRequests[i].Type == "" {
+			topicRequests[i].Type = "internal"
+		}
+		if s.topicManager.GetTopic(topicRequests[i].Name) != nil {
+			writeAPIError(w, http.StatusConflict, "CONFLICT", fmt.Sprintf("Topic %s already exists", topicRequests[i].Name), nil)
+			return
+		}
+	}
+
+	// Strategies shared by every device of the type are created once
+	response := TemplateInstantiateResponse{
+		Template:   name,
+		Device:     device,
+		Strategies: []string{},
+		Topics:     []string{},
+	}
+	for _, req := range strategyRequests {
+		if _, err := s.strategyEngine.GetStrategy(req.ID); err == nil {
+			response.ReusedStrategies = append(response.ReusedStrategies, req.ID)
+			continue
+		}
+		if !s.createStrategy(w, req) {
+			s.removeInstantiated(response)
+			return
+		}
+		response.Strategies = append(response.Strategies, req.ID)
+	}
+	for _, req := range topicRequests {
+		if !s.createTopic(w, req) {
+			s.removeInstantiated(response)
+			return
+		}
+		response.Topics = append(response.Topics, req.Name)
+	}
+
+	s.logger.Printf("Instantiated device template %s for %s: %d strategies, %d topics", name, device, len(response.Strategies), len(response.Topics))
+	w.WriteHeader(http.StatusCreated)
+	writeAPIResponse(w, response)
+}
+
+// removeInstantiated deletes what a failed instantiation created, so a
+// template is created completely or not at all
+func (s *Server) removeInstantiated(created TemplateInstantiateResponse) {
+	for _, topicName := range created.Topics {
+		if err := s.stateManager.DeleteTopicConfig(topicName); err != nil {
+			s.logger.Printf("Failed to delete topic %s from database: %v", topicName, err)
+		}
+		if err := s.topicManager.RemoveTopic(topicName); err != nil {
+			s.logger.Printf("Failed to remove topic %s from memory: %v", topicName, err)
+		}
+	}
+	if len(created.Topics) > 0 {
+		s.invalidateTopicList()
+	}
+	for _, strategyID := range created.Strategies {
+		if err := s.stateManager.DeleteStrategy(strategyID); err != nil {
+			s.logger.Printf("Failed to delete strategy %s from database: %v", strategyID, err)
+		}
+		if err := s.strategyEngine.RemoveStrategy(strategyID); err != nil {
+			s.logger.Printf("Failed to remove strategy %s from memory: %v", strategyID, err)
+		}
+	}
+}
+
+// validateDeviceName checks that a device name can be used within topic names
+func validateDeviceName(device string) error {
+	if device == "" {
+		return fmt.Errorf("device is required")
+	}
+	if strings.ContainsAny(device, "/+#{}") {
+		return fmt.Errorf("invalid device %q: must not contain /, +, #, { or }", device)
+	}
+	return nil
+}
+
+// expandDeviceEntry replaces {device} in the strings (and map keys) of a
+// template entry and decodes the result into out
+func expandDeviceEntry(entry map[string]interface{}, device string, out interface{}) error {
+	data, err := json.Marshal(expandDeviceValue(entry, device))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+func expandDeviceValue(value interface{}, device string) interface{} {
+	switch v := value.(type) {
+	case string:
+		return strings.ReplaceAll(v, config.DevicePlaceholder, device)
+	case map[string]interface{}:
+		expanded := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			expanded[strings.ReplaceAll(key, config.DevicePlaceholder, device)] = expandDeviceValue(item, device)
+		}
+		return expanded
+	case []interface{}:
+		expanded := make([]interface{}, len(v))
+		for i, item := range v {
+			expanded[i] = expandDeviceValue(item, device)
+		}
+		return expanded
+	default:
+		return value
+	}
+}
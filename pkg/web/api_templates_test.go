@@ -0,0 +1,136 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/config"
+)
+
+func thermostatTemplates() map[string]config.DeviceTemplate {
+	return map[string]config.DeviceTemplate{
+		"thermostat": {
+			Description: "Zigbee thermostat",
+			Strategies: []map[string]interface{}{
+				{
+					"id":   "thermostat-demand",
+					"name": "Thermostat demand",
+					"code": `function process(context) { return context.input("temp") < context.parameters.setpoint ? "heat" : "idle"; }`,
+				},
+			},
+			Topics: []map[string]interface{}{
+				{
+					"name":        "thermostats/{device}/demand",
+					"inputs":      []interface{}{"zigbee/{device}/temperature"},
+					"input_names": map[string]interface{}{"zigbee/{device}/temperature": "temp"},
+					"strategy_id": "thermostat-demand",
+					"parameters":  map[string]interface{}{"setpoint": 20.5, "room": "{device}"},
+				},
+			},
+		},
+	}
+}
+
+func TestHandleAPITemplateInstantiate(t *testing.T) {
+	server := newTestServer(t, &config.Config{DeviceTemplates: thermostatTemplates()})
+
+	for i, device := range []string{"hall", "bedroom"} {
+		rec := doRequest(t, server.handleAPITemplateDetail, "POST", "/api/v1/templates/thermostat/instantiate?device="+device, "")
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("instantiate %s: status = %d: %s", device, rec.Code, rec.Body.String())
+		}
+		var response struct {
+			Data TemplateInstantiateResponse `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(response.Data.Topics) != 1 || response.Data.Topics[0] != "thermostats/"+device+"/demand" {
+			t.Errorf("instantiate %s: topics = %v", device, response.Data.Topics)
+		}
+		// The shared strategy is created for the first device and reused after
+		if created := len(response.Data.Strategies); created != 1-i || len(response.Data.ReusedStrategies) != i {
+			t.Errorf("instantiate %s: created %v, reused %v", device, response.Data.Strategies, response.Data.ReusedStrategies)
+		}
+	}
+
+	for _, device := range []string{"hall", "bedroom"} {
+		topic := server.topicManager.GetInternalTopic("thermostats/" + device + "/demand")
+		if topic == nil {
+			t.Fatalf("topic for %s was not created", device)
+		}
+		cfg := topic.GetConfig()
+		input := "zigbee/" + device + "/temperature"
+		if len(cfg.Inputs) != 1 || cfg.Inputs[0] != input || cfg.InputNames[input] != "temp" {
+			t.Errorf("%s inputs = %v, names = %v", device, cfg.Inputs, cfg.InputNames)
+		}
+		if cfg.Parameters["room"] != device {
+			t.Errorf("%s parameters = %v", device, cfg.Parameters)
+		}
+		if _, err := server.stateManager.LoadTopicConfig(topic.Name()); err != nil {
+			t.Errorf("topic for %s was not saved: %v", device, err)
+		}
+	}
+
+	// Each device's topic only reacts to its own sensor
+	sensor, err := server.topicManager.AddExternalTopic("zigbee/hall/temperature")
+	if err != nil {
+		t.Fatalf("AddExternalTopic failed: %v", err)
+	}
+	if err := sensor.Emit(18.5); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	if value := server.topicManager.GetInternalTopic("thermostats/hall/demand").LastValue(); value != "heat" {
+		t.Errorf("hall demand = %v, want heat", value)
+	}
+	if value := server.topicManager.GetInternalTopic("thermostats/bedroom/demand").LastValue(); value != nil {
+		t.Errorf("bedroom demand = %v, want unset", value)
+	}
+}
+
+func TestHandleAPITemplateInstantiateErrors(t *testing.T) {
+	templates := thermostatTemplates()
+	templates["broken"] = config.DeviceTemplate{
+		Strategies: []map[string]interface{}{
+			{"id": "{device}-broken", "name": "Broken", "code": `function process(context) { return 1.5; }`},
+		},
+		Topics: []map[string]interface{}{
+			{"name": "broken/{device}", "strategy_id": "{device}-broken", "null_policy": "invalid"},
+		},
+	}
+	server := newTestServer(t, &config.Config{DeviceTemplates: templates})
+
+	if rec := doRequest(t, server.handleAPITemplateDetail, "POST", "/api/v1/templates/thermostat/instantiate?device=hall", ""); rec.Code != http.StatusCreated {
+		t.Fatalf("instantiate: status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		wantCode int
+	}{
+		{name: "unknown template", path: "/api/v1/templates/boiler/instantiate?device=hall", wantCode: http.StatusNotFound},
+		{name: "missing device", path: "/api/v1/templates/thermostat/instantiate", wantCode: http.StatusBadRequest},
+		{name: "device with wildcard", path: "/api/v1/templates/thermostat/instantiate?device=%2B", wantCode: http.StatusBadRequest},
+		{name: "existing device", path: "/api/v1/templates/thermostat/instantiate?device=hall", wantCode: http.StatusConflict},
+		{name: "invalid topic", path: "/api/v1/templates/broken/instantiate?device=hall", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doRequest(t, server.handleAPITemplateDetail, "POST", tt.path, "")
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body.String())
+			}
+		})
+	}
+
+	// A failed instantiation removes the strategies it created
+	if _, err := server.strategyEngine.GetStrategy("hall-broken"); err == nil {
+		t.Error("strategy of the failed instantiation was kept")
+	}
+	if _, err := server.stateManager.LoadStrategy("hall-broken"); err == nil {
+		t.Error("strategy of the failed instantiation was kept in the database")
+	}
+}
@@ -0,0 +1,1419 @@
+package web
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/config"
+	"github.com/denwilliams/go-mqtt-automation/pkg/mqtt"
+	"github.com/denwilliams/go-mqtt-automation/pkg/state"
+	"github.com/denwilliams/go-mqtt-automation/pkg/strategy"
+	"github.com/denwilliams/go-mqtt-automation/pkg/topics"
+)
+
+func init() {
+	// Tests run from the package directory
+	state.MigrationsDir = "../../db/migrations"
+}
+
+// newTestServer creates a server backed by a temporary SQLite database
+func newTestServer(t testing.TB, cfg *config.Config) *Server {
+	t.Helper()
+
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+	cfg.Database.Type = "sqlite"
+	cfg.Database.Connection = filepath.Join(t.TempDir(), "test.db")
+
+	stateManager, err := state.NewManager(cfg.Database, nil)
+	if err != nil {
+		t.Fatalf("Failed to create state manager: %v", err)
+	}
+	t.Cleanup(func() { stateManager.Close() })
+
+	topicManager := topics.NewManager(nil)
+	strategyEngine := strategy.NewEngine(nil)
+	topicManager.SetStrategyExecutor(strategyEngine)
+	topicManager.SetStateManager(stateManager)
+	topicManager.SetExecutionRecorder(stateManager)
+
+	server, err := NewServer(cfg, topicManager, strategyEngine, stateManager, nil, nil)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	return server
+}
+
+func doRequest(t testing.TB, handler http.HandlerFunc, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func TestHandleAPITopicsCreateDefaultEmitToMQTT(t *testing.T) {
+	tests := []struct {
+		name        string
+		defaultEmit bool
+		body        string
+		wantEmit    bool
+	}{
+		{
+			name:        "omitted uses default true",
+			defaultEmit: true,
+			body:        `{"name":"test/topic","type":"internal","strategy_id":"alias"}`,
+			wantEmit:    true,
+		},
+		{
+			name:        "explicit false overrides default true",
+			defaultEmit: true,
+			body:        `{"name":"test/topic","type":"internal","strategy_id":"alias","emit_to_mqtt":false}`,
+			wantEmit:    false,
+		},
+		{
+			name:        "omitted uses default false",
+			defaultEmit: false,
+			body:        `{"name":"test/topic","type":"internal","strategy_id":"alias"}`,
+			wantEmit:    false,
+		},
+		{
+			name:        "explicit true overrides default false",
+			defaultEmit: false,
+			body:        `{"name":"test/topic","type":"internal","strategy_id":"alias","emit_to_mqtt":true}`,
+			wantEmit:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t, &config.Config{Web: config.WebConfig{DefaultEmitToMQTT: tt.defaultEmit}})
+
+			rec := doRequest(t, server.handleAPITopicsCreate, "POST", "/api/v1/topics", tt.body)
+			if rec.Code != http.StatusCreated {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body.String())
+			}
+
+			topic := server.topicManager.GetInternalTopic("test/topic")
+			if topic == nil {
+				t.Fatal("topic was not created in memory")
+			}
+			if topic.ShouldEmitToMQTT() != tt.wantEmit {
+				t.Errorf("in-memory EmitToMQTT = %v, want %v", topic.ShouldEmitToMQTT(), tt.wantEmit)
+			}
+
+			saved, err := server.stateManager.LoadTopicConfig("test/topic")
+			if err != nil {
+				t.Fatalf("LoadTopicConfig failed: %v", err)
+			}
+			if cfg, ok := saved.(topics.InternalTopicConfig); !ok || cfg.EmitToMQTT != tt.wantEmit {
+				t.Errorf("saved config = %+v, want EmitToMQTT %v", saved, tt.wantEmit)
+			}
+		})
+	}
+}
+
+func TestHandleAPISystemEvents(t *testing.T) {
+	server := newTestServer(t, nil)
+	server.topicManager.SetSystemEventRecorder(server.stateManager)
+
+	for _, eventType := range []string{"startup", "mqtt_connected", "error", "heartbeat"} {
+		topic := server.topicManager.AddSystemTopic("system/events/"+eventType, map[string]interface{}{})
+		if err := topic.EmitSystemEvent(eventType, map[string]interface{}{"error": eventType + " detail"}); err != nil {
+			t.Fatalf("EmitSystemEvent(%s) failed: %v", eventType, err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantTypes  []string
+	}{
+		{name: "all events newest first", path: "/api/v1/system/events", wantStatus: http.StatusOK, wantTypes: []string{"error", "mqtt_connected", "startup"}},
+		{name: "type filter", path: "/api/v1/system/events?type=mqtt_connected", wantStatus: http.StatusOK, wantTypes: []string{"mqtt_connected"}},
+		{name: "heartbeats are not recorded", path: "/api/v1/system/events?type=heartbeat", wantStatus: http.StatusOK, wantTypes: []string{}},
+		{name: "time filter", path: "/api/v1/system/events?to=2000-01-01T00:00:00Z", wantStatus: http.StatusOK, wantTypes: []string{}},
+		{name: "limit", path: "/api/v1/system/events?limit=1", wantStatus: http.StatusOK, wantTypes: []string{"error"}},
+		{name: "invalid from", path: "/api/v1/system/events?from=yesterday", wantStatus: http.StatusBadRequest},
+		{name: "invalid limit", path: "/api/v1/system/events?limit=0", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doRequest(t, server.handleAPISystemEvents, "GET", tt.path, "")
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Data SystemEventsResponse `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			types := []string{}
+			for _, event := range response.Data.Events {
+				types = append(types, event.Type)
+			}
+			if !reflect.DeepEqual(types, tt.wantTypes) {
+				t.Errorf("event types = %v, want %v", types, tt.wantTypes)
+			}
+		})
+	}
+
+	rec := doRequest(t, server.handleAPISystemActivity, "GET", "/api/v1/system/activity", "")
+	var activity struct {
+		Data ActivityResponse `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &activity); err != nil {
+		t.Fatalf("Failed to decode activity: %v", err)
+	}
+	if len(activity.Data.Activities) != 3 {
+		t.Fatalf("got %d activities, want 3", len(activity.Data.Activities))
+	}
+	if got := activity.Data.Activities[0]; got.Type != "error" || got.Level != "error" || got.Message != "System error: error detail" {
+		t.Errorf("latest activity = %+v, want the recorded error", got)
+	}
+}
+
+func TestHandleAPISystemInfoSchemaVersion(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	rec := doRequest(t, server.handleAPISystemInfo, "GET", "/api/v1/system/info", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	var response struct {
+		Data SystemInfoResponse `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if response.Data.SchemaVersion == 0 {
+		t.Error("schema_version should report the applied migration version")
+	}
+	if response.Data.SchemaDirty {
+		t.Error("schema_dirty should be false after a clean migration")
+	}
+}
+
+func TestHandleAPITopicDetailBinaryPayload(t *testing.T) {
+	server := newTestServer(t, nil)
+	payload := []byte{0xff, 0x00, 0xfe, 0x80, '{', '"'}
+
+	name := "cameras/front/snapshot"
+	if err := server.stateManager.SaveTopicConfig(topics.BaseTopicConfig{
+		Name:      name,
+		Type:      topics.TopicTypeExternal,
+		CreatedAt: time.Now(),
+		Config:    map[string]interface{}{"binary": true},
+	}); err != nil {
+		t.Fatalf("SaveTopicConfig failed: %v", err)
+	}
+	if err := server.topicManager.ReloadTopicFromDatabase(name); err != nil {
+		t.Fatalf("ReloadTopicFromDatabase failed: %v", err)
+	}
+
+	if err := server.topicManager.HandleMQTTMessage(mqtt.Event{Topic: name, Payload: payload}); err != nil {
+		t.Fatalf("HandleMQTTMessage failed: %v", err)
+	}
+
+	// The stored value decodes back to the original bytes
+	stored, err := server.stateManager.LoadTopicConfig(name)
+	if err != nil {
+		t.Fatalf("LoadTopicConfig failed: %v", err)
+	}
+	storedConfig, ok := stored.(topics.BaseTopicConfig)
+	if !ok {
+		t.Fatalf("stored config = %T, want topics.BaseTopicConfig", stored)
+	}
+	assertBase64Payload(t, "stored value", storedConfig.LastValue, payload)
+
+	rec := doRequest(t, server.handleAPITopicDetail, "GET", "/api/v1/topics/"+name, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	var response struct {
+		Data TopicDetail `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !response.Data.Binary {
+		t.Error("binary should be reported for a binary topic")
+	}
+	assertBase64Payload(t, "API last_value", response.Data.LastValue, payload)
+}
+
+func assertBase64Payload(t *testing.T, name string, value interface{}, want []byte) {
+	t.Helper()
+
+	encoded, ok := value.(string)
+	if !ok {
+		t.Fatalf("%s = %T, want a base64 string", name, value)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("%s is not valid base64: %v", name, err)
+	}
+	if !bytes.Equal(decoded, want) {
+		t.Errorf("%s decodes to %v, want %v", name, decoded, want)
+	}
+}
+
+func TestHandleAPIStrategiesListUsage(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	rec := doRequest(t, server.handleAPITopicsCreate, "POST", "/api/v1/topics",
+		`{"name":"test/topic","type":"internal","strategy_id":"alias"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create topic status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	listStrategies := func(t *testing.T, query string) []StrategySummary {
+		t.Helper()
+		rec := doRequest(t, server.handleAPIStrategiesList, "GET", "/api/v1/strategies"+query, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+		}
+		var resp struct {
+			Data StrategyListResponse `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return resp.Data.Strategies
+	}
+
+	t.Run("unused strategies report zero usage", func(t *testing.T) {
+		strategies := listStrategies(t, "")
+		if len(strategies) < 2 {
+			t.Fatalf("expected seeded strategies, got %d", len(strategies))
+		}
+		for _, s := range strategies {
+			want := 0
+			if s.ID == "alias" {
+				want = 1
+			}
+			if s.UsageCount != want {
+				t.Errorf("%s usage_count = %d, want %d", s.ID, s.UsageCount, want)
+			}
+			if s.LastExecutedAt != nil {
+				t.Errorf("%s last_executed_at = %v, want nil", s.ID, s.LastExecutedAt)
+			}
+		}
+	})
+
+	t.Run("sort by usage count", func(t *testing.T) {
+		desc := listStrategies(t, "?sort=usage_count&order=desc")
+		if desc[0].ID != "alias" {
+			t.Errorf("first strategy sorted desc = %s, want alias", desc[0].ID)
+		}
+
+		asc := listStrategies(t, "?sort=usage_count&order=asc")
+		if asc[len(asc)-1].ID != "alias" {
+			t.Errorf("last strategy sorted asc = %s, want alias", asc[len(asc)-1].ID)
+		}
+	})
+
+	t.Run("invalid sort is rejected", func(t *testing.T) {
+		rec := doRequest(t, server.handleAPIStrategiesList, "GET", "/api/v1/strategies?sort=bogus", "")
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+		}
+	})
+}
+
+func TestHandleAPITopicParametersPatch(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	rec := doRequest(t, server.handleAPIStrategiesCreate, "POST", "/api/v1/strategies", `{
+		"id": "offset",
+		"name": "Offset",
+		"code": "function process(context) { return context.inputs['sensors/temp'] + context.parameters.offset; }"
+	}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create strategy status = %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(t, server.handleAPITopicsCreate, "POST", "/api/v1/topics",
+		`{"name":"house/temp","type":"internal","strategy_id":"offset","inputs":["sensors/temp"],"parameters":{"offset":0.5,"unit":"c"}}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create topic status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	sensor, err := server.topicManager.AddExternalTopic("sensors/temp")
+	if err != nil {
+		t.Fatalf("AddExternalTopic failed: %v", err)
+	}
+	topic := server.topicManager.GetInternalTopic("house/temp")
+	if err := sensor.Emit(20.25); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	if got := topic.LastValue(); got != 20.75 {
+		t.Fatalf("value before patch = %v, want 20.75", got)
+	}
+
+	rec = doRequest(t, server.handleAPITopicDetail, "PATCH", "/api/v1/topics/house/temp/parameters", `{"offset":1.5,"unit":null}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("patch status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Data TopicParametersResponse `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	want := map[string]interface{}{"offset": 1.5}
+	if !reflect.DeepEqual(resp.Data.Parameters, want) {
+		t.Errorf("response parameters = %v, want %v", resp.Data.Parameters, want)
+	}
+
+	// The same in-memory topic uses the new parameters on its next execution
+	if server.topicManager.GetInternalTopic("house/temp") != topic {
+		t.Fatal("topic was reloaded")
+	}
+	if err := sensor.Emit(20.75); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	if got := topic.LastValue(); got != 22.25 {
+		t.Errorf("value after patch = %v, want 22.25", got)
+	}
+
+	stored, err := server.stateManager.LoadTopicConfig("house/temp")
+	if err != nil {
+		t.Fatalf("LoadTopicConfig failed: %v", err)
+	}
+	if params := stored.(topics.InternalTopicConfig).Parameters; !reflect.DeepEqual(params, want) {
+		t.Errorf("persisted parameters = %v, want %v", params, want)
+	}
+
+	for name, tc := range map[string]struct {
+		path, body string
+		status     int
+	}{
+		"unknown topic": {"/api/v1/topics/house/missing/parameters", `{"offset":1}`, http.StatusNotFound},
+		"not an object": {"/api/v1/topics/house/temp/parameters", `[1]`, http.StatusBadRequest},
+		"null body":     {"/api/v1/topics/house/temp/parameters", `null`, http.StatusBadRequest},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rec := doRequest(t, server.handleAPITopicDetail, "PATCH", tc.path, tc.body)
+			if rec.Code != tc.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tc.status, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleAPITopicLogsLevels(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	rec := doRequest(t, server.handleAPIStrategiesCreate, "POST", "/api/v1/strategies", `{
+		"id": "chatty",
+		"name": "Chatty",
+		"log_level": "info",
+		"code": "function process(context) { context.debug('noise'); context.info('computing'); context.warn('running hot'); return 1; }"
+	}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create strategy status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doRequest(t, server.handleAPITopicsCreate, "POST", "/api/v1/topics",
+		`{"name":"test/chatty","type":"internal","strategy_id":"chatty","inputs":["sensors/temp"]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create topic status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	sensor, err := server.topicManager.AddExternalTopic("sensors/temp")
+	if err != nil {
+		t.Fatalf("AddExternalTopic failed: %v", err)
+	}
+	if err := sensor.Emit(21.5); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+
+	getLogs := func(t *testing.T, query string) []ExecutionLogEntry {
+		t.Helper()
+		rec := doRequest(t, server.handleAPITopicDetail, "GET", "/api/v1/topics/test/chatty/logs"+query, "")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+		}
+		var resp struct {
+			Data TopicLogsResponse `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if len(resp.Data.Logs) != 1 {
+			t.Fatalf("got %d execution logs, want 1", len(resp.Data.Logs))
+		}
+		return resp.Data.Logs
+	}
+
+	t.Run("debug is filtered at info level", func(t *testing.T) {
+		logs := getLogs(t, "")
+		want := []strategy.LogMessage{
+			{Level: strategy.LogLevelInfo, Message: "computing"},
+			{Level: strategy.LogLevelWarn, Message: "running hot"},
+		}
+		if !reflect.DeepEqual(logs[0].LogMessages, want) {
+			t.Errorf("log_messages = %v, want %v", logs[0].LogMessages, want)
+		}
+		if logs[0].StrategyID != "chatty" || logs[0].TriggerTopic != "sensors/temp" {
+			t.Errorf("log entry = %+v, want strategy chatty triggered by sensors/temp", logs[0])
+		}
+	})
+
+	t.Run("level parameter filters messages", func(t *testing.T) {
+		logs := getLogs(t, "?level=warn")
+		want := []strategy.LogMessage{{Level: strategy.LogLevelWarn, Message: "running hot"}}
+		if !reflect.DeepEqual(logs[0].LogMessages, want) {
+			t.Errorf("log_messages = %v, want %v", logs[0].LogMessages, want)
+		}
+	})
+
+	t.Run("invalid level is rejected", func(t *testing.T) {
+		rec := doRequest(t, server.handleAPITopicDetail, "GET", "/api/v1/topics/test/chatty/logs?level=loud", "")
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+		}
+	})
+}
+
+func TestHandleAPITopicLogsVerify(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Database.AuditHashChain = true
+	server := newTestServer(t, cfg)
+
+	rec := doRequest(t, server.handleAPIStrategiesCreate, "POST", "/api/v1/strategies", `{
+		"id": "doubler",
+		"name": "Doubler",
+		"code": "function process(context) { return context.inputs['sensors/temp'] * 2; }"
+	}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create strategy status = %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(t, server.handleAPITopicsCreate, "POST", "/api/v1/topics",
+		`{"name":"test/double","type":"internal","strategy_id":"doubler","inputs":["sensors/temp"]}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create topic status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	sensor, err := server.topicManager.AddExternalTopic("sensors/temp")
+	if err != nil {
+		t.Fatalf("AddExternalTopic failed: %v", err)
+	}
+	for _, value := range []float64{20, 21} {
+		if err := sensor.Emit(value); err != nil {
+			t.Fatalf("Emit failed: %v", err)
+		}
+	}
+
+	rec = doRequest(t, server.handleAPITopicDetail, "GET", "/api/v1/topics/test/double/logs/verify", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var resp struct {
+		Data TopicLogsVerifyResponse `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if resp.Data.Topic != "test/double" || !resp.Data.Enabled || !resp.Data.Valid || resp.Data.Verified != 2 {
+		t.Errorf("verification = %+v, want a valid chain of 2", resp.Data)
+	}
+}
+
+func TestHandleAPITopicLogsCSV(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	rec := doRequest(t, server.handleAPIStrategiesCreate, "POST", "/api/v1/strategies", `{
+		"id": "splitter",
+		"name": "Splitter",
+		"code": "function process(context) { context.emit('/battery', 80); return context.inputs['Temp'] * 2; }"
+	}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create strategy status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doRequest(t, server.handleAPITopicsCreate, "POST", "/api/v1/topics",
+		`{"name":"test/split","type":"internal","strategy_id":"splitter","inputs":["sensors/temp"],"input_names":{"sensors/temp":"Temp"}}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create topic status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	sensor, err := server.topicManager.AddExternalTopic("sensors/temp")
+	if err != nil {
+		t.Fatalf("AddExternalTopic failed: %v", err)
+	}
+	if err := sensor.Emit(21.5); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+
+	readCSV := func(t *testing.T, rec *httptest.ResponseRecorder) [][]string {
+		t.Helper()
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+		}
+		if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/csv") {
+			t.Errorf("Content-Type = %q, want text/csv", ct)
+		}
+		records, err := csv.NewReader(rec.Body).ReadAll()
+		if err != nil {
+			t.Fatalf("Failed to parse CSV: %v", err)
+		}
+		return records
+	}
+
+	records := readCSV(t, doRequest(t, server.handleAPITopicDetail, "GET", "/api/v1/topics/test/split/logs.csv", ""))
+
+	wantHeader := []string{"executed_at", "strategy_id", "trigger_topic", "duration_ms", "error", "inputs", "outputs"}
+	if len(records) != 2 {
+		t.Fatalf("got %d CSV records, want header and 1 row: %v", len(records), records)
+	}
+	if !reflect.DeepEqual(records[0], wantHeader) {
+		t.Errorf("header = %v, want %v", records[0], wantHeader)
+	}
+
+	row := records[1]
+	if _, err := time.Parse(time.RFC3339Nano, row[0]); err != nil {
+		t.Errorf("executed_at = %q, want RFC 3339 timestamp", row[0])
+	}
+	if row[1] != "splitter" || row[2] != "sensors/temp" || row[4] != "" {
+		t.Errorf("row = %v, want splitter triggered by sensors/temp without error", row)
+	}
+	if _, err := strconv.Atoi(row[3]); err != nil {
+		t.Errorf("duration_ms = %q, want integer", row[3])
+	}
+	if row[5] != `{"Temp":21.5}` {
+		t.Errorf("inputs = %s, want {\"Temp\":21.5}", row[5])
+	}
+	if row[6] != `{"":43,"/battery":80}` {
+		t.Errorf("outputs = %s, want {\"\":43,\"/battery\":80}", row[6])
+	}
+
+	t.Run("accept header selects csv", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/api/v1/topics/test/split/logs", nil)
+		req.Header.Set("Accept", "text/csv")
+		rec := httptest.NewRecorder()
+		server.handleAPITopicDetail(rec, req)
+
+		records := readCSV(t, rec)
+		if len(records) != 2 || !reflect.DeepEqual(records[0], wantHeader) {
+			t.Errorf("records = %v, want header and 1 row", records)
+		}
+	})
+}
+
+func TestHandleAPIEncryptedParameters(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Database.EncryptionKey = "test-encryption-key"
+	cfg.Web.AdminToken = "admin-secret"
+	server := newTestServer(t, cfg)
+
+	rec := doRequest(t, server.handleAPIStrategiesCreate, "POST", "/api/v1/strategies", `{
+		"id": "weather",
+		"name": "Weather",
+		"code": "function process(context) { return context.parameters.api_key; }",
+		"parameters": {"api_key": "sk-live-12345"}
+	}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create strategy status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	getParameters := func(t *testing.T, path, token string) map[string]interface{} {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		server.handleAPIStrategyDetail(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+		}
+		var resp struct {
+			Data StrategyDetail `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		return resp.Data.Parameters
+	}
+
+	t.Run("parameters are redacted by default", func(t *testing.T) {
+		params := getParameters(t, "/api/v1/strategies/weather", "")
+		if params["api_key"] != redactedParameterValue {
+			t.Errorf("api_key = %v, want %q", params["api_key"], redactedParameterValue)
+		}
+	})
+
+	t.Run("reveal requires the admin token", func(t *testing.T) {
+		for _, token := range []string{"", "wrong"} {
+			req := httptest.NewRequest("GET", "/api/v1/strategies/weather?reveal=true", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			server.handleAPIStrategyDetail(rec, req)
+			if rec.Code != http.StatusForbidden {
+				t.Errorf("token %q: status = %d, want %d", token, rec.Code, http.StatusForbidden)
+			}
+		}
+	})
+
+	t.Run("admin can reveal parameters", func(t *testing.T) {
+		params := getParameters(t, "/api/v1/strategies/weather?reveal=true", "admin-secret")
+		if params["api_key"] != "sk-live-12345" {
+			t.Errorf("api_key = %v, want sk-live-12345", params["api_key"])
+		}
+	})
+
+	t.Run("saving redacted values keeps the secret", func(t *testing.T) {
+		rec := doRequest(t, server.handleAPIStrategyDetail, "PUT", "/api/v1/strategies/weather", `{
+			"name": "Weather",
+			"code": "function process(context) { return context.parameters.api_key; }",
+			"parameters": {"api_key": "********", "units": "metric"}
+		}`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("update status = %d: %s", rec.Code, rec.Body.String())
+		}
+
+		params := getParameters(t, "/api/v1/strategies/weather?reveal=true", "admin-secret")
+		want := map[string]interface{}{"api_key": "sk-live-12345", "units": "metric"}
+		if !reflect.DeepEqual(params, want) {
+			t.Errorf("parameters = %v, want %v", params, want)
+		}
+	})
+}
+
+func TestHandleAPITopicsCreateRejectsInvalidNames(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	for _, name := range []string{"sensors/+/avg", "home/#", "/home/avg", "home/avg/", `home/\u0007avg`} {
+		body := `{"name":"` + name + `","type":"internal","strategy_id":"alias","inputs":["sensors/temp"]}`
+		rec := doRequest(t, server.handleAPITopicsCreate, "POST", "/api/v1/topics", body)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("create %q status = %d, want %d: %s", name, rec.Code, http.StatusBadRequest, rec.Body.String())
+		}
+	}
+
+	rec := doRequest(t, server.handleAPITopicDetail, "PUT", "/api/v1/topics/home/avg/%2B", `{"strategy_id":"alias"}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("update status = %d, want %d: %s", rec.Code, http.StatusBadRequest, rec.Body.String())
+	}
+
+	rec = doRequest(t, server.handleAPITopicsCreate, "POST", "/api/v1/topics",
+		`{"name":"home/avg","type":"internal","strategy_id":"alias","inputs":["sensors/temp"]}`)
+	if rec.Code != http.StatusCreated {
+		t.Errorf("create valid topic status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+}
+
+func TestHandleAPIMQTTStatus(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.MQTT.Broker = "tcp://broker:1883"
+
+	tests := []struct {
+		name   string
+		client *mqtt.Client
+	}{
+		{name: "no client"},
+		{name: "client not connected", client: mqtt.NewClient(cfg.MQTT, nil)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t, cfg)
+			server.mqttClient = tt.client
+
+			rec := doRequest(t, server.handleAPIMQTTStatus, "GET", "/api/v1/mqtt/status", "")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+			}
+
+			var response struct {
+				Data mqtt.Status `json:"data"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+
+			status := response.Data
+			if status.Broker != "tcp://broker:1883" || status.State != "closed" || status.Connected {
+				t.Errorf("status = %+v, want closed connection to tcp://broker:1883", status)
+			}
+			if status.LastConnectedAt != nil || status.MessagesIn != 0 || status.MessagesOut != 0 {
+				t.Errorf("status = %+v, want no connection history", status)
+			}
+		})
+	}
+
+	server := newTestServer(t, cfg)
+	rec := doRequest(t, server.handleAPIMQTTStatus, "POST", "/api/v1/mqtt/status", "")
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestHandleAPITopicDisplayName(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	rec := doRequest(t, server.handleAPITopicsCreate, "POST", "/api/v1/topics",
+		`{"name":"house/ground_floor/living_room/lights/ceiling","display_name":" Lounge lights ","type":"internal","strategy_id":"alias"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d, want %d: %s", rec.Code, http.StatusCreated, rec.Body.String())
+	}
+
+	// Lookup by display name resolves to the canonical topic
+	rec = doRequest(t, server.handleAPITopicDetail, "GET", "/api/v1/topics/Lounge%20lights?by=display_name", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("lookup status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	var detail struct {
+		Data TopicDetail `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &detail); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if detail.Data.Name != "house/ground_floor/living_room/lights/ceiling" || detail.Data.DisplayName != "Lounge lights" {
+		t.Errorf("detail name = %q, display name = %q", detail.Data.Name, detail.Data.DisplayName)
+	}
+
+	rec = doRequest(t, server.handleAPITopicsList, "GET", "/api/v1/topics?type=internal", "")
+	var list struct {
+		Data TopicListResponse `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(list.Data.Topics) != 1 || list.Data.Topics[0].DisplayName != "Lounge lights" {
+		t.Errorf("summaries = %+v, want display name Lounge lights", list.Data.Topics)
+	}
+
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		method   string
+		path     string
+		body     string
+		wantCode int
+	}{
+		{
+			name:     "unknown display name",
+			handler:  server.handleAPITopicDetail,
+			method:   "GET",
+			path:     "/api/v1/topics/Kitchen?by=display_name",
+			wantCode: http.StatusNotFound,
+		},
+		{
+			name:     "invalid lookup",
+			handler:  server.handleAPITopicDetail,
+			method:   "GET",
+			path:     "/api/v1/topics/Lounge%20lights?by=alias",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "duplicate display name",
+			handler:  server.handleAPITopicsCreate,
+			method:   "POST",
+			path:     "/api/v1/topics",
+			body:     `{"name":"house/lights/other","display_name":"Lounge lights","type":"internal","strategy_id":"alias"}`,
+			wantCode: http.StatusConflict,
+		},
+		{
+			name:     "display name too long",
+			handler:  server.handleAPITopicsCreate,
+			method:   "POST",
+			path:     "/api/v1/topics",
+			body:     `{"name":"house/lights/other","display_name":"` + strings.Repeat("x", topics.MaxDisplayNameLength+1) + `","type":"internal","strategy_id":"alias"}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "update keeps own display name",
+			handler:  server.handleAPITopicDetail,
+			method:   "PUT",
+			path:     "/api/v1/topics/house/ground_floor/living_room/lights/ceiling",
+			body:     `{"display_name":"Lounge lights","strategy_id":"alias"}`,
+			wantCode: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := doRequest(t, tt.handler, tt.method, tt.path, tt.body)
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body.String())
+			}
+		})
+	}
+
+	// Renaming the display name keeps the canonical name as the identity
+	rec = doRequest(t, server.handleAPITopicDetail, "PUT", "/api/v1/topics/Lounge%20lights?by=display_name",
+		`{"display_name":"Living room","strategy_id":"alias"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if name, found := server.topicManager.FindTopicByDisplayName("Living room"); !found || name != "house/ground_floor/living_room/lights/ceiling" {
+		t.Errorf("FindTopicByDisplayName() = %q, %v", name, found)
+	}
+	if _, found := server.topicManager.FindTopicByDisplayName("Lounge lights"); found {
+		t.Error("old display name still resolves")
+	}
+}
+
+func TestHandleAPIDashboardStrategyStats(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	for id, code := range map[string]string{
+		"works":  "function process(context) { return 1; }",
+		"breaks": "function process(context) { throw new Error('boom'); }",
+		"idle":   "function process(context) { return 2; }",
+	} {
+		if err := server.strategyEngine.AddStrategy(&strategy.Strategy{ID: id, Name: id, Code: code, Language: "javascript"}); err != nil {
+			t.Fatalf("AddStrategy failed: %v", err)
+		}
+	}
+	if _, err := server.strategyEngine.ExecuteStrategy("works", nil, nil, "", nil, nil); err != nil {
+		t.Fatalf("ExecuteStrategy failed: %v", err)
+	}
+	if _, err := server.strategyEngine.ExecuteStrategy("breaks", nil, nil, "", nil, nil); err == nil {
+		t.Fatal("expected the failing strategy to fail")
+	}
+
+	rec := doRequest(t, server.handleAPIDashboard, "GET", "/api/v1/dashboard", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var response struct {
+		Data DashboardResponse `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+
+	stats := response.Data.Stats.Strategies
+	total := server.strategyEngine.GetStrategyCount()
+	if stats.Total != total || stats.Failed != 1 || stats.Active != total-1 {
+		t.Errorf("strategy stats = %+v, want total %d with 1 failed", stats, total)
+	}
+}
+
+func TestHandleAPIStrategyTestTrace(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	strat := &strategy.Strategy{
+		ID:       "chatty",
+		Name:     "Chatty",
+		Code:     "function process(context) { context.emit('a', 1); context.emit('b', 2); context.warn('hot'); return context.inputs.value; }",
+		Language: "javascript",
+	}
+	if err := server.strategyEngine.AddStrategy(strat); err != nil {
+		t.Fatalf("AddStrategy failed: %v", err)
+	}
+
+	rec := doRequest(t, server.handleAPIStrategyDetail, "POST", "/api/v1/strategies/chatty/test", `{"inputs":{"value":5},"trace":true}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var response struct {
+		Data StrategyTestResponse `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	trace := response.Data.Trace
+	if trace == nil {
+		t.Fatalf("no trace in response: %s", rec.Body.String())
+	}
+	if trace.EmitCalls != 2 || trace.LogCalls[strategy.LogLevelWarn] != 1 {
+		t.Errorf("trace = %+v, want 2 emits and 1 warning", trace)
+	}
+	if response.Data.Result != 5.0 {
+		t.Errorf("result = %v, want 5", response.Data.Result)
+	}
+
+	rec = doRequest(t, server.handleAPIStrategyDetail, "POST", "/api/v1/strategies/chatty/test", `{"inputs":{"value":5}}`)
+	if strings.Contains(rec.Body.String(), `"trace"`) {
+		t.Errorf("untraced test returned a trace: %s", rec.Body.String())
+	}
+}
+
+func TestHandleAPIStrategyRunFixtures(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	strat := &strategy.Strategy{
+		ID:       "thermostat",
+		Name:     "Thermostat",
+		Code:     "function process(context) { return { heat: context.inputs.temp < context.parameters.target, target: context.parameters.target }; }",
+		Language: "javascript",
+		Parameters: map[string]interface{}{
+			"target": 20,
+		},
+	}
+	if err := server.stateManager.SaveStrategy(strat); err != nil {
+		t.Fatalf("SaveStrategy failed: %v", err)
+	}
+	if err := server.strategyEngine.AddStrategy(strat); err != nil {
+		t.Fatalf("AddStrategy failed: %v", err)
+	}
+
+	fixtures := []string{
+		`{"name":"cold","inputs":{"temp":15},"expected":{"heat":true,"target":20}}`,
+		`{"name":"warm","inputs":{"temp":25},"expected":{"heat":false,"target":20}}`,
+		`{"name":"wrong target","inputs":{"temp":25},"parameters":{"target":30},"expected":{"heat":false,"target":30}}`,
+	}
+	for _, body := range fixtures {
+		rec := doRequest(t, server.handleAPIStrategyDetail, "POST", "/api/v1/strategies/thermostat/fixtures", body)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("save fixture status = %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := doRequest(t, server.handleAPIStrategyDetail, "POST", "/api/v1/strategies/thermostat/fixtures", `{"name":"a/b","inputs":{}}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid fixture name status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	rec = doRequest(t, server.handleAPIStrategyDetail, "POST", "/api/v1/strategies/thermostat/test/run-fixtures", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("run fixtures status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var run struct {
+		Data FixtureRunResponse `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &run); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if run.Data.Total != 3 || run.Data.Passed != 2 || run.Data.Failed != 1 {
+		t.Fatalf("run = %+v, want 2 of 3 passed", run.Data)
+	}
+	for _, result := range run.Data.Results {
+		wantPassed := result.Name != "wrong target"
+		if result.Passed != wantPassed {
+			t.Errorf("fixture %s passed = %v, want %v (diff %v)", result.Name, result.Passed, wantPassed, result.Diff)
+		}
+	}
+	// Results are ordered by fixture name
+	failed := run.Data.Results[2]
+	if failed.Name != "wrong target" || !reflect.DeepEqual(failed.Diff, []string{"$.heat: expected false, got true"}) {
+		t.Errorf("failed fixture = %+v", failed)
+	}
+
+	rec = doRequest(t, server.handleAPIStrategyDetail, "DELETE", "/api/v1/strategies/thermostat/fixtures/wrong%20target", "")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("delete fixture status = %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(t, server.handleAPIStrategyDetail, "GET", "/api/v1/strategies/thermostat/fixtures", "")
+	var list struct {
+		Data []state.StrategyFixture `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(list.Data) != 2 {
+		t.Errorf("got %d fixtures after delete, want 2", len(list.Data))
+	}
+
+	rec = doRequest(t, server.handleAPIStrategyDetail, "POST", "/api/v1/strategies/missing/test/run-fixtures", "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown strategy status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestHandleAPIStrategyFixtureInjectedTime(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	strat := &strategy.Strategy{
+		ID:       "stamp",
+		Name:     "Stamp",
+		Code:     "function process(context) { return { at: getISO(), unix: getTime() }; }",
+		Language: "javascript",
+	}
+	if err := server.stateManager.SaveStrategy(strat); err != nil {
+		t.Fatalf("SaveStrategy failed: %v", err)
+	}
+	if err := server.strategyEngine.AddStrategy(strat); err != nil {
+		t.Fatalf("AddStrategy failed: %v", err)
+	}
+
+	body := `{"name":"noon","inputs":{},"now":"2024-06-01T12:00:00Z","expected":{"at":"2024-06-01T12:00:00Z","unix":1717243200}}`
+	rec := doRequest(t, server.handleAPIStrategyDetail, "POST", "/api/v1/strategies/stamp/fixtures", body)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("save fixture status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doRequest(t, server.handleAPIStrategyDetail, "POST", "/api/v1/strategies/stamp/test/run-fixtures", "")
+	var run struct {
+		Data FixtureRunResponse `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &run); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if run.Data.Passed != 1 {
+		t.Errorf("fixture with injected time did not pass: %+v", run.Data.Results)
+	}
+
+	rec = doRequest(t, server.handleAPIStrategyDetail, "POST", "/api/v1/strategies/stamp/test", `{"inputs":{},"now":"2024-06-01T12:00:00Z"}`)
+	var test struct {
+		Data StrategyTestResponse `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &test); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if result, _ := test.Data.Result.(map[string]interface{}); result["at"] != "2024-06-01T12:00:00Z" {
+		t.Errorf("test run result = %v, want the injected time", test.Data.Result)
+	}
+}
+
+func TestHandleAPIStrategyTestStack(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	strat := &strategy.Strategy{
+		ID:       "thrower",
+		Name:     "Thrower",
+		Code:     "function check(v) { throw new Error('bad ' + v); }\nfunction process(context) { return check(context.inputs.v); }",
+		Language: "javascript",
+	}
+	if err := server.strategyEngine.AddStrategy(strat); err != nil {
+		t.Fatalf("AddStrategy failed: %v", err)
+	}
+
+	rec := doRequest(t, server.handleAPIStrategyDetail, "POST", "/api/v1/strategies/thrower/test", `{"inputs":{"v":1}}`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var response struct {
+		Error struct {
+			Details StrategyTestResponse `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	stack := response.Error.Details.Stack
+	if !strings.HasPrefix(stack, "at check (") || !strings.Contains(stack, "at process (") {
+		t.Errorf("stack = %q, want check and process frames", stack)
+	}
+}
+
+func listTopics(t testing.TB, server *Server, query string) TopicListResponse {
+	t.Helper()
+
+	rec := doRequest(t, server.handleAPITopicsList, "GET", "/api/v1/topics"+query, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Data TopicListResponse `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	return resp.Data
+}
+
+func TestHandleAPITopicsListCache(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	for _, name := range []string{"house/a", "house/b", "house/c"} {
+		rec := doRequest(t, server.handleAPITopicsCreate, "POST", "/api/v1/topics",
+			`{"name":"`+name+`","type":"internal","strategy_id":"alias"}`)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("create %s status = %d: %s", name, rec.Code, rec.Body.String())
+		}
+	}
+
+	list := listTopics(t, server, "?type=internal&limit=2&page=2")
+	if list.Pagination.Total != 3 || list.Pagination.Pages != 2 || len(list.Topics) != 1 || list.Topics[0].Name != "house/c" {
+		t.Fatalf("page 2 = %+v, want house/c of 3", list)
+	}
+
+	// Updates through the API invalidate the cache
+	rec := doRequest(t, server.handleAPITopicDetail, "PUT", "/api/v1/topics/house/b",
+		`{"strategy_id":"alias","tags":["lights"]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update status = %d: %s", rec.Code, rec.Body.String())
+	}
+	list = listTopics(t, server, "?tag=lights")
+	if list.Pagination.Total != 1 || list.Topics[0].Name != "house/b" {
+		t.Errorf("tag filter after update = %+v, want house/b", list)
+	}
+
+	rec = doRequest(t, server.handleAPITopicDetail, "DELETE", "/api/v1/topics/house/a", "")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("delete status = %d: %s", rec.Code, rec.Body.String())
+	}
+	list = listTopics(t, server, "?type=internal")
+	if list.Pagination.Total != 2 || list.Topics[0].Name != "house/b" {
+		t.Errorf("topics after delete = %+v, want house/b and house/c", list)
+	}
+
+	// Changes made outside the API are only seen once the cache is invalidated
+	if err := server.stateManager.SaveTopicConfig(topics.InternalTopicConfig{
+		BaseTopicConfig: topics.BaseTopicConfig{Name: "house/d", Type: topics.TopicTypeInternal},
+		StrategyID:      "alias",
+	}); err != nil {
+		t.Fatalf("SaveTopicConfig failed: %v", err)
+	}
+	if list = listTopics(t, server, "?type=internal"); list.Pagination.Total != 2 {
+		t.Errorf("cached total = %d, want 2", list.Pagination.Total)
+	}
+	server.invalidateTopicList()
+	if list = listTopics(t, server, "?type=internal"); list.Pagination.Total != 3 {
+		t.Errorf("total after invalidation = %d, want 3", list.Pagination.Total)
+	}
+
+	// New MQTT topics change the in-memory counts and rebuild the list, and
+	// values are always current
+	if err := server.topicManager.HandleMQTTMessage(mqtt.Event{Topic: "sensors/temp", Payload: []byte("21.5")}); err != nil {
+		t.Fatalf("HandleMQTTMessage failed: %v", err)
+	}
+	list = listTopics(t, server, "?type=external")
+	if list.Pagination.Total != 1 || list.Topics[0].LastValue != 21.5 {
+		t.Fatalf("external topics = %+v, want sensors/temp = 21.5", list)
+	}
+	if err := server.topicManager.HandleMQTTMessage(mqtt.Event{Topic: "sensors/temp", Payload: []byte("22")}); err != nil {
+		t.Fatalf("HandleMQTTMessage failed: %v", err)
+	}
+	if list = listTopics(t, server, "?type=external"); list.Topics[0].LastValue != 22.0 {
+		t.Errorf("external value = %v, want 22", list.Topics[0].LastValue)
+	}
+}
+
+func TestHandleAPITopicsListStatusFilter(t *testing.T) {
+	server := newTestServer(t, nil)
+
+	rec := doRequest(t, server.handleAPIStrategiesCreate, "POST", "/api/v1/strategies", `{
+		"id": "fails",
+		"name": "Fails",
+		"code": "function process(context) { throw new Error('sensor offline'); }"
+	}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create strategy status = %d: %s", rec.Code, rec.Body.String())
+	}
+	rec = doRequest(t, server.handleAPIStrategiesCreate, "POST", "/api/v1/strategies", `{
+		"id": "passes",
+		"name": "Passes",
+		"code": "function process(context) { return context.triggeringValue; }"
+	}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create strategy status = %d: %s", rec.Code, rec.Body.String())
+	}
+	for _, body := range []string{
+		`{"name":"house/healthy","type":"internal","strategy_id":"passes","inputs":["sensors/temp"]}`,
+		`{"name":"house/failing","type":"internal","strategy_id":"fails","inputs":["sensors/temp"]}`,
+		`{"name":"house/stale","type":"internal","strategy_id":"passes","inputs":["sensors/other"],"ttl":"1m"}`,
+	} {
+		rec := doRequest(t, server.handleAPITopicsCreate, "POST", "/api/v1/topics", body)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("create topic status = %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+	if err := server.topicManager.HandleMQTTMessage(mqtt.Event{Topic: "sensors/temp", Payload: []byte("21.5")}); err != nil {
+		t.Fatalf("HandleMQTTMessage failed: %v", err)
+	}
+
+	tests := []struct {
+		status string
+		want   []string
+	}{
+		{status: "error", want: []string{"house/failing"}},
+		{status: "stale", want: []string{"house/stale"}},
+		// In-memory external topics report no status, so only database topics match
+		{status: "ok", want: []string{"house/healthy"}},
+		{status: "circuit-open", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			list := listTopics(t, server, "?status="+tt.status)
+			got := []string{}
+			for _, topic := range list.Topics {
+				got = append(got, topic.Name)
+				if string(topic.Status) != tt.status {
+					t.Errorf("topic %s status = %s, want %s", topic.Name, topic.Status, tt.status)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) || list.Pagination.Total != len(tt.want) {
+				t.Errorf("topics = %v (total %d), want %v", got, list.Pagination.Total, tt.want)
+			}
+		})
+	}
+
+	rec = doRequest(t, server.handleAPITopicsList, "GET", "/api/v1/topics?status=broken", "")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid status filter = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func BenchmarkHandleAPITopicsList(b *testing.B) {
+	server := newTestServer(b, nil)
+	for i := 0; i < 2000; i++ {
+		if err := server.stateManager.SaveTopicConfig(topics.InternalTopicConfig{
+			BaseTopicConfig: topics.BaseTopicConfig{Name: fmt.Sprintf("bench/topic/%04d", i), Type: topics.TopicTypeInternal},
+			StrategyID:      "alias",
+		}); err != nil {
+			b.Fatalf("SaveTopicConfig failed: %v", err)
+		}
+	}
+
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			listTopics(b, server, "?page=3&limit=50")
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			server.invalidateTopicList()
+			listTopics(b, server, "?page=3&limit=50")
+		}
+	})
+}
+
+func TestHandleAPITopicsGraphDOT(t *testing.T) {
+	server := newTestServer(t, nil)
+	for _, name := range []string{"sensors/kitchen/temp", "sensors/kitchen/humidity", "sensors/hall/humidity"} {
+		if _, err := server.topicManager.AddExternalTopic(name); err != nil {
+			t.Fatalf("AddExternalTopic failed: %v", err)
+		}
+	}
+	server.topicManager.AddSystemTopic("system/ticker/1s", nil)
+	if _, err := server.topicManager.AddInternalTopic("heating/demand",
+		[]string{"sensors/kitchen/temp", "sensors/+/humidity", "system/ticker/1s", `weather/"outside"`},
+		map[string]string{"sensors/kitchen/temp": "Temperature"}, "demand", nil, false, false); err != nil {
+		t.Fatalf("AddInternalTopic failed: %v", err)
+	}
+
+	rec := doRequest(t, server.handleAPITopicsGraphDOT, "GET", "/api/v1/topics/graph.dot", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/vnd.graphviz") {
+		t.Errorf("Content-Type = %q", got)
+	}
+
+	want := `digraph topics {
+  rankdir=LR;
+  node [shape=box, style="rounded,filled", fillcolor="white"];
+  "heating/demand" [fillcolor="palegreen"];
+  "sensors/hall/humidity" [fillcolor="lightblue"];
+  "sensors/kitchen/humidity" [fillcolor="lightblue"];
+  "sensors/kitchen/temp" [fillcolor="lightblue"];
+  "system/ticker/1s" [fillcolor="lightgrey"];
+  "weather/\"outside\"" [style="rounded,dashed"];
+  "sensors/hall/humidity" -> "heating/demand";
+  "sensors/kitchen/humidity" -> "heating/demand";
+  "sensors/kitchen/temp" -> "heating/demand" [label="Temperature"];
+  "system/ticker/1s" -> "heating/demand";
+  "weather/\"outside\"" -> "heating/demand";
+}
+`
+	if got := rec.Body.String(); got != want {
+		t.Errorf("unexpected DOT output:\n%s\nwant:\n%s", got, want)
+	}
+
+	rec = doRequest(t, server.handleAPITopicsGraphDOT, "POST", "/api/v1/topics/graph.dot", "")
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for POST, got %d", rec.Code)
+	}
+}
+
+func TestHandleAPITopicCycles(t *testing.T) {
+	server := newTestServer(t, nil)
+	if _, err := server.topicManager.AddInternalTopic("a", []string{"b"}, nil, "alias", nil, false, false); err != nil {
+		t.Fatalf("AddInternalTopic failed: %v", err)
+	}
+
+	rec := doRequest(t, server.handleAPITopicsCreate, "POST", "/api/v1/topics",
+		`{"name":"b","type":"internal","strategy_id":"alias","inputs":["a"]}`)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), `b -\u003e a -\u003e b`) {
+		t.Fatalf("expected 400 naming the cycle, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if _, err := server.stateManager.LoadTopicConfig("b"); err == nil {
+		t.Error("cyclic topic was saved")
+	}
+
+	// A cycle made outside the API is reported
+	b, err := server.topicManager.AddInternalTopic("b", nil, nil, "alias", nil, false, false)
+	if err != nil {
+		t.Fatalf("AddInternalTopic failed: %v", err)
+	}
+	b.AddInput("a")
+
+	rec = doRequest(t, server.handleAPITopicCycles, "GET", "/api/v1/topics/cycles", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got, want := strings.TrimSpace(rec.Body.String()), `{"success":true,"data":{"cycles":[["a","b","a"]]}}`; got != want {
+		t.Errorf("body = %s, want %s", got, want)
+	}
+}
+
+func TestHandleAPITopicDeleteCascade(t *testing.T) {
+	server := newTestServer(t, nil)
+	for _, body := range []string{
+		`{"name":"house/src","type":"internal","strategy_id":"alias"}`,
+		`{"name":"house/a","type":"internal","strategy_id":"alias","inputs":["house/src","sensors/temp"]}`,
+		`{"name":"house/b","type":"internal","strategy_id":"alias","inputs":["house/a"]}`,
+	} {
+		rec := doRequest(t, server.handleAPITopicsCreate, "POST", "/api/v1/topics", body)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("create status = %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := doRequest(t, server.handleAPITopicDetail, "DELETE", "/api/v1/topics/house/src?cascade=orphan", "")
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unknown cascade, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = doRequest(t, server.handleAPITopicDetail, "DELETE", "/api/v1/topics/house/src?cascade=detach", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("delete status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if got, want := strings.TrimSpace(rec.Body.String()), `{"success":true,"data":{"deleted":["house/src"],"detached":["house/a"]}}`; got != want {
+		t.Errorf("body = %s, want %s", got, want)
+	}
+	saved, err := server.stateManager.LoadTopicConfig("house/a")
+	if err != nil {
+		t.Fatalf("LoadTopicConfig failed: %v", err)
+	}
+	if inputs := saved.(topics.InternalTopicConfig).Inputs; !reflect.DeepEqual(inputs, []string{"sensors/temp"}) {
+		t.Errorf("saved inputs = %v, want the deleted input removed", inputs)
+	}
+
+	rec = doRequest(t, server.handleAPITopicDetail, "DELETE", "/api/v1/topics/house/a?cascade=delete", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("delete status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if got, want := strings.TrimSpace(rec.Body.String()), `{"success":true,"data":{"deleted":["house/a","house/b"]}}`; got != want {
+		t.Errorf("body = %s, want %s", got, want)
+	}
+	for _, name := range []string{"house/a", "house/b"} {
+		if server.topicManager.GetTopic(name) != nil {
+			t.Errorf("%s was not deleted", name)
+		}
+		if _, err := server.stateManager.LoadTopicConfig(name); err == nil {
+			t.Errorf("%s is still saved", name)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/state"
+	"github.com/denwilliams/go-mqtt-automation/pkg/strategy"
+)
+
+// Strategy fixture structures
+type StrategyFixtureRequest struct {
+	Name         string                 `json:"name"`
+	Inputs       map[string]interface{} `json:"inputs"`
+	Parameters   map[string]interface{} `json:"parameters,omitempty"`
+	TriggerTopic string                 `json:"trigger_topic,omitempty"`
+	Expected     interface{}            `json:"expected"`
+	Now          *time.Time             `json:"now,omitempty"` // getTime/getISO return this during the run
+}
+
+type FixtureRunResponse struct {
+	StrategyID string          `json:"strategy_id"`
+	Total      int             `json:"total"`
+	Passed     int             `json:"passed"`
+	Failed     int             `json:"failed"`
+	Results    []FixtureResult `json:"results"`
+}
+
+type FixtureResult struct {
+	Name     string      `json:"name"`
+	Passed   bool        `json:"passed"`
+	Expected interface{} `json:"expected"`
+	Actual   interface{} `json:"actual"`
+	Diff     []string    `json:"diff,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+func (s *Server) handleAPIStrategyFixtures(w http.ResponseWriter, r *http.Request, strategyID, fixtureName string) {
+	if _, err := s.strategyEngine.GetStrategy(strategyID); err != nil {
+		writeAPIError(w, http.StatusNotFound, "NOT_FOUND", "Strategy not found", nil)
+		return
+	}
+
+	switch {
+	case fixtureName == "" && r.Method == "GET":
+		fixtures, err := s.stateManager.LoadStrategyFixtures(strategyID)
+		if err != nil {
+			s.logger.Printf("Failed to load fixtures for strategy %s: %v", strategyID, err)
+			writeAPIError(w, http.StatusInternalServerError, "DATABASE_ERROR", "Failed to load fixtures", nil)
+			return
+		}
+		if fixtures == nil {
+			fixtures = []state.StrategyFixture{}
+		}
+		writeAPIResponse(w, fixtures)
+	case fixtureName == "" && r.Method == "POST":
+		s.handleAPIStrategyFixtureSave(w, r, strategyID)
+	case fixtureName != "" && r.Method == "DELETE":
+		if err := s.stateManager.DeleteStrategyFixture(strategyID, fixtureName); err != nil {
+			s.logger.Printf("Failed to delete fixture %s for strategy %s: %v", fixtureName, strategyID, err)
+			writeAPIError(w, http.StatusInternalServerError, "DATABASE_ERROR", "Failed to delete fixture", nil)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeAPIError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed", nil)
+	}
+}
+
+func (s *Server) handleAPIStrategyFixtureSave(w http.ResponseWriter, r *http.Request, strategyID string) {
+	var req StrategyFixtureRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, "INVALID_JSON", "Invalid JSON in request body", nil)
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" || strings.Contains(name, "/") {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", "Fixture name is required and must not contain '/'", nil)
+		return
+	}
+
+	fixture := state.StrategyFixture{
+		StrategyID:   strategyID,
+		Name:         name,
+		Inputs:       req.Inputs,
+		Parameters:   req.Parameters,
+		TriggerTopic: req.TriggerTopic,
+		Expected:     req.Expected,
+		Now:          req.Now,
+	}
+	if err := s.stateManager.SaveStrategyFixture(fixture); err != nil {
+		s.logger.Printf("Failed to save fixture: %v", err)
+		writeAPIError(w, http.StatusInternalServerError, "DATABASE_ERROR", "Failed to save fixture", nil)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	writeAPIResponse(w, map[string]string{"message": "Fixture saved successfully"})
+}
+
+func (s *Server) handleAPIStrategyRunFixtures(w http.ResponseWriter, r *http.Request, strategyID string) {
+	if _, err := s.strategyEngine.GetStrategy(strategyID); err != nil {
+		writeAPIError(w, http.StatusNotFound, "NOT_FOUND", "Strategy not found", nil)
+		return
+	}
+
+	fixtures, err := s.stateManager.LoadStrategyFixtures(strategyID)
+	if err != nil {
+		s.logger.Printf("Failed to load fixtures for strategy %s: %v", strategyID, err)
+		writeAPIError(w, http.StatusInternalServerError, "DATABASE_ERROR", "Failed to load fixtures", nil)
+		return
+	}
+
+	response := FixtureRunResponse{
+		StrategyID: strategyID,
+		Total:      len(fixtures),
+		Results:    make([]FixtureResult, 0, len(fixtures)),
+	}
+	for _, fixture := range fixtures {
+		result := s.runFixture(fixture)
+		if result.Passed {
+			response.Passed++
+		} else {
+			response.Failed++
+		}
+		response.Results = append(response.Results, result)
+	}
+
+	writeAPIResponse(w, response)
+}
+
+// handleAPIStrategySelfTest runs the tests embedded in the strategy's code
+func (s *Server) handleAPIStrategySelfTest(w http.ResponseWriter, r *http.Request, strategyID string) {
+	if _, err := s.strategyEngine.GetStrategy(strategyID); err != nil {
+		writeAPIError(w, http.StatusNotFound, "NOT_FOUND", "Strategy not found", nil)
+		return
+	}
+
+	report, err := s.strategyEngine.SelfTest(strategyID)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "VALIDATION_ERROR", fmt.Sprintf("Failed to load self-tests: %v", err), nil)
+		return
+	}
+
+	writeAPIResponse(w, report)
+}
+
+// runFixture executes a strategy with a fixture's inputs and compares the
+// main output with the expected value
+func (s *Server) runFixture(fixture state.StrategyFixture) FixtureResult {
+	result := FixtureResult{Name: fixture.Name, Expected: fixture.Expected}
+
+	triggerTopic := fixture.TriggerTopic
+	if triggerTopic == "" {
+		triggerTopic = "test"
+	}
+	var options strategy.ExecuteOptions
+	if fixture.Now != nil {
+		options.Now = *fixture.Now
+	}
+	events, _, _, err := s.strategyEngine.ExecuteStrategyWithOptions(fixture.StrategyID, fixture.Inputs, nil, triggerTopic, nil, fixture.Parameters, options)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	for _, event := range events {
+		if event.Topic == "" {
+			result.Actual = event.Value
+			break
+		}
+	}
+
+	// Compare as JSON so numbers from the strategy match decoded fixtures
+	actual, err := strategy.NormalizeJSON(result.Actual)
+	if err != nil {
+		result.Error = fmt.Sprintf("output is not JSON encodable: %v", err)
+		return result
+	}
+	result.Actual = actual
+	result.Diff = strategy.DiffValues("$", fixture.Expected, actual)
+	result.Passed = len(result.Diff) == 0
+	return result
+}
@@ -0,0 +1,77 @@
+package web
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/topics"
+)
+
+// dotNodeColors fills graph nodes by topic type; inputs matching no known
+// topic use the default
+var dotNodeColors = map[topics.TopicType]string{
+	topics.TopicTypeExternal: "lightblue",
+	topics.TopicTypeInternal: "palegreen",
+	topics.TopicTypeSystem:   "lightgrey",
+}
+
+// handleAPITopicsGraphDOT serves the topic dependency graph in Graphviz DOT
+// format, e.g. for rendering with `dot -Tpng`
+func (s *Server) handleAPITopicsGraphDOT(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeAPIError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed", nil)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/vnd.graphviz; charset=utf-8")
+	writeDOT(w, s.topicManager.BuildDependencyGraph())
+}
+
+// TopicCyclesResponse lists the dependency cycles among the topics
+type TopicCyclesResponse struct {
+	Cycles []topics.DependencyCycle `json:"cycles"`
+}
+
+// handleAPITopicCycles reports dependency cycles, e.g. from topic configs
+// edited directly in the database
+func (s *Server) handleAPITopicCycles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeAPIError(w, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", "Method not allowed", nil)
+		return
+	}
+
+	writeAPIResponse(w, TopicCyclesResponse{Cycles: s.topicManager.DetectCycles()})
+}
+
+// writeDOT writes a dependency graph as a Graphviz digraph flowing from
+// inputs to the topics that depend on them
+func writeDOT(w io.Writer, graph topics.DependencyGraph) {
+	fmt.Fprintln(w, "digraph topics {")
+	fmt.Fprintln(w, "  rankdir=LR;")
+	fmt.Fprintln(w, `  node [shape=box, style="rounded,filled", fillcolor="white"];`)
+	for _, node := range graph.Nodes {
+		if color, ok := dotNodeColors[node.Type]; ok {
+			fmt.Fprintf(w, "  %s [fillcolor=%s];\n", dotQuote(node.Name), dotQuote(color))
+		} else {
+			fmt.Fprintf(w, "  %s [style=\"rounded,dashed\"];\n", dotQuote(node.Name))
+		}
+	}
+	for _, edge := range graph.Edges {
+		if edge.Label != "" {
+			fmt.Fprintf(w, "  %s -> %s [label=%s];\n", dotQuote(edge.From), dotQuote(edge.To), dotQuote(edge.Label))
+		} else {
+			fmt.Fprintf(w, "  %s -> %s;\n", dotQuote(edge.From), dotQuote(edge.To))
+		}
+	}
+	fmt.Fprintln(w, "}")
+}
+
+// dotQuote returns s as a DOT quoted string
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	return `"` + s + `"`
+}
@@ -0,0 +1,57 @@
+package web
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// redactedParameterValue replaces parameter values in API responses when
+// parameters are encrypted at rest
+const redactedParameterValue = "********"
+
+// revealParameters reports whether parameter values may be returned as-is.
+// Values are always visible when encryption is disabled; otherwise the client
+// must ask with ?reveal=true and present the admin token. ok is false when a
+// reveal was requested without a valid token.
+func (s *Server) revealParameters(r *http.Request) (reveal bool, ok bool) {
+	if !s.stateManager.ParametersEncrypted() {
+		return true, true
+	}
+	if r.URL.Query().Get("reveal") != "true" {
+		return false, true
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	adminToken := s.config.Web.AdminToken
+	if adminToken == "" || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+		return false, false
+	}
+	return true, true
+}
+
+// redactParameters returns a copy of parameters with every value replaced by
+// redactedParameterValue
+func redactParameters(parameters map[string]interface{}) map[string]interface{} {
+	if parameters == nil {
+		return nil
+	}
+	redacted := make(map[string]interface{}, len(parameters))
+	for key := range parameters {
+		redacted[key] = redactedParameterValue
+	}
+	return redacted
+}
+
+// restoreRedactedParameters replaces redacted values sent back by a client
+// with the stored values, so saving a redacted form keeps existing secrets
+func restoreRedactedParameters(parameters, existing map[string]interface{}) {
+	for key, value := range parameters {
+		if value != redactedParameterValue {
+			continue
+		}
+		if previous, found := existing[key]; found {
+			parameters[key] = previous
+		}
+	}
+}
@@ -23,6 +23,12 @@ type Server struct {
 	logger         *log.Logger
 	server         *http.Server
 	startTime      time.Time
+
+	// stream forwards topic updates to WebSocket clients
+	stream *streamHub
+
+	// topicList caches the merged topic list served by the topics API
+	topicList topicListCache
 }
 
 func NewServer(cfg *config.Config, topicManager *topics.Manager, strategyEngine *strategy.Engine,
@@ -32,6 +38,12 @@ func NewServer(cfg *config.Config, topicManager *topics.Manager, strategyEngine
 		logger = log.Default()
 	}
 
+	// Validated with the config; empty disables coalescing
+	var coalesceInterval time.Duration
+	if cfg.Web.StreamCoalesceInterval != "" {
+		coalesceInterval, _ = time.ParseDuration(cfg.Web.StreamCoalesceInterval)
+	}
+
 	server := &Server{
 		config:         cfg,
 		topicManager:   topicManager,
@@ -40,6 +52,13 @@ func NewServer(cfg *config.Config, topicManager *topics.Manager, strategyEngine
 		mqttClient:     mqttClient,
 		logger:         logger,
 		startTime:      time.Now(),
+		stream:         newStreamHub(logger, coalesceInterval),
+	}
+
+	if topicManager != nil {
+		if err := topicManager.RegisterSink("websocket", server.stream, 0); err != nil {
+			return nil, err
+		}
 	}
 
 	return server, nil
@@ -62,6 +81,8 @@ func (s *Server) Start() error {
 func (s *Server) Shutdown(ctx context.Context) error {
 	if s.server != nil {
 		s.logger.Println("Shutting down web server...")
+		// Shutdown does not close hijacked connections
+		s.stream.close()
 		return s.server.Shutdown(ctx)
 	}
 	return nil
@@ -74,16 +95,29 @@ func (s *Server) setupRoutes() {
 	// Topics API
 	http.HandleFunc("/api/v1/topics", s.handleAPIV1Topics)
 	http.HandleFunc("/api/v1/topics/", s.handleAPITopicDetail)
+	http.HandleFunc("/api/v1/topics/graph.dot", s.handleAPITopicsGraphDOT)
+	http.HandleFunc("/api/v1/topics/cycles", s.handleAPITopicCycles)
 
 	// Strategies API
 	http.HandleFunc("/api/v1/strategies", s.handleAPIV1Strategies)
 	http.HandleFunc("/api/v1/strategies/", s.handleAPIStrategyDetail)
 
+	// Device templates API
+	http.HandleFunc("/api/v1/templates", s.handleAPITemplates)
+	http.HandleFunc("/api/v1/templates/", s.handleAPITemplateDetail)
+
 	// System API
 	http.HandleFunc("/api/v1/system", s.handleAPISystem)
 	http.HandleFunc("/api/v1/system/info", s.handleAPISystemInfo)
 	http.HandleFunc("/api/v1/system/stats", s.handleAPISystemStats)
 	http.HandleFunc("/api/v1/system/activity", s.handleAPISystemActivity)
+	http.HandleFunc("/api/v1/system/events", s.handleAPISystemEvents)
+
+	// Live topic updates over WebSocket
+	http.HandleFunc("/api/v1/stream", s.handleStream)
+
+	// MQTT API
+	http.HandleFunc("/api/v1/mqtt/status", s.handleAPIMQTTStatus)
 
 	// Metrics endpoint (Prometheus format)
 	http.Handle("/metrics", promhttp.Handler())
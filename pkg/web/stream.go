@@ -0,0 +1,395 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/mqtt"
+	"github.com/denwilliams/go-mqtt-automation/pkg/topics"
+	"github.com/gorilla/websocket"
+)
+
+// streamSendBuffer is how many messages a WebSocket client may fall behind by
+// before further updates to it are dropped
+const streamSendBuffer = 256
+
+const streamWriteTimeout = 10 * time.Second
+
+var streamUpgrader = websocket.Upgrader{
+	// The API allows any origin, so the stream does too
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// StreamFilter limits the updates sent for a subscription. With neither field
+// set every update is sent.
+type StreamFilter struct {
+	// Threshold sends an update only when the value crosses it, in either
+	// direction
+	Threshold *float64 `json:"threshold,omitempty"`
+	// Delta sends an update only when the value differs from the last one
+	// sent by more than it
+	Delta *float64 `json:"delta,omitempty"`
+}
+
+// StreamRequest is a message sent by a stream client
+type StreamRequest struct {
+	Action string        `json:"action"` // subscribe or unsubscribe
+	ID     string        `json:"id"`
+	Topic  string        `json:"topic,omitempty"` // MQTT-style pattern; empty matches every topic
+	Filter *StreamFilter `json:"filter,omitempty"`
+}
+
+// StreamMessage is a message sent to a stream client
+type StreamMessage struct {
+	Type          string          `json:"type"` // subscribed, unsubscribed, update, batch or error
+	Subscription  string          `json:"subscription,omitempty"`
+	Topic         string          `json:"topic,omitempty"`
+	Value         interface{}     `json:"value,omitempty"`
+	PreviousValue interface{}     `json:"previous_value,omitempty"`
+	Timestamp     *time.Time      `json:"timestamp,omitempty"`
+	Updates       []StreamMessage `json:"updates,omitempty"` // the coalesced updates of a batch
+	Error         string          `json:"error,omitempty"`
+}
+
+func (f *StreamFilter) validate() error {
+	if f == nil {
+		return nil
+	}
+	if f.Threshold != nil && f.Delta != nil {
+		return fmt.Errorf("filter cannot have both threshold and delta")
+	}
+	if f.Delta != nil && *f.Delta < 0 {
+		return fmt.Errorf("filter delta must not be negative")
+	}
+	return nil
+}
+
+// streamSubscription is one subscription of a client, with the values its
+// filter compares against per topic
+type streamSubscription struct {
+	id     string
+	topic  string
+	filter *StreamFilter
+	last   map[string]float64
+}
+
+// accept reports whether an update passes the subscription's filter
+func (s *streamSubscription) accept(event topics.TopicEvent) bool {
+	if s.filter == nil || (s.filter.Threshold == nil && s.filter.Delta == nil) {
+		return true
+	}
+	value, ok := streamNumber(event.Value)
+	if !ok {
+		return false
+	}
+	last, seen := s.last[event.TopicName]
+
+	if s.filter.Threshold != nil {
+		// Until the subscription has seen the topic, compare with the value
+		// it replaced
+		if !seen {
+			last, seen = streamNumber(event.PreviousValue)
+		}
+		s.last[event.TopicName] = value
+		threshold := *s.filter.Threshold
+		return seen && (last < threshold) != (value < threshold)
+	}
+
+	if seen && math.Abs(value-last) <= *s.filter.Delta {
+		return false
+	}
+	s.last[event.TopicName] = value
+	return true
+}
+
+func streamNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// streamClient is one WebSocket connection
+type streamClient struct {
+	conn *websocket.Conn
+	send chan StreamMessage
+
+	mutex         sync.Mutex
+	subscriptions map[string]*streamSubscription
+
+	// pending holds the latest coalesced update per subscription and topic,
+	// keyed in pendingOrder in the order they first arrived
+	pending      map[string]StreamMessage
+	pendingOrder []string
+}
+
+// queue sends a message without blocking, reporting whether it was queued
+func (c *streamClient) queue(message StreamMessage) bool {
+	select {
+	case c.send <- message:
+		return true
+	default:
+		return false
+	}
+}
+
+// coalesce holds an update until the next flush, replacing any pending
+// update of the same subscription and topic. The replaced update's previous
+// value is kept so the batch reports the change since the last one sent.
+// Must be called with the client's mutex held.
+func (c *streamClient) coalesce(message StreamMessage) {
+	key := message.Subscription + "\x00" + message.Topic
+	if existing, ok := c.pending[key]; ok {
+		message.PreviousValue = existing.PreviousValue
+	} else {
+		c.pendingOrder = append(c.pendingOrder, key)
+	}
+	if c.pending == nil {
+		c.pending = make(map[string]StreamMessage)
+	}
+	c.pending[key] = message
+}
+
+// dropPending discards the pending updates of a subscription. Must be called
+// with the client's mutex held.
+func (c *streamClient) dropPending(subscriptionID string) {
+	order := c.pendingOrder[:0]
+	for _, key := range c.pendingOrder {
+		if c.pending[key].Subscription == subscriptionID {
+			delete(c.pending, key)
+			continue
+		}
+		order = append(order, key)
+	}
+	c.pendingOrder = order
+}
+
+// takePending removes the pending updates, in arrival order
+func (c *streamClient) takePending() []StreamMessage {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(c.pendingOrder) == 0 {
+		return nil
+	}
+	updates := make([]StreamMessage, 0, len(c.pendingOrder))
+	for _, key := range c.pendingOrder {
+		updates = append(updates, c.pending[key])
+	}
+	c.pending = nil
+	c.pendingOrder = nil
+	return updates
+}
+
+// streamHub forwards topic updates to WebSocket clients. It is registered as
+// an event sink, so updates reach it off the propagation path.
+type streamHub struct {
+	logger *log.Logger
+
+	// coalesceInterval batches each client's updates into one message per
+	// interval; 0 sends updates as they happen
+	coalesceInterval time.Duration
+
+	mutex   sync.RWMutex
+	clients map[*streamClient]struct{}
+}
+
+func newStreamHub(logger *log.Logger, coalesceInterval time.Duration) *streamHub {
+	return &streamHub{
+		logger:           logger,
+		coalesceInterval: coalesceInterval,
+		clients:          make(map[*streamClient]struct{}),
+	}
+}
+
+// HandleEvent sends an update to every subscription it matches and passes
+func (h *streamHub) HandleEvent(event topics.TopicEvent) error {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	for client := range h.clients {
+		client.mutex.Lock()
+		for _, sub := range client.subscriptions {
+			if !mqtt.TopicMatches(sub.topic, event.TopicName) || !sub.accept(event) {
+				continue
+			}
+			timestamp := event.Timestamp
+			message := StreamMessage{
+				Type:          "update",
+				Subscription:  sub.id,
+				Topic:         event.TopicName,
+				Value:         event.Value,
+				PreviousValue: event.PreviousValue,
+				Timestamp:     &timestamp,
+			}
+			if h.coalesceInterval > 0 {
+				client.coalesce(message)
+				continue
+			}
+			if !client.queue(message) {
+				h.logger.Printf("WebSocket client %s is full; dropped update of %s", client.conn.RemoteAddr(), event.TopicName)
+			}
+		}
+		client.mutex.Unlock()
+	}
+	return nil
+}
+
+// flush sends a client's pending updates as one batch message
+func (h *streamHub) flush(client *streamClient) {
+	updates := client.takePending()
+	if len(updates) == 0 {
+		return
+	}
+	if !client.queue(StreamMessage{Type: "batch", Updates: updates}) {
+		h.logger.Printf("WebSocket client %s is full; dropped a batch of %d updates", client.conn.RemoteAddr(), len(updates))
+	}
+}
+
+// flushEvery flushes a client's pending updates every coalesce interval
+// until stop is closed
+func (h *streamHub) flushEvery(client *streamClient, stop <-chan struct{}) {
+	ticker := time.NewTicker(h.coalesceInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			h.flush(client)
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (h *streamHub) add(client *streamClient) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.clients[client] = struct{}{}
+}
+
+func (h *streamHub) remove(client *streamClient) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	delete(h.clients, client)
+}
+
+// close disconnects every client
+func (h *streamHub) close() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for client := range h.clients {
+		client.conn.Close()
+	}
+}
+
+// handle applies a client request, returning the reply
+func (h *streamHub) handle(client *streamClient, request StreamRequest) StreamMessage {
+	if request.ID == "" {
+		return StreamMessage{Type: "error", Error: "subscription id is required"}
+	}
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	switch request.Action {
+	case "subscribe":
+		if err := request.Filter.validate(); err != nil {
+			return StreamMessage{Type: "error", Subscription: request.ID, Error: err.Error()}
+		}
+		topic := request.Topic
+		if topic == "" {
+			topic = "#"
+		}
+		client.subscriptions[request.ID] = &streamSubscription{
+			id:     request.ID,
+			topic:  topic,
+			filter: request.Filter,
+			last:   make(map[string]float64),
+		}
+		return StreamMessage{Type: "subscribed", Subscription: request.ID}
+	case "unsubscribe":
+		delete(client.subscriptions, request.ID)
+		client.dropPending(request.ID)
+		return StreamMessage{Type: "unsubscribed", Subscription: request.ID}
+	default:
+		return StreamMessage{Type: "error", Subscription: request.ID, Error: fmt.Sprintf("unknown action %q", request.Action)}
+	}
+}
+
+// handleStream upgrades the request to a WebSocket streaming topic updates
+// for the subscriptions the client makes
+func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
+	conn, err := streamUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written the error response
+		s.logger.Printf("WebSocket upgrade failed: %v", err)
+		return
+	}
+
+	client := &streamClient{
+		conn:          conn,
+		send:          make(chan StreamMessage, streamSendBuffer),
+		subscriptions: make(map[string]*streamSubscription),
+	}
+	s.stream.add(client)
+
+	stopFlush := make(chan struct{})
+	flushDone := make(chan struct{})
+	go func() {
+		defer close(flushDone)
+		if s.stream.coalesceInterval > 0 {
+			s.stream.flushEvery(client, stopFlush)
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for message := range client.send {
+			conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if err := conn.WriteJSON(message); err != nil {
+				conn.Close()
+				return
+			}
+		}
+	}()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		reply := StreamMessage{Type: "error", Error: "invalid request"}
+		var request StreamRequest
+		if err := json.Unmarshal(data, &request); err == nil {
+			reply = s.stream.handle(client, request)
+		}
+		if !client.queue(reply) {
+			break
+		}
+	}
+
+	s.stream.remove(client)
+	close(stopFlush)
+	<-flushDone
+	close(client.send)
+	<-done
+	conn.Close()
+}
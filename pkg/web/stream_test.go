@@ -0,0 +1,255 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/config"
+	"github.com/denwilliams/go-mqtt-automation/pkg/topics"
+	"github.com/gorilla/websocket"
+)
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+func TestStreamSubscriptionAccept(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   *StreamFilter
+		previous interface{} // previous value of the first update
+		values   []interface{}
+		expected []bool
+	}{
+		{
+			name:     "no filter",
+			values:   []interface{}{1.5, 1.5, "on"},
+			expected: []bool{true, true, true},
+		},
+		{
+			name:     "threshold crossings",
+			filter:   &StreamFilter{Threshold: floatPtr(25)},
+			values:   []interface{}{20.5, 24.5, 26.5, 27.5, 23.5, 25.0},
+			expected: []bool{false, false, true, false, true, true},
+		},
+		{
+			name:     "threshold compares first update with previous value",
+			filter:   &StreamFilter{Threshold: floatPtr(25)},
+			previous: 30.5,
+			values:   []interface{}{20.5},
+			expected: []bool{true},
+		},
+		{
+			name:     "threshold ignores non-numeric values",
+			filter:   &StreamFilter{Threshold: floatPtr(25)},
+			values:   []interface{}{20.5, "hot", 26.5},
+			expected: []bool{false, false, true},
+		},
+		{
+			name:     "delta from last sent value",
+			filter:   &StreamFilter{Delta: floatPtr(1)},
+			values:   []interface{}{10.0, 10.5, 10.9, 11.25, 11.5, 9.5},
+			expected: []bool{true, false, false, true, false, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub := &streamSubscription{id: "test", topic: "#", filter: tt.filter, last: make(map[string]float64)}
+			previous := tt.previous
+			for i, value := range tt.values {
+				got := sub.accept(topics.TopicEvent{TopicName: "sensors/temp", Value: value, PreviousValue: previous})
+				if got != tt.expected[i] {
+					t.Errorf("update %d (%v): expected accept %v, got %v", i, value, tt.expected[i], got)
+				}
+				previous = value
+			}
+		})
+	}
+}
+
+func TestStreamFilterValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  *StreamFilter
+		wantErr bool
+	}{
+		{name: "none", filter: nil},
+		{name: "threshold", filter: &StreamFilter{Threshold: floatPtr(-5)}},
+		{name: "delta", filter: &StreamFilter{Delta: floatPtr(0.5)}},
+		{name: "both", filter: &StreamFilter{Threshold: floatPtr(1), Delta: floatPtr(1)}, wantErr: true},
+		{name: "negative delta", filter: &StreamFilter{Delta: floatPtr(-1)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.filter.validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStreamForwardsOnlyQualifyingUpdates(t *testing.T) {
+	server := newTestServer(t, nil)
+	httpServer := httptest.NewServer(http.HandlerFunc(server.handleStream))
+	defer httpServer.Close()
+
+	url := "ws" + strings.TrimPrefix(httpServer.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	request := StreamRequest{Action: "subscribe", ID: "hot", Topic: "sensors/+/temp", Filter: &StreamFilter{Threshold: floatPtr(25)}}
+	if err := conn.WriteJSON(request); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	var reply StreamMessage
+	if err := conn.ReadJSON(&reply); err != nil {
+		t.Fatalf("Failed to read reply: %v", err)
+	}
+	if reply.Type != "subscribed" || reply.Subscription != "hot" {
+		t.Fatalf("expected subscribed reply, got %+v", reply)
+	}
+
+	updates := []topics.TopicEvent{
+		{TopicName: "sensors/kitchen/temp", Value: 20.5},
+		{TopicName: "sensors/kitchen/temp", Value: 24.5, PreviousValue: 20.5},
+		{TopicName: "sensors/kitchen/humidity", Value: 60.5},
+		{TopicName: "sensors/kitchen/temp", Value: 26.5, PreviousValue: 24.5},
+		{TopicName: "sensors/kitchen/temp", Value: 27.5, PreviousValue: 26.5},
+		{TopicName: "sensors/kitchen/temp", Value: 23.5, PreviousValue: 27.5},
+	}
+	for _, update := range updates {
+		if err := server.stream.HandleEvent(update); err != nil {
+			t.Fatalf("HandleEvent failed: %v", err)
+		}
+	}
+
+	for _, expected := range []float64{26.5, 23.5} {
+		var message StreamMessage
+		if err := conn.ReadJSON(&message); err != nil {
+			t.Fatalf("Failed to read update: %v", err)
+		}
+		if message.Type != "update" || message.Subscription != "hot" || message.Topic != "sensors/kitchen/temp" {
+			t.Fatalf("unexpected message %+v", message)
+		}
+		if message.Value != expected {
+			t.Errorf("expected value %v, got %v", expected, message.Value)
+		}
+	}
+}
+
+func TestStreamCoalescesRapidUpdates(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Web.StreamCoalesceInterval = "50ms"
+	server := newTestServer(t, cfg)
+	httpServer := httptest.NewServer(http.HandlerFunc(server.handleStream))
+	defer httpServer.Close()
+
+	url := "ws" + strings.TrimPrefix(httpServer.URL, "http")
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	if err := conn.WriteJSON(StreamRequest{Action: "subscribe", ID: "power", Topic: "house/#"}); err != nil {
+		t.Fatalf("Failed to subscribe: %v", err)
+	}
+	var reply StreamMessage
+	if err := conn.ReadJSON(&reply); err != nil || reply.Type != "subscribed" {
+		t.Fatalf("expected subscribed reply, got %+v (%v)", reply, err)
+	}
+
+	const updates = 100
+	for i := 1; i <= updates; i++ {
+		event := topics.TopicEvent{TopicName: "house/power", Value: float64(i) + 0.5, PreviousValue: float64(i) - 0.5}
+		if err := server.stream.HandleEvent(event); err != nil {
+			t.Fatalf("HandleEvent failed: %v", err)
+		}
+	}
+
+	// Every message is a batch with at most one update per topic; the last
+	// update carries the final value
+	received := 0
+	for {
+		var message StreamMessage
+		if err := conn.ReadJSON(&message); err != nil {
+			t.Fatalf("Failed to read batch: %v", err)
+		}
+		if message.Type != "batch" || len(message.Updates) != 1 {
+			t.Fatalf("expected a batch of one update, got %+v", message)
+		}
+		update := message.Updates[0]
+		if update.Subscription != "power" || update.Topic != "house/power" {
+			t.Fatalf("unexpected update %+v", update)
+		}
+		received++
+		if update.Value == float64(updates)+0.5 {
+			break
+		}
+	}
+	if received >= updates {
+		t.Errorf("received %d batches for %d updates, expected them to be coalesced", received, updates)
+	}
+}
+
+func TestStreamClientCoalesce(t *testing.T) {
+	client := &streamClient{subscriptions: make(map[string]*streamSubscription)}
+	client.coalesce(StreamMessage{Type: "update", Subscription: "a", Topic: "house/power", Value: 1.5, PreviousValue: 0.5})
+	client.coalesce(StreamMessage{Type: "update", Subscription: "a", Topic: "house/temp", Value: 20.5})
+	client.coalesce(StreamMessage{Type: "update", Subscription: "a", Topic: "house/power", Value: 2.5, PreviousValue: 1.5})
+	client.coalesce(StreamMessage{Type: "update", Subscription: "b", Topic: "house/power", Value: 2.5})
+	client.dropPending("b")
+
+	updates := client.takePending()
+	if len(updates) != 2 {
+		t.Fatalf("expected 2 pending updates, got %+v", updates)
+	}
+	// Latest value, previous value of the first coalesced update, first-arrival order
+	if updates[0].Topic != "house/power" || updates[0].Value != 2.5 || updates[0].PreviousValue != 0.5 {
+		t.Errorf("unexpected coalesced update %+v", updates[0])
+	}
+	if updates[1].Topic != "house/temp" || updates[1].Value != 20.5 {
+		t.Errorf("unexpected update %+v", updates[1])
+	}
+	if pending := client.takePending(); pending != nil {
+		t.Errorf("expected nothing pending after take, got %+v", pending)
+	}
+}
+
+func TestStreamRejectsInvalidRequests(t *testing.T) {
+	server := newTestServer(t, nil)
+	client := &streamClient{subscriptions: make(map[string]*streamSubscription)}
+
+	tests := []struct {
+		name    string
+		request StreamRequest
+		errMsg  string
+	}{
+		{name: "missing id", request: StreamRequest{Action: "subscribe"}, errMsg: "id is required"},
+		{name: "unknown action", request: StreamRequest{Action: "watch", ID: "a"}, errMsg: "unknown action"},
+		{name: "invalid filter", request: StreamRequest{Action: "subscribe", ID: "a", Filter: &StreamFilter{Delta: floatPtr(-1)}}, errMsg: "delta"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reply := server.stream.handle(client, tt.request)
+			if reply.Type != "error" || !strings.Contains(reply.Error, tt.errMsg) {
+				t.Errorf("expected error containing %q, got %+v", tt.errMsg, reply)
+			}
+		})
+	}
+	if len(client.subscriptions) != 0 {
+		t.Errorf("expected no subscriptions, got %d", len(client.subscriptions))
+	}
+}
@@ -0,0 +1,209 @@
+package web
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/denwilliams/go-mqtt-automation/pkg/topics"
+)
+
+// topicListCache holds the merged list of database and in-memory topics,
+// sorted by name. It is rebuilt after topics are created, updated or deleted
+// through the API, or when the number of topics in memory changes (e.g. a new
+// MQTT topic is seen). Values and statuses are refreshed for each page served.
+type topicListCache struct {
+	mutex  sync.Mutex
+	topics []TopicSummary
+	counts map[topics.TopicType]int
+	valid  bool
+}
+
+// invalidateTopicList discards the cached topic list
+func (s *Server) invalidateTopicList() {
+	s.topicList.mutex.Lock()
+	defer s.topicList.mutex.Unlock()
+
+	s.topicList.valid = false
+	s.topicList.topics = nil
+}
+
+// topicSummaries returns the cached topic list, rebuilding it when stale. The
+// returned slice is shared and must not be modified.
+func (s *Server) topicSummaries() ([]TopicSummary, error) {
+	counts := s.topicManager.GetTopicCount()
+
+	s.topicList.mutex.Lock()
+	defer s.topicList.mutex.Unlock()
+
+	if s.topicList.valid && reflect.DeepEqual(counts, s.topicList.counts) {
+		return s.topicList.topics, nil
+	}
+
+	summaries, err := s.buildTopicSummaries()
+	if err != nil {
+		return nil, err
+	}
+	s.topicList.topics = summaries
+	s.topicList.counts = counts
+	s.topicList.valid = true
+	return summaries, nil
+}
+
+// buildTopicSummaries merges topics from the database with child and external
+// topics from memory
+func (s *Server) buildTopicSummaries() ([]TopicSummary, error) {
+	redact := s.stateManager.ParametersEncrypted()
+
+	// Get all topics from database (already ordered by name)
+	allTopicConfigs, err := s.stateManager.LoadAllTopicConfigs()
+	if err != nil {
+		return nil, err
+	}
+
+	// Get child topics and external topics from in-memory topic manager
+	childTopics := s.topicManager.GetChildTopics()
+	externalTopics := s.topicManager.GetExternalTopics()
+
+	topicList := make([]TopicSummary, 0, len(allTopicConfigs)+len(childTopics)+len(externalTopics))
+
+	// Process database topics
+	for _, config := range allTopicConfigs {
+		// Extract common fields based on config type
+		var summary TopicSummary
+
+		switch cfg := config.(type) {
+		case topics.BaseTopicConfig:
+			summary = TopicSummary{
+				Name:        cfg.Name,
+				DisplayName: topics.DisplayName(cfg.Config),
+				Type:        string(cfg.Type),
+				LastValue:   cfg.LastValue,
+				LastUpdated: cfg.LastUpdated,
+				Tags:        cfg.Tags,
+			}
+		case topics.InternalTopicConfig:
+			summary = TopicSummary{
+				Name:        cfg.Name,
+				DisplayName: topics.DisplayName(cfg.Config),
+				Type:        string(cfg.Type),
+				LastValue:   cfg.LastValue,
+				LastUpdated: cfg.LastUpdated,
+				Inputs:      cfg.Inputs,
+				InputNames:  cfg.InputNames,
+				StrategyID:  cfg.StrategyID,
+				Parameters:  cfg.Parameters,
+				EmitToMQTT:  cfg.EmitToMQTT,
+				Tags:        cfg.Tags,
+			}
+			if redact {
+				summary.Parameters = redactParameters(summary.Parameters)
+			}
+		case topics.SystemTopicConfig:
+			summary = TopicSummary{
+				Name:        cfg.Name,
+				DisplayName: topics.DisplayName(cfg.Config),
+				Type:        string(cfg.Type),
+				LastValue:   cfg.LastValue,
+				LastUpdated: cfg.LastUpdated,
+				Tags:        cfg.Tags,
+			}
+		default:
+			s.logger.Printf("Unknown topic config type: %T", cfg)
+			continue
+		}
+
+		summary.hasStatus = true
+		topicList = append(topicList, summary)
+	}
+
+	// Add child topics from in-memory topic manager
+	for _, childConfig := range childTopics {
+		summary := TopicSummary{
+			Name:        childConfig.Name,
+			DisplayName: topics.DisplayName(childConfig.Config),
+			Type:        string(childConfig.Type),
+			LastValue:   childConfig.LastValue,
+			LastUpdated: childConfig.LastUpdated,
+			Inputs:      childConfig.Inputs,
+			InputNames:  childConfig.InputNames,
+			StrategyID:  childConfig.StrategyID,
+			Parameters:  childConfig.Parameters,
+			EmitToMQTT:  childConfig.EmitToMQTT,
+			Tags:        childConfig.Tags,
+		}
+		if redact {
+			summary.Parameters = redactParameters(summary.Parameters)
+		}
+		topicList = append(topicList, summary)
+	}
+
+	// Add external topics from in-memory topic manager
+	for _, externalConfig := range externalTopics {
+		topicList = append(topicList, TopicSummary{
+			Name:        externalConfig.Name,
+			DisplayName: topics.DisplayName(externalConfig.Config),
+			Type:        string(externalConfig.Type),
+			LastValue:   externalConfig.LastValue,
+			LastUpdated: externalConfig.LastUpdated,
+			Tags:        externalConfig.Tags,
+		})
+	}
+
+	// Sort topics by name since we merged from multiple sources
+	sort.Slice(topicList, func(i, j int) bool {
+		return topicList[i].Name < topicList[j].Name
+	})
+
+	return topicList, nil
+}
+
+// refreshTopicSummary updates a summary with the topic's current value and status
+func (s *Server) refreshTopicSummary(summary *TopicSummary) {
+	if topic := s.topicManager.GetTopic(summary.Name); topic != nil {
+		summary.LastValue = topic.LastValue()
+		summary.LastUpdated = topic.LastUpdated()
+	}
+	if summary.hasStatus {
+		summary.Status, _ = s.topicManager.TopicStatus(summary.Name)
+	}
+}
+
+// matchesStatusFilter reports whether a topic's current status is status.
+// Statuses change without invalidating the cache, so they are read from the
+// topic manager rather than the cached summary; topics without a reported
+// status never match.
+func (s *Server) matchesStatusFilter(summary TopicSummary, status topics.TopicStatus) bool {
+	if status == "" {
+		return true
+	}
+	if !summary.hasStatus {
+		return false
+	}
+	current, err := s.topicManager.TopicStatus(summary.Name)
+	return err == nil && current == status
+}
+
+// matchesTopicFilters applies the type, name (case-insensitive substring) and
+// tag (case-insensitive) filters of the topic list
+func matchesTopicFilters(summary TopicSummary, topicType, nameFilter, tagFilter string) bool {
+	if topicType != "" && summary.Type != topicType {
+		return false
+	}
+
+	if nameFilter != "" && !strings.Contains(strings.ToLower(summary.Name), strings.ToLower(nameFilter)) {
+		return false
+	}
+
+	if tagFilter != "" {
+		for _, tag := range summary.Tags {
+			if strings.EqualFold(tag, tagFilter) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}